@@ -23,45 +23,112 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
-        "/api/download": {
-            "post": {
-                "description": "Create a new download job for a YouTube video or audio",
-                "consumes": [
-                    "application/json"
-                ],
+        "/api/admin/archive/{date}": {
+            "get": {
+                "description": "One day's archived job records (JSON lines, UTC day), written when ARCHIVE_JOBS is enabled",
                 "produces": [
-                    "application/json"
+                    "application/x-ndjson"
                 ],
                 "tags": [
-                    "download"
+                    "admin"
                 ],
-                "summary": "Create download job",
+                "summary": "Download job archive",
                 "parameters": [
                     {
-                        "description": "Download request",
-                        "name": "request",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/models.DownloadRequest"
-                        }
+                        "type": "string",
+                        "description": "Admin API key",
+                        "name": "X-API-Key",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Day (YYYY-MM-DD, UTC)",
+                        "name": "date",
+                        "in": "path",
+                        "required": true
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "OK",
+                        "description": "JSON lines",
                         "schema": {
-                            "$ref": "#/definitions/models.DownloadResponse"
+                            "type": "file"
                         }
                     },
                     "400": {
-                        "description": "Validation error",
+                        "description": "Invalid date",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Missing or invalid admin API key",
                         "schema": {
                             "$ref": "#/definitions/utils.ErrorResponse"
                         }
                     },
                     "404": {
-                        "description": "No stream found",
+                        "description": "No archive for that day",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/admin/jobs": {
+            "get": {
+                "description": "Jobs on disk, newest first, with the client that created them (abuse investigation)",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "List jobs",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Admin API key",
+                        "name": "X-API-Key",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only jobs for this video ID",
+                        "name": "videoId",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only jobs created from this client IP",
+                        "name": "ip",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Only jobs flagged possiblyStuck",
+                        "name": "stuck",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Max jobs returned (default 100, max 1000)",
+                        "name": "limit",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.AdminJobsResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Missing or invalid admin API key",
                         "schema": {
                             "$ref": "#/definitions/utils.ErrorResponse"
                         }
@@ -75,17 +142,24 @@ const docTemplate = `{
                 }
             }
         },
-        "/api/jobs/{id}": {
-            "delete": {
-                "description": "Delete a job and its associated files",
+        "/api/admin/jobs/{id}": {
+            "get": {
+                "description": "Full stored metadata of a job, including its creating client",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "jobs"
+                    "admin"
                 ],
-                "summary": "Delete job",
+                "summary": "Get job metadata",
                 "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Admin API key",
+                        "name": "X-API-Key",
+                        "in": "header",
+                        "required": true
+                    },
                     {
                         "type": "string",
                         "description": "Job ID",
@@ -98,7 +172,7 @@ const docTemplate = `{
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/models.DeleteResponse"
+                            "$ref": "#/definitions/models.Meta"
                         }
                     },
                     "400": {
@@ -107,6 +181,12 @@ const docTemplate = `{
                             "$ref": "#/definitions/utils.ErrorResponse"
                         }
                     },
+                    "403": {
+                        "description": "Missing or invalid admin API key",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
                     "404": {
                         "description": "Job not found",
                         "schema": {
@@ -114,7 +194,7 @@ const docTemplate = `{
                         }
                     },
                     "500": {
-                        "description": "Delete failed",
+                        "description": "Server error",
                         "schema": {
                             "$ref": "#/definitions/utils.ErrorResponse"
                         }
@@ -122,34 +202,59 @@ const docTemplate = `{
                 }
             }
         },
-        "/api/status/{id}": {
-            "get": {
-                "description": "Check the status and progress of a download job",
+        "/api/admin/jobs/{id}/fail": {
+            "post": {
+                "description": "Set an unfinished job to error with an operator-supplied reason so clients stop polling",
+                "consumes": [
+                    "application/json"
+                ],
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "status"
+                    "admin"
                 ],
-                "summary": "Get job status",
+                "summary": "Force-fail a stuck job",
                 "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Admin API key",
+                        "name": "X-API-Key",
+                        "in": "header",
+                        "required": true
+                    },
                     {
                         "type": "string",
                         "description": "Job ID",
                         "name": "id",
                         "in": "path",
                         "required": true
+                    },
+                    {
+                        "description": "Failure reason",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.FailJobRequest"
+                        }
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/models.StatusResponse"
+                            "$ref": "#/definitions/models.Meta"
                         }
                     },
                     "400": {
-                        "description": "Invalid job ID",
+                        "description": "Validation error",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Missing or invalid admin API key",
                         "schema": {
                             "$ref": "#/definitions/utils.ErrorResponse"
                         }
@@ -160,6 +265,18 @@ const docTemplate = `{
                             "$ref": "#/definitions/utils.ErrorResponse"
                         }
                     },
+                    "409": {
+                        "description": "Job already finished",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "410": {
+                        "description": "Job deleted",
+                        "schema": {
+                            "$ref": "#/definitions/utils.JobDeletedResponse"
+                        }
+                    },
                     "500": {
                         "description": "Server error",
                         "schema": {
@@ -169,73 +286,77 @@ const docTemplate = `{
                 }
             }
         },
-        "/files/{id}/{filename}": {
-            "get": {
-                "description": "Download the merged output file",
+        "/api/admin/jobs/{id}/requeue": {
+            "post": {
+                "description": "Reset an unfinished or failed job to pending and run it again. Stream URLs are re-extracted; cached and partially downloaded sources are reused.",
                 "produces": [
-                    "application/octet-stream"
+                    "application/json"
                 ],
                 "tags": [
-                    "files"
+                    "admin"
                 ],
-                "summary": "Download file",
+                "summary": "Requeue a stuck job",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Job ID",
-                        "name": "id",
-                        "in": "path",
+                        "description": "Admin API key",
+                        "name": "X-API-Key",
+                        "in": "header",
                         "required": true
                     },
                     {
                         "type": "string",
-                        "description": "Output filename",
-                        "name": "filename",
+                        "description": "Job ID",
+                        "name": "id",
                         "in": "path",
                         "required": true
-                    },
-                    {
-                        "type": "string",
-                        "description": "Signed URL token",
-                        "name": "token",
-                        "in": "query",
-                        "required": true
-                    },
-                    {
-                        "type": "integer",
-                        "description": "Expiration timestamp",
-                        "name": "expires",
-                        "in": "query",
-                        "required": true
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Output file",
+                        "description": "OK",
                         "schema": {
-                            "type": "file"
+                            "$ref": "#/definitions/models.Meta"
                         }
                     },
                     "400": {
-                        "description": "Invalid parameters",
+                        "description": "Invalid job ID",
                         "schema": {
                             "$ref": "#/definitions/utils.ErrorResponse"
                         }
                     },
-                    "401": {
-                        "description": "Missing auth",
+                    "403": {
+                        "description": "Missing or invalid admin API key",
                         "schema": {
                             "$ref": "#/definitions/utils.ErrorResponse"
                         }
                     },
-                    "403": {
-                        "description": "Invalid token",
+                    "404": {
+                        "description": "Job or selected streams not found",
                         "schema": {
                             "$ref": "#/definitions/utils.ErrorResponse"
                         }
                     },
-                    "404": {
-                        "description": "Not found",
+                    "409": {
+                        "description": "Job is running or completed",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "410": {
+                        "description": "Job deleted",
+                        "schema": {
+                            "$ref": "#/definitions/utils.JobDeletedResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Server error",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Extract API rate limited",
                         "schema": {
                             "$ref": "#/definitions/utils.ErrorResponse"
                         }
@@ -243,306 +364,3886 @@ const docTemplate = `{
                 }
             }
         },
-        "/health": {
+        "/api/admin/load": {
             "get": {
-                "description": "Check if the server is running",
+                "description": "Backlog estimate used for admission control",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "health"
+                    "admin"
+                ],
+                "summary": "Processing load",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Admin API key",
+                        "name": "X-API-Key",
+                        "in": "header",
+                        "required": true
+                    }
                 ],
-                "summary": "Health check",
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/models.HealthResponse"
+                            "$ref": "#/definitions/models.LoadResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Missing or invalid admin API key",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
                         }
                     }
                 }
-            }
-        },
-        "/stream/{id}": {
-            "get": {
-                "description": "Stream video/audio using FFmpeg pipe (realtime remux/convert)",
+            },
+            "put": {
+                "description": "Temporarily replace the max estimated wait above which new jobs get 503",
+                "consumes": [
+                    "application/json"
+                ],
                 "produces": [
-                    "application/octet-stream"
+                    "application/json"
                 ],
                 "tags": [
-                    "stream"
+                    "admin"
                 ],
-                "summary": "Stream video/audio",
+                "summary": "Override admission threshold",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Job ID",
-                        "name": "id",
-                        "in": "path",
-                        "required": true
-                    },
-                    {
-                        "type": "string",
-                        "description": "Signed URL token",
-                        "name": "token",
-                        "in": "query",
+                        "description": "Admin API key",
+                        "name": "X-API-Key",
+                        "in": "header",
                         "required": true
                     },
                     {
-                        "type": "integer",
-                        "description": "Expiration timestamp",
-                        "name": "expires",
-                        "in": "query",
-                        "required": true
+                        "description": "Threshold override",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.LoadOverrideRequest"
+                        }
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Media stream",
+                        "description": "OK",
                         "schema": {
-                            "type": "file"
+                            "$ref": "#/definitions/models.LoadResponse"
                         }
                     },
-                    "307": {
-                        "description": "Redirect to download URL"
-                    },
                     "400": {
-                        "description": "Invalid parameters",
+                        "description": "Validation error",
                         "schema": {
                             "$ref": "#/definitions/utils.ErrorResponse"
                         }
                     },
-                    "401": {
-                        "description": "Missing auth",
+                    "403": {
+                        "description": "Missing or invalid admin API key",
                         "schema": {
                             "$ref": "#/definitions/utils.ErrorResponse"
                         }
+                    }
+                }
+            }
+        },
+        "/api/admin/log-levels": {
+            "get": {
+                "description": "Default log level and the level in effect for each module",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Log levels",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Admin API key",
+                        "name": "X-API-Key",
+                        "in": "header",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.LogLevelsResponse"
+                        }
                     },
                     "403": {
-                        "description": "Invalid token",
+                        "description": "Missing or invalid admin API key",
                         "schema": {
                             "$ref": "#/definitions/utils.ErrorResponse"
                         }
-                    },
-                    "404": {
-                        "description": "Not found",
+                    }
+                }
+            },
+            "put": {
+                "description": "Change the default or per-module log levels until restart",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Change log levels",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Admin API key",
+                        "name": "X-API-Key",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "description": "Levels to change",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.LogLevelsRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.LogLevelsResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Validation error",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Missing or invalid admin API key",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/admin/usage": {
+            "get": {
+                "description": "Origin and served bytes of the jobs on disk, summed per API key fingerprint (billing).\nJobs leave this view when they are cleaned up; the job archive keeps their usageKey.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Usage per API key",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Admin API key",
+                        "name": "X-API-Key",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Only jobs created at or after this time (Unix ms)",
+                        "name": "since",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.AdminUsageResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Missing or invalid admin API key",
                         "schema": {
                             "$ref": "#/definitions/utils.ErrorResponse"
                         }
                     },
                     "500": {
-                        "description": "Stream failed",
+                        "description": "Server error",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/capabilities": {
+            "get": {
+                "description": "Supported formats, qualities, OS profiles, limits and feature flags",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "capabilities"
+                ],
+                "summary": "Server capabilities",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.CapabilitiesResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/download": {
+            "post": {
+                "description": "Create a new download job for a YouTube video or audio",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "download"
+                ],
+                "summary": "Create download job",
+                "parameters": [
+                    {
+                        "description": "Download request",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.DownloadRequest"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "API key (required for priority=high)",
+                        "name": "X-API-Key",
+                        "in": "header"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Retry-safe key; repeats return the original response",
+                        "name": "Idempotency-Key",
+                        "in": "header"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Respond 200 instead of 202 (deprecated)",
+                        "name": "legacy",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "With legacy=true (deprecated)",
+                        "schema": {
+                            "$ref": "#/definitions/models.DownloadResponse"
+                        }
+                    },
+                    "202": {
+                        "description": "Job accepted; Location is the signed status URL",
+                        "schema": {
+                            "$ref": "#/definitions/models.DownloadResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Validation error",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "High priority without an authorized API key",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "No stream found",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Idempotency key reused with a different body",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "413": {
+                        "description": "Request body too large",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "422": {
+                        "description": "Protected content, lossy source with audio.strictLossless, or over the size limits (TOO_LARGE)",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Server error",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Overloaded; retry after estimatedWaitSeconds",
+                        "schema": {
+                            "$ref": "#/definitions/utils.OverloadResponse"
+                        }
+                    },
+                    "504": {
+                        "description": "Timed out before the job was created",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "507": {
+                        "description": "Storage full; retry later",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/groups": {
+            "post": {
+                "description": "Group existing jobs. With group.archive, the primary outputs of the members are zipped once all have finished; members without one are listed in the archive's manifest.json. The group is removed with its last member.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "groups"
+                ],
+                "summary": "Create job group",
+                "parameters": [
+                    {
+                        "description": "Member jobs, each with its status token",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.GroupRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/models.GroupResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Validation error",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Invalid member token",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Member job not found",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Member already in a group",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "410": {
+                        "description": "Member job deleted",
+                        "schema": {
+                            "$ref": "#/definitions/utils.JobDeletedResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Server error",
                         "schema": {
                             "$ref": "#/definitions/utils.ErrorResponse"
                         }
                     }
                 }
             }
-        }
-    },
-    "definitions": {
-        "models.AudioConfig": {
-            "description": "Audio configuration",
+        },
+        "/api/groups/{id}": {
+            "get": {
+                "description": "Member statuses and, with group.archive, the archive and its signed download URL once built",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "groups"
+                ],
+                "summary": "Get job group status",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Group ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Compact signed token (statusUrl)",
+                        "name": "t",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.GroupStatusResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid group ID",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Missing token",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Invalid or expired token",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Group not found",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Server error",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Remove a group and its archive. With members=true the member jobs are soft-deleted too (restorable like DELETE /api/jobs/:id); otherwise they leave the group.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "groups"
+                ],
+                "summary": "Delete job group",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Group ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Compact signed token (statusUrl)",
+                        "name": "t",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Also delete the member jobs",
+                        "name": "members",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.GroupDeleteResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid group ID",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Missing token",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Invalid or expired token",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Group not found",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Delete failed",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/info/{videoId}": {
+            "get": {
+                "description": "Title, duration, channel, upload date, view count, thumbnail and audio tracks of a video. No job is created; the extract result is cached and shared with downloads.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "download"
+                ],
+                "summary": "Get video metadata",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "YouTube video ID",
+                        "name": "videoId",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.VideoInfoResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid video ID",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to fetch video metadata",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Metadata service rate limited",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/jobs/{id}": {
+            "delete": {
+                "description": "Soft-delete a job. It is hidden immediately (410 Gone) and its files are removed after a grace period, during which it can be restored. Idempotent: deleting a deleted or removed job succeeds with alreadyDeleted.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "jobs"
+                ],
+                "summary": "Delete job",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Job ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.DeleteResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid job ID",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Delete failed",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/jobs/{id}/convert": {
+            "post": {
+                "description": "Re-run only the FFmpeg phase against a completed job's retained sources (keepSources) to produce another format, bitrate or trim. Track it in the status response's outputs.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "jobs"
+                ],
+                "summary": "Render an additional output",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Job ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Output settings",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.ConvertRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.ConvertResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request or job not completed",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Job not found",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Sources not kept, or job busy",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "410": {
+                        "description": "Job deleted",
+                        "schema": {
+                            "$ref": "#/definitions/utils.JobDeletedResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Server error",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/jobs/{id}/extend": {
+            "post": {
+                "description": "Push the job's expiresAt to MaxJobAge from now, capped at MaxJobLifetime after creation",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "jobs"
+                ],
+                "summary": "Extend job expiry",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Job ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Compact signed status token",
+                        "name": "t",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.ExtendResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid job ID",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Missing token",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Invalid or expired token",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Job not found",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Job expired or extension cap reached",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "410": {
+                        "description": "Job deleted",
+                        "schema": {
+                            "$ref": "#/definitions/utils.JobDeletedResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Extend failed",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/jobs/{id}/restore": {
+            "post": {
+                "description": "Restore a soft-deleted job within the grace period",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "jobs"
+                ],
+                "summary": "Restore job",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Job ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.RestoreResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid job ID or job not deleted",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Job not found",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "410": {
+                        "description": "Grace period expired",
+                        "schema": {
+                            "$ref": "#/definitions/utils.JobDeletedResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Restore failed",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/preview-audio/{videoId}": {
+            "get": {
+                "description": "Short opus clip from the start of an audio track, to tell the tracks (dubs) of a video apart before choosing audio.trackId. No job is created.",
+                "produces": [
+                    "audio/opus"
+                ],
+                "tags": [
+                    "download"
+                ],
+                "summary": "Preview an audio track",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "YouTube video ID",
+                        "name": "videoId",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "audio.trackId (default: the original track)",
+                        "name": "track",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Clip length in seconds (1-30, default 10)",
+                        "name": "seconds",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Opus clip (48 kbps)",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid video ID or seconds",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Track not found",
+                        "schema": {
+                            "$ref": "#/definitions/utils.TrackNotFoundResponse"
+                        }
+                    },
+                    "429": {
+                        "description": "Too many previews from this client",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Preview failed",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Too many previews in progress, or metadata service rate limited",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/status/{id}": {
+            "get": {
+                "description": "Check the status and progress of a download job",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "status"
+                ],
+                "summary": "Get job status",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Job ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Compact signed token",
+                        "name": "t",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Legacy signed URL token (deprecated)",
+                        "name": "token",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Legacy expiration timestamp (deprecated)",
+                        "name": "expires",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Include the parameters the job was created with",
+                        "name": "includeRequest",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.StatusResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid job ID",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Missing token or expires",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Invalid or expired token",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Job not found",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "410": {
+                        "description": "Job deleted",
+                        "schema": {
+                            "$ref": "#/definitions/utils.JobDeletedResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Server error (metadata unreadable and no recent status to serve stale)",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/d/{id}/{name}": {
+            "get": {
+                "description": "Download the primary output of a completed job. The last segment is cosmetic (the title as filename) and is neither signed nor used to find the file; the token is that of the output's /files URL.",
+                "produces": [
+                    "application/octet-stream"
+                ],
+                "tags": [
+                    "files"
+                ],
+                "summary": "Download output via share link",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Job ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Any name (cosmetic)",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Compact signed token (shareUrl)",
+                        "name": "t",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Manifest part index (serves only that part)",
+                        "name": "part",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Serve with Content-Disposition: inline for in-browser playback",
+                        "name": "inline",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Output file",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "206": {
+                        "description": "Requested part or range",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "304": {
+                        "description": "Not modified (If-None-Match / If-Modified-Since)"
+                    },
+                    "400": {
+                        "description": "Invalid parameters",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Missing token",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Invalid token",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not found",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "410": {
+                        "description": "Job deleted",
+                        "schema": {
+                            "$ref": "#/definitions/utils.JobDeletedResponse"
+                        }
+                    },
+                    "416": {
+                        "description": "Range not satisfiable",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/files/{id}/{filename}": {
+            "get": {
+                "description": "Download the merged output file",
+                "produces": [
+                    "application/octet-stream"
+                ],
+                "tags": [
+                    "files"
+                ],
+                "summary": "Download file",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Job ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Output filename",
+                        "name": "filename",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Compact signed token",
+                        "name": "t",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Legacy signed URL token (deprecated)",
+                        "name": "token",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Legacy expiration timestamp (deprecated)",
+                        "name": "expires",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Manifest part index (serves only that part)",
+                        "name": "part",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Serve with Content-Disposition: inline for in-browser playback",
+                        "name": "inline",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Output file",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "206": {
+                        "description": "Requested part or range",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "304": {
+                        "description": "Not modified (If-None-Match / If-Modified-Since)"
+                    },
+                    "400": {
+                        "description": "Invalid parameters",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Missing auth",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Invalid token",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not found",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "410": {
+                        "description": "Job deleted",
+                        "schema": {
+                            "$ref": "#/definitions/utils.JobDeletedResponse"
+                        }
+                    },
+                    "416": {
+                        "description": "Range not satisfiable",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/files/{id}/{filename}/manifest": {
+            "get": {
+                "description": "List fixed-size parts of the output file with SHA-256 hashes. Fetch each part with ?part=N (or a Range header) and verify before concatenating.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "files"
+                ],
+                "summary": "Get resumable download manifest",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Job ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Output filename",
+                        "name": "filename",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Compact signed token",
+                        "name": "t",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Legacy signed URL token (deprecated)",
+                        "name": "token",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Legacy expiration timestamp (deprecated)",
+                        "name": "expires",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.ManifestResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid parameters",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Missing auth",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Invalid token",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not found",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "410": {
+                        "description": "Job deleted",
+                        "schema": {
+                            "$ref": "#/definitions/utils.JobDeletedResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/groups/{id}/archive": {
+            "get": {
+                "description": "Zip of the members' outputs with manifest.json",
+                "produces": [
+                    "application/zip"
+                ],
+                "tags": [
+                    "groups"
+                ],
+                "summary": "Download job group archive",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Group ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Compact signed token (archive downloadUrl)",
+                        "name": "t",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Archive",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "206": {
+                        "description": "Requested range",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid group ID or archive not built",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Missing token",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Invalid or expired token",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Group not found",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "416": {
+                        "description": "Range not satisfiable",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/health": {
+            "get": {
+                "description": "Check if the server is running; deep=true adds dependency state",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "health"
+                ],
+                "summary": "Health check",
+                "parameters": [
+                    {
+                        "type": "boolean",
+                        "description": "Include the extract API cool-down and storage state",
+                        "name": "deep",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.HealthResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/stream/{id}": {
+            "get": {
+                "description": "Stream video/audio using FFmpeg pipe (realtime remux/convert)",
+                "produces": [
+                    "application/octet-stream"
+                ],
+                "tags": [
+                    "stream"
+                ],
+                "summary": "Stream video/audio",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Job ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Compact signed token",
+                        "name": "t",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Legacy signed URL token (deprecated)",
+                        "name": "token",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Legacy expiration timestamp (deprecated)",
+                        "name": "expires",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Serve with Content-Disposition: inline for in-browser playback",
+                        "name": "inline",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Media stream",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "307": {
+                        "description": "Redirect to download URL"
+                    },
+                    "400": {
+                        "description": "Invalid parameters",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Missing auth",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Invalid token",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not found",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "410": {
+                        "description": "Job deleted",
+                        "schema": {
+                            "$ref": "#/definitions/utils.JobDeletedResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Stream failed",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/stream/{id}/hls/{filename}": {
+            "get": {
+                "description": "Media playlist, init segment or media segment referenced by the master playlist",
+                "produces": [
+                    "application/octet-stream"
+                ],
+                "tags": [
+                    "stream"
+                ],
+                "summary": "HLS playlist or segment",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Job ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Playlist or segment name",
+                        "name": "filename",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Compact signed token (from the playlist)",
+                        "name": "t",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Playlist or segment",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid parameters",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Missing auth",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Invalid token",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not found",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "410": {
+                        "description": "Job deleted",
+                        "schema": {
+                            "$ref": "#/definitions/utils.JobDeletedResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/stream/{id}/master.m3u8": {
+            "get": {
+                "description": "Master playlist for in-browser playback with seeking. Segments are generated on first request and reused; all URIs in the playlist are signed.",
+                "produces": [
+                    "application/vnd.apple.mpegurl"
+                ],
+                "tags": [
+                    "stream"
+                ],
+                "summary": "HLS preview playlist",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Job ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Compact signed token (same as /stream)",
+                        "name": "t",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Legacy signed URL token (deprecated)",
+                        "name": "token",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Legacy expiration timestamp (deprecated)",
+                        "name": "expires",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Master playlist",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid parameters",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Missing auth",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Invalid token",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not found",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "410": {
+                        "description": "Job deleted",
+                        "schema": {
+                            "$ref": "#/definitions/utils.JobDeletedResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Generation failed",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Still generating, retry after Retry-After seconds",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/ui": {
+            "get": {
+                "description": "Minimal page for manual testing: submits to /api/download, polls the status URL and links the result. Not registered with UI_ENABLED=false.",
+                "produces": [
+                    "text/html"
+                ],
+                "tags": [
+                    "ui"
+                ],
+                "summary": "Web UI",
+                "responses": {
+                    "200": {
+                        "description": "HTML page",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "models.AdminJob": {
+            "description": "Admin job summary",
+            "type": "object",
+            "properties": {
+                "client": {
+                    "$ref": "#/definitions/models.ClientInfo"
+                },
+                "createdAt": {
+                    "type": "integer",
+                    "example": 1705122256789
+                },
+                "deletedAt": {
+                    "type": "integer",
+                    "example": 0
+                },
+                "expiresAt": {
+                    "type": "integer",
+                    "example": 1705124056789
+                },
+                "format": {
+                    "type": "string",
+                    "example": "mp3"
+                },
+                "id": {
+                    "type": "string",
+                    "example": "V1StGXR8_Z5jdHi6B-myT"
+                },
+                "outputType": {
+                    "type": "string",
+                    "example": "audio"
+                },
+                "possiblyStuck": {
+                    "description": "Pending/processing past the job timeout or without a live worker",
+                    "type": "boolean"
+                },
+                "status": {
+                    "type": "string",
+                    "example": "completed"
+                },
+                "title": {
+                    "type": "string",
+                    "example": "Rick Astley - Never Gonna Give You Up"
+                },
+                "usage": {
+                    "$ref": "#/definitions/models.Usage"
+                },
+                "usageKey": {
+                    "type": "string",
+                    "example": "key:3f2a9c0d1e4b5a6f7c8d9e0f1a2b3c4d"
+                },
+                "videoId": {
+                    "type": "string",
+                    "example": "dQw4w9WgXcQ"
+                }
+            }
+        },
+        "models.AdminJobsResponse": {
+            "description": "Admin job listing",
+            "type": "object",
+            "properties": {
+                "jobs": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.AdminJob"
+                    }
+                },
+                "total": {
+                    "description": "Jobs on disk before limit",
+                    "type": "integer",
+                    "example": 250
+                }
+            }
+        },
+        "models.AdminUsageResponse": {
+            "type": "object",
+            "properties": {
+                "keys": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.KeyUsage"
+                    }
+                },
+                "since": {
+                    "description": "Only jobs created at or after (ms)",
+                    "type": "integer",
+                    "example": 1705122256789
+                }
+            }
+        },
+        "models.AudioChannels": {
+            "description": "Source and output audio channels",
+            "type": "object",
+            "properties": {
+                "action": {
+                    "type": "string",
+                    "enum": [
+                        "kept",
+                        "downmixed",
+                        "upmixed"
+                    ],
+                    "example": "downmixed"
+                },
+                "channels": {
+                    "type": "integer",
+                    "example": 2
+                },
+                "layout": {
+                    "type": "string",
+                    "example": "stereo"
+                },
+                "sourceChannels": {
+                    "type": "integer",
+                    "example": 6
+                },
+                "sourceLayout": {
+                    "description": "As reported by ffprobe; empty when unknown",
+                    "type": "string",
+                    "example": "5.1(side)"
+                }
+            }
+        },
+        "models.AudioConfig": {
+            "description": "Audio configuration",
+            "type": "object",
+            "properties": {
+                "autoTrimSilence": {
+                    "description": "Audio outputs only; ignored when trim is set",
+                    "type": "boolean",
+                    "example": false
+                },
+                "bitrate": {
+                    "type": "string",
+                    "enum": [
+                        "64k",
+                        "128k",
+                        "192k",
+                        "320k"
+                    ],
+                    "example": "192k"
+                },
+                "channels": {
+                    "description": "Audio outputs only; default downmixes surround to stereo for lossy formats",
+                    "type": "string",
+                    "enum": [
+                        "keep",
+                        "stereo",
+                        "mono"
+                    ],
+                    "example": "stereo"
+                },
+                "codec": {
+                    "description": "ogg: opus/vorbis; m4a/m4b: aac/aac_he/libfdk_aac",
+                    "type": "string",
+                    "enum": [
+                        "opus",
+                        "vorbis",
+                        "aac",
+                        "aac_he",
+                        "libfdk_aac"
+                    ],
+                    "example": "opus"
+                },
+                "mute": {
+                    "description": "Video outputs only: no audio stream is downloaded or written",
+                    "type": "boolean",
+                    "example": false
+                },
+                "pitchSemitones": {
+                    "description": "Audio outputs only: pitch shift -12..12 at the same speed",
+                    "type": "integer",
+                    "example": -2
+                },
+                "strict": {
+                    "description": "Reject out-of-range bitrates instead of clamping",
+                    "type": "boolean",
+                    "example": false
+                },
+                "strictLossless": {
+                    "description": "Reject wav/flac output from a lossy source (422)",
+                    "type": "boolean",
+                    "example": false
+                },
+                "tempo": {
+                    "description": "Audio outputs only: playback speed 0.5–2.0 at the same pitch",
+                    "type": "number",
+                    "example": 0.75
+                },
+                "trackId": {
+                    "type": "string",
+                    "example": "en.vss_abc123"
+                },
+                "transcript": {
+                    "description": "Audio outputs only; save captions as transcript.vtt/.txt",
+                    "type": "boolean",
+                    "example": false
+                },
+                "vbr": {
+                    "description": "mp3/opus/ogg only; excludes bitrate",
+                    "type": "string",
+                    "enum": [
+                        "V0",
+                        "V1",
+                        "V2",
+                        "V3",
+                        "V4",
+                        "V5",
+                        "V6",
+                        "V7",
+                        "V8",
+                        "V9"
+                    ],
+                    "example": "V0"
+                }
+            }
+        },
+        "models.AudioTrack": {
+            "description": "Audio track of a video",
+            "type": "object",
+            "properties": {
+                "id": {
+                    "description": "audio.trackId; empty for videos with a single track",
+                    "type": "string",
+                    "example": "en.vss_abc123"
+                },
+                "original": {
+                    "description": "The video's original language",
+                    "type": "boolean",
+                    "example": true
+                }
+            }
+        },
+        "models.CapabilitiesResponse": {
+            "description": "Server capabilities",
+            "type": "object",
+            "properties": {
+                "apiVersion": {
+                    "type": "string",
+                    "example": "2.0"
+                },
+                "audioFormats": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "mp3",
+                        "m4a",
+                        "m4b",
+                        "wav",
+                        "opus",
+                        "ogg",
+                        "flac"
+                    ]
+                },
+                "bitrates": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "64k",
+                        "128k",
+                        "192k",
+                        "320k"
+                    ]
+                },
+                "features": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "boolean"
+                    }
+                },
+                "hardwareAcceleration": {
+                    "type": "boolean",
+                    "example": false
+                },
+                "maxMergeDurationRemux": {
+                    "description": "Seconds; longer jobs are stream-only",
+                    "type": "number",
+                    "example": 14400
+                },
+                "maxMergeDurationTranscode": {
+                    "description": "Seconds; longer jobs are stream-only",
+                    "type": "number",
+                    "example": 900
+                },
+                "maxTrimDuration": {
+                    "description": "Seconds",
+                    "type": "number",
+                    "example": 86400
+                },
+                "osProfiles": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "$ref": "#/definitions/models.OSProfile"
+                    }
+                },
+                "qualities": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "2160p",
+                        "1080p",
+                        "720p"
+                    ]
+                },
+                "videoFormats": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "mp4",
+                        "webm",
+                        "mkv"
+                    ]
+                }
+            }
+        },
+        "models.Chapter": {
+            "type": "object",
+            "properties": {
+                "start": {
+                    "description": "Seconds",
+                    "type": "number"
+                },
+                "title": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.ClientInfo": {
+            "type": "object",
+            "properties": {
+                "apiKey": {
+                    "description": "Key fingerprint (\"key:\u003chash\u003e\"), never the key itself",
+                    "type": "string"
+                },
+                "ip": {
+                    "type": "string"
+                },
+                "referer": {
+                    "type": "string"
+                },
+                "scrubbedAt": {
+                    "description": "Fields cleared after the retention window (ms)",
+                    "type": "integer"
+                },
+                "userAgent": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.ConvertRequest": {
+            "description": "Additional output request",
+            "type": "object",
+            "properties": {
+                "audio": {
+                    "$ref": "#/definitions/models.AudioConfig"
+                },
+                "output": {
+                    "description": "Explicit format; quality is fixed by the sources",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.OutputConfig"
+                        }
+                    ]
+                },
+                "trim": {
+                    "$ref": "#/definitions/models.TrimConfig"
+                }
+            }
+        },
+        "models.ConvertResponse": {
+            "description": "Additional output queued",
+            "type": "object",
+            "properties": {
+                "name": {
+                    "type": "string",
+                    "example": "output_2.mp3"
+                },
+                "statusUrl": {
+                    "type": "string",
+                    "example": "https://api.ytconvert.org/api/status/V1StGXR8_Z5jdHi?t=xxx"
+                }
+            }
+        },
+        "models.DeleteResponse": {
+            "description": "Delete job response",
+            "type": "object",
+            "properties": {
+                "alreadyDeleted": {
+                    "description": "The job was deleted or removed before this request",
+                    "type": "boolean",
+                    "example": false
+                },
+                "deleted": {
+                    "type": "boolean",
+                    "example": true
+                },
+                "deletedAt": {
+                    "description": "Soft delete time (ms); unknown for jobs removed without a tombstone",
+                    "type": "integer",
+                    "example": 1705123456789
+                },
+                "restoreUntil": {
+                    "description": "End of the restore window, while it is open",
+                    "type": "integer",
+                    "example": 1705124056789
+                }
+            }
+        },
+        "models.DownloadRequest": {
+            "description": "Download request payload",
+            "type": "object",
+            "properties": {
+                "allowTranscode": {
+                    "description": "No stream playable on os: transcode the best one to H.264",
+                    "type": "boolean",
+                    "example": false
+                },
+                "audio": {
+                    "$ref": "#/definitions/models.AudioConfig"
+                },
+                "bindIp": {
+                    "description": "Strict mode: bind links to this IP (default: caller IP)",
+                    "type": "string",
+                    "example": "203.0.113.7"
+                },
+                "idempotencyKey": {
+                    "description": "Same as the Idempotency-Key header",
+                    "type": "string",
+                    "example": "9b2f6c1e-retry-safe"
+                },
+                "keepSources": {
+                    "description": "Retain sources for /api/jobs/:id/convert (default: server config)",
+                    "type": "boolean",
+                    "example": true
+                },
+                "os": {
+                    "type": "string",
+                    "enum": [
+                        "ios",
+                        "android",
+                        "macos",
+                        "windows",
+                        "linux"
+                    ],
+                    "example": "windows"
+                },
+                "output": {
+                    "$ref": "#/definitions/models.OutputConfig"
+                },
+                "outputs": {
+                    "description": "Several outputs from one download (instead of output; max 3)",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.OutputSpec"
+                    }
+                },
+                "priority": {
+                    "description": "high requires an authorized X-API-Key",
+                    "type": "string",
+                    "enum": [
+                        "low",
+                        "normal",
+                        "high"
+                    ],
+                    "example": "normal"
+                },
+                "sessionId": {
+                    "description": "Strict mode: bind links to this session instead of IP",
+                    "type": "string",
+                    "example": "c2Vzc2lvbi0xMjM"
+                },
+                "trim": {
+                    "$ref": "#/definitions/models.TrimConfig"
+                },
+                "url": {
+                    "type": "string",
+                    "example": "https://youtube.com/watch?v=dQw4w9WgXcQ"
+                }
+            }
+        },
+        "models.DownloadResponse": {
+            "description": "Response after creating a download job",
+            "type": "object",
+            "properties": {
+                "duration": {
+                    "type": "number",
+                    "example": 213.5
+                },
+                "lossyToLossless": {
+                    "description": "Lossless output from a lossy source: larger file, no quality gain",
+                    "type": "boolean",
+                    "example": false
+                },
+                "muted": {
+                    "description": "Video output without an audio stream (audio.mute)",
+                    "type": "boolean",
+                    "example": false
+                },
+                "needsReencode": {
+                    "type": "boolean",
+                    "example": false
+                },
+                "outputs": {
+                    "description": "Additional outputs of a multi-output job",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.OutputStatus"
+                    }
+                },
+                "plan": {
+                    "$ref": "#/definitions/models.JobPlan"
+                },
+                "qualityChangeReason": {
+                    "type": "string",
+                    "example": "1080p not available, using 720p"
+                },
+                "qualityChanged": {
+                    "type": "boolean",
+                    "example": true
+                },
+                "replayed": {
+                    "description": "Response of an earlier request with the same idempotency key",
+                    "type": "boolean",
+                    "example": false
+                },
+                "requestedQuality": {
+                    "type": "string",
+                    "example": "1080p"
+                },
+                "resolvedBitrate": {
+                    "description": "Requested or format default; omitted for lossless",
+                    "type": "string",
+                    "example": "192k"
+                },
+                "resolvedFormat": {
+                    "type": "string",
+                    "example": "mp4"
+                },
+                "selectedQuality": {
+                    "type": "string",
+                    "example": "720p"
+                },
+                "selection": {
+                    "$ref": "#/definitions/models.StreamSelection"
+                },
+                "selectionChanged": {
+                    "description": "Streams differ from a recent identical request (upstream dropped them)",
+                    "type": "boolean",
+                    "example": false
+                },
+                "statusUrl": {
+                    "type": "string",
+                    "example": "https://api.ytconvert.org/api/status/V1StGXR8_Z5jdHi?token=xxx\u0026expires=xxx"
+                },
+                "title": {
+                    "type": "string",
+                    "example": "Rick Astley - Never Gonna Give You Up"
+                },
+                "trimFromURL": {
+                    "type": "boolean",
+                    "example": false
+                },
+                "warnings": {
+                    "description": "Everything accepted differently from the request; empty when none",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.Warning"
+                    }
+                }
+            }
+        },
+        "models.ExtendResponse": {
+            "description": "Extend job response",
+            "type": "object",
+            "properties": {
+                "expiresAt": {
+                    "description": "New removal time (ms)",
+                    "type": "integer",
+                    "example": 1705124056789
+                },
+                "maxExpiresAt": {
+                    "description": "Extension cap (ms)",
+                    "type": "integer",
+                    "example": 1705142056789
+                },
+                "statusUrl": {
+                    "type": "string",
+                    "example": "https://api.ytconvert.org/api/status/V1StGXR8_Z5jdHi?t=xxx"
+                }
+            }
+        },
+        "models.ExtraOutput": {
+            "type": "object",
+            "properties": {
+                "audioCodec": {
+                    "type": "string"
+                },
+                "bitrate": {
+                    "type": "string"
+                },
+                "createdAt": {
+                    "type": "integer"
+                },
+                "displayFilename": {
+                    "type": "string"
+                },
+                "error": {
+                    "type": "string"
+                },
+                "format": {
+                    "type": "string"
+                },
+                "name": {
+                    "description": "output_2.mp3 (signed in URLs)",
+                    "type": "string"
+                },
+                "outputType": {
+                    "type": "string"
+                },
+                "status": {
+                    "description": "pending, processing, completed, error",
+                    "type": "string"
+                },
+                "trim": {
+                    "$ref": "#/definitions/models.TrimConfig"
+                }
+            }
+        },
+        "models.ExtractCircuit": {
+            "description": "Extract API cool-down state",
+            "type": "object",
+            "properties": {
+                "authFailures": {
+                    "description": "401/403 responses (credentials rejected) since start",
+                    "type": "integer",
+                    "example": 0
+                },
+                "failures": {
+                    "description": "Other extract failures since start",
+                    "type": "integer",
+                    "example": 1
+                },
+                "lastError": {
+                    "description": "Set while the last extract request was rejected for its credentials",
+                    "type": "string",
+                    "enum": [
+                        "EXTRACT_AUTH_FAILED"
+                    ],
+                    "example": "EXTRACT_AUTH_FAILED"
+                },
+                "rateLimited": {
+                    "description": "429/503 responses from the extract API since start",
+                    "type": "integer",
+                    "example": 3
+                },
+                "retryAfterSeconds": {
+                    "type": "integer",
+                    "example": 0
+                },
+                "state": {
+                    "description": "open = requests fail fast with EXTRACT_RATE_LIMITED",
+                    "type": "string",
+                    "enum": [
+                        "closed",
+                        "open"
+                    ],
+                    "example": "closed"
+                }
+            }
+        },
+        "models.FFmpegCommand": {
+            "type": "object",
+            "properties": {
+                "args": {
+                    "description": "Arguments after the ffmpeg binary",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "startedAt": {
+                    "description": "Unix ms",
+                    "type": "integer"
+                }
+            }
+        },
+        "models.FadeConfig": {
+            "description": "Fade configuration",
+            "type": "object",
+            "properties": {
+                "in": {
+                    "type": "number",
+                    "example": 1.5
+                },
+                "out": {
+                    "type": "number",
+                    "example": 2
+                },
+                "video": {
+                    "description": "Also fade video from/to black",
+                    "type": "boolean",
+                    "example": false
+                }
+            }
+        },
+        "models.FailJobRequest": {
+            "description": "Force-fail request",
+            "type": "object",
+            "properties": {
+                "reason": {
+                    "description": "Shown to clients as the job error",
+                    "type": "string",
+                    "example": "Stuck after origin outage"
+                }
+            }
+        },
+        "models.FileInfo": {
+            "type": "object",
+            "properties": {
+                "name": {
+                    "type": "string"
+                },
+                "size": {
+                    "type": "integer"
+                },
+                "source": {
+                    "description": "Shared source cache file name",
+                    "type": "string"
+                }
+            }
+        },
+        "models.FileManifest": {
+            "type": "object",
+            "properties": {
+                "partSize": {
+                    "type": "integer"
+                },
+                "parts": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.FilePart"
+                    }
+                },
+                "sha256": {
+                    "type": "string"
+                },
+                "size": {
+                    "type": "integer"
+                }
+            }
+        },
+        "models.FilePart": {
+            "description": "Output file part",
+            "type": "object",
+            "properties": {
+                "index": {
+                    "type": "integer",
+                    "example": 0
+                },
+                "length": {
+                    "type": "integer",
+                    "example": 16777216
+                },
+                "offset": {
+                    "type": "integer",
+                    "example": 0
+                },
+                "sha256": {
+                    "type": "string",
+                    "example": "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"
+                }
+            }
+        },
+        "models.FilesInfo": {
+            "type": "object",
+            "properties": {
+                "audio": {
+                    "$ref": "#/definitions/models.FileInfo"
+                },
+                "video": {
+                    "$ref": "#/definitions/models.FileInfo"
+                }
+            }
+        },
+        "models.GroupArchiveStatus": {
+            "description": "Job group archive",
+            "type": "object",
+            "properties": {
+                "downloadUrl": {
+                    "type": "string",
+                    "example": "https://api.ytconvert.org/groups/Uakgb_J5m9g-0JDMbcJqL/archive?t=xxx"
+                },
+                "error": {
+                    "type": "string",
+                    "example": "No member has an output file"
+                },
+                "files": {
+                    "type": "integer",
+                    "example": 5
+                },
+                "size": {
+                    "type": "integer",
+                    "example": 52428800
+                },
+                "status": {
+                    "type": "string",
+                    "enum": [
+                        "pending",
+                        "processing",
+                        "completed",
+                        "error"
+                    ],
+                    "example": "completed"
+                }
+            }
+        },
+        "models.GroupDeleteResponse": {
+            "description": "Delete group response",
+            "type": "object",
+            "properties": {
+                "deleted": {
+                    "type": "boolean",
+                    "example": true
+                },
+                "membersDeleted": {
+                    "description": "Members soft-deleted (?members=true)",
+                    "type": "integer",
+                    "example": 5
+                }
+            }
+        },
+        "models.GroupMemberRef": {
+            "type": "object",
+            "properties": {
+                "id": {
+                    "type": "string",
+                    "example": "V1StGXR8_Z5jdHi6B-myT"
+                },
+                "t": {
+                    "description": "The t parameter of the job's statusUrl",
+                    "type": "string",
+                    "example": "xxx"
+                }
+            }
+        },
+        "models.GroupMemberStatus": {
+            "description": "Job group member",
+            "type": "object",
+            "properties": {
+                "error": {
+                    "type": "string",
+                    "example": "Download failed"
+                },
+                "jobId": {
+                    "type": "string",
+                    "example": "V1StGXR8_Z5jdHi6B-myT"
+                },
+                "status": {
+                    "type": "string",
+                    "enum": [
+                        "pending",
+                        "processing",
+                        "completed",
+                        "error",
+                        "deleted",
+                        "expired"
+                    ],
+                    "example": "completed"
+                }
+            }
+        },
+        "models.GroupOptions": {
+            "type": "object",
+            "properties": {
+                "archive": {
+                    "description": "Zip the members' outputs once all have finished",
+                    "type": "boolean",
+                    "example": true
+                },
+                "name": {
+                    "description": "Archive download name (without .zip)",
+                    "type": "string",
+                    "example": "lecture-pack"
+                }
+            }
+        },
+        "models.GroupRequest": {
+            "description": "Job group request",
+            "type": "object",
+            "properties": {
+                "group": {
+                    "$ref": "#/definitions/models.GroupOptions"
+                },
+                "jobs": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.GroupMemberRef"
+                    }
+                }
+            }
+        },
+        "models.GroupResponse": {
+            "description": "Job group created",
+            "type": "object",
+            "properties": {
+                "expiresAt": {
+                    "type": "integer",
+                    "example": 1705124056789
+                },
+                "groupId": {
+                    "type": "string",
+                    "example": "Uakgb_J5m9g-0JDMbcJqL"
+                },
+                "statusUrl": {
+                    "type": "string",
+                    "example": "https://api.ytconvert.org/api/groups/Uakgb_J5m9g-0JDMbcJqL?t=xxx"
+                }
+            }
+        },
+        "models.GroupStatusResponse": {
+            "description": "Job group status",
+            "type": "object",
+            "properties": {
+                "archive": {
+                    "$ref": "#/definitions/models.GroupArchiveStatus"
+                },
+                "expiresAt": {
+                    "type": "integer",
+                    "example": 1705124056789
+                },
+                "id": {
+                    "type": "string",
+                    "example": "Uakgb_J5m9g-0JDMbcJqL"
+                },
+                "members": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.GroupMemberStatus"
+                    }
+                },
+                "name": {
+                    "type": "string",
+                    "example": "lecture-pack"
+                },
+                "status": {
+                    "description": "completed once every member has finished",
+                    "type": "string",
+                    "enum": [
+                        "pending",
+                        "completed"
+                    ],
+                    "example": "completed"
+                }
+            }
+        },
+        "models.HealthResponse": {
+            "description": "Health check response",
+            "type": "object",
+            "properties": {
+                "extract": {
+                    "description": "deep=true only",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.ExtractCircuit"
+                        }
+                    ]
+                },
+                "status": {
+                    "type": "string",
+                    "example": "ok"
+                },
+                "storage": {
+                    "description": "deep=true only",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.StorageState"
+                        }
+                    ]
+                },
+                "timestamp": {
+                    "type": "integer",
+                    "example": 1705123456789
+                }
+            }
+        },
+        "models.JobError": {
+            "description": "Structured job error",
+            "type": "object",
+            "properties": {
+                "code": {
+                    "type": "string",
+                    "example": "ORIGIN_FORBIDDEN"
+                },
+                "message": {
+                    "type": "string",
+                    "example": "Download failed: chunk 12 failed: HTTP 403: Forbidden"
+                },
+                "phase": {
+                    "type": "string",
+                    "enum": [
+                        "extract",
+                        "validation",
+                        "download",
+                        "processing"
+                    ],
+                    "example": "download"
+                },
+                "retryable": {
+                    "type": "boolean",
+                    "example": true
+                }
+            }
+        },
+        "models.JobPlan": {
+            "description": "Planned processing, for estimating the cost of a job before submitting more",
+            "type": "object",
+            "properties": {
+                "delivery": {
+                    "description": "Pre-rendered file, or served via /stream only",
+                    "type": "string",
+                    "enum": [
+                        "file",
+                        "stream"
+                    ],
+                    "example": "file"
+                },
+                "download": {
+                    "description": "Ranged parallel workers, or one request per source",
+                    "type": "string",
+                    "enum": [
+                        "parallel",
+                        "single"
+                    ],
+                    "example": "parallel"
+                },
+                "estimatedCpuClass": {
+                    "description": "heavy = any re-encoding",
+                    "type": "string",
+                    "enum": [
+                        "light",
+                        "heavy"
+                    ],
+                    "example": "light"
+                },
+                "merge": {
+                    "description": "Sources stream-copied or re-encoded into the output",
+                    "type": "string",
+                    "enum": [
+                        "copy",
+                        "transcode"
+                    ],
+                    "example": "copy"
+                },
+                "thresholds": {
+                    "$ref": "#/definitions/models.PlanThresholds"
+                },
+                "trim": {
+                    "description": "fast cuts at keyframes; accurate re-encodes",
+                    "type": "string",
+                    "enum": [
+                        "none",
+                        "fast",
+                        "accurate"
+                    ],
+                    "example": "none"
+                }
+            }
+        },
+        "models.JobRequest": {
+            "description": "Parameters the job was created with (normalized)",
+            "type": "object",
+            "properties": {
+                "allowTranscode": {
+                    "type": "boolean",
+                    "example": false
+                },
+                "audio": {
+                    "$ref": "#/definitions/models.AudioConfig"
+                },
+                "keepSources": {
+                    "type": "boolean",
+                    "example": true
+                },
+                "os": {
+                    "type": "string",
+                    "example": "windows"
+                },
+                "output": {
+                    "$ref": "#/definitions/models.OutputConfig"
+                },
+                "outputs": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.OutputSpec"
+                    }
+                },
+                "priority": {
+                    "type": "string",
+                    "example": "normal"
+                },
+                "trim": {
+                    "description": "Includes a start taken from the URL (?t=)",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.TrimConfig"
+                        }
+                    ]
+                },
+                "url": {
+                    "type": "string",
+                    "example": "https://youtube.com/watch?v=dQw4w9WgXcQ"
+                }
+            }
+        },
+        "models.KeyUsage": {
+            "description": "Usage of one API key",
+            "type": "object",
+            "properties": {
+                "jobs": {
+                    "type": "integer",
+                    "example": 12
+                },
+                "key": {
+                    "description": "Key fingerprint, \"anonymous\" for jobs without a key",
+                    "type": "string",
+                    "example": "key:3f2a9c0d1e4b5a6f7c8d9e0f1a2b3c4d"
+                },
+                "originBytes": {
+                    "type": "integer",
+                    "example": 104857600
+                },
+                "servedBytes": {
+                    "type": "integer",
+                    "example": 52428800
+                },
+                "servedCount": {
+                    "type": "integer",
+                    "example": 14
+                }
+            }
+        },
+        "models.LoadOverrideRequest": {
+            "description": "Admission threshold override",
+            "type": "object",
+            "properties": {
+                "maxWaitSeconds": {
+                    "description": "0 disables shedding",
+                    "type": "number",
+                    "example": 1200
+                },
+                "ttlSeconds": {
+                    "description": "Override lifetime (default 1h)",
+                    "type": "number",
+                    "example": 3600
+                }
+            }
+        },
+        "models.LoadResponse": {
+            "description": "Processing load estimate",
+            "type": "object",
+            "properties": {
+                "avgProcessingSeconds": {
+                    "type": "number",
+                    "example": 42.5
+                },
+                "estimatedWaitSeconds": {
+                    "type": "number",
+                    "example": 127.5
+                },
+                "maxWaitSeconds": {
+                    "description": "New jobs are rejected above this (0 = never)",
+                    "type": "number",
+                    "example": 600
+                },
+                "overrideExpiresAt": {
+                    "description": "When an admin override of maxWaitSeconds ends (ms)",
+                    "type": "integer",
+                    "example": 0
+                },
+                "runningJobs": {
+                    "type": "integer",
+                    "example": 4
+                },
+                "waitingJobs": {
+                    "type": "integer",
+                    "example": 12
+                }
+            }
+        },
+        "models.LogLevelsRequest": {
+            "description": "Log level change",
+            "type": "object",
+            "properties": {
+                "level": {
+                    "description": "New default level, empty = unchanged",
+                    "type": "string",
+                    "example": "warn"
+                },
+                "modules": {
+                    "description": "Module levels; \"\" makes a module follow the default again",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "models.LogLevelsResponse": {
+            "description": "Log levels",
+            "type": "object",
+            "properties": {
+                "level": {
+                    "description": "Default level (LOG_LEVEL)",
+                    "type": "string",
+                    "example": "info"
+                },
+                "modules": {
+                    "description": "Level in effect per module",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "models.ManifestResponse": {
+            "description": "Resumable download manifest",
+            "type": "object",
+            "properties": {
+                "filename": {
+                    "type": "string",
+                    "example": "output.mp4"
+                },
+                "partSize": {
+                    "type": "integer",
+                    "example": 16777216
+                },
+                "parts": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.FilePart"
+                    }
+                },
+                "sha256": {
+                    "type": "string",
+                    "example": "2c26b46b68ffc68ff99b453c1d30413413422d706483bfa0f98a5e886266e7ae"
+                },
+                "size": {
+                    "type": "integer",
+                    "example": 52428800
+                }
+            }
+        },
+        "models.Meta": {
+            "type": "object",
+            "properties": {
+                "audioChannels": {
+                    "description": "Detected after download (jobs with audio)",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.AudioChannels"
+                        }
+                    ]
+                },
+                "audioCodec": {
+                    "description": "audio.codec override (vorbis, aac_he, ...)",
+                    "type": "string"
+                },
+                "author": {
+                    "type": "string"
+                },
+                "autoTrimSilence": {
+                    "type": "boolean"
+                },
+                "binding": {
+                    "description": "Client binding for file/stream URLs (\"ip:\u003chash\u003e\", \"session:\u003chash\u003e\")",
+                    "type": "string"
+                },
+                "bitrate": {
+                    "type": "string"
+                },
+                "channelMode": {
+                    "description": "audio.channels (\"\" = automatic)",
+                    "type": "string"
+                },
+                "chapters": {
+                    "description": "Written as chapter markers (m4a/m4b)",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.Chapter"
+                    }
+                },
+                "client": {
+                    "description": "Creating request (admin only, scrubbed after ClientInfoRetention)",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.ClientInfo"
+                        }
+                    ]
+                },
+                "createdAt": {
+                    "type": "integer"
+                },
+                "deletedAt": {
+                    "description": "Soft delete time (ms), 0 = not deleted",
+                    "type": "integer"
+                },
+                "displayFilename": {
+                    "description": "User-facing filename (Content-Disposition)",
+                    "type": "string"
+                },
+                "duration": {
+                    "type": "number"
+                },
+                "error": {
+                    "type": "string"
+                },
+                "expiresAt": {
+                    "description": "Removal time (ms); extendable up to MaxJobLifetime",
+                    "type": "integer"
+                },
+                "faststart": {
+                    "description": "Output written with +faststart (moov atom first)",
+                    "type": "boolean"
+                },
+                "ffmpegCommands": {
+                    "description": "ffmpeg invocations of the job, oldest first (admin only)",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.FFmpegCommand"
+                    }
+                },
+                "ffmpegVersion": {
+                    "description": "\"ffmpeg -version\" of the process that last ran ffmpeg for the job",
+                    "type": "string"
+                },
+                "files": {
+                    "$ref": "#/definitions/models.FilesInfo"
+                },
+                "forceRotate": {
+                    "description": "output.forceRotate",
+                    "type": "boolean"
+                },
+                "format": {
+                    "type": "string"
+                },
+                "groupId": {
+                    "description": "Job group (POST /api/groups) the job belongs to",
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "jobErrorDetail": {
+                    "$ref": "#/definitions/models.JobError"
+                },
+                "keepSources": {
+                    "description": "video.*/audio.* kept after processing",
+                    "type": "boolean"
+                },
+                "lastStreamError": {
+                    "description": "Most recent /stream transfer that ended early",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.StreamError"
+                        }
+                    ]
+                },
+                "lossyToLossless": {
+                    "description": "Lossless output from a lossy source",
+                    "type": "boolean"
+                },
+                "manifest": {
+                    "description": "Part hashes of Output",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.FileManifest"
+                        }
+                    ]
+                },
+                "muted": {
+                    "description": "audio.mute: video only, Files.Audio is nil",
+                    "type": "boolean"
+                },
+                "noAutorotate": {
+                    "description": "output.autorotate false",
+                    "type": "boolean"
+                },
+                "output": {
+                    "description": "On-disk filename (signed in URLs)",
+                    "type": "string"
+                },
+                "outputDuration": {
+                    "description": "Output length (seconds) when audio.tempo changes it",
+                    "type": "number"
+                },
+                "outputType": {
+                    "description": "video or audio",
+                    "type": "string"
+                },
+                "outputs": {
+                    "description": "Additional outputs (multi-output jobs, convert)",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.ExtraOutput"
+                    }
+                },
+                "pitchSemitones": {
+                    "description": "audio.pitchSemitones",
+                    "type": "integer"
+                },
+                "priority": {
+                    "description": "low, normal, high",
+                    "type": "string"
+                },
+                "quality": {
+                    "type": "string"
+                },
+                "request": {
+                    "description": "Normalized request parameters (status with ?includeRequest=1)",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.JobRequest"
+                        }
+                    ]
+                },
+                "rotation": {
+                    "description": "Detected after download (video jobs)",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.VideoRotation"
+                        }
+                    ]
+                },
+                "schemaVersion": {
+                    "description": "MetaSchemaVersion when written; older files are migrated on read",
+                    "type": "integer"
+                },
+                "selection": {
+                    "description": "Selected streams and processing plan",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.StreamSelection"
+                        }
+                    ]
+                },
+                "silenceTrim": {
+                    "description": "Detected boundaries, applied as Trim",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.SilenceTrim"
+                        }
+                    ]
+                },
+                "sourceLimit": {
+                    "description": "Byte cap on downloading a stream of unknown size (MAX_SOURCE_BYTES)",
+                    "type": "integer"
+                },
+                "staticVideo": {
+                    "description": "Audio rendered over the thumbnail (cover.jpg)",
+                    "type": "boolean"
+                },
+                "status": {
+                    "description": "pending, processing, completed, error",
+                    "type": "string"
+                },
+                "streamOnly": {
+                    "description": "true = skip merge, stream only",
+                    "type": "boolean"
+                },
+                "tempo": {
+                    "description": "audio.tempo (0 = unchanged)",
+                    "type": "number"
+                },
+                "thumbnailUrl": {
+                    "type": "string"
+                },
+                "title": {
+                    "type": "string"
+                },
+                "transcript": {
+                    "description": "Requested with audio.transcript",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.Transcript"
+                        }
+                    ]
+                },
+                "trim": {
+                    "$ref": "#/definitions/models.TrimConfig"
+                },
+                "uploadDate": {
+                    "type": "string"
+                },
+                "usage": {
+                    "$ref": "#/definitions/models.Usage"
+                },
+                "usageKey": {
+                    "description": "API key fingerprint the usage is billed to, \"\" = anonymous (kept after the client scrub)",
+                    "type": "string"
+                },
+                "videoId": {
+                    "type": "string"
+                },
+                "videoTranscode": {
+                    "description": "Video re-encoded for the device (allowTranscode)",
+                    "type": "boolean"
+                },
+                "viewCount": {
+                    "type": "integer"
+                },
+                "warnings": {
+                    "description": "Returned by status (STREAM_ONLY may be added when processing starts)",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.Warning"
+                    }
+                }
+            }
+        },
+        "models.OSProfile": {
+            "type": "object",
+            "properties": {
+                "audioCodecs": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "mp4a"
+                    ]
+                },
+                "maxQuality": {
+                    "type": "string",
+                    "example": "1080p"
+                },
+                "videoCodecs": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "avc1"
+                    ]
+                }
+            }
+        },
+        "models.OutputConfig": {
+            "description": "Output configuration",
+            "type": "object",
+            "properties": {
+                "audioOnly": {
+                    "description": "Video formats: extract audio instead (mp4→m4a, webm→opus, mkv→auto)",
+                    "type": "boolean",
+                    "example": false
+                },
+                "autorotate": {
+                    "description": "Video only, default true: apply or keep the source rotation; false leaves it as in the source",
+                    "type": "boolean",
+                    "example": true
+                },
+                "forceRotate": {
+                    "description": "Video only: re-encode a rotated source that would be copied, baking the rotation in",
+                    "type": "boolean",
+                    "example": false
+                },
+                "format": {
+                    "type": "string",
+                    "enum": [
+                        "auto",
+                        "mp4",
+                        "webm",
+                        "mkv",
+                        "mp3",
+                        "m4a",
+                        "m4b",
+                        "wav",
+                        "opus",
+                        "ogg",
+                        "flac"
+                    ],
+                    "example": "mp4"
+                },
+                "quality": {
+                    "type": "string",
+                    "enum": [
+                        "2160p",
+                        "1440p",
+                        "1080p",
+                        "720p",
+                        "480p",
+                        "360p"
+                    ],
+                    "example": "1080p"
+                },
+                "staticVideo": {
+                    "description": "Audio over the thumbnail as a still video (type audio, format mp4/mkv)",
+                    "type": "boolean",
+                    "example": false
+                },
+                "type": {
+                    "type": "string",
+                    "enum": [
+                        "video",
+                        "audio"
+                    ],
+                    "example": "video"
+                }
+            }
+        },
+        "models.OutputSpec": {
+            "description": "Output of a multi-output job",
+            "type": "object",
+            "properties": {
+                "bitrate": {
+                    "type": "string",
+                    "example": "320k"
+                },
+                "format": {
+                    "type": "string",
+                    "example": "mp3"
+                },
+                "quality": {
+                    "description": "Video outputs share one quality (one source download)",
+                    "type": "string",
+                    "example": "1080p"
+                },
+                "type": {
+                    "type": "string",
+                    "enum": [
+                        "video",
+                        "audio"
+                    ],
+                    "example": "audio"
+                }
+            }
+        },
+        "models.OutputStatus": {
+            "description": "Additional output status",
+            "type": "object",
+            "properties": {
+                "bitrate": {
+                    "type": "string",
+                    "example": "320k"
+                },
+                "downloadUrl": {
+                    "type": "string",
+                    "example": "https://api.ytconvert.org/files/abc123/output_2.mp3?t=xxx"
+                },
+                "error": {
+                    "type": "string",
+                    "example": "Conversion failed"
+                },
+                "format": {
+                    "type": "string",
+                    "example": "mp3"
+                },
+                "name": {
+                    "type": "string",
+                    "example": "output_2.mp3"
+                },
+                "progress": {
+                    "type": "integer",
+                    "example": 100
+                },
+                "status": {
+                    "type": "string",
+                    "enum": [
+                        "pending",
+                        "processing",
+                        "completed",
+                        "error"
+                    ],
+                    "example": "completed"
+                }
+            }
+        },
+        "models.PlanThresholds": {
+            "type": "object",
+            "properties": {
+                "maxFileDuration": {
+                    "description": "Longest job pre-rendered for its CPU class (seconds)",
+                    "type": "number",
+                    "example": 14400
+                },
+                "parallelDownloadBytes": {
+                    "description": "Sources larger than this download in parallel",
+                    "type": "integer",
+                    "example": 10000000
+                }
+            }
+        },
+        "models.RestoreResponse": {
+            "description": "Restore job response",
+            "type": "object",
+            "properties": {
+                "restored": {
+                    "type": "boolean",
+                    "example": true
+                }
+            }
+        },
+        "models.SelectedStream": {
+            "description": "Selected source stream",
+            "type": "object",
+            "properties": {
+                "bitrate": {
+                    "description": "bits/s",
+                    "type": "integer",
+                    "example": 4200000
+                },
+                "codec": {
+                    "type": "string",
+                    "example": "av01"
+                },
+                "fps": {
+                    "type": "integer",
+                    "example": 30
+                },
+                "height": {
+                    "type": "integer",
+                    "example": 1080
+                },
+                "itag": {
+                    "type": "integer",
+                    "example": 399
+                },
+                "language": {
+                    "type": "string",
+                    "example": "en"
+                },
+                "size": {
+                    "type": "integer",
+                    "example": 210000000
+                },
+                "trackId": {
+                    "type": "string",
+                    "example": "en.4"
+                }
+            }
+        },
+        "models.SilenceTrim": {
+            "description": "Detected silence boundaries (seconds)",
+            "type": "object",
+            "properties": {
+                "end": {
+                    "description": "Start of trailing silence",
+                    "type": "number",
+                    "example": 211.8
+                },
+                "start": {
+                    "description": "End of leading silence",
+                    "type": "number",
+                    "example": 2.35
+                }
+            }
+        },
+        "models.StatusResponse": {
+            "description": "Job status response",
+            "type": "object",
+            "properties": {
+                "audioChannels": {
+                    "description": "Jobs with audio once downloaded",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.AudioChannels"
+                        }
+                    ]
+                },
+                "author": {
+                    "type": "string",
+                    "example": "Rick Astley"
+                },
+                "downloadUrl": {
+                    "type": "string",
+                    "example": "https://api.ytconvert.org/files/abc123/output.mp4?token=xxx\u0026expires=123"
+                },
+                "downloadUrlExpiresAt": {
+                    "description": "When downloadUrl stops being accepted for new requests (ms)",
+                    "type": "integer",
+                    "example": 1705125856789
+                },
+                "duration": {
+                    "type": "number",
+                    "example": 213.5
+                },
+                "estimatedSize": {
+                    "description": "Stream-only jobs of copied streams: estimated /stream body size (bytes)",
+                    "type": "integer",
+                    "example": 734003200
+                },
+                "expiresAt": {
+                    "description": "When the job is removed (ms)",
+                    "type": "integer",
+                    "example": 1705124056789
+                },
+                "faststart": {
+                    "description": "mp4-family output with its moov atom first (progressive playback)",
+                    "type": "boolean",
+                    "example": true
+                },
+                "groupId": {
+                    "description": "Job group the job belongs to",
+                    "type": "string",
+                    "example": "Uakgb_J5m9g-0JDMbcJqL"
+                },
+                "jobError": {
+                    "type": "string",
+                    "example": "Download failed: connection timeout"
+                },
+                "jobErrorDetail": {
+                    "$ref": "#/definitions/models.JobError"
+                },
+                "lastStreamError": {
+                    "description": "Most recent /stream transfer that ended early",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.StreamError"
+                        }
+                    ]
+                },
+                "lossyToLossless": {
+                    "type": "boolean",
+                    "example": false
+                },
+                "maxExpiresAt": {
+                    "description": "Latest expiresAt reachable via POST /api/jobs/:id/extend (ms)",
+                    "type": "integer",
+                    "example": 1705142056789
+                },
+                "muted": {
+                    "description": "Video output without an audio stream (audio.mute)",
+                    "type": "boolean",
+                    "example": false
+                },
+                "outputDuration": {
+                    "description": "Output length (seconds) when audio.tempo changes it; duration is the video's",
+                    "type": "number",
+                    "example": 284.7
+                },
+                "outputs": {
+                    "description": "Additional outputs (POST /api/jobs/:id/convert)",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.OutputStatus"
+                    }
+                },
+                "progress": {
+                    "type": "integer",
+                    "example": 45
+                },
+                "queuePosition": {
+                    "description": "1-based position among jobs waiting to download or for processing",
+                    "type": "integer",
+                    "example": 3
+                },
+                "request": {
+                    "description": "Only with ?includeRequest=1",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.JobRequest"
+                        }
+                    ]
+                },
+                "rotation": {
+                    "description": "Video jobs once downloaded",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.VideoRotation"
+                        }
+                    ]
+                },
+                "selection": {
+                    "$ref": "#/definitions/models.StreamSelection"
+                },
+                "shareUrl": {
+                    "type": "string",
+                    "example": "https://api.ytconvert.org/d/abc123/Video_Title.mp4?t=xxx"
+                },
+                "silenceTrim": {
+                    "$ref": "#/definitions/models.SilenceTrim"
+                },
+                "stale": {
+                    "description": "The job's metadata couldn't be read; this is the last status returned",
+                    "type": "boolean",
+                    "example": false
+                },
+                "status": {
+                    "type": "string",
+                    "enum": [
+                        "pending",
+                        "processing",
+                        "completed",
+                        "error"
+                    ],
+                    "example": "pending"
+                },
+                "thumbnailUrl": {
+                    "type": "string",
+                    "example": "https://i.ytimg.com/vi/dQw4w9WgXcQ/hqdefault.jpg"
+                },
+                "title": {
+                    "type": "string",
+                    "example": "Rick Astley - Never Gonna Give You Up"
+                },
+                "transcript": {
+                    "description": "audio.transcript jobs with captions, once completed",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.TranscriptStatus"
+                        }
+                    ]
+                },
+                "transcriptAvailable": {
+                    "description": "audio.transcript jobs, once completed",
+                    "type": "boolean",
+                    "example": true
+                },
+                "uploadDate": {
+                    "type": "string",
+                    "example": "2009-10-25"
+                },
+                "viewCount": {
+                    "type": "integer",
+                    "example": 1500000000
+                },
+                "warnings": {
+                    "description": "Everything accepted differently from the request; empty when none",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.Warning"
+                    }
+                }
+            }
+        },
+        "models.StorageState": {
+            "description": "Storage volume state",
             "type": "object",
             "properties": {
-                "bitrate": {
-                    "type": "string",
-                    "enum": [
-                        "64k",
-                        "128k",
-                        "192k",
-                        "320k"
-                    ],
-                    "example": "192k"
+                "freeBytes": {
+                    "type": "integer",
+                    "example": 52428800000
                 },
-                "trackId": {
-                    "type": "string",
-                    "example": "en.vss_abc123"
+                "full": {
+                    "description": "true = POST /api/download returns 507 STORAGE_FULL",
+                    "type": "boolean",
+                    "example": false
+                },
+                "quotaBytes": {
+                    "description": "STORAGE_QUOTA_MB; usedBytes at or past it = 507 STORAGE_QUOTA_EXCEEDED",
+                    "type": "integer",
+                    "example": 10737418240
+                },
+                "usedBytes": {
+                    "description": "Job files, HLS segments and cached sources, counted toward quotaBytes",
+                    "type": "integer",
+                    "example": 1073741824
                 }
             }
         },
-        "models.DeleteResponse": {
-            "description": "Delete job response",
+        "models.StreamError": {
+            "description": "Failed stream transfer (the response ended with X-Stream-Status: error)",
             "type": "object",
             "properties": {
-                "deleted": {
-                    "type": "boolean",
-                    "example": true
+                "at": {
+                    "description": "Unix ms",
+                    "type": "integer",
+                    "example": 1705124056789
+                },
+                "bytesSent": {
+                    "description": "Body bytes written before the failure",
+                    "type": "integer",
+                    "example": 1048576
+                },
+                "error": {
+                    "type": "string",
+                    "example": "ffmpeg exited: exit status 1"
                 }
             }
         },
-        "models.DownloadRequest": {
-            "description": "Download request payload",
+        "models.StreamSelection": {
+            "description": "Selected streams and processing plan",
             "type": "object",
             "properties": {
                 "audio": {
-                    "$ref": "#/definitions/models.AudioConfig"
+                    "$ref": "#/definitions/models.SelectedStream"
                 },
-                "os": {
-                    "type": "string",
-                    "enum": [
-                        "ios",
-                        "android",
-                        "macos",
-                        "windows",
-                        "linux"
-                    ],
-                    "example": "windows"
+                "merge": {
+                    "description": "Video and audio are combined into one file",
+                    "type": "boolean",
+                    "example": true
                 },
-                "output": {
-                    "$ref": "#/definitions/models.OutputConfig"
+                "sourceSize": {
+                    "type": "integer",
+                    "example": 220200960
                 },
-                "trim": {
-                    "$ref": "#/definitions/models.TrimConfig"
+                "streamOnly": {
+                    "description": "Too long to pre-merge; served via /stream only",
+                    "type": "boolean",
+                    "example": false
                 },
-                "url": {
-                    "type": "string",
-                    "example": "https://youtube.com/watch?v=dQw4w9WgXcQ"
+                "transcode": {
+                    "description": "Re-encoding (instead of stream copy)",
+                    "type": "boolean",
+                    "example": false
+                },
+                "video": {
+                    "$ref": "#/definitions/models.SelectedStream"
                 }
             }
         },
-        "models.DownloadResponse": {
-            "description": "Response after creating a download job",
+        "models.Transcript": {
             "type": "object",
             "properties": {
-                "duration": {
-                    "type": "number",
-                    "example": 213.5
+                "available": {
+                    "description": "transcript.vtt/.txt were written",
+                    "type": "boolean"
                 },
-                "id": {
-                    "type": "string",
-                    "example": "V1StGXR8_Z5jdHi"
+                "generated": {
+                    "description": "Speech recognition (ASR) captions",
+                    "type": "boolean"
                 },
-                "needsReencode": {
-                    "type": "boolean",
-                    "example": false
+                "language": {
+                    "type": "string"
                 },
-                "qualityChangeReason": {
-                    "type": "string",
-                    "example": "1080p not available, using 720p"
+                "name": {
+                    "type": "string"
                 },
-                "qualityChanged": {
+                "url": {
+                    "description": "Caption source; empty when the video has none",
+                    "type": "string"
+                }
+            }
+        },
+        "models.TranscriptStatus": {
+            "description": "Transcript files, aligned to the trimmed output",
+            "type": "object",
+            "properties": {
+                "generated": {
+                    "description": "Speech recognition (ASR) captions",
                     "type": "boolean",
-                    "example": true
+                    "example": false
                 },
-                "requestedQuality": {
+                "language": {
                     "type": "string",
-                    "example": "1080p"
+                    "example": "en"
                 },
-                "selectedQuality": {
+                "textUrl": {
                     "type": "string",
-                    "example": "720p"
+                    "example": "https://api.ytconvert.org/files/abc123/transcript.txt?t=xxx"
                 },
-                "title": {
+                "vttUrl": {
                     "type": "string",
-                    "example": "Rick Astley - Never Gonna Give You Up"
+                    "example": "https://api.ytconvert.org/files/abc123/transcript.vtt?t=xxx"
                 }
             }
         },
-        "models.HealthResponse": {
-            "description": "Health check response",
+        "models.TrimConfig": {
+            "description": "Trim configuration",
             "type": "object",
             "properties": {
-                "status": {
-                    "type": "string",
-                    "example": "ok"
+                "accurate": {
+                    "type": "boolean",
+                    "example": false
                 },
-                "timestamp": {
-                    "type": "integer",
-                    "example": 1705123456789
+                "end": {
+                    "type": "number",
+                    "example": 60
+                },
+                "fade": {
+                    "description": "Requires re-encoding; forces accurate mode",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.FadeConfig"
+                        }
+                    ]
+                },
+                "start": {
+                    "type": "number",
+                    "example": 10
                 }
             }
         },
-        "models.OutputConfig": {
-            "description": "Output configuration",
+        "models.Usage": {
             "type": "object",
             "properties": {
-                "format": {
-                    "type": "string",
-                    "enum": [
-                        "mp4",
-                        "webm",
-                        "mkv",
-                        "mp3",
-                        "m4a",
-                        "wav",
-                        "opus",
-                        "flac"
-                    ],
-                    "example": "mp4"
+                "originBytes": {
+                    "description": "Downloaded from origin",
+                    "type": "integer"
                 },
-                "quality": {
-                    "type": "string",
-                    "enum": [
-                        "2160p",
-                        "1440p",
-                        "1080p",
-                        "720p",
-                        "480p",
-                        "360p"
-                    ],
-                    "example": "1080p"
+                "servedBytes": {
+                    "description": "Written to clients",
+                    "type": "integer"
                 },
-                "type": {
-                    "type": "string",
-                    "enum": [
-                        "video",
-                        "audio"
-                    ],
-                    "example": "video"
+                "servedCount": {
+                    "description": "Number of transfers to clients",
+                    "type": "integer"
                 }
             }
         },
-        "models.StatusResponse": {
-            "description": "Job status response",
+        "models.VideoInfoResponse": {
+            "description": "Video metadata",
             "type": "object",
             "properties": {
-                "downloadUrl": {
+                "audioTracks": {
+                    "description": "Values of audio.trackId",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.AudioTrack"
+                    }
+                },
+                "author": {
                     "type": "string",
-                    "example": "https://api.ytconvert.org/files/abc123/output.mp4?token=xxx\u0026expires=123"
+                    "example": "Rick Astley"
                 },
                 "duration": {
                     "type": "number",
                     "example": 213.5
                 },
-                "jobError": {
+                "thumbnailUrl": {
                     "type": "string",
-                    "example": "Download failed: connection timeout"
+                    "example": "https://i.ytimg.com/vi/dQw4w9WgXcQ/maxresdefault.jpg"
                 },
-                "progress": {
-                    "type": "integer",
-                    "example": 45
+                "title": {
+                    "type": "string",
+                    "example": "Rick Astley - Never Gonna Give You Up"
                 },
-                "status": {
+                "uploadDate": {
+                    "description": "YYYY-MM-DD",
+                    "type": "string",
+                    "example": "2009-10-25"
+                },
+                "videoId": {
+                    "type": "string",
+                    "example": "dQw4w9WgXcQ"
+                },
+                "viewCount": {
+                    "type": "integer",
+                    "example": 1500000000
+                }
+            }
+        },
+        "models.VideoRotation": {
+            "description": "Detected source rotation and its handling",
+            "type": "object",
+            "properties": {
+                "action": {
                     "type": "string",
                     "enum": [
-                        "pending",
-                        "completed",
-                        "error"
+                        "none",
+                        "applied",
+                        "forced",
+                        "preserved",
+                        "ignored"
                     ],
-                    "example": "pending"
+                    "example": "preserved"
                 },
-                "title": {
-                    "type": "string",
-                    "example": "Rick Astley - Never Gonna Give You Up"
+                "degrees": {
+                    "description": "Clockwise display rotation of the source: 0, 90, 180 or 270",
+                    "type": "integer",
+                    "example": 90
                 }
             }
         },
-        "models.TrimConfig": {
-            "description": "Trim configuration",
+        "models.Warning": {
+            "description": "Request accepted with a change clients may want to show",
             "type": "object",
             "properties": {
-                "accurate": {
-                    "type": "boolean",
-                    "example": false
+                "code": {
+                    "type": "string",
+                    "enum": [
+                        "QUALITY_DOWNGRADED",
+                        "VIDEO_TRANSCODED",
+                        "AUDIO_TRACK_FALLBACK",
+                        "SELECTION_CHANGED",
+                        "BITRATE_CLAMPED",
+                        "TRIM_CLAMPED",
+                        "LOSSY_TO_LOSSLESS",
+                        "STREAM_ONLY"
+                    ],
+                    "example": "QUALITY_DOWNGRADED"
                 },
-                "end": {
-                    "type": "number",
-                    "example": 60
+                "field": {
+                    "description": "Request field the warning is about",
+                    "type": "string",
+                    "example": "output.quality"
                 },
-                "start": {
-                    "type": "number",
-                    "example": 10
+                "message": {
+                    "type": "string",
+                    "example": "1080p not available, using 720p"
                 }
             }
         },
@@ -564,6 +4265,44 @@ const docTemplate = `{
                     "$ref": "#/definitions/utils.ErrorDetail"
                 }
             }
+        },
+        "utils.JobDeletedResponse": {
+            "type": "object",
+            "properties": {
+                "deletedAt": {
+                    "description": "Soft delete time (ms)",
+                    "type": "integer"
+                },
+                "error": {
+                    "$ref": "#/definitions/utils.ErrorDetail"
+                }
+            }
+        },
+        "utils.OverloadResponse": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "$ref": "#/definitions/utils.ErrorDetail"
+                },
+                "estimatedWaitSeconds": {
+                    "type": "integer"
+                }
+            }
+        },
+        "utils.TrackNotFoundResponse": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "$ref": "#/definitions/utils.ErrorDetail"
+                },
+                "tracks": {
+                    "description": "Tracks the video offers",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.AudioTrack"
+                    }
+                }
+            }
         }
     }
 }`
@@ -571,9 +4310,9 @@ const docTemplate = `{
 // SwaggerInfo holds exported Swagger Info so clients can modify it
 var SwaggerInfo = &swag.Spec{
 	Version:          "2.0",
-	Host:             "",
+	Host:             "api.ytconvert.org",
 	BasePath:         "/",
-	Schemes:          []string{},
+	Schemes:          []string{"https", "http"},
 	Title:            "YT Downloader API",
 	Description:      "API for downloading YouTube videos and audio",
 	InfoInstanceName: "swagger",
@@ -0,0 +1,95 @@
+package e2e
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"testing"
+	"yt-downloader-go/config"
+	"yt-downloader-go/models"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Media goes out as stored even when the client accepts compression
+func TestMediaNotCompressed(t *testing.T) {
+	audioVideo("e2eGzipFile", 213, 64_000)
+	mergeVideo("e2eGzipLong", config.MaxMergeDurationRemux+60)
+	file := waitForJob(t, startJob(t, `{"url":"https://youtu.be/e2eGzipFile","output":{"type":"audio","format":"mp3"}}`))
+	streamed := waitForJob(t, startJob(t, `{"url":"https://youtu.be/e2eGzipLong","output":{"type":"video","format":"mp4","quality":"720p"}}`))
+	assertCompleted(t, file)
+	assertCompleted(t, streamed)
+
+	tests := []struct {
+		name string
+		url  string
+		want []byte
+	}{
+		{name: "/files", url: file.DownloadURL, want: origin.data("e2eGzipFile-251")},
+		{name: "/d", url: file.ShareURL, want: origin.data("e2eGzipFile-251")},
+		{name: "/stream", url: streamed.DownloadURL, want: concat(origin.data("e2eGzipLong-136"), origin.data("e2eGzipLong-140"))},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, body := getWithHeaders(t, tt.url, map[string]string{fiber.HeaderAcceptEncoding: "zstd, gzip, br"})
+			if resp.StatusCode != fiber.StatusOK {
+				t.Fatalf("status %d: %s", resp.StatusCode, body)
+			}
+			if encoding := resp.Header.Get(fiber.HeaderContentEncoding); encoding != "" {
+				t.Errorf("Content-Encoding %s, want none", encoding)
+			}
+			if !bytes.Equal(body, tt.want) {
+				t.Errorf("body is %d bytes, want the %d bytes of the media", len(body), len(tt.want))
+			}
+		})
+	}
+}
+
+func TestAdminJobsCompressed(t *testing.T) {
+	// Enough jobs for a listing well over API_COMPRESS_MIN_BYTES
+	for _, videoID := range []string{"e2eGzipJob1", "e2eGzipJob2", "e2eGzipJob3"} {
+		audioVideo(videoID, 213, 20_000)
+		waitForJob(t, startJob(t, `{"url":"https://youtu.be/`+videoID+`","output":{"type":"audio","format":"mp3"}}`))
+	}
+	const listing = "/api/admin/jobs?limit=1000"
+
+	plainResp, plain := getWithHeaders(t, listing, map[string]string{"X-API-Key": adminKey})
+	if plainResp.StatusCode != fiber.StatusOK {
+		t.Fatalf("status %d: %s", plainResp.StatusCode, plain)
+	}
+	if encoding := plainResp.Header.Get(fiber.HeaderContentEncoding); encoding != "" {
+		t.Errorf("without Accept-Encoding: Content-Encoding %s, want none", encoding)
+	}
+	if len(plain) < config.APICompressMinBytes {
+		t.Fatalf("listing is %d bytes, want at least %d", len(plain), config.APICompressMinBytes)
+	}
+
+	resp, body := getWithHeaders(t, listing, map[string]string{"X-API-Key": adminKey, fiber.HeaderAcceptEncoding: "gzip"})
+	if encoding := resp.Header.Get(fiber.HeaderContentEncoding); encoding != "gzip" {
+		t.Fatalf("Content-Encoding %q, want gzip", encoding)
+	}
+	if vary := resp.Header.Get(fiber.HeaderVary); vary != fiber.HeaderAcceptEncoding {
+		t.Errorf("Vary %q, want %s", vary, fiber.HeaderAcceptEncoding)
+	}
+	if len(body)*2 > len(plain) {
+		t.Errorf("gzip listing is %d bytes, want under half of the %d plain bytes", len(body), len(plain))
+	}
+
+	reader, err := gzip.NewReader(bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var jobs models.AdminJobsResponse
+	if err := json.Unmarshal(decoded, &jobs); err != nil {
+		t.Fatalf("decode gunzipped listing: %v", err)
+	}
+	if !bytes.Equal(decoded, plain) {
+		t.Errorf("gunzipped listing differs from the plain one")
+	}
+}
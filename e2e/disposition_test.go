@@ -0,0 +1,150 @@
+package e2e
+
+import (
+	"path"
+	"strings"
+	"testing"
+	"time"
+	"yt-downloader-go/config"
+	"yt-downloader-go/models"
+	"yt-downloader-go/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// displayFilename returns the filename a job's downloads are served as
+func displayFilename(t *testing.T, created models.DownloadResponse) string {
+	t.Helper()
+	meta, err := utils.ReadMeta(jobID(t, created))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return utils.GetDisplayFilename(meta)
+}
+
+func TestInlineDisposition(t *testing.T) {
+	audioVideo("e2eInline01", 213, 20_000)
+	mergeVideo("e2eInlineSt", config.MaxMergeDurationRemux+60)
+	fileJob := startJob(t, `{"url":"https://youtu.be/e2eInline01","output":{"type":"audio","format":"mp3"}}`)
+	streamJob := startJob(t, `{"url":"https://youtu.be/e2eInlineSt","output":{"type":"video","format":"mp4","quality":"720p"}}`)
+	file := waitForJob(t, fileJob)
+	streamed := waitForJob(t, streamJob)
+	assertCompleted(t, file)
+	assertCompleted(t, streamed)
+
+	// A merged job's /stream link redirects to /files
+	redirect := utils.GenerateStreamURL(jobID(t, fileJob), "", time.Now().Add(time.Hour))
+
+	tests := []struct {
+		name     string
+		url      string
+		filename string
+		ranges   bool // Served from a file, seekable
+	}{
+		{name: "/files", url: file.DownloadURL, filename: displayFilename(t, fileJob), ranges: true},
+		{name: "/stream", url: streamed.DownloadURL, filename: displayFilename(t, streamJob)},
+		{name: "/stream to /files", url: redirect, filename: displayFilename(t, fileJob), ranges: true},
+	}
+
+	for _, tt := range tests {
+		for _, inline := range []bool{false, true} {
+			name := tt.name + " attachment"
+			rawURL := tt.url
+			if inline {
+				name = tt.name + " inline"
+				rawURL += "&inline=1"
+			}
+			t.Run(name, func(t *testing.T) {
+				resp, body := getWithHeaders(t, rawURL, nil)
+				if resp.StatusCode == fiber.StatusTemporaryRedirect {
+					location := resp.Header.Get(fiber.HeaderLocation)
+					if strings.Contains(location, "inline=1") != inline {
+						t.Errorf("redirected to %s, want inline=1 kept only when requested", location)
+					}
+					resp, body = getWithHeaders(t, location, nil)
+				}
+				if resp.StatusCode != fiber.StatusOK {
+					t.Fatalf("status %d: %s", resp.StatusCode, body)
+				}
+
+				if got, want := resp.Header.Get(fiber.HeaderContentDisposition), utils.ContentDisposition(tt.filename, inline); got != want {
+					t.Errorf("Content-Disposition %s, want %s", got, want)
+				}
+				if got := resp.Header.Get(fiber.HeaderXContentTypeOptions); got != "nosniff" {
+					t.Errorf("X-Content-Type-Options %q, want nosniff", got)
+				}
+				if got := resp.Header.Get(fiber.HeaderAcceptRanges); tt.ranges && got != "bytes" {
+					t.Errorf("Accept-Ranges %q, want bytes", got)
+				}
+			})
+		}
+	}
+}
+
+// Every route serves a job under the same display name, trimmed or not
+func TestDisplayFilenameAcrossRoutes(t *testing.T) {
+	audioVideo("e2eName0001", 213, 20_000)
+	mergeVideo("e2eNameSt01", config.MaxMergeDurationRemux+60)
+
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{
+			name: "untrimmed file",
+			body: `{"url":"https://youtu.be/e2eName0001","output":{"type":"audio","format":"mp3"},"audio":{"bitrate":"192k"}}`,
+			want: "Song_e2eName0001_192k.mp3",
+		},
+		{
+			name: "trimmed file",
+			body: `{"url":"https://youtu.be/e2eName0001","output":{"type":"audio","format":"mp3"},"audio":{"bitrate":"192k"},"trim":{"start":10,"end":25}}`,
+			want: "Song_e2eName0001_192k_10-25s.mp3",
+		},
+		{
+			name: "sub-second trim",
+			body: `{"url":"https://youtu.be/e2eName0001","output":{"type":"audio","format":"mp3"},"audio":{"bitrate":"192k"},"trim":{"start":0,"end":0.5}}`,
+			want: "Song_e2eName0001_192k_0-0.5s.mp3",
+		},
+		{
+			name: "untrimmed stream",
+			body: `{"url":"https://youtu.be/e2eNameSt01","output":{"type":"video","format":"mp4","quality":"720p"}}`,
+			want: "Clip_e2eNameSt01_720p.mp4",
+		},
+		{
+			name: "trimmed stream",
+			body: `{"url":"https://youtu.be/e2eNameSt01","output":{"type":"video","format":"mp4","quality":"720p"},"trim":{"start":10,"end":25}}`,
+			want: "Clip_e2eNameSt01_720p_10-25s.mp4",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			created := startJob(t, tt.body)
+			status := waitForJob(t, created)
+			assertCompleted(t, status)
+			id := jobID(t, created)
+
+			links := map[string]string{"/stream": utils.GenerateStreamURL(id, "", time.Now().Add(time.Hour))}
+			if status.ShareURL != "" {
+				links["/files"] = status.DownloadURL
+				links["/d"] = status.ShareURL
+				if segment := path.Base(strings.SplitN(status.ShareURL, "?", 2)[0]); segment != tt.want {
+					t.Errorf("/d name segment %s, want %s", segment, tt.want)
+				}
+			}
+			for route, link := range links {
+				resp, body := getWithHeaders(t, link, nil)
+				if resp.StatusCode == fiber.StatusTemporaryRedirect {
+					resp, body = getWithHeaders(t, resp.Header.Get(fiber.HeaderLocation), nil)
+				}
+				if resp.StatusCode != fiber.StatusOK {
+					t.Fatalf("%s: status %d: %s", route, resp.StatusCode, body)
+				}
+				if got, want := resp.Header.Get(fiber.HeaderContentDisposition), utils.ContentDisposition(tt.want, false); got != want {
+					t.Errorf("%s: Content-Disposition %s, want %s", route, got, want)
+				}
+			}
+		})
+	}
+}
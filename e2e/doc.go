@@ -0,0 +1,4 @@
+// Package e2e runs jobs end to end, from POST /api/download to the served file or stream,
+// against a fake Extract API, a fake origin honoring range requests and ffmpeg/ffprobe
+// shims, so the suite needs neither the network nor an ffmpeg build.
+package e2e
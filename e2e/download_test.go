@@ -0,0 +1,245 @@
+package e2e
+
+import (
+	"bytes"
+	"slices"
+	"strings"
+	"testing"
+	"yt-downloader-go/config"
+	"yt-downloader-go/models"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	opusMime = `audio/webm; codecs="opus"`
+	aacMime  = `audio/mp4; codecs="mp4a.40.2"`
+	h264Mime = `video/mp4; codecs="avc1.4d401f"`
+)
+
+// audioVideo is an extract result with a single opus stream of size bytes
+func audioVideo(videoID string, duration float64, size int) *models.ExtractResponse {
+	data := &models.ExtractResponse{
+		Title:        "Song " + videoID,
+		Duration:     duration,
+		AudioStreams: []models.Stream{origin.stream(videoID+"-251", size, 251, opusMime, 0)},
+	}
+	extractAPI.set(videoID, data)
+	return data
+}
+
+// mergeVideo is an extract result with an h264 720p stream and an aac stream
+func mergeVideo(videoID string, duration float64) *models.ExtractResponse {
+	data := &models.ExtractResponse{
+		Title:        "Clip " + videoID,
+		Duration:     duration,
+		VideoStreams: []models.Stream{origin.stream(videoID+"-136", 300_000, 136, h264Mime, 720)},
+		AudioStreams: []models.Stream{origin.stream(videoID+"-140", 60_000, 140, aacMime, 0)},
+	}
+	extractAPI.set(videoID, data)
+	return data
+}
+
+// assertCompleted fails the test unless the job completed
+func assertCompleted(t *testing.T, status models.StatusResponse) {
+	t.Helper()
+	if status.Status != models.StatusCompleted || status.DownloadURL == "" {
+		t.Fatalf("job %s (%s), downloadUrl %q, want completed", status.Status, status.JobError, status.DownloadURL)
+	}
+}
+
+// assertDownload fetches the download URL of a completed job and compares the body with want
+func assertDownload(t *testing.T, status models.StatusResponse, want []byte) {
+	t.Helper()
+	code, body := get(t, status.DownloadURL)
+	if code != fiber.StatusOK {
+		t.Fatalf("download: %d %s", code, body)
+	}
+	if !bytes.Equal(body, want) {
+		t.Errorf("download is %d bytes, want the %d bytes of the sources", len(body), len(want))
+	}
+}
+
+func TestAudioConvert(t *testing.T) {
+	audioVideo("e2eAudio001", 213, 64_000)
+	created := startJob(t, `{"url":"https://youtu.be/e2eAudio001","output":{"type":"audio","format":"mp3"},"audio":{"bitrate":"192k"}}`)
+	if created.ResolvedFormat != "mp3" || created.ResolvedBitrate != "192k" {
+		t.Errorf("resolved %s %s, want mp3 192k", created.ResolvedFormat, created.ResolvedBitrate)
+	}
+
+	status := waitForJob(t, created)
+	assertCompleted(t, status)
+	assertDownload(t, status, origin.data("e2eAudio001-251"))
+	if commands := ffmpegCommands(t, created); len(commands) != 1 || !slices.Contains(commands[0], "libmp3lame") {
+		t.Errorf("ffmpeg runs %q, want one mp3 conversion", commands)
+	}
+}
+
+func TestVideoMerge(t *testing.T) {
+	mergeVideo("e2eVideo001", 213)
+	created := startJob(t, `{"url":"https://youtu.be/e2eVideo001","output":{"type":"video","format":"mp4","quality":"720p"}}`)
+
+	status := waitForJob(t, created)
+	assertCompleted(t, status)
+	assertDownload(t, status, concat(origin.data("e2eVideo001-136"), origin.data("e2eVideo001-140")))
+	commands := ffmpegCommands(t, created)
+	if len(commands) != 1 {
+		t.Fatalf("ffmpeg runs %q, want one merge", commands)
+	}
+
+	// The moov position itself is checked on real output in services (TestFaststartMoovFirst)
+	if merge := strings.Join(commands[0], " "); !strings.Contains(merge, "-movflags +faststart") || !status.Faststart {
+		t.Errorf("merge %q, faststart %t, want the mp4 written with +faststart", merge, status.Faststart)
+	}
+}
+
+func TestTrim(t *testing.T) {
+	audioVideo("e2eTrim0001", 213, 64_000)
+	created := startJob(t, `{"url":"https://youtu.be/e2eTrim0001","output":{"type":"audio","format":"mp3"},"trim":{"start":10,"end":25}}`)
+
+	status := waitForJob(t, created)
+	assertCompleted(t, status)
+	assertDownload(t, status, origin.data("e2eTrim0001-251"))
+
+	// Converted first, then cut from the converted output
+	commands := ffmpegCommands(t, created)
+	if len(commands) != 2 {
+		t.Fatalf("ffmpeg runs %q, want a conversion and a trim", commands)
+	}
+	if trim := strings.Join(commands[1], " "); !strings.Contains(trim, "-ss 10.000") || !strings.Contains(trim, "-t 15.000") {
+		t.Errorf("trim run %q, want -ss 10.000 and -t 15.000", trim)
+	}
+}
+
+func TestStreamOnlyLongVideo(t *testing.T) {
+	mergeVideo("e2eLong0001", config.MaxMergeDurationRemux+60)
+	created := startJob(t, `{"url":"https://youtu.be/e2eLong0001","output":{"type":"video","format":"mp4","quality":"720p"}}`)
+
+	status := waitForJob(t, created)
+	assertCompleted(t, status)
+	if !strings.HasPrefix(status.DownloadURL, config.PublicURL+"/stream/") {
+		t.Errorf("downloadUrl %s, want a /stream URL", status.DownloadURL)
+	}
+	if len(status.Warnings) != 1 || status.Warnings[0].Code != models.WarnStreamOnly {
+		t.Errorf("warnings %+v, want %s", status.Warnings, models.WarnStreamOnly)
+	}
+
+	// Nothing is rendered ahead: /stream remuxes the sources as it serves them
+	if commands := ffmpegCommands(t, created); len(commands) != 0 {
+		t.Errorf("ffmpeg runs %q, want none", commands)
+	}
+	assertDownload(t, status, concat(origin.data("e2eLong0001-136"), origin.data("e2eLong0001-140")))
+}
+
+func TestChunkFailureRetried(t *testing.T) {
+	// Two chunks; the first request of the second fails
+	audioVideo("e2eChunk001", 213, config.ChunkSize+50_000)
+	origin.failNext("e2eChunk001-251", config.ChunkSize, 1)
+	created := startJob(t, `{"url":"https://youtu.be/e2eChunk001","output":{"type":"audio","format":"mp3"}}`)
+
+	status := waitForJob(t, created)
+	assertCompleted(t, status)
+	assertDownload(t, status, origin.data("e2eChunk001-251"))
+	for start, want := range map[int]int{0: 1, config.ChunkSize: 2} {
+		if got := origin.requestCount("e2eChunk001-251", start); got != want {
+			t.Errorf("range %d-: %d requests, want %d", start, got, want)
+		}
+	}
+}
+
+// The shim copies its input, so the length of the tempo output is checked on real output in
+// services (TestAudioEffectsDuration); here the job must pass the filter and report the length
+func TestAudioTempo(t *testing.T) {
+	audioVideo("e2eTempo001", 213, 64_000)
+	created := startJob(t, `{"url":"https://youtu.be/e2eTempo001","output":{"type":"audio","format":"mp3"},"audio":{"tempo":0.75,"pitchSemitones":-2}}`)
+
+	status := waitForJob(t, created)
+	assertCompleted(t, status)
+	if status.OutputDuration != 284 {
+		t.Errorf("outputDuration %v, want 284 (213s at 0.75x)", status.OutputDuration)
+	}
+	commands := ffmpegCommands(t, created)
+	if len(commands) != 1 {
+		t.Fatalf("ffmpeg runs %q, want one conversion", commands)
+	}
+	filter := commands[0][slices.Index(commands[0], "-af")+1]
+	if !strings.Contains(filter, "asetrate=") || !strings.Contains(filter, "atempo=") {
+		t.Errorf("filter %q, want a pitch shift and atempo", filter)
+	}
+	if name := displayFilename(t, created); !strings.Contains(name, "_0.75x") || !strings.Contains(name, "-2st") {
+		t.Errorf("filename %q, want the tempo and pitch", name)
+	}
+}
+
+// The shim can't drop a stream, so zero audio streams in the output is checked by ffprobe in
+// services (TestMutedOutputHasNoAudio); here nothing of the audio is downloaded or mapped
+func TestMutedVideo(t *testing.T) {
+	mergeVideo("e2eMuted001", 213)
+	created := startJob(t, `{"url":"https://youtu.be/e2eMuted001","output":{"type":"video","format":"mp4","quality":"720p"},"audio":{"mute":true}}`)
+	if !created.Muted {
+		t.Error("download response not muted")
+	}
+
+	status := waitForJob(t, created)
+	assertCompleted(t, status)
+	if !status.Muted {
+		t.Error("status not muted")
+	}
+	assertDownload(t, status, origin.data("e2eMuted001-136"))
+	if got := origin.requestCount("e2eMuted001-140", 0); got != 0 {
+		t.Errorf("audio stream requested %d times, want never", got)
+	}
+
+	commands := ffmpegCommands(t, created)
+	if len(commands) != 1 {
+		t.Fatalf("ffmpeg runs %q, want one remux", commands)
+	}
+	if merge := commands[0]; !slices.Contains(merge, "-an") || slices.Contains(merge, "-c:a") {
+		t.Errorf("remux %q, want a single input with -an", merge)
+	}
+}
+
+// The shim doesn't cut, so the output length is checked by ffprobe in services
+// (TestAccurateTrimDuration); here the accurate trim seeks coarse before the input and exact after
+func TestAccurateTrim(t *testing.T) {
+	audioVideo("e2eTrimAcc1", 213, 64_000)
+	created := startJob(t, `{"url":"https://youtu.be/e2eTrimAcc1","output":{"type":"audio","format":"mp3"},"trim":{"start":25,"end":40,"accurate":true}}`)
+
+	status := waitForJob(t, created)
+	assertCompleted(t, status)
+	commands := ffmpegCommands(t, created)
+	if len(commands) != 2 {
+		t.Fatalf("ffmpeg runs %q, want a conversion and a trim", commands)
+	}
+	if trim := strings.Join(commands[1], " "); !strings.Contains(trim, "-ss 15.000 -i ") || !strings.Contains(trim, " -ss 10.000 -t 15.000") {
+		t.Errorf("trim run %q, want -ss 15.000 before the input and -ss 10.000 -t 15.000 after", trim)
+	}
+}
+
+// The shim appends its inputs, so the ffmetadata the chapters run reads ends the download: its
+// times are shifted by the trim. ffprobe reads the markers of real output in services
+// (TestChapterMarkers).
+func TestTrimmedChapters(t *testing.T) {
+	data := audioVideo("e2eChapter1", 213, 64_000)
+	data.Chapters = []models.Chapter{
+		{Title: "Intro", Start: 0},
+		{Title: "Lecture", Start: 60},
+		{Title: "Q&A; live", Start: 120},
+		{Title: "Outro", Start: 180},
+	}
+	extractAPI.set("e2eChapter1", data)
+	created := startJob(t, `{"url":"https://youtu.be/e2eChapter1","output":{"type":"audio","format":"m4b"},"trim":{"start":50,"end":150}}`)
+
+	status := waitForJob(t, created)
+	assertCompleted(t, status)
+	const chapters = ";FFMETADATA1\n" +
+		"[CHAPTER]\nTIMEBASE=1/1000\nSTART=0\nEND=10000\ntitle=Intro\n" +
+		"[CHAPTER]\nTIMEBASE=1/1000\nSTART=10000\nEND=70000\ntitle=Lecture\n" +
+		"[CHAPTER]\nTIMEBASE=1/1000\nSTART=70000\nEND=100000\ntitle=Q&A\\; live\n"
+	assertDownload(t, status, concat(origin.data("e2eChapter1-251"), []byte(chapters)))
+
+	commands := ffmpegCommands(t, created)
+	if len(commands) != 3 || !slices.Contains(commands[2], "-map_chapters") {
+		t.Errorf("ffmpeg runs %q, want a conversion, a trim and the chapters", commands)
+	}
+}
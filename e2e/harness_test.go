@@ -0,0 +1,400 @@
+package e2e
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"yt-downloader-go/config"
+	"yt-downloader-go/handlers"
+	"yt-downloader-go/models"
+	"yt-downloader-go/services"
+	"yt-downloader-go/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ffmpegShim concatenates the -i inputs into the output: the last argument, or stdout for
+// pipe:1. Paths are split on spaces, which the temp dirs of the run don't contain.
+// It waits while the file at $FFMPEG_SHIM_HOLD exists (holdFFmpeg).
+const ffmpegShim = `#!/bin/sh
+while [ -n "$FFMPEG_SHIM_HOLD" ] && [ -e "$FFMPEG_SHIM_HOLD" ]; do
+	sleep 0.01
+done
+inputs=""
+prev=""
+for arg; do
+	if [ "$prev" = "-i" ]; then
+		inputs="$inputs $arg"
+	fi
+	prev="$arg"
+done
+if [ "$prev" = "pipe:1" ]; then
+	exec cat $inputs
+fi
+cat $inputs > "$prev"
+`
+
+// ffprobeShim reports one stereo audio stream without rotation; durations are unknown
+const ffprobeShim = `#!/bin/sh
+case "$*" in
+*format=duration*) exit 1 ;;
+esac
+echo '{"streams":[{"channels":2,"channel_layout":"stereo"}]}'
+`
+
+// adminKey is the admin API key of the run
+const adminKey = "e2e-admin-key"
+
+var (
+	extractAPI *fakeExtractAPI
+	origin     *fakeOrigin
+	app        *fiber.App
+	ffmpegHold string // While this file exists, ffmpeg runs wait
+)
+
+func TestMain(m *testing.M) {
+	os.Exit(runWithFakes(m))
+}
+
+// runWithFakes points storage, the Extract API, origin downloads and ffmpeg at the fakes for
+// the whole run, with API compression and the admin API on. Nothing is restored per test:
+// job goroutines read them after requests return.
+func runWithFakes(m *testing.M) int {
+	dir, err := os.MkdirTemp("", "yt-downloader-e2e-")
+	if err != nil {
+		panic(err)
+	}
+	defer os.RemoveAll(dir)
+
+	config.SetStorageDir(filepath.Join(dir, "storage"))
+	config.SetDownloadProxy(config.ProxyDirect)
+	config.FFmpegPath = installShim(dir, "ffmpeg", ffmpegShim)
+	config.FFprobePath = installShim(dir, "ffprobe", ffprobeShim)
+	services.FFmpeg = services.NewExecRunner(config.FFmpegPath)
+	ffmpegHold = filepath.Join(dir, "ffmpeg.hold")
+	os.Setenv("FFMPEG_SHIM_HOLD", ffmpegHold)
+
+	config.APICompression = true
+	config.AdminAPIKeys = []string{adminKey}
+
+	extractAPI = newFakeExtractAPI()
+	defer extractAPI.Close()
+	config.ExtractAPIBase = extractAPI.URL + "/api/youtube/video"
+
+	origin = newFakeOrigin()
+	defer origin.Close()
+
+	app = newApp()
+	return m.Run()
+}
+
+// holdFFmpeg makes ffmpeg runs wait until the returned release is called (at the latest
+// when the test ends)
+func holdFFmpeg(t *testing.T) (release func()) {
+	t.Helper()
+	if err := os.WriteFile(ffmpegHold, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	release = func() { os.Remove(ffmpegHold) }
+	t.Cleanup(release)
+	return release
+}
+
+// installShim writes an executable script to dir/bin/name and returns its path
+func installShim(dir string, name string, script string) string {
+	binDir := filepath.Join(dir, "bin")
+	if err := os.MkdirAll(binDir, 0755); err != nil {
+		panic(err)
+	}
+	shimPath := filepath.Join(binDir, name)
+	if err := os.WriteFile(shimPath, []byte(script), 0755); err != nil {
+		panic(err)
+	}
+	return shimPath
+}
+
+// newApp routes every route of the server, as main.go does
+func newApp() *fiber.App {
+	app := fiber.New(fiber.Config{ErrorHandler: handlers.HandleError})
+	handlers.RegisterRoutes(app)
+	return app
+}
+
+// fakeExtractAPI answers GET /api/youtube/video/<id> with the result set for the video
+type fakeExtractAPI struct {
+	*httptest.Server
+	mu     sync.Mutex
+	videos map[string]*models.ExtractResponse
+}
+
+func newFakeExtractAPI() *fakeExtractAPI {
+	api := &fakeExtractAPI{videos: map[string]*models.ExtractResponse{}}
+	api.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		api.mu.Lock()
+		defer api.mu.Unlock()
+		data := api.videos[path.Base(r.URL.Path)]
+		if data == nil {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(data)
+	}))
+	return api
+}
+
+// set makes the API answer videoID with data
+func (a *fakeExtractAPI) set(videoID string, data *models.ExtractResponse) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.videos[videoID] = data
+}
+
+// fakeOrigin serves media to range requests in the origin's query style
+// (?id=<media>&range=start-end) and fails chosen requests on demand
+type fakeOrigin struct {
+	*httptest.Server
+	mu       sync.Mutex
+	media    map[string][]byte
+	failures map[string]int           // Requests still to fail, by rangeKey
+	requests map[string]int           // Requests received, by rangeKey
+	holds    map[string]chan struct{} // Requests wait until closed, by media id
+}
+
+func newFakeOrigin() *fakeOrigin {
+	o := &fakeOrigin{
+		media:    map[string][]byte{},
+		failures: map[string]int{},
+		requests: map[string]int{},
+		holds:    map[string]chan struct{}{},
+	}
+	o.Server = httptest.NewServer(http.HandlerFunc(o.serve))
+	return o
+}
+
+// rangeKey identifies the requests for media starting at offset start
+func rangeKey(id string, start int) string {
+	return fmt.Sprintf("%s@%d", id, start)
+}
+
+func (o *fakeOrigin) serve(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	id := query.Get("id")
+	first, last, ok := strings.Cut(query.Get("range"), "-")
+	start, err1 := strconv.Atoi(first)
+	end, err2 := strconv.Atoi(last)
+
+	o.mu.Lock()
+	data := o.media[id]
+	key := rangeKey(id, start)
+	o.requests[key]++
+	fail := o.failures[key] > 0
+	if fail {
+		o.failures[key]--
+	}
+	hold := o.holds[id]
+	o.mu.Unlock()
+
+	if hold != nil {
+		<-hold
+	}
+
+	switch {
+	case data == nil:
+		http.NotFound(w, r)
+		return
+	case fail:
+		http.Error(w, "injected failure", http.StatusServiceUnavailable)
+		return
+	case !ok || err1 != nil || err2 != nil || start > end || start >= len(data):
+		http.Error(w, "bad range", http.StatusRequestedRangeNotSatisfiable)
+		return
+	}
+	end = min(end, len(data)-1)
+	w.Header().Set("Content-Length", strconv.Itoa(end-start+1))
+	w.Write(data[start : end+1])
+}
+
+// stream registers size bytes of reproducible random media as id and returns an extract
+// API stream serving it, valid for six hours
+func (o *fakeOrigin) stream(id string, size int, itag int, mimeType string, height int) models.Stream {
+	seed := fnv.New64a()
+	seed.Write([]byte(id))
+	data := make([]byte, size)
+	rand.New(rand.NewSource(int64(seed.Sum64()))).Read(data)
+
+	o.mu.Lock()
+	o.media[id] = data
+	o.mu.Unlock()
+
+	return models.Stream{
+		URL:           fmt.Sprintf("%s/videoplayback?id=%s&itag=%d&expire=%d", o.URL, id, itag, time.Now().Add(6*time.Hour).Unix()),
+		MimeType:      mimeType,
+		Height:        height,
+		ContentLength: int64(size),
+		Itag:          itag,
+	}
+}
+
+// data returns the media registered as id
+func (o *fakeOrigin) data(id string) []byte {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.media[id]
+}
+
+// failNext makes the next n requests for media id starting at start fail with a 503
+func (o *fakeOrigin) failNext(id string, start int, n int) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.failures[rangeKey(id, start)] += n
+}
+
+// hold makes requests for media id wait until the returned release is called (at the
+// latest when the test ends)
+func (o *fakeOrigin) hold(t *testing.T, id string) (release func()) {
+	hold := make(chan struct{})
+	o.mu.Lock()
+	o.holds[id] = hold
+	o.mu.Unlock()
+
+	var once sync.Once
+	release = func() { once.Do(func() { close(hold) }) }
+	t.Cleanup(release)
+	return release
+}
+
+// requestCount returns the requests received for media id starting at start
+func (o *fakeOrigin) requestCount(id string, start int) int {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.requests[rangeKey(id, start)]
+}
+
+// get requests a URL returned by the API and returns the status and body
+func get(t *testing.T, rawURL string) (int, []byte) {
+	t.Helper()
+	resp, body := getWithHeaders(t, rawURL, nil)
+	return resp.StatusCode, body
+}
+
+// getWithHeaders requests a URL with extra request headers and returns the response and
+// its body as sent (not decompressed)
+func getWithHeaders(t *testing.T, rawURL string, headers map[string]string) (*http.Response, []byte) {
+	t.Helper()
+	req := httptest.NewRequest("GET", strings.TrimPrefix(rawURL, config.PublicURL), nil)
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	resp, err := app.Test(req, -1)
+	if err != nil {
+		t.Fatalf("GET %s: %v", rawURL, err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("GET %s: %v", rawURL, err)
+	}
+	return resp, body
+}
+
+// startJob posts a download request and returns the created job
+func startJob(t *testing.T, body string) models.DownloadResponse {
+	t.Helper()
+	return startJobWithHeaders(t, body, nil)
+}
+
+// startJobWithHeaders posts a download request with extra request headers and returns the
+// created job
+func startJobWithHeaders(t *testing.T, body string, headers map[string]string) models.DownloadResponse {
+	t.Helper()
+	req := httptest.NewRequest("POST", "/api/download", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	resp, err := app.Test(req, -1)
+	if err != nil {
+		t.Fatalf("request: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != fiber.StatusAccepted {
+		errBody, _ := io.ReadAll(resp.Body)
+		t.Fatalf("status %d: %s", resp.StatusCode, errBody)
+	}
+
+	var created models.DownloadResponse
+	if err := json.NewDecoder(resp.Body).Decode(&created); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	return created
+}
+
+// jobID returns the ID of a created job, from its status URL
+func jobID(t *testing.T, created models.DownloadResponse) string {
+	t.Helper()
+	statusURL, err := url.Parse(created.StatusURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return path.Base(statusURL.Path)
+}
+
+// waitForJob polls the status of a created job until it completed or failed and its run
+// has ended, and returns the final status
+func waitForJob(t *testing.T, created models.DownloadResponse) models.StatusResponse {
+	t.Helper()
+	id := jobID(t, created)
+	lockPath := filepath.Join(utils.GetJobDir(id), "run.lock")
+	for deadline := time.Now().Add(10 * time.Second); time.Now().Before(deadline); time.Sleep(20 * time.Millisecond) {
+		code, body := get(t, created.StatusURL)
+		if code != fiber.StatusOK {
+			t.Fatalf("status: %d %s", code, body)
+		}
+		var status models.StatusResponse
+		if err := json.Unmarshal(body, &status); err != nil {
+			t.Fatalf("decode status: %v", err)
+		}
+		if status.Status != models.StatusCompleted && status.Status != models.StatusError {
+			continue
+		}
+		if _, err := os.Stat(lockPath); os.IsNotExist(err) {
+			return status
+		}
+	}
+	t.Fatalf("job %s still running", id)
+	return models.StatusResponse{}
+}
+
+// ffmpegCommands returns the arguments of the ffmpeg runs recorded for a job
+func ffmpegCommands(t *testing.T, created models.DownloadResponse) [][]string {
+	t.Helper()
+	meta, err := utils.ReadMeta(jobID(t, created))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var commands [][]string
+	for _, command := range meta.FFmpegCommands {
+		commands = append(commands, command.Args)
+	}
+	return commands
+}
+
+// concat joins media in the order the ffmpeg shim writes its inputs
+func concat(parts ...[]byte) []byte {
+	return bytes.Join(parts, nil)
+}
@@ -0,0 +1,56 @@
+package e2e
+
+import (
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"yt-downloader-go/config"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// TestExtractReplay runs a job from the recording in testdata/extract (EXTRACT_REPLAY_DIR).
+// The fake extract API doesn't know the video, so a job that called it would fail. The
+// recorded stream URLs point at origin.invalid and reach the fake origin through it as the
+// download proxy.
+func TestExtractReplay(t *testing.T) {
+	prevReplay, prevProxy := config.ExtractReplayDir, config.WARPProxyURL
+	config.ExtractReplayDir = "testdata/extract"
+	config.SetDownloadProxy(origin.URL)
+	t.Cleanup(func() {
+		config.ExtractReplayDir = prevReplay
+		config.SetDownloadProxy(prevProxy)
+	})
+
+	// The media behind the recorded URLs, at the recorded sizes
+	origin.stream("e2eReplay01-251", 20_000, 251, opusMime, 0)
+	origin.stream("e2eReplay01-140", 24_000, 140, aacMime, 0)
+
+	created := startJob(t, `{"url":"https://youtu.be/e2eReplay01","output":{"type":"audio","format":"mp3"}}`)
+	if created.Title != "Replayed lecture" {
+		t.Errorf("title %q, want the recorded one", created.Title)
+	}
+	status := waitForJob(t, created)
+	assertCompleted(t, status)
+	assertDownload(t, status, origin.data("e2eReplay01-251"))
+	if got := origin.requestCount("e2eReplay01-251", 0); got != 1 {
+		t.Errorf("recorded opus stream requested %d times, want once", got)
+	}
+
+	// Without a recording the job fails instead of falling back to the API, which knows this video
+	t.Run("not recorded", func(t *testing.T) {
+		audioVideo("e2eNoRecord", 213, 20_000)
+		req := httptest.NewRequest("POST", "/api/download", strings.NewReader(`{"url":"https://youtu.be/e2eNoRecord","output":{"type":"audio","format":"mp3"}}`))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req, -1)
+		if err != nil {
+			t.Fatalf("request: %v", err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode < fiber.StatusBadRequest {
+			body, _ := io.ReadAll(resp.Body)
+			t.Errorf("download: %d %s, want an error", resp.StatusCode, body)
+		}
+	})
+}
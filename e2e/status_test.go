@@ -0,0 +1,210 @@
+package e2e
+
+import (
+	"encoding/json"
+	"maps"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+	"yt-downloader-go/config"
+	"yt-downloader-go/models"
+	"yt-downloader-go/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// statusJSON fetches a status URL and decodes the body into a map of its fields
+func statusJSON(t *testing.T, statusURL string) (map[string]json.RawMessage, string) {
+	t.Helper()
+	code, body := get(t, statusURL)
+	if code != fiber.StatusOK {
+		t.Fatalf("status: %d %s", code, body)
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(body, &fields); err != nil {
+		t.Fatalf("decode status: %v", err)
+	}
+	return fields, string(body)
+}
+
+func TestStatusIncludeRequest(t *testing.T) {
+	const (
+		sessionID      = "secret-session-id"
+		bindIP         = "203.0.113.77"
+		idempotencyKey = "secret-idempotency-key"
+		apiKey         = "secret-api-key"
+	)
+	audioVideo("e2eRequest1", 213, 20_000)
+	created := startJobWithHeaders(t, `{"url":"https://youtu.be/e2eRequest1","os":"windows",`+
+		`"output":{"type":"audio","format":"mp3"},"audio":{"bitrate":"192k"},"trim":{"start":10,"end":25},`+
+		`"sessionId":"`+sessionID+`","bindIp":"`+bindIP+`","idempotencyKey":"`+idempotencyKey+`"}`,
+		map[string]string{"X-API-Key": apiKey})
+	waitForJob(t, created)
+
+	fields, _ := statusJSON(t, created.StatusURL)
+	if _, ok := fields["request"]; ok {
+		t.Error("request returned without includeRequest")
+	}
+
+	fields, body := statusJSON(t, created.StatusURL+"&includeRequest=1")
+	var request map[string]json.RawMessage
+	if err := json.Unmarshal(fields["request"], &request); err != nil {
+		t.Fatalf("request %s: %v", fields["request"], err)
+	}
+	allowed := []string{"url", "os", "output", "outputs", "audio", "trim", "priority", "keepSources", "allowTranscode"}
+	for _, field := range slices.Sorted(maps.Keys(request)) {
+		if !slices.Contains(allowed, field) {
+			t.Errorf("request has %s, which isn't allowlisted", field)
+		}
+	}
+	for _, secret := range []string{sessionID, bindIP, idempotencyKey, apiKey} {
+		if strings.Contains(body, secret) {
+			t.Errorf("status carries %q", secret)
+		}
+	}
+
+	var got models.JobRequest
+	if err := json.Unmarshal(fields["request"], &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.OS != "windows" || got.Output.Format != "mp3" || got.Audio.Bitrate != "192k" || got.Priority != models.PriorityNormal {
+		t.Errorf("request %+v, want the os, format, bitrate and default priority sent", got)
+	}
+	if got.Trim == nil || got.Trim.Start != 10 || got.Trim.End != 25 {
+		t.Errorf("request trim %+v, want 10-25", got.Trim)
+	}
+}
+
+// adminJob returns the admin listing entry of a job
+func adminJob(t *testing.T, id string, videoID string) models.AdminJob {
+	t.Helper()
+	resp, body := getWithHeaders(t, "/api/admin/jobs?videoId="+videoID, map[string]string{"X-API-Key": adminKey})
+	if resp.StatusCode != fiber.StatusOK {
+		t.Fatalf("admin jobs: %d %s", resp.StatusCode, body)
+	}
+	var listing models.AdminJobsResponse
+	if err := json.Unmarshal(body, &listing); err != nil {
+		t.Fatalf("decode admin jobs: %v", err)
+	}
+	for _, job := range listing.Jobs {
+		if job.ID == id {
+			return job
+		}
+	}
+	t.Fatalf("job %s not in the admin listing", id)
+	return models.AdminJob{}
+}
+
+func TestClientInfo(t *testing.T) {
+	const (
+		userAgent = "e2e-agent/1.0"
+		referer   = "https://referer.example/page"
+		apiKey    = "client-api-key"
+	)
+	audioVideo("e2eClient01", 213, 20_000)
+	created := startJobWithHeaders(t, `{"url":"https://youtu.be/e2eClient01","output":{"type":"audio","format":"mp3"}}`,
+		map[string]string{fiber.HeaderUserAgent: userAgent, fiber.HeaderReferer: referer, "X-API-Key": apiKey})
+	waitForJob(t, created)
+	id := jobID(t, created)
+
+	// Public status, with or without the request
+	for _, statusURL := range []string{created.StatusURL, created.StatusURL + "&includeRequest=1"} {
+		fields, body := statusJSON(t, statusURL)
+		if _, ok := fields["client"]; ok {
+			t.Errorf("%s: status has client", statusURL)
+		}
+		for _, value := range []string{userAgent, referer, apiKey, utils.ClientBinding("key", apiKey)} {
+			if strings.Contains(body, value) {
+				t.Errorf("%s: status carries %q", statusURL, value)
+			}
+		}
+	}
+
+	client := adminJob(t, id, "e2eClient01").Client
+	want := models.ClientInfo{UserAgent: userAgent, Referer: referer, APIKey: utils.ClientBinding("key", apiKey)}
+	if client == nil || client.IP == "" || client.UserAgent != want.UserAgent || client.Referer != want.Referer || client.APIKey != want.APIKey {
+		t.Fatalf("admin client %+v, want %+v with an IP", client, want)
+	}
+
+	// Past the privacy window the cleanup scrubs the client; the job stays
+	if err := utils.UpdateMeta(id, func(meta *models.Meta) {
+		meta.CreatedAt = time.Now().Add(-config.ClientInfoRetention - time.Minute).UnixMilli()
+	}); err != nil {
+		t.Fatal(err)
+	}
+	utils.CleanupOldJobs()
+	client = adminJob(t, id, "e2eClient01").Client
+	if client == nil || client.ScrubbedAt == 0 || *client != (models.ClientInfo{ScrubbedAt: client.ScrubbedAt}) {
+		t.Errorf("admin client after the retention window %+v, want only scrubbedAt", client)
+	}
+}
+
+// pollStatus polls the status of a created job until until returns true for it, recording
+// every status seen in seen, and returns the last one
+func pollStatus(t *testing.T, created models.DownloadResponse, seen *[]models.StatusResponse, until func(models.StatusResponse) bool) models.StatusResponse {
+	t.Helper()
+	for deadline := time.Now().Add(10 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		code, body := get(t, created.StatusURL)
+		if code != fiber.StatusOK {
+			t.Fatalf("status: %d %s", code, body)
+		}
+		var status models.StatusResponse
+		if err := json.Unmarshal(body, &status); err != nil {
+			t.Fatalf("decode status: %v", err)
+		}
+		*seen = append(*seen, status)
+		if until(status) {
+			return status
+		}
+	}
+	t.Fatalf("job %s never reached the awaited status", jobID(t, created))
+	return models.StatusResponse{}
+}
+
+// statusIs returns a pollStatus condition for one status
+func statusIs(want string) func(models.StatusResponse) bool {
+	return func(status models.StatusResponse) bool { return status.Status == want }
+}
+
+// A polling client sees the download, then the conversion, then the result, with
+// progress never going back
+func TestStatusSequence(t *testing.T) {
+	audioVideo("e2eStages01", 213, 64_000)
+	releaseDownload := origin.hold(t, "e2eStages01-251")
+	releaseFFmpeg := holdFFmpeg(t)
+	created := startJob(t, `{"url":"https://youtu.be/e2eStages01","output":{"type":"audio","format":"mp3"}}`)
+
+	var seen []models.StatusResponse
+	pending := pollStatus(t, created, &seen, func(models.StatusResponse) bool { return true })
+	if pending.Status != models.StatusPending || pending.Progress >= 100 {
+		t.Errorf("while downloading: %s %d%%, want pending under 100%%", pending.Status, pending.Progress)
+	}
+
+	releaseDownload()
+	processing := pollStatus(t, created, &seen, statusIs(models.StatusProcessing))
+	if processing.Progress != 100 || processing.DownloadURL != "" {
+		t.Errorf("while converting: %d%%, downloadUrl %q, want 100%% and no link yet", processing.Progress, processing.DownloadURL)
+	}
+
+	releaseFFmpeg()
+	completed := pollStatus(t, created, &seen, statusIs(models.StatusCompleted))
+	if completed.Progress != 100 || completed.DownloadURL == "" {
+		t.Errorf("completed: %d%%, downloadUrl %q, want 100%% and a link", completed.Progress, completed.DownloadURL)
+	}
+	waitForJob(t, created)
+
+	// Collapsed runs of the same status, in the order seen
+	var stages []string
+	for i, status := range seen {
+		if i > 0 && status.Progress < seen[i-1].Progress {
+			t.Errorf("progress went from %d%% to %d%%", seen[i-1].Progress, status.Progress)
+		}
+		if len(stages) == 0 || stages[len(stages)-1] != status.Status {
+			stages = append(stages, status.Status)
+		}
+	}
+	if want := []string{models.StatusPending, models.StatusProcessing, models.StatusCompleted}; !slices.Equal(stages, want) {
+		t.Errorf("statuses seen %v, want %v", stages, want)
+	}
+}
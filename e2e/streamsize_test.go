@@ -0,0 +1,73 @@
+package e2e
+
+import (
+	"math"
+	"strconv"
+	"testing"
+	"yt-downloader-go/config"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// streamSizeTolerance is how far the estimate may be from the piped bytes, as a fraction
+const streamSizeTolerance = 0.02
+
+// The estimate of a copied stream-only job is close to what /stream pipes. The ffmpeg shim
+// pipes the sources as they are, so the overhead factors themselves aren't measured here.
+func TestEstimatedStreamSize(t *testing.T) {
+	mergeVideo("e2eSizeMp4v", config.MaxMergeDurationRemux+60)
+	mergeVideo("e2eSizeMkvv", config.MaxMergeDurationRemux+60)
+	audioVideo("e2eSizeOpus", config.MaxMergeDurationRemux+60, 200_000)
+
+	tests := []struct {
+		name    string
+		body    string
+		sources []string
+	}{
+		{
+			name:    "mp4",
+			body:    `{"url":"https://youtu.be/e2eSizeMp4v","output":{"type":"video","format":"mp4","quality":"720p"}}`,
+			sources: []string{"e2eSizeMp4v-136", "e2eSizeMp4v-140"},
+		},
+		{
+			name:    "mkv",
+			body:    `{"url":"https://youtu.be/e2eSizeMkvv","output":{"type":"video","format":"mkv","quality":"720p"}}`,
+			sources: []string{"e2eSizeMkvv-136", "e2eSizeMkvv-140"},
+		},
+		{
+			name:    "opus",
+			body:    `{"url":"https://youtu.be/e2eSizeOpus","output":{"type":"audio","format":"opus"}}`,
+			sources: []string{"e2eSizeOpus-251"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status := waitForJob(t, startJob(t, tt.body))
+			assertCompleted(t, status)
+			if status.EstimatedSize == 0 {
+				t.Fatal("no estimatedSize in the status")
+			}
+
+			resp, body := getWithHeaders(t, status.DownloadURL, nil)
+			if resp.StatusCode != fiber.StatusOK {
+				t.Fatalf("stream: %d %s", resp.StatusCode, body)
+			}
+			if header := resp.Header.Get("X-Estimated-Content-Length"); header != strconv.FormatInt(status.EstimatedSize, 10) {
+				t.Errorf("X-Estimated-Content-Length %s, want the status estimatedSize %d", header, status.EstimatedSize)
+			}
+
+			var sources int
+			for _, id := range tt.sources {
+				sources += len(origin.data(id))
+			}
+			if len(body) != sources {
+				t.Fatalf("streamed %d bytes, want the %d bytes of the sources", len(body), sources)
+			}
+			if off := math.Abs(float64(status.EstimatedSize)-float64(len(body))) / float64(len(body)); off > streamSizeTolerance {
+				t.Errorf("estimated %d bytes for %d piped (%.1f%% off), want within %.0f%%",
+					status.EstimatedSize, len(body), off*100, streamSizeTolerance*100)
+			}
+		})
+	}
+}
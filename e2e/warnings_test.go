@@ -0,0 +1,162 @@
+package e2e
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+	"yt-downloader-go/config"
+	"yt-downloader-go/models"
+)
+
+const (
+	vp9Mime = `video/webm; codecs="vp9"`
+	av1Mime = `video/mp4; codecs="av01.0.05M.08"`
+)
+
+// warningVideo is an extract result with vp9 1080p and h264 720p streams, and opus and aac
+// streams of the original audio track (en.4)
+func warningVideo(videoID string, duration float64) *models.ExtractResponse {
+	audio := func(itag int, mimeType string) models.Stream {
+		stream := origin.stream(fmt.Sprintf("%s-%d", videoID, itag), 20_000, itag, mimeType, 0)
+		stream.AudioTrackID, stream.IsOriginal = "en.4", true
+		return stream
+	}
+	data := &models.ExtractResponse{
+		Title:    "Song " + videoID,
+		Duration: duration,
+		VideoStreams: []models.Stream{
+			origin.stream(videoID+"-248", 50_000, 248, vp9Mime, 1080),
+			origin.stream(videoID+"-136", 50_000, 136, h264Mime, 720),
+		},
+		AudioStreams: []models.Stream{audio(251, opusMime), audio(140, aacMime)},
+	}
+	extractAPI.set(videoID, data)
+	return data
+}
+
+// expireSoon moves the expiry of every stream URL of data inside ExtractCacheURLMargin, so
+// each request extracts the video again instead of reusing the cached result
+func expireSoon(t *testing.T, data *models.ExtractResponse) {
+	t.Helper()
+	expire := strconv.FormatInt(time.Now().Add(config.ExtractCacheURLMargin/2).Unix(), 10)
+	for _, streams := range [][]models.Stream{data.VideoStreams, data.AudioStreams} {
+		for i := range streams {
+			streamURL, err := url.Parse(streams[i].URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			query := streamURL.Query()
+			query.Set("expire", expire)
+			streamURL.RawQuery = query.Encode()
+			streams[i].URL = streamURL.String()
+		}
+	}
+}
+
+// warningCodes counts the warnings of each code
+func warningCodes(warnings models.Warnings) map[string]int {
+	codes := map[string]int{}
+	for _, warning := range warnings {
+		codes[warning.Code]++
+	}
+	return codes
+}
+
+// assertOnlyWarning fails the test unless warnings holds want exactly once and nothing else
+// ("" for no warnings)
+func assertOnlyWarning(t *testing.T, where string, warnings models.Warnings, want string) {
+	t.Helper()
+	switch {
+	case want == "" && len(warnings) > 0:
+		t.Errorf("%s: warnings %+v, want none", where, warnings)
+	case want != "" && (warningCodes(warnings)[want] != 1 || len(warnings) != 1):
+		t.Errorf("%s: warnings %+v, want %s exactly once", where, warnings, want)
+	}
+}
+
+func TestDownloadWarnings(t *testing.T) {
+	warningVideo("warnVideo01", 213)
+	warningVideo("warnLong001", config.MaxMergeDurationTranscode+60)
+	vp9Only := warningVideo("warnVp9Only", 213)
+	vp9Only.VideoStreams = vp9Only.VideoStreams[:1]
+	extractAPI.set("warnVp9Only", vp9Only)
+
+	tests := []struct {
+		name string
+		body string
+		want string // Only warning code, "" for none
+	}{
+		{
+			name: "none",
+			body: `{"url":"https://youtu.be/warnVideo01","output":{"type":"audio","format":"mp3"},"audio":{"bitrate":"192k"}}`,
+		},
+		{
+			name: "bitrate clamped",
+			body: `{"url":"https://youtu.be/warnVideo01","output":{"type":"audio","format":"mp3"},"audio":{"bitrate":"512k"}}`,
+			want: models.WarnBitrateClamped,
+		},
+		{
+			name: "trim clamped",
+			body: `{"url":"https://youtu.be/warnVideo01","output":{"type":"audio","format":"mp3"},"trim":{"start":10,"end":500}}`,
+			want: models.WarnTrimClamped,
+		},
+		{
+			name: "lossy to lossless",
+			body: `{"url":"https://youtu.be/warnVideo01","output":{"type":"audio","format":"flac"}}`,
+			want: models.WarnLossyToLossless,
+		},
+		{
+			// Added on creation and again when the run finds the job stream-only
+			name: "stream only",
+			body: `{"url":"https://youtu.be/warnLong001","output":{"type":"audio","format":"mp3"}}`,
+			want: models.WarnStreamOnly,
+		},
+		{
+			name: "audio track fallback",
+			body: `{"url":"https://youtu.be/warnVideo01","output":{"type":"audio","format":"mp3"},"audio":{"trackId":"fr.3"}}`,
+			want: models.WarnAudioTrackFallback,
+		},
+		{
+			name: "quality downgraded",
+			body: `{"url":"https://youtu.be/warnVideo01","os":"windows","output":{"type":"video","format":"auto","quality":"1440p"}}`,
+			want: models.WarnQualityDowngraded,
+		},
+		{
+			name: "video transcoded",
+			body: `{"url":"https://youtu.be/warnVp9Only","os":"ios","allowTranscode":true,"output":{"type":"video","format":"mp4","quality":"1080p"}}`,
+			want: models.WarnVideoTranscoded,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			created := startJob(t, tt.body)
+			assertOnlyWarning(t, "download", created.Warnings, tt.want)
+			status := waitForJob(t, created)
+			assertCompleted(t, status)
+			assertOnlyWarning(t, "status", status.Warnings, tt.want)
+		})
+	}
+
+	t.Run("selection changed", func(t *testing.T) {
+		const body = `{"url":"https://youtu.be/warnChanged","os":"windows","output":{"type":"video","format":"auto","quality":"720p"}}`
+		expireSoon(t, warningVideo("warnChanged", 213))
+		first := startJob(t, body)
+		assertOnlyWarning(t, "first request", first.Warnings, "")
+		waitForJob(t, first)
+
+		// The selected h264 stream is no longer offered
+		changed := warningVideo("warnChanged", 213)
+		changed.VideoStreams[1] = origin.stream("warnChanged-398", 50_000, 398, av1Mime, 720)
+		expireSoon(t, changed)
+		extractAPI.set("warnChanged", changed)
+
+		second := startJob(t, body)
+		assertOnlyWarning(t, "second request", second.Warnings, models.WarnSelectionChanged)
+		status := waitForJob(t, second)
+		assertCompleted(t, status)
+		assertOnlyWarning(t, "second status", status.Warnings, models.WarnSelectionChanged)
+	})
+}
@@ -0,0 +1,500 @@
+package handlers
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"yt-downloader-go/config"
+	"yt-downloader-go/models"
+	"yt-downloader-go/services"
+	"yt-downloader-go/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// defaultOverrideTTL applies when a threshold override has no ttlSeconds
+const defaultOverrideTTL = time.Hour
+
+// AdminAuth only admits requests carrying one of the ADMIN_API_KEYS in X-API-Key
+func AdminAuth(c *fiber.Ctx) error {
+	if len(config.AdminAPIKeys) == 0 {
+		return utils.Forbidden(c, "Admin API is disabled")
+	}
+	if !slices.Contains(config.AdminAPIKeys, c.Get("X-API-Key")) {
+		return utils.Forbidden(c, "Invalid admin API key")
+	}
+	return c.Next()
+}
+
+// HandleGetLoad handles GET /api/admin/load
+// @Summary Processing load
+// @Description Backlog estimate used for admission control
+// @Tags admin
+// @Produce json
+// @Param X-API-Key header string true "Admin API key"
+// @Success 200 {object} models.LoadResponse
+// @Failure 403 {object} utils.ErrorResponse "Missing or invalid admin API key"
+// @Router /api/admin/load [get]
+func HandleGetLoad(c *fiber.Ctx) error {
+	return c.JSON(services.LoadEstimate())
+}
+
+// HandleSetLoad handles PUT /api/admin/load
+// @Summary Override admission threshold
+// @Description Temporarily replace the max estimated wait above which new jobs get 503
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param X-API-Key header string true "Admin API key"
+// @Param request body models.LoadOverrideRequest true "Threshold override"
+// @Success 200 {object} models.LoadResponse
+// @Failure 400 {object} utils.ErrorResponse "Validation error"
+// @Failure 403 {object} utils.ErrorResponse "Missing or invalid admin API key"
+// @Router /api/admin/load [put]
+func HandleSetLoad(c *fiber.Ctx) error {
+	var req models.LoadOverrideRequest
+	if err := parseJSONStrict(c, &req); err != nil {
+		return utils.BadRequest(c, utils.ErrInvalidRequest, "Invalid request body: "+err.Error())
+	}
+	if req.MaxWaitSeconds < 0 || req.TTLSeconds < 0 {
+		return utils.BadRequest(c, utils.ErrValidationError, "maxWaitSeconds and ttlSeconds must not be negative")
+	}
+
+	ttl := defaultOverrideTTL
+	if req.TTLSeconds > 0 {
+		ttl = time.Duration(req.TTLSeconds * float64(time.Second))
+	}
+	services.SetMaxWaitOverride(time.Duration(req.MaxWaitSeconds*float64(time.Second)), ttl)
+
+	return c.JSON(services.LoadEstimate())
+}
+
+// HandleGetLogLevels handles GET /api/admin/log-levels
+// @Summary Log levels
+// @Description Default log level and the level in effect for each module
+// @Tags admin
+// @Produce json
+// @Param X-API-Key header string true "Admin API key"
+// @Success 200 {object} models.LogLevelsResponse
+// @Failure 403 {object} utils.ErrorResponse "Missing or invalid admin API key"
+// @Router /api/admin/log-levels [get]
+func HandleGetLogLevels(c *fiber.Ctx) error {
+	return c.JSON(logLevelsResponse())
+}
+
+// HandleSetLogLevels handles PUT /api/admin/log-levels
+// @Summary Change log levels
+// @Description Change the default or per-module log levels until restart
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param X-API-Key header string true "Admin API key"
+// @Param request body models.LogLevelsRequest true "Levels to change"
+// @Success 200 {object} models.LogLevelsResponse
+// @Failure 400 {object} utils.ErrorResponse "Validation error"
+// @Failure 403 {object} utils.ErrorResponse "Missing or invalid admin API key"
+// @Router /api/admin/log-levels [put]
+func HandleSetLogLevels(c *fiber.Ctx) error {
+	var req models.LogLevelsRequest
+	if err := parseJSONStrict(c, &req); err != nil {
+		return utils.BadRequest(c, utils.ErrInvalidRequest, "Invalid request body: "+err.Error())
+	}
+	if err := utils.SetLogLevels(req.Level, req.Modules); err != nil {
+		return utils.BadRequest(c, utils.ErrValidationError, err.Error())
+	}
+
+	response := logLevelsResponse()
+	slog.Info("log levels changed", "level", response.Level, "modules", response.Modules)
+	return c.JSON(response)
+}
+
+// logLevelsResponse returns the log levels in effect
+func logLevelsResponse() models.LogLevelsResponse {
+	level, modules := utils.LogLevels()
+	return models.LogLevelsResponse{Level: level, Modules: modules}
+}
+
+// HandleListJobs handles GET /api/admin/jobs
+// @Summary List jobs
+// @Description Jobs on disk, newest first, with the client that created them (abuse investigation)
+// @Tags admin
+// @Produce json
+// @Param X-API-Key header string true "Admin API key"
+// @Param videoId query string false "Only jobs for this video ID"
+// @Param ip query string false "Only jobs created from this client IP"
+// @Param stuck query boolean false "Only jobs flagged possiblyStuck"
+// @Param limit query integer false "Max jobs returned (default 100, max 1000)"
+// @Success 200 {object} models.AdminJobsResponse
+// @Failure 403 {object} utils.ErrorResponse "Missing or invalid admin API key"
+// @Failure 500 {object} utils.ErrorResponse "Server error"
+// @Router /api/admin/jobs [get]
+func HandleListJobs(c *fiber.Ctx) error {
+	metas, err := utils.ListJobMetas()
+	if err != nil {
+		return utils.InternalError(c, "Failed to list jobs")
+	}
+
+	videoID := c.Query("videoId")
+	ip := c.Query("ip")
+	stuck := c.QueryBool("stuck")
+	metas = slices.DeleteFunc(metas, func(meta *models.Meta) bool {
+		if videoID != "" && meta.VideoID != videoID {
+			return true
+		}
+		if stuck && !isPossiblyStuck(meta) {
+			return true
+		}
+		return ip != "" && (meta.Client == nil || meta.Client.IP != ip)
+	})
+	slices.SortFunc(metas, func(a, b *models.Meta) int {
+		return cmp.Compare(b.CreatedAt, a.CreatedAt)
+	})
+
+	limit := c.QueryInt("limit", config.AdminJobsDefaultLimit)
+	if limit <= 0 || limit > config.AdminJobsMaxLimit {
+		limit = config.AdminJobsMaxLimit
+	}
+
+	response := models.AdminJobsResponse{Jobs: []models.AdminJob{}, Total: len(metas)}
+	for _, meta := range metas[:min(limit, len(metas))] {
+		response.Jobs = append(response.Jobs, models.AdminJob{
+			ID:         meta.ID,
+			Status:     meta.Status,
+			CreatedAt:  meta.CreatedAt,
+			ExpiresAt:  utils.JobExpiresAt(meta).UnixMilli(),
+			DeletedAt:  meta.DeletedAt,
+			VideoID:    meta.VideoID,
+			Title:      meta.Title,
+			OutputType: meta.OutputType,
+			Format:     meta.Format,
+			Client:     meta.Client,
+			Usage:      meta.Usage,
+			UsageKey:   meta.UsageKey,
+
+			PossiblyStuck: isPossiblyStuck(meta),
+		})
+	}
+	return c.JSON(response)
+}
+
+// HandleGetJob handles GET /api/admin/jobs/:id
+// @Summary Get job metadata
+// @Description Full stored metadata of a job, including its creating client
+// @Tags admin
+// @Produce json
+// @Param X-API-Key header string true "Admin API key"
+// @Param id path string true "Job ID"
+// @Success 200 {object} models.Meta
+// @Failure 400 {object} utils.ErrorResponse "Invalid job ID"
+// @Failure 403 {object} utils.ErrorResponse "Missing or invalid admin API key"
+// @Failure 404 {object} utils.ErrorResponse "Job not found"
+// @Failure 500 {object} utils.ErrorResponse "Server error"
+// @Router /api/admin/jobs/{id} [get]
+func HandleGetJob(c *fiber.Ctx) error {
+	jobID := c.Params("id")
+
+	if !utils.ValidateJobID(jobID) {
+		return utils.BadRequest(c, utils.ErrInvalidJobID, "Invalid job ID format")
+	}
+	meta, err := readJob(c, jobID)
+	if meta == nil {
+		return err
+	}
+	return c.JSON(meta)
+}
+
+// isPossiblyStuck reports whether an unfinished job outlived the job timeout or has no live worker
+func isPossiblyStuck(meta *models.Meta) bool {
+	if (meta.Status != models.StatusPending && meta.Status != models.StatusProcessing) || utils.IsDeleted(meta) {
+		return false
+	}
+	return time.Since(time.UnixMilli(meta.CreatedAt)) > config.JobTimeout || !utils.IsJobRunning(meta.ID)
+}
+
+// HandleGetUsage handles GET /api/admin/usage
+// @Summary Usage per API key
+// @Description Origin and served bytes of the jobs on disk, summed per API key fingerprint (billing).
+// @Description Jobs leave this view when they are cleaned up; the job archive keeps their usageKey.
+// @Tags admin
+// @Produce json
+// @Param X-API-Key header string true "Admin API key"
+// @Param since query integer false "Only jobs created at or after this time (Unix ms)"
+// @Success 200 {object} models.AdminUsageResponse
+// @Failure 403 {object} utils.ErrorResponse "Missing or invalid admin API key"
+// @Failure 500 {object} utils.ErrorResponse "Server error"
+// @Router /api/admin/usage [get]
+func HandleGetUsage(c *fiber.Ctx) error {
+	metas, err := utils.ListJobMetas()
+	if err != nil {
+		return utils.InternalError(c, "Failed to list jobs")
+	}
+
+	since := int64(c.QueryInt("since"))
+	metas = slices.DeleteFunc(metas, func(meta *models.Meta) bool {
+		return meta.CreatedAt < since
+	})
+	return c.JSON(models.AdminUsageResponse{Keys: utils.AggregateUsage(metas), Since: since})
+}
+
+// HandleRequeueJob handles POST /api/admin/jobs/:id/requeue
+// @Summary Requeue a stuck job
+// @Description Reset an unfinished or failed job to pending and run it again. Stream URLs are re-extracted; cached and partially downloaded sources are reused.
+// @Tags admin
+// @Produce json
+// @Param X-API-Key header string true "Admin API key"
+// @Param id path string true "Job ID"
+// @Success 200 {object} models.Meta
+// @Failure 400 {object} utils.ErrorResponse "Invalid job ID"
+// @Failure 403 {object} utils.ErrorResponse "Missing or invalid admin API key"
+// @Failure 404 {object} utils.ErrorResponse "Job or selected streams not found"
+// @Failure 409 {object} utils.ErrorResponse "Job is running or completed"
+// @Failure 410 {object} utils.JobDeletedResponse "Job deleted"
+// @Failure 500 {object} utils.ErrorResponse "Server error"
+// @Failure 503 {object} utils.ErrorResponse "Extract API rate limited"
+// @Router /api/admin/jobs/{id}/requeue [post]
+func HandleRequeueJob(c *fiber.Ctx) error {
+	jobID := c.Params("id")
+
+	if !utils.ValidateJobID(jobID) {
+		return utils.BadRequest(c, utils.ErrInvalidJobID, "Invalid job ID format")
+	}
+	meta, err := readJob(c, jobID)
+	if meta == nil {
+		return err
+	}
+	if utils.IsDeleted(meta) {
+		return utils.JobGone(c, "Job has been deleted", meta.DeletedAt)
+	}
+	if meta.Status == models.StatusCompleted {
+		return utils.Error(c, fiber.StatusConflict, utils.ErrJobFinished, "Job has already completed")
+	}
+
+	// A live worker keeps its lock; one left by a crashed or timed-out run is taken over
+	utils.ClearStaleRunLock(jobID)
+	if err := utils.AcquireRunLock(jobID); err != nil {
+		if errors.Is(err, utils.ErrJobRunning) {
+			return utils.Error(c, fiber.StatusConflict, utils.ErrJobBusy, "Job is being processed")
+		}
+		return utils.InternalError(c, "Failed to lock job")
+	}
+
+	videoSelection, audioStream, err := refreshJobStreams(c, meta)
+	if err != nil {
+		utils.ReleaseRunLock(jobID)
+		return err
+	}
+
+	if err := utils.UpdateMetaRequeued(jobID, meta.Files, meta.Selection); err != nil {
+		utils.ReleaseRunLock(jobID)
+		return utils.InternalError(c, "Failed to save job metadata")
+	}
+	meta, err = utils.ReadMeta(jobID)
+	if err != nil {
+		utils.ReleaseRunLock(jobID)
+		return utils.InternalError(c, "Failed to read job metadata")
+	}
+
+	jobLog.Info("requeued by admin", "job", jobID)
+	go runJob(jobID, meta, videoSelection, audioStream, meta.Format, meta.Bitrate)
+
+	return c.JSON(meta)
+}
+
+// refreshJobStreams re-extracts the video and finds the job's selected streams: by the itags
+// recorded in meta.Selection, then by codec and height (services.MatchStream). A stream that
+// is other content than the job's source gets its own source cache entry in meta.Files and
+// meta.Selection (not stored; UpdateMetaRequeued does). Jobs without a recorded selection
+// match by source cache name.
+// Returns the error response already written on failure.
+func refreshJobStreams(c *fiber.Ctx, meta *models.Meta) (*models.VideoSelectionResult, *models.Stream, error) {
+	ctx, cancel := context.WithTimeout(c.Context(), config.DownloadSyncTimeout)
+	defer cancel()
+
+	extractData, err := services.Extract(services.WithExtractPriority(ctx, meta.Priority), meta.VideoID)
+	var rateLimited *services.RateLimitError
+	if errors.As(err, &rateLimited) {
+		c.Set(fiber.HeaderRetryAfter, strconv.Itoa(rateLimited.RetryAfterSeconds()))
+		return nil, nil, utils.Error(c, fiber.StatusServiceUnavailable, utils.ErrExtractLimited, "Video metadata service is rate limited, retry later")
+	}
+	if err != nil {
+		return nil, nil, utils.InternalError(c, "Failed to fetch video metadata")
+	}
+
+	selection := meta.Selection
+	if selection == nil {
+		selection = &models.StreamSelection{}
+	}
+
+	// Muted video jobs have no audio stream
+	var audioStream *models.Stream
+	if meta.Files.Audio != nil {
+		audioStream = matchJobStream(extractData.AudioStreams, meta.VideoID, meta.Files.Audio, selection.Audio)
+		if audioStream == nil {
+			return nil, nil, utils.NotFound(c, utils.ErrAudioNotFound, "Selected audio stream is no longer available")
+		}
+		if file := refreshedSource(meta.VideoID, "audio", meta.Files.Audio, audioStream); file != nil {
+			jobLog.Info("audio stream replaced", "job", meta.ID, "itag", selectedItag(selection.Audio), "newItag", audioStream.Itag)
+			meta.Files.Audio = file
+			selection.Audio = services.DescribeStream(audioStream)
+		}
+	}
+
+	var videoSelection *models.VideoSelectionResult
+	if meta.Files.Video != nil {
+		videoStream := matchJobStream(extractData.VideoStreams, meta.VideoID, meta.Files.Video, selection.Video)
+		if videoStream == nil {
+			return nil, nil, utils.NotFound(c, utils.ErrVideoNotFound, "Selected video stream is no longer available")
+		}
+		if file := refreshedSource(meta.VideoID, "video", meta.Files.Video, videoStream); file != nil {
+			jobLog.Info("video stream replaced", "job", meta.ID, "itag", selectedItag(selection.Video), "newItag", videoStream.Itag)
+			meta.Files.Video = file
+			selection.Video = services.DescribeStream(videoStream)
+		}
+		videoSelection = &models.VideoSelectionResult{Stream: videoStream, SelectedQuality: meta.Quality}
+	}
+
+	if meta.Selection != nil {
+		services.LogSelection(meta.ID, meta.Selection)
+	}
+	return videoSelection, audioStream, nil
+}
+
+// matchJobStream finds a job's stream by its recorded selection, else by source cache name
+func matchJobStream(streams []models.Stream, videoID string, file *models.FileInfo, selected *models.SelectedStream) *models.Stream {
+	if selected != nil {
+		return services.MatchStream(streams, selected)
+	}
+	return findSourceStream(streams, videoID, file)
+}
+
+// refreshedSource returns the file a refreshed stream is downloaded to when it is other
+// content than the job's source (a new source cache entry); nil when it is the same
+func refreshedSource(videoID string, kind string, file *models.FileInfo, stream *models.Stream) *models.FileInfo {
+	source := services.SourceCacheName(videoID, stream)
+	if source == file.Source {
+		return nil
+	}
+	return &models.FileInfo{
+		Name:   kind + "." + services.GetExtension(stream),
+		Size:   stream.ContentLength,
+		Source: source,
+	}
+}
+
+// selectedItag returns the itag of a recorded stream (0 when unknown)
+func selectedItag(selected *models.SelectedStream) int {
+	if selected == nil {
+		return 0
+	}
+	return selected.Itag
+}
+
+// findSourceStream returns the stream whose source cache name matches file
+func findSourceStream(streams []models.Stream, videoID string, file *models.FileInfo) *models.Stream {
+	if file == nil {
+		return nil
+	}
+	for i := range streams {
+		if services.SourceCacheName(videoID, &streams[i]) == file.Source {
+			return &streams[i]
+		}
+	}
+	return nil
+}
+
+// HandleFailJob handles POST /api/admin/jobs/:id/fail
+// @Summary Force-fail a stuck job
+// @Description Set an unfinished job to error with an operator-supplied reason so clients stop polling
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param X-API-Key header string true "Admin API key"
+// @Param id path string true "Job ID"
+// @Param request body models.FailJobRequest true "Failure reason"
+// @Success 200 {object} models.Meta
+// @Failure 400 {object} utils.ErrorResponse "Validation error"
+// @Failure 403 {object} utils.ErrorResponse "Missing or invalid admin API key"
+// @Failure 404 {object} utils.ErrorResponse "Job not found"
+// @Failure 409 {object} utils.ErrorResponse "Job already finished"
+// @Failure 410 {object} utils.JobDeletedResponse "Job deleted"
+// @Failure 500 {object} utils.ErrorResponse "Server error"
+// @Router /api/admin/jobs/{id}/fail [post]
+func HandleFailJob(c *fiber.Ctx) error {
+	jobID := c.Params("id")
+
+	if !utils.ValidateJobID(jobID) {
+		return utils.BadRequest(c, utils.ErrInvalidJobID, "Invalid job ID format")
+	}
+
+	var req models.FailJobRequest
+	if err := parseJSONStrict(c, &req); err != nil {
+		return utils.BadRequest(c, utils.ErrInvalidRequest, "Invalid request body: "+err.Error())
+	}
+	req.Reason = strings.TrimSpace(req.Reason)
+	if req.Reason == "" || len(req.Reason) > config.MaxFailReasonLength {
+		return utils.BadRequest(c, utils.ErrValidationError, fmt.Sprintf("reason: required, at most %d characters", config.MaxFailReasonLength))
+	}
+
+	meta, err := readJob(c, jobID)
+	if meta == nil {
+		return err
+	}
+	if utils.IsDeleted(meta) {
+		return utils.JobGone(c, "Job has been deleted", meta.DeletedAt)
+	}
+	if meta.Status != models.StatusPending && meta.Status != models.StatusProcessing {
+		return utils.Error(c, fiber.StatusConflict, utils.ErrJobFinished, "Job has already finished")
+	}
+
+	// A live worker is not stopped; its run lock stays until it exits
+	phase := models.PhaseDownload
+	if meta.Status == models.StatusProcessing {
+		phase = models.PhaseProcessing
+	}
+	jobErr := &models.JobError{Code: models.JobErrFailedByAdmin, Message: req.Reason, Phase: phase}
+	if err := utils.UpdateMetaError(jobID, jobErr); err != nil {
+		return utils.InternalError(c, "Failed to save job metadata")
+	}
+
+	jobLog.Info("failed by admin", "job", jobID, "reason", req.Reason)
+	meta, err = utils.ReadMeta(jobID)
+	if err != nil {
+		return utils.InternalError(c, "Failed to read job metadata")
+	}
+	return c.JSON(meta)
+}
+
+// HandleGetArchive handles GET /api/admin/archive/:date
+// @Summary Download job archive
+// @Description One day's archived job records (JSON lines, UTC day), written when ARCHIVE_JOBS is enabled
+// @Tags admin
+// @Produce application/x-ndjson
+// @Param X-API-Key header string true "Admin API key"
+// @Param date path string true "Day (YYYY-MM-DD, UTC)"
+// @Success 200 {file} binary "JSON lines"
+// @Failure 400 {object} utils.ErrorResponse "Invalid date"
+// @Failure 403 {object} utils.ErrorResponse "Missing or invalid admin API key"
+// @Failure 404 {object} utils.ErrorResponse "No archive for that day"
+// @Router /api/admin/archive/{date} [get]
+func HandleGetArchive(c *fiber.Ctx) error {
+	date := c.Params("date")
+	if _, err := time.Parse(utils.ArchiveDateLayout, date); err != nil {
+		return utils.BadRequest(c, utils.ErrValidationError, "date must be YYYY-MM-DD")
+	}
+
+	path := utils.ArchivePath(date)
+	if _, err := os.Stat(path); err != nil {
+		return utils.NotFound(c, utils.ErrFileNotFound, "No archive for that day")
+	}
+
+	c.Set("Content-Type", "application/x-ndjson")
+	c.Set("Content-Disposition", utils.ContentDisposition("jobs-"+date+".jsonl", false))
+	return c.SendFile(path)
+}
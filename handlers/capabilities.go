@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"yt-downloader-go/config"
+	"yt-downloader-go/models"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// HandleCapabilities handles GET /api/capabilities
+// @Summary Server capabilities
+// @Description Supported formats, qualities, OS profiles, limits and feature flags
+// @Tags capabilities
+// @Produce json
+// @Success 200 {object} models.CapabilitiesResponse
+// @Router /api/capabilities [get]
+func HandleCapabilities(c *fiber.Ctx) error {
+	profiles := make(map[string]models.OSProfile, len(config.DeviceProfiles))
+	for osType, profile := range config.DeviceProfiles {
+		profiles[osType] = models.OSProfile{
+			MaxQuality:  profile.MaxQuality,
+			VideoCodecs: profile.VideoCodecs,
+			AudioCodecs: profile.AudioCodecs,
+		}
+	}
+
+	return c.JSON(models.CapabilitiesResponse{
+		APIVersion:                config.APIVersion,
+		VideoFormats:              append([]string{config.FormatAuto}, config.VideoFormats...),
+		AudioFormats:              append([]string{config.FormatAuto}, config.AudioFormats...),
+		Qualities:                 config.Qualities,
+		Bitrates:                  config.Bitrates,
+		OSProfiles:                profiles,
+		MaxTrimDuration:           config.MaxTrimDuration.Seconds(),
+		MaxMergeDurationTranscode: config.MaxMergeDurationTranscode,
+		MaxMergeDurationRemux:     config.MaxMergeDurationRemux,
+		HardwareAcceleration:      false, // All encoding runs on CPU
+		Features: map[string]bool{
+			"clientBinding":   config.SignedURLBindClient,
+			"streamRateLimit": config.StreamRateLimit > 0,
+			"hls":             true,
+			"idempotencyKeys": true,
+			"chapters":        true,
+			"silenceTrim":     true,
+			"fades":           true,
+			"vbr":             true,
+			"softDelete":      true,
+		},
+	})
+}
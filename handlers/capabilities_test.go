@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"yt-downloader-go/config"
+	"yt-downloader-go/models"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// The response is built from config, so it changes with it
+func TestCapabilitiesMatchConfig(t *testing.T) {
+	app := fiber.New()
+	app.Get("/api/capabilities", HandleCapabilities)
+	resp, err := app.Test(httptest.NewRequest("GET", "/api/capabilities", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var got models.CapabilitiesResponse
+	if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+
+	profiles := map[string]models.OSProfile{}
+	for osType, profile := range config.DeviceProfiles {
+		profiles[osType] = models.OSProfile{MaxQuality: profile.MaxQuality, VideoCodecs: profile.VideoCodecs, AudioCodecs: profile.AudioCodecs}
+	}
+	want := map[string]struct{ got, want any }{
+		"apiVersion":                {got.APIVersion, config.APIVersion},
+		"videoFormats":              {got.VideoFormats, append([]string{config.FormatAuto}, config.VideoFormats...)},
+		"audioFormats":              {got.AudioFormats, append([]string{config.FormatAuto}, config.AudioFormats...)},
+		"qualities":                 {got.Qualities, config.Qualities},
+		"bitrates":                  {got.Bitrates, config.Bitrates},
+		"osProfiles":                {got.OSProfiles, profiles},
+		"maxTrimDuration":           {got.MaxTrimDuration, config.MaxTrimDuration.Seconds()},
+		"maxMergeDurationTranscode": {got.MaxMergeDurationTranscode, float64(config.MaxMergeDurationTranscode)},
+		"maxMergeDurationRemux":     {got.MaxMergeDurationRemux, float64(config.MaxMergeDurationRemux)},
+		"features.clientBinding":    {got.Features["clientBinding"], config.SignedURLBindClient},
+		"features.streamRateLimit":  {got.Features["streamRateLimit"], config.StreamRateLimit > 0},
+	}
+	for field, values := range want {
+		if !reflect.DeepEqual(values.got, values.want) {
+			t.Errorf("%s = %v, want %v", field, values.got, values.want)
+		}
+	}
+}
@@ -0,0 +1,239 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+	"yt-downloader-go/config"
+	"yt-downloader-go/models"
+	"yt-downloader-go/services"
+	"yt-downloader-go/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// convertWorkDir (in the job's work directory) holds symlinked sources while an additional output is rendered
+const convertWorkDir = "convert.tmp"
+
+// HandleConvertJob handles POST /api/jobs/:id/convert
+// @Summary Render an additional output
+// @Description Re-run only the FFmpeg phase against a completed job's retained sources (keepSources) to produce another format, bitrate or trim. Track it in the status response's outputs.
+// @Tags jobs
+// @Accept json
+// @Produce json
+// @Param id path string true "Job ID"
+// @Param request body models.ConvertRequest true "Output settings"
+// @Success 200 {object} models.ConvertResponse
+// @Failure 400 {object} utils.ErrorResponse "Invalid request or job not completed"
+// @Failure 404 {object} utils.ErrorResponse "Job not found"
+// @Failure 409 {object} utils.ErrorResponse "Sources not kept, or job busy"
+// @Failure 410 {object} utils.JobDeletedResponse "Job deleted"
+// @Failure 500 {object} utils.ErrorResponse "Server error"
+// @Router /api/jobs/{id}/convert [post]
+func HandleConvertJob(c *fiber.Ctx) error {
+	jobID := c.Params("id")
+
+	// Validate job ID
+	if !utils.ValidateJobID(jobID) {
+		return utils.BadRequest(c, utils.ErrInvalidJobID, "Invalid job ID format")
+	}
+
+	var convert models.ConvertRequest
+	if err := parseJSONStrict(c, &convert); err != nil {
+		return utils.BadRequest(c, utils.ErrInvalidRequest, "Invalid request body: "+err.Error())
+	}
+
+	meta, err := readJob(c, jobID)
+	if meta == nil {
+		return err
+	}
+
+	if utils.IsDeleted(meta) {
+		return utils.JobGone(c, "Job has been deleted", meta.DeletedAt)
+	}
+	if meta.Status != models.StatusCompleted {
+		return utils.BadRequest(c, utils.ErrJobNotReady, "Job is not completed yet")
+	}
+	if !meta.KeepSources || !sourcesExist(jobID, meta) {
+		return utils.Error(c, fiber.StatusConflict, utils.ErrSourcesNotKept, "Job sources were not kept; create the job with keepSources")
+	}
+
+	// Same rules as a new download (normalizes aliases, audioOnly and bitrate)
+	if convert.Output.Format == config.FormatAuto {
+		return utils.BadRequest(c, utils.ErrValidationError, "output.format: auto is not supported for conversions")
+	}
+	req := models.DownloadRequest{URL: meta.VideoID, Output: convert.Output, Audio: convert.Audio, Trim: convert.Trim}
+	if err := utils.ValidateDownloadRequest(&req, nil); err != nil {
+		return utils.BadRequest(c, utils.ErrValidationError, err.Error())
+	}
+	if req.Output.Type == "video" && meta.Files.Video == nil {
+		return utils.BadRequest(c, utils.ErrValidationError, "output.type: job has no video source")
+	}
+	if req.Output.Type == "audio" && meta.Files.Audio == nil {
+		return utils.BadRequest(c, utils.ErrValidationError, "output.type: job has no audio source (audio.mute)")
+	}
+	// Outputs of a job with audio keep it; a muted job's outputs are muted anyway
+	if req.Audio.Mute {
+		return utils.BadRequest(c, utils.ErrValidationError, "audio.mute: not supported for conversions")
+	}
+	// Channels follow the job's audio.channels
+	if req.Audio.Channels != "" {
+		return utils.BadRequest(c, utils.ErrValidationError, "audio.channels: not supported for conversions")
+	}
+	if err := services.CheckAudioCodec(req.Audio.Codec); err != nil {
+		return utils.BadRequest(c, utils.ErrValidationError, "audio.codec: "+err.Error())
+	}
+
+	// Fades need re-encoding, so they always use accurate trim
+	if req.Trim != nil && req.Trim.Fade != nil {
+		req.Trim.Accurate = true
+	}
+
+	// One FFmpeg run per job directory at a time
+	if err := utils.AcquireRunLock(jobID); err != nil {
+		if errors.Is(err, utils.ErrJobRunning) {
+			return utils.Error(c, fiber.StatusConflict, utils.ErrJobBusy, "Job is being processed, retry later")
+		}
+		return utils.InternalError(c, "Failed to lock job")
+	}
+
+	output := models.ExtraOutput{
+		OutputType: req.Output.Type,
+		Format:     req.Output.Format,
+		Bitrate:    resolveBitrate(req.Audio, req.Output.Format),
+		AudioCodec: req.Audio.Codec,
+		Trim:       req.Trim,
+		CreatedAt:  time.Now().UnixMilli(),
+	}
+	index, name, err := utils.AddMetaExtraOutput(jobID, output)
+	if err != nil {
+		utils.ReleaseRunLock(jobID)
+		return utils.InternalError(c, "Failed to save job metadata")
+	}
+
+	output.Name = name
+	go processConvert(jobID, index, extraOutputMeta(meta, &output))
+
+	return c.JSON(models.ConvertResponse{
+		Name:      name,
+		StatusURL: utils.GenerateStatusURL(jobID),
+	})
+}
+
+// sourcesExist reports whether the job's downloaded sources are still on disk
+func sourcesExist(jobID string, meta *models.Meta) bool {
+	for _, file := range []*models.FileInfo{meta.Files.Video, meta.Files.Audio} {
+		if file == nil {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(utils.GetJobDir(jobID), file.Name)); err != nil {
+			return false
+		}
+	}
+	return meta.Files.Audio != nil || meta.Files.Video != nil
+}
+
+// extraOutputMeta returns the job settings the FFmpeg phase reads for an additional output
+func extraOutputMeta(meta *models.Meta, output *models.ExtraOutput) *models.Meta {
+	outputMeta := *meta
+	outputMeta.OutputType = output.OutputType
+	outputMeta.Format = output.Format
+	outputMeta.Bitrate = output.Bitrate
+	outputMeta.AudioCodec = output.AudioCodec
+	outputMeta.Trim = output.Trim
+	outputMeta.Output = output.Name
+	outputMeta.StaticVideo = false
+	// Copy or re-encode may differ from the primary output
+	if meta.Rotation != nil {
+		outputMeta.Rotation = services.DecideRotation(&outputMeta, meta.Rotation.Degrees)
+	}
+	if meta.AudioChannels != nil {
+		outputMeta.AudioChannels = services.DecideChannels(&outputMeta, meta.AudioChannels.SourceChannels, meta.AudioChannels.SourceLayout)
+	}
+	if output.OutputType != "audio" || !services.SupportsChapters(output.Format) {
+		outputMeta.Chapters = nil
+	}
+	return &outputMeta
+}
+
+// processConvert renders an additional output requested after completion
+// The caller holds the job's run lock.
+func processConvert(jobID string, index int, meta *models.Meta) {
+	defer utils.ReleaseRunLock(jobID)
+	// The job finished: nothing else uses its work directory
+	defer utils.RemoveWorkDir(jobID)
+
+	defer func() {
+		if r := recover(); r != nil {
+			utils.PanicError("convert", r, "job", jobID)
+			utils.UpdateMetaExtraOutput(jobID, index, models.StatusError, "Internal error")
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), config.JobTimeout)
+	defer cancel()
+	ctx = services.WithFFmpegJob(ctx, jobID)
+
+	release, err := services.AcquireFFmpegSlot(ctx, jobID, meta.Priority)
+	if err != nil {
+		utils.UpdateMetaExtraOutput(jobID, index, models.StatusError, "Processing failed")
+		return
+	}
+	defer release()
+
+	renderExtraOutput(ctx, jobID, index, meta, utils.GetJobDir(jobID))
+}
+
+// renderExtraOutput renders an additional output (meta from extraOutputMeta) in a work
+// directory linking the sources in sourceDir, then moves it into the job directory as meta.Output
+// The caller holds the job's run lock and an FFmpeg slot.
+func renderExtraOutput(ctx context.Context, jobID string, index int, meta *models.Meta, sourceDir string) {
+	utils.UpdateMetaExtraOutput(jobID, index, models.StatusProcessing, "")
+
+	jobDir := utils.GetJobDir(jobID)
+	workDir := filepath.Join(utils.GetWorkDir(jobID), convertWorkDir)
+	os.RemoveAll(workDir)
+	defer os.RemoveAll(workDir)
+
+	if err := linkSources(workDir, sourceDir, meta); err != nil {
+		jobLog.Warn("convert: sources unavailable", "job", jobID, "err", err)
+		utils.UpdateMetaExtraOutput(jobID, index, models.StatusError, "Sources unavailable")
+		return
+	}
+
+	outputFile, jobErr := renderOutput(ctx, workDir, meta, meta.Format, meta.Bitrate)
+	if jobErr != nil {
+		utils.UpdateMetaExtraOutput(jobID, index, models.StatusError, jobErr.Message)
+		return
+	}
+
+	if err := utils.MoveFile(filepath.Join(workDir, outputFile), filepath.Join(jobDir, meta.Output)); err != nil {
+		utils.UpdateMetaExtraOutput(jobID, index, models.StatusError, "Failed to store output")
+		return
+	}
+
+	utils.UpdateMetaExtraOutput(jobID, index, models.StatusCompleted, "")
+}
+
+// linkSources creates workDir with symlinks to the job's sources in sourceDir (the FFmpeg phase writes next to its inputs)
+func linkSources(workDir string, sourceDir string, meta *models.Meta) error {
+	if err := os.MkdirAll(workDir, 0755); err != nil {
+		return err
+	}
+	sourceDir, err := filepath.Abs(sourceDir)
+	if err != nil {
+		return err
+	}
+	for _, file := range []*models.FileInfo{meta.Files.Video, meta.Files.Audio} {
+		if file == nil {
+			continue
+		}
+		if err := os.Symlink(filepath.Join(sourceDir, file.Name), filepath.Join(workDir, file.Name)); err != nil {
+			return fmt.Errorf("failed to link %s: %w", file.Name, err)
+		}
+	}
+	return nil
+}
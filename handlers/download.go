@@ -2,7 +2,15 @@ package handlers
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"math"
+	"os"
 	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
 	"time"
 	"yt-downloader-go/config"
 	"yt-downloader-go/models"
@@ -13,17 +21,47 @@ import (
 	"github.com/jaevor/go-nanoid"
 )
 
-var generateID func() string
+var nanoidGenerate func() string
+
+// jobLog logs job runs, requeues and conversions (module jobs)
+var jobLog = utils.Logger(utils.LogJobs)
 
 func init() {
 	// Initialize nanoid generator
 	var err error
-	generateID, err = nanoid.Standard(config.JobIDLength)
+	nanoidGenerate, err = nanoid.Standard(config.JobIDLength)
 	if err != nil {
 		panic(err)
 	}
 }
 
+// generateID returns a new job or group ID. It never starts with "-" or "_", so it is
+// safe as a command-line argument (stored IDs that do keep validating).
+func generateID() string {
+	for {
+		if id := nanoidGenerate(); id[0] != '-' && id[0] != '_' {
+			return id
+		}
+	}
+}
+
+// maxIDAttempts bounds the IDs tried when creating a job collides with an existing one
+const maxIDAttempts = 3
+
+// createJob stores meta under a new job ID and returns the ID. An ID whose directory
+// already exists (e.g. differing only in case on a case-insensitive filesystem) is
+// replaced by a new one.
+func createJob(meta *models.Meta) (string, error) {
+	var err error
+	for attempt := 0; attempt < maxIDAttempts; attempt++ {
+		meta.ID = generateID()
+		if err = utils.CreateJob(meta.ID, meta); !errors.Is(err, utils.ErrJobExists) {
+			break
+		}
+	}
+	return meta.ID, err
+}
+
 // HandleDownload handles POST /api/download
 // @Summary Create download job
 // @Description Create a new download job for a YouTube video or audio
@@ -31,121 +69,448 @@ func init() {
 // @Accept json
 // @Produce json
 // @Param request body models.DownloadRequest true "Download request"
-// @Success 200 {object} models.DownloadResponse
+// @Param X-API-Key header string false "API key (required for priority=high)"
+// @Param Idempotency-Key header string false "Retry-safe key; repeats return the original response"
+// @Param legacy query boolean false "Respond 200 instead of 202 (deprecated)"
+// @Success 202 {object} models.DownloadResponse "Job accepted; Location is the signed status URL"
+// @Success 200 {object} models.DownloadResponse "With legacy=true (deprecated)"
 // @Failure 400 {object} utils.ErrorResponse "Validation error"
+// @Failure 403 {object} utils.ErrorResponse "High priority without an authorized API key"
 // @Failure 404 {object} utils.ErrorResponse "No stream found"
+// @Failure 409 {object} utils.ErrorResponse "Idempotency key reused with a different body"
+// @Failure 413 {object} utils.ErrorResponse "Request body too large"
+// @Failure 422 {object} utils.ErrorResponse "Protected content, lossy source with audio.strictLossless, or over the size limits (TOO_LARGE)"
 // @Failure 500 {object} utils.ErrorResponse "Server error"
+// @Failure 503 {object} utils.OverloadResponse "Overloaded; retry after estimatedWaitSeconds"
+// @Failure 504 {object} utils.ErrorResponse "Timed out before the job was created"
+// @Failure 507 {object} utils.ErrorResponse "Storage full; retry later"
 // @Router /api/download [post]
 func HandleDownload(c *fiber.Ctx) error {
 	var req models.DownloadRequest
-	if err := c.BodyParser(&req); err != nil {
-		return utils.BadRequest(c, utils.ErrInvalidRequest, "Invalid request body")
+	if err := parseJSONStrict(c, &req); err != nil {
+		return utils.BadRequest(c, utils.ErrInvalidRequest, "Invalid request body: "+err.Error())
+	}
+
+	// Idempotency: a retried request with the same key gets the original response
+	idempotencyKey := c.Get("Idempotency-Key", req.IdempotencyKey)
+	var bodyHash string
+	if idempotencyKey != "" {
+		if len(idempotencyKey) > config.MaxIdempotencyKeyLength {
+			return utils.BadRequest(c, utils.ErrValidationError, fmt.Sprintf("idempotencyKey: must be at most %d characters", config.MaxIdempotencyKeyLength))
+		}
+
+		// Header and body field are interchangeable, so the key is not part of the hash
+		req.IdempotencyKey = ""
+		bodyHash = utils.HashRequestBody(&req)
+
+		unlock := utils.LockIdempotencyKey(idempotencyKey)
+		defer unlock()
+
+		if record := utils.ReadIdempotencyRecord(idempotencyKey); record != nil {
+			if record.BodyHash != bodyHash {
+				return utils.Error(c, fiber.StatusConflict, utils.ErrIdempotencyKey, "Idempotency key was used with a different request body")
+			}
+			response := record.Response
+			response.StatusURL = utils.GenerateStatusURL(record.JobID)
+			response.Replayed = true
+			response.Warnings = append(models.Warnings{}, response.Warnings...)
+			return sendJobCreated(c, response)
+		}
 	}
 
-	// Validate request
-	if err := utils.ValidateDownloadRequest(&req); err != nil {
+	// Multi-output requests: one entry becomes the job's primary output, the rest share its download
+	extraSpecs, err := splitOutputs(&req)
+	if err != nil {
 		return utils.BadRequest(c, utils.ErrValidationError, err.Error())
 	}
 
-	// Extract video ID
-	videoID, err := utils.ExtractVideoID(req.URL)
+	// Validate request; what it adjusts, and later selection and planning, becomes warnings
+	var warnings models.Warnings
+	if err := utils.ValidateDownloadRequest(&req, &warnings); err != nil {
+		return utils.BadRequest(c, utils.ErrValidationError, err.Error())
+	}
+	for i := range extraSpecs {
+		if err := validateOutputSpec(&req, &extraSpecs[i]); err != nil {
+			return utils.BadRequest(c, utils.ErrValidationError, "outputs: "+err.Error())
+		}
+	}
+	// Further outputs share the sources, and a muted job has no audio
+	if req.Audio.Mute && len(extraSpecs) > 0 {
+		return utils.BadRequest(c, utils.ErrValidationError, "outputs: can't be combined with audio.mute")
+	}
+
+	// Codec overrides need an encoder this ffmpeg build provides
+	if err := services.CheckAudioCodec(req.Audio.Codec); err != nil {
+		return utils.BadRequest(c, utils.ErrValidationError, "audio.codec: "+err.Error())
+	}
+
+	// Only authorized API keys may jump the queue
+	priority := req.Priority
+	if priority == "" {
+		priority = models.PriorityNormal
+	}
+	if priority == models.PriorityHigh && !slices.Contains(config.PriorityAPIKeys, c.Get("X-API-Key")) {
+		return utils.Forbidden(c, "High priority requires an authorized API key")
+	}
+
+	// Refuse new work until space is freed after a disk-full error
+	if utils.StorageFull() {
+		return utils.Error(c, fiber.StatusInsufficientStorage, utils.ErrStorageFull, "Server storage is full, retry later")
+	}
+	if utils.StorageQuotaExceeded() {
+		return utils.Error(c, fiber.StatusInsufficientStorage, utils.ErrStorageQuota, "Server storage quota is used up, retry later")
+	}
+
+	// Shed new work while the FFmpeg backlog is too long to finish in reasonable time
+	if shed, load := services.ShouldShedLoad(); shed {
+		wait := int(math.Ceil(load.EstimatedWaitSeconds))
+		c.Set(fiber.HeaderRetryAfter, strconv.Itoa(wait))
+		return c.Status(fiber.StatusServiceUnavailable).JSON(utils.OverloadResponse{
+			Error: utils.ErrorDetail{
+				Code:    utils.ErrOverloaded,
+				Message: "Server is overloaded, retry later",
+			},
+			EstimatedWaitSeconds: wait,
+		})
+	}
+
+	// Extract video ID and optional start time (?t=90)
+	videoID, urlStart, err := utils.ParseYouTubeURL(req.URL)
 	if err != nil {
 		return utils.BadRequest(c, utils.ErrInvalidURL, err.Error())
 	}
 
-	extractData, err := services.Extract(videoID)
+	// Bound the synchronous phase; the request context also ends on shutdown
+	ctx, cancel := context.WithTimeout(c.Context(), config.DownloadSyncTimeout)
+	defer cancel()
+
+	// Shared with previews and earlier requests for the video; must not be modified
+	extractData, err := services.ExtractCached(services.WithExtractPriority(ctx, priority), videoID)
+	if ctx.Err() != nil {
+		return abortSyncPhase(c, ctx)
+	}
+	var rateLimited *services.RateLimitError
+	if errors.As(err, &rateLimited) {
+		c.Set(fiber.HeaderRetryAfter, strconv.Itoa(rateLimited.RetryAfterSeconds()))
+		return utils.Error(c, fiber.StatusServiceUnavailable, utils.ErrExtractLimited, "Video metadata service is rate limited, retry later")
+	}
 	if err != nil {
 		return utils.InternalError(c, "Failed to fetch video metadata")
 	}
 
+	// URL timestamp acts as an implicit trim start; explicit trim wins
+	trimFromURL := false
+	if req.Trim == nil && urlStart > 0 && urlStart < extractData.Duration {
+		req.Trim = &models.TrimConfig{Start: urlStart, End: extractData.Duration}
+		trimFromURL = true
+	}
+
+	// Fades need re-encoding, so they always use accurate trim
+	if req.Trim != nil && req.Trim.Fade != nil {
+		req.Trim.Accurate = true
+	}
+
+	// A trim ending past the video ends with it (and so does a fade-out)
+	if req.Trim != nil && extractData.Duration > req.Trim.Start && req.Trim.End > extractData.Duration {
+		warnings.Add(models.WarnTrimClamped, "trim.end",
+			fmt.Sprintf("Video ends at %.1fs; trim.end %.1fs was clamped to it", extractData.Duration, req.Trim.End))
+		req.Trim.End = extractData.Duration
+	}
+
 	// Set default values
 	osType := req.OS
 	if osType == "" {
 		osType = "windows"
 	}
-	bitrate := req.Audio.Bitrate
-	if bitrate == "" {
-		bitrate = "192k"
+	// Ciphered/DRM streams are skipped during selection; say so when nothing else is left
+	if (!req.Audio.Mute && services.HasOnlyProtectedStreams(extractData.AudioStreams)) ||
+		(req.Output.Type == "video" && services.HasOnlyProtectedStreams(extractData.VideoStreams)) {
+		return utils.Error(c, fiber.StatusUnprocessableEntity, utils.ErrProtectedContent, "Video is protected (DRM or ciphered streams only)")
 	}
 
-	// Select streams
+	// Select streams; a recent identical request reuses its streams (same itags, fresh URLs)
+	// Muted video selects no audio stream; remembered selections are pairs, so it doesn't use them
+	selectionKey := services.SelectionKey(videoID, req.Output.Type, req.Output.Quality, osType, req.Audio.TrackID, req.AllowTranscode)
 	var videoSelection *models.VideoSelectionResult
 	var audioStream *models.Stream
+	selectionChanged := false
+	if !req.Audio.Mute {
+		videoSelection, audioStream, selectionChanged = services.ReuseSelection(extractData, selectionKey)
+	}
 
-	if req.Output.Type == "video" {
-		videoSelection = services.SelectVideo(extractData, req.Output.Quality, osType)
-		if videoSelection.Stream == nil {
-			return utils.NotFound(c, utils.ErrVideoNotFound, "No compatible video stream found")
+	if audioStream == nil {
+		if req.Output.Type == "video" {
+			videoSelection = services.SelectVideo(extractData, req.Output.Quality, osType)
+			// Nothing playable on this os: transcode the best stream to H.264 (opt-in), else explain
+			if videoSelection.Stream == nil && req.AllowTranscode && canTranscodeVideo(req.Output.Format) {
+				videoSelection = services.SelectVideoTranscode(extractData, req.Output.Quality, osType)
+			}
+			if videoSelection.Stream == nil {
+				return incompatibleVideo(c, extractData, req.Output.Format)
+			}
+			if !req.Audio.Mute {
+				audioStream = services.SelectAudio(extractData, req.Audio.TrackID, osType)
+				if audioStream == nil {
+					return utils.NotFound(c, utils.ErrAudioNotFound, "No compatible audio stream found")
+				}
+			}
+		} else {
+			audioStream = services.SelectAudio(extractData, req.Audio.TrackID, osType)
+			if audioStream == nil {
+				return utils.NotFound(c, utils.ErrAudioNotFound, "No compatible audio stream found")
+			}
 		}
-		audioStream = services.SelectAudio(extractData, req.Audio.TrackID, osType)
-		if audioStream == nil {
-			return utils.NotFound(c, utils.ErrAudioNotFound, "No compatible audio stream found")
+		if !req.Audio.Mute {
+			services.RememberSelection(selectionKey, videoSelection, audioStream)
 		}
-	} else {
-		audioStream = services.SelectAudio(extractData, req.Audio.TrackID, osType)
-		if audioStream == nil {
-			return utils.NotFound(c, utils.ErrAudioNotFound, "No compatible audio stream found")
+	}
+	addSelectionWarnings(&warnings, &req, videoSelection, audioStream, selectionChanged)
+
+	// Resolve "auto" to the container that allows pure copy of the selected streams
+	var videoStream *models.Stream
+	if videoSelection != nil {
+		videoStream = videoSelection.Stream
+	}
+	format, err := resolveFormat(req.Output.Type, req.Output.Format, videoStream, audioStream)
+	if err != nil {
+		return utils.BadRequest(c, utils.ErrValidationError, err.Error())
+	}
+
+	// Transcoded video is encoded, so the transcode duration cap applies (no stream-only fallback)
+	videoTranscode := videoSelection != nil && videoSelection.NeedsReencode
+	if videoTranscode {
+		if req.Output.Format == config.FormatAuto {
+			format = "mp4"
+		}
+		duration := extractData.Duration
+		if req.Trim != nil {
+			duration = min(req.Trim.End, duration) - req.Trim.Start
+		}
+		if duration > config.MaxMergeDurationTranscode {
+			return utils.Error(c, fiber.StatusUnprocessableEntity, utils.ErrValidationError,
+				fmt.Sprintf("allowTranscode: limited to %.0f minutes of video", config.MaxMergeDurationTranscode/60))
+		}
+	}
+
+	createdAt := time.Now().UnixMilli()
+	extraOutputs := make([]models.ExtraOutput, 0, len(extraSpecs))
+	for i, spec := range extraSpecs {
+		extraFormat, err := resolveFormat(spec.Type, spec.Format, videoStream, audioStream)
+		if err != nil {
+			return utils.BadRequest(c, utils.ErrValidationError, "outputs: "+err.Error())
 		}
+		extraOutputs = append(extraOutputs, models.ExtraOutput{
+			Name:       utils.ExtraOutputName(i, extraFormat),
+			OutputType: spec.Type,
+			Format:     extraFormat,
+			Bitrate:    resolveBitrate(models.AudioConfig{Bitrate: spec.Bitrate}, extraFormat),
+			Trim:       req.Trim,
+			Status:     models.StatusPending,
+			CreatedAt:  createdAt,
+		})
 	}
 
-	// Generate job ID
-	jobID := generateID()
+	// Lossless output from a lossy source only inflates the file
+	lossyToLossless := audioStream != nil && slices.Contains(config.LosslessFormats, format) && services.IsLossyStream(audioStream)
+	if lossyToLossless && req.Audio.StrictLossless {
+		return utils.Error(c, fiber.StatusUnprocessableEntity, utils.ErrLossySource,
+			fmt.Sprintf("Source audio is lossy (%s); %s output would not improve quality", services.DescribeStream(audioStream).Codec, format))
+	}
+	if lossyToLossless {
+		warnings.Add(models.WarnLossyToLossless, "output.format",
+			fmt.Sprintf("Source audio is lossy (%s); %s output is larger without being better", services.DescribeStream(audioStream).Codec, format))
+	}
 
-	// Create job directory
-	if err := utils.CreateJobDir(jobID); err != nil {
-		return utils.InternalError(c, "Failed to create job directory")
+	// Muted video has no audio to encode
+	bitrate := ""
+	if !req.Audio.Mute {
+		bitrate = resolveBitrate(req.Audio, format)
 	}
 
-	// Prepare metadata
+	// Static video is always encoded, so the transcode duration cap applies
+	if req.Output.StaticVideo {
+		if extractData.ThumbnailURL == "" {
+			return utils.BadRequest(c, utils.ErrValidationError, "output.staticVideo: video has no thumbnail")
+		}
+		duration := extractData.Duration
+		if req.Trim != nil {
+			duration = min(req.Trim.End, duration) - req.Trim.Start
+		}
+		if duration > config.MaxMergeDurationTranscode {
+			return utils.BadRequest(c, utils.ErrValidationError, fmt.Sprintf("output.staticVideo: limited to %.0f minutes of audio", config.MaxMergeDurationTranscode/60))
+		}
+	}
+
+	// Tempo and pitch re-encode the audio; longer videos would be streamed unchanged
+	if (req.Audio.Tempo != 0 || req.Audio.PitchSemitones != 0) && extractData.Duration > config.MaxMergeDurationTranscode {
+		return utils.BadRequest(c, utils.ErrValidationError, fmt.Sprintf("audio.tempo, audio.pitchSemitones: limited to videos of %.0f minutes", config.MaxMergeDurationTranscode/60))
+	}
+
+	// Size limits: refuse jobs that would run for ages and fill the disk
+	// Streams of unknown size are allowed; their download is capped instead.
+	var sourceLimit int64
+	if !slices.Contains(config.SizeLimitExemptKeys, c.Get("X-API-Key")) {
+		if (audioStream != nil && audioStream.ContentLength == 0) || (videoStream != nil && videoStream.ContentLength == 0) {
+			sourceLimit = config.MaxSourceBytes
+		} else if tooLarge := checkSizeLimits(req.Output.Type, format, bitrate, videoStream, audioStream, extractData.Duration, req.Trim); tooLarge != nil {
+			return c.Status(fiber.StatusUnprocessableEntity).JSON(tooLarge)
+		}
+	}
+
+	// No side effects once the deadline has passed or the server is going away
+	if ctx.Err() != nil {
+		return abortSyncPhase(c, ctx)
+	}
+
+	// Prepare metadata (the job ID is assigned on creation)
 	meta := &models.Meta{
-		ID:         jobID,
-		Status:     models.StatusPending,
-		CreatedAt:  time.Now().UnixMilli(),
-		VideoID:    videoID,
-		Title:      extractData.Title,
-		Duration:   extractData.Duration,
-		OutputType: req.Output.Type,
-		Format:     req.Output.Format,
-		Bitrate:    bitrate,
-		Trim:       req.Trim,
-		Files:      models.FilesInfo{},
+		Status:          models.StatusPending,
+		CreatedAt:       createdAt,
+		ExpiresAt:       time.UnixMilli(createdAt).Add(config.MaxJobAge).UnixMilli(),
+		Client:          utils.RequestClientInfo(c),
+		UsageKey:        utils.ClientBinding("key", c.Get("X-API-Key")),
+		Request:         newJobRequest(&req, priority),
+		VideoID:         videoID,
+		Title:           extractData.Title,
+		Duration:        extractData.Duration,
+		Author:          extractData.Author,
+		UploadDate:      utils.NormalizeUploadDate(extractData.UploadDate),
+		ViewCount:       int64(extractData.ViewCount),
+		ThumbnailURL:    extractData.ThumbnailURL,
+		OutputType:      req.Output.Type,
+		Format:          format,
+		Bitrate:         bitrate,
+		AudioCodec:      req.Audio.Codec,
+		StaticVideo:     req.Output.StaticVideo,
+		VideoTranscode:  videoTranscode,
+		NoAutorotate:    req.Output.Autorotate != nil && !*req.Output.Autorotate,
+		ForceRotate:     req.Output.ForceRotate,
+		Muted:           req.Audio.Mute,
+		ChannelMode:     req.Audio.Channels,
+		LossyToLossless: lossyToLossless,
+		KeepSources:     config.KeepSourcesDefault,
+		Priority:        priority,
+		Trim:            req.Trim,
+		Tempo:           req.Audio.Tempo,
+		PitchSemitones:  req.Audio.PitchSemitones,
+		SourceLimit:     sourceLimit,
+		Files:           models.FilesInfo{},
+	}
+	if len(extraOutputs) > 0 {
+		meta.Outputs = extraOutputs
+	}
+	meta.OutputDuration = services.OutputDuration(meta)
+
+	// Chapter markers for long audio (m4a/m4b); a single chapter adds nothing
+	if req.Output.Type == "audio" && !req.Output.StaticVideo && services.SupportsChapters(format) && len(extractData.Chapters) > 1 {
+		meta.Chapters = slices.Clone(extractData.Chapters)
+	}
+
+	// Transcript from the video's captions; jobs without captions complete without one
+	if req.Output.Type == "audio" && req.Audio.Transcript {
+		meta.Transcript = services.NewTranscript(services.SelectCaption(extractData.Captions))
+	}
+
+	if req.KeepSources != nil {
+		meta.KeepSources = *req.KeepSources
+	}
+
+	// Explicit (or URL) trim takes precedence over silence detection
+	meta.AutoTrimSilence = req.Audio.AutoTrimSilence && req.Trim == nil
+
+	// Strict mode: bind download links to the session, else to the client IP
+	if config.SignedURLBindClient {
+		if req.SessionID != "" {
+			meta.Binding = utils.ClientBinding(utils.BindSession, req.SessionID)
+		} else {
+			bindIP := req.BindIP
+			if bindIP == "" {
+				bindIP = c.IP()
+			}
+			meta.Binding = utils.ClientBinding(utils.BindIP, bindIP)
+		}
 	}
 
 	// Set file info
 	if req.Output.Type == "video" {
 		videoExt := services.GetExtension(videoSelection.Stream)
-		audioExt := services.GetExtension(audioStream)
 		meta.Quality = videoSelection.SelectedQuality
 		meta.Files.Video = &models.FileInfo{
-			Name: "video." + videoExt,
-			Size: videoSelection.Stream.ContentLength,
+			Name:   "video." + videoExt,
+			Size:   videoSelection.Stream.ContentLength,
+			Source: services.SourceCacheName(videoID, videoSelection.Stream),
 		}
-		meta.Files.Audio = &models.FileInfo{
-			Name: "audio." + audioExt,
-			Size: audioStream.ContentLength,
+		if audioStream != nil {
+			audioExt := services.GetExtension(audioStream)
+			meta.Files.Audio = &models.FileInfo{
+				Name:   "audio." + audioExt,
+				Size:   audioStream.ContentLength,
+				Source: services.SourceCacheName(videoID, audioStream),
+			}
 		}
 	} else {
 		audioExt := services.GetExtension(audioStream)
 		meta.Files.Audio = &models.FileInfo{
-			Name: "audio." + audioExt,
-			Size: audioStream.ContentLength,
+			Name:   "audio." + audioExt,
+			Size:   audioStream.ContentLength,
+			Source: services.SourceCacheName(videoID, audioStream),
 		}
 	}
 
-	// Save metadata
-	if err := utils.WriteMeta(jobID, meta); err != nil {
-		utils.DeleteJobDir(jobID)
-		return utils.InternalError(c, "Failed to save job metadata")
+	// Selected streams and processing plan (also returned by status)
+	// The copy-vs-encode decision reads the source codec from meta.Selection
+	selection := &models.StreamSelection{}
+	if audioStream != nil {
+		selection.Audio = services.DescribeStream(audioStream)
+		selection.SourceSize = audioStream.ContentLength
+	}
+	meta.Selection = selection
+	plan := services.PlanJob(meta)
+	selection.Transcode = plan.EstimatedCPUClass == models.CPUClassHeavy
+	selection.StreamOnly = plan.Delivery == models.PlanDeliveryStream
+	if selection.StreamOnly {
+		warnings.Add(models.WarnStreamOnly, "", models.StreamOnlyMessage)
 	}
+	if videoSelection != nil {
+		selection.Video = services.DescribeStream(videoSelection.Stream)
+		selection.Merge = !selection.StreamOnly && audioStream != nil
+		selection.SourceSize += videoSelection.Stream.ContentLength
+	}
+
+	meta.Warnings = warnings
+
+	// Create the job directory with its metadata in one step
+	jobID, err := createJob(meta)
+	if err != nil {
+		return utils.InternalError(c, "Failed to create job")
+	}
+	services.LogSelection(jobID, selection)
 
 	// Start background processing
-	go processJob(jobID, meta, videoSelection, audioStream, req.Output.Format, bitrate)
+	go processJob(jobID, meta, videoSelection, audioStream, format, bitrate)
 
 	// Build response
 	response := models.DownloadResponse{
-		StatusURL: utils.GenerateStatusURL(jobID),
-		Title:     extractData.Title,
-		Duration:  extractData.Duration,
+		StatusURL:        utils.GenerateStatusURL(jobID),
+		Title:            extractData.Title,
+		Duration:         extractData.Duration,
+		ResolvedFormat:   format,
+		ResolvedBitrate:  bitrate,
+		Selection:        selection,
+		LossyToLossless:  lossyToLossless,
+		TrimFromURL:      trimFromURL,
+		Muted:            meta.Muted,
+		SelectionChanged: selectionChanged,
+		Plan:             plan,
+		Warnings:         append(models.Warnings{}, warnings...),
+	}
+	for _, output := range meta.Outputs {
+		response.Outputs = append(response.Outputs, models.OutputStatus{
+			Name:    output.Name,
+			Format:  output.Format,
+			Bitrate: output.Bitrate,
+			Status:  output.Status,
+		})
 	}
 
 	if req.Output.Type == "video" && videoSelection != nil {
@@ -156,156 +521,592 @@ func HandleDownload(c *fiber.Ctx) error {
 		response.NeedsReencode = videoSelection.NeedsReencode
 	}
 
-	return c.JSON(response)
+	if idempotencyKey != "" {
+		record := &utils.IdempotencyRecord{
+			JobID:     jobID,
+			BodyHash:  bodyHash,
+			Response:  response,
+			CreatedAt: meta.CreatedAt,
+		}
+		if err := utils.WriteIdempotencyRecord(idempotencyKey, record); err != nil {
+			jobLog.Warn("failed to store idempotency key", "job", jobID, "err", err)
+		}
+	}
+
+	return sendJobCreated(c, response)
+}
+
+// sendJobCreated responds 202 Accepted with Location set to the signed status URL
+// ?legacy=true keeps the deprecated 200 response.
+func sendJobCreated(c *fiber.Ctx, response models.DownloadResponse) error {
+	if c.QueryBool("legacy") {
+		return c.JSON(response)
+	}
+	c.Location(response.StatusURL)
+	c.Set(fiber.HeaderRetryAfter, strconv.Itoa(config.FirstPollDelay))
+	return c.Status(fiber.StatusAccepted).JSON(response)
+}
+
+// canTranscodeVideo reports whether the allowTranscode fallback (H.264) can produce format
+func canTranscodeVideo(format string) bool {
+	return format == config.FormatAuto || config.VideoCodecMap[format] == "libx264"
+}
+
+// incompatibleVideo responds 404 listing the codecs the video offers and the os values that play them
+func incompatibleVideo(c *fiber.Ctx, extractData *models.ExtractResponse, format string) error {
+	offered := services.OfferedVideoCodecs(extractData)
+	if len(offered) == 0 {
+		return utils.NotFound(c, utils.ErrVideoNotFound, "No compatible video stream found")
+	}
+	return c.Status(fiber.StatusNotFound).JSON(utils.IncompatibleStreamResponse{
+		Error: utils.ErrorDetail{
+			Code:    utils.ErrVideoNotFound,
+			Message: fmt.Sprintf("No video stream playable on this os (video offers %s)", strings.Join(offered, ", ")),
+		},
+		OfferedCodecs:      offered,
+		CompatibleProfiles: services.ProfilesForCodecs(offered),
+		TranscodeAvailable: canTranscodeVideo(format),
+	})
+}
+
+// pcmBytesPerSecond is 16-bit stereo at 44.1 kHz, the size of WAV output and a bound for FLAC
+const pcmBytesPerSecond = 44100 * 2 * 2
+
+// checkSizeLimits compares the selected streams and the estimated output with
+// MaxSourceBytes and MaxOutputBytes; nil when the job fits
+func checkSizeLimits(outputType string, format string, bitrate string, videoStream *models.Stream, audioStream *models.Stream, duration float64, trim *models.TrimConfig) *utils.TooLargeResponse {
+	response := &utils.TooLargeResponse{
+		AudioBytes:           streamLength(audioStream),
+		EstimatedOutputBytes: estimateOutputBytes(outputType, format, bitrate, videoStream, audioStream, duration, trim),
+		MaxSourceBytes:       config.MaxSourceBytes,
+		MaxOutputBytes:       config.MaxOutputBytes,
+	}
+	if videoStream != nil {
+		response.VideoBytes = videoStream.ContentLength
+	}
+
+	hint := "request a lower quality"
+	if outputType == "audio" {
+		hint = "request a lower bitrate or a trim"
+	}
+
+	switch {
+	case config.MaxSourceBytes > 0 && max(response.VideoBytes, response.AudioBytes) > config.MaxSourceBytes:
+		response.Error = utils.ErrorDetail{
+			Code:    utils.ErrTooLarge,
+			Message: fmt.Sprintf("Selected stream is %d MB, over the %d MB limit; %s", max(response.VideoBytes, response.AudioBytes)>>20, config.MaxSourceBytes>>20, hint),
+		}
+	case config.MaxOutputBytes > 0 && response.EstimatedOutputBytes > config.MaxOutputBytes:
+		response.Error = utils.ErrorDetail{
+			Code:    utils.ErrTooLarge,
+			Message: fmt.Sprintf("Output would be about %d MB, over the %d MB limit; %s", response.EstimatedOutputBytes>>20, config.MaxOutputBytes>>20, hint),
+		}
+	default:
+		return nil
+	}
+	return response
+}
+
+// estimateOutputBytes approximates the size of the primary output
+// Copied streams scale with the kept duration; encoded audio follows its bitrate.
+func estimateOutputBytes(outputType string, format string, bitrate string, videoStream *models.Stream, audioStream *models.Stream, duration float64, trim *models.TrimConfig) int64 {
+	kept := duration
+	if trim != nil {
+		kept = min(trim.End, duration) - trim.Start
+	}
+	fraction := 1.0
+	if duration > 0 {
+		fraction = kept / duration
+	}
+
+	if outputType == "video" {
+		return int64(float64(videoStream.ContentLength+streamLength(audioStream)) * fraction)
+	}
+	if slices.Contains(config.LosslessFormats, format) {
+		return int64(kept * pcmBytesPerSecond)
+	}
+	if bps := services.AudioBitsPerSecond(bitrate); bps > 0 {
+		return int64(kept * float64(bps) / 8)
+	}
+	return int64(float64(audioStream.ContentLength) * fraction)
+}
+
+// streamLength returns the size of a selected stream, 0 for none (muted video)
+func streamLength(stream *models.Stream) int64 {
+	if stream == nil {
+		return 0
+	}
+	return stream.ContentLength
+}
+
+// newJobRequest copies the allowlisted fields of a normalized request for meta
+func newJobRequest(req *models.DownloadRequest, priority string) *models.JobRequest {
+	request := &models.JobRequest{
+		URL:            req.URL,
+		OS:             req.OS,
+		Output:         req.Output,
+		Outputs:        slices.Clone(req.Outputs),
+		Audio:          req.Audio,
+		Priority:       priority,
+		KeepSources:    req.KeepSources,
+		AllowTranscode: req.AllowTranscode,
+	}
+	if req.Trim != nil {
+		trim := *req.Trim
+		request.Trim = &trim
+	}
+	return request
+}
+
+// splitOutputs moves the primary entry of req.Outputs (the first video output, else the first)
+// into req.Output and returns the others. Video outputs must share one quality.
+func splitOutputs(req *models.DownloadRequest) ([]models.OutputSpec, error) {
+	if len(req.Outputs) == 0 {
+		return nil, nil
+	}
+	if req.Output != (models.OutputConfig{}) {
+		return nil, utils.ValidationError{Field: "outputs", Message: "output and outputs are mutually exclusive"}
+	}
+	if len(req.Outputs) > config.MaxOutputsPerJob {
+		return nil, utils.ValidationError{Field: "outputs", Message: fmt.Sprintf("At most %d outputs per job", config.MaxOutputsPerJob)}
+	}
+
+	primary := 0
+	for i, spec := range req.Outputs {
+		if spec.Type == "video" {
+			primary = i
+			break
+		}
+	}
+	primarySpec := req.Outputs[primary]
+	for _, spec := range req.Outputs {
+		if spec.Type == "video" && spec.Quality != primarySpec.Quality {
+			return nil, utils.ValidationError{Field: "outputs", Message: "Video outputs must share one quality (the source is downloaded once)"}
+		}
+	}
+
+	req.Output = models.OutputConfig{Type: primarySpec.Type, Format: primarySpec.Format, Quality: primarySpec.Quality}
+	if primarySpec.Bitrate != "" {
+		req.Audio.Bitrate = primarySpec.Bitrate
+	}
+	return slices.Delete(slices.Clone(req.Outputs), primary, primary+1), nil
+}
+
+// validateOutputSpec validates an additional output like a request of its own and normalizes it
+func validateOutputSpec(req *models.DownloadRequest, spec *models.OutputSpec) error {
+	specReq := *req
+	specReq.Output = models.OutputConfig{Type: spec.Type, Format: spec.Format, Quality: spec.Quality}
+	specReq.Audio = models.AudioConfig{Bitrate: spec.Bitrate}
+	if err := utils.ValidateDownloadRequest(&specReq, nil); err != nil {
+		return err
+	}
+	spec.Type = specReq.Output.Type
+	spec.Format = specReq.Output.Format
+	spec.Bitrate = specReq.Audio.Bitrate
+	return nil
+}
+
+// addSelectionWarnings records what stream selection chose differently from the request
+func addSelectionWarnings(warnings *models.Warnings, req *models.DownloadRequest, videoSelection *models.VideoSelectionResult, audioStream *models.Stream, selectionChanged bool) {
+	if videoSelection != nil && videoSelection.QualityChanged {
+		warnings.Add(models.WarnQualityDowngraded, "output.quality", videoSelection.QualityChangeReason)
+	}
+	if videoSelection != nil && videoSelection.NeedsReencode {
+		warnings.Add(models.WarnVideoTranscoded, "allowTranscode", "No video stream plays on this os; the video is re-encoded to H.264")
+	}
+	if audioStream != nil && req.Audio.TrackID != "" && audioStream.AudioTrackID != req.Audio.TrackID {
+		track := audioStream.AudioTrackID
+		if track == "" {
+			track = "the default track"
+		}
+		warnings.Add(models.WarnAudioTrackFallback, "audio.trackId",
+			fmt.Sprintf("Audio track %s not found; using %s", req.Audio.TrackID, track))
+	}
+	if selectionChanged {
+		warnings.Add(models.WarnSelectionChanged, "", "Streams of a recent identical request are no longer offered; others were selected")
+	}
+}
+
+// resolveFormat resolves "auto" to the container that allows pure copy of the selected streams
+func resolveFormat(outputType string, format string, videoStream *models.Stream, audioStream *models.Stream) (string, error) {
+	if format != config.FormatAuto {
+		return format, nil
+	}
+	if outputType == "audio" {
+		videoStream = nil
+	}
+	format = services.ResolveAutoFormat(outputType, videoStream, audioStream)
+
+	formats := config.AudioFormats
+	if outputType == "video" {
+		formats = config.VideoFormats
+	}
+	if !slices.Contains(formats, format) {
+		return "", errors.New("Could not resolve an output format for the selected streams")
+	}
+	return format, nil
+}
+
+// resolveBitrate returns the VBR level, explicit bitrate or format default (none for lossless)
+func resolveBitrate(audio models.AudioConfig, format string) string {
+	if slices.Contains(config.LosslessFormats, format) {
+		return ""
+	}
+	if audio.VBR != "" {
+		return audio.VBR // "V0".."V9", used in place of a bitrate
+	}
+	if audio.Bitrate != "" {
+		return audio.Bitrate
+	}
+	return services.DefaultBitrate(format, audio.Codec)
+}
+
+// abortSyncPhase responds when HandleDownload gives up before creating the job
+func abortSyncPhase(c *fiber.Ctx, ctx context.Context) error {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return utils.Error(c, fiber.StatusGatewayTimeout, utils.ErrTimeout, "Timed out preparing the job, retry later")
+	}
+	httpLog.Info("download request cancelled before job creation", "err", ctx.Err())
+	return utils.Error(c, fiber.StatusServiceUnavailable, utils.ErrInternalError, "Request cancelled")
 }
 
 // processJob handles the background download and processing
 func processJob(jobID string, meta *models.Meta, videoSelection *models.VideoSelectionResult, audioStream *models.Stream, format string, bitrate string) {
-	// Timeout: 30 minutes max per job to prevent zombie goroutines
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Minute)
+	// Only one worker may touch a job directory at a time
+	if err := utils.AcquireRunLock(jobID); err != nil {
+		jobLog.Info("skipping run", "job", jobID, "err", err)
+		return
+	}
+	runJob(jobID, meta, videoSelection, audioStream, format, bitrate)
+}
+
+// runJob downloads the sources and renders the outputs of a job
+// The caller holds the job's run lock; runJob releases it.
+func runJob(jobID string, meta *models.Meta, videoSelection *models.VideoSelectionResult, audioStream *models.Stream, format string, bitrate string) {
+	// Last: the group archive needs the final status
+	defer utils.NotifyGroupMemberFinished(jobID)
+	defer utils.ReleaseRunLock(jobID)
+
+	// Timeout: max per job to prevent zombie goroutines
+	ctx, cancel := context.WithTimeout(context.Background(), config.JobTimeout)
 	defer cancel()
 
+	// Sources and renders live in the work directory (TEMP_DIR); finished files move to jobDir
 	jobDir := utils.GetJobDir(jobID)
+	workDir := utils.GetWorkDir(jobID)
+
+	// Track bytes downloaded from origin for billing
+	var originBytes atomic.Int64
+	ctx = services.WithOriginCounter(ctx, &originBytes)
+	ctx = services.WithFFmpegJob(ctx, jobID)
+	if meta.SourceLimit > 0 {
+		ctx = services.WithSourceLimit(ctx, meta.SourceLimit)
+	}
+	defer func() {
+		utils.AddMetaOriginBytes(jobID, originBytes.Load())
+	}()
 
 	defer func() {
 		if r := recover(); r != nil {
-			utils.UpdateMetaError(jobID, "Internal error")
+			utils.PanicError("job run", r, "job", jobID)
+			utils.UpdateMetaError(jobID, &models.JobError{
+				Code:    models.JobErrInternal,
+				Message: "Internal error",
+				Phase:   models.PhaseProcessing,
+			})
 		}
 	}()
 
-	if meta.OutputType == "video" {
-		// Download video and audio in parallel
-		errChan := make(chan error, 2)
+	if err := os.MkdirAll(workDir, 0755); err != nil {
+		utils.UpdateMetaError(jobID, services.NewJobError(models.PhaseDownload, "Download failed", err))
+		return
+	}
 
-		go func() {
-			videoPath := jobDir + "/" + meta.Files.Video.Name
-			errChan <- services.Download(ctx, videoSelection.Stream.URL, videoPath, videoSelection.Stream.ContentLength)
-		}()
+	if !downloadSources(ctx, jobID, meta, workDir, videoSelection, audioStream) {
+		return
+	}
 
-		go func() {
-			audioPath := jobDir + "/" + meta.Files.Audio.Name
-			errChan <- services.Download(ctx, audioStream.URL, audioPath, audioStream.ContentLength)
-		}()
+	// Still image for static video
+	if meta.StaticVideo {
+		if err := services.DownloadCover(ctx, meta.ThumbnailURL, filepath.Join(workDir, config.CoverFileName)); err != nil {
+			utils.UpdateMetaError(jobID, services.NewJobError(models.PhaseDownload, "Thumbnail download failed", err))
+			return
+		}
+	}
+
+	if meta.AutoTrimSilence {
+		applySilenceTrim(ctx, jobID, meta)
+	}
+
+	// Before the delivery decision: forceRotate may turn the merge into a re-encode
+	if meta.OutputType == "video" && !meta.StaticVideo {
+		applyRotation(jobID, meta)
+	}
+
+	if meta.Files.Audio != nil {
+		applyChannels(jobID, meta)
+	}
 
-		for i := 0; i < 2; i++ {
-			if err := <-errChan; err != nil {
-				utils.UpdateMetaError(jobID, "Download failed: "+err.Error())
+	// After silence detection, so the transcript follows the final trim
+	if meta.Transcript != nil && meta.Transcript.URL != "" {
+		if err := services.SaveTranscript(ctx, meta.Transcript, jobDir, meta.Duration, meta.Trim); err != nil {
+			jobLog.Warn("transcript failed", "job", jobID, "err", err)
+		} else {
+			utils.UpdateMetaTranscriptAvailable(jobID)
+		}
+	}
+
+	if services.PlanJob(meta).Delivery == models.PlanDeliveryStream {
+		// /stream reads the sources from the job directory
+		utils.CleanupTempFiles(jobID, true)
+		utils.UpdateMetaStreamOnly(jobID)
+		if len(meta.Outputs) > 0 {
+			release, err := services.AcquireFFmpegSlot(ctx, jobID, meta.Priority)
+			if err != nil {
+				for i := range meta.Outputs {
+					utils.UpdateMetaExtraOutput(jobID, i, models.StatusError, "Processing failed")
+				}
 				return
 			}
+			defer release()
+			renderExtraOutputs(ctx, jobID, meta, jobDir)
 		}
-	} else {
-		audioPath := jobDir + "/" + meta.Files.Audio.Name
-		if err := services.Download(ctx, audioStream.URL, audioPath, audioStream.ContentLength); err != nil {
-			utils.UpdateMetaError(jobID, "Download failed: "+err.Error())
-			return
-		}
+		return
 	}
 
-	if !shouldMerge(meta) {
-		utils.UpdateMetaStreamOnly(jobID)
+	// Wait for an FFmpeg slot (by priority when concurrency is limited)
+	release, err := services.AcquireFFmpegSlot(ctx, jobID, meta.Priority)
+	if err != nil {
+		utils.UpdateMetaError(jobID, services.NewJobError(models.PhaseProcessing, "Processing failed", err))
 		return
 	}
+	defer release()
 
 	// Process with FFmpeg
-	var outputFile string
-	var err error
+	utils.UpdateMetaStatus(jobID, models.StatusProcessing)
+
+	faststart := services.UseFaststart(meta, workDir)
+	outputFile, jobErr := renderOutput(ctx, workDir, meta, format, bitrate)
+	if jobErr != nil {
+		utils.UpdateMetaError(jobID, jobErr)
+		return
+	}
+	if err := utils.PromoteWorkFile(jobID, outputFile); err != nil {
+		utils.UpdateMetaError(jobID, services.NewJobError(models.PhaseProcessing, "Failed to store output", err))
+		return
+	}
+
+	// Hash parts once so resumable clients can verify each piece
+	if manifest, err := utils.BuildFileManifest(filepath.Join(jobDir, outputFile), config.ManifestPartSize); err == nil {
+		utils.UpdateMetaManifest(jobID, manifest)
+	}
+
+	utils.UpdateMetaOutput(jobID, outputFile, faststart)
+
+	// Further outputs of a multi-output job reuse the sources
+	renderExtraOutputs(ctx, jobID, meta, workDir)
+
+	// Retained sources allow POST /api/jobs/:id/convert; job-age cleanup removes them
+	utils.CleanupTempFiles(jobID, meta.KeepSources)
+}
+
+// downloadSources fetches the job's sources into workDir once it gets a download slot (by
+// priority when downloads are limited); on failure the job error is recorded and it returns false
+func downloadSources(ctx context.Context, jobID string, meta *models.Meta, workDir string, videoSelection *models.VideoSelectionResult, audioStream *models.Stream) bool {
+	release, err := services.AcquireDownloadSlot(ctx, jobID, meta.Priority)
+	if err != nil {
+		utils.UpdateMetaError(jobID, services.NewJobError(models.PhaseDownload, "Download failed", err))
+		return false
+	}
+	defer release()
 
 	if meta.OutputType == "video" {
-		outputFile, err = services.FFmpegMerge(jobDir, format, meta.Files.Video.Name, meta.Files.Audio.Name)
-		if err != nil {
-			utils.UpdateMetaError(jobID, "Processing failed: "+err.Error())
-			return
+		// Download video and audio in parallel (muted video has no audio)
+		// A failure cancels the other fetch; both have returned before the job fails.
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		errChan := make(chan error, 2)
+		downloads := 1
+
+		fetchAsync(jobID, "video download", errChan, func() error {
+			videoPath := workDir + "/" + meta.Files.Video.Name
+			return services.FetchSource(ctx, jobID, meta.Files.Video.Source, videoSelection.Stream.URL, videoPath, videoSelection.Stream.ContentLength, videoSelection.Stream.ContentHash)
+		})
+
+		if meta.Files.Audio != nil {
+			downloads++
+			fetchAsync(jobID, "audio download", errChan, func() error {
+				audioPath := workDir + "/" + meta.Files.Audio.Name
+				return services.FetchSource(ctx, jobID, meta.Files.Audio.Source, audioStream.URL, audioPath, audioStream.ContentLength, audioStream.ContentHash)
+			})
 		}
 
-		if meta.Trim != nil {
-			outputFile, err = services.FFmpegTrim(jobDir, format, meta.Trim, bitrate)
-			if err != nil {
-				utils.UpdateMetaError(jobID, "Trim failed: "+err.Error())
-				return
+		var firstErr error
+		for i := 0; i < downloads; i++ {
+			if err := <-errChan; err != nil && firstErr == nil {
+				firstErr = err
+				cancel()
 			}
 		}
+		if firstErr != nil {
+			utils.UpdateMetaError(jobID, services.NewJobError(models.PhaseDownload, "Download failed", firstErr))
+			return false
+		}
 	} else {
-		outputFile, err = services.FFmpegConvertAudio(jobDir, format, bitrate, meta.Files.Audio.Name)
-		if err != nil {
-			utils.UpdateMetaError(jobID, "Conversion failed: "+err.Error())
-			return
+		audioPath := workDir + "/" + meta.Files.Audio.Name
+		if err := services.FetchSource(ctx, jobID, meta.Files.Audio.Source, audioStream.URL, audioPath, audioStream.ContentLength, audioStream.ContentHash); err != nil {
+			utils.UpdateMetaError(jobID, services.NewJobError(models.PhaseDownload, "Download failed", err))
+			return false
 		}
+	}
+	return true
+}
 
-		if meta.Trim != nil {
-			outputFile, err = services.FFmpegTrimAudio(jobDir, format, meta.Trim, bitrate)
-			if err != nil {
-				utils.UpdateMetaError(jobID, "Trim failed: "+err.Error())
-				return
+// fetchAsync runs fetch in a goroutine and sends its result to errChan; a panic is
+// recovered there (runJob's recover doesn't cover other goroutines) and sent as an error
+func fetchAsync(jobID string, what string, errChan chan<- error, fetch func() error) {
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				errChan <- utils.PanicError(what, r, "job", jobID)
 			}
+		}()
+		errChan <- fetch()
+	}()
+}
+
+// renderExtraOutputs renders the further outputs of a multi-output job from the shared sources in sourceDir
+// The duration limits apply per output; only the primary output can fall back to streaming.
+func renderExtraOutputs(ctx context.Context, jobID string, meta *models.Meta, sourceDir string) {
+	for i := range meta.Outputs {
+		outputMeta := extraOutputMeta(meta, &meta.Outputs[i])
+		if services.PlanJob(outputMeta).Delivery == models.PlanDeliveryStream {
+			utils.UpdateMetaExtraOutput(jobID, i, models.StatusError, "Too long to pre-render this output")
+			continue
 		}
+		renderExtraOutput(ctx, jobID, i, outputMeta, sourceDir)
 	}
-
-	utils.CleanupTempFiles(jobID)
-	utils.UpdateMetaOutput(jobID, outputFile)
 }
 
-// shouldMerge determines if the job should be pre-merged or stream-only
-// Strategy: minimize CPU usage
-// - Heavy tasks (transcode): threshold 15 minutes
-// - Light tasks (remux/copy): threshold 4 hours
-func shouldMerge(meta *models.Meta) bool {
-	const (
-		maxDurationTranscode = 15 * 60.0   // 15 minutes - heavy CPU (transcode)
-		maxDurationRemux     = 4 * 3600.0  // 4 hours - light CPU (remux/copy)
-	)
+// renderOutput runs the FFmpeg phase on the sources in dir and returns the output filename
+func renderOutput(ctx context.Context, dir string, meta *models.Meta, format string, bitrate string) (string, *models.JobError) {
+	var outputFile string
+	var err error
 
-	// Check if this job needs transcoding (heavy CPU)
-	transcode := needsTranscode(meta)
+	// Partial outputs of a crashed earlier run
+	utils.RemovePartialFiles(dir)
 
-	if transcode {
-		return meta.Duration <= maxDurationTranscode
+	// Moov atom first for progressive playback (every pass rewrites the file, so each keeps it)
+	if services.UseFaststart(meta, dir) {
+		ctx = services.WithFaststart(ctx)
 	}
-	return meta.Duration <= maxDurationRemux
-}
 
-// needsTranscode checks if the job requires transcoding (heavy CPU)
-// Returns true for:
-// - Audio format conversion (e.g., webm→mp3)
-// - Video with accurate trim (requires re-encoding)
-func needsTranscode(meta *models.Meta) bool {
-	// Video with accurate trim needs re-encoding
-	if meta.OutputType == "video" && meta.Trim != nil && meta.Trim.Accurate {
-		return true
+	// Audio over the thumbnail; trim and fades are applied in the same pass
+	if meta.StaticVideo {
+		outputFile, err = services.FFmpegStaticVideo(ctx, dir, format, bitrate, services.SourceAudioCodec(meta), meta.Files.Audio.Name, config.CoverFileName, meta.Trim)
+		if err != nil {
+			return "", services.NewJobError(models.PhaseProcessing, "Processing failed", err)
+		}
+		return outputFile, nil
+	}
+
+	if meta.OutputType == "video" {
+		var audioFile string
+		if meta.Files.Audio != nil {
+			audioFile = meta.Files.Audio.Name
+		}
+		outputFile, err = services.FFmpegMerge(ctx, dir, format, meta.Files.Video.Name, audioFile, services.MergeTranscodesVideo(meta), meta.Rotation)
+		if err != nil {
+			return "", services.NewJobError(models.PhaseProcessing, "Processing failed", err)
+		}
+
+		if meta.Trim != nil {
+			outputFile, err = services.FFmpegTrim(ctx, dir, format, meta.Trim, bitrate, audioFile == "", meta.Rotation)
+			if err != nil {
+				return "", services.NewJobError(models.PhaseProcessing, "Trim failed", err)
+			}
+		}
+		return outputFile, nil
 	}
 
-	// Audio: check if format conversion is needed
-	if meta.OutputType == "audio" {
-		return needsAudioTranscode(meta)
+	// Title, channel and upload year tags (also kept by the trim pass)
+	ctx = services.WithAudioTags(ctx, meta)
+	outputFile, err = services.FFmpegConvertAudio(ctx, dir, format, bitrate, meta.AudioCodec, services.SourceAudioCodec(meta), meta.Files.Audio.Name, services.AudioFilter(meta), services.OutputChannels(meta))
+	if err != nil {
+		return "", services.NewJobError(models.PhaseProcessing, "Conversion failed", err)
 	}
 
-	return false
-}
+	// Tempo changes are applied by the conversion, so the trim is cut in output time
+	if meta.Trim != nil {
+		outputFile, err = services.FFmpegTrimAudio(ctx, dir, format, services.OutputTrim(meta), bitrate, meta.AudioCodec)
+		if err != nil {
+			return "", services.NewJobError(models.PhaseProcessing, "Trim failed", err)
+		}
+	}
 
-// needsAudioTranscode checks if audio format requires transcoding
-func needsAudioTranscode(meta *models.Meta) bool {
-	if meta.Files.Audio == nil {
-		return false
+	if len(meta.Chapters) > 0 {
+		if err := services.FFmpegAddChapters(ctx, dir, format, meta.Chapters, meta.Duration, meta.Trim, services.JobTempo(meta)); err != nil {
+			return "", services.NewJobError(models.PhaseProcessing, "Chapters failed", err)
+		}
 	}
 
-	inputExt := filepath.Ext(meta.Files.Audio.Name)
-	if len(inputExt) > 0 && inputExt[0] == '.' {
-		inputExt = inputExt[1:]
+	// Leftover .part files mean an FFmpeg step didn't finish cleanly
+	if partial := utils.PartialFiles(dir); len(partial) > 0 {
+		return "", services.NewJobError(models.PhaseProcessing, "Processing failed", fmt.Errorf("incomplete output: %s", strings.Join(partial, ", ")))
 	}
+	return outputFile, nil
+}
 
-	outputFormat := meta.Format
+// applyRotation probes the rotation of the downloaded video and records how the output
+// handles it. Probe failures leave the rotation to ffmpeg's defaults.
+func applyRotation(jobID string, meta *models.Meta) {
+	videoPath := filepath.Join(utils.GetWorkDir(jobID), meta.Files.Video.Name)
+	degrees, err := services.ProbeRotation(videoPath)
+	if err != nil {
+		jobLog.Warn("rotation probe failed", "job", jobID, "err", err)
+		return
+	}
+	rotation := services.DecideRotation(meta, degrees)
+	if meta.ForceRotate && rotation.Action == models.RotationPreserved {
+		jobLog.Info("forceRotate skipped, too long to re-encode", "job", jobID)
+	}
+	meta.Rotation = rotation
+	utils.UpdateMetaRotation(jobID, rotation)
+}
 
-	// Same format: no transcode
-	if inputExt == outputFormat {
-		return false
+// applyChannels probes the channels of the downloaded audio and records how the output
+// handles them. Probe failures leave the channels as they are.
+func applyChannels(jobID string, meta *models.Meta) {
+	audioPath := filepath.Join(utils.GetWorkDir(jobID), meta.Files.Audio.Name)
+	channels, layout, err := services.ProbeChannels(audioPath)
+	if err != nil {
+		jobLog.Warn("channel probe failed", "job", jobID, "err", err)
+		return
 	}
-	// m4a/mp4 compatible: no transcode
-	if (inputExt == "m4a" || inputExt == "mp4") && (outputFormat == "m4a" || outputFormat == "mp4") {
-		return false
+	audioChannels := services.DecideChannels(meta, channels, layout)
+	if audioChannels.Action != models.ChannelsKept {
+		jobLog.Info("audio channels changed", "job", jobID, "source", layout, "sourceChannels", channels, "channels", audioChannels.Channels, "action", audioChannels.Action)
 	}
-	// webm to opus: no transcode (YouTube webm contains Opus)
-	if inputExt == "webm" && outputFormat == "opus" {
-		return false
+	meta.AudioChannels = audioChannels
+	utils.UpdateMetaAudioChannels(jobID, audioChannels)
+}
+
+// applySilenceTrim detects leading/trailing silence in the downloaded audio
+// and sets it as the job's trim. Detection failures leave the audio untrimmed.
+func applySilenceTrim(ctx context.Context, jobID string, meta *models.Meta) {
+	audioPath := filepath.Join(utils.GetWorkDir(jobID), meta.Files.Audio.Name)
+	silence, err := services.DetectSilence(ctx, audioPath, meta.Duration)
+	if err != nil {
+		jobLog.Warn("silence detection failed", "job", jobID, "err", err)
+		return
 	}
 
-	return true
+	var trim *models.TrimConfig
+	if silence.Start > 0 || silence.End < meta.Duration {
+		trim = &models.TrimConfig{Start: silence.Start, End: silence.End, Accurate: true}
+	}
+	meta.SilenceTrim = silence
+	meta.Trim = trim
+	meta.OutputDuration = services.OutputDuration(meta)
+	utils.UpdateMetaSilenceTrim(jobID, silence, trim, meta.OutputDuration)
 }
@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"context"
+	"expvar"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"yt-downloader-go/config"
+	"yt-downloader-go/models"
+	"yt-downloader-go/services"
+	"yt-downloader-go/utils"
+)
+
+func TestResolveBitrate(t *testing.T) {
+	tests := []struct {
+		name   string
+		format string
+		audio  models.AudioConfig
+		want   string
+	}{
+		{name: "opus default", format: "opus", want: "128k"},
+		{name: "ogg default", format: "ogg", want: "128k"},
+		{name: "ogg vorbis default", format: "ogg", audio: models.AudioConfig{Codec: "vorbis"}, want: "128k"},
+		{name: "m4a default", format: "m4a", want: "160k"},
+		{name: "m4b default", format: "m4b", want: "160k"},
+		{name: "m4a he-aac default", format: "m4a", audio: models.AudioConfig{Codec: "aac_he"}, want: "64k"},
+		{name: "mp3 default", format: "mp3", want: "192k"},
+		{name: "wav lossless", format: "wav", want: ""},
+		{name: "flac lossless", format: "flac", want: ""},
+		{name: "explicit opus", format: "opus", audio: models.AudioConfig{Bitrate: "96k"}, want: "96k"},
+		{name: "explicit m4a", format: "m4a", audio: models.AudioConfig{Bitrate: "256k"}, want: "256k"},
+		{name: "explicit mp3", format: "mp3", audio: models.AudioConfig{Bitrate: "320k"}, want: "320k"},
+		{name: "explicit he-aac", format: "m4a", audio: models.AudioConfig{Codec: "aac_he", Bitrate: "48k"}, want: "48k"},
+		{name: "vbr level", format: "mp3", audio: models.AudioConfig{VBR: "V0"}, want: "V0"},
+		{name: "explicit ignored for lossless", format: "flac", audio: models.AudioConfig{Bitrate: "320k"}, want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := resolveBitrate(tt.audio, tt.format); got != tt.want {
+				t.Errorf("resolveBitrate = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// panickingDownload replaces services.Download with one that panics; the test waits for
+// the expected number of calls (shared downloads outlive the job run that started them)
+func panickingDownload(t *testing.T, calls int) {
+	t.Helper()
+	var pending sync.WaitGroup
+	pending.Add(calls)
+	prev := services.Download
+	services.Download = func(ctx context.Context, downloadURL string, destPath string, totalSize int64) error {
+		defer pending.Done()
+		panic("injected download panic")
+	}
+	t.Cleanup(func() {
+		pending.Wait()
+		services.Download = prev
+	})
+}
+
+func TestRunJobSurvivesDownloadPanic(t *testing.T) {
+	panicking := expvar.Get("panics_recovered").(*expvar.Int)
+
+	tests := []struct {
+		name    string
+		sources int
+		meta    models.Meta
+	}{
+		{
+			name:    "audio",
+			sources: 1,
+			meta: models.Meta{OutputType: "audio", Format: "mp3", Bitrate: "192k", Files: models.FilesInfo{
+				Audio: &models.FileInfo{Name: "audio.webm", Size: 1000, Source: "panic-audio.webm"},
+			}},
+		},
+		{
+			name:    "video",
+			sources: 2,
+			meta: models.Meta{OutputType: "video", Format: "mp4", Files: models.FilesInfo{
+				Video: &models.FileInfo{Name: "video.mp4", Size: 1000, Source: "panic-video.mp4"},
+				Audio: &models.FileInfo{Name: "audio.m4a", Size: 1000, Source: "panic-audio.m4a"},
+			}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useTempStorage(t)
+			panickingDownload(t, tt.sources)
+
+			meta := tt.meta
+			meta.ID, meta.Status = testJobID, models.StatusProcessing
+			meta.CreatedAt = time.Now().UnixMilli()
+			meta.ExpiresAt = time.Now().Add(config.MaxJobAge).UnixMilli()
+			if err := utils.CreateJob(testJobID, &meta); err != nil {
+				t.Fatal(err)
+			}
+			if err := utils.AcquireRunLock(testJobID); err != nil {
+				t.Fatal(err)
+			}
+
+			stream := &models.Stream{URL: "http://127.0.0.1:1/videoplayback", ContentLength: 1000}
+			before := panicking.Value()
+			runJob(testJobID, &meta, &models.VideoSelectionResult{Stream: stream}, stream, meta.Format, meta.Bitrate)
+
+			got, err := utils.ReadMeta(testJobID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Status != models.StatusError || got.JobError == nil || got.JobError.Phase != models.PhaseDownload ||
+				!strings.Contains(got.JobError.Message, "injected download panic") {
+				t.Errorf("job ended %s with %+v, want a download error naming the panic", got.Status, got.JobError)
+			}
+			if n := panicking.Value() - before; n < 1 {
+				t.Errorf("panics_recovered grew by %d, want at least 1", n)
+			}
+			if _, err := os.Stat(filepath.Join(utils.GetJobDir(testJobID), "run.lock")); !os.IsNotExist(err) {
+				t.Errorf("run lock kept after the failed run (err %v)", err)
+			}
+		})
+	}
+}
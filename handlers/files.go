@@ -2,14 +2,20 @@ package handlers
 
 import (
 	"fmt"
-	"net/url"
+	"io"
+	"net/http"
 	"os"
 	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
+	"yt-downloader-go/config"
 	"yt-downloader-go/models"
 	"yt-downloader-go/utils"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/valyala/fasthttp"
 )
 
 // HandleFiles handles GET /files/:id/:filename
@@ -19,19 +25,24 @@ import (
 // @Produce octet-stream
 // @Param id path string true "Job ID"
 // @Param filename path string true "Output filename"
-// @Param token query string true "Signed URL token"
-// @Param expires query integer true "Expiration timestamp"
+// @Param t query string false "Compact signed token"
+// @Param token query string false "Legacy signed URL token (deprecated)"
+// @Param expires query integer false "Legacy expiration timestamp (deprecated)"
+// @Param part query integer false "Manifest part index (serves only that part)"
+// @Param inline query boolean false "Serve with Content-Disposition: inline for in-browser playback"
 // @Success 200 {file} binary "Output file"
+// @Success 206 {file} binary "Requested part or range"
+// @Success 304 "Not modified (If-None-Match / If-Modified-Since)"
 // @Failure 400 {object} utils.ErrorResponse "Invalid parameters"
 // @Failure 401 {object} utils.ErrorResponse "Missing auth"
 // @Failure 403 {object} utils.ErrorResponse "Invalid token"
 // @Failure 404 {object} utils.ErrorResponse "Not found"
+// @Failure 410 {object} utils.JobDeletedResponse "Job deleted"
+// @Failure 416 {object} utils.ErrorResponse "Range not satisfiable"
 // @Router /files/{id}/{filename} [get]
 func HandleFiles(c *fiber.Ctx) error {
 	jobID := c.Params("id")
 	filename := c.Params("filename")
-	token := c.Query("token")
-	expiresStr := c.Query("expires")
 
 	// Validate job ID
 	if !utils.ValidateJobID(jobID) {
@@ -44,35 +55,92 @@ func HandleFiles(c *fiber.Ctx) error {
 	}
 
 	// Validate signed URL
-	if token == "" || expiresStr == "" {
-		return utils.Unauthorized(c, "Missing token or expires parameter")
+	if ok, err := utils.AuthorizeRequest(c, utils.ScopeDownload, jobID, filename); !ok {
+		return err
 	}
 
-	expires, err := utils.ParseExpires(expiresStr)
-	if err != nil {
-		return utils.BadRequest(c, utils.ErrInvalidExpires, "Invalid expires parameter")
+	// Read metadata to get actual output filename
+	meta, err := readJob(c, jobID)
+	if meta == nil {
+		return err
 	}
 
-	if !utils.ValidateSignedURL(jobID, filename, token, expires) {
-		return utils.Forbidden(c, "Invalid or expired download link")
+	if utils.IsDeleted(meta) {
+		return utils.JobGone(c, "Job has been deleted", meta.DeletedAt)
 	}
 
-	// Check if job exists
-	if !utils.JobExists(jobID) {
-		return utils.NotFound(c, utils.ErrJobNotFound, "Job not found")
+	// Check if job is completed
+	if meta.Status != models.StatusCompleted {
+		return utils.BadRequest(c, utils.ErrJobNotReady, "Job is not completed yet")
 	}
 
-	// Read metadata to get actual output filename
-	meta, err := utils.ReadMeta(jobID)
-	if err != nil {
-		return utils.InternalError(c, "Failed to read job metadata")
+	// Only declared outputs, even with a valid signature for another name
+	if !utils.IsServableFile(meta, filename) {
+		return utils.NotFound(c, utils.ErrFileNotFound, "File not found")
 	}
 
-	// Check if job is completed
+	return serveJobFile(c, jobID, meta, filename)
+}
+
+// HandleShareFile handles GET /d/:id/:name
+// @Summary Download output via share link
+// @Description Download the primary output of a completed job. The last segment is cosmetic (the title as filename) and is neither signed nor used to find the file; the token is that of the output's /files URL.
+// @Tags files
+// @Produce octet-stream
+// @Param id path string true "Job ID"
+// @Param name path string true "Any name (cosmetic)"
+// @Param t query string true "Compact signed token (shareUrl)"
+// @Param part query integer false "Manifest part index (serves only that part)"
+// @Param inline query boolean false "Serve with Content-Disposition: inline for in-browser playback"
+// @Success 200 {file} binary "Output file"
+// @Success 206 {file} binary "Requested part or range"
+// @Success 304 "Not modified (If-None-Match / If-Modified-Since)"
+// @Failure 400 {object} utils.ErrorResponse "Invalid parameters"
+// @Failure 401 {object} utils.ErrorResponse "Missing token"
+// @Failure 403 {object} utils.ErrorResponse "Invalid token"
+// @Failure 404 {object} utils.ErrorResponse "Not found"
+// @Failure 410 {object} utils.JobDeletedResponse "Job deleted"
+// @Failure 416 {object} utils.ErrorResponse "Range not satisfiable"
+// @Router /d/{id}/{name} [get]
+func HandleShareFile(c *fiber.Ctx) error {
+	jobID := c.Params("id")
+	if !utils.ValidateJobID(jobID) {
+		return utils.BadRequest(c, utils.ErrInvalidJobID, "Invalid job ID format")
+	}
+
+	// The token names the output it was signed for; the name segment plays no part
+	if c.Query("t") == "" {
+		return utils.Unauthorized(c, "Missing token")
+	}
+	filename := utils.TokenFile(c.Query("t"))
+	if !utils.ValidateFilename(filename) {
+		return utils.Forbidden(c, "Token is not valid for this resource")
+	}
+	if ok, err := utils.AuthorizeRequest(c, utils.ScopeDownload, jobID, filename); !ok {
+		return err
+	}
+
+	meta, err := readJob(c, jobID)
+	if meta == nil {
+		return err
+	}
+	if utils.IsDeleted(meta) {
+		return utils.JobGone(c, "Job has been deleted", meta.DeletedAt)
+	}
 	if meta.Status != models.StatusCompleted {
 		return utils.BadRequest(c, utils.ErrJobNotReady, "Job is not completed yet")
 	}
 
+	// Primary output only
+	if meta.Output == "" || filename != meta.Output {
+		return utils.NotFound(c, utils.ErrFileNotFound, "File not found")
+	}
+
+	return serveJobFile(c, jobID, meta, filename)
+}
+
+// serveJobFile sends a servable file of a completed job with its caching and download headers
+func serveJobFile(c *fiber.Ctx, jobID string, meta *models.Meta, filename string) error {
 	// Build file path
 	filePath := filepath.Join(utils.GetJobDir(jobID), filename)
 
@@ -82,21 +150,244 @@ func HandleFiles(c *fiber.Ctx) error {
 		return utils.NotFound(c, utils.ErrFileNotFound, "File not found")
 	}
 
+	// Conditional requests: the ETag changes whenever the file is rewritten (re-convert)
+	etag := fileETag(info)
+	lastModified := info.ModTime().UTC().Format(http.TimeFormat)
+	c.Set("ETag", etag)
+	c.Set("Last-Modified", lastModified)
+	c.Set("Cache-Control", fmt.Sprintf("private, max-age=%d", max(utils.AuthorizedExpiry(c)-time.Now().Unix(), 0)))
+	if notModified(c, etag, info.ModTime()) {
+		return c.SendStatus(fiber.StatusNotModified)
+	}
+
+	// If-Range that no longer matches: the client's partial copy is stale, send the whole file
+	if ifRange := c.Get(fiber.HeaderIfRange); ifRange != "" && ifRange != etag && ifRange != lastModified {
+		c.Request().Header.Del(fiber.HeaderRange)
+	}
+
 	// Get content type
 	ext := strings.TrimPrefix(filepath.Ext(filename), ".")
 	contentType := utils.ContentTypeFromExt(ext)
 
-	// Generate download filename
-	downloadFilename := utils.GenerateOutputFilename(meta)
-
-	// RFC 5987 encoding for non-ASCII characters
-	encodedFilename := url.PathEscape(downloadFilename)
+	// Generate download filename (additional outputs carry their own)
+	downloadFilename := utils.GetDisplayFilename(meta)
+	for _, output := range meta.Outputs {
+		if output.Name == filename && output.DisplayFilename != "" {
+			downloadFilename = output.DisplayFilename
+		}
+	}
+	// Transcripts are named after the output
+	if filename == config.TranscriptVTTName || filename == config.TranscriptTextName {
+		downloadFilename = strings.TrimSuffix(downloadFilename, filepath.Ext(downloadFilename)) + filepath.Ext(filename)
+	}
 
 	// Set headers
 	c.Set("Content-Type", contentType)
 	c.Set("Content-Length", fmt.Sprintf("%d", info.Size()))
-	c.Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"; filename*=UTF-8''%s`, downloadFilename, encodedFilename))
+	c.Set("Content-Disposition", utils.ContentDisposition(downloadFilename, c.QueryBool("inline")))
+	c.Set("Accept-Ranges", "bytes")
+
+	// Serve a single manifest part as a byte range
+	if partStr := c.Query("part"); partStr != "" {
+		part, err := strconv.Atoi(partStr)
+		if err != nil || filename != meta.Output || meta.Manifest == nil || part < 0 || part >= len(meta.Manifest.Parts) {
+			return fileError(c, fiber.StatusBadRequest, utils.ErrInvalidPart, "Invalid part index")
+		}
+		p := meta.Manifest.Parts[part]
+		c.Request().Header.Set(fiber.HeaderRange, fmt.Sprintf("bytes=%d-%d", p.Offset, p.Offset+p.Length-1))
+	}
+
+	// Stream file (bandwidth shaping per FILES_RATE_LIMIT_*)
+	return sendCountedFile(c, filePath, jobID, true)
+}
+
+// sendCountedFile streams a file (or the single byte range requested) and records the
+// bytes actually written in the job's usage (jobID "" = none), ranges and aborted transfers included.
+// Served explicitly rather than with SendFile, whose body stream can't be wrapped.
+func sendCountedFile(c *fiber.Ctx, filePath string, jobID string, throttle bool) error {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return fileError(c, fiber.StatusNotFound, utils.ErrFileNotFound, "File not found")
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fileError(c, fiber.StatusInternalServerError, utils.ErrInternalError, "Failed to read file")
+	}
+	size := info.Size()
+
+	start, end := int64(0), size-1
+	if rangeHeader := c.Get(fiber.HeaderRange); rangeHeader != "" && !strings.Contains(rangeHeader, ",") {
+		first, last, err := fasthttp.ParseByteRange([]byte(rangeHeader), int(size))
+		if err != nil {
+			file.Close()
+			c.Set(fiber.HeaderContentRange, fmt.Sprintf("bytes */%d", size))
+			return fileError(c, fiber.StatusRequestedRangeNotSatisfiable, utils.ErrRangeNotSatisfiable, fmt.Sprintf("Range not satisfiable for a %d-byte file", size))
+		}
+		start, end = int64(first), int64(last)
+		c.Status(fiber.StatusPartialContent)
+		c.Set(fiber.HeaderContentRange, fmt.Sprintf("bytes %d-%d/%d", start, end, size))
+	}
+
+	if _, err := file.Seek(start, io.SeekStart); err != nil {
+		file.Close()
+		return fileError(c, fiber.StatusInternalServerError, utils.ErrInternalError, "Failed to read file")
+	}
+
+	var body io.Reader = &fileBody{Reader: io.LimitReader(file, end-start+1), file: file}
+	if throttle {
+		body = throttleFileBody(c, body)
+	}
+	c.Response().SetBodyStream(&servedBytesReader{reader: body, jobID: jobID}, int(end-start+1))
+	return nil
+}
+
+// fileError sends a JSON error in place of a file response whose headers are already set
+// (the file's disposition, length and validators don't describe the error body)
+func fileError(c *fiber.Ctx, status int, code string, message string) error {
+	for _, header := range []string{fiber.HeaderContentDisposition, fiber.HeaderContentLength, fiber.HeaderETag, fiber.HeaderLastModified, fiber.HeaderAcceptRanges} {
+		c.Response().Header.Del(header)
+	}
+	c.Set(fiber.HeaderCacheControl, "no-store")
+	return utils.Error(c, status, code, message)
+}
+
+// fileBody reads a section of an open file and closes the file with the response
+type fileBody struct {
+	io.Reader
+	file *os.File
+}
+
+func (b *fileBody) Close() error {
+	return b.file.Close()
+}
+
+// throttleFileBody applies the configured per-connection and per-IP bandwidth limits
+// Clients with an exempt API key are not limited.
+func throttleFileBody(c *fiber.Ctx, body io.Reader) io.Reader {
+	if config.FilesRateLimitConn <= 0 && config.FilesRateLimitIP <= 0 {
+		return body
+	}
+	if slices.Contains(config.FilesRateLimitExemptKeys, c.Get("X-API-Key")) {
+		return body
+	}
+
+	throttled := &utils.ThrottledReader{Reader: body}
+	if config.FilesRateLimitConn > 0 {
+		throttled.Limiters = append(throttled.Limiters, utils.NewRateLimiter(config.FilesRateLimitConn))
+	}
+	if config.FilesRateLimitIP > 0 {
+		limiter, release := utils.AcquireIPLimiter(c.IP(), config.FilesRateLimitIP)
+		throttled.Limiters = append(throttled.Limiters, limiter)
+		throttled.OnClose = release
+	}
+	return throttled
+}
+
+// fileETag derives a strong ETag from size and modification time
+func fileETag(info os.FileInfo) string {
+	return fmt.Sprintf(`"%x-%x"`, info.Size(), info.ModTime().UnixNano())
+}
+
+// notModified evaluates If-None-Match, or If-Modified-Since when no If-None-Match is sent
+func notModified(c *fiber.Ctx, etag string, modTime time.Time) bool {
+	if ifNoneMatch := c.Get(fiber.HeaderIfNoneMatch); ifNoneMatch != "" {
+		for _, tag := range strings.Split(ifNoneMatch, ",") {
+			tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+			if tag == "*" || tag == etag {
+				return true
+			}
+		}
+		return false
+	}
+	if since, err := http.ParseTime(c.Get(fiber.HeaderIfModifiedSince)); err == nil {
+		return !modTime.Truncate(time.Second).After(since)
+	}
+	return false
+}
+
+// servedBytesReader counts bytes read by the response writer and records them on close
+type servedBytesReader struct {
+	reader io.Reader
+	jobID  string
+	n      int64
+}
+
+func (r *servedBytesReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	r.n += int64(n)
+	return n, err
+}
+
+func (r *servedBytesReader) Close() error {
+	if r.jobID != "" {
+		utils.AddMetaServedBytes(r.jobID, r.n)
+	}
+	if closer, ok := r.reader.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// HandleFileManifest handles GET /files/:id/:filename/manifest
+// @Summary Get resumable download manifest
+// @Description List fixed-size parts of the output file with SHA-256 hashes. Fetch each part with ?part=N (or a Range header) and verify before concatenating.
+// @Tags files
+// @Produce json
+// @Param id path string true "Job ID"
+// @Param filename path string true "Output filename"
+// @Param t query string false "Compact signed token"
+// @Param token query string false "Legacy signed URL token (deprecated)"
+// @Param expires query integer false "Legacy expiration timestamp (deprecated)"
+// @Success 200 {object} models.ManifestResponse
+// @Failure 400 {object} utils.ErrorResponse "Invalid parameters"
+// @Failure 401 {object} utils.ErrorResponse "Missing auth"
+// @Failure 403 {object} utils.ErrorResponse "Invalid token"
+// @Failure 404 {object} utils.ErrorResponse "Not found"
+// @Failure 410 {object} utils.JobDeletedResponse "Job deleted"
+// @Router /files/{id}/{filename}/manifest [get]
+func HandleFileManifest(c *fiber.Ctx) error {
+	jobID := c.Params("id")
+	filename := c.Params("filename")
+
+	// Validate job ID
+	if !utils.ValidateJobID(jobID) {
+		return utils.BadRequest(c, utils.ErrInvalidJobID, "Invalid job ID format")
+	}
+
+	// Validate filename (prevent path traversal)
+	if !utils.ValidateFilename(filename) {
+		return utils.BadRequest(c, utils.ErrInvalidFilename, "Invalid filename")
+	}
+
+	// Validate signed URL (manifest uses the same token as the file)
+	if ok, err := utils.AuthorizeRequest(c, utils.ScopeDownload, jobID, filename); !ok {
+		return err
+	}
+
+	meta, err := readJob(c, jobID)
+	if meta == nil {
+		return err
+	}
+
+	if utils.IsDeleted(meta) {
+		return utils.JobGone(c, "Job has been deleted", meta.DeletedAt)
+	}
+
+	if meta.Status != models.StatusCompleted {
+		return utils.BadRequest(c, utils.ErrJobNotReady, "Job is not completed yet")
+	}
+
+	// Manifest only covers the merged output file
+	if filename != meta.Output || meta.Manifest == nil {
+		return utils.NotFound(c, utils.ErrFileNotFound, "Manifest not available")
+	}
 
-	// Stream file
-	return c.SendFile(filePath)
+	return c.JSON(models.ManifestResponse{
+		Filename: filename,
+		Size:     meta.Manifest.Size,
+		PartSize: meta.Manifest.PartSize,
+		SHA256:   meta.Manifest.SHA256,
+		Parts:    meta.Manifest.Parts,
+	})
 }
@@ -0,0 +1,330 @@
+package handlers
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+	"yt-downloader-go/config"
+	"yt-downloader-go/models"
+	"yt-downloader-go/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const testJobID = "V1StGXR8_Z5jdHi6B-myT"
+
+// useTempStorage points StorageDir at a temp dir for the duration of the test
+func useTempStorage(t *testing.T) {
+	t.Helper()
+	prev := config.StorageDir
+	config.SetStorageDir(t.TempDir())
+	t.Cleanup(func() { config.SetStorageDir(prev) })
+}
+
+// createCompletedJob writes a completed audio job whose output.mp3 holds content
+func createCompletedJob(t *testing.T, jobID string, content string) *models.Meta {
+	t.Helper()
+	if err := os.MkdirAll(utils.GetJobDir(jobID), 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeOutput(t, jobID, content, time.Now().Add(-time.Minute))
+
+	now := time.Now()
+	meta := &models.Meta{
+		ID:         jobID,
+		Status:     models.StatusCompleted,
+		CreatedAt:  now.UnixMilli(),
+		ExpiresAt:  now.Add(config.MaxJobAge).UnixMilli(),
+		Title:      "Song",
+		OutputType: "audio",
+		Format:     "mp3",
+		Bitrate:    "192k",
+		Output:     "output.mp3",
+	}
+	if err := utils.WriteMeta(jobID, meta); err != nil {
+		t.Fatal(err)
+	}
+	return meta
+}
+
+// writeOutput (re)writes the job's output.mp3 with a modification time
+func writeOutput(t *testing.T, jobID string, content string, modTime time.Time) {
+	t.Helper()
+	path := filepath.Join(utils.GetJobDir(jobID), "output.mp3")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// newFilesApp routes /files and /d like main.go
+func newFilesApp() *fiber.App {
+	app := fiber.New()
+	app.Get("/files/:id/:filename", SecurityHeaders, HandleFiles)
+	app.Get("/d/:id/:name", SecurityHeaders, HandleShareFile)
+	return app
+}
+
+// get requests the path of a signed URL with headers and returns the response and its body
+func get(t *testing.T, app *fiber.App, signedURL string, headers map[string]string) (*http.Response, string) {
+	t.Helper()
+	req := httptest.NewRequest("GET", strings.TrimPrefix(signedURL, config.PublicURL), nil)
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatalf("request: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	return resp, string(body)
+}
+
+func TestFilesConditionalRequests(t *testing.T) {
+	useTempStorage(t)
+	createCompletedJob(t, testJobID, "first output")
+	app := newFilesApp()
+	url := utils.GenerateSignedURL(testJobID, "output.mp3", "")
+
+	resp, body := get(t, app, url, nil)
+	if resp.StatusCode != fiber.StatusOK || body != "first output" {
+		t.Fatalf("first download: %d %q", resp.StatusCode, body)
+	}
+	etag := resp.Header.Get("ETag")
+	lastModified := resp.Header.Get("Last-Modified")
+	if etag == "" || lastModified == "" {
+		t.Fatalf("missing validators: ETag %q, Last-Modified %q", etag, lastModified)
+	}
+	if cc := resp.Header.Get("Cache-Control"); !strings.HasPrefix(cc, "private, max-age=") || cc == "private, max-age=0" {
+		t.Errorf("Cache-Control = %q, want private with the remaining URL lifetime", cc)
+	}
+
+	t.Run("if-none-match", func(t *testing.T) {
+		resp, body := get(t, app, url, map[string]string{"If-None-Match": etag})
+		if resp.StatusCode != fiber.StatusNotModified || body != "" {
+			t.Errorf("got %d %q, want 304 without a body", resp.StatusCode, body)
+		}
+		if resp.Header.Get("ETag") != etag {
+			t.Errorf("304 ETag = %q, want %q", resp.Header.Get("ETag"), etag)
+		}
+	})
+
+	t.Run("weak if-none-match in a list", func(t *testing.T) {
+		resp, _ := get(t, app, url, map[string]string{"If-None-Match": `"other", W/` + etag})
+		if resp.StatusCode != fiber.StatusNotModified {
+			t.Errorf("got %d, want 304", resp.StatusCode)
+		}
+	})
+
+	t.Run("if-modified-since", func(t *testing.T) {
+		resp, _ := get(t, app, url, map[string]string{"If-Modified-Since": lastModified})
+		if resp.StatusCode != fiber.StatusNotModified {
+			t.Errorf("got %d, want 304", resp.StatusCode)
+		}
+	})
+
+	t.Run("if-none-match wins over if-modified-since", func(t *testing.T) {
+		resp, _ := get(t, app, url, map[string]string{"If-None-Match": `"other"`, "If-Modified-Since": lastModified})
+		if resp.StatusCode != fiber.StatusOK {
+			t.Errorf("got %d, want 200", resp.StatusCode)
+		}
+	})
+
+	t.Run("if-range matching", func(t *testing.T) {
+		resp, body := get(t, app, url, map[string]string{"Range": "bytes=0-4", "If-Range": etag})
+		if resp.StatusCode != fiber.StatusPartialContent || body != "first" {
+			t.Errorf("got %d %q, want 206 \"first\"", resp.StatusCode, body)
+		}
+		if cr := resp.Header.Get("Content-Range"); cr != "bytes 0-4/12" {
+			t.Errorf("Content-Range = %q", cr)
+		}
+	})
+
+	t.Run("if-range by date", func(t *testing.T) {
+		resp, body := get(t, app, url, map[string]string{"Range": "bytes=6-", "If-Range": lastModified})
+		if resp.StatusCode != fiber.StatusPartialContent || body != "output" {
+			t.Errorf("got %d %q, want 206 \"output\"", resp.StatusCode, body)
+		}
+	})
+
+	// A re-convert rewrites the output: old validators no longer match
+	writeOutput(t, testJobID, "second, longer output", time.Now())
+
+	t.Run("changed file after if-none-match", func(t *testing.T) {
+		resp, body := get(t, app, url, map[string]string{"If-None-Match": etag})
+		if resp.StatusCode != fiber.StatusOK || body != "second, longer output" {
+			t.Fatalf("got %d %q, want 200 with the new output", resp.StatusCode, body)
+		}
+		if newETag := resp.Header.Get("ETag"); newETag == etag {
+			t.Errorf("ETag %q unchanged after the file changed", newETag)
+		}
+	})
+
+	t.Run("changed file after if-modified-since", func(t *testing.T) {
+		resp, _ := get(t, app, url, map[string]string{"If-Modified-Since": lastModified})
+		if resp.StatusCode != fiber.StatusOK {
+			t.Errorf("got %d, want 200", resp.StatusCode)
+		}
+	})
+
+	t.Run("stale if-range sends the whole file", func(t *testing.T) {
+		resp, body := get(t, app, url, map[string]string{"Range": "bytes=0-4", "If-Range": etag})
+		if resp.StatusCode != fiber.StatusOK || body != "second, longer output" {
+			t.Errorf("got %d %q, want 200 with the whole new output", resp.StatusCode, body)
+		}
+		if cr := resp.Header.Get("Content-Range"); cr != "" {
+			t.Errorf("Content-Range %q on a full response", cr)
+		}
+	})
+}
+
+func TestFilesRateLimit(t *testing.T) {
+	const size, rate = 96 * 1024, 160 * 1024
+	useTempStorage(t)
+	createCompletedJob(t, testJobID, strings.Repeat("x", size))
+	app := newFilesApp()
+	url := utils.GenerateSignedURL(testJobID, "output.mp3", "")
+
+	prevConn, prevExempt := config.FilesRateLimitConn, config.FilesRateLimitExemptKeys
+	config.FilesRateLimitConn, config.FilesRateLimitExemptKeys = rate, []string{"exempt-key"}
+	t.Cleanup(func() { config.FilesRateLimitConn, config.FilesRateLimitExemptKeys = prevConn, prevExempt })
+
+	// Every byte but the last 16KB read is paced before the transfer can end
+	minDuration := time.Duration(int64(size-16*1024) * int64(time.Second) / rate)
+
+	download := func(apiKey string) time.Duration {
+		req := httptest.NewRequest("GET", strings.TrimPrefix(url, config.PublicURL), nil)
+		if apiKey != "" {
+			req.Header.Set("X-API-Key", apiKey)
+		}
+		start := time.Now()
+		resp, err := app.Test(req, -1)
+		if err != nil {
+			t.Fatalf("request: %v", err)
+		}
+		n, _ := io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != fiber.StatusOK || n != size {
+			t.Fatalf("got %d with %d bytes, want 200 with %d", resp.StatusCode, n, size)
+		}
+		return time.Since(start)
+	}
+
+	if elapsed := download(""); elapsed < minDuration {
+		t.Errorf("limited download took %v, want at least %v", elapsed, minDuration)
+	}
+	if elapsed := download("exempt-key"); elapsed >= minDuration {
+		t.Errorf("exempt download took %v, want it unthrottled", elapsed)
+	}
+}
+
+// shareToken returns the token of a /d link signed for filename
+func shareToken(t *testing.T, jobID string, filename string) string {
+	t.Helper()
+	shareURL, err := url.Parse(utils.GenerateShareURL(jobID, filename, "Song", ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return shareURL.Query().Get("t")
+}
+
+func TestShareFileIgnoresNameSegment(t *testing.T) {
+	useTempStorage(t)
+	meta := createCompletedJob(t, testJobID, "output")
+	app := newFilesApp()
+
+	// What a traversal would reach: the job's meta.json and a file outside the job directory
+	if err := os.WriteFile(filepath.Join(config.StorageDir, "secret.txt"), []byte("secret"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	token := shareToken(t, testJobID, "output.mp3")
+	disposition := utils.ContentDisposition(utils.GetDisplayFilename(meta), false)
+	for _, name := range []string{
+		"Song.mp3",
+		"meta.json",
+		"..%2Fsecret.txt",
+		"..%2F..%2Fsecret.txt",
+		"%2E%2E%2F%2E%2E%2Fsecret.txt",
+		"..%5C..%5Csecret.txt",
+		"%2Fetc%2Fpasswd",
+		"output.mp3%00.txt",
+	} {
+		t.Run(name, func(t *testing.T) {
+			resp, body := get(t, app, "/d/"+testJobID+"/"+name+"?t="+token, nil)
+			if resp.StatusCode != fiber.StatusOK || body != "output" {
+				t.Fatalf("got %d %q, want 200 with the job output", resp.StatusCode, body)
+			}
+			if got := resp.Header.Get(fiber.HeaderContentDisposition); got != disposition {
+				t.Errorf("Content-Disposition = %q, want %q", got, disposition)
+			}
+		})
+	}
+
+	// Only the token picks the file, and only the primary output
+	for file, want := range map[string]int{
+		"../secret.txt":      fiber.StatusForbidden,
+		"..\\secret.txt":     fiber.StatusForbidden,
+		"output.mp3/../meta": fiber.StatusForbidden,
+		"meta.json":          fiber.StatusNotFound,
+	} {
+		t.Run("token for "+file, func(t *testing.T) {
+			resp, body := get(t, app, "/d/"+testJobID+"/Song.mp3?t="+shareToken(t, testJobID, file), nil)
+			if resp.StatusCode != want {
+				t.Errorf("got %d %q, want %d", resp.StatusCode, body, want)
+			}
+		})
+	}
+}
+
+func TestFilesRefusesInternalFiles(t *testing.T) {
+	useTempStorage(t)
+	meta := createCompletedJob(t, testJobID, "output")
+	meta.Outputs = []models.ExtraOutput{{Name: "output_2.flac", Status: models.StatusProcessing}}
+	meta.Transcript = &models.Transcript{URL: "https://captions.example/en", Available: false}
+	if err := utils.WriteMeta(testJobID, meta); err != nil {
+		t.Fatal(err)
+	}
+	app := newFilesApp()
+
+	// Everything but output.mp3 exists in the job directory, and each URL is correctly signed
+	internal := []string{
+		"meta.json",
+		"run.lock",
+		"audio.webm",
+		"audio.webm.tmp",
+		"audio.webm.chunks",
+		"output.mp3.part",
+		"output_2.flac",
+		config.TranscriptVTTName,
+		"job.log",
+	}
+	jobDir := utils.GetJobDir(testJobID)
+	for _, name := range internal[1:] {
+		if err := os.WriteFile(filepath.Join(jobDir, name), []byte("internal"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, name := range internal {
+		t.Run(name, func(t *testing.T) {
+			resp, body := get(t, app, utils.GenerateSignedURL(testJobID, name, ""), nil)
+			if resp.StatusCode != fiber.StatusNotFound {
+				t.Errorf("got %d %q, want 404", resp.StatusCode, body)
+			}
+		})
+	}
+
+	if resp, body := get(t, app, utils.GenerateSignedURL(testJobID, "output.mp3", ""), nil); resp.StatusCode != fiber.StatusOK || body != "output" {
+		t.Errorf("output.mp3: got %d %q, want 200 with the output", resp.StatusCode, body)
+	}
+}
@@ -0,0 +1,274 @@
+package handlers
+
+import (
+	"fmt"
+	"os"
+	"time"
+	"yt-downloader-go/config"
+	"yt-downloader-go/models"
+	"yt-downloader-go/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// defaultGroupName names the archive of a group created without group.name
+const defaultGroupName = "videos"
+
+// HandleCreateGroup handles POST /api/groups
+// @Summary Create job group
+// @Description Group existing jobs. With group.archive, the primary outputs of the members are zipped once all have finished; members without one are listed in the archive's manifest.json. The group is removed with its last member.
+// @Tags groups
+// @Accept json
+// @Produce json
+// @Param request body models.GroupRequest true "Member jobs, each with its status token"
+// @Success 201 {object} models.GroupResponse
+// @Failure 400 {object} utils.ErrorResponse "Validation error"
+// @Failure 403 {object} utils.ErrorResponse "Invalid member token"
+// @Failure 404 {object} utils.ErrorResponse "Member job not found"
+// @Failure 409 {object} utils.ErrorResponse "Member already in a group"
+// @Failure 410 {object} utils.JobDeletedResponse "Member job deleted"
+// @Failure 500 {object} utils.ErrorResponse "Server error"
+// @Router /api/groups [post]
+func HandleCreateGroup(c *fiber.Ctx) error {
+	var req models.GroupRequest
+	if err := parseJSONStrict(c, &req); err != nil {
+		return utils.BadRequest(c, utils.ErrInvalidRequest, "Invalid request body: "+err.Error())
+	}
+
+	if len(req.Jobs) == 0 || len(req.Jobs) > config.MaxGroupJobs {
+		return utils.BadRequest(c, utils.ErrValidationError, fmt.Sprintf("jobs: must list 1 to %d jobs", config.MaxGroupJobs))
+	}
+	name := utils.SanitizeFilename(req.Group.Name)
+	if name == "" {
+		name = defaultGroupName
+	}
+
+	seen := map[string]bool{}
+	for _, member := range req.Jobs {
+		if !utils.ValidateJobID(member.ID) {
+			return utils.BadRequest(c, utils.ErrInvalidJobID, "Invalid job ID format: "+member.ID)
+		}
+		if seen[member.ID] {
+			return utils.BadRequest(c, utils.ErrValidationError, "jobs: duplicate job "+member.ID)
+		}
+		seen[member.ID] = true
+
+		// The member's status token proves the caller may see the job
+		if !utils.ValidToken(c, member.T, utils.ScopeStatus, member.ID, "") {
+			return utils.Forbidden(c, "Invalid or expired token for job "+member.ID)
+		}
+		meta, err := utils.ReadMeta(member.ID)
+		if err != nil {
+			return utils.NotFound(c, utils.ErrJobNotFound, "Job not found: "+member.ID)
+		}
+		if utils.IsDeleted(meta) {
+			return utils.JobGone(c, "Job has been deleted: "+member.ID, meta.DeletedAt)
+		}
+	}
+
+	now := time.Now()
+	group := &models.Group{
+		ID:        generateID(),
+		Name:      name,
+		CreatedAt: now.UnixMilli(),
+	}
+	for _, member := range req.Jobs {
+		group.JobIDs = append(group.JobIDs, member.ID)
+	}
+	if req.Group.Archive {
+		group.Archive = &models.GroupArchive{Status: models.StatusPending}
+	}
+	if config.SignedURLBindClient {
+		group.Binding = utils.ClientBinding(utils.BindIP, c.IP())
+	}
+	group.ExpiresAt = utils.GroupExpiresAt(group).UnixMilli()
+
+	// Like job IDs, a new ID when its directory exists (case-insensitive filesystems)
+	for attempt := 1; utils.GroupExists(group.ID) && attempt < maxIDAttempts; attempt++ {
+		group.ID = generateID()
+	}
+	if utils.GroupExists(group.ID) {
+		return utils.InternalError(c, "Failed to create group")
+	}
+
+	if err := utils.WriteGroup(group); err != nil {
+		return utils.InternalError(c, "Failed to create group")
+	}
+
+	// Claim the members; a job joins one group at most
+	for i, jobID := range group.JobIDs {
+		claimed, err := utils.ClaimGroupMember(jobID, group.ID)
+		if err == nil && claimed {
+			continue
+		}
+		group.JobIDs = group.JobIDs[:i]
+		utils.WriteGroup(group)
+		utils.DeleteGroup(group.ID, false)
+		if err != nil {
+			return utils.InternalError(c, "Failed to create group")
+		}
+		return utils.Error(c, fiber.StatusConflict, utils.ErrJobInGroup, "Job already belongs to a group: "+jobID)
+	}
+
+	// Members may all have finished already
+	if group.Archive != nil {
+		go func() {
+			defer utils.RecoverPanic("group archive")
+			utils.CheckGroupArchive(group.ID)
+		}()
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(models.GroupResponse{
+		GroupID:   group.ID,
+		StatusURL: utils.GenerateGroupStatusURL(group.ID),
+		ExpiresAt: group.ExpiresAt,
+	})
+}
+
+// HandleGroupStatus handles GET /api/groups/:id
+// @Summary Get job group status
+// @Description Member statuses and, with group.archive, the archive and its signed download URL once built
+// @Tags groups
+// @Produce json
+// @Param id path string true "Group ID"
+// @Param t query string true "Compact signed token (statusUrl)"
+// @Success 200 {object} models.GroupStatusResponse
+// @Failure 400 {object} utils.ErrorResponse "Invalid group ID"
+// @Failure 401 {object} utils.ErrorResponse "Missing token"
+// @Failure 403 {object} utils.ErrorResponse "Invalid or expired token"
+// @Failure 404 {object} utils.ErrorResponse "Group not found"
+// @Failure 500 {object} utils.ErrorResponse "Server error"
+// @Router /api/groups/{id} [get]
+func HandleGroupStatus(c *fiber.Ctx) error {
+	group, err := authorizeGroup(c, utils.ScopeStatus, "")
+	if group == nil {
+		return err
+	}
+
+	response := models.GroupStatusResponse{
+		ID:        group.ID,
+		Name:      group.Name,
+		Status:    models.StatusCompleted,
+		Members:   []models.GroupMemberStatus{},
+		ExpiresAt: utils.GroupExpiresAt(group).UnixMilli(),
+	}
+	for _, jobID := range group.JobIDs {
+		state, meta := utils.GroupMemberState(jobID)
+		member := models.GroupMemberStatus{JobID: jobID, Status: state}
+		if state == models.StatusError {
+			member.Error = meta.Error
+		}
+		if state == models.StatusPending || state == models.StatusProcessing {
+			response.Status = models.StatusPending
+		}
+		response.Members = append(response.Members, member)
+	}
+
+	if archive := group.Archive; archive != nil {
+		response.Archive = &models.GroupArchiveStatus{
+			Status: archive.Status,
+			Files:  archive.Files,
+			Size:   archive.Size,
+			Error:  archive.Error,
+		}
+		if archive.Status == models.StatusCompleted {
+			response.Archive.DownloadURL = utils.GenerateGroupArchiveURL(group.ID, group.Binding)
+		}
+	}
+
+	return c.JSON(response)
+}
+
+// HandleDeleteGroup handles DELETE /api/groups/:id
+// @Summary Delete job group
+// @Description Remove a group and its archive. With members=true the member jobs are soft-deleted too (restorable like DELETE /api/jobs/:id); otherwise they leave the group.
+// @Tags groups
+// @Produce json
+// @Param id path string true "Group ID"
+// @Param t query string true "Compact signed token (statusUrl)"
+// @Param members query boolean false "Also delete the member jobs"
+// @Success 200 {object} models.GroupDeleteResponse
+// @Failure 400 {object} utils.ErrorResponse "Invalid group ID"
+// @Failure 401 {object} utils.ErrorResponse "Missing token"
+// @Failure 403 {object} utils.ErrorResponse "Invalid or expired token"
+// @Failure 404 {object} utils.ErrorResponse "Group not found"
+// @Failure 500 {object} utils.ErrorResponse "Delete failed"
+// @Router /api/groups/{id} [delete]
+func HandleDeleteGroup(c *fiber.Ctx) error {
+	group, err := authorizeGroup(c, utils.ScopeStatus, "")
+	if group == nil {
+		return err
+	}
+
+	deleted, err := utils.DeleteGroup(group.ID, c.QueryBool("members"))
+	if err != nil {
+		return utils.InternalError(c, "Failed to delete group")
+	}
+
+	return c.JSON(models.GroupDeleteResponse{
+		Deleted:        true,
+		MembersDeleted: deleted,
+	})
+}
+
+// HandleGroupArchive handles GET /groups/:id/archive
+// @Summary Download job group archive
+// @Description Zip of the members' outputs with manifest.json
+// @Tags groups
+// @Produce application/zip
+// @Param id path string true "Group ID"
+// @Param t query string true "Compact signed token (archive downloadUrl)"
+// @Success 200 {file} binary "Archive"
+// @Success 206 {file} binary "Requested range"
+// @Failure 400 {object} utils.ErrorResponse "Invalid group ID or archive not built"
+// @Failure 401 {object} utils.ErrorResponse "Missing token"
+// @Failure 403 {object} utils.ErrorResponse "Invalid or expired token"
+// @Failure 404 {object} utils.ErrorResponse "Group not found"
+// @Failure 416 {object} utils.ErrorResponse "Range not satisfiable"
+// @Router /groups/{id}/archive [get]
+func HandleGroupArchive(c *fiber.Ctx) error {
+	group, err := authorizeGroup(c, utils.ScopeDownload, config.GroupArchiveName)
+	if group == nil {
+		return err
+	}
+
+	if group.Archive == nil || group.Archive.Status != models.StatusCompleted {
+		return utils.BadRequest(c, utils.ErrArchiveNotReady, "Group archive is not ready")
+	}
+
+	archivePath := utils.GetGroupArchivePath(group.ID)
+	info, err := os.Stat(archivePath)
+	if err != nil {
+		return utils.NotFound(c, utils.ErrFileNotFound, "File not found")
+	}
+
+	c.Set("ETag", fileETag(info))
+	c.Set("Cache-Control", fmt.Sprintf("private, max-age=%d", max(utils.AuthorizedExpiry(c)-time.Now().Unix(), 0)))
+	c.Set("Content-Type", "application/zip")
+	c.Set("Content-Disposition", utils.ContentDisposition(group.Name+".zip", false))
+	c.Set("Accept-Ranges", "bytes")
+
+	// Bandwidth shaping like /files; usage is recorded per job, not per group
+	return sendCountedFile(c, archivePath, "", true)
+}
+
+// authorizeGroup validates the group ID and token of a group request and reads the group
+// When it returns nil the error response has already been written; return err.
+func authorizeGroup(c *fiber.Ctx, scope string, filename string) (*models.Group, error) {
+	groupID := c.Params("id")
+	if !utils.ValidateJobID(groupID) {
+		return nil, utils.BadRequest(c, utils.ErrInvalidJobID, "Invalid group ID format")
+	}
+	if ok, err := utils.AuthorizeRequest(c, scope, groupID, filename); !ok {
+		return nil, err
+	}
+
+	group, err := utils.ReadGroup(groupID)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, utils.NotFound(c, utils.ErrGroupNotFound, "Group not found")
+		}
+		return nil, utils.InternalError(c, "Failed to read group")
+	}
+	return group, nil
+}
@@ -2,21 +2,38 @@ package handlers
 
 import (
 	"time"
+	"yt-downloader-go/config"
 	"yt-downloader-go/models"
+	"yt-downloader-go/services"
+	"yt-downloader-go/utils"
 
 	"github.com/gofiber/fiber/v2"
 )
 
 // HandleHealth handles GET /health
 // @Summary Health check
-// @Description Check if the server is running
+// @Description Check if the server is running; deep=true adds dependency state
 // @Tags health
 // @Produce json
+// @Param deep query boolean false "Include the extract API cool-down and storage state"
 // @Success 200 {object} models.HealthResponse
 // @Router /health [get]
 func HandleHealth(c *fiber.Ctx) error {
-	return c.JSON(models.HealthResponse{
+	response := models.HealthResponse{
 		Status:    "ok",
 		Timestamp: time.Now().UnixMilli(),
-	})
+	}
+	if c.QueryBool("deep") {
+		circuit := services.ExtractCircuit()
+		response.Extract = &circuit
+
+		free, _ := utils.StorageFreeBytes()
+		response.Storage = &models.StorageState{
+			Full:       utils.StorageFull(),
+			FreeBytes:  free,
+			UsedBytes:  utils.StorageUsedBytes(),
+			QuotaBytes: config.StorageQuota,
+		}
+	}
+	return c.JSON(response)
 }
@@ -0,0 +1,183 @@
+package handlers
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+	"yt-downloader-go/config"
+	"yt-downloader-go/models"
+	"yt-downloader-go/services"
+	"yt-downloader-go/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// hlsURIAttrPattern matches URI attributes in playlist tags (e.g. #EXT-X-MAP:URI="init.mp4")
+var hlsURIAttrPattern = regexp.MustCompile(`URI="([^"]+)"`)
+
+// HandleHLSMaster handles GET /stream/:id/master.m3u8
+// @Summary HLS preview playlist
+// @Description Master playlist for in-browser playback with seeking. Segments are generated on first request and reused; all URIs in the playlist are signed.
+// @Tags stream
+// @Produce application/vnd.apple.mpegurl
+// @Param id path string true "Job ID"
+// @Param t query string false "Compact signed token (same as /stream)"
+// @Param token query string false "Legacy signed URL token (deprecated)"
+// @Param expires query integer false "Legacy expiration timestamp (deprecated)"
+// @Success 200 {string} string "Master playlist"
+// @Failure 400 {object} utils.ErrorResponse "Invalid parameters"
+// @Failure 401 {object} utils.ErrorResponse "Missing auth"
+// @Failure 403 {object} utils.ErrorResponse "Invalid token"
+// @Failure 404 {object} utils.ErrorResponse "Not found"
+// @Failure 410 {object} utils.JobDeletedResponse "Job deleted"
+// @Failure 500 {object} utils.ErrorResponse "Generation failed"
+// @Failure 503 {object} utils.ErrorResponse "Still generating, retry after Retry-After seconds"
+// @Router /stream/{id}/master.m3u8 [get]
+func HandleHLSMaster(c *fiber.Ctx) error {
+	jobID := c.Params("id")
+
+	// Validate job ID
+	if !utils.ValidateJobID(jobID) {
+		return utils.BadRequest(c, utils.ErrInvalidJobID, "Invalid job ID format")
+	}
+
+	// Validate signed URL (same token as /stream)
+	if ok, err := utils.AuthorizeRequest(c, utils.ScopeDownload, jobID, ""); !ok {
+		return err
+	}
+
+	meta, err := readReadyJob(c, jobID)
+	if meta == nil {
+		return err
+	}
+
+	if !services.HLSReady(jobID) {
+		select {
+		case result := <-services.GenerateHLS(meta):
+			if result.Err != nil {
+				return utils.InternalError(c, "Failed to generate HLS")
+			}
+		case <-time.After(config.HLSWaitTimeout):
+			c.Set(fiber.HeaderRetryAfter, "5")
+			return utils.Error(c, fiber.StatusServiceUnavailable, utils.ErrHLSGenerating, "HLS is being generated, retry shortly")
+		}
+	}
+
+	return serveHLSPlaylist(c, meta, services.HLSMasterPlaylist)
+}
+
+// HandleHLSFile handles GET /stream/:id/hls/:filename
+// @Summary HLS playlist or segment
+// @Description Media playlist, init segment or media segment referenced by the master playlist
+// @Tags stream
+// @Produce octet-stream
+// @Param id path string true "Job ID"
+// @Param filename path string true "Playlist or segment name"
+// @Param t query string true "Compact signed token (from the playlist)"
+// @Success 200 {file} binary "Playlist or segment"
+// @Failure 400 {object} utils.ErrorResponse "Invalid parameters"
+// @Failure 401 {object} utils.ErrorResponse "Missing auth"
+// @Failure 403 {object} utils.ErrorResponse "Invalid token"
+// @Failure 404 {object} utils.ErrorResponse "Not found"
+// @Failure 410 {object} utils.JobDeletedResponse "Job deleted"
+// @Router /stream/{id}/hls/{filename} [get]
+func HandleHLSFile(c *fiber.Ctx) error {
+	jobID := c.Params("id")
+	filename := c.Params("filename")
+
+	// Validate job ID
+	if !utils.ValidateJobID(jobID) {
+		return utils.BadRequest(c, utils.ErrInvalidJobID, "Invalid job ID format")
+	}
+
+	// Validate filename (prevent path traversal)
+	if !utils.ValidateFilename(filename) {
+		return utils.BadRequest(c, utils.ErrInvalidFilename, "Invalid filename")
+	}
+
+	// Validate signed URL
+	if ok, err := utils.AuthorizeRequest(c, utils.ScopeDownload, jobID, config.HLSDirName+"/"+filename); !ok {
+		return err
+	}
+
+	meta, err := readReadyJob(c, jobID)
+	if meta == nil {
+		return err
+	}
+
+	if !services.HLSReady(jobID) {
+		return utils.NotFound(c, utils.ErrFileNotFound, "HLS not generated")
+	}
+
+	if strings.HasSuffix(filename, ".m3u8") {
+		return serveHLSPlaylist(c, meta, filename)
+	}
+
+	filePath := filepath.Join(services.GetHLSDir(jobID), filename)
+	if _, err := os.Stat(filePath); err != nil {
+		return utils.NotFound(c, utils.ErrFileNotFound, "File not found")
+	}
+
+	// Segments never change once generated
+	c.Set("Content-Type", utils.ContentTypeFromExt(strings.TrimPrefix(filepath.Ext(filename), ".")))
+	c.Set("Cache-Control", "private, max-age=3600")
+	return sendCountedFile(c, filePath, jobID, false)
+}
+
+// readReadyJob loads a completed, non-deleted job
+// When it returns nil the error response has already been written; return err.
+func readReadyJob(c *fiber.Ctx, jobID string) (*models.Meta, error) {
+	meta, err := readJob(c, jobID)
+	if meta == nil {
+		return nil, err
+	}
+
+	if utils.IsDeleted(meta) {
+		return nil, utils.JobGone(c, "Job has been deleted", meta.DeletedAt)
+	}
+
+	if meta.Status != models.StatusCompleted {
+		return nil, utils.BadRequest(c, utils.ErrJobNotReady, "Job is not ready for streaming")
+	}
+
+	return meta, nil
+}
+
+// serveHLSPlaylist serves a generated playlist with every URI replaced by a signed URL
+func serveHLSPlaylist(c *fiber.Ctx, meta *models.Meta, name string) error {
+	data, err := os.ReadFile(filepath.Join(services.GetHLSDir(meta.ID), name))
+	if err != nil {
+		return utils.NotFound(c, utils.ErrFileNotFound, "Playlist not found")
+	}
+
+	sign := func(uri string) string {
+		return utils.GenerateHLSURL(meta, path.Base(uri))
+	}
+
+	var out bytes.Buffer
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "":
+		case strings.HasPrefix(line, "#"):
+			line = hlsURIAttrPattern.ReplaceAllStringFunc(line, func(attr string) string {
+				return `URI="` + sign(hlsURIAttrPattern.FindStringSubmatch(attr)[1]) + `"`
+			})
+		default:
+			line = sign(line)
+		}
+		out.WriteString(line)
+		out.WriteByte('\n')
+	}
+
+	// Tokens in the body expire, so playlists must not be cached
+	c.Set("Content-Type", utils.ContentTypeFromExt("m3u8"))
+	c.Set("Cache-Control", "no-cache")
+	return c.Send(out.Bytes())
+}
@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"yt-downloader-go/config"
+	"yt-downloader-go/models"
+	"yt-downloader-go/services"
+	"yt-downloader-go/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// HandleVideoInfo handles GET /api/info/:videoId
+// @Summary Get video metadata
+// @Description Title, duration, channel, upload date, view count, thumbnail and audio tracks of a video. No job is created; the extract result is cached and shared with downloads.
+// @Tags download
+// @Produce json
+// @Param videoId path string true "YouTube video ID"
+// @Success 200 {object} models.VideoInfoResponse
+// @Failure 400 {object} utils.ErrorResponse "Invalid video ID"
+// @Failure 500 {object} utils.ErrorResponse "Failed to fetch video metadata"
+// @Failure 503 {object} utils.ErrorResponse "Metadata service rate limited"
+// @Router /api/info/{videoId} [get]
+func HandleVideoInfo(c *fiber.Ctx) error {
+	videoID, err := utils.ExtractVideoID(c.Params("videoId"))
+	if err != nil {
+		return utils.BadRequest(c, utils.ErrInvalidURL, "Invalid video ID")
+	}
+
+	ctx, cancel := context.WithTimeout(c.Context(), config.DownloadSyncTimeout)
+	defer cancel()
+
+	extractData, err := services.ExtractCached(ctx, videoID)
+	var rateLimited *services.RateLimitError
+	if errors.As(err, &rateLimited) {
+		c.Set(fiber.HeaderRetryAfter, strconv.Itoa(rateLimited.RetryAfterSeconds()))
+		return utils.Error(c, fiber.StatusServiceUnavailable, utils.ErrExtractLimited, "Video metadata service is rate limited, retry later")
+	}
+	if err != nil {
+		return utils.InternalError(c, "Failed to fetch video metadata")
+	}
+
+	return c.JSON(models.VideoInfoResponse{
+		VideoID:      videoID,
+		Title:        extractData.Title,
+		Duration:     extractData.Duration,
+		Author:       extractData.Author,
+		UploadDate:   utils.NormalizeUploadDate(extractData.UploadDate),
+		ViewCount:    int64(extractData.ViewCount),
+		ThumbnailURL: extractData.ThumbnailURL,
+		AudioTracks:  services.AudioTracks(extractData),
+	})
+}
@@ -1,6 +1,8 @@
 package handlers
 
 import (
+	"time"
+	"yt-downloader-go/config"
 	"yt-downloader-go/models"
 	"yt-downloader-go/utils"
 
@@ -9,13 +11,12 @@ import (
 
 // HandleDeleteJob handles DELETE /api/jobs/:id
 // @Summary Delete job
-// @Description Delete a job and its associated files
+// @Description Soft-delete a job. It is hidden immediately (410 Gone) and its files are removed after a grace period, during which it can be restored. Idempotent: deleting a deleted or removed job succeeds with alreadyDeleted.
 // @Tags jobs
 // @Produce json
 // @Param id path string true "Job ID"
 // @Success 200 {object} models.DeleteResponse
 // @Failure 400 {object} utils.ErrorResponse "Invalid job ID"
-// @Failure 404 {object} utils.ErrorResponse "Job not found"
 // @Failure 500 {object} utils.ErrorResponse "Delete failed"
 // @Router /api/jobs/{id} [delete]
 func HandleDeleteJob(c *fiber.Ctx) error {
@@ -26,17 +27,163 @@ func HandleDeleteJob(c *fiber.Ctx) error {
 		return utils.BadRequest(c, utils.ErrInvalidJobID, "Invalid job ID format")
 	}
 
-	// Check if job exists
-	if !utils.JobExists(jobID) {
-		return utils.NotFound(c, utils.ErrJobNotFound, "Job not found")
+	// Retries of a delete succeed: the job is gone either way
+	meta, err := utils.ReadMeta(jobID)
+	if err != nil {
+		if !utils.MetaMissing(err) {
+			return utils.InternalError(c, "Failed to read job metadata")
+		}
+		response := models.DeleteResponse{Deleted: true, AlreadyDeleted: true}
+		if tombstone := utils.ReadTombstone(jobID); tombstone != nil {
+			response.DeletedAt = tombstone.DeletedAt
+		}
+		return c.JSON(response)
+	}
+
+	if utils.IsDeleted(meta) {
+		response := models.DeleteResponse{Deleted: true, AlreadyDeleted: true, DeletedAt: meta.DeletedAt}
+		if restoreUntil := time.UnixMilli(meta.DeletedAt).Add(config.DeleteGracePeriod); time.Now().Before(restoreUntil) {
+			response.RestoreUntil = restoreUntil.UnixMilli()
+		}
+		return c.JSON(response)
 	}
 
-	// Delete job directory
-	if err := utils.DeleteJobDir(jobID); err != nil {
+	// Soft delete: cleanup removes the directory after the grace period
+	deletedAt := time.Now()
+	if err := utils.UpdateMetaDeleted(jobID, deletedAt.UnixMilli()); err != nil {
 		return utils.InternalError(c, "Failed to delete job")
 	}
 
 	return c.JSON(models.DeleteResponse{
-		Deleted: true,
+		Deleted:      true,
+		DeletedAt:    deletedAt.UnixMilli(),
+		RestoreUntil: deletedAt.Add(config.DeleteGracePeriod).UnixMilli(),
+	})
+}
+
+// HandleExtendJob handles POST /api/jobs/:id/extend
+// @Summary Extend job expiry
+// @Description Push the job's expiresAt to MaxJobAge from now, capped at MaxJobLifetime after creation
+// @Tags jobs
+// @Produce json
+// @Param id path string true "Job ID"
+// @Param t query string true "Compact signed status token"
+// @Success 200 {object} models.ExtendResponse
+// @Failure 400 {object} utils.ErrorResponse "Invalid job ID"
+// @Failure 401 {object} utils.ErrorResponse "Missing token"
+// @Failure 403 {object} utils.ErrorResponse "Invalid or expired token"
+// @Failure 404 {object} utils.ErrorResponse "Job not found"
+// @Failure 409 {object} utils.ErrorResponse "Job expired or extension cap reached"
+// @Failure 410 {object} utils.JobDeletedResponse "Job deleted"
+// @Failure 500 {object} utils.ErrorResponse "Extend failed"
+// @Router /api/jobs/{id}/extend [post]
+func HandleExtendJob(c *fiber.Ctx) error {
+	jobID := c.Params("id")
+
+	// Validate job ID
+	if !utils.ValidateJobID(jobID) {
+		return utils.BadRequest(c, utils.ErrInvalidJobID, "Invalid job ID format")
+	}
+
+	// Same token as the status URL
+	if ok, err := utils.AuthorizeRequest(c, utils.ScopeStatus, jobID, ""); !ok {
+		return err
+	}
+
+	var (
+		expiresAt, maxExpiresAt time.Time
+		deletedAt               int64
+		conflict                string
+	)
+	err := utils.UpdateMeta(jobID, func(meta *models.Meta) {
+		if utils.IsDeleted(meta) {
+			conflict = utils.ErrJobDeleted
+			deletedAt = meta.DeletedAt
+			return
+		}
+
+		now := time.Now()
+		expiresAt = utils.JobExpiresAt(meta)
+		maxExpiresAt = utils.JobMaxExpiresAt(meta)
+
+		// Past expiry the next cleanup pass may remove it at any moment
+		if now.After(expiresAt) {
+			conflict = utils.ErrJobExpired
+			return
+		}
+		if !expiresAt.Before(maxExpiresAt) {
+			conflict = utils.ErrExtendLimit
+			return
+		}
+
+		expiresAt = now.Add(config.MaxJobAge)
+		if expiresAt.After(maxExpiresAt) {
+			expiresAt = maxExpiresAt
+		}
+		meta.ExpiresAt = expiresAt.UnixMilli()
+	})
+	if err != nil {
+		if utils.MetaMissing(err) {
+			return jobMissing(c, jobID)
+		}
+		return utils.InternalError(c, "Failed to extend job")
+	}
+
+	switch conflict {
+	case utils.ErrJobDeleted:
+		return utils.JobGone(c, "Job has been deleted", deletedAt)
+	case utils.ErrJobExpired:
+		return utils.Error(c, fiber.StatusConflict, utils.ErrJobExpired, "Job has already expired")
+	case utils.ErrExtendLimit:
+		return utils.Error(c, fiber.StatusConflict, utils.ErrExtendLimit, "Job has reached its maximum lifetime")
+	}
+
+	return c.JSON(models.ExtendResponse{
+		ExpiresAt:    expiresAt.UnixMilli(),
+		MaxExpiresAt: maxExpiresAt.UnixMilli(),
+		StatusURL:    utils.GenerateStatusURL(jobID),
+	})
+}
+
+// HandleRestoreJob handles POST /api/jobs/:id/restore
+// @Summary Restore job
+// @Description Restore a soft-deleted job within the grace period
+// @Tags jobs
+// @Produce json
+// @Param id path string true "Job ID"
+// @Success 200 {object} models.RestoreResponse
+// @Failure 400 {object} utils.ErrorResponse "Invalid job ID or job not deleted"
+// @Failure 404 {object} utils.ErrorResponse "Job not found"
+// @Failure 410 {object} utils.JobDeletedResponse "Grace period expired"
+// @Failure 500 {object} utils.ErrorResponse "Restore failed"
+// @Router /api/jobs/{id}/restore [post]
+func HandleRestoreJob(c *fiber.Ctx) error {
+	jobID := c.Params("id")
+
+	// Validate job ID
+	if !utils.ValidateJobID(jobID) {
+		return utils.BadRequest(c, utils.ErrInvalidJobID, "Invalid job ID format")
+	}
+
+	meta, err := readJob(c, jobID)
+	if meta == nil {
+		return err
+	}
+
+	if !utils.IsDeleted(meta) {
+		return utils.BadRequest(c, utils.ErrJobNotDeleted, "Job is not deleted")
+	}
+
+	// Directory may still exist until the next cleanup pass
+	if time.Since(time.UnixMilli(meta.DeletedAt)) > config.DeleteGracePeriod {
+		return utils.JobGone(c, "Restore period has expired", meta.DeletedAt)
+	}
+
+	if err := utils.UpdateMetaDeleted(jobID, 0); err != nil {
+		return utils.InternalError(c, "Failed to restore job")
+	}
+
+	return c.JSON(models.RestoreResponse{
+		Restored: true,
 	})
 }
@@ -0,0 +1,202 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+	"yt-downloader-go/config"
+	"yt-downloader-go/models"
+	"yt-downloader-go/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// newJobsApp routes job deletion and status like main.go
+func newJobsApp() *fiber.App {
+	app := fiber.New()
+	app.Delete("/api/jobs/:id", HandleDeleteJob)
+	app.Get("/api/status/:id", HandleStatus)
+	return app
+}
+
+// deleteJob sends DELETE /api/jobs/:id and decodes the response
+func deleteJob(t *testing.T, app *fiber.App, jobID string) (int, models.DeleteResponse) {
+	t.Helper()
+	resp, err := app.Test(httptest.NewRequest("DELETE", "/api/jobs/"+jobID, nil))
+	if err != nil {
+		t.Fatalf("request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var body models.DeleteResponse
+	if resp.StatusCode == fiber.StatusOK {
+		if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+	}
+	return resp.StatusCode, body
+}
+
+// jobStatus requests the signed status URL of a job and decodes a 410 body
+func jobStatus(t *testing.T, app *fiber.App, jobID string) (int, utils.JobDeletedResponse) {
+	t.Helper()
+	resp, err := app.Test(httptest.NewRequest("GET", strings.TrimPrefix(utils.GenerateStatusURL(jobID), config.PublicURL), nil))
+	if err != nil {
+		t.Fatalf("request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var body utils.JobDeletedResponse
+	if resp.StatusCode == fiber.StatusGone {
+		if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+	}
+	return resp.StatusCode, body
+}
+
+func TestDeleteJob(t *testing.T) {
+	app := newJobsApp()
+
+	t.Run("malformed id", func(t *testing.T) {
+		if status, _ := deleteJob(t, app, "not-a-job"); status != fiber.StatusBadRequest {
+			t.Errorf("status %d, want 400", status)
+		}
+	})
+
+	t.Run("never existed", func(t *testing.T) {
+		useTempStorage(t)
+		status, body := deleteJob(t, app, testJobID)
+		if status != fiber.StatusOK || !body.Deleted || !body.AlreadyDeleted || body.DeletedAt != 0 {
+			t.Errorf("got %d %+v, want 200 alreadyDeleted without deletedAt", status, body)
+		}
+		if status, _ := jobStatus(t, app, testJobID); status != fiber.StatusNotFound {
+			t.Errorf("status endpoint: %d, want 404", status)
+		}
+	})
+
+	t.Run("delete and retry", func(t *testing.T) {
+		useTempStorage(t)
+		createCompletedJob(t, testJobID, "output")
+
+		status, first := deleteJob(t, app, testJobID)
+		if status != fiber.StatusOK || !first.Deleted || first.AlreadyDeleted || first.DeletedAt == 0 {
+			t.Fatalf("first delete: %d %+v", status, first)
+		}
+		if want := time.UnixMilli(first.DeletedAt).Add(config.DeleteGracePeriod).UnixMilli(); first.RestoreUntil != want {
+			t.Errorf("restoreUntil = %d, want %d", first.RestoreUntil, want)
+		}
+
+		status, retry := deleteJob(t, app, testJobID)
+		if status != fiber.StatusOK || !retry.AlreadyDeleted || retry.DeletedAt != first.DeletedAt || retry.RestoreUntil != first.RestoreUntil {
+			t.Errorf("retry: %d %+v, want 200 alreadyDeleted with the first deletion", status, retry)
+		}
+
+		if status, gone := jobStatus(t, app, testJobID); status != fiber.StatusGone || gone.DeletedAt != first.DeletedAt {
+			t.Errorf("status endpoint: %d %+v, want 410 with deletedAt %d", status, gone, first.DeletedAt)
+		}
+	})
+
+	t.Run("removed after the grace period", func(t *testing.T) {
+		useTempStorage(t)
+		createCompletedJob(t, testJobID, "output")
+		deletedAt := time.Now().Add(-config.DeleteGracePeriod - time.Minute).UnixMilli()
+		if err := utils.UpdateMetaDeleted(testJobID, deletedAt); err != nil {
+			t.Fatal(err)
+		}
+		utils.CleanupOldJobs()
+		if utils.JobExists(testJobID) {
+			t.Fatal("cleanup kept the job directory")
+		}
+
+		status, body := deleteJob(t, app, testJobID)
+		if status != fiber.StatusOK || !body.AlreadyDeleted || body.DeletedAt != deletedAt || body.RestoreUntil != 0 {
+			t.Errorf("got %d %+v, want 200 alreadyDeleted with deletedAt %d from the tombstone", status, body, deletedAt)
+		}
+		if status, gone := jobStatus(t, app, testJobID); status != fiber.StatusGone || gone.DeletedAt != deletedAt || gone.Error.Code != utils.ErrJobDeleted {
+			t.Errorf("status endpoint: %d %+v, want 410 JOB_DELETED with deletedAt %d", status, gone, deletedAt)
+		}
+	})
+}
+
+// windowJobID is a job only TestJobCreationWindow polls, so no other test's status is cached for it
+const windowJobID = "WindowJob_0123456789a"
+
+// A job is not found until CreateJob moves its directory into place with meta.json, and found
+// from then on; a crashed creation is not found rather than a server error clients retry
+func TestJobCreationWindow(t *testing.T) {
+	app := newJobsApp()
+	t.Cleanup(func() {
+		statusCache.mu.Lock()
+		delete(statusCache.entries, windowJobID)
+		statusCache.mu.Unlock()
+	})
+
+	writeJobFile := func(name string, data string) func(t *testing.T) {
+		return func(t *testing.T) {
+			if err := os.MkdirAll(utils.GetJobDir(windowJobID), 0755); err != nil {
+				t.Fatal(err)
+			}
+			if name != "" {
+				if err := os.WriteFile(filepath.Join(utils.GetJobDir(windowJobID), name), []byte(data), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+		}
+	}
+
+	tests := []struct {
+		name  string
+		setup func(t *testing.T)
+		want  int
+	}{
+		{name: "nothing yet", setup: func(t *testing.T) {}, want: fiber.StatusNotFound},
+		{
+			name: "staged, not moved into place",
+			setup: func(t *testing.T) {
+				staged := filepath.Join(config.StagingDir, windowJobID+"-1")
+				if err := os.MkdirAll(staged, 0755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(filepath.Join(staged, "meta.json"), []byte(`{"id":"`+windowJobID+`"}`), 0644); err != nil {
+					t.Fatal(err)
+				}
+			},
+			want: fiber.StatusNotFound,
+		},
+		{name: "directory without meta", setup: writeJobFile("", ""), want: fiber.StatusNotFound},
+		{name: "meta temp file only", setup: writeJobFile("meta.json.new", `{"id":"`+windowJobID+`"}`), want: fiber.StatusNotFound},
+		{name: "empty meta", setup: writeJobFile("meta.json", ""), want: fiber.StatusNotFound},
+		{name: "truncated meta", setup: writeJobFile("meta.json", `{"id":"`+windowJobID+`","status":"pend`), want: fiber.StatusNotFound},
+		{
+			name: "created",
+			setup: func(t *testing.T) {
+				meta := &models.Meta{ID: windowJobID, Status: models.StatusPending, CreatedAt: time.Now().UnixMilli()}
+				if err := utils.CreateJob(windowJobID, meta); err != nil {
+					t.Fatal(err)
+				}
+			},
+			want: fiber.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useTempStorage(t)
+			tt.setup(t)
+			if status, _ := jobStatus(t, app, windowJobID); status != tt.want {
+				t.Errorf("status endpoint: %d, want %d", status, tt.want)
+			}
+
+			// Deleting a job that isn't there yet is an idempotent no-op
+			status, body := deleteJob(t, app, windowJobID)
+			if wantGone := tt.want == fiber.StatusNotFound; status != fiber.StatusOK || body.AlreadyDeleted != wantGone {
+				t.Errorf("delete: %d %+v, want 200 with alreadyDeleted %t", status, body, wantGone)
+			}
+		})
+	}
+}
@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"yt-downloader-go/config"
+	"yt-downloader-go/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/cors"
+	"github.com/valyala/fasthttp"
+)
+
+// httpLog logs failed requests (module http)
+var httpLog = utils.Logger(utils.LogHTTP)
+
+// CORS answers preflights and sets CORS headers per CORS_ALLOW_ORIGINS, CORS_ALLOW_METHODS
+// and CORS_ALLOW_HEADERS
+func CORS() fiber.Handler {
+	return cors.New(cors.Config{
+		AllowOrigins: config.CORSAllowOrigins,
+		AllowMethods: config.CORSAllowMethods,
+		AllowHeaders: config.CORSAllowHeaders,
+		// Browser players read it for a /stream progress bar
+		ExposeHeaders: "X-Estimated-Content-Length",
+		// Credentials are only allowed with an explicit origin list
+		AllowCredentials: config.CORSAllowOrigins != "*",
+	})
+}
+
+// SecurityHeaders sets hardening headers on file and stream responses
+func SecurityHeaders(c *fiber.Ctx) error {
+	c.Set("X-Content-Type-Options", "nosniff")
+	c.Set("Referrer-Policy", "no-referrer")
+	c.Set("X-Frame-Options", "DENY")
+	c.Set("Content-Security-Policy", config.ContentSecurityPolicy)
+	return c.Next()
+}
+
+// HandleError is the app's error handler: errors returned by handlers and Fiber
+// (unknown routes, wrong methods, panics) get the unified JSON error body
+func HandleError(c *fiber.Ctx, err error) error {
+	var fiberErr *fiber.Error
+	if errors.As(err, &fiberErr) {
+		switch {
+		case fiberErr.Code == fiber.StatusNotFound:
+			return utils.Error(c, fiberErr.Code, utils.ErrNotFound, "Route not found")
+		case fiberErr.Code == fiber.StatusMethodNotAllowed:
+			return utils.Error(c, fiberErr.Code, utils.ErrMethodNotAllowed, "Method not allowed")
+		case fiberErr.Code < fiber.StatusInternalServerError:
+			return utils.Error(c, fiberErr.Code, utils.ErrInvalidRequest, fiberErr.Message)
+		}
+	}
+
+	httpLog.Error("request error", "method", c.Method(), "path", c.Path(), "err", err)
+	return utils.InternalError(c, "Internal server error")
+}
+
+// CompressJSON encodes JSON responses of at least APICompressMinBytes with zstd or gzip,
+// as the client accepts (zstd preferred). Only the /api group uses it: ranged and
+// already-compressed media on /files and /stream must go out unchanged.
+func CompressJSON(c *fiber.Ctx) error {
+	if err := c.Next(); err != nil {
+		return err
+	}
+
+	resp := c.Response()
+	if resp.IsBodyStream() || len(resp.Header.ContentEncoding()) > 0 ||
+		!strings.HasPrefix(string(resp.Header.ContentType()), fiber.MIMEApplicationJSON) ||
+		len(resp.Body()) < config.APICompressMinBytes {
+		return nil
+	}
+	c.Vary(fiber.HeaderAcceptEncoding)
+
+	var encoding string
+	var body []byte
+	switch {
+	case c.Request().Header.HasAcceptEncoding("zstd"):
+		encoding, body = "zstd", fasthttp.AppendZstdBytes(nil, resp.Body())
+	case c.Request().Header.HasAcceptEncoding("gzip"):
+		encoding, body = "gzip", fasthttp.AppendGzipBytes(nil, resp.Body())
+	default:
+		return nil
+	}
+	resp.SetBodyRaw(body)
+	resp.Header.SetContentEncoding(encoding)
+	return nil
+}
+
+// BodyLimit rejects request bodies larger than limit bytes
+// Below the server's MaxRequestBodySize, so oversized JSON gets the unified error body
+func BodyLimit(limit int) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if c.Request().Header.ContentLength() > limit || len(c.Body()) > limit {
+			return utils.Error(c, fiber.StatusRequestEntityTooLarge, utils.ErrBodyTooLarge,
+				fmt.Sprintf("Request body must be at most %d bytes", limit))
+		}
+		return c.Next()
+	}
+}
+
+// parseJSONStrict decodes a JSON body, rejecting unknown fields so typos surface as errors
+func parseJSONStrict(c *fiber.Ctx, out interface{}) error {
+	decoder := json.NewDecoder(bytes.NewReader(c.Body()))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(out); err != nil {
+		return err
+	}
+	if decoder.More() {
+		return errors.New("unexpected data after JSON body")
+	}
+	return nil
+}
@@ -0,0 +1,217 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"yt-downloader-go/config"
+	"yt-downloader-go/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// useCORSOrigins sets CORS_ALLOW_ORIGINS for the duration of the test
+func useCORSOrigins(t *testing.T, origins string) {
+	t.Helper()
+	prev := config.CORSAllowOrigins
+	config.CORSAllowOrigins = origins
+	t.Cleanup(func() { config.CORSAllowOrigins = prev })
+}
+
+// newCORSApp routes GET and POST /api/download behind CORS, like main.go
+func newCORSApp() *fiber.App {
+	app := fiber.New()
+	app.Use(CORS())
+	ok := func(c *fiber.Ctx) error { return c.SendString("ok") }
+	app.Get("/api/download", ok)
+	app.Post("/api/download", ok)
+	return app
+}
+
+// preflight sends a browser preflight for POST /api/download from origin
+func preflight(t *testing.T, app *fiber.App, origin string) *http.Response {
+	t.Helper()
+	req := httptest.NewRequest(fiber.MethodOptions, "/api/download", nil)
+	req.Header.Set(fiber.HeaderOrigin, origin)
+	req.Header.Set(fiber.HeaderAccessControlRequestMethod, fiber.MethodPost)
+	req.Header.Set(fiber.HeaderAccessControlRequestHeaders, "Content-Type")
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	return resp
+}
+
+func TestCORSPreflight(t *testing.T) {
+	tests := []struct {
+		name        string
+		origins     string
+		origin      string
+		allowOrigin string // Access-Control-Allow-Origin, "" for none
+		credentials string // Access-Control-Allow-Credentials, "" for none
+	}{
+		{
+			// Browsers refuse credentialed requests without Allow-Credentials
+			name: "wildcard", origins: "*", origin: "https://any.example",
+			allowOrigin: "*",
+		},
+		{
+			name: "allowed origin", origins: "https://app.example,https://admin.example", origin: "https://admin.example",
+			allowOrigin: "https://admin.example", credentials: "true",
+		},
+		{
+			name: "disallowed origin", origins: "https://app.example,https://admin.example", origin: "https://evil.example",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useCORSOrigins(t, tt.origins)
+			resp := preflight(t, newCORSApp(), tt.origin)
+			if resp.StatusCode != fiber.StatusNoContent {
+				t.Errorf("status %d, want 204", resp.StatusCode)
+			}
+			header := resp.Header
+			if got := header.Get(fiber.HeaderAccessControlAllowOrigin); got != tt.allowOrigin {
+				t.Errorf("Access-Control-Allow-Origin %q, want %q", got, tt.allowOrigin)
+			}
+			if got := header.Get(fiber.HeaderAccessControlAllowCredentials); got != tt.credentials {
+				t.Errorf("Access-Control-Allow-Credentials %q, want %q", got, tt.credentials)
+			}
+			if tt.allowOrigin == "" {
+				return
+			}
+			if got := header.Get(fiber.HeaderAccessControlAllowMethods); got != config.CORSAllowMethods {
+				t.Errorf("Access-Control-Allow-Methods %q, want %q", got, config.CORSAllowMethods)
+			}
+			if got := header.Get(fiber.HeaderAccessControlAllowHeaders); got != config.CORSAllowHeaders {
+				t.Errorf("Access-Control-Allow-Headers %q, want %q", got, config.CORSAllowHeaders)
+			}
+		})
+	}
+}
+
+// A credentialed request under the wildcard gets no Allow-Credentials, so the browser
+// keeps the response from the page
+func TestCORSWildcardWithCredentials(t *testing.T) {
+	useCORSOrigins(t, "*")
+	req := httptest.NewRequest(fiber.MethodGet, "/api/download", nil)
+	req.Header.Set(fiber.HeaderOrigin, "https://any.example")
+	req.Header.Set(fiber.HeaderCookie, "session=1")
+	resp, err := newCORSApp().Test(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if got := resp.Header.Get(fiber.HeaderAccessControlAllowOrigin); got != "*" {
+		t.Errorf("Access-Control-Allow-Origin %q, want *", got)
+	}
+	if got := resp.Header.Get(fiber.HeaderAccessControlAllowCredentials); got != "" {
+		t.Errorf("Access-Control-Allow-Credentials %q, want none", got)
+	}
+	if got := resp.Header.Get(fiber.HeaderAccessControlExposeHeaders); got != "X-Estimated-Content-Length" {
+		t.Errorf("Access-Control-Expose-Headers %q, want X-Estimated-Content-Length", got)
+	}
+}
+
+func TestSecurityHeaders(t *testing.T) {
+	app := fiber.New()
+	app.Get("/files/:id/:filename", SecurityHeaders, func(c *fiber.Ctx) error { return c.SendString("ok") })
+	resp, err := app.Test(httptest.NewRequest(fiber.MethodGet, "/files/"+testJobID+"/output.mp3", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	want := map[string]string{
+		"X-Content-Type-Options":  "nosniff",
+		"Referrer-Policy":         "no-referrer",
+		"X-Frame-Options":         "DENY",
+		"Content-Security-Policy": config.ContentSecurityPolicy,
+	}
+	for name, value := range want {
+		if got := resp.Header.Get(name); got != value {
+			t.Errorf("%s %q, want %q", name, got, value)
+		}
+	}
+}
+
+// newDownloadApp routes job creation behind the API body limit, like main.go
+func newDownloadApp() *fiber.App {
+	app := fiber.New()
+	api := app.Group("/api", BodyLimit(config.MaxAPIBodySize))
+	api.Post("/download", HandleDownload)
+	return app
+}
+
+// postJSON posts body to path and decodes the error response
+func postJSON(t *testing.T, app *fiber.App, path string, body string) (int, utils.ErrorDetail) {
+	t.Helper()
+	req := httptest.NewRequest(fiber.MethodPost, path, strings.NewReader(body))
+	req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	var errBody utils.ErrorResponse
+	if resp.StatusCode >= fiber.StatusBadRequest {
+		if err := json.NewDecoder(resp.Body).Decode(&errBody); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+	}
+	return resp.StatusCode, errBody.Error
+}
+
+func TestDownloadBodyRejected(t *testing.T) {
+	const job = `"url":"https://youtu.be/dQw4w9WgXcQ","output":{"type":"audio","format":"mp3"}`
+	tests := []struct {
+		name    string
+		body    string
+		status  int
+		code    string
+		message string // Part of the error message
+	}{
+		{
+			name:   "oversized body",
+			body:   `{` + job + `,"idempotencyKey":"` + strings.Repeat("a", config.MaxAPIBodySize) + `"}`,
+			status: fiber.StatusRequestEntityTooLarge, code: utils.ErrBodyTooLarge,
+			message: fmt.Sprintf("at most %d bytes", config.MaxAPIBodySize),
+		},
+		{
+			name:   "unknown field",
+			body:   `{` + job + `,"qualty":"720p"}`,
+			status: fiber.StatusBadRequest, code: utils.ErrInvalidRequest,
+			message: `unknown field "qualty"`,
+		},
+		{
+			name:   "unknown nested field",
+			body:   `{"url":"https://youtu.be/dQw4w9WgXcQ","output":{"type":"audio","format":"mp3","bitrat":"192k"}}`,
+			status: fiber.StatusBadRequest, code: utils.ErrInvalidRequest,
+			message: `unknown field "bitrat"`,
+		},
+		{
+			name:   "data after the body",
+			body:   `{` + job + `}{}`,
+			status: fiber.StatusBadRequest, code: utils.ErrInvalidRequest,
+			message: "unexpected data after JSON body",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, detail := postJSON(t, newDownloadApp(), "/api/download", tt.body)
+			if status != tt.status || detail.Code != tt.code {
+				t.Fatalf("%d %s, want %d %s", status, detail.Code, tt.status, tt.code)
+			}
+			if !strings.Contains(detail.Message, tt.message) {
+				t.Errorf("message %q, want it to contain %q", detail.Message, tt.message)
+			}
+		})
+	}
+}
@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+	"yt-downloader-go/config"
+	"yt-downloader-go/services"
+	"yt-downloader-go/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/limiter"
+)
+
+// previewSlots caps the previews running at once across all clients (PREVIEW_MAX_CONCURRENT)
+var previewSlots = make(chan struct{}, max(config.PreviewMaxConcurrent, 1))
+
+// PreviewRateLimit allows each client IP PREVIEW_RATE_LIMIT previews per minute (0 = unlimited)
+func PreviewRateLimit() fiber.Handler {
+	if config.PreviewRateLimit <= 0 {
+		return func(c *fiber.Ctx) error { return c.Next() }
+	}
+	return limiter.New(limiter.Config{
+		Max:        config.PreviewRateLimit,
+		Expiration: time.Minute,
+		LimitReached: func(c *fiber.Ctx) error {
+			return utils.Error(c, fiber.StatusTooManyRequests, utils.ErrRateLimited,
+				fmt.Sprintf("At most %d previews per minute", config.PreviewRateLimit))
+		},
+	})
+}
+
+// HandlePreviewAudio handles GET /api/preview-audio/:videoId
+// @Summary Preview an audio track
+// @Description Short opus clip from the start of an audio track, to tell the tracks (dubs) of a video apart before choosing audio.trackId. No job is created.
+// @Tags download
+// @Produce audio/opus
+// @Param videoId path string true "YouTube video ID"
+// @Param track query string false "audio.trackId (default: the original track)"
+// @Param seconds query integer false "Clip length in seconds (1-30, default 10)"
+// @Success 200 {file} binary "Opus clip (48 kbps)"
+// @Failure 400 {object} utils.ErrorResponse "Invalid video ID or seconds"
+// @Failure 404 {object} utils.TrackNotFoundResponse "Track not found"
+// @Failure 429 {object} utils.ErrorResponse "Too many previews from this client"
+// @Failure 500 {object} utils.ErrorResponse "Preview failed"
+// @Failure 503 {object} utils.ErrorResponse "Too many previews in progress, or metadata service rate limited"
+// @Router /api/preview-audio/{videoId} [get]
+func HandlePreviewAudio(c *fiber.Ctx) error {
+	videoID, err := utils.ExtractVideoID(c.Params("videoId"))
+	if err != nil {
+		return utils.BadRequest(c, utils.ErrInvalidURL, "Invalid video ID")
+	}
+
+	seconds := c.QueryInt("seconds", config.PreviewDefaultSeconds)
+	if seconds < 1 || seconds > config.PreviewMaxSeconds {
+		return utils.BadRequest(c, utils.ErrValidationError, fmt.Sprintf("seconds: must be between 1 and %d", config.PreviewMaxSeconds))
+	}
+
+	select {
+	case previewSlots <- struct{}{}:
+		defer func() { <-previewSlots }()
+	default:
+		c.Set(fiber.HeaderRetryAfter, "5")
+		return utils.Error(c, fiber.StatusServiceUnavailable, utils.ErrOverloaded, "Too many previews in progress, retry later")
+	}
+
+	ctx, cancel := context.WithTimeout(c.Context(), config.PreviewTimeout)
+	defer cancel()
+
+	extractData, err := services.ExtractCached(ctx, videoID)
+	var rateLimited *services.RateLimitError
+	if errors.As(err, &rateLimited) {
+		c.Set(fiber.HeaderRetryAfter, strconv.Itoa(rateLimited.RetryAfterSeconds()))
+		return utils.Error(c, fiber.StatusServiceUnavailable, utils.ErrExtractLimited, "Video metadata service is rate limited, retry later")
+	}
+	if err != nil {
+		return utils.InternalError(c, "Failed to fetch video metadata")
+	}
+
+	stream := services.PreviewStream(extractData, c.Query("track"))
+	if stream == nil {
+		return c.Status(fiber.StatusNotFound).JSON(utils.TrackNotFoundResponse{
+			Error: utils.ErrorDetail{
+				Code:    utils.ErrTrackNotFound,
+				Message: "Audio track not found",
+			},
+			Tracks: services.AudioTracks(extractData),
+		}, fiber.MIMEApplicationJSONCharsetUTF8)
+	}
+
+	clip, err := services.RenderAudioPreview(ctx, stream, extractData.Duration, seconds)
+	if err != nil {
+		streamLog.Warn("preview failed", "video", videoID, "track", stream.AudioTrackID, "err", err)
+		return utils.InternalError(c, "Failed to render preview")
+	}
+
+	c.Set(fiber.HeaderContentType, utils.ContentTypeFromExt("opus"))
+	c.Set(fiber.HeaderCacheControl, "private, max-age=300")
+	return c.Send(clip)
+}
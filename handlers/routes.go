@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"yt-downloader-go/config"
+
+	"github.com/gofiber/fiber/v2"
+	expvarmw "github.com/gofiber/fiber/v2/middleware/expvar"
+	"github.com/gofiber/swagger"
+)
+
+// RegisterRoutes adds every route of the server to app
+func RegisterRoutes(app *fiber.App) {
+	// All routes live under PATH_PREFIX (empty by default)
+	root := app.Group(config.PathPrefix)
+
+	// Swagger docs
+	root.Get("/swagger/*", swagger.HandlerDefault)
+
+	// API routes
+	api := root.Group("/api", BodyLimit(config.MaxAPIBodySize))
+	if config.APICompression {
+		api.Use(CompressJSON)
+	}
+	api.Post("/download", HandleDownload)
+	api.Get("/capabilities", HandleCapabilities)
+	api.Get("/info/:videoId", HandleVideoInfo)
+	api.Get("/preview-audio/:videoId", PreviewRateLimit(), HandlePreviewAudio)
+	api.Get("/status/:id", HandleStatus)
+	api.Delete("/jobs/:id", HandleDeleteJob)
+	api.Post("/jobs/:id/restore", HandleRestoreJob)
+	api.Post("/jobs/:id/extend", HandleExtendJob)
+	api.Post("/jobs/:id/convert", HandleConvertJob)
+	api.Post("/groups", HandleCreateGroup)
+	api.Get("/groups/:id", HandleGroupStatus)
+	api.Delete("/groups/:id", HandleDeleteGroup)
+
+	admin := api.Group("/admin", AdminAuth)
+	admin.Get("/load", HandleGetLoad)
+	admin.Put("/load", HandleSetLoad)
+	admin.Get("/log-levels", HandleGetLogLevels)
+	admin.Put("/log-levels", HandleSetLogLevels)
+	admin.Get("/jobs", HandleListJobs)
+	admin.Get("/jobs/:id", HandleGetJob)
+	admin.Post("/jobs/:id/requeue", HandleRequeueJob)
+	admin.Post("/jobs/:id/fail", HandleFailJob)
+	admin.Get("/archive/:date", HandleGetArchive)
+	admin.Get("/usage", HandleGetUsage)
+
+	// Process metrics (expvar: memstats, watchdog, buffers, downloads, extract)
+	root.Get("/debug/vars", AdminAuth, expvarmw.New())
+
+	// File serving
+	root.Get("/files/:id/:filename", SecurityHeaders, HandleFiles)
+	root.Get("/files/:id/:filename/manifest", SecurityHeaders, HandleFileManifest)
+	root.Get("/d/:id/:name", SecurityHeaders, HandleShareFile)
+	root.Get("/groups/:id/archive", SecurityHeaders, HandleGroupArchive)
+
+	// Stream serving (FFmpeg pipe)
+	root.Get("/stream/:id", SecurityHeaders, HandleStream)
+	root.Get("/stream/:id/master.m3u8", SecurityHeaders, HandleHLSMaster)
+	root.Get("/stream/:id/hls/:filename", SecurityHeaders, HandleHLSFile)
+
+	// Health check
+	root.Get("/health", HandleHealth)
+
+	// Manual testing page (public API only)
+	if config.UIEnabled {
+		root.Get("/ui", HandleUI)
+	}
+}
@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+	"yt-downloader-go/config"
+	"yt-downloader-go/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// usePathPrefix sets PATH_PREFIX for the duration of the test
+func usePathPrefix(t *testing.T, prefix string) {
+	t.Helper()
+	prev := config.PathPrefix
+	config.SetPathPrefix(prefix)
+	t.Cleanup(func() { config.SetPathPrefix(prev) })
+}
+
+// request sends GET for the path of a link (everything after BASE_URL) and returns the
+// status and Location
+func request(t *testing.T, app *fiber.App, link string) (int, string) {
+	t.Helper()
+	resp, err := app.Test(httptest.NewRequest("GET", strings.TrimPrefix(link, config.BaseURL), nil))
+	if err != nil {
+		t.Fatalf("request: %v", err)
+	}
+	resp.Body.Close()
+	return resp.StatusCode, resp.Header.Get(fiber.HeaderLocation)
+}
+
+// Generated links reach their routes with and without PATH_PREFIX
+func TestRoutesPathPrefix(t *testing.T) {
+	for _, prefix := range []string{"", "/yt"} {
+		t.Run("prefix "+prefix, func(t *testing.T) {
+			useTempStorage(t)
+			usePathPrefix(t, prefix)
+			meta := createCompletedJob(t, testJobID, "output")
+			app := fiber.New()
+			RegisterRoutes(app)
+
+			fileURL := utils.GenerateSignedURL(testJobID, meta.Output, "")
+			shareURL := utils.GenerateShareURL(testJobID, meta.Output, utils.GetDisplayFilename(meta), "")
+			streamURL := utils.GenerateStreamURL(testJobID, "", time.Now().Add(time.Hour))
+			for _, link := range []string{fileURL, shareURL} {
+				if !strings.HasPrefix(link, config.BaseURL+prefix+"/") {
+					t.Errorf("%s lacks the prefix %q", link, prefix)
+				}
+				if status, _ := request(t, app, link); status != fiber.StatusOK {
+					t.Errorf("%s: status %d, want 200", link, status)
+				}
+			}
+
+			// A merged job's stream redirects to its prefixed /files link
+			status, location := request(t, app, streamURL)
+			if status != fiber.StatusTemporaryRedirect || !strings.HasPrefix(location, config.BaseURL+prefix+"/files/"+testJobID+"/output.mp3?t=") {
+				t.Errorf("stream: %d to %q, want a redirect to the /files link", status, location)
+			}
+
+			if prefix == "" {
+				return
+			}
+			// Nothing is routed outside the prefix
+			if status, _ := request(t, app, strings.Replace(fileURL, prefix, "", 1)); status != fiber.StatusNotFound {
+				t.Errorf("unprefixed /files: status %d, want 404", status)
+			}
+		})
+	}
+}
@@ -1,7 +1,9 @@
 package handlers
 
 import (
+	"yt-downloader-go/config"
 	"yt-downloader-go/models"
+	"yt-downloader-go/services"
 	"yt-downloader-go/utils"
 
 	"github.com/gofiber/fiber/v2"
@@ -13,60 +15,70 @@ import (
 // @Tags status
 // @Produce json
 // @Param id path string true "Job ID"
-// @Param token query string true "Signed URL token"
-// @Param expires query string true "Expiration timestamp"
+// @Param t query string false "Compact signed token"
+// @Param token query string false "Legacy signed URL token (deprecated)"
+// @Param expires query string false "Legacy expiration timestamp (deprecated)"
+// @Param includeRequest query boolean false "Include the parameters the job was created with"
 // @Success 200 {object} models.StatusResponse
 // @Failure 400 {object} utils.ErrorResponse "Invalid job ID"
 // @Failure 401 {object} utils.ErrorResponse "Missing token or expires"
 // @Failure 403 {object} utils.ErrorResponse "Invalid or expired token"
 // @Failure 404 {object} utils.ErrorResponse "Job not found"
-// @Failure 500 {object} utils.ErrorResponse "Server error"
+// @Failure 410 {object} utils.JobDeletedResponse "Job deleted"
+// @Failure 500 {object} utils.ErrorResponse "Server error (metadata unreadable and no recent status to serve stale)"
 // @Router /api/status/{id} [get]
 func HandleStatus(c *fiber.Ctx) error {
 	jobID := c.Params("id")
-	token := c.Query("token")
-	expiresStr := c.Query("expires")
 
 	// Validate job ID
 	if !utils.ValidateJobID(jobID) {
 		return utils.BadRequest(c, utils.ErrInvalidJobID, "Invalid job ID format")
 	}
 
-	// Check token and expires
-	if token == "" || expiresStr == "" {
-		return utils.Unauthorized(c, "Missing token or expires parameter")
+	// Validate signed URL
+	if ok, err := utils.AuthorizeRequest(c, utils.ScopeStatus, jobID, ""); !ok {
+		return err
 	}
 
-	// Parse expires
-	expires, err := utils.ParseExpires(expiresStr)
-	if err != nil {
-		return utils.BadRequest(c, utils.ErrInvalidRequest, "Invalid expires format")
-	}
-
-	// Validate token
-	if !utils.ValidateStatusURL(jobID, token, expires) {
-		return utils.Forbidden(c, "Invalid or expired token")
-	}
-
-	// Check if job exists
-	if !utils.JobExists(jobID) {
-		return utils.NotFound(c, utils.ErrJobNotFound, "Job not found")
+	// Read metadata; a failed read may be answered with the last status (stale)
+	meta, err := readStatusMeta(c, jobID)
+	if meta == nil {
+		return err
 	}
 
-	// Read metadata
-	meta, err := utils.ReadMeta(jobID)
-	if err != nil {
-		return utils.InternalError(c, "Failed to read job metadata")
+	if utils.IsDeleted(meta) {
+		return utils.JobGone(c, "Job has been deleted", meta.DeletedAt)
 	}
 
 	// Calculate progress
 	progress := utils.CalculateProgress(meta)
 
 	response := models.StatusResponse{
-		Status:   meta.Status,
-		Progress: progress,
-		Title:    meta.Title,
-		Duration: meta.Duration,
+		Status:          meta.Status,
+		Progress:        progress,
+		Title:           meta.Title,
+		Duration:        meta.Duration,
+		OutputDuration:  meta.OutputDuration,
+		SilenceTrim:     meta.SilenceTrim,
+		Rotation:        meta.Rotation,
+		AudioChannels:   meta.AudioChannels,
+		Muted:           meta.Muted,
+		Faststart:       meta.Faststart,
+		Author:          meta.Author,
+		UploadDate:      meta.UploadDate,
+		ViewCount:       meta.ViewCount,
+		ThumbnailURL:    meta.ThumbnailURL,
+		Selection:       meta.Selection,
+		LossyToLossless: meta.LossyToLossless,
+		Warnings:        append(models.Warnings{}, meta.Warnings...),
+		ExpiresAt:       utils.JobExpiresAt(meta).UnixMilli(),
+		MaxExpiresAt:    utils.JobMaxExpiresAt(meta).UnixMilli(),
+		GroupID:         meta.GroupID,
+	}
+
+	// Position among jobs waiting for a download or FFmpeg slot
+	if meta.Status == models.StatusPending {
+		response.QueuePosition = services.QueuePosition(jobID)
 	}
 
 	// Set downloadUrl when completed
@@ -74,17 +86,97 @@ func HandleStatus(c *fiber.Ctx) error {
 		response.Progress = 100
 		if meta.Output != "" {
 			// Merged file available - use static file URL
-			response.DownloadURL = utils.GenerateSignedURL(jobID, meta.Output)
+			response.DownloadURL = utils.GenerateSignedURL(jobID, meta.Output, meta.Binding)
+			response.DownloadURLExpiresAt = utils.SignedURLExpiresAt().UnixMilli()
+			response.ShareURL = utils.GenerateShareURL(jobID, meta.Output, utils.GetDisplayFilename(meta), meta.Binding)
 		} else if meta.StreamOnly {
-			// Stream only - use stream URL
-			response.DownloadURL = utils.GenerateStreamURL(jobID)
+			// Stream only - use stream URL (longer-lived, see STREAM_URL_EXPIRATION)
+			expiresAt := utils.StreamURLExpiresAt(meta)
+			response.DownloadURL = utils.GenerateStreamURL(jobID, meta.Binding, expiresAt)
+			response.DownloadURLExpiresAt = expiresAt.UnixMilli()
+			response.EstimatedSize = services.EstimateStreamSize(meta)
+		}
+	}
+
+	// Transcript artifacts (audio.transcript)
+	if meta.Status == models.StatusCompleted && meta.Transcript != nil {
+		available := meta.Transcript.Available
+		response.TranscriptAvailable = &available
+		if available {
+			response.Transcript = &models.TranscriptStatus{
+				VTTURL:    utils.GenerateSignedURL(jobID, config.TranscriptVTTName, meta.Binding),
+				TextURL:   utils.GenerateSignedURL(jobID, config.TranscriptTextName, meta.Binding),
+				Language:  meta.Transcript.Language,
+				Generated: meta.Transcript.Generated,
+			}
 		}
 	}
 
-	// Set jobError when error
+	// Stream-only jobs: lets clients tell a truncated /stream body from a complete one
+	response.LastStreamError = meta.LastStreamError
+
+	// Additional outputs rendered from retained sources
+	for _, output := range meta.Outputs {
+		status := models.OutputStatus{
+			Name:     output.Name,
+			Format:   output.Format,
+			Bitrate:  output.Bitrate,
+			Status:   output.Status,
+			Progress: outputProgress(output.Status, progress),
+			Error:    output.Error,
+		}
+		if output.Status == models.StatusCompleted {
+			status.DownloadURL = utils.GenerateSignedURL(jobID, output.Name, meta.Binding)
+		}
+		response.Outputs = append(response.Outputs, status)
+	}
+
+	// Set jobError (message) and jobErrorDetail when error
 	if meta.Status == models.StatusError {
 		response.JobError = meta.Error
+		response.JobErrorDetail = meta.JobError
 	}
 
+	rememberStatus(jobID, response)
+
+	// Opt-in: keeps regular polls small. Not remembered, so a stale status is small too.
+	if c.QueryBool("includeRequest") {
+		response.Request = meta.Request
+	}
 	return c.JSON(response)
 }
+
+// outputProgress reports an additional output's progress: the shared download's until it is rendered
+func outputProgress(status string, downloadProgress int) int {
+	switch status {
+	case models.StatusCompleted:
+		return 100
+	case models.StatusError:
+		return 0
+	default:
+		return min(downloadProgress, 99)
+	}
+}
+
+// readJob reads the meta of a job. A directory without a readable meta.json (a crashed
+// creation) is not found, rather than a server error clients would retry.
+// When it returns nil the error response has already been written; return err.
+func readJob(c *fiber.Ctx, jobID string) (*models.Meta, error) {
+	meta, err := utils.ReadMeta(jobID)
+	if err != nil {
+		if utils.MetaMissing(err) {
+			return nil, jobMissing(c, jobID)
+		}
+		return nil, utils.InternalError(c, "Failed to read job metadata")
+	}
+	return meta, nil
+}
+
+// jobMissing writes the response for a job without meta.json: 410 while a tombstone
+// records its deletion, 404 otherwise
+func jobMissing(c *fiber.Ctx, jobID string) error {
+	if tombstone := utils.ReadTombstone(jobID); tombstone != nil {
+		return utils.JobGone(c, "Job has been deleted", tombstone.DeletedAt)
+	}
+	return utils.NotFound(c, utils.ErrJobNotFound, "Job not found")
+}
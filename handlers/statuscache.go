@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"expvar"
+	"os"
+	"sync"
+	"time"
+	"yt-downloader-go/config"
+	"yt-downloader-go/models"
+	"yt-downloader-go/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// statusStaleServed counts status responses served from statusCache because meta.json
+// could not be read (exported via /debug/vars)
+var statusStaleServed = expvar.NewInt("status_stale_served")
+
+// cachedStatus is the last status returned for a job
+type cachedStatus struct {
+	response models.StatusResponse
+	storedAt time.Time
+}
+
+// statusCache keeps the last status of each polled job for StatusStaleMaxAge, so a failed
+// meta.json read (EIO, a torn read) doesn't look like a dead job to clients
+var statusCache = struct {
+	mu        sync.Mutex
+	entries   map[string]*cachedStatus
+	lastSweep time.Time
+}{entries: map[string]*cachedStatus{}}
+
+// rememberStatus stores the status returned for a job; expired entries are swept at most
+// once per StatusStaleMaxAge
+func rememberStatus(jobID string, response models.StatusResponse) {
+	now := time.Now()
+	statusCache.mu.Lock()
+	defer statusCache.mu.Unlock()
+
+	statusCache.entries[jobID] = &cachedStatus{response: response, storedAt: now}
+	if now.Sub(statusCache.lastSweep) < config.StatusStaleMaxAge {
+		return
+	}
+	statusCache.lastSweep = now
+	for id, entry := range statusCache.entries {
+		if now.Sub(entry.storedAt) > config.StatusStaleMaxAge {
+			delete(statusCache.entries, id)
+		}
+	}
+}
+
+// staleStatus returns the last status returned for a job, if recent enough
+func staleStatus(jobID string) (models.StatusResponse, bool) {
+	statusCache.mu.Lock()
+	defer statusCache.mu.Unlock()
+
+	entry := statusCache.entries[jobID]
+	if entry == nil || time.Since(entry.storedAt) > config.StatusStaleMaxAge {
+		return models.StatusResponse{}, false
+	}
+	return entry.response, true
+}
+
+// readStatusMeta reads the meta of a status request. A read that fails other than with a
+// missing meta.json (EIO, or a torn read that looks corrupt) is retried once; if that fails
+// too, the job's last status is sent marked stale. Without one, corrupt meta is not found
+// like in readJob, and anything else is a server error.
+// When it returns nil the response has already been written; return err.
+func readStatusMeta(c *fiber.Ctx, jobID string) (*models.Meta, error) {
+	meta, err := utils.ReadMeta(jobID)
+	if err != nil && !os.IsNotExist(err) {
+		time.Sleep(config.StatusReadRetryDelay)
+		meta, err = utils.ReadMeta(jobID)
+	}
+	if err == nil {
+		return meta, nil
+	}
+	if os.IsNotExist(err) {
+		return nil, jobMissing(c, jobID)
+	}
+
+	response, ok := staleStatus(jobID)
+	if !ok {
+		if utils.MetaMissing(err) {
+			return nil, jobMissing(c, jobID)
+		}
+		jobLog.Warn("failed to read meta", "job", jobID, "err", err)
+		return nil, utils.InternalError(c, "Failed to read job metadata")
+	}
+	statusStaleServed.Add(1)
+	jobLog.Warn("failed to read meta, serving stale status", "job", jobID, "err", err)
+	response.Stale = true
+	if !c.QueryBool("includeRequest") {
+		response.Request = nil
+	}
+	return nil, c.JSON(response)
+}
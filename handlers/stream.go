@@ -2,14 +2,18 @@ package handlers
 
 import (
 	"bufio"
-	"fmt"
+	"context"
+	"expvar"
+	"io"
 	"net/url"
 	"os"
-	"os/exec"
 	"path/filepath"
+	"strconv"
+	"sync/atomic"
 	"time"
 	"yt-downloader-go/config"
 	"yt-downloader-go/models"
+	"yt-downloader-go/services"
 	"yt-downloader-go/utils"
 
 	"github.com/gofiber/fiber/v2"
@@ -21,20 +25,21 @@ import (
 // @Tags stream
 // @Produce octet-stream
 // @Param id path string true "Job ID"
-// @Param token query string true "Signed URL token"
-// @Param expires query integer true "Expiration timestamp"
+// @Param t query string false "Compact signed token"
+// @Param token query string false "Legacy signed URL token (deprecated)"
+// @Param expires query integer false "Legacy expiration timestamp (deprecated)"
+// @Param inline query boolean false "Serve with Content-Disposition: inline for in-browser playback"
 // @Success 200 {file} binary "Media stream"
 // @Success 307 "Redirect to download URL"
 // @Failure 400 {object} utils.ErrorResponse "Invalid parameters"
 // @Failure 401 {object} utils.ErrorResponse "Missing auth"
 // @Failure 403 {object} utils.ErrorResponse "Invalid token"
 // @Failure 404 {object} utils.ErrorResponse "Not found"
+// @Failure 410 {object} utils.JobDeletedResponse "Job deleted"
 // @Failure 500 {object} utils.ErrorResponse "Stream failed"
 // @Router /stream/{id} [get]
 func HandleStream(c *fiber.Ctx) error {
 	jobID := c.Params("id")
-	token := c.Query("token")
-	expiresStr := c.Query("expires")
 
 	// Validate job ID
 	if !utils.ValidateJobID(jobID) {
@@ -42,28 +47,18 @@ func HandleStream(c *fiber.Ctx) error {
 	}
 
 	// Validate signed URL
-	if token == "" || expiresStr == "" {
-		return utils.Unauthorized(c, "Missing token or expires parameter")
+	if ok, err := utils.AuthorizeRequest(c, utils.ScopeDownload, jobID, ""); !ok {
+		return err
 	}
 
-	expires, err := utils.ParseExpires(expiresStr)
-	if err != nil {
-		return utils.BadRequest(c, utils.ErrInvalidExpires, "Invalid expires parameter")
-	}
-
-	if !utils.ValidateStreamURL(jobID, token, expires) {
-		return utils.Forbidden(c, "Invalid or expired stream link")
-	}
-
-	// Check if job exists
-	if !utils.JobExists(jobID) {
-		return utils.NotFound(c, utils.ErrJobNotFound, "Job not found")
+	// Read metadata
+	meta, err := readJob(c, jobID)
+	if meta == nil {
+		return err
 	}
 
-	// Read metadata
-	meta, err := utils.ReadMeta(jobID)
-	if err != nil {
-		return utils.InternalError(c, "Failed to read job metadata")
+	if utils.IsDeleted(meta) {
+		return utils.JobGone(c, "Job has been deleted", meta.DeletedAt)
 	}
 
 	// Check if job is ready for streaming
@@ -73,7 +68,13 @@ func HandleStream(c *fiber.Ctx) error {
 
 	// If already merged (not stream-only), redirect to file download
 	if meta.Output != "" && !meta.StreamOnly {
-		downloadURL := utils.GenerateSignedURL(jobID, meta.Output)
+		downloadURL := utils.GenerateSignedURL(jobID, meta.Output, meta.Binding)
+		if c.QueryBool("inline") {
+			downloadURL += "&inline=1"
+		}
+		if session := c.Query("session"); session != "" {
+			downloadURL += "&session=" + url.QueryEscape(session)
+		}
 		return c.Redirect(downloadURL, fiber.StatusTemporaryRedirect)
 	}
 
@@ -88,48 +89,21 @@ func HandleStream(c *fiber.Ctx) error {
 func streamVideo(c *fiber.Ctx, meta *models.Meta) error {
 	jobDir := utils.GetJobDir(meta.ID)
 	videoPath := filepath.Join(jobDir, meta.Files.Video.Name)
-	audioPath := filepath.Join(jobDir, meta.Files.Audio.Name)
 
 	// Check files exist
 	if _, err := os.Stat(videoPath); err != nil {
 		return utils.NotFound(c, utils.ErrFileNotFound, "Video file not found")
 	}
-	if _, err := os.Stat(audioPath); err != nil {
-		return utils.NotFound(c, utils.ErrFileNotFound, "Audio file not found")
-	}
-
-	// Determine output format and content type
-	format := meta.Format
-	contentType := utils.ContentTypeFromExt(format)
-
-	// Generate filename
-	filename := utils.GenerateOutputFilename(meta)
-	encodedFilename := url.PathEscape(filename)
 
-	// Set response headers
-	c.Set("Content-Type", contentType)
-	c.Set("Transfer-Encoding", "chunked")
-	c.Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"; filename*=UTF-8''%s`, filename, encodedFilename))
-	c.Set("Cache-Control", "no-cache")
-
-	// Build FFmpeg command for remuxing (no re-encoding, very light CPU)
-	args := []string{
-		"-y",
-		"-i", videoPath,
-		"-i", audioPath,
-		"-c:v", "copy",
-		"-c:a", "copy",
-		"-f", getFFmpegFormat(format),
-	}
-
-	// Add movflags for streamable MP4
-	if format == "mp4" {
-		args = append(args, "-movflags", "frag_keyframe+empty_moov+faststart")
+	var audioPath string
+	if meta.Files.Audio != nil {
+		audioPath = filepath.Join(jobDir, meta.Files.Audio.Name)
+		if _, err := os.Stat(audioPath); err != nil {
+			return utils.NotFound(c, utils.ErrFileNotFound, "Audio file not found")
+		}
 	}
 
-	args = append(args, "pipe:1")
-
-	return runFFmpegStream(c, args)
+	return runFFmpegStream(c, meta, services.StreamVideoArgs(meta, videoPath, audioPath))
 }
 
 // streamAudio streams audio, with transcoding if needed
@@ -142,89 +116,114 @@ func streamAudio(c *fiber.Ctx, meta *models.Meta) error {
 		return utils.NotFound(c, utils.ErrFileNotFound, "Audio file not found")
 	}
 
-	// Determine output format
-	format := meta.Format
-	contentType := utils.ContentTypeFromExt(format)
-
-	// Generate filename
-	filename := utils.GenerateOutputFilename(meta)
-	encodedFilename := url.PathEscape(filename)
-
-	// Set response headers
-	c.Set("Content-Type", contentType)
-	c.Set("Transfer-Encoding", "chunked")
-	c.Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"; filename*=UTF-8''%s`, filename, encodedFilename))
-	c.Set("Cache-Control", "no-cache")
-
-	// Check if transcoding is needed
-	inputExt := filepath.Ext(meta.Files.Audio.Name)
-	if len(inputExt) > 0 && inputExt[0] == '.' {
-		inputExt = inputExt[1:]
-	}
-
-	var args []string
+	return runFFmpegStream(c, meta, services.StreamAudioArgs(meta, audioPath))
+}
 
-	if canCopyAudioStream(inputExt, format) {
-		args = []string{
-			"-y",
-			"-i", audioPath,
-			"-c:a", "copy",
-			"-f", getFFmpegFormat(format),
-			"pipe:1",
-		}
-	} else {
-		codec := config.AudioCodecMap[format]
-		if codec == "" {
-			codec = "aac"
-		}
+// streamLog logs /stream sessions and previews (module stream)
+var streamLog = utils.Logger(utils.LogStream)
 
-		bitrate := meta.Bitrate
-		if bitrate == "" {
-			bitrate = "192k"
-		}
+// streamWatchdogKills counts streams killed for stalling or overrunning (exported via /debug/vars)
+var streamWatchdogKills = expvar.NewInt("stream_watchdog_kills")
 
-		args = []string{
-			"-y",
-			"-i", audioPath,
-			"-vn",
-			"-c:a", codec,
-		}
+// streamClientAborts counts streams whose client went away mid-body (exported via /debug/vars)
+var streamClientAborts = expvar.NewInt("stream_client_aborts")
 
-		// Add bitrate for lossy codecs
-		if codec != "pcm_s16le" && codec != "flac" {
-			args = append(args, "-b:a", bitrate)
-		}
+// streamStatusTrailer ends every stream: "ok" when ffmpeg reached EOF and exited cleanly,
+// "error" when the body was cut short (the status line already said 200)
+const streamStatusTrailer = "X-Stream-Status"
 
-		args = append(args, "-f", getFFmpegFormat(format), "pipe:1")
-	}
+// estimatedLengthHeader carries the estimated body size of a stream of copied streams
+const estimatedLengthHeader = "X-Estimated-Content-Length"
 
-	return runFFmpegStream(c, args)
-}
+// runFFmpegStream pipes ffmpeg's output to the client. Headers are only set once ffmpeg
+// has started, so failures before the body still get a JSON error response.
+func runFFmpegStream(c *fiber.Ctx, meta *models.Meta, args []string) error {
+	jobID := meta.ID
 
-func runFFmpegStream(c *fiber.Ctx, args []string) error {
-	cmd := exec.Command("ffmpeg", args...)
-	cmd.Stderr = os.Stderr
+	// Cancelling kills ffmpeg (e.g. when the client goes away)
+	ctx, cancel := context.WithCancel(context.Background())
 
-	stdout, err := cmd.StdoutPipe()
+	stdout, wait, err := services.FFmpeg.StartPipe(services.WithFFmpegJob(ctx, jobID), args)
 	if err != nil {
+		cancel()
 		return utils.InternalError(c, "Failed to start stream")
 	}
 
-	if err := cmd.Start(); err != nil {
-		return utils.InternalError(c, "Failed to start stream")
+	c.Set("Content-Type", utils.ContentTypeFromExt(meta.Format))
+	c.Set("Transfer-Encoding", "chunked")
+	c.Set("Content-Disposition", utils.ContentDisposition(utils.GetDisplayFilename(meta), c.QueryBool("inline")))
+	c.Set("Cache-Control", "no-cache")
+	// Chunked, so no Content-Length; copied streams have a close estimate for progress bars
+	if estimated := services.EstimateStreamSize(meta); estimated > 0 {
+		c.Set(estimatedLengthHeader, strconv.FormatInt(estimated, 10))
 	}
+	c.Response().Header.SetTrailer(streamStatusTrailer)
+	header := &c.Response().Header
+
+	conn := c.Context().Conn()
+	maxDuration := streamMaxDuration(meta)
 
 	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		var totalBytes int64
+		var lastRead, lastWrite atomic.Int64
+		lastRead.Store(time.Now().UnixNano())
+		lastWrite.Store(time.Now().UnixNano())
+
+		// Why the stream was cut short: watchdog kill, read error or ffmpeg exit status
+		var killReason atomic.Value
+		var readErr error
+		clientGone := false
+
+		// A failed write or flush means the client disconnected: kill ffmpeg right away
+		// rather than leaving it to the watchdog
+		abort := func(err error) {
+			clientGone = true
+			cancel()
+			streamClientAborts.Add(1)
+			streamLog.Debug("client disconnected", "job", jobID, "bytes", totalBytes, "err", err)
+		}
+
+		done := make(chan struct{})
+		go watchStream(jobID, maxDuration, &lastRead, &lastWrite, done, func(reason string) {
+			killReason.Store(reason)
+			// Killing ffmpeg ends reads; closing the connection unblocks a stuck write
+			cancel()
+			conn.Close()
+		})
+
 		defer func() {
+			close(done)
 			stdout.Close()
-			cmd.Wait()
+			waitErr := wait()
+			cancel()
+			utils.AddMetaServedBytes(jobID, totalBytes)
+
+			// A client that went away caused the failure; nobody is left to read the trailer
+			if clientGone {
+				return
+			}
+			var failure string
+			if reason, ok := killReason.Load().(string); ok {
+				failure = "stream watchdog: " + reason
+			} else if readErr != nil {
+				failure = "read ffmpeg output: " + readErr.Error()
+			} else if waitErr != nil {
+				failure = "ffmpeg exited: " + waitErr.Error()
+			}
+
+			if failure == "" {
+				header.Set(streamStatusTrailer, "ok")
+				return
+			}
+			header.Set(streamStatusTrailer, "error")
+			streamLog.Warn("stream failed", "job", jobID, "bytes", totalBytes, "reason", failure)
+			utils.UpdateMetaStreamError(jobID, failure, totalBytes)
 		}()
 
 		buf := make([]byte, 64*1024)
 		rateLimit := config.StreamRateLimit
 
 		var startTime time.Time
-		var totalBytes int64
 
 		if rateLimit > 0 {
 			startTime = time.Now()
@@ -233,22 +232,34 @@ func runFFmpegStream(c *fiber.Ctx, args []string) error {
 		for {
 			n, err := stdout.Read(buf)
 			if n > 0 {
+				lastRead.Store(time.Now().UnixNano())
 				if _, writeErr := w.Write(buf[:n]); writeErr != nil {
-					cmd.Process.Kill()
+					abort(writeErr)
 					return
 				}
-				w.Flush()
+				if flushErr := w.Flush(); flushErr != nil {
+					abort(flushErr)
+					return
+				}
+				lastWrite.Store(time.Now().UnixNano())
+				totalBytes += int64(n)
 
 				if rateLimit > 0 {
-					totalBytes += int64(n)
 					expectedDuration := time.Duration(totalBytes) * time.Second / time.Duration(rateLimit)
 					actualDuration := time.Since(startTime)
 					if expectedDuration > actualDuration {
-						time.Sleep(expectedDuration - actualDuration)
+						// Interrupted when the watchdog kills the stream
+						select {
+						case <-ctx.Done():
+						case <-time.After(expectedDuration - actualDuration):
+						}
 					}
 				}
 			}
 			if err != nil {
+				if err != io.EOF {
+					readErr = err
+				}
 				return
 			}
 		}
@@ -257,40 +268,51 @@ func runFFmpegStream(c *fiber.Ctx, args []string) error {
 	return nil
 }
 
-// getFFmpegFormat returns the FFmpeg format name for a given extension
-func getFFmpegFormat(ext string) string {
-	switch ext {
-	case "mp4":
-		return "mp4"
-	case "webm":
-		return "webm"
-	case "mkv":
-		return "matroska"
-	case "mp3":
-		return "mp3"
-	case "m4a":
-		return "ipod" // FFmpeg uses "ipod" for m4a
-	case "opus":
-		return "opus"
-	case "wav":
-		return "wav"
-	case "flac":
-		return "flac"
-	default:
-		return ext
+// streamMaxDuration is how long a stream may run: the output's length plus StreamDurationMargin.
+// Trims shorten the output and audio.tempo stretches or shortens it (OutputDuration).
+func streamMaxDuration(meta *models.Meta) time.Duration {
+	seconds := meta.Duration
+	switch {
+	case meta.OutputDuration > 0:
+		seconds = meta.OutputDuration
+	case meta.Trim != nil:
+		seconds = min(meta.Trim.End, meta.Duration) - meta.Trim.Start
 	}
+	return time.Duration(seconds*float64(time.Second)) + config.StreamDurationMargin
 }
 
-// canCopyAudioStream checks if audio can be copied without re-encoding
-func canCopyAudioStream(inputExt, outputFormat string) bool {
-	if inputExt == outputFormat {
-		return true
-	}
-	if (inputExt == "m4a" || inputExt == "mp4") && (outputFormat == "m4a" || outputFormat == "mp4") {
-		return true
-	}
-	if inputExt == "webm" && outputFormat == "opus" {
-		return true
+// streamWatchdogTick is how often watchStream checks a stream; tests shorten it
+var streamWatchdogTick = config.StreamWatchdogTick
+
+// watchStream kills a stream when ffmpeg output or client writes stall past StreamIdleTimeout,
+// or when it outlives maxDuration (dead connections without RST never fail a write)
+func watchStream(jobID string, maxDuration time.Duration, lastRead, lastWrite *atomic.Int64, done <-chan struct{}, kill func(reason string)) {
+	defer utils.RecoverPanic("stream watchdog")
+	ticker := time.NewTicker(streamWatchdogTick)
+	defer ticker.Stop()
+	start := time.Now()
+
+	for {
+		select {
+		case <-done:
+			return
+		case now := <-ticker.C:
+			var reason string
+			switch {
+			case now.Sub(time.Unix(0, lastRead.Load())) > config.StreamIdleTimeout:
+				reason = "ffmpeg output stalled"
+			case now.Sub(time.Unix(0, lastWrite.Load())) > config.StreamIdleTimeout:
+				reason = "client write stalled"
+			case now.Sub(start) > maxDuration:
+				reason = "max stream duration exceeded"
+			default:
+				continue
+			}
+
+			streamWatchdogKills.Add(1)
+			streamLog.Warn("watchdog: killing stream", "job", jobID, "reason", reason, "totalKills", streamWatchdogKills.Value())
+			kill(reason)
+			return
+		}
 	}
-	return false
 }
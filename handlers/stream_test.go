@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+	"yt-downloader-go/config"
+	"yt-downloader-go/models"
+)
+
+func TestStreamMaxDuration(t *testing.T) {
+	tests := []struct {
+		name string
+		meta models.Meta
+		want time.Duration // Before the margin
+	}{
+		{name: "whole video", meta: models.Meta{Duration: 213}, want: 213 * time.Second},
+		{name: "trim", meta: models.Meta{Duration: 213, Trim: &models.TrimConfig{Start: 10, End: 25}}, want: 15 * time.Second},
+		{name: "trim past the end", meta: models.Meta{Duration: 213, Trim: &models.TrimConfig{Start: 200, End: 500}}, want: 13 * time.Second},
+		{name: "slowed down", meta: models.Meta{Duration: 213, Tempo: 0.5, OutputDuration: 426}, want: 426 * time.Second},
+		{
+			name: "trimmed and sped up",
+			meta: models.Meta{Duration: 213, Tempo: 1.5, Trim: &models.TrimConfig{Start: 10, End: 25}, OutputDuration: 10},
+			want: 10 * time.Second,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := streamMaxDuration(&tt.meta); got != tt.want+config.StreamDurationMargin {
+				t.Errorf("streamMaxDuration = %s, want %s + margin", got, tt.want)
+			}
+		})
+	}
+}
+
+// useWatchdogTimes shortens the watchdog tick and idle timeout for the duration of the test.
+// Tests wait for watchStream to return before they end.
+func useWatchdogTimes(t *testing.T, tick time.Duration, idle time.Duration) {
+	t.Helper()
+	prevTick, prevIdle := streamWatchdogTick, config.StreamIdleTimeout
+	streamWatchdogTick, config.StreamIdleTimeout = tick, idle
+	t.Cleanup(func() { streamWatchdogTick, config.StreamIdleTimeout = prevTick, prevIdle })
+}
+
+func TestWatchStream(t *testing.T) {
+	useWatchdogTimes(t, 5*time.Millisecond, time.Minute)
+
+	tests := []struct {
+		name        string
+		maxDuration time.Duration
+		readAgo     time.Duration // Since ffmpeg output was last read
+		writeAgo    time.Duration // Since the client was last written to
+		want        string        // Kill reason, "" for none
+	}{
+		{name: "healthy", maxDuration: time.Hour},
+		{name: "max duration", maxDuration: 20 * time.Millisecond, want: "max stream duration exceeded"},
+		{name: "ffmpeg stalled", maxDuration: time.Hour, readAgo: 2 * time.Minute, want: "ffmpeg output stalled"},
+		{name: "client stalled", maxDuration: time.Hour, writeAgo: 2 * time.Minute, want: "client write stalled"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var lastRead, lastWrite atomic.Int64
+			lastRead.Store(time.Now().Add(-tt.readAgo).UnixNano())
+			lastWrite.Store(time.Now().Add(-tt.writeAgo).UnixNano())
+
+			done := make(chan struct{})
+			returned := make(chan struct{})
+			var reason string
+			kills := streamWatchdogKills.Value()
+			go func() {
+				defer close(returned)
+				watchStream(testJobID, tt.maxDuration, &lastRead, &lastWrite, done, func(r string) { reason = r })
+			}()
+
+			select {
+			case <-returned:
+			case <-time.After(200 * time.Millisecond):
+				// Still watching: end the stream
+				close(done)
+				<-returned
+			}
+			if reason != tt.want {
+				t.Errorf("kill reason %q, want %q", reason, tt.want)
+			}
+			if tt.want != "" && streamWatchdogKills.Value() != kills+1 {
+				t.Errorf("stream_watchdog_kills %d, want %d", streamWatchdogKills.Value(), kills+1)
+			}
+		})
+	}
+}
@@ -0,0 +1,35 @@
+package handlers
+
+import (
+	"bytes"
+	_ "embed"
+	"html"
+	"yt-downloader-go/config"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+//go:embed ui/index.html
+var uiPage []byte
+
+// uiCSP lets the page run its inline script and call the API at BASE_URL
+var uiCSP = "default-src 'none'; script-src 'unsafe-inline'; style-src 'unsafe-inline'; connect-src 'self' " + config.BaseURL + "; frame-ancestors 'none'"
+
+// renderedUIPage is the page with BASE_URL filled in, built once
+var renderedUIPage = bytes.Replace(uiPage, []byte("{{BASE_URL}}"), []byte(html.EscapeString(config.PublicURL)), 1)
+
+// HandleUI handles GET /ui
+// @Summary Web UI
+// @Description Minimal page for manual testing: submits to /api/download, polls the status URL and links the result. Not registered with UI_ENABLED=false.
+// @Tags ui
+// @Produce html
+// @Success 200 {string} string "HTML page"
+// @Router /ui [get]
+func HandleUI(c *fiber.Ctx) error {
+	c.Set("Content-Type", fiber.MIMETextHTMLCharsetUTF8)
+	c.Set("Content-Security-Policy", uiCSP)
+	c.Set("X-Content-Type-Options", "nosniff")
+	c.Set("X-Frame-Options", "DENY")
+	c.Set("Cache-Control", "no-cache")
+	return c.Send(renderedUIPage)
+}
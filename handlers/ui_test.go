@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"html"
+	"net/http/httptest"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+	"yt-downloader-go/config"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestUIEnabled(t *testing.T) {
+	prev := config.UIEnabled
+	t.Cleanup(func() { config.UIEnabled = prev })
+
+	for _, enabled := range []bool{true, false} {
+		config.UIEnabled = enabled
+		app := fiber.New()
+		RegisterRoutes(app)
+		resp, err := app.Test(httptest.NewRequest("GET", config.PathPrefix+"/ui", nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+
+		want := fiber.StatusNotFound
+		if enabled {
+			want = fiber.StatusOK
+		}
+		if resp.StatusCode != want {
+			t.Errorf("UI_ENABLED=%t: status %d, want %d", enabled, resp.StatusCode, want)
+		}
+	}
+}
+
+// The page calls /api/download and the status URL it returns, nothing else
+func TestUIUsesPublicAPIOnly(t *testing.T) {
+	page := string(renderedUIPage)
+
+	fetches := regexp.MustCompile(`fetch\(([^,)]+)`).FindAllStringSubmatch(page, -1)
+	allowed := []string{"API + '/api/download'", "statusUrl"}
+	if len(fetches) != len(allowed) {
+		t.Errorf("page fetches %q, want %q", fetches, allowed)
+	}
+	for _, fetch := range fetches {
+		if target := strings.TrimSpace(fetch[1]); !slices.Contains(allowed, target) {
+			t.Errorf("page fetches %s, want only %q", target, allowed)
+		}
+	}
+
+	for _, private := range []string{"/api/admin", "/debug/vars", "X-API-Key", "/api/jobs"} {
+		if strings.Contains(page, private) {
+			t.Errorf("page references %s", private)
+		}
+	}
+	if base := `<meta name="api-base" content="` + html.EscapeString(config.PublicURL) + `">`; !strings.Contains(page, base) {
+		t.Errorf("page lacks %s", base)
+	}
+}
@@ -1,21 +1,21 @@
 package main
 
 import (
+	"flag"
 	"fmt"
-	"net"
 	"os"
 	"os/signal"
 	"syscall"
 	"yt-downloader-go/config"
 	_ "yt-downloader-go/docs"
 	"yt-downloader-go/handlers"
+	"yt-downloader-go/models"
+	"yt-downloader-go/services"
 	"yt-downloader-go/utils"
 
 	"github.com/gofiber/fiber/v2"
-	"github.com/gofiber/fiber/v2/middleware/cors"
 	"github.com/gofiber/fiber/v2/middleware/logger"
 	"github.com/gofiber/fiber/v2/middleware/recover"
-	"github.com/gofiber/swagger"
 )
 
 // @title YT Downloader API
@@ -34,25 +34,79 @@ import (
 // @schemes https http
 
 func main() {
+	checkOnly := flag.Bool("check", false, "Run startup checks and exit (non-zero on failure)")
+	flag.Parse()
+
+	// Leveled logging per module (LOG_LEVEL, LOG_LEVEL_<module>)
+	utils.InitLogging()
+
+	// Fail fast on a broken environment instead of on the first job
+	if !runStartupChecks() {
+		os.Exit(1)
+	}
+	if *checkOnly {
+		return
+	}
+
 	if err := os.MkdirAll(config.StorageDir, 0755); err != nil {
 		panic(fmt.Sprintf("Failed to create storage directory: %v", err))
 	}
 
+	// Recorded with each job's ffmpeg commands
+	services.CaptureFFmpegVersion()
+
+	// Clear run locks left by a previous (crashed) process
+	utils.ClearStaleRunLocks()
+
+	// Store meta.json files of older builds in the current schema (META_MIGRATE_ON_START)
+	if config.MetaMigrateOnStart {
+		serverLog.Info("migrated job metas", "count", utils.MigrateMetas(), "schemaVersion", models.MetaSchemaVersion)
+	}
+
+	// Restore processing averages for admission estimates
+	services.LoadProcessingStats()
+
+	serverLog.Info("download transport", "transport", config.DescribeDownloadTransport())
+	if config.DebugRecordExtract != "" {
+		serverLog.Warn("recording extract API responses (DEBUG_RECORD_EXTRACT)", "dir", config.DebugRecordExtract)
+	}
+	if config.ExtractReplayDir != "" {
+		serverLog.Warn("replaying extract API responses (EXTRACT_REPLAY_DIR), the extract API is not called", "dir", config.ExtractReplayDir)
+	}
+
+	// Probe encoders so unsupported audio.codec requests fail validation
+	if err := services.ProbeEncoders(); err != nil {
+		serverLog.Warn("encoder probe failed, codec overrides unchecked", "err", err)
+	}
+
+	// Archive removed jobs (ARCHIVE_JOBS); flushed on shutdown
+	utils.StartArchiveWriter()
+	defer utils.StopArchiveWriter()
+
 	// Start cleanup scheduler
 	cleanupCron := utils.StartCleanupScheduler()
 	defer cleanupCron.Stop()
 
 	// Create Fiber app
-	app := fiber.New(fiber.Config{
+	appConfig := fiber.Config{
 		AppName:       "YouTube Downloader Go",
 		ServerHeader:  "yt-downloader-go",
 		CaseSensitive: true,
 		StrictRouting: false,
-		// Disable body limit for file streaming
-		BodyLimit: 0,
+		// Request bodies only: responses (files, streams) aren't limited. The JSON API
+		// routes get their own, lower limit with a JSON error (handlers.BodyLimit).
+		BodyLimit: config.MaxRequestBodySize,
 		// Enable IPv6 (dual-stack)
 		Network: "tcp",
-	})
+		// JSON error bodies for unknown routes, wrong methods and panics
+		ErrorHandler: handlers.HandleError,
+	}
+	// Client IP from the proxy's X-Forwarded-For (job client info, IP-bound links)
+	if config.TrustProxy {
+		appConfig.ProxyHeader = fiber.HeaderXForwardedFor
+		appConfig.EnableIPValidation = true
+	}
+	app := fiber.New(appConfig)
 
 	// Middleware
 	app.Use(recover.New())
@@ -60,29 +114,9 @@ func main() {
 		Format:     "${time} | ${status} | ${latency} | ${method} ${path}\n",
 		TimeFormat: "2006-01-02 15:04:05",
 	}))
-	app.Use(cors.New(cors.Config{
-		AllowOrigins: "*",
-		AllowMethods: "GET,POST,DELETE,OPTIONS",
-		AllowHeaders: "Content-Type,Accept",
-	}))
-
-	// Swagger docs
-	app.Get("/swagger/*", swagger.HandlerDefault)
-
-	// API routes
-	api := app.Group("/api")
-	api.Post("/download", handlers.HandleDownload)
-	api.Get("/status/:id", handlers.HandleStatus)
-	api.Delete("/jobs/:id", handlers.HandleDeleteJob)
+	app.Use(handlers.CORS())
 
-	// File serving
-	app.Get("/files/:id/:filename", handlers.HandleFiles)
-
-	// Stream serving (FFmpeg pipe)
-	app.Get("/stream/:id", handlers.HandleStream)
-
-	// Health check
-	app.Get("/health", handlers.HandleHealth)
+	handlers.RegisterRoutes(app)
 
 	go func() {
 		sigChan := make(chan os.Signal, 1)
@@ -91,13 +125,24 @@ func main() {
 		app.Shutdown()
 	}()
 
-	addr := fmt.Sprintf(":%d", config.Port)
-	ln, err := net.Listen("tcp6", addr)
+	ln, network, err := utils.Listen(config.ListenNetwork, config.ListenAddr)
 	if err != nil {
 		panic(fmt.Sprintf("Failed to create listener: %v", err))
 	}
+	serverLog.Info("listening", "addr", ln.Addr().String(), "network", network)
 
 	if err := app.Listener(ln); err != nil {
 		panic(fmt.Sprintf("Failed to start server: %v", err))
 	}
 }
+
+var serverLog = utils.Logger(utils.LogServer)
+
+// runStartupChecks prints the startup check table and reports whether startup may continue
+func runStartupChecks() bool {
+	results := services.RunStartupChecks()
+	for _, r := range results {
+		fmt.Printf("%-5s %-15s %s\n", r.Status, r.Name, r.Detail)
+	}
+	return !services.HasFailedCheck(results)
+}
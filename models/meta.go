@@ -0,0 +1,82 @@
+package models
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// MetaSchemaVersion is the meta.json layout this build writes
+// 0: unversioned (before schemaVersion); may lack expiresAt, jobError and displayFilename
+// 1: schemaVersion added; those fields are always set where they apply
+const MetaSchemaVersion = 1
+
+// metaFields are the JSON names of Meta's fields; anything else read is kept in Unknown
+var metaFields = jsonFieldNames(reflect.TypeOf(Meta{}))
+
+// jsonFieldNames returns the JSON names of a struct type's fields
+func jsonFieldNames(t reflect.Type) map[string]bool {
+	names := map[string]bool{}
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		if name != "" && name != "-" {
+			names[name] = true
+		}
+	}
+	return names
+}
+
+// metaJSON is Meta without its JSON methods
+type metaJSON Meta
+
+// UnmarshalJSON reads meta.json, keeping fields this build doesn't know in Unknown
+func (m *Meta) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, (*metaJSON)(m)); err != nil {
+		return err
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	m.Unknown = nil
+	for name, value := range fields {
+		if metaFields[name] {
+			continue
+		}
+		if m.Unknown == nil {
+			m.Unknown = map[string]json.RawMessage{}
+		}
+		m.Unknown[name] = value
+	}
+	return nil
+}
+
+// MarshalJSON writes the known fields followed by Unknown, so a read-modify-write by an
+// older build doesn't drop what a newer one added
+func (m Meta) MarshalJSON() ([]byte, error) {
+	data, err := json.Marshal(metaJSON(m))
+	if err != nil || len(m.Unknown) == 0 {
+		return data, err
+	}
+
+	names := make([]string, 0, len(m.Unknown))
+	for name := range m.Unknown {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var buf bytes.Buffer
+	buf.Write(data[:len(data)-1])
+	for _, name := range names {
+		key, _ := json.Marshal(name)
+		buf.WriteByte(',')
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(m.Unknown[name])
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
@@ -1,86 +1,469 @@
 package models
 
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+)
+
 // DownloadRequest represents the incoming download request
 // @Description Download request payload
 type DownloadRequest struct {
-	URL    string       `json:"url" example:"https://youtube.com/watch?v=dQw4w9WgXcQ"`
-	OS     string       `json:"os,omitempty" example:"windows" enums:"ios,android,macos,windows,linux"`
-	Output OutputConfig `json:"output"`
-	Audio  AudioConfig  `json:"audio,omitempty"`
-	Trim   *TrimConfig  `json:"trim,omitempty"`
+	URL            string       `json:"url" example:"https://youtube.com/watch?v=dQw4w9WgXcQ"`
+	OS             string       `json:"os,omitempty" example:"windows" enums:"ios,android,macos,windows,linux"`
+	Output         OutputConfig `json:"output"`
+	Outputs        []OutputSpec `json:"outputs,omitempty"` // Several outputs from one download (instead of output; max 3)
+	Audio          AudioConfig  `json:"audio,omitempty"`
+	Trim           *TrimConfig  `json:"trim,omitempty"`
+	BindIP         string       `json:"bindIp,omitempty" example:"203.0.113.7"`                      // Strict mode: bind links to this IP (default: caller IP)
+	SessionID      string       `json:"sessionId,omitempty" example:"c2Vzc2lvbi0xMjM"`               // Strict mode: bind links to this session instead of IP
+	Priority       string       `json:"priority,omitempty" example:"normal" enums:"low,normal,high"` // high requires an authorized X-API-Key
+	IdempotencyKey string       `json:"idempotencyKey,omitempty" example:"9b2f6c1e-retry-safe"`      // Same as the Idempotency-Key header
+	KeepSources    *bool        `json:"keepSources,omitempty" example:"true"`                        // Retain sources for /api/jobs/:id/convert (default: server config)
+	AllowTranscode bool         `json:"allowTranscode,omitempty" example:"false"`                    // No stream playable on os: transcode the best one to H.264
+}
+
+// JobRequest is the normalized DownloadRequest a job was created with, kept in meta.
+// It is an allowlist: client binding (bindIp, sessionId) and idempotency fields are left
+// out, and fields added to DownloadRequest only appear here once copied explicitly.
+// @Description Parameters the job was created with (normalized)
+type JobRequest struct {
+	URL            string       `json:"url" example:"https://youtube.com/watch?v=dQw4w9WgXcQ"`
+	OS             string       `json:"os,omitempty" example:"windows"`
+	Output         OutputConfig `json:"output"`
+	Outputs        []OutputSpec `json:"outputs,omitempty"`
+	Audio          AudioConfig  `json:"audio"`
+	Trim           *TrimConfig  `json:"trim,omitempty"` // Includes a start taken from the URL (?t=)
+	Priority       string       `json:"priority" example:"normal"`
+	KeepSources    *bool        `json:"keepSources,omitempty" example:"true"`
+	AllowTranscode bool         `json:"allowTranscode,omitempty" example:"false"`
 }
 
 // OutputConfig specifies output format and quality
 // @Description Output configuration
 type OutputConfig struct {
-	Type    string `json:"type" example:"video" enums:"video,audio"`
-	Format  string `json:"format" example:"mp4" enums:"mp4,webm,mkv,mp3,m4a,wav,opus,flac"`
-	Quality string `json:"quality,omitempty" example:"1080p" enums:"2160p,1440p,1080p,720p,480p,360p"`
+	Type        string `json:"type" example:"video" enums:"video,audio"`
+	Format      string `json:"format" example:"mp4" enums:"auto,mp4,webm,mkv,mp3,m4a,m4b,wav,opus,ogg,flac"`
+	Quality     string `json:"quality,omitempty" example:"1080p" enums:"2160p,1440p,1080p,720p,480p,360p"`
+	AudioOnly   bool   `json:"audioOnly,omitempty" example:"false"`   // Video formats: extract audio instead (mp4→m4a, webm→opus, mkv→auto)
+	StaticVideo bool   `json:"staticVideo,omitempty" example:"false"` // Audio over the thumbnail as a still video (type audio, format mp4/mkv)
+	Autorotate  *bool  `json:"autorotate,omitempty" example:"true"`   // Video only, default true: apply or keep the source rotation; false leaves it as in the source
+	ForceRotate bool   `json:"forceRotate,omitempty" example:"false"` // Video only: re-encode a rotated source that would be copied, baking the rotation in
+}
+
+// OutputSpec is one entry of DownloadRequest.Outputs
+// @Description Output of a multi-output job
+type OutputSpec struct {
+	Type    string `json:"type" example:"audio" enums:"video,audio"`
+	Format  string `json:"format" example:"mp3"`
+	Quality string `json:"quality,omitempty" example:"1080p"` // Video outputs share one quality (one source download)
+	Bitrate string `json:"bitrate,omitempty" example:"320k"`
 }
 
 // AudioConfig specifies audio track and bitrate
 // @Description Audio configuration
 type AudioConfig struct {
-	TrackID string `json:"trackId,omitempty" example:"en.vss_abc123"`
-	Bitrate string `json:"bitrate,omitempty" example:"192k" enums:"64k,128k,192k,320k"`
+	TrackID         string  `json:"trackId,omitempty" example:"en.vss_abc123"`
+	Bitrate         string  `json:"bitrate,omitempty" example:"192k" enums:"64k,128k,192k,320k"`
+	VBR             string  `json:"vbr,omitempty" example:"V0" enums:"V0,V1,V2,V3,V4,V5,V6,V7,V8,V9"`         // mp3/opus/ogg only; excludes bitrate
+	Codec           string  `json:"codec,omitempty" example:"opus" enums:"opus,vorbis,aac,aac_he,libfdk_aac"` // ogg: opus/vorbis; m4a/m4b: aac/aac_he/libfdk_aac
+	Strict          bool    `json:"strict,omitempty" example:"false"`                                         // Reject out-of-range bitrates instead of clamping
+	StrictLossless  bool    `json:"strictLossless,omitempty" example:"false"`                                 // Reject wav/flac output from a lossy source (422)
+	AutoTrimSilence bool    `json:"autoTrimSilence,omitempty" example:"false"`                                // Audio outputs only; ignored when trim is set
+	Transcript      bool    `json:"transcript,omitempty" example:"false"`                                     // Audio outputs only; save captions as transcript.vtt/.txt
+	Tempo           float64 `json:"tempo,omitempty" example:"0.75"`                                           // Audio outputs only: playback speed 0.5–2.0 at the same pitch
+	PitchSemitones  int     `json:"pitchSemitones,omitempty" example:"-2"`                                    // Audio outputs only: pitch shift -12..12 at the same speed
+	Mute            bool    `json:"mute,omitempty" example:"false"`                                           // Video outputs only: no audio stream is downloaded or written
+	Channels        string  `json:"channels,omitempty" example:"stereo" enums:"keep,stereo,mono"`             // Audio outputs only; default downmixes surround to stereo for lossy formats
 }
 
 // TrimConfig specifies trim start and end times
 // @Description Trim configuration
 type TrimConfig struct {
-	Start    float64 `json:"start" example:"10"`
-	End      float64 `json:"end" example:"60"`
-	Accurate bool    `json:"accurate,omitempty" example:"false"`
+	Start    float64     `json:"start" example:"10"`
+	End      float64     `json:"end" example:"60"`
+	Accurate bool        `json:"accurate,omitempty" example:"false"`
+	Fade     *FadeConfig `json:"fade,omitempty"` // Requires re-encoding; forces accurate mode
+}
+
+// FadeConfig specifies fade-in and fade-out durations in seconds
+// @Description Fade configuration
+type FadeConfig struct {
+	In    float64 `json:"in,omitempty" example:"1.5"`
+	Out   float64 `json:"out,omitempty" example:"2"`
+	Video bool    `json:"video,omitempty" example:"false"` // Also fade video from/to black
 }
 
 // DownloadResponse is returned when a job is created
 // @Description Response after creating a download job
 type DownloadResponse struct {
-	StatusURL           string  `json:"statusUrl" example:"https://api.ytconvert.org/api/status/V1StGXR8_Z5jdHi?token=xxx&expires=xxx"`
-	Title               string  `json:"title" example:"Rick Astley - Never Gonna Give You Up"`
-	Duration            float64 `json:"duration" example:"213.5"`
-	RequestedQuality    string  `json:"requestedQuality,omitempty" example:"1080p"`
-	SelectedQuality     string  `json:"selectedQuality,omitempty" example:"720p"`
-	QualityChanged      bool    `json:"qualityChanged" example:"true"`
-	QualityChangeReason string  `json:"qualityChangeReason,omitempty" example:"1080p not available, using 720p"`
-	NeedsReencode       bool    `json:"needsReencode" example:"false"`
+	StatusURL           string           `json:"statusUrl" example:"https://api.ytconvert.org/api/status/V1StGXR8_Z5jdHi?token=xxx&expires=xxx"`
+	Title               string           `json:"title" example:"Rick Astley - Never Gonna Give You Up"`
+	Duration            float64          `json:"duration" example:"213.5"`
+	RequestedQuality    string           `json:"requestedQuality,omitempty" example:"1080p"`
+	SelectedQuality     string           `json:"selectedQuality,omitempty" example:"720p"`
+	QualityChanged      bool             `json:"qualityChanged" example:"true"`
+	QualityChangeReason string           `json:"qualityChangeReason,omitempty" example:"1080p not available, using 720p"`
+	NeedsReencode       bool             `json:"needsReencode" example:"false"`
+	ResolvedFormat      string           `json:"resolvedFormat" example:"mp4"`
+	ResolvedBitrate     string           `json:"resolvedBitrate,omitempty" example:"192k"` // Requested or format default; omitted for lossless
+	Selection           *StreamSelection `json:"selection,omitempty"`
+	Outputs             []OutputStatus   `json:"outputs,omitempty"`                         // Additional outputs of a multi-output job
+	LossyToLossless     bool             `json:"lossyToLossless,omitempty" example:"false"` // Lossless output from a lossy source: larger file, no quality gain
+	TrimFromURL         bool             `json:"trimFromURL,omitempty" example:"false"`
+	Muted               bool             `json:"muted,omitempty" example:"false"`            // Video output without an audio stream (audio.mute)
+	Replayed            bool             `json:"replayed,omitempty" example:"false"`         // Response of an earlier request with the same idempotency key
+	SelectionChanged    bool             `json:"selectionChanged,omitempty" example:"false"` // Streams differ from a recent identical request (upstream dropped them)
+	Plan                *JobPlan         `json:"plan,omitempty"`
+	Warnings            Warnings         `json:"warnings"` // Everything accepted differently from the request; empty when none
 }
 
+// JobPlan is the processing decided for a job when it is created (services.PlanJob)
+// @Description Planned processing, for estimating the cost of a job before submitting more
+type JobPlan struct {
+	Download          string         `json:"download" example:"parallel" enums:"parallel,single"`   // Ranged parallel workers, or one request per source
+	Merge             string         `json:"merge" example:"copy" enums:"copy,transcode"`           // Sources stream-copied or re-encoded into the output
+	Trim              string         `json:"trim" example:"none" enums:"none,fast,accurate"`        // fast cuts at keyframes; accurate re-encodes
+	Delivery          string         `json:"delivery" example:"file" enums:"file,stream"`           // Pre-rendered file, or served via /stream only
+	EstimatedCPUClass string         `json:"estimatedCpuClass" example:"light" enums:"light,heavy"` // heavy = any re-encoding
+	Thresholds        PlanThresholds `json:"thresholds"`
+}
+
+// PlanThresholds are the limits a plan was decided with
+type PlanThresholds struct {
+	ParallelDownloadBytes int64   `json:"parallelDownloadBytes" example:"10000000"` // Sources larger than this download in parallel
+	MaxFileDuration       float64 `json:"maxFileDuration" example:"14400"`          // Longest job pre-rendered for its CPU class (seconds)
+}
+
+// Job plan values
+const (
+	PlanDownloadParallel = "parallel"
+	PlanDownloadSingle   = "single"
+	PlanCopy             = "copy"
+	PlanTranscode        = "transcode"
+	PlanTrimNone         = "none"
+	PlanTrimFast         = "fast"
+	PlanTrimAccurate     = "accurate"
+	PlanDeliveryFile     = "file"
+	PlanDeliveryStream   = "stream"
+	CPUClassLight        = "light"
+	CPUClassHeavy        = "heavy"
+)
+
+// Rotation handling of a video output (VideoRotation.Action)
+const (
+	RotationNone      = "none"      // Source not rotated
+	RotationApplied   = "applied"   // The re-encode applies the rotation to the frames
+	RotationForced    = "forced"    // Re-encoded only to apply the rotation (output.forceRotate)
+	RotationPreserved = "preserved" // Streams copied with the rotation kept as metadata
+	RotationIgnored   = "ignored"   // output.autorotate false: left as in the source
+)
+
+// Requested channel handling of an audio output (AudioConfig.Channels; "" = automatic)
+const (
+	ChannelModeKeep   = "keep"
+	ChannelModeStereo = "stereo"
+	ChannelModeMono   = "mono"
+)
+
+// Channel handling of an audio output (AudioChannels.Action)
+const (
+	ChannelsKept      = "kept"      // Output has the source's channels
+	ChannelsDownmixed = "downmixed" // Mixed down to stereo or mono
+	ChannelsUpmixed   = "upmixed"   // Mono source duplicated to stereo (audio.channels stereo)
+)
+
+// AudioChannels records the channel layout of the source audio and of the output
+// @Description Source and output audio channels
+type AudioChannels struct {
+	SourceChannels int    `json:"sourceChannels" example:"6"`
+	SourceLayout   string `json:"sourceLayout,omitempty" example:"5.1(side)"` // As reported by ffprobe; empty when unknown
+	Channels       int    `json:"channels" example:"2"`
+	Layout         string `json:"layout,omitempty" example:"stereo"`
+	Action         string `json:"action" example:"downmixed" enums:"kept,downmixed,upmixed"`
+}
+
+// VideoRotation records the rotation of the source video and how the output handles it
+// @Description Detected source rotation and its handling
+type VideoRotation struct {
+	Degrees int    `json:"degrees" example:"90"` // Clockwise display rotation of the source: 0, 90, 180 or 270
+	Action  string `json:"action" example:"preserved" enums:"none,applied,forced,preserved,ignored"`
+}
+
+// StreamSelection describes the selected source streams and the planned processing
+// @Description Selected streams and processing plan
+type StreamSelection struct {
+	Video      *SelectedStream `json:"video,omitempty"`
+	Audio      *SelectedStream `json:"audio,omitempty"`
+	Merge      bool            `json:"merge" example:"true"`       // Video and audio are combined into one file
+	Transcode  bool            `json:"transcode" example:"false"`  // Re-encoding (instead of stream copy)
+	StreamOnly bool            `json:"streamOnly" example:"false"` // Too long to pre-merge; served via /stream only
+	SourceSize int64           `json:"sourceSize,omitempty" example:"220200960"`
+}
+
+// SelectedStream holds a selected source stream's technical details
+// @Description Selected source stream
+type SelectedStream struct {
+	Itag     int    `json:"itag,omitempty" example:"399"`
+	Codec    string `json:"codec" example:"av01"`
+	Height   int    `json:"height,omitempty" example:"1080"`
+	FPS      int    `json:"fps,omitempty" example:"30"`
+	Bitrate  int64  `json:"bitrate,omitempty" example:"4200000"` // bits/s
+	Size     int64  `json:"size,omitempty" example:"210000000"`
+	TrackID  string `json:"trackId,omitempty" example:"en.4"`
+	Language string `json:"language,omitempty" example:"en"`
+}
+
+// Job priorities (order of FFmpeg slot acquisition)
+const (
+	PriorityLow    = "low"
+	PriorityNormal = "normal"
+	PriorityHigh   = "high"
+)
+
 // Job status constants
 const (
-	StatusPending   = "pending"
-	StatusCompleted = "completed"
-	StatusError     = "error"
+	StatusPending    = "pending"
+	StatusProcessing = "processing" // Downloads done, FFmpeg running
+	StatusCompleted  = "completed"
+	StatusError      = "error"
 )
 
+// Job error phases
+const (
+	PhaseExtract    = "extract"
+	PhaseValidation = "validation"
+	PhaseDownload   = "download"
+	PhaseProcessing = "processing"
+)
+
+// Job error codes
+const (
+	JobErrOriginForbidden = "ORIGIN_FORBIDDEN"
+	JobErrOriginNotFound  = "ORIGIN_NOT_FOUND"
+	JobErrRateLimited     = "RATE_LIMITED"
+	JobErrOriginError     = "ORIGIN_ERROR"
+	JobErrNetwork         = "NETWORK_ERROR"
+	JobErrTimeout         = "TIMEOUT"
+	JobErrDownloadFailed  = "DOWNLOAD_FAILED"
+	JobErrFFmpegFailed    = "FFMPEG_FAILED"
+	JobErrInvalidTrim     = "INVALID_TRIM"
+	JobErrInternal        = "INTERNAL_ERROR"
+	JobErrFailedByAdmin   = "FAILED_BY_ADMIN"
+	JobErrStorageFull     = "STORAGE_FULL"
+	JobErrSourceTooLarge  = "SOURCE_TOO_LARGE"
+	JobErrDownloadCorrupt = "DOWNLOAD_CORRUPT"
+)
+
+// JobError is a structured job failure that clients can act on
+// @Description Structured job error
+type JobError struct {
+	Code      string `json:"code" example:"ORIGIN_FORBIDDEN"`
+	Message   string `json:"message" example:"Download failed: chunk 12 failed: HTTP 403: Forbidden"`
+	Retryable bool   `json:"retryable" example:"true"`
+	Phase     string `json:"phase" example:"download" enums:"extract,validation,download,processing"`
+}
+
+func (e *JobError) Error() string {
+	return e.Message
+}
+
+// Warning codes: the request was accepted, but not exactly as asked
+const (
+	WarnQualityDowngraded  = "QUALITY_DOWNGRADED"   // output.quality not available; a lower one was selected
+	WarnVideoTranscoded    = "VIDEO_TRANSCODED"     // No stream plays on the os; the video is re-encoded (allowTranscode)
+	WarnAudioTrackFallback = "AUDIO_TRACK_FALLBACK" // audio.trackId not found; the default track was selected
+	WarnSelectionChanged   = "SELECTION_CHANGED"    // Streams differ from a recent identical request
+	WarnBitrateClamped     = "BITRATE_CLAMPED"      // audio.bitrate outside the encoder's range
+	WarnTrimClamped        = "TRIM_CLAMPED"         // trim.end past the end of the video
+	WarnLossyToLossless    = "LOSSY_TO_LOSSLESS"    // Lossless output from a lossy source
+	WarnStreamOnly         = "STREAM_ONLY"          // Too long to pre-render; served via /stream only
+)
+
+// StreamOnlyMessage is the message of a STREAM_ONLY warning, added on creation or once
+// the job turns out to be stream-only when processing starts
+const StreamOnlyMessage = "Too long to pre-render; the output is served via /stream only"
+
+// Warning is something the server decided differently from the request
+// @Description Request accepted with a change clients may want to show
+type Warning struct {
+	Code    string `json:"code" example:"QUALITY_DOWNGRADED" enums:"QUALITY_DOWNGRADED,VIDEO_TRANSCODED,AUDIO_TRACK_FALLBACK,SELECTION_CHANGED,BITRATE_CLAMPED,TRIM_CLAMPED,LOSSY_TO_LOSSLESS,STREAM_ONLY"`
+	Message string `json:"message" example:"1080p not available, using 720p"`
+	Field   string `json:"field,omitempty" example:"output.quality"` // Request field the warning is about
+}
+
+// Warnings collects the warnings of a request; a nil *Warnings discards them
+type Warnings []Warning
+
+// Add records a warning unless one with the same code and field was recorded
+func (w *Warnings) Add(code string, field string, message string) {
+	if w == nil {
+		return
+	}
+	for _, existing := range *w {
+		if existing.Code == code && existing.Field == field {
+			return
+		}
+	}
+	*w = append(*w, Warning{Code: code, Message: message, Field: field})
+}
+
 // StatusResponse is returned when checking job status
 // @Description Job status response
 type StatusResponse struct {
-	Status      string  `json:"status" example:"pending" enums:"pending,completed,error"`
-	Progress    int     `json:"progress" example:"45"`
-	Title       string  `json:"title,omitempty" example:"Rick Astley - Never Gonna Give You Up"`
-	Duration    float64 `json:"duration,omitempty" example:"213.5"`
-	DownloadURL string  `json:"downloadUrl,omitempty" example:"https://api.ytconvert.org/files/abc123/output.mp4?token=xxx&expires=123"`
-	JobError    string  `json:"jobError,omitempty" example:"Download failed: connection timeout"`
+	Status               string            `json:"status" example:"pending" enums:"pending,processing,completed,error"`
+	Progress             int               `json:"progress" example:"45"`
+	Title                string            `json:"title,omitempty" example:"Rick Astley - Never Gonna Give You Up"`
+	Duration             float64           `json:"duration,omitempty" example:"213.5"`
+	OutputDuration       float64           `json:"outputDuration,omitempty" example:"284.7"` // Output length (seconds) when audio.tempo changes it; duration is the video's
+	DownloadURL          string            `json:"downloadUrl,omitempty" example:"https://api.ytconvert.org/files/abc123/output.mp4?token=xxx&expires=123"`
+	DownloadURLExpiresAt int64             `json:"downloadUrlExpiresAt,omitempty" example:"1705125856789"` // When downloadUrl stops being accepted for new requests (ms)
+	ShareURL             string            `json:"shareUrl,omitempty" example:"https://api.ytconvert.org/d/abc123/Video_Title.mp4?t=xxx"`
+	EstimatedSize        int64             `json:"estimatedSize,omitempty" example:"734003200"` // Stream-only jobs of copied streams: estimated /stream body size (bytes)
+	JobError             string            `json:"jobError,omitempty" example:"Download failed: connection timeout"`
+	JobErrorDetail       *JobError         `json:"jobErrorDetail,omitempty"`
+	SilenceTrim          *SilenceTrim      `json:"silenceTrim,omitempty"`
+	Rotation             *VideoRotation    `json:"rotation,omitempty"`                  // Video jobs once downloaded
+	AudioChannels        *AudioChannels    `json:"audioChannels,omitempty"`             // Jobs with audio once downloaded
+	Muted                bool              `json:"muted,omitempty" example:"false"`     // Video output without an audio stream (audio.mute)
+	Faststart            bool              `json:"faststart,omitempty" example:"true"`  // mp4-family output with its moov atom first (progressive playback)
+	QueuePosition        int               `json:"queuePosition,omitempty" example:"3"` // 1-based position among jobs waiting to download or for processing
+	Selection            *StreamSelection  `json:"selection,omitempty"`
+	LossyToLossless      bool              `json:"lossyToLossless,omitempty" example:"false"`
+	Warnings             Warnings          `json:"warnings"`                             // Everything accepted differently from the request; empty when none
+	Outputs              []OutputStatus    `json:"outputs,omitempty"`                    // Additional outputs (POST /api/jobs/:id/convert)
+	ExpiresAt            int64             `json:"expiresAt" example:"1705124056789"`    // When the job is removed (ms)
+	MaxExpiresAt         int64             `json:"maxExpiresAt" example:"1705142056789"` // Latest expiresAt reachable via POST /api/jobs/:id/extend (ms)
+	Author               string            `json:"author,omitempty" example:"Rick Astley"`
+	UploadDate           string            `json:"uploadDate,omitempty" example:"2009-10-25"`
+	ViewCount            int64             `json:"viewCount,omitempty" example:"1500000000"`
+	ThumbnailURL         string            `json:"thumbnailUrl,omitempty" example:"https://i.ytimg.com/vi/dQw4w9WgXcQ/hqdefault.jpg"`
+	Transcript           *TranscriptStatus `json:"transcript,omitempty"`                              // audio.transcript jobs with captions, once completed
+	TranscriptAvailable  *bool             `json:"transcriptAvailable,omitempty" example:"true"`      // audio.transcript jobs, once completed
+	LastStreamError      *StreamError      `json:"lastStreamError,omitempty"`                         // Most recent /stream transfer that ended early
+	Request              *JobRequest       `json:"request,omitempty"`                                 // Only with ?includeRequest=1
+	GroupID              string            `json:"groupId,omitempty" example:"Uakgb_J5m9g-0JDMbcJqL"` // Job group the job belongs to
+	Stale                bool              `json:"stale,omitempty" example:"false"`                   // The job's metadata couldn't be read; this is the last status returned
+}
+
+// StreamError records a /stream transfer cut short after the response headers were sent
+// @Description Failed stream transfer (the response ended with X-Stream-Status: error)
+type StreamError struct {
+	Error     string `json:"error" example:"ffmpeg exited: exit status 1"`
+	BytesSent int64  `json:"bytesSent" example:"1048576"` // Body bytes written before the failure
+	At        int64  `json:"at" example:"1705124056789"`  // Unix ms
+}
+
+// Transcript is the caption track chosen for a job's transcript artifact
+type Transcript struct {
+	URL       string `json:"url,omitempty"` // Caption source; empty when the video has none
+	Language  string `json:"language,omitempty"`
+	Name      string `json:"name,omitempty"`
+	Generated bool   `json:"generated,omitempty"` // Speech recognition (ASR) captions
+	Available bool   `json:"available"`           // transcript.vtt/.txt were written
+}
+
+// TranscriptStatus links the transcript artifacts of a completed job
+// @Description Transcript files, aligned to the trimmed output
+type TranscriptStatus struct {
+	VTTURL    string `json:"vttUrl" example:"https://api.ytconvert.org/files/abc123/transcript.vtt?t=xxx"`
+	TextURL   string `json:"textUrl" example:"https://api.ytconvert.org/files/abc123/transcript.txt?t=xxx"`
+	Language  string `json:"language" example:"en"`
+	Generated bool   `json:"generated" example:"false"` // Speech recognition (ASR) captions
+}
+
+// SilenceTrim records the range kept after removing leading and trailing silence
+// @Description Detected silence boundaries (seconds)
+type SilenceTrim struct {
+	Start float64 `json:"start" example:"2.35"` // End of leading silence
+	End   float64 `json:"end" example:"211.8"`  // Start of trailing silence
 }
 
 // Meta represents job metadata stored in meta.json
 type Meta struct {
-	ID         string      `json:"id"`
-	Status     string      `json:"status"` // pending, completed, error
-	CreatedAt  int64       `json:"createdAt"`
-	VideoID    string      `json:"videoId"`
-	Title      string      `json:"title"`
-	Duration   float64     `json:"duration"`
-	Files      FilesInfo   `json:"files"`
-	OutputType string      `json:"outputType"` // video or audio
-	Format     string      `json:"format"`
-	Quality    string      `json:"quality,omitempty"`
-	Bitrate    string      `json:"bitrate,omitempty"`
-	Trim       *TrimConfig `json:"trim,omitempty"`
-	Output     string      `json:"output,omitempty"`
-	StreamOnly bool        `json:"streamOnly,omitempty"` // true = skip merge, stream only
-	Error      string      `json:"error,omitempty"`
+	SchemaVersion   int              `json:"schemaVersion"` // MetaSchemaVersion when written; older files are migrated on read
+	ID              string           `json:"id"`
+	Status          string           `json:"status"` // pending, processing, completed, error
+	CreatedAt       int64            `json:"createdAt"`
+	ExpiresAt       int64            `json:"expiresAt,omitempty"` // Removal time (ms); extendable up to MaxJobLifetime
+	VideoID         string           `json:"videoId"`
+	Title           string           `json:"title"`
+	Duration        float64          `json:"duration"`
+	Author          string           `json:"author,omitempty"`
+	UploadDate      string           `json:"uploadDate,omitempty"`
+	ViewCount       int64            `json:"viewCount,omitempty"`
+	ThumbnailURL    string           `json:"thumbnailUrl,omitempty"`
+	Files           FilesInfo        `json:"files"`
+	OutputType      string           `json:"outputType"` // video or audio
+	Format          string           `json:"format"`
+	Quality         string           `json:"quality,omitempty"`
+	Bitrate         string           `json:"bitrate,omitempty"`
+	AudioCodec      string           `json:"audioCodec,omitempty"`     // audio.codec override (vorbis, aac_he, ...)
+	StaticVideo     bool             `json:"staticVideo,omitempty"`    // Audio rendered over the thumbnail (cover.jpg)
+	VideoTranscode  bool             `json:"videoTranscode,omitempty"` // Video re-encoded for the device (allowTranscode)
+	NoAutorotate    bool             `json:"noAutorotate,omitempty"`   // output.autorotate false
+	ForceRotate     bool             `json:"forceRotate,omitempty"`    // output.forceRotate
+	Muted           bool             `json:"muted,omitempty"`          // audio.mute: video only, Files.Audio is nil
+	Faststart       bool             `json:"faststart,omitempty"`      // Output written with +faststart (moov atom first)
+	Rotation        *VideoRotation   `json:"rotation,omitempty"`       // Detected after download (video jobs)
+	ChannelMode     string           `json:"channelMode,omitempty"`    // audio.channels ("" = automatic)
+	AudioChannels   *AudioChannels   `json:"audioChannels,omitempty"`  // Detected after download (jobs with audio)
+	Priority        string           `json:"priority,omitempty"`       // low, normal, high
+	Trim            *TrimConfig      `json:"trim,omitempty"`
+	AutoTrimSilence bool             `json:"autoTrimSilence,omitempty"`
+	Tempo           float64          `json:"tempo,omitempty"`           // audio.tempo (0 = unchanged)
+	PitchSemitones  int              `json:"pitchSemitones,omitempty"`  // audio.pitchSemitones
+	OutputDuration  float64          `json:"outputDuration,omitempty"`  // Output length (seconds) when audio.tempo changes it
+	Chapters        []Chapter        `json:"chapters,omitempty"`        // Written as chapter markers (m4a/m4b)
+	SilenceTrim     *SilenceTrim     `json:"silenceTrim,omitempty"`     // Detected boundaries, applied as Trim
+	Transcript      *Transcript      `json:"transcript,omitempty"`      // Requested with audio.transcript
+	Selection       *StreamSelection `json:"selection,omitempty"`       // Selected streams and processing plan
+	SourceLimit     int64            `json:"sourceLimit,omitempty"`     // Byte cap on downloading a stream of unknown size (MAX_SOURCE_BYTES)
+	LossyToLossless bool             `json:"lossyToLossless,omitempty"` // Lossless output from a lossy source
+	Warnings        Warnings         `json:"warnings,omitempty"`        // Returned by status (STREAM_ONLY may be added when processing starts)
+	Output          string           `json:"output,omitempty"`          // On-disk filename (signed in URLs)
+	Outputs         []ExtraOutput    `json:"outputs,omitempty"`         // Additional outputs (multi-output jobs, convert)
+	KeepSources     bool             `json:"keepSources,omitempty"`     // video.*/audio.* kept after processing
+	DisplayFilename string           `json:"displayFilename,omitempty"` // User-facing filename (Content-Disposition)
+	StreamOnly      bool             `json:"streamOnly,omitempty"`      // true = skip merge, stream only
+	Error           string           `json:"error,omitempty"`
+	JobError        *JobError        `json:"jobErrorDetail,omitempty"`
+	Manifest        *FileManifest    `json:"manifest,omitempty"`        // Part hashes of Output
+	LastStreamError *StreamError     `json:"lastStreamError,omitempty"` // Most recent /stream transfer that ended early
+	FFmpegVersion   string           `json:"ffmpegVersion,omitempty"`   // "ffmpeg -version" of the process that last ran ffmpeg for the job
+	FFmpegCommands  []FFmpegCommand  `json:"ffmpegCommands,omitempty"`  // ffmpeg invocations of the job, oldest first (admin only)
+	Usage           Usage            `json:"usage"`
+	UsageKey        string           `json:"usageKey,omitempty"`  // API key fingerprint the usage is billed to, "" = anonymous (kept after the client scrub)
+	DeletedAt       int64            `json:"deletedAt,omitempty"` // Soft delete time (ms), 0 = not deleted
+	Binding         string           `json:"binding,omitempty"`   // Client binding for file/stream URLs ("ip:<hash>", "session:<hash>")
+	Client          *ClientInfo      `json:"client,omitempty"`    // Creating request (admin only, scrubbed after ClientInfoRetention)
+	Request         *JobRequest      `json:"request,omitempty"`   // Normalized request parameters (status with ?includeRequest=1)
+	GroupID         string           `json:"groupId,omitempty"`   // Job group (POST /api/groups) the job belongs to
+
+	Unknown map[string]json.RawMessage `json:"-"` // Top-level fields of a newer schema, written back unchanged
+}
+
+// ClientInfo records the request that created a job, for abuse investigation
+// Never returned by public endpoints.
+type ClientInfo struct {
+	IP         string `json:"ip,omitempty"`
+	UserAgent  string `json:"userAgent,omitempty"`
+	APIKey     string `json:"apiKey,omitempty"` // Key fingerprint ("key:<hash>"), never the key itself
+	Referer    string `json:"referer,omitempty"`
+	ScrubbedAt int64  `json:"scrubbedAt,omitempty"` // Fields cleared after the retention window (ms)
+}
+
+// FFmpegCommand records one ffmpeg invocation of a job, for reproducing output issues
+type FFmpegCommand struct {
+	Args      []string `json:"args"`      // Arguments after the ffmpeg binary
+	StartedAt int64    `json:"startedAt"` // Unix ms
+}
+
+// Usage tracks bytes transferred for a job (billing)
+type Usage struct {
+	OriginBytes int64 `json:"originBytes"` // Downloaded from origin
+	ServedBytes int64 `json:"servedBytes"` // Written to clients
+	ServedCount int64 `json:"servedCount"` // Number of transfers to clients
 }
 
 type FilesInfo struct {
@@ -89,16 +472,98 @@ type FilesInfo struct {
 }
 
 type FileInfo struct {
-	Name string `json:"name"`
-	Size int64  `json:"size"`
+	Name   string `json:"name"`
+	Size   int64  `json:"size"`
+	Source string `json:"source,omitempty"` // Shared source cache file name
+}
+
+// FileManifest describes an output file split into fixed-size parts
+type FileManifest struct {
+	Size     int64      `json:"size"`
+	PartSize int64      `json:"partSize"`
+	SHA256   string     `json:"sha256"`
+	Parts    []FilePart `json:"parts"`
+}
+
+// FilePart is a byte range of an output file with its hash
+// @Description Output file part
+type FilePart struct {
+	Index  int    `json:"index" example:"0"`
+	Offset int64  `json:"offset" example:"0"`
+	Length int64  `json:"length" example:"16777216"`
+	SHA256 string `json:"sha256" example:"9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"`
+}
+
+// ManifestResponse lists the parts of an output file for resumable downloads
+// @Description Resumable download manifest
+type ManifestResponse struct {
+	Filename string     `json:"filename" example:"output.mp4"`
+	Size     int64      `json:"size" example:"52428800"`
+	PartSize int64      `json:"partSize" example:"16777216"`
+	SHA256   string     `json:"sha256" example:"2c26b46b68ffc68ff99b453c1d30413413422d706483bfa0f98a5e886266e7ae"`
+	Parts    []FilePart `json:"parts"`
 }
 
 // ExtractResponse from YouTube Extract API
 type ExtractResponse struct {
-	Title        string   `json:"title"`
-	Duration     float64  `json:"duration"`
-	VideoStreams []Stream `json:"videoStreams"`
-	AudioStreams []Stream `json:"audioStreams"`
+	Title        string         `json:"title"`
+	Duration     float64        `json:"duration"`
+	VideoStreams []Stream       `json:"videoStreams"`
+	AudioStreams []Stream       `json:"audioStreams"`
+	Chapters     []Chapter      `json:"chapters,omitempty"`
+	Captions     []CaptionTrack `json:"captions,omitempty"`
+	Author       string         `json:"author,omitempty"`
+	UploadDate   string         `json:"uploadDate,omitempty"`
+	ViewCount    FlexInt        `json:"viewCount,omitempty"`
+	ThumbnailURL string         `json:"thumbnailUrl,omitempty"`
+}
+
+// VideoInfoResponse is the metadata of a video, returned by GET /api/info/:videoId
+// @Description Video metadata
+type VideoInfoResponse struct {
+	VideoID      string       `json:"videoId" example:"dQw4w9WgXcQ"`
+	Title        string       `json:"title" example:"Rick Astley - Never Gonna Give You Up"`
+	Duration     float64      `json:"duration" example:"213.5"`
+	Author       string       `json:"author,omitempty" example:"Rick Astley"`
+	UploadDate   string       `json:"uploadDate,omitempty" example:"2009-10-25"` // YYYY-MM-DD
+	ViewCount    int64        `json:"viewCount,omitempty" example:"1500000000"`
+	ThumbnailURL string       `json:"thumbnailUrl,omitempty" example:"https://i.ytimg.com/vi/dQw4w9WgXcQ/maxresdefault.jpg"`
+	AudioTracks  []AudioTrack `json:"audioTracks"` // Values of audio.trackId
+}
+
+// AudioTrack is an audio track (language or dub) of a video, for choosing audio.trackId
+// @Description Audio track of a video
+type AudioTrack struct {
+	ID       string `json:"id" example:"en.vss_abc123"` // audio.trackId; empty for videos with a single track
+	Original bool   `json:"original" example:"true"`    // The video's original language
+}
+
+// CaptionTrack is a caption/transcript track offered by the extract API
+type CaptionTrack struct {
+	URL          string `json:"url"`
+	LanguageCode string `json:"languageCode"`
+	Name         string `json:"name,omitempty"`
+	Kind         string `json:"kind,omitempty"` // "asr" = generated by speech recognition
+}
+
+// Chapter is a named section of a video, ending where the next one starts
+type Chapter struct {
+	Title string  `json:"title"`
+	Start float64 `json:"start"` // Seconds
+}
+
+// FlexInt is an integer that upstream may send as a number or a numeric string
+// Unparseable values decode as 0 instead of failing extraction
+type FlexInt int64
+
+func (n *FlexInt) UnmarshalJSON(data []byte) error {
+	v, err := strconv.ParseInt(strings.Trim(string(data), `"`), 10, 64)
+	if err != nil {
+		*n = 0
+		return nil
+	}
+	*n = FlexInt(v)
+	return nil
 }
 
 // Stream represents a video or audio stream
@@ -115,6 +580,10 @@ type Stream struct {
 	AudioTrackID  string  `json:"audioTrackId,omitempty"`
 	IsOriginal    bool    `json:"isOriginal,omitempty"`
 	FPS           int     `json:"fps,omitempty"`
+	Itag          int     `json:"itag,omitempty"`
+	Cipher        string  `json:"signatureCipher,omitempty"` // Set when the URL needs deciphering
+	DRM           bool    `json:"drm,omitempty"`
+	ContentHash   string  `json:"contentHash,omitempty"` // SHA-256 of the stream (hex, optionally "sha256:"-prefixed); downloads are verified against it
 }
 
 // VideoSelectionResult contains the selected video stream and metadata
@@ -129,12 +598,318 @@ type VideoSelectionResult struct {
 // HealthResponse for health check
 // @Description Health check response
 type HealthResponse struct {
-	Status    string `json:"status" example:"ok"`
-	Timestamp int64  `json:"timestamp" example:"1705123456789"`
+	Status    string          `json:"status" example:"ok"`
+	Timestamp int64           `json:"timestamp" example:"1705123456789"`
+	Extract   *ExtractCircuit `json:"extract,omitempty"` // deep=true only
+	Storage   *StorageState   `json:"storage,omitempty"` // deep=true only
+}
+
+// StorageState reports whether new downloads are refused after a disk-full error
+// @Description Storage volume state
+type StorageState struct {
+	Full       bool  `json:"full" example:"false"` // true = POST /api/download returns 507 STORAGE_FULL
+	FreeBytes  int64 `json:"freeBytes" example:"52428800000"`
+	UsedBytes  int64 `json:"usedBytes" example:"1073741824"`             // Job files, HLS segments and cached sources, counted toward quotaBytes
+	QuotaBytes int64 `json:"quotaBytes,omitempty" example:"10737418240"` // STORAGE_QUOTA_MB; usedBytes at or past it = 507 STORAGE_QUOTA_EXCEEDED
+}
+
+// ExtractCircuit reports whether extract requests are failing fast after upstream rate limiting
+// @Description Extract API cool-down state
+type ExtractCircuit struct {
+	State             string `json:"state" example:"closed" enums:"closed,open"` // open = requests fail fast with EXTRACT_RATE_LIMITED
+	RetryAfterSeconds int    `json:"retryAfterSeconds,omitempty" example:"0"`
+	RateLimited       int64  `json:"rateLimited" example:"3"`                                                       // 429/503 responses from the extract API since start
+	Failures          int64  `json:"failures" example:"1"`                                                          // Other extract failures since start
+	AuthFailures      int64  `json:"authFailures" example:"0"`                                                      // 401/403 responses (credentials rejected) since start
+	LastError         string `json:"lastError,omitempty" example:"EXTRACT_AUTH_FAILED" enums:"EXTRACT_AUTH_FAILED"` // Set while the last extract request was rejected for its credentials
+}
+
+// CapabilitiesResponse lists what the server supports, from the live config
+// @Description Server capabilities
+type CapabilitiesResponse struct {
+	APIVersion                string               `json:"apiVersion" example:"2.0"`
+	VideoFormats              []string             `json:"videoFormats" example:"mp4,webm,mkv"`
+	AudioFormats              []string             `json:"audioFormats" example:"mp3,m4a,m4b,wav,opus,ogg,flac"`
+	Qualities                 []string             `json:"qualities" example:"2160p,1080p,720p"`
+	Bitrates                  []string             `json:"bitrates" example:"64k,128k,192k,320k"`
+	OSProfiles                map[string]OSProfile `json:"osProfiles"`
+	MaxTrimDuration           float64              `json:"maxTrimDuration" example:"86400"`         // Seconds
+	MaxMergeDurationTranscode float64              `json:"maxMergeDurationTranscode" example:"900"` // Seconds; longer jobs are stream-only
+	MaxMergeDurationRemux     float64              `json:"maxMergeDurationRemux" example:"14400"`   // Seconds; longer jobs are stream-only
+	HardwareAcceleration      bool                 `json:"hardwareAcceleration" example:"false"`
+	Features                  map[string]bool      `json:"features"`
+}
+
+// OSProfile describes the streams selected for a client OS
+type OSProfile struct {
+	MaxQuality  string   `json:"maxQuality" example:"1080p"`
+	VideoCodecs []string `json:"videoCodecs" example:"avc1"`
+	AudioCodecs []string `json:"audioCodecs" example:"mp4a"`
+}
+
+// LoadResponse reports processing backlog and the admission threshold
+// @Description Processing load estimate
+type LoadResponse struct {
+	WaitingJobs          int     `json:"waitingJobs" example:"12"`
+	RunningJobs          int     `json:"runningJobs" example:"4"`
+	AvgProcessingSeconds float64 `json:"avgProcessingSeconds" example:"42.5"`
+	EstimatedWaitSeconds float64 `json:"estimatedWaitSeconds" example:"127.5"`
+	MaxWaitSeconds       float64 `json:"maxWaitSeconds" example:"600"`            // New jobs are rejected above this (0 = never)
+	OverrideExpiresAt    int64   `json:"overrideExpiresAt,omitempty" example:"0"` // When an admin override of maxWaitSeconds ends (ms)
+}
+
+// LoadOverrideRequest temporarily replaces the admission threshold
+// @Description Admission threshold override
+type LoadOverrideRequest struct {
+	MaxWaitSeconds float64 `json:"maxWaitSeconds" example:"1200"` // 0 disables shedding
+	TTLSeconds     float64 `json:"ttlSeconds" example:"3600"`     // Override lifetime (default 1h)
+}
+
+// LogLevelsResponse lists the log levels in effect
+// @Description Log levels
+type LogLevelsResponse struct {
+	Level   string            `json:"level" example:"info"` // Default level (LOG_LEVEL)
+	Modules map[string]string `json:"modules"`              // Level in effect per module
+}
+
+// LogLevelsRequest changes log levels until restart
+// @Description Log level change
+type LogLevelsRequest struct {
+	Level   string            `json:"level,omitempty" example:"warn"` // New default level, empty = unchanged
+	Modules map[string]string `json:"modules,omitempty"`              // Module levels; "" makes a module follow the default again
+}
+
+// JobArchiveRecord is the compact record archived when cleanup removes a job
+type JobArchiveRecord struct {
+	ID          string  `json:"id"`
+	VideoHash   string  `json:"videoHash"` // Hashed video ID
+	Status      string  `json:"status"`
+	ErrorCode   string  `json:"errorCode,omitempty"`
+	OutputType  string  `json:"outputType"`
+	Format      string  `json:"format"`
+	Quality     string  `json:"quality,omitempty"`
+	Bitrate     string  `json:"bitrate,omitempty"`
+	Duration    float64 `json:"duration"`             // Media duration (seconds)
+	SourceBytes int64   `json:"sourceBytes"`          // Expected video + audio download size
+	OutputBytes int64   `json:"outputBytes"`          // Output file size at removal
+	Outputs     int     `json:"outputs,omitempty"`    // Additional outputs
+	StreamOnly  bool    `json:"streamOnly,omitempty"` // Served via /stream only
+	OriginBytes int64   `json:"originBytes"`
+	ServedBytes int64   `json:"servedBytes"`
+	ServedCount int64   `json:"servedCount"`
+	UsageKey    string  `json:"usageKey,omitempty"` // API key fingerprint the usage is billed to
+	CreatedAt   int64   `json:"createdAt"`
+	DeletedAt   int64   `json:"deletedAt,omitempty"`
+	RemovedAt   int64   `json:"removedAt"`
+}
+
+// AdminJob summarizes a job for the admin listing, including its creating client
+// @Description Admin job summary
+type AdminJob struct {
+	ID            string      `json:"id" example:"V1StGXR8_Z5jdHi6B-myT"`
+	Status        string      `json:"status" example:"completed"`
+	CreatedAt     int64       `json:"createdAt" example:"1705122256789"`
+	ExpiresAt     int64       `json:"expiresAt" example:"1705124056789"`
+	DeletedAt     int64       `json:"deletedAt,omitempty" example:"0"`
+	VideoID       string      `json:"videoId" example:"dQw4w9WgXcQ"`
+	Title         string      `json:"title" example:"Rick Astley - Never Gonna Give You Up"`
+	OutputType    string      `json:"outputType" example:"audio"`
+	Format        string      `json:"format" example:"mp3"`
+	Client        *ClientInfo `json:"client,omitempty"`
+	Usage         Usage       `json:"usage"`
+	UsageKey      string      `json:"usageKey,omitempty" example:"key:3f2a9c0d1e4b5a6f7c8d9e0f1a2b3c4d"`
+	PossiblyStuck bool        `json:"possiblyStuck,omitempty"` // Pending/processing past the job timeout or without a live worker
+}
+
+// FailJobRequest force-fails a stuck job with an operator-supplied reason
+// @Description Force-fail request
+type FailJobRequest struct {
+	Reason string `json:"reason" example:"Stuck after origin outage"` // Shown to clients as the job error
+}
+
+// AdminJobsResponse lists jobs, newest first
+// @Description Admin job listing
+type AdminJobsResponse struct {
+	Jobs  []AdminJob `json:"jobs"`
+	Total int        `json:"total" example:"250"` // Jobs on disk before limit
+}
+
+// KeyUsage sums the usage of the jobs billed to one API key
+// @Description Usage of one API key
+type KeyUsage struct {
+	Key         string `json:"key" example:"key:3f2a9c0d1e4b5a6f7c8d9e0f1a2b3c4d"` // Key fingerprint, "anonymous" for jobs without a key
+	Jobs        int    `json:"jobs" example:"12"`
+	OriginBytes int64  `json:"originBytes" example:"104857600"`
+	ServedBytes int64  `json:"servedBytes" example:"52428800"`
+	ServedCount int64  `json:"servedCount" example:"14"`
+}
+
+// AdminUsageResponse lists usage per API key over the jobs on disk
+type AdminUsageResponse struct {
+	Keys  []KeyUsage `json:"keys"`
+	Since int64      `json:"since,omitempty" example:"1705122256789"` // Only jobs created at or after (ms)
+}
+
+// ConvertRequest renders an additional output from a job's retained sources
+// @Description Additional output request
+type ConvertRequest struct {
+	Output OutputConfig `json:"output"` // Explicit format; quality is fixed by the sources
+	Audio  AudioConfig  `json:"audio,omitempty"`
+	Trim   *TrimConfig  `json:"trim,omitempty"`
+}
+
+// ConvertResponse is returned when an additional output is queued
+// @Description Additional output queued
+type ConvertResponse struct {
+	Name      string `json:"name" example:"output_2.mp3"`
+	StatusURL string `json:"statusUrl" example:"https://api.ytconvert.org/api/status/V1StGXR8_Z5jdHi?t=xxx"`
+}
+
+// ExtraOutput is an additional output rendered from the job's sources
+// (further outputs of a multi-output job, or POST /api/jobs/:id/convert)
+type ExtraOutput struct {
+	Name            string      `json:"name"` // output_2.mp3 (signed in URLs)
+	DisplayFilename string      `json:"displayFilename,omitempty"`
+	OutputType      string      `json:"outputType"`
+	Format          string      `json:"format"`
+	Bitrate         string      `json:"bitrate,omitempty"`
+	AudioCodec      string      `json:"audioCodec,omitempty"`
+	Trim            *TrimConfig `json:"trim,omitempty"`
+	Status          string      `json:"status"` // pending, processing, completed, error
+	Error           string      `json:"error,omitempty"`
+	CreatedAt       int64       `json:"createdAt"`
+}
+
+// OutputStatus reports an additional output in the status response
+// @Description Additional output status
+type OutputStatus struct {
+	Name        string `json:"name" example:"output_2.mp3"`
+	Format      string `json:"format" example:"mp3"`
+	Bitrate     string `json:"bitrate,omitempty" example:"320k"`
+	Status      string `json:"status" example:"completed" enums:"pending,processing,completed,error"`
+	Progress    int    `json:"progress" example:"100"`
+	DownloadURL string `json:"downloadUrl,omitempty" example:"https://api.ytconvert.org/files/abc123/output_2.mp3?t=xxx"`
+	Error       string `json:"error,omitempty" example:"Conversion failed"`
 }
 
 // DeleteResponse for job deletion
 // @Description Delete job response
 type DeleteResponse struct {
-	Deleted bool `json:"deleted" example:"true"`
+	Deleted        bool  `json:"deleted" example:"true"`
+	AlreadyDeleted bool  `json:"alreadyDeleted,omitempty" example:"false"`       // The job was deleted or removed before this request
+	DeletedAt      int64 `json:"deletedAt,omitempty" example:"1705123456789"`    // Soft delete time (ms); unknown for jobs removed without a tombstone
+	RestoreUntil   int64 `json:"restoreUntil,omitempty" example:"1705124056789"` // End of the restore window, while it is open
+}
+
+// ExtendResponse for job expiry extension
+// @Description Extend job response
+type ExtendResponse struct {
+	ExpiresAt    int64  `json:"expiresAt" example:"1705124056789"`    // New removal time (ms)
+	MaxExpiresAt int64  `json:"maxExpiresAt" example:"1705142056789"` // Extension cap (ms)
+	StatusURL    string `json:"statusUrl" example:"https://api.ytconvert.org/api/status/V1StGXR8_Z5jdHi?t=xxx"`
+}
+
+// RestoreResponse for job restore
+// @Description Restore job response
+type RestoreResponse struct {
+	Restored bool `json:"restored" example:"true"`
+}
+
+// GroupRequest groups existing jobs, e.g. to download their outputs as one zip
+// @Description Job group request
+type GroupRequest struct {
+	Jobs  []GroupMemberRef `json:"jobs"`
+	Group GroupOptions     `json:"group"`
+}
+
+// GroupMemberRef names a job to add to a group; T proves access to it
+type GroupMemberRef struct {
+	ID string `json:"id" example:"V1StGXR8_Z5jdHi6B-myT"`
+	T  string `json:"t" example:"xxx"` // The t parameter of the job's statusUrl
+}
+
+// GroupOptions configures a job group
+type GroupOptions struct {
+	Archive bool   `json:"archive" example:"true"`                // Zip the members' outputs once all have finished
+	Name    string `json:"name,omitempty" example:"lecture-pack"` // Archive download name (without .zip)
+}
+
+// Group is the stored state of a job group (_groups/<id>/group.json)
+type Group struct {
+	ID        string        `json:"id"`
+	Name      string        `json:"name"`
+	JobIDs    []string      `json:"jobIds"`
+	CreatedAt int64         `json:"createdAt"`
+	ExpiresAt int64         `json:"expiresAt"`         // Latest member expiry seen (ms); the group is removed after it
+	Binding   string        `json:"binding,omitempty"` // Client binding for the archive URL
+	Archive   *GroupArchive `json:"archive,omitempty"` // Requested with group.archive
+}
+
+// GroupArchive tracks the zip of a group's outputs
+type GroupArchive struct {
+	Status string `json:"status"`          // pending (members running), processing, completed, error
+	Files  int    `json:"files,omitempty"` // Member outputs included
+	Size   int64  `json:"size,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// GroupManifest is manifest.json inside a group archive
+type GroupManifest struct {
+	Group     string               `json:"group"`
+	Name      string               `json:"name"`
+	CreatedAt int64                `json:"createdAt"`
+	Files     []GroupManifestEntry `json:"files"`
+	Errors    []GroupManifestEntry `json:"errors"` // Members without an output in the archive
+}
+
+// GroupManifestEntry is a member of a group archive
+type GroupManifestEntry struct {
+	JobID string `json:"jobId"`
+	Title string `json:"title,omitempty"`
+	File  string `json:"file,omitempty"` // Name in the archive
+	Error string `json:"error,omitempty"`
+}
+
+// GroupResponse is returned when a group is created
+// @Description Job group created
+type GroupResponse struct {
+	GroupID   string `json:"groupId" example:"Uakgb_J5m9g-0JDMbcJqL"`
+	StatusURL string `json:"statusUrl" example:"https://api.ytconvert.org/api/groups/Uakgb_J5m9g-0JDMbcJqL?t=xxx"`
+	ExpiresAt int64  `json:"expiresAt" example:"1705124056789"`
+}
+
+// GroupStatusResponse reports a group's members and archive
+// @Description Job group status
+type GroupStatusResponse struct {
+	ID        string              `json:"id" example:"Uakgb_J5m9g-0JDMbcJqL"`
+	Name      string              `json:"name" example:"lecture-pack"`
+	Status    string              `json:"status" example:"completed" enums:"pending,completed"` // completed once every member has finished
+	Members   []GroupMemberStatus `json:"members"`
+	Archive   *GroupArchiveStatus `json:"archive,omitempty"`
+	ExpiresAt int64               `json:"expiresAt" example:"1705124056789"`
+}
+
+// GroupMemberStatus reports a member job
+// @Description Job group member
+type GroupMemberStatus struct {
+	JobID  string `json:"jobId" example:"V1StGXR8_Z5jdHi6B-myT"`
+	Status string `json:"status" example:"completed" enums:"pending,processing,completed,error,deleted,expired"`
+	Error  string `json:"error,omitempty" example:"Download failed"`
+}
+
+// GroupArchiveStatus reports a group archive
+// @Description Job group archive
+type GroupArchiveStatus struct {
+	Status      string `json:"status" example:"completed" enums:"pending,processing,completed,error"`
+	DownloadURL string `json:"downloadUrl,omitempty" example:"https://api.ytconvert.org/groups/Uakgb_J5m9g-0JDMbcJqL/archive?t=xxx"`
+	Files       int    `json:"files,omitempty" example:"5"`
+	Size        int64  `json:"size,omitempty" example:"52428800"`
+	Error       string `json:"error,omitempty" example:"No member has an output file"`
+}
+
+// GroupDeleteResponse for group deletion
+// @Description Delete group response
+type GroupDeleteResponse struct {
+	Deleted        bool `json:"deleted" example:"true"`
+	MembersDeleted int  `json:"membersDeleted,omitempty" example:"5"` // Members soft-deleted (?members=true)
 }
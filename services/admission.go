@@ -0,0 +1,108 @@
+package services
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+	"yt-downloader-go/config"
+	"yt-downloader-go/models"
+)
+
+// processingStatsWeight is the EWMA weight of the newest job duration
+const processingStatsWeight = 0.1
+
+// persistedStats is the on-disk form of the processing averages
+type persistedStats struct {
+	AvgSeconds float64 `json:"avgProcessingSeconds"`
+	Samples    int64   `json:"samples"`
+}
+
+// processingStats is the rolling average of FFmpeg processing time plus the admin threshold override
+var processingStats = struct {
+	mu         sync.Mutex
+	persisted  persistedStats
+	maxWait    time.Duration
+	maxWaitExp time.Time // Override expiry; zero = no override
+}{}
+
+// getProcessingStatsPath returns the file holding the persisted averages
+func getProcessingStatsPath() string {
+	return filepath.Join(config.StorageDir, "_processing_stats.json")
+}
+
+// LoadProcessingStats restores the persisted averages (missing file = no history)
+func LoadProcessingStats() {
+	data, err := os.ReadFile(getProcessingStatsPath())
+	if err != nil {
+		return
+	}
+
+	processingStats.mu.Lock()
+	defer processingStats.mu.Unlock()
+	json.Unmarshal(data, &processingStats.persisted)
+}
+
+// RecordProcessingDuration folds a completed job's processing time into the rolling average
+func RecordProcessingDuration(d time.Duration) {
+	processingStats.mu.Lock()
+	defer processingStats.mu.Unlock()
+
+	stats := &processingStats.persisted
+	seconds := d.Seconds()
+	if stats.Samples == 0 {
+		stats.AvgSeconds = seconds
+	} else {
+		stats.AvgSeconds += processingStatsWeight * (seconds - stats.AvgSeconds)
+	}
+	stats.Samples++
+
+	if data, err := json.Marshal(stats); err == nil {
+		path := getProcessingStatsPath()
+		if os.WriteFile(path+".new", data, 0644) == nil {
+			os.Rename(path+".new", path)
+		}
+	}
+}
+
+// SetMaxWaitOverride replaces AdmissionMaxWait until ttl elapses
+func SetMaxWaitOverride(maxWait time.Duration, ttl time.Duration) {
+	processingStats.mu.Lock()
+	defer processingStats.mu.Unlock()
+	processingStats.maxWait = maxWait
+	processingStats.maxWaitExp = time.Now().Add(ttl)
+}
+
+// LoadEstimate returns the current backlog estimate and admission threshold
+// Estimated wait = jobs waiting for a slot × average processing time / slots.
+func LoadEstimate() models.LoadResponse {
+	ffmpegSlots.mu.Lock()
+	waiting, running := len(ffmpegSlots.waiters), ffmpegSlots.used
+	ffmpegSlots.mu.Unlock()
+
+	processingStats.mu.Lock()
+	defer processingStats.mu.Unlock()
+
+	result := models.LoadResponse{
+		WaitingJobs:          waiting,
+		RunningJobs:          running,
+		AvgProcessingSeconds: processingStats.persisted.AvgSeconds,
+		MaxWaitSeconds:       config.AdmissionMaxWait.Seconds(),
+	}
+	if config.MaxConcurrentFFmpeg > 0 {
+		result.EstimatedWaitSeconds = float64(waiting) * processingStats.persisted.AvgSeconds / float64(config.MaxConcurrentFFmpeg)
+	}
+	if time.Now().Before(processingStats.maxWaitExp) {
+		result.MaxWaitSeconds = processingStats.maxWait.Seconds()
+		result.OverrideExpiresAt = processingStats.maxWaitExp.UnixMilli()
+	}
+	return result
+}
+
+// ShouldShedLoad reports whether new jobs should be rejected, with the current estimate
+// A max wait of 0 disables shedding.
+func ShouldShedLoad() (bool, models.LoadResponse) {
+	load := LoadEstimate()
+	return load.MaxWaitSeconds > 0 && load.EstimatedWaitSeconds > load.MaxWaitSeconds, load
+}
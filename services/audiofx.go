@@ -0,0 +1,90 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"yt-downloader-go/models"
+)
+
+// pitchSampleRate is the rate audio is resampled to around a pitch shift
+const pitchSampleRate = 48000
+
+// HasAudioEffects reports whether a job changes the tempo or pitch of its audio (forces a re-encode)
+func HasAudioEffects(meta *models.Meta) bool {
+	return (meta.Tempo != 0 && meta.Tempo != 1) || meta.PitchSemitones != 0
+}
+
+// AudioEffectsFilter returns the -af chain for audio.tempo and audio.pitchSemitones, "" for none
+// A pitch shift plays the audio at a scaled sample rate (pitch and speed change together)
+// and atempo restores the speed.
+func AudioEffectsFilter(meta *models.Meta) string {
+	if !HasAudioEffects(meta) {
+		return ""
+	}
+
+	tempo := JobTempo(meta)
+	var filters []string
+	if meta.PitchSemitones != 0 {
+		ratio := math.Pow(2, float64(meta.PitchSemitones)/12)
+		filters = append(filters,
+			fmt.Sprintf("aresample=%d", pitchSampleRate),
+			fmt.Sprintf("asetrate=%d", int(math.Round(pitchSampleRate*ratio))),
+			fmt.Sprintf("aresample=%d", pitchSampleRate),
+		)
+		tempo /= ratio
+	}
+	filters = append(filters, atempoChain(tempo)...)
+	return strings.Join(filters, ",")
+}
+
+// atempoChain returns atempo filters for factor, chaining 0.5–2.0 steps (older ffmpeg's range per instance)
+func atempoChain(factor float64) []string {
+	var filters []string
+	for factor < 0.5 {
+		filters = append(filters, "atempo=0.5")
+		factor /= 0.5
+	}
+	for factor > 2 {
+		filters = append(filters, "atempo=2")
+		factor /= 2
+	}
+	if math.Abs(factor-1) > 1e-6 {
+		filters = append(filters, fmt.Sprintf("atempo=%.6g", factor))
+	}
+	return filters
+}
+
+// JobTempo returns the job's tempo factor (1 when unchanged)
+func JobTempo(meta *models.Meta) float64 {
+	if meta.Tempo == 0 {
+		return 1
+	}
+	return meta.Tempo
+}
+
+// OutputTrim maps a trim of the source timeline onto the output of a tempo-changed job
+// Fade lengths are output seconds and stay as they are.
+func OutputTrim(meta *models.Meta) *models.TrimConfig {
+	tempo := JobTempo(meta)
+	if meta.Trim == nil || tempo == 1 {
+		return meta.Trim
+	}
+	trim := *meta.Trim
+	trim.Start /= tempo
+	trim.End /= tempo
+	return &trim
+}
+
+// OutputDuration returns the output length of a tempo-changed job (0 when the tempo is unchanged)
+func OutputDuration(meta *models.Meta) float64 {
+	tempo := JobTempo(meta)
+	if tempo == 1 {
+		return 0
+	}
+	kept := meta.Duration
+	if meta.Trim != nil {
+		kept = min(meta.Trim.End, meta.Duration) - meta.Trim.Start
+	}
+	return math.Round(kept/tempo*1000) / 1000
+}
@@ -0,0 +1,74 @@
+package services
+
+import (
+	"context"
+	"math"
+	"path/filepath"
+	"testing"
+	"yt-downloader-go/models"
+)
+
+// The converted length follows the tempo (duration / tempo) and a pitch shift leaves it as it is
+func TestAudioEffectsDuration(t *testing.T) {
+	useRealFFmpeg(t)
+	dir := t.TempDir()
+	generate(t, dir, "audio.m4a", "-f", "lavfi", "-i", "sine=frequency=440:duration=6", "-c:a", "aac")
+	source := probe(t, filepath.Join(dir, "audio.m4a")).duration(t)
+
+	tests := []struct {
+		name  string
+		tempo float64
+		pitch int
+	}{
+		{name: "slower", tempo: 0.75},
+		{name: "faster", tempo: 1.5},
+		{name: "chained atempo", tempo: 0.5},
+		{name: "pitch up", pitch: 5},
+		{name: "pitch down and faster", tempo: 1.25, pitch: -3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			meta := audioJob("wav")
+			meta.Duration = source
+			meta.Tempo, meta.PitchSemitones = tt.tempo, tt.pitch
+
+			output, err := FFmpegConvertAudio(context.Background(), dir, "wav", "", "", "aac", "audio.m4a", AudioFilter(meta), 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			want := source
+			if reported := OutputDuration(meta); reported != 0 {
+				want = reported
+			}
+			if got := probe(t, filepath.Join(dir, output)).duration(t); math.Abs(got-want) > 0.05 {
+				t.Errorf("output is %.3fs, want %.3fs (source %.3fs)", got, want, source)
+			}
+		})
+	}
+}
+
+func TestAudioEffectsFilter(t *testing.T) {
+	tests := []struct {
+		name  string
+		tempo float64
+		pitch int
+		want  string
+	}{
+		{name: "unchanged"},
+		{name: "tempo 1", tempo: 1},
+		{name: "tempo", tempo: 0.75, want: "atempo=0.75"},
+		{name: "pitch up an octave", pitch: 12, want: "aresample=48000,asetrate=96000,aresample=48000,atempo=0.5"},
+		{name: "pitch down an octave, half speed", tempo: 0.5, pitch: -12, want: "aresample=48000,asetrate=24000,aresample=48000"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			meta := &models.Meta{Tempo: tt.tempo, PitchSemitones: tt.pitch}
+			if got := AudioEffectsFilter(meta); got != tt.want {
+				t.Errorf("AudioEffectsFilter = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
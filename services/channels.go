@@ -0,0 +1,165 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"slices"
+	"strconv"
+	"strings"
+	"yt-downloader-go/config"
+	"yt-downloader-go/models"
+)
+
+// channelLayouts are the channels of the surround layouts ffprobe reports (FFmpeg's names)
+var channelLayouts = map[string][]string{
+	"3.0":       {"FL", "FR", "FC"},
+	"quad":      {"FL", "FR", "BL", "BR"},
+	"4.0":       {"FL", "FR", "FC", "BC"},
+	"5.0":       {"FL", "FR", "FC", "BL", "BR"},
+	"5.0(side)": {"FL", "FR", "FC", "SL", "SR"},
+	"5.1":       {"FL", "FR", "FC", "LFE", "BL", "BR"},
+	"5.1(side)": {"FL", "FR", "FC", "LFE", "SL", "SR"},
+	"6.1":       {"FL", "FR", "FC", "LFE", "BC", "SL", "SR"},
+	"7.1":       {"FL", "FR", "FC", "LFE", "BL", "BR", "SL", "SR"},
+}
+
+// downmixGain is a source channel mixed into one side of a stereo downmix
+type downmixGain struct {
+	channel string
+	gain    string
+}
+
+// Gains of a stereo downmix. The centre carries the dialogue and is weighted above the
+// fronts, so vocals don't end up quiet; LFE is left out. pan's "<" renormalizes the gains
+// of each side so the mix can't clip.
+var (
+	downmixLeft  = []downmixGain{{"FC", "1"}, {"FL", "0.707"}, {"BL", "0.5"}, {"SL", "0.5"}, {"BC", "0.35"}}
+	downmixRight = []downmixGain{{"FC", "1"}, {"FR", "0.707"}, {"BR", "0.5"}, {"SR", "0.5"}, {"BC", "0.35"}}
+)
+
+// ProbeChannels returns the channel count and layout ("" when unknown) of an audio file's
+// first audio stream
+func ProbeChannels(path string) (int, string, error) {
+	out, err := exec.Command(config.FFprobePath,
+		"-v", "error",
+		"-select_streams", "a:0",
+		"-show_entries", "stream=channels,channel_layout",
+		"-of", "json",
+		path,
+	).Output()
+	if err != nil {
+		return 0, "", fmt.Errorf("ffprobe error: %w", err)
+	}
+
+	var probe struct {
+		Streams []struct {
+			Channels      int    `json:"channels"`
+			ChannelLayout string `json:"channel_layout"`
+		} `json:"streams"`
+	}
+	if err := json.Unmarshal(out, &probe); err != nil {
+		return 0, "", fmt.Errorf("ffprobe output: %w", err)
+	}
+	if len(probe.Streams) == 0 || probe.Streams[0].Channels == 0 {
+		return 0, "", fmt.Errorf("no audio stream")
+	}
+	return probe.Streams[0].Channels, probe.Streams[0].ChannelLayout, nil
+}
+
+// DecideChannels returns how the output of meta handles a source with channels and layout.
+// Without audio.channels, surround is downmixed to stereo for StereoAudioFormats; formats
+// that can't carry surround are always downmixed. Video outputs copy their audio as it is.
+func DecideChannels(meta *models.Meta, channels int, layout string) *models.AudioChannels {
+	result := &models.AudioChannels{
+		SourceChannels: channels,
+		SourceLayout:   layout,
+		Channels:       channels,
+		Layout:         layout,
+		Action:         models.ChannelsKept,
+	}
+	if meta.OutputType != "audio" || meta.StaticVideo {
+		return result
+	}
+
+	target := channels
+	switch meta.ChannelMode {
+	case models.ChannelModeStereo:
+		target = 2
+	case models.ChannelModeMono:
+		target = 1
+	case models.ChannelModeKeep:
+	default:
+		if slices.Contains(config.StereoAudioFormats, meta.Format) {
+			target = min(channels, 2)
+		}
+	}
+	if slices.Contains(config.StereoOnlyFormats, meta.Format) {
+		target = min(target, 2)
+	}
+	if target == channels {
+		return result
+	}
+
+	result.Channels = target
+	result.Layout = "stereo"
+	if target == 1 {
+		result.Layout = "mono"
+	}
+	result.Action = models.ChannelsDownmixed
+	if target > channels {
+		result.Action = models.ChannelsUpmixed
+	}
+	return result
+}
+
+// OutputChannels returns the channel count the output of meta is encoded with, 0 to keep the source's
+func OutputChannels(meta *models.Meta) int {
+	if meta.AudioChannels == nil || meta.AudioChannels.Action == models.ChannelsKept {
+		return 0
+	}
+	return meta.AudioChannels.Channels
+}
+
+// ChannelFilter returns the -af filter of a stereo downmix from a known surround layout, ""
+// otherwise (mono and unknown layouts are left to -ac)
+func ChannelFilter(channels *models.AudioChannels) string {
+	if channels == nil || channels.Action != models.ChannelsDownmixed || channels.Channels != 2 {
+		return ""
+	}
+	names, ok := channelLayouts[channels.SourceLayout]
+	if !ok {
+		return ""
+	}
+	return fmt.Sprintf("pan=stereo|FL<%s|FR<%s", downmixTerms(names, downmixLeft), downmixTerms(names, downmixRight))
+}
+
+// downmixTerms returns the pan terms of one output side for the channels of a layout
+func downmixTerms(names []string, gains []downmixGain) string {
+	var terms []string
+	for _, g := range gains {
+		if slices.Contains(names, g.channel) {
+			terms = append(terms, g.gain+"*"+g.channel)
+		}
+	}
+	return strings.Join(terms, "+")
+}
+
+// AudioFilter returns the -af chain of an audio output: the downmix, then tempo and pitch
+func AudioFilter(meta *models.Meta) string {
+	var filters []string
+	for _, filter := range []string{ChannelFilter(meta.AudioChannels), AudioEffectsFilter(meta)} {
+		if filter != "" {
+			filters = append(filters, filter)
+		}
+	}
+	return strings.Join(filters, ",")
+}
+
+// channelArgs returns the -ac arguments of an output with channels (none for 0)
+func channelArgs(channels int) []string {
+	if channels == 0 {
+		return nil
+	}
+	return []string{"-ac", strconv.Itoa(channels)}
+}
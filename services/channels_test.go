@@ -0,0 +1,150 @@
+package services
+
+import (
+	"testing"
+	"yt-downloader-go/models"
+)
+
+func TestDecideChannels(t *testing.T) {
+	staticVideo := audioJob("mp4")
+	staticVideo.StaticVideo = true
+
+	tests := []struct {
+		name     string
+		meta     *models.Meta
+		mode     string
+		channels int
+		layout   string
+		want     models.AudioChannels
+	}{
+		{
+			name: "surround to stereo mp3", meta: audioJob("mp3"), channels: 6, layout: "5.1(side)",
+			want: models.AudioChannels{Channels: 2, Layout: "stereo", Action: models.ChannelsDownmixed},
+		},
+		{
+			name: "surround to stereo m4a", meta: audioJob("m4a"), channels: 8, layout: "7.1",
+			want: models.AudioChannels{Channels: 2, Layout: "stereo", Action: models.ChannelsDownmixed},
+		},
+		{
+			name: "surround kept in flac", meta: audioJob("flac"), channels: 6, layout: "5.1",
+			want: models.AudioChannels{Channels: 6, Layout: "5.1", Action: models.ChannelsKept},
+		},
+		{
+			name: "stereo kept", meta: audioJob("mp3"), channels: 2, layout: "stereo",
+			want: models.AudioChannels{Channels: 2, Layout: "stereo", Action: models.ChannelsKept},
+		},
+		{
+			name: "mono kept", meta: audioJob("mp3"), channels: 1, layout: "mono",
+			want: models.AudioChannels{Channels: 1, Layout: "mono", Action: models.ChannelsKept},
+		},
+		{
+			name: "keep surround in m4a", meta: audioJob("m4a"), mode: models.ChannelModeKeep, channels: 6, layout: "5.1",
+			want: models.AudioChannels{Channels: 6, Layout: "5.1", Action: models.ChannelsKept},
+		},
+		{
+			name: "keep capped at stereo in mp3", meta: audioJob("mp3"), mode: models.ChannelModeKeep, channels: 6, layout: "5.1",
+			want: models.AudioChannels{Channels: 2, Layout: "stereo", Action: models.ChannelsDownmixed},
+		},
+		{
+			name: "stereo requested for flac", meta: audioJob("flac"), mode: models.ChannelModeStereo, channels: 6, layout: "5.1",
+			want: models.AudioChannels{Channels: 2, Layout: "stereo", Action: models.ChannelsDownmixed},
+		},
+		{
+			name: "mono requested", meta: audioJob("m4a"), mode: models.ChannelModeMono, channels: 2, layout: "stereo",
+			want: models.AudioChannels{Channels: 1, Layout: "mono", Action: models.ChannelsDownmixed},
+		},
+		{
+			name: "stereo requested from mono", meta: audioJob("mp3"), mode: models.ChannelModeStereo, channels: 1, layout: "mono",
+			want: models.AudioChannels{Channels: 2, Layout: "stereo", Action: models.ChannelsUpmixed},
+		},
+		{
+			name: "unknown surround layout", meta: audioJob("opus"), channels: 6,
+			want: models.AudioChannels{Channels: 2, Layout: "stereo", Action: models.ChannelsDownmixed},
+		},
+		{
+			name: "video copies its audio", meta: videoJob(), channels: 6, layout: "5.1",
+			want: models.AudioChannels{Channels: 6, Layout: "5.1", Action: models.ChannelsKept},
+		},
+		{
+			name: "static video copies its audio", meta: staticVideo, mode: models.ChannelModeStereo, channels: 6, layout: "5.1",
+			want: models.AudioChannels{Channels: 6, Layout: "5.1", Action: models.ChannelsKept},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.meta.ChannelMode = tt.mode
+			tt.want.SourceChannels, tt.want.SourceLayout = tt.channels, tt.layout
+			if got := DecideChannels(tt.meta, tt.channels, tt.layout); *got != tt.want {
+				t.Errorf("DecideChannels = %+v, want %+v", *got, tt.want)
+			}
+		})
+	}
+}
+
+func TestChannelFilter(t *testing.T) {
+	downmix := func(layout string, channels int) *models.AudioChannels {
+		return &models.AudioChannels{SourceLayout: layout, Channels: channels, Action: models.ChannelsDownmixed}
+	}
+
+	tests := []struct {
+		name     string
+		channels *models.AudioChannels
+		want     string
+	}{
+		{
+			// Centre above the fronts, LFE left out
+			name:     "5.1",
+			channels: downmix("5.1", 2),
+			want:     "pan=stereo|FL<1*FC+0.707*FL+0.5*BL|FR<1*FC+0.707*FR+0.5*BR",
+		},
+		{
+			name:     "5.1(side)",
+			channels: downmix("5.1(side)", 2),
+			want:     "pan=stereo|FL<1*FC+0.707*FL+0.5*SL|FR<1*FC+0.707*FR+0.5*SR",
+		},
+		{
+			name:     "7.1",
+			channels: downmix("7.1", 2),
+			want:     "pan=stereo|FL<1*FC+0.707*FL+0.5*BL+0.5*SL|FR<1*FC+0.707*FR+0.5*BR+0.5*SR",
+		},
+		{
+			name:     "6.1 back centre on both sides",
+			channels: downmix("6.1", 2),
+			want:     "pan=stereo|FL<1*FC+0.707*FL+0.5*SL+0.35*BC|FR<1*FC+0.707*FR+0.5*SR+0.35*BC",
+		},
+		{
+			name:     "quad without centre",
+			channels: downmix("quad", 2),
+			want:     "pan=stereo|FL<0.707*FL+0.5*BL|FR<0.707*FR+0.5*BR",
+		},
+		{name: "unknown layout left to -ac", channels: downmix("", 2)},
+		{name: "mono left to -ac", channels: downmix("5.1", 1)},
+		{name: "kept", channels: &models.AudioChannels{SourceLayout: "5.1", Channels: 6, Action: models.ChannelsKept}},
+		{name: "upmixed", channels: &models.AudioChannels{SourceLayout: "mono", Channels: 2, Action: models.ChannelsUpmixed}},
+		{name: "not probed"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ChannelFilter(tt.channels); got != tt.want {
+				t.Errorf("ChannelFilter = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// The decision feeds the conversion: a surround mp3 gets the downmix ahead of the tempo
+func TestAudioFilterDownmixesBeforeTempo(t *testing.T) {
+	meta := audioJob("mp3")
+	meta.Tempo = 1.25
+	meta.AudioChannels = DecideChannels(meta, 6, "5.1(side)")
+
+	want := "pan=stereo|FL<1*FC+0.707*FL+0.5*SL|FR<1*FC+0.707*FR+0.5*SR,atempo=1.25"
+	if got := AudioFilter(meta); got != want {
+		t.Errorf("AudioFilter = %q, want %q", got, want)
+	}
+	if got := OutputChannels(meta); got != 2 {
+		t.Errorf("OutputChannels = %d, want 2", got)
+	}
+}
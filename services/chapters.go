@@ -0,0 +1,95 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"yt-downloader-go/models"
+)
+
+// chapterFormats are output formats that carry native chapter markers
+var chapterFormats = map[string]bool{"m4a": true, "m4b": true}
+
+// SupportsChapters reports whether chapters are written for an output format
+func SupportsChapters(format string) bool {
+	return chapterFormats[format]
+}
+
+// ffmetadataEscaper escapes characters with special meaning in ffmetadata values
+var ffmetadataEscaper = strings.NewReplacer(`\`, `\\`, "=", `\=`, ";", `\;`, "#", `\#`, "\n", `\`+"\n")
+
+// chapterRange is a chapter with resolved start and end times (seconds)
+type chapterRange struct {
+	Title string
+	Start float64
+	End   float64
+}
+
+// clipChapters shifts chapters into a trimmed range [start, end) and drops those outside it
+// Chapter end times come from the next chapter's start (or duration for the last one).
+func clipChapters(chapters []models.Chapter, duration float64, trim *models.TrimConfig) []chapterRange {
+	start, end := 0.0, duration
+	if trim != nil {
+		start, end = trim.Start, min(trim.End, duration)
+	}
+
+	var result []chapterRange
+	for i, ch := range chapters {
+		chEnd := duration
+		if i+1 < len(chapters) {
+			chEnd = chapters[i+1].Start
+		}
+
+		clippedStart := max(ch.Start, start)
+		clippedEnd := min(chEnd, end)
+		if clippedEnd <= clippedStart {
+			continue
+		}
+		result = append(result, chapterRange{
+			Title: ch.Title,
+			Start: clippedStart - start,
+			End:   clippedEnd - start,
+		})
+	}
+	return result
+}
+
+// FFmpegAddChapters writes chapter markers into the output file (copy, no re-encode)
+// Fewer than two chapters after trimming is a no-op.
+func FFmpegAddChapters(ctx context.Context, jobDir string, format string, chapters []models.Chapter, duration float64, trim *models.TrimConfig, tempo float64) error {
+	ranges := clipChapters(chapters, duration, trim)
+	if len(ranges) < 2 {
+		return nil
+	}
+
+	var metadata strings.Builder
+	metadata.WriteString(";FFMETADATA1\n")
+	for _, r := range ranges {
+		fmt.Fprintf(&metadata, "[CHAPTER]\nTIMEBASE=1/1000\nSTART=%d\nEND=%d\ntitle=%s\n",
+			int64(r.Start/tempo*1000), int64(r.End/tempo*1000), ffmetadataEscaper.Replace(r.Title))
+	}
+
+	metadataPath := filepath.Join(jobDir, "chapters.txt")
+	if err := os.WriteFile(metadataPath, []byte(metadata.String()), 0644); err != nil {
+		return fmt.Errorf("failed to write chapters: %w", err)
+	}
+	defer os.Remove(metadataPath)
+
+	// Rewrites output.<format> in place through output.<format>.part
+	inputPath := filepath.Join(jobDir, fmt.Sprintf("output.%s", format))
+	args := []string{
+		"-y",
+		"-i", inputPath,
+		"-i", metadataPath,
+		"-map", "0",
+		"-map_metadata", "0",
+		"-map_chapters", "1",
+		"-c", "copy",
+	}
+	if err := runFFmpegToFile(ctx, args, format, inputPath); err != nil {
+		return fmt.Errorf("adding chapters failed: %w", err)
+	}
+	return nil
+}
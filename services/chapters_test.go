@@ -0,0 +1,153 @@
+package services
+
+import (
+	"context"
+	"math"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"testing"
+	"yt-downloader-go/models"
+)
+
+// lectureChapters are four chapters of a 60s video
+var lectureChapters = []models.Chapter{
+	{Title: "Intro", Start: 0},
+	{Title: "Part 1", Start: 15},
+	{Title: "Part 2", Start: 30},
+	{Title: "Q&A", Start: 45},
+}
+
+func TestClipChapters(t *testing.T) {
+	tests := []struct {
+		name     string
+		chapters []models.Chapter
+		trim     *models.TrimConfig
+		want     []chapterRange
+	}{
+		{name: "none"},
+		{
+			name:     "one",
+			chapters: lectureChapters[:1],
+			want:     []chapterRange{{Title: "Intro", Start: 0, End: 60}},
+		},
+		{
+			name:     "untrimmed",
+			chapters: lectureChapters,
+			want: []chapterRange{
+				{Title: "Intro", Start: 0, End: 15},
+				{Title: "Part 1", Start: 15, End: 30},
+				{Title: "Part 2", Start: 30, End: 45},
+				{Title: "Q&A", Start: 45, End: 60},
+			},
+		},
+		{
+			name:     "trim shifts and clips",
+			chapters: lectureChapters,
+			trim:     &models.TrimConfig{Start: 10, End: 40},
+			want: []chapterRange{
+				{Title: "Intro", Start: 0, End: 5},
+				{Title: "Part 1", Start: 5, End: 20},
+				{Title: "Part 2", Start: 20, End: 30},
+			},
+		},
+		{
+			name:     "trim on chapter bounds",
+			chapters: lectureChapters,
+			trim:     &models.TrimConfig{Start: 15, End: 45},
+			want: []chapterRange{
+				{Title: "Part 1", Start: 0, End: 15},
+				{Title: "Part 2", Start: 15, End: 30},
+			},
+		},
+		{
+			name:     "trim inside one chapter",
+			chapters: lectureChapters,
+			trim:     &models.TrimConfig{Start: 32, End: 40},
+			want:     []chapterRange{{Title: "Part 2", Start: 0, End: 8}},
+		},
+		{
+			name:     "trim end past the duration",
+			chapters: lectureChapters,
+			trim:     &models.TrimConfig{Start: 50, End: 90},
+			want:     []chapterRange{{Title: "Q&A", Start: 0, End: 10}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := clipChapters(tt.chapters, 60, tt.trim); !slices.Equal(got, tt.want) {
+				t.Errorf("clipChapters = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+// ffprobe reads back the chapter markers written into m4a/m4b outputs, shifted by trims and
+// scaled by the tempo
+func TestChapterMarkers(t *testing.T) {
+	useRealFFmpeg(t)
+
+	tests := []struct {
+		name   string
+		format string
+		length float64 // Seconds of the output before chapters are added
+		trim   *models.TrimConfig
+		tempo  float64
+		want   []chapterRange
+	}{
+		{
+			name: "m4a", format: "m4a", length: 60, tempo: 1,
+			want: []chapterRange{
+				{Title: "Intro", Start: 0, End: 15},
+				{Title: "Part 1", Start: 15, End: 30},
+				{Title: "Part 2", Start: 30, End: 45},
+				{Title: "Q&A", Start: 45, End: 60},
+			},
+		},
+		{
+			name: "m4b trimmed", format: "m4b", length: 30, trim: &models.TrimConfig{Start: 10, End: 40}, tempo: 1,
+			want: []chapterRange{
+				{Title: "Intro", Start: 0, End: 5},
+				{Title: "Part 1", Start: 5, End: 20},
+				{Title: "Part 2", Start: 20, End: 30},
+			},
+		},
+		{
+			name: "trimmed at double tempo", format: "m4a", length: 15, trim: &models.TrimConfig{Start: 10, End: 40}, tempo: 2,
+			want: []chapterRange{
+				{Title: "Intro", Start: 0, End: 2.5},
+				{Title: "Part 1", Start: 2.5, End: 10},
+				{Title: "Part 2", Start: 10, End: 15},
+			},
+		},
+		{
+			// A single chapter is left out rather than written as one marker
+			name: "one chapter left", format: "m4a", length: 8, trim: &models.TrimConfig{Start: 32, End: 40}, tempo: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			output := "output." + tt.format
+			generate(t, dir, output, "-f", "lavfi", "-i", "sine=duration="+strconv.FormatFloat(tt.length, 'f', -1, 64), "-c:a", "aac", "-f", "ipod")
+
+			if err := FFmpegAddChapters(context.Background(), dir, tt.format, lectureChapters, 60, tt.trim, tt.tempo); err != nil {
+				t.Fatal(err)
+			}
+
+			chapters := probe(t, filepath.Join(dir, output)).Chapters
+			if len(chapters) != len(tt.want) {
+				t.Fatalf("%d chapters %+v, want %d", len(chapters), chapters, len(tt.want))
+			}
+			for i, want := range tt.want {
+				start, _ := strconv.ParseFloat(chapters[i].StartTime, 64)
+				end, _ := strconv.ParseFloat(chapters[i].EndTime, 64)
+				if chapters[i].Tags.Title != want.Title || math.Abs(start-want.Start) > 0.01 || math.Abs(end-want.End) > 0.01 {
+					t.Errorf("chapter %d: %q %.3f–%.3f, want %q %.3f–%.3f", i, chapters[i].Tags.Title, start, end, want.Title, want.Start, want.End)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,104 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"expvar"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"yt-downloader-go/config"
+	"yt-downloader-go/utils"
+)
+
+// sourceChecksumMismatches counts downloads that didn't match their contentHash (exported via /debug/vars)
+var sourceChecksumMismatches = expvar.NewInt("source_checksum_mismatches")
+
+// ChecksumError is returned when a downloaded stream doesn't match the extract API's contentHash
+type ChecksumError struct {
+	Expected string
+	Actual   string
+}
+
+func (e *ChecksumError) Error() string {
+	return fmt.Sprintf("content hash mismatch: expected sha256 %s, got %s", e.Expected, e.Actual)
+}
+
+type contentHashKey struct{}
+
+// withContentHash returns a context whose download is verified against hash (Stream.ContentHash)
+func withContentHash(ctx context.Context, hash string) context.Context {
+	return context.WithValue(ctx, contentHashKey{}, hash)
+}
+
+// expectedContentHash returns the lowercase hex SHA-256 a download of ctx must match, "" for none
+func expectedContentHash(ctx context.Context) string {
+	hash, _ := ctx.Value(contentHashKey{}).(string)
+	return strings.ToLower(strings.TrimPrefix(hash, "sha256:"))
+}
+
+// verifyContentHash compares the hash of a finished download with the expected one
+func verifyContentHash(expected string, h hash.Hash) error {
+	if actual := hex.EncodeToString(h.Sum(nil)); actual != expected {
+		sourceChecksumMismatches.Add(1)
+		return &ChecksumError{Expected: expected, Actual: actual}
+	}
+	return nil
+}
+
+// chunkHasher hashes a sparse download in order as its chunks complete
+// Chunks are written out of order, so each is hashed once the chunks before it are: read
+// back right after being written, while still in the page cache. Nothing is read after the
+// download has finished.
+type chunkHasher struct {
+	mu        sync.Mutex
+	file      *os.File
+	totalSize int64
+	h         hash.Hash
+	next      int          // First chunk not hashed yet
+	done      map[int]bool // Completed chunks from next on
+	err       error
+}
+
+func newChunkHasher(file *os.File, totalSize int64) *chunkHasher {
+	return &chunkHasher{file: file, totalSize: totalSize, h: sha256.New(), done: map[int]bool{}}
+}
+
+// complete records a written chunk and hashes every chunk now in order
+func (c *chunkHasher) complete(idx int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.done[idx] = true
+	if c.err != nil {
+		return
+	}
+
+	bufPtr := utils.GetBuffer()
+	defer utils.PutBuffer(bufPtr)
+	for c.done[c.next] {
+		start := int64(c.next) * config.ChunkSize
+		section := io.NewSectionReader(c.file, start, chunkLength(c.next, c.totalSize))
+		if _, err := io.CopyBuffer(c.h, section, *bufPtr); err != nil {
+			c.err = fmt.Errorf("hash chunk %d failed: %w", c.next, err)
+			return
+		}
+		delete(c.done, c.next)
+		c.next++
+	}
+}
+
+// verify compares the hash of the whole download with expected
+func (c *chunkHasher) verify(expected string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.err != nil {
+		return c.err
+	}
+	return verifyContentHash(expected, c.h)
+}
@@ -0,0 +1,43 @@
+package services
+
+import (
+	"slices"
+	"strings"
+	"yt-downloader-go/config"
+	"yt-downloader-go/models"
+)
+
+// CanCopyAudio reports whether the source audio can be stream-copied into outputFormat
+// sourceCodec is the stream codec ("opus", "vorbis", "mp4a.40.2"); empty falls back to
+// the usual codec for inputExt. codec is the audio.codec override, empty = format default.
+func CanCopyAudio(inputExt, sourceCodec, outputFormat, codec string) bool {
+	inputExt = strings.TrimPrefix(inputExt, ".")
+
+	sourceCodec, _, _ = strings.Cut(sourceCodec, ".")
+	if sourceCodec == "" {
+		sourceCodec = config.AudioExtCodecs[inputExt]
+	}
+	if sourceCodec == "" {
+		return false
+	}
+
+	// An override only copies when the source already carries that codec
+	if codec != "" && config.AudioCodecCopySource[codec] != sourceCodec {
+		return false
+	}
+
+	return slices.Contains(config.AudioCopyCodecs[outputFormat], sourceCodec)
+}
+
+// NeedsAudioTranscode reports whether an audio output must be encoded (VBR always is)
+func NeedsAudioTranscode(inputExt, sourceCodec, outputFormat, bitrate, codec string) bool {
+	return IsVBR(bitrate) || !CanCopyAudio(inputExt, sourceCodec, outputFormat, codec)
+}
+
+// SourceAudioCodec returns the selected audio stream's codec, empty when unknown (older jobs)
+func SourceAudioCodec(meta *models.Meta) string {
+	if meta.Selection == nil || meta.Selection.Audio == nil {
+		return ""
+	}
+	return meta.Selection.Audio.Codec
+}
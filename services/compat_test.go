@@ -0,0 +1,67 @@
+package services
+
+import "testing"
+
+func TestAudioCompat(t *testing.T) {
+	tests := []struct {
+		name        string
+		inputExt    string
+		sourceCodec string
+		format      string
+		bitrate     string
+		codec       string
+		canCopy     bool
+		transcode   bool
+	}{
+		// vorbis in webm: the extension alone would say opus
+		{name: "vorbis webm to webm", inputExt: ".webm", sourceCodec: "vorbis", format: "webm", canCopy: true},
+		{name: "vorbis webm to ogg", inputExt: ".webm", sourceCodec: "vorbis", format: "ogg", canCopy: true},
+		{name: "vorbis webm to opus", inputExt: ".webm", sourceCodec: "vorbis", format: "opus", transcode: true},
+		{name: "vorbis webm to mkv", inputExt: ".webm", sourceCodec: "vorbis", format: "mkv", canCopy: true},
+		{name: "vorbis webm with vorbis override", inputExt: ".webm", sourceCodec: "vorbis", format: "ogg", codec: "vorbis", canCopy: true},
+		{name: "vorbis webm with opus override", inputExt: ".webm", sourceCodec: "vorbis", format: "ogg", codec: "opus", transcode: true},
+
+		// ogg carries opus or vorbis
+		{name: "opus webm to ogg", inputExt: ".webm", sourceCodec: "opus", format: "ogg", canCopy: true},
+		{name: "ogg by extension to ogg", inputExt: ".ogg", format: "ogg", canCopy: true},
+		{name: "ogg by extension to opus", inputExt: ".ogg", format: "opus", canCopy: true},
+		{name: "ogg to mp3", inputExt: ".ogg", format: "mp3", transcode: true},
+		{name: "opus to ogg vbr", inputExt: ".webm", sourceCodec: "opus", format: "ogg", bitrate: "V2", canCopy: true, transcode: true},
+
+		// mp4 family, both ways
+		{name: "m4a to mp4", inputExt: ".m4a", sourceCodec: "mp4a.40.2", format: "mp4", canCopy: true},
+		{name: "mp4 to m4a", inputExt: ".mp4", sourceCodec: "mp4a.40.2", format: "m4a", canCopy: true},
+		{name: "m4a by extension to mp4", inputExt: "m4a", format: "mp4", canCopy: true},
+		{name: "mp4 by extension to m4a", inputExt: "mp4", format: "m4a", canCopy: true},
+		{name: "m4a with aac override", inputExt: ".m4a", sourceCodec: "mp4a.40.2", format: "m4a", codec: "aac", canCopy: true},
+		{name: "m4a with he-aac override", inputExt: ".m4a", sourceCodec: "mp4a.40.5", format: "m4a", codec: "aac_he", transcode: true},
+		{name: "opus to m4a", inputExt: ".webm", sourceCodec: "opus", format: "m4a", transcode: true},
+
+		// m4b is an m4a with chapters
+		{name: "m4a to m4b", inputExt: ".m4a", sourceCodec: "mp4a.40.2", format: "m4b", canCopy: true},
+		{name: "m4b to m4a", inputExt: ".m4b", format: "m4a", canCopy: true},
+		{name: "opus to m4b", inputExt: ".webm", sourceCodec: "opus", format: "m4b", transcode: true},
+
+		// mkv takes anything common
+		{name: "aac to mkv", inputExt: ".m4a", sourceCodec: "mp4a.40.2", format: "mkv", canCopy: true},
+		{name: "opus to mkv", inputExt: ".webm", sourceCodec: "opus", format: "mkv", canCopy: true},
+		{name: "mp3 to mkv", inputExt: ".mp3", format: "mkv", canCopy: true},
+		{name: "flac to mkv", inputExt: ".flac", format: "mkv", canCopy: true},
+
+		// Unknown sources always encode
+		{name: "unknown extension", inputExt: ".wav", format: "wav", transcode: true},
+		{name: "no extension or codec", format: "mkv", transcode: true},
+		{name: "opus to mp3", inputExt: ".webm", sourceCodec: "opus", format: "mp3", bitrate: "192k", transcode: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := CanCopyAudio(tt.inputExt, tt.sourceCodec, tt.format, tt.codec); got != tt.canCopy {
+				t.Errorf("CanCopyAudio = %v, want %v", got, tt.canCopy)
+			}
+			if got := NeedsAudioTranscode(tt.inputExt, tt.sourceCodec, tt.format, tt.bitrate, tt.codec); got != tt.transcode {
+				t.Errorf("NeedsAudioTranscode = %v, want %v", got, tt.transcode)
+			}
+		})
+	}
+}
@@ -2,6 +2,8 @@ package services
 
 import (
 	"context"
+	"crypto/sha256"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -11,6 +13,7 @@ import (
 	"sync/atomic"
 	"time"
 	"yt-downloader-go/config"
+	"yt-downloader-go/utils"
 )
 
 // HTTPError represents an HTTP error
@@ -23,16 +26,77 @@ func (e *HTTPError) Error() string {
 	return fmt.Sprintf("HTTP %d: %s", e.StatusCode, e.Message)
 }
 
-// Download downloads a file using streaming (low memory)
-func Download(ctx context.Context, downloadURL string, destPath string, totalSize int64) error {
-	if totalSize <= config.ChunkSize {
-		return downloadSingle(ctx, downloadURL, destPath, totalSize)
+// downloadLog logs source downloads; chunk requests and retries are debug lines
+var downloadLog = utils.Logger(utils.LogDownloader)
+
+// logRetry logs a failed range request before it is tried again
+func logRetry(start, end int64, attempt int, err error) {
+	downloadLog.Debug("range request failed, retrying", "range", fmt.Sprintf("%d-%d", start, end), "attempt", attempt, "err", err)
+}
+
+// ErrSourceTooLarge stops the download of a stream of unknown size that outgrew its limit
+var ErrSourceTooLarge = errors.New("source exceeds the size limit")
+
+type sourceLimitKey struct{}
+
+// WithSourceLimit returns a context whose downloads of unknown size fail past limit bytes
+func WithSourceLimit(ctx context.Context, limit int64) context.Context {
+	return context.WithValue(ctx, sourceLimitKey{}, limit)
+}
+
+// sourceLimitReader fails with ErrSourceTooLarge once more than remaining bytes were read
+type sourceLimitReader struct {
+	io.ReadCloser
+	remaining int64
+}
+
+func (r *sourceLimitReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	if r.remaining -= int64(n); r.remaining < 0 {
+		return n, ErrSourceTooLarge
+	}
+	return n, err
+}
+
+type fileConnectionsKey struct{}
+
+// withFileConnections returns a context whose range requests share the persistent
+// connections of one download (DOWNLOAD_FILE_CONNS); release closes them
+func withFileConnections(ctx context.Context) (context.Context, func()) {
+	if config.DownloadFileConns <= 0 {
+		return ctx, func() {}
+	}
+	client := config.NewFileDownloadClient()
+	return context.WithValue(ctx, fileConnectionsKey{}, client), client.CloseIdleConnections
+}
+
+// Download downloads a file using streaming (low memory); swap it to mock the origin
+// Progress is published through utils.TrackDownload for status polls.
+var Download = download
+
+func download(ctx context.Context, downloadURL string, destPath string, totalSize int64) error {
+	tracker, done := utils.TrackDownload(destPath)
+	defer done()
+
+	if !IsParallelDownload(totalSize) {
+		return downloadSingle(ctx, downloadURL, destPath, totalSize, tracker)
 	}
-	return downloadChunked(ctx, downloadURL, destPath, totalSize)
+
+	ctx, release := withFileConnections(ctx)
+	defer release()
+	if config.LegacyChunkMerge {
+		return downloadChunked(ctx, downloadURL, destPath, totalSize, tracker)
+	}
+	return downloadSparse(ctx, downloadURL, destPath, totalSize, tracker)
+}
+
+// IsParallelDownload reports whether a source of totalSize bytes is fetched by parallel range workers
+func IsParallelDownload(totalSize int64) bool {
+	return totalSize > config.ChunkSize
 }
 
 // downloadSingle streams small files directly to disk
-func downloadSingle(ctx context.Context, downloadURL string, destPath string, totalSize int64) error {
+func downloadSingle(ctx context.Context, downloadURL string, destPath string, totalSize int64, tracker *utils.DownloadTracker) error {
 	tmpPath := destPath + ".tmp"
 
 	resp, err := fetchRange(ctx, downloadURL, 0, totalSize-1)
@@ -41,16 +105,39 @@ func downloadSingle(ctx context.Context, downloadURL string, destPath string, to
 	}
 	defer resp.Body.Close()
 
-	if err := streamToFile(resp.Body, tmpPath); err != nil {
+	tracker.Workers.Add(1)
+	defer tracker.Workers.Add(-1)
+
+	// Unknown sizes (0) always download here; the job's limit stops a runaway stream
+	body := io.ReadCloser(&countingReadCloser{ReadCloser: resp.Body, counter: &tracker.Written})
+	if limit, _ := ctx.Value(sourceLimitKey{}).(int64); limit > 0 && totalSize == 0 {
+		body = &sourceLimitReader{ReadCloser: body, remaining: limit}
+	}
+
+	// Hashed as it streams to disk
+	var reader io.Reader = body
+	expectedHash := expectedContentHash(ctx)
+	hasher := sha256.New()
+	if expectedHash != "" {
+		reader = io.TeeReader(body, hasher)
+	}
+
+	if err := streamToFile(reader, tmpPath); err != nil {
 		os.Remove(tmpPath)
 		return err
 	}
+	if expectedHash != "" {
+		if err := verifyContentHash(expectedHash, hasher); err != nil {
+			os.Remove(tmpPath)
+			return err
+		}
+	}
 
 	return os.Rename(tmpPath, destPath)
 }
 
-// downloadChunked downloads large files using parallel workers with chunk files
-func downloadChunked(ctx context.Context, downloadURL string, destPath string, totalSize int64) error {
+// downloadChunked downloads large files using parallel workers with chunk files (LEGACY_CHUNK_MERGE)
+func downloadChunked(ctx context.Context, downloadURL string, destPath string, totalSize int64, tracker *utils.DownloadTracker) error {
 	// Create chunks directory
 	chunksDir := destPath + ".chunks"
 	if err := os.MkdirAll(chunksDir, 0755); err != nil {
@@ -63,6 +150,7 @@ func downloadChunked(ctx context.Context, downloadURL string, destPath string, t
 	// Error channel for workers
 	errChan := make(chan error, config.Threads)
 	chunkIndex := int32(-1)
+	guard := &throttleGuard{}
 
 	// Start workers
 	var wg sync.WaitGroup
@@ -70,6 +158,11 @@ func downloadChunked(ctx context.Context, downloadURL string, destPath string, t
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
+			defer func() {
+				if r := recover(); r != nil {
+					errChan <- utils.PanicError("download worker", r)
+				}
+			}()
 
 			for {
 				// Get next chunk atomically
@@ -95,7 +188,7 @@ func downloadChunked(ctx context.Context, downloadURL string, destPath string, t
 
 				// Download chunk with retries
 				chunkPath := filepath.Join(chunksDir, fmt.Sprintf("chunk_%d", idx))
-				if err := downloadChunkWithRetry(ctx, downloadURL, chunkPath, start, end); err != nil {
+				if err := downloadChunkWithRetry(ctx, downloadURL, chunkPath, start, end, tracker, guard); err != nil {
 					errChan <- fmt.Errorf("chunk %d failed: %w", idx, err)
 					return
 				}
@@ -115,8 +208,8 @@ func downloadChunked(ctx context.Context, downloadURL string, destPath string, t
 		}
 	}
 
-	// Merge chunks
-	if err := mergeChunks(chunksDir, destPath, numChunks); err != nil {
+	// Merge chunks (verified while merging)
+	if err := mergeChunks(chunksDir, destPath, numChunks, expectedContentHash(ctx)); err != nil {
 		os.RemoveAll(chunksDir)
 		return fmt.Errorf("merge failed: %w", err)
 	}
@@ -128,28 +221,49 @@ func downloadChunked(ctx context.Context, downloadURL string, destPath string, t
 }
 
 // downloadChunkWithRetry downloads a single chunk with retry logic
-func downloadChunkWithRetry(ctx context.Context, downloadURL string, chunkPath string, start, end int64) error {
+// Written bytes count toward tracker and are taken back when an attempt fails.
+// A request guard finds throttled is retried on a fresh connection.
+func downloadChunkWithRetry(ctx context.Context, downloadURL string, chunkPath string, start, end int64, tracker *utils.DownloadTracker, guard *throttleGuard) error {
 	tmpPath := chunkPath + ".tmp"
 
+	tracker.Workers.Add(1)
+	defer tracker.Workers.Add(-1)
+
 	var lastErr error
+	var throttled bool
 	for retry := 0; retry < config.MaxRetries; retry++ {
-		resp, err := fetchRange(ctx, downloadURL, start, end)
+		var written atomic.Int64
+		reqCtx, stopWatch := guard.watch(ctx, &written, throttled)
+		resp, err := fetchRange(reqCtx, downloadURL, start, end)
 		if err != nil {
+			throttled = stopWatch()
 			lastErr = err
 			// Don't retry on 403
 			if httpErr, ok := err.(*HTTPError); ok && httpErr.StatusCode == 403 {
 				return err
 			}
+			logRetry(start, end, retry+1, err)
 			time.Sleep(config.RetryDelay * time.Duration(retry+1))
 			continue
 		}
 
-		err = streamToFile(resp.Body, tmpPath)
+		// Count live progress and this attempt's bytes (taken back on failure)
+		body := &countingReadCloser{ReadCloser: &countingReadCloser{ReadCloser: resp.Body, counter: &tracker.Written}, counter: &written}
+		err = streamToFile(body, tmpPath)
 		resp.Body.Close()
+		if throttled = stopWatch(); throttled {
+			err = errThrottled
+		}
 
 		if err != nil {
 			lastErr = err
+			tracker.Written.Add(-written.Load())
 			os.Remove(tmpPath)
+			// Retrying can't succeed until space is freed
+			if utils.IsStorageFull(err) {
+				return err
+			}
+			logRetry(start, end, retry+1, err)
 			time.Sleep(config.RetryDelay * time.Duration(retry+1))
 			continue
 		}
@@ -158,6 +272,7 @@ func downloadChunkWithRetry(ctx context.Context, downloadURL string, chunkPath s
 		if err := os.Rename(tmpPath, chunkPath); err != nil {
 			return fmt.Errorf("rename failed: %w", err)
 		}
+		downloadLog.Debug("chunk done", "range", fmt.Sprintf("%d-%d", start, end), "bytes", written.Load())
 		return nil
 	}
 
@@ -170,11 +285,13 @@ func streamToFile(reader io.Reader, destPath string) error {
 	if err != nil {
 		return fmt.Errorf("create file failed: %w", err)
 	}
+	utils.DownloadFilesOpen.Add(1)
+	defer utils.DownloadFilesOpen.Add(-1)
 	defer file.Close()
 
 	// Get buffer from pool
-	bufPtr := config.BufferPool.Get().(*[]byte)
-	defer config.BufferPool.Put(bufPtr)
+	bufPtr := utils.GetBuffer()
+	defer utils.PutBuffer(bufPtr)
 
 	// Stream copy with pooled buffer
 	_, err = io.CopyBuffer(file, reader, *bufPtr)
@@ -186,7 +303,8 @@ func streamToFile(reader io.Reader, destPath string) error {
 }
 
 // mergeChunks combines all chunk files into the final file
-func mergeChunks(chunksDir string, destPath string, numChunks int) error {
+// A non-empty expectedHash is checked against the merged data as it is written.
+func mergeChunks(chunksDir string, destPath string, numChunks int, expectedHash string) error {
 	tmpPath := destPath + ".tmp"
 
 	destFile, err := os.Create(tmpPath)
@@ -195,9 +313,15 @@ func mergeChunks(chunksDir string, destPath string, numChunks int) error {
 	}
 	defer destFile.Close()
 
+	var dest io.Writer = destFile
+	hasher := sha256.New()
+	if expectedHash != "" {
+		dest = io.MultiWriter(destFile, hasher)
+	}
+
 	// Get buffer from pool
-	bufPtr := config.BufferPool.Get().(*[]byte)
-	defer config.BufferPool.Put(bufPtr)
+	bufPtr := utils.GetBuffer()
+	defer utils.PutBuffer(bufPtr)
 
 	// Merge in order
 	for i := 0; i < numChunks; i++ {
@@ -207,15 +331,25 @@ func mergeChunks(chunksDir string, destPath string, numChunks int) error {
 		if err != nil {
 			return fmt.Errorf("open chunk %d failed: %w", i, err)
 		}
+		utils.DownloadFilesOpen.Add(1)
 
-		_, err = io.CopyBuffer(destFile, chunkFile, *bufPtr)
+		_, err = io.CopyBuffer(dest, chunkFile, *bufPtr)
 		chunkFile.Close()
+		utils.DownloadFilesOpen.Add(-1)
 
 		if err != nil {
+			os.Remove(tmpPath)
 			return fmt.Errorf("copy chunk %d failed: %w", i, err)
 		}
 	}
 
+	if expectedHash != "" {
+		if err := verifyContentHash(expectedHash, hasher); err != nil {
+			os.Remove(tmpPath)
+			return err
+		}
+	}
+
 	// Rename to final
 	if err := os.Rename(tmpPath, destPath); err != nil {
 		os.Remove(tmpPath)
@@ -235,15 +369,13 @@ func fetchRange(ctx context.Context, downloadURL string, start, end int64) (*htt
 		return nil, fmt.Errorf("create request failed: %w", err)
 	}
 
-	// Set headers
-	req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36")
-	req.Header.Set("Origin", "https://www.youtube.com")
-	req.Header.Set("Referer", "https://www.youtube.com/")
+	profile := setOriginHeaders(req, true)
 
-	resp, err := config.DownloadClient.Do(req)
+	resp, err := downloadClient(ctx).Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("request failed: %w", err)
 	}
+	recordOriginStatus(profile, resp.StatusCode)
 
 	if resp.StatusCode >= 400 {
 		body, _ := io.ReadAll(resp.Body)
@@ -254,6 +386,11 @@ func fetchRange(ctx context.Context, downloadURL string, start, end int64) (*htt
 		}
 	}
 
+	// Count origin bytes actually received (including retried chunks)
+	if counter := originCounter(ctx); counter != nil {
+		resp.Body = &countingReadCloser{ReadCloser: resp.Body, counter: counter}
+	}
+
 	return resp, nil
 }
 
@@ -264,13 +401,14 @@ func DownloadFile(ctx context.Context, downloadURL string, destPath string) erro
 		return fmt.Errorf("create request failed: %w", err)
 	}
 
-	req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36")
+	profile := setOriginHeaders(req, false)
 
 	resp, err := config.DownloadClient.Do(req)
 	if err != nil {
 		return fmt.Errorf("request failed: %w", err)
 	}
 	defer resp.Body.Close()
+	recordOriginStatus(profile, resp.StatusCode)
 
 	if resp.StatusCode >= 400 {
 		return &HTTPError{StatusCode: resp.StatusCode, Message: "download failed"}
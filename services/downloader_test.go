@@ -0,0 +1,137 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"yt-downloader-go/config"
+	"yt-downloader-go/utils"
+)
+
+// syntheticSize spans several chunks and ends in a short one
+const syntheticSize = 4*config.ChunkSize + 1234
+
+// syntheticData returns size bytes of reproducible random data
+func syntheticData(size int) []byte {
+	data := make([]byte, size)
+	rand.New(rand.NewSource(1)).Read(data)
+	return data
+}
+
+// fakeOrigin serves data to range requests in the origin's query style (&range=start-end)
+func fakeOrigin(tb testing.TB, data []byte) string {
+	tb.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		first, last, ok := strings.Cut(r.URL.Query().Get("range"), "-")
+		start, err1 := strconv.Atoi(first)
+		end, err2 := strconv.Atoi(last)
+		if !ok || err1 != nil || err2 != nil || start > end || start >= len(data) {
+			http.Error(w, "bad range", http.StatusRequestedRangeNotSatisfiable)
+			return
+		}
+		end = min(end, len(data)-1)
+		w.Header().Set("Content-Length", strconv.Itoa(end-start+1))
+		w.Write(data[start : end+1])
+	}))
+	tb.Cleanup(server.Close)
+	return server.URL + "/videoplayback?id=synthetic"
+}
+
+// useDirectDownloads sends origin requests straight to the fake origin instead of WARP_PROXY_URL
+func useDirectDownloads(tb testing.TB) {
+	tb.Helper()
+	prevDownload, prevFresh := config.DownloadClient, config.FreshDownloadClient
+	config.DownloadClient = &http.Client{Timeout: config.ChunkTimeout}
+	config.FreshDownloadClient = &http.Client{Timeout: config.ChunkTimeout, Transport: &http.Transport{DisableKeepAlives: true}}
+	tb.Cleanup(func() { config.DownloadClient, config.FreshDownloadClient = prevDownload, prevFresh })
+}
+
+// downloadFunc is a parallel download strategy (downloadChunked, downloadSparse)
+type downloadFunc func(ctx context.Context, downloadURL string, destPath string, totalSize int64, tracker *utils.DownloadTracker) error
+
+func TestParallelDownloads(t *testing.T) {
+	useDirectDownloads(t)
+	data := syntheticData(syntheticSize)
+	url := fakeOrigin(t, data)
+
+	for name, download := range map[string]downloadFunc{"chunked": downloadChunked, "sparse": downloadSparse} {
+		t.Run(name, func(t *testing.T) {
+			dest := filepath.Join(t.TempDir(), "video.mp4")
+			tracker, done := utils.TrackDownload(dest)
+			defer done()
+
+			if err := download(context.Background(), url, dest, int64(len(data)), tracker); err != nil {
+				t.Fatalf("download: %v", err)
+			}
+			got, err := os.ReadFile(dest)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, data) {
+				t.Errorf("downloaded %d bytes differ from the %d served", len(got), len(data))
+			}
+			if written := tracker.Written.Load(); written != int64(len(data)) {
+				t.Errorf("tracker counted %d bytes, want %d", written, len(data))
+			}
+		})
+	}
+}
+
+func benchmarkDownload(b *testing.B, download downloadFunc) {
+	useDirectDownloads(b)
+	data := syntheticData(syntheticSize)
+	url := fakeOrigin(b, data)
+	dir := b.TempDir()
+
+	b.SetBytes(int64(len(data)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		dest := filepath.Join(dir, fmt.Sprintf("video_%d.mp4", i))
+		tracker, done := utils.TrackDownload(dest)
+		if err := download(context.Background(), url, dest, int64(len(data)), tracker); err != nil {
+			b.Fatalf("download: %v", err)
+		}
+		done()
+		os.Remove(dest)
+	}
+}
+
+func BenchmarkDownloadChunked(b *testing.B) {
+	benchmarkDownload(b, downloadChunked)
+}
+
+func BenchmarkDownloadSparse(b *testing.B) {
+	benchmarkDownload(b, downloadSparse)
+}
+
+func BenchmarkMergeChunks(b *testing.B) {
+	data := syntheticData(syntheticSize)
+	chunksDir := b.TempDir()
+	numChunks := 0
+	for start := 0; start < len(data); start += config.ChunkSize {
+		chunk := data[start:min(start+config.ChunkSize, len(data))]
+		if err := os.WriteFile(filepath.Join(chunksDir, fmt.Sprintf("chunk_%d", numChunks)), chunk, 0644); err != nil {
+			b.Fatal(err)
+		}
+		numChunks++
+	}
+	dest := filepath.Join(b.TempDir(), "video.mp4")
+
+	b.SetBytes(int64(len(data)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := mergeChunks(chunksDir, dest, numChunks, ""); err != nil {
+			b.Fatalf("mergeChunks: %v", err)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strings"
+	"sync"
+	"yt-downloader-go/config"
+)
+
+// availableEncoders holds the encoder names reported by "ffmpeg -encoders"
+var availableEncoders = struct {
+	sync.RWMutex
+	names map[string]bool
+}{}
+
+// ProbeEncoders records the encoders the ffmpeg build supports (called at startup)
+func ProbeEncoders() error {
+	out, err := exec.Command(config.FFmpegPath, "-hide_banner", "-encoders").Output()
+	if err != nil {
+		return fmt.Errorf("ffmpeg -encoders failed: %w", err)
+	}
+
+	// Lines look like " A....D libfdk_aac  Fraunhofer FDK AAC"; the legend ends at "------"
+	names := make(map[string]bool)
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	inList := false
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+		if !inList {
+			inList = strings.HasPrefix(fields[0], "---")
+			continue
+		}
+		names[fields[1]] = true
+	}
+
+	availableEncoders.Lock()
+	availableEncoders.names = names
+	availableEncoders.Unlock()
+	return nil
+}
+
+// CheckAudioCodec reports an error if the codec override needs an encoder this ffmpeg lacks
+// Before a successful probe every encoder is assumed available.
+func CheckAudioCodec(codec string) error {
+	encoder := config.AudioCodecOverrideMap[codec]
+	if encoder == "" {
+		return nil
+	}
+
+	availableEncoders.RLock()
+	defer availableEncoders.RUnlock()
+	if availableEncoders.names == nil || availableEncoders.names[encoder] {
+		return nil
+	}
+	return fmt.Errorf("codec %s requires the %s encoder, which this server's ffmpeg build does not include", codec, encoder)
+}
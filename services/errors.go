@@ -0,0 +1,77 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net"
+	"yt-downloader-go/models"
+	"yt-downloader-go/utils"
+)
+
+// NewJobError classifies err into a structured job error for the given phase
+// The message is prefixed with action, e.g. "Download failed: <err>"
+func NewJobError(phase string, action string, err error) *models.JobError {
+	// Already classified (e.g. trim validation)
+	var jobErr *models.JobError
+	if errors.As(err, &jobErr) {
+		classified := *jobErr
+		classified.Message = action + ": " + jobErr.Message
+		return &classified
+	}
+
+	result := &models.JobError{
+		Code:    models.JobErrInternal,
+		Message: action + ": " + err.Error(),
+		Phase:   phase,
+	}
+
+	var httpErr *HTTPError
+	var ffmpegErr *FFmpegError
+	var netErr net.Error
+	var checksumErr *ChecksumError
+
+	switch {
+	case utils.IsStorageFull(err):
+		// Also refuses new downloads and starts an emergency cleanup
+		utils.MarkStorageFull()
+		result.Code = models.JobErrStorageFull
+		result.Retryable = true
+	case errors.Is(err, ErrSourceTooLarge):
+		result.Code = models.JobErrSourceTooLarge
+	case errors.As(err, &checksumErr):
+		// Already downloaded twice; a new job gets fresh origin URLs
+		result.Code = models.JobErrDownloadCorrupt
+		result.Retryable = true
+	case errors.As(err, &httpErr):
+		switch {
+		case httpErr.StatusCode == 403:
+			// Signed origin URLs expire; a fresh extract usually succeeds
+			result.Code = models.JobErrOriginForbidden
+			result.Retryable = true
+		case httpErr.StatusCode == 404 || httpErr.StatusCode == 410:
+			result.Code = models.JobErrOriginNotFound
+			result.Retryable = true
+		case httpErr.StatusCode == 429:
+			result.Code = models.JobErrRateLimited
+			result.Retryable = true
+		case httpErr.StatusCode >= 500:
+			result.Code = models.JobErrOriginError
+			result.Retryable = true
+		default:
+			result.Code = models.JobErrOriginError
+		}
+	case errors.Is(err, context.DeadlineExceeded):
+		result.Code = models.JobErrTimeout
+		result.Retryable = true
+	case errors.As(err, &ffmpegErr):
+		result.Code = models.JobErrFFmpegFailed
+	case errors.As(err, &netErr):
+		result.Code = models.JobErrNetwork
+		result.Retryable = true
+	case phase == models.PhaseDownload:
+		result.Code = models.JobErrDownloadFailed
+		result.Retryable = true
+	}
+
+	return result
+}
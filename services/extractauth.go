@@ -0,0 +1,66 @@
+package services
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"expvar"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+	"yt-downloader-go/config"
+)
+
+// ExtractAuthFailed marks extract API credential rejections in logs and the health check
+const ExtractAuthFailed = "EXTRACT_AUTH_FAILED"
+
+// extractAuthFailures counts 401/403 responses from the extract API (exported via /debug/vars)
+var extractAuthFailures = expvar.NewInt("extract_auth_failures")
+
+// extractAuthRejected is set by a 401/403 from the extract API and cleared by the next success
+var extractAuthRejected atomic.Bool
+
+// ExtractAuthError is returned when the extract API rejects our credentials
+type ExtractAuthError struct {
+	StatusCode int
+}
+
+func (e *ExtractAuthError) Error() string {
+	return fmt.Sprintf("%s: extract API answered %d", ExtractAuthFailed, e.StatusCode)
+}
+
+// signExtractRequest adds the EXTRACT_API_AUTH credentials to an extract API request
+func signExtractRequest(req *http.Request) {
+	switch config.ExtractAPIAuth {
+	case config.ExtractAuthBearer:
+		req.Header.Set("Authorization", "Bearer "+config.ExtractAPIToken)
+	case config.ExtractAuthHMAC:
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		req.Header.Set("X-Timestamp", timestamp)
+		req.Header.Set("X-Signature", extractSignature(timestamp, req.URL.Path))
+	}
+}
+
+// extractSignature returns the hex HMAC-SHA256 of "<timestamp>\n<path>" with EXTRACT_API_SECRET
+func extractSignature(timestamp string, path string) string {
+	mac := hmac.New(sha256.New, []byte(config.ExtractAPISecret))
+	mac.Write([]byte(timestamp + "\n" + path))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// checkExtractAuth records the credential outcome of an extract API response
+// Returns an *ExtractAuthError for 401/403.
+func checkExtractAuth(statusCode int) error {
+	if statusCode != http.StatusUnauthorized && statusCode != http.StatusForbidden {
+		extractAuthRejected.Store(false)
+		return nil
+	}
+
+	extractAuthFailures.Add(1)
+	extractAuthRejected.Store(true)
+	authErr := &ExtractAuthError{StatusCode: statusCode}
+	extractLog.Error("extract API rejected credentials", "status", statusCode, "auth", config.ExtractAPIAuth)
+	return authErr
+}
@@ -0,0 +1,95 @@
+package services
+
+import (
+	"context"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+	"yt-downloader-go/config"
+	"yt-downloader-go/models"
+	"yt-downloader-go/utils"
+)
+
+// cachedExtract is an extract API result remembered per video
+type cachedExtract struct {
+	data    *models.ExtractResponse
+	expires time.Time
+}
+
+// extractCache keeps recent extract results so previews and the following download
+// of a video share one API call (stream URLs stay valid far longer than ExtractCacheTTL).
+// Results are also persisted in ExtractCacheDir, so a restart doesn't send every request
+// to the extract API at once.
+var extractCache = struct {
+	mu      sync.Mutex
+	entries map[string]*cachedExtract
+}{entries: map[string]*cachedExtract{}}
+
+// ExtractCached returns the video's extract data fetched within ExtractCacheTTL, from memory
+// or disk, else calls Extract. Results whose stream URLs expire within ExtractCacheURLMargin
+// are fetched again. The result is shared between callers and must not be modified.
+func ExtractCached(ctx context.Context, videoID string) (*models.ExtractResponse, error) {
+	extractCache.mu.Lock()
+	entry := extractCache.entries[videoID]
+	extractCache.mu.Unlock()
+
+	if entry != nil && time.Now().Before(entry.expires) && streamURLsUsable(entry.data) {
+		return entry.data, nil
+	}
+
+	// Persisted by this or a previous process
+	if stored := utils.ReadExtractCache(videoID); stored != nil && streamURLsUsable(stored.Data) {
+		extractCache.mu.Lock()
+		extractCache.entries[videoID] = &cachedExtract{
+			data:    stored.Data,
+			expires: time.UnixMilli(stored.FetchedAt).Add(config.ExtractCacheTTL),
+		}
+		extractCache.mu.Unlock()
+		return stored.Data, nil
+	}
+	return Extract(ctx, videoID)
+}
+
+// rememberExtract stores a fresh extract result for videoID
+func rememberExtract(videoID string, data *models.ExtractResponse) {
+	now := time.Now()
+	extractCache.mu.Lock()
+	// Drop expired entries so the map stays bounded by recent traffic
+	for k, e := range extractCache.entries {
+		if now.After(e.expires) {
+			delete(extractCache.entries, k)
+		}
+	}
+	extractCache.entries[videoID] = &cachedExtract{data: data, expires: now.Add(config.ExtractCacheTTL)}
+	extractCache.mu.Unlock()
+
+	utils.WriteExtractCache(videoID, now, data)
+}
+
+// streamURLsUsable reports whether every stream URL of data stays valid for ExtractCacheURLMargin
+// URLs without an expire parameter are assumed valid.
+func streamURLsUsable(data *models.ExtractResponse) bool {
+	deadline := time.Now().Add(config.ExtractCacheURLMargin)
+	for _, streams := range [][]models.Stream{data.VideoStreams, data.AudioStreams} {
+		for _, stream := range streams {
+			if expiresAt, ok := streamURLExpiry(stream.URL); ok && expiresAt.Before(deadline) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// streamURLExpiry returns the expiry of a stream URL from its expire parameter (unix seconds)
+func streamURLExpiry(rawURL string) (time.Time, bool) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return time.Time{}, false
+	}
+	expire, err := strconv.ParseInt(u.Query().Get("expire"), 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.Unix(expire, 0), true
+}
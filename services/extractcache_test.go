@@ -0,0 +1,92 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+	"yt-downloader-go/config"
+	"yt-downloader-go/models"
+	"yt-downloader-go/utils"
+)
+
+// fakeExtractAPI answers every video with result and counts the calls
+func fakeExtractAPI(t *testing.T, result *models.ExtractResponse) *atomic.Int32 {
+	t.Helper()
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(result)
+	}))
+	t.Cleanup(server.Close)
+
+	prev := config.ExtractAPIBase
+	config.ExtractAPIBase = server.URL + "/api/youtube/video"
+	t.Cleanup(func() { config.ExtractAPIBase = prev })
+	return &calls
+}
+
+// resetExtractCache empties the in-memory extract cache, as after a restart
+func resetExtractCache(t *testing.T) {
+	t.Helper()
+	reset := func() {
+		extractCache.mu.Lock()
+		extractCache.entries = map[string]*cachedExtract{}
+		extractCache.mu.Unlock()
+	}
+	reset()
+	t.Cleanup(reset)
+}
+
+// extractWithExpiry is an extract result whose stream URL expires at expire
+func extractWithExpiry(title string, expire time.Time) *models.ExtractResponse {
+	return &models.ExtractResponse{
+		Title:        title,
+		AudioStreams: []models.Stream{{URL: fmt.Sprintf("https://origin.example/videoplayback?expire=%d", expire.Unix())}},
+	}
+}
+
+func TestExtractCachedColdStart(t *testing.T) {
+	prevStorage := config.StorageDir
+	config.SetStorageDir(t.TempDir())
+	t.Cleanup(func() { config.SetStorageDir(prevStorage) })
+	resetExtractCache(t)
+	calls := fakeExtractAPI(t, extractWithExpiry("From the API", time.Now().Add(6*time.Hour)))
+	ctx := context.Background()
+
+	// Persisted by the previous process, memory empty
+	utils.WriteExtractCache("persisted00", time.Now().Add(-time.Minute), extractWithExpiry("From disk", time.Now().Add(6*time.Hour)))
+	data, err := ExtractCached(ctx, "persisted00")
+	if err != nil || data.Title != "From disk" || calls.Load() != 0 {
+		t.Fatalf("cold start: %+v, %v after %d API calls, want the persisted result", data, err, calls.Load())
+	}
+
+	// Past the TTL on disk
+	utils.WriteExtractCache("expired0000", time.Now().Add(-config.ExtractCacheTTL-time.Second), extractWithExpiry("Stale", time.Now().Add(6*time.Hour)))
+	if data, err := ExtractCached(ctx, "expired0000"); err != nil || data.Title != "From the API" || calls.Load() != 1 {
+		t.Errorf("expired entry: %+v, %v after %d API calls, want a fresh extract", data, err, calls.Load())
+	}
+
+	// Within the TTL, but a stream URL expires inside ExtractCacheURLMargin
+	utils.WriteExtractCache("urlexpiring", time.Now(), extractWithExpiry("Expiring", time.Now().Add(config.ExtractCacheURLMargin/2)))
+	if data, err := ExtractCached(ctx, "urlexpiring"); err != nil || data.Title != "From the API" || calls.Load() != 2 {
+		t.Errorf("expiring URL: %+v, %v after %d API calls, want a fresh extract", data, err, calls.Load())
+	}
+
+	// The fresh result is persisted for the next restart
+	resetExtractCache(t)
+	if entry := utils.ReadExtractCache("urlexpiring"); entry == nil || entry.Data.Title != "From the API" {
+		t.Errorf("persisted after the refetch: %+v, want the API result", entry)
+	}
+	if data, err := ExtractCached(ctx, "urlexpiring"); err != nil || data.Title != "From the API" || calls.Load() != 2 {
+		t.Errorf("after restart: %+v, %v after %d API calls, want the persisted refetch", data, err, calls.Load())
+	}
+	if _, err := ExtractCached(ctx, "dQw4w9WgXcQ"); err != nil || calls.Load() != 3 {
+		t.Errorf("uncached video: %v after %d API calls, want one more call", err, calls.Load())
+	}
+}
@@ -0,0 +1,107 @@
+package services
+
+import (
+	"expvar"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+	"yt-downloader-go/config"
+	"yt-downloader-go/models"
+)
+
+// Extract API failure counters (rate limits and credential rejections, extract_auth_failures,
+// are counted apart from other failures)
+var (
+	extractRateLimited  = expvar.NewInt("extract_rate_limited")
+	extractShortCircuit = expvar.NewInt("extract_short_circuited")
+	extractFailures     = expvar.NewInt("extract_failures")
+)
+
+// RateLimitError is returned while the extract API is rate-limiting us
+type RateLimitError struct {
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("extract API rate limited, retry after %s", e.RetryAfter)
+}
+
+// RetryAfterSeconds rounds the retry hint up to whole seconds for Retry-After
+func (e *RateLimitError) RetryAfterSeconds() int {
+	return int(math.Ceil(e.RetryAfter.Seconds()))
+}
+
+// extractCooldown holds when requests may reach the extract API again after a 429/503
+var extractCooldown struct {
+	mu    sync.Mutex
+	until time.Time
+}
+
+// checkExtractCooldown fails fast while the global cool-down is active
+func checkExtractCooldown() error {
+	extractCooldown.mu.Lock()
+	defer extractCooldown.mu.Unlock()
+
+	if remaining := time.Until(extractCooldown.until); remaining > 0 {
+		extractShortCircuit.Add(1)
+		return &RateLimitError{RetryAfter: remaining}
+	}
+	return nil
+}
+
+// startExtractCooldown opens the cool-down for the upstream's Retry-After (bounded)
+func startExtractCooldown(header http.Header) *RateLimitError {
+	wait := parseRetryAfter(header.Get("Retry-After"))
+	if wait <= 0 {
+		wait = config.ExtractCooldown
+	}
+	wait = min(wait, config.ExtractCooldownMax)
+
+	extractCooldown.mu.Lock()
+	if until := time.Now().Add(wait); until.After(extractCooldown.until) {
+		extractCooldown.until = until
+	}
+	extractCooldown.mu.Unlock()
+
+	extractRateLimited.Add(1)
+	return &RateLimitError{RetryAfter: wait}
+}
+
+// parseRetryAfter reads delay-seconds or an HTTP date, 0 when absent or invalid
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(seconds) * time.Second
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		return time.Until(at)
+	}
+	return 0
+}
+
+// ExtractCircuit reports the extract API cool-down state (health check)
+func ExtractCircuit() models.ExtractCircuit {
+	extractCooldown.mu.Lock()
+	remaining := time.Until(extractCooldown.until)
+	extractCooldown.mu.Unlock()
+
+	circuit := models.ExtractCircuit{
+		State:        "closed",
+		RateLimited:  extractRateLimited.Value(),
+		Failures:     extractFailures.Value(),
+		AuthFailures: extractAuthFailures.Value(),
+	}
+	if remaining > 0 {
+		circuit.State = "open"
+		circuit.RetryAfterSeconds = int(math.Ceil(remaining.Seconds()))
+	}
+	if extractAuthRejected.Load() {
+		circuit.LastError = ExtractAuthFailed
+	}
+	return circuit
+}
@@ -1,7 +1,9 @@
 package services
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -14,30 +16,87 @@ import (
 )
 
 // Extract fetches video metadata from YouTube Extract API
-func Extract(videoID string) (*models.ExtractResponse, error) {
-	apiURL := fmt.Sprintf("%s/%s?proxy=%s", config.ExtractAPIBase, videoID, config.WARPProxyURL)
+// Cancelling ctx aborts the request. While the API is rate-limiting us it fails
+// fast with a *RateLimitError instead of calling it. With MAX_CONCURRENT_EXTRACTS the
+// request first waits for a slot, by the priority of WithExtractPriority.
+func Extract(ctx context.Context, videoID string) (*models.ExtractResponse, error) {
+	if err := checkExtractCooldown(); err != nil {
+		return nil, err
+	}
 
-	req, err := http.NewRequest("GET", apiURL, nil)
+	release, err := acquireExtractSlot(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	result, err := extract(ctx, videoID)
+	if err != nil && ctx.Err() == nil {
+		var rateLimited *RateLimitError
+		var authErr *ExtractAuthError
+		if !errors.As(err, &rateLimited) && !errors.As(err, &authErr) {
+			extractFailures.Add(1)
+		}
+	}
+	if err == nil {
+		rememberExtract(videoID, result)
+	}
+	return result, err
+}
+
+// extract performs one extract API request, or replays a recorded one (EXTRACT_REPLAY_DIR)
+func extract(ctx context.Context, videoID string) (*models.ExtractResponse, error) {
+	if config.ExtractReplayDir != "" {
+		record, err := loadExtractRecord(videoID)
+		if err != nil {
+			return nil, err
+		}
+		return parseExtractResponse(record.Status, http.Header{}, []byte(record.Body), nil)
+	}
+
+	apiURL := fmt.Sprintf("%s/%s", config.ExtractAPIBase, videoID)
+	if config.WARPProxyURL != config.ProxyDirect {
+		apiURL += "?proxy=" + config.WARPProxyURL
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", apiURL, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
-	req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36")
+	profile := setOriginHeaders(req, false)
+	signExtractRequest(req)
 
 	resp, err := config.ExtractClient.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch metadata: %w", err)
 	}
 	defer resp.Body.Close()
+	recordOriginStatus(profile, resp.StatusCode)
 
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("API error (status %d): %s", resp.StatusCode, string(body))
+	body, readErr := io.ReadAll(resp.Body)
+	if config.DebugRecordExtract != "" && readErr == nil {
+		recordExtract(videoID, req, resp.StatusCode, body)
 	}
+	return parseExtractResponse(resp.StatusCode, resp.Header, body, readErr)
+}
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read response: %w", err)
+// parseExtractResponse turns an extract API response into its result or error
+func parseExtractResponse(status int, header http.Header, body []byte, readErr error) (*models.ExtractResponse, error) {
+	if err := checkExtractAuth(status); err != nil {
+		return nil, err
+	}
+
+	if status == http.StatusTooManyRequests || status == http.StatusServiceUnavailable {
+		return nil, startExtractCooldown(header)
+	}
+
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("API error (status %d): %s", status, string(body))
+	}
+
+	if readErr != nil {
+		return nil, fmt.Errorf("failed to read response: %w", readErr)
 	}
 
 	var result models.ExtractResponse
@@ -50,8 +109,6 @@ func Extract(videoID string) (*models.ExtractResponse, error) {
 
 // SelectVideo selects the best video stream based on quality and device
 func SelectVideo(data *models.ExtractResponse, requestedQuality string, osType string) *models.VideoSelectionResult {
-	result := &models.VideoSelectionResult{}
-
 	// Get device profile
 	profile, ok := config.DeviceProfiles[osType]
 	if !ok {
@@ -61,12 +118,68 @@ func SelectVideo(data *models.ExtractResponse, requestedQuality string, osType s
 	// Filter streams by supported codecs
 	var compatibleStreams []models.Stream
 	for _, stream := range data.VideoStreams {
+		if isProtectedStream(&stream) {
+			logProtectedStream(&stream)
+			continue
+		}
 		codec := getStreamCodec(&stream)
 		if isCodecSupported(codec, profile.VideoCodecs) {
 			compatibleStreams = append(compatibleStreams, stream)
 		}
 	}
 
+	return selectVideoByQuality(compatibleStreams, requestedQuality, profile)
+}
+
+// SelectVideoTranscode selects the best downloadable video stream regardless of codec
+// Fallback (allowTranscode) when the device supports none of the offered codecs;
+// the result is re-encoded, so NeedsReencode is set.
+func SelectVideoTranscode(data *models.ExtractResponse, requestedQuality string, osType string) *models.VideoSelectionResult {
+	profile, ok := config.DeviceProfiles[osType]
+	if !ok {
+		profile = config.DefaultProfile
+	}
+
+	var streams []models.Stream
+	for _, stream := range data.VideoStreams {
+		if !isProtectedStream(&stream) {
+			streams = append(streams, stream)
+		}
+	}
+
+	result := selectVideoByQuality(streams, requestedQuality, profile)
+	result.NeedsReencode = result.Stream != nil
+	return result
+}
+
+// OfferedVideoCodecs lists the distinct codecs of a video's downloadable streams
+func OfferedVideoCodecs(data *models.ExtractResponse) []string {
+	var codecs []string
+	for _, stream := range data.VideoStreams {
+		if codec := getStreamCodec(&stream); !isProtectedStream(&stream) && codec != "" && !slices.Contains(codecs, codec) {
+			codecs = append(codecs, codec)
+		}
+	}
+	slices.Sort(codecs)
+	return codecs
+}
+
+// ProfilesForCodecs lists the device profiles (os values) that can play one of codecs
+func ProfilesForCodecs(codecs []string) []string {
+	var profiles []string
+	for osType, profile := range config.DeviceProfiles {
+		if slices.ContainsFunc(codecs, func(codec string) bool { return isCodecSupported(codec, profile.VideoCodecs) }) {
+			profiles = append(profiles, osType)
+		}
+	}
+	slices.Sort(profiles)
+	return profiles
+}
+
+// selectVideoByQuality picks the stream closest to requestedQuality within the device limits
+func selectVideoByQuality(compatibleStreams []models.Stream, requestedQuality string, profile config.DeviceProfile) *models.VideoSelectionResult {
+	result := &models.VideoSelectionResult{}
+
 	if len(compatibleStreams) == 0 {
 		return result
 	}
@@ -160,6 +273,10 @@ func SelectAudio(data *models.ExtractResponse, trackID string, osType string) *m
 	// Filter streams by supported codecs
 	var compatibleStreams []models.Stream
 	for _, stream := range data.AudioStreams {
+		if isProtectedStream(&stream) {
+			logProtectedStream(&stream)
+			continue
+		}
 		codec := getStreamCodec(&stream)
 		if isCodecSupported(codec, profile.AudioCodecs) {
 			compatibleStreams = append(compatibleStreams, stream)
@@ -214,6 +331,29 @@ func SelectAudio(data *models.ExtractResponse, trackID string, osType string) *m
 	return nil
 }
 
+var extractLog = utils.Logger(utils.LogExtract)
+
+// isProtectedStream reports whether a stream can't be downloaded directly:
+// DRM-protected, or its URL needs signature deciphering (signatureCipher / no URL)
+func isProtectedStream(stream *models.Stream) bool {
+	return stream.DRM || stream.Cipher != "" || stream.URL == ""
+}
+
+// logProtectedStream logs an excluded stream to help debug extract provider issues
+func logProtectedStream(stream *models.Stream) {
+	extractLog.Debug("excluding protected stream", "itag", stream.Itag, "codec", getStreamCodec(stream), "drm", stream.DRM, "cipher", stream.Cipher != "")
+}
+
+// HasOnlyProtectedStreams reports whether streams is non-empty but nothing in it is playable
+func HasOnlyProtectedStreams(streams []models.Stream) bool {
+	for i := range streams {
+		if !isProtectedStream(&streams[i]) {
+			return false
+		}
+	}
+	return len(streams) > 0
+}
+
 // getStreamCodec returns the codec from Stream, preferring Codec field over mimeType extraction
 func getStreamCodec(stream *models.Stream) string {
 	// Prefer direct codec field if available
@@ -273,8 +413,35 @@ func codecPriority(codec string, priorityList []string) int {
 	return len(priorityList)
 }
 
+// DescribeStream returns a selected stream's technical details for API responses
+func DescribeStream(stream *models.Stream) *models.SelectedStream {
+	language, _, _ := strings.Cut(stream.AudioTrackID, ".")
+	return &models.SelectedStream{
+		Itag:     stream.Itag,
+		Codec:    getStreamCodec(stream),
+		Height:   stream.Height,
+		FPS:      stream.FPS,
+		Bitrate:  int64(stream.Bitrate),
+		Size:     stream.ContentLength,
+		TrackID:  stream.AudioTrackID,
+		Language: language,
+	}
+}
+
+// IsLossyStream reports whether a stream's codec is lossy (e.g. opus, mp4a)
+func IsLossyStream(stream *models.Stream) bool {
+	return !isCodecSupported(getStreamCodec(stream), config.LosslessCodecs)
+}
+
 // GetExtension returns file extension for a stream
+// Audio-only streams are named by codec so the extension always matches
+// AudioExtCodecs (opus/vorbis: webm, mp4a: m4a); video keeps its container.
 func GetExtension(stream *models.Stream) string {
+	if strings.HasPrefix(stream.MimeType, "audio/") {
+		if ext := config.AudioCodecExtensions[getStreamCodec(stream)]; ext != "" {
+			return ext
+		}
+	}
 	return utils.GetExtFromMimeType(stream.MimeType)
 }
 
@@ -308,3 +475,44 @@ func NeedsReencode(videoStream *models.Stream, audioStream *models.Stream, targe
 
 	return false
 }
+
+// ResolveAutoFormat picks the container that can hold the selected streams without transcoding
+// - Video: mp4 for avc1+mp4a, webm for vp9/av01+opus, mkv otherwise; without audio (muted)
+// the video codec alone decides
+// - Audio: m4a for mp4a, opus for opus, otherwise the source container
+func ResolveAutoFormat(outputType string, videoStream *models.Stream, audioStream *models.Stream) string {
+	audioCodec := ""
+	if audioStream != nil {
+		audioCodec = getStreamCodec(audioStream)
+	}
+
+	if outputType == "video" {
+		videoCodec := ""
+		if videoStream != nil {
+			videoCodec = getStreamCodec(videoStream)
+		}
+		switch {
+		case videoCodec == "avc1" && (audioStream == nil || strings.HasPrefix(audioCodec, "mp4a")):
+			return "mp4"
+		case slices.Contains([]string{"vp9", "vp09", "av01"}, videoCodec) && (audioStream == nil || audioCodec == "opus"):
+			return "webm"
+		default:
+			return "mkv"
+		}
+	}
+
+	switch {
+	case strings.HasPrefix(audioCodec, "mp4a"):
+		return "m4a"
+	case audioCodec == "opus":
+		return "opus"
+	}
+
+	// Keep the source container when it is a supported output, else fall back to a lossless one
+	if audioStream != nil {
+		if ext := GetExtension(audioStream); slices.Contains(config.AudioFormats, ext) {
+			return ext
+		}
+	}
+	return "flac"
+}
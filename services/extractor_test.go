@@ -0,0 +1,69 @@
+package services
+
+import (
+	"testing"
+	"yt-downloader-go/models"
+)
+
+// Stream mime types and codecs as returned by the extract API
+var extensionFixtures = []struct {
+	name     string
+	mimeType string
+	codec    string
+	ext      string
+	base     string // getStreamCodec
+}{
+	{name: "h264", mimeType: `video/mp4; codecs="avc1.640028"`, ext: "mp4", base: "avc1"},
+	{name: "av1 in mp4", mimeType: `video/mp4; codecs="av01.0.08M.08"`, ext: "mp4", base: "av01"},
+	{name: "vp9", mimeType: `video/webm; codecs="vp9"`, ext: "webm", base: "vp9"},
+	{name: "vp9 profile 2", mimeType: `video/webm; codecs="vp09.02.51.10.01.09.16.09.00"`, ext: "webm", base: "vp09"},
+	{name: "muxed mp4", mimeType: `video/mp4; codecs="avc1.42001E, mp4a.40.2"`, ext: "mp4", base: "avc1"},
+	{name: "aac lc", mimeType: `audio/mp4; codecs="mp4a.40.2"`, ext: "m4a", base: "mp4a"},
+	{name: "he-aac", mimeType: `audio/mp4; codecs="mp4a.40.5"`, ext: "m4a", base: "mp4a"},
+	{name: "opus in webm", mimeType: `audio/webm; codecs="opus"`, ext: "webm", base: "opus"},
+	{name: "opus labelled mp4", mimeType: `audio/mp4; codecs="opus"`, ext: "webm", base: "opus"},
+	{name: "opus unquoted", mimeType: `audio/webm;codecs=opus`, ext: "webm", base: "opus"},
+	{name: "vorbis in webm", mimeType: `audio/webm; codecs="vorbis"`, ext: "webm", base: "vorbis"},
+	{name: "codec field wins", mimeType: `audio/mp4`, codec: "opus", ext: "webm", base: "opus"},
+	{name: "codec field with profile", mimeType: `audio/mp4`, codec: "mp4a.40.2", ext: "m4a", base: "mp4a"},
+	{name: "webm without codecs", mimeType: `audio/webm`, ext: "webm", base: "webm"},
+	{name: "mp4 audio without codecs", mimeType: `audio/mp4`, ext: "m4a", base: "mp4"},
+	{name: "eac3 keeps the container", mimeType: `audio/mp4; codecs="ec-3"`, ext: "m4a", base: "ec-3"},
+	{name: "mpeg audio", mimeType: `audio/mpeg`, ext: "mp3", base: "mpeg"},
+}
+
+func TestGetExtension(t *testing.T) {
+	for _, tt := range extensionFixtures {
+		t.Run(tt.name, func(t *testing.T) {
+			stream := &models.Stream{MimeType: tt.mimeType, Codec: tt.codec}
+			if got := GetExtension(stream); got != tt.ext {
+				t.Errorf("GetExtension = %q, want %q", got, tt.ext)
+			}
+			if got := getStreamCodec(stream); got != tt.base {
+				t.Errorf("getStreamCodec = %q, want %q", got, tt.base)
+			}
+		})
+	}
+}
+
+// Audio files named by GetExtension stream-copy into the formats of their codec
+func TestGetExtensionCopiesByCodec(t *testing.T) {
+	tests := []struct {
+		mimeType string
+		format   string
+	}{
+		{`audio/mp4; codecs="opus"`, "opus"},
+		{`audio/webm; codecs="opus"`, "ogg"},
+		{`audio/mp4; codecs="mp4a.40.2"`, "m4a"},
+		{`audio/mp4; codecs="mp4a.40.2"`, "mp4"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.mimeType+" to "+tt.format, func(t *testing.T) {
+			ext := GetExtension(&models.Stream{MimeType: tt.mimeType})
+			if !CanCopyAudio(ext, "", tt.format, "") {
+				t.Errorf("audio.%s can't be copied into %s", ext, tt.format)
+			}
+		})
+	}
+}
@@ -0,0 +1,156 @@
+package services
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"yt-downloader-go/config"
+)
+
+// extractRecordSuffix ends every recording file: "<unix nanos>-<videoID>.json.gz"
+const extractRecordSuffix = ".json.gz"
+
+// scrubbedHeaders are replaced in recordings
+var scrubbedHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "X-Signature"}
+
+// extractRecord is one recorded extract API exchange (DEBUG_RECORD_EXTRACT)
+type extractRecord struct {
+	VideoID    string            `json:"videoId"`
+	URL        string            `json:"url"`
+	Headers    map[string]string `json:"headers"`
+	Status     int               `json:"status"`
+	RecordedAt int64             `json:"recordedAt"`
+	Body       string            `json:"body"` // Raw response body
+}
+
+// recordMu serializes writing and rotating recordings
+var recordMu sync.Mutex
+
+// recordExtract writes an extract exchange to DEBUG_RECORD_EXTRACT and drops the oldest
+// recordings past DEBUG_RECORD_EXTRACT_MAX. Failures are logged, never returned.
+func recordExtract(videoID string, req *http.Request, status int, body []byte) {
+	record := extractRecord{
+		VideoID:    videoID,
+		URL:        scrubExtractURL(req.URL),
+		Headers:    map[string]string{},
+		Status:     status,
+		RecordedAt: time.Now().UnixMilli(),
+		Body:       string(body),
+	}
+	for name := range req.Header {
+		record.Headers[name] = req.Header.Get(name)
+	}
+	for _, name := range scrubbedHeaders {
+		if req.Header.Get(name) != "" {
+			record.Headers[http.CanonicalHeaderKey(name)] = "[scrubbed]"
+		}
+	}
+
+	recordMu.Lock()
+	defer recordMu.Unlock()
+
+	if err := writeExtractRecord(&record); err != nil {
+		extractLog.Warn("recording extract response failed", "video", videoID, "err", err)
+		return
+	}
+	rotateExtractRecords()
+}
+
+// scrubExtractURL returns u with credentials removed, including those of the proxy parameter
+func scrubExtractURL(u *url.URL) string {
+	scrubbed := *u
+	scrubbed.User = nil
+	query := scrubbed.Query()
+	if proxy, err := url.Parse(query.Get("proxy")); err == nil && proxy.User != nil {
+		query.Set("proxy", proxy.Redacted())
+		scrubbed.RawQuery = query.Encode()
+	}
+	return scrubbed.String()
+}
+
+// writeExtractRecord stores record as a gzipped JSON file (temp file and rename)
+func writeExtractRecord(record *extractRecord) error {
+	if err := os.MkdirAll(config.DebugRecordExtract, 0700); err != nil {
+		return err
+	}
+	name := fmt.Sprintf("%d-%s%s", time.Now().UnixNano(), record.VideoID, extractRecordSuffix)
+	path := filepath.Join(config.DebugRecordExtract, name)
+
+	file, err := os.OpenFile(path+".new", os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	zw := gzip.NewWriter(file)
+	err = json.NewEncoder(zw).Encode(record)
+	if closeErr := zw.Close(); err == nil {
+		err = closeErr
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path + ".new")
+		return err
+	}
+	return os.Rename(path+".new", path)
+}
+
+// listExtractRecords returns the recording file names in dir, oldest first
+func listExtractRecords(dir string) []string {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+	var names []string
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.HasSuffix(entry.Name(), extractRecordSuffix) {
+			names = append(names, entry.Name())
+		}
+	}
+	// Names start with the recording time; same-length nanosecond stamps sort as strings
+	sort.Strings(names)
+	return names
+}
+
+// rotateExtractRecords removes the oldest recordings past DEBUG_RECORD_EXTRACT_MAX
+func rotateExtractRecords() {
+	names := listExtractRecords(config.DebugRecordExtract)
+	for len(names) > max(config.DebugRecordExtractMax, 1) {
+		os.Remove(filepath.Join(config.DebugRecordExtract, names[0]))
+		names = names[1:]
+	}
+}
+
+// loadExtractRecord reads the newest recording of a video from EXTRACT_REPLAY_DIR
+func loadExtractRecord(videoID string) (*extractRecord, error) {
+	names := listExtractRecords(config.ExtractReplayDir)
+	for i := len(names) - 1; i >= 0; i-- {
+		if !strings.HasSuffix(names[i], "-"+videoID+extractRecordSuffix) {
+			continue
+		}
+
+		file, err := os.Open(filepath.Join(config.ExtractReplayDir, names[i]))
+		if err != nil {
+			return nil, fmt.Errorf("replay %s: %w", videoID, err)
+		}
+		defer file.Close()
+		zr, err := gzip.NewReader(file)
+		if err != nil {
+			return nil, fmt.Errorf("replay %s: %w", videoID, err)
+		}
+		var record extractRecord
+		if err := json.NewDecoder(zr).Decode(&record); err != nil {
+			return nil, fmt.Errorf("replay %s: %w", videoID, err)
+		}
+		return &record, nil
+	}
+	return nil, fmt.Errorf("replay %s: no recording in EXTRACT_REPLAY_DIR", videoID)
+}
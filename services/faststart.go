@@ -0,0 +1,47 @@
+package services
+
+import (
+	"context"
+	"path/filepath"
+	"yt-downloader-go/config"
+	"yt-downloader-go/models"
+	"yt-downloader-go/utils"
+)
+
+// faststartFormats are the mp4-family containers whose moov atom +faststart moves to the front
+var faststartFormats = map[string]bool{"mp4": true, "m4a": true, "m4b": true}
+
+type faststartKey struct{}
+
+// WithFaststart returns a context whose mp4-family ffmpeg outputs are written with +faststart
+func WithFaststart(ctx context.Context) context.Context {
+	return context.WithValue(ctx, faststartKey{}, true)
+}
+
+// faststartArgs returns the muxer flags of a file output of ctx in format
+func faststartArgs(ctx context.Context, format string) []string {
+	if enabled, _ := ctx.Value(faststartKey{}).(bool); !enabled || !faststartFormats[format] {
+		return nil
+	}
+	return []string{"-movflags", "+faststart"}
+}
+
+// UseFaststart reports whether the output of meta, rendered from the sources in dir, gets
+// +faststart: MP4_FASTSTART is on, the format is mp4-family and the sources, an upper bound
+// of a copied output, are within MP4_FASTSTART_MAX_MB
+func UseFaststart(meta *models.Meta, dir string) bool {
+	if !config.Faststart || !faststartFormats[meta.Format] {
+		return false
+	}
+	if config.FaststartMaxBytes <= 0 {
+		return true
+	}
+
+	var total int64
+	for _, file := range []*models.FileInfo{meta.Files.Video, meta.Files.Audio} {
+		if file != nil {
+			total += utils.GetFileSize(filepath.Join(dir, file.Name))
+		}
+	}
+	return total <= config.FaststartMaxBytes
+}
@@ -0,0 +1,102 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// mp4Boxes returns the types of the top-level boxes of an mp4 file, in order
+func mp4Boxes(t *testing.T, path string) []string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var boxes []string
+	for offset := 0; offset+8 <= len(data); {
+		size := uint64(binary.BigEndian.Uint32(data[offset:]))
+		boxType := string(data[offset+4 : offset+8])
+		switch size {
+		case 0: // Runs to the end of the file
+			size = uint64(len(data) - offset)
+		case 1: // 64-bit size after the type
+			if offset+16 > len(data) {
+				t.Fatalf("%s: truncated %s box at %d", path, boxType, offset)
+			}
+			size = binary.BigEndian.Uint64(data[offset+8:])
+		}
+		if size < 8 || size > uint64(len(data)-offset) {
+			t.Fatalf("%s: %s box at %d has size %d", path, boxType, offset, size)
+		}
+		boxes = append(boxes, boxType)
+		offset += int(size)
+	}
+	return boxes
+}
+
+// mp4Box builds a box with a 32-bit size, or a 64-bit one when large is set
+func mp4Box(boxType string, payload []byte, large bool) []byte {
+	var b bytes.Buffer
+	if large {
+		binary.Write(&b, binary.BigEndian, uint32(1))
+		b.WriteString(boxType)
+		binary.Write(&b, binary.BigEndian, uint64(16+len(payload)))
+	} else {
+		binary.Write(&b, binary.BigEndian, uint32(8+len(payload)))
+		b.WriteString(boxType)
+	}
+	b.Write(payload)
+	return b.Bytes()
+}
+
+func TestMP4Boxes(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "output.mp4")
+	data := slices.Concat(
+		mp4Box("ftyp", []byte("isom\x00\x00\x02\x00"), false),
+		mp4Box("moov", mp4Box("mvhd", make([]byte, 100), false), false),
+		mp4Box("mdat", make([]byte, 300), true),
+		mp4Box("free", nil, false),
+	)
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Nested boxes (mvhd) aren't listed
+	if got, want := mp4Boxes(t, path), []string{"ftyp", "moov", "mdat", "free"}; !slices.Equal(got, want) {
+		t.Errorf("mp4Boxes = %v, want %v", got, want)
+	}
+}
+
+// +faststart moves the moov atom ahead of the media data; without it ffmpeg writes it last
+func TestFaststartMoovFirst(t *testing.T) {
+	useRealFFmpeg(t)
+	dir := t.TempDir()
+	generate(t, dir, "video.mp4", "-f", "lavfi", "-i", "testsrc=duration=2:size=160x90:rate=10", "-c:v", "mpeg4")
+	generate(t, dir, "audio.m4a", "-f", "lavfi", "-i", "sine=duration=2", "-c:a", "aac")
+
+	for _, faststart := range []bool{false, true} {
+		ctx := context.Background()
+		if faststart {
+			ctx = WithFaststart(ctx)
+		}
+		output, err := FFmpegMerge(ctx, dir, "mp4", "video.mp4", "audio.m4a", false, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		boxes := mp4Boxes(t, filepath.Join(dir, output))
+		moov, mdat := slices.Index(boxes, "moov"), slices.Index(boxes, "mdat")
+		if moov < 0 || mdat < 0 {
+			t.Fatalf("faststart %t: boxes %v, want moov and mdat", faststart, boxes)
+		}
+		if (moov < mdat) != faststart {
+			t.Errorf("faststart %t: boxes %v", faststart, boxes)
+		}
+	}
+}
@@ -1,28 +1,62 @@
 package services
 
 import (
+	"context"
 	"fmt"
+	"math"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
 	"yt-downloader-go/config"
 	"yt-downloader-go/models"
 )
 
-// FFmpegMerge merges video and audio files
-func FFmpegMerge(jobDir string, format string, videoFile string, audioFile string) (string, error) {
+// FFmpegError represents a failed ffmpeg invocation
+type FFmpegError struct {
+	Err error
+}
+
+func (e *FFmpegError) Error() string {
+	return fmt.Sprintf("ffmpeg error: %v", e.Err)
+}
+
+func (e *FFmpegError) Unwrap() error {
+	return e.Err
+}
+
+// FFmpegMerge merges video and audio files; an empty audioFile remuxes the video alone (audio.mute)
+// transcodeVideo re-encodes the video with the format's encoder (allowTranscode fallback,
+// forceRotate); rotation is the job's rotation handling (nil when not probed).
+func FFmpegMerge(ctx context.Context, jobDir string, format string, videoFile string, audioFile string, transcodeVideo bool, rotation *models.VideoRotation) (string, error) {
 	outputFile := filepath.Join(jobDir, fmt.Sprintf("output.%s", format))
 
-	args := []string{
-		"-y",
-		"-i", filepath.Join(jobDir, videoFile),
-		"-i", filepath.Join(jobDir, audioFile),
-		"-c:v", "copy",
-		"-c:a", "copy",
-		outputFile,
+	args := []string{"-y"}
+	args = append(args, RotationInputArgs(rotation)...)
+	args = append(args, "-i", filepath.Join(jobDir, videoFile))
+	if audioFile != "" {
+		args = append(args, "-i", filepath.Join(jobDir, audioFile))
+	}
+	if transcodeVideo {
+		videoCodec := config.VideoCodecMap[format]
+		if videoCodec == "" {
+			videoCodec = "libx264"
+		}
+		// yuv420p keeps H.264 playable on Apple devices (VP9/AV1 sources may be 10-bit)
+		args = append(args, "-threads", "0", "-c:v", videoCodec, "-pix_fmt", "yuv420p")
+	} else {
+		args = append(args, "-c:v", "copy")
+		args = append(args, RotationCopyArgs(rotation)...)
+	}
+	if audioFile != "" {
+		args = append(args, "-c:a", "copy")
+	} else {
+		args = append(args, "-an")
 	}
 
-	if err := runFFmpeg(args); err != nil {
+	if err := runFFmpegToFile(ctx, args, format, outputFile); err != nil {
 		return "", fmt.Errorf("merge failed: %w", err)
 	}
 
@@ -30,13 +64,16 @@ func FFmpegMerge(jobDir string, format string, videoFile string, audioFile strin
 }
 
 // FFmpegConvertAudio converts audio to target format
-func FFmpegConvertAudio(jobDir string, format string, bitrate string, audioFile string) (string, error) {
+// codec overrides the format's default encoder (ogg: "vorbis"), empty = default
+// sourceCodec is the audio stream codec when known (see CanCopyAudio)
+// filter is an -af chain (AudioFilter), empty = none
+// channels is the output channel count (OutputChannels), 0 = as the source
+func FFmpegConvertAudio(ctx context.Context, jobDir string, format string, bitrate string, codec string, sourceCodec string, audioFile string, filter string, channels int) (string, error) {
 	inputPath := filepath.Join(jobDir, audioFile)
 	outputFile := filepath.Join(jobDir, fmt.Sprintf("output.%s", format))
 
-	// Determine if we need to encode or can copy
-	inputExt := filepath.Ext(audioFile)
-	canCopy := canCopyAudio(inputExt, format)
+	// Determine if we need to encode or can copy (VBR, filters and channel changes always re-encode)
+	canCopy := filter == "" && channels == 0 && !NeedsAudioTranscode(filepath.Ext(audioFile), sourceCodec, format, bitrate, codec)
 
 	var args []string
 	if canCopy {
@@ -44,30 +81,23 @@ func FFmpegConvertAudio(jobDir string, format string, bitrate string, audioFile
 			"-y",
 			"-i", inputPath,
 			"-c:a", "copy",
-			outputFile,
 		}
 	} else {
-		codec := config.AudioCodecMap[format]
-		if codec == "" {
-			codec = "aac"
-		}
-
 		args = []string{
 			"-y",
 			"-i", inputPath,
 			"-threads", "0",
-			"-c:a", codec,
 		}
-
-		// Add bitrate for lossy codecs
-		if bitrate != "" && codec != "pcm_s16le" && codec != "flac" {
-			args = append(args, "-b:a", bitrate)
+		if filter != "" {
+			args = append(args, "-af", filter)
 		}
+		args = append(args, channelArgs(channels)...)
 
-		args = append(args, outputFile)
+		// Encoder, profile and bitrate (or VBR quality)
+		args = append(args, AudioEncodeArgs(format, codec, bitrate)...)
 	}
 
-	if err := runFFmpeg(args); err != nil {
+	if err := runFFmpegToFile(ctx, args, format, outputFile); err != nil {
 		return "", fmt.Errorf("audio conversion failed: %w", err)
 	}
 
@@ -75,22 +105,46 @@ func FFmpegConvertAudio(jobDir string, format string, bitrate string, audioFile
 }
 
 // ffmpegTrim is the internal trim function for both video and audio
-func ffmpegTrim(jobDir string, format string, trim *models.TrimConfig, bitrate string, isVideo bool) (string, error) {
+// muted marks video without an audio stream (audio.mute)
+// rotation is the video's rotation handling (nil for audio or when not probed)
+func ffmpegTrim(ctx context.Context, jobDir string, format string, trim *models.TrimConfig, bitrate string, codec string, isVideo bool, muted bool, rotation *models.VideoRotation) (string, error) {
 	if trim.End <= trim.Start {
-		return "", fmt.Errorf("invalid trim range: end (%.2f) must be greater than start (%.2f)", trim.End, trim.Start)
+		return "", &models.JobError{
+			Code:    models.JobErrInvalidTrim,
+			Message: fmt.Sprintf("invalid trim range: end (%.2f) must be greater than start (%.2f)", trim.End, trim.Start),
+			Phase:   models.PhaseValidation,
+		}
 	}
 
+	// Reads output.<format> and replaces it through output.<format>.part
 	inputPath := filepath.Join(jobDir, fmt.Sprintf("output.%s", format))
-	outputPath := filepath.Join(jobDir, fmt.Sprintf("output_trimmed.%s", format))
 	duration := trim.End - trim.Start
 
 	var args []string
 	if trim.Accurate {
-		args = []string{
-			"-y",
-			"-ss", fmt.Sprintf("%.3f", trim.Start),
+		// Coarse input seek to a keyframe before the start keeps the decode window small,
+		// then a precise output seek makes the cut frame-exact
+		coarseStart := max(trim.Start-accurateSeekPreroll, 0)
+		args = []string{"-y"}
+		args = append(args, RotationInputArgs(rotation)...)
+		args = append(args,
+			"-ss", fmt.Sprintf("%.3f", coarseStart),
 			"-i", inputPath,
+			"-ss", fmt.Sprintf("%.3f", trim.Start-coarseStart),
 			"-t", fmt.Sprintf("%.3f", duration),
+		)
+
+		// Filter timestamps start at the coarse seek point, so fades are offset by the preroll
+		if trim.Fade != nil {
+			offset := trim.Start - coarseStart
+			if filter := fadeFilter("afade", trim.Fade, offset, duration); filter != "" && !muted {
+				args = append(args, "-af", filter)
+			}
+			if isVideo && trim.Fade.Video {
+				if filter := fadeFilter("fade", trim.Fade, offset, duration); filter != "" {
+					args = append(args, "-vf", filter)
+				}
+			}
 		}
 
 		if isVideo {
@@ -102,21 +156,19 @@ func ffmpegTrim(jobDir string, format string, trim *models.TrimConfig, bitrate s
 			if audioCodec == "" {
 				audioCodec = "aac"
 			}
-			args = append(args, "-c:v", videoCodec, "-c:a", audioCodec)
-			if bitrate != "" {
-				args = append(args, "-b:a", bitrate)
+			args = append(args, "-c:v", videoCodec)
+			if muted {
+				args = append(args, "-an")
+			} else {
+				args = append(args, "-c:a", audioCodec)
+				if bitrate != "" {
+					args = append(args, "-b:a", bitrate)
+				}
 			}
 		} else {
-			audioCodec := config.AudioCodecMap[format]
-			if audioCodec == "" {
-				audioCodec = "aac"
-			}
-			args = append(args, "-threads", "0", "-c:a", audioCodec)
-			if bitrate != "" && audioCodec != "pcm_s16le" && audioCodec != "flac" {
-				args = append(args, "-b:a", bitrate)
-			}
+			args = append(args, "-threads", "0")
+			args = append(args, AudioEncodeArgs(format, codec, bitrate)...)
 		}
-		args = append(args, outputPath)
 	} else {
 		// Fast trim: copy
 		args = []string{
@@ -127,70 +179,227 @@ func ffmpegTrim(jobDir string, format string, trim *models.TrimConfig, bitrate s
 		}
 		if isVideo {
 			args = append(args, "-c", "copy")
+			args = append(args, RotationCopyArgs(rotation)...)
 		} else {
 			args = append(args, "-c:a", "copy")
 		}
-		args = append(args, outputPath)
 	}
 
-	if err := runFFmpeg(args); err != nil {
+	if err := runFFmpegToFile(ctx, args, format, inputPath); err != nil {
 		return "", fmt.Errorf("trim failed: %w", err)
 	}
 
-	_ = os.Remove(inputPath)
-	if err := os.Rename(outputPath, inputPath); err != nil {
-		return "", fmt.Errorf("failed to rename trimmed file: %w", err)
+	// Verify accurate trims (output may be shorter when end is past the source end)
+	if trim.Accurate {
+		if actual, err := probeDuration(inputPath); err == nil && math.Abs(actual-duration) > accurateTrimTolerance {
+			jobLog.Warn("accurate trim duration differs", "path", inputPath, "duration", actual, "expected", duration)
+		}
 	}
 
 	return fmt.Sprintf("output.%s", format), nil
 }
 
+// fadeFilter builds a fade-in/fade-out filter chain (afade or fade) for a clip
+// starting at offset. Fades longer than the clip are clamped to its duration.
+func fadeFilter(name string, fade *models.FadeConfig, offset float64, duration float64) string {
+	var filters []string
+	if in := min(fade.In, duration); in > 0 {
+		filters = append(filters, fmt.Sprintf("%s=t=in:st=%.3f:d=%.3f", name, offset, in))
+	}
+	if out := min(fade.Out, duration); out > 0 {
+		filters = append(filters, fmt.Sprintf("%s=t=out:st=%.3f:d=%.3f", name, offset+duration-out, out))
+	}
+	return strings.Join(filters, ",")
+}
+
+// Accurate trim tuning
+const (
+	accurateSeekPreroll   = 10.0 // Seconds decoded before the cut point
+	accurateTrimTolerance = 0.05 // Max allowed output duration drift (seconds)
+)
+
+// probeDuration returns a media file's duration in seconds using ffprobe
+func probeDuration(path string) (float64, error) {
+	out, err := exec.Command(config.FFprobePath,
+		"-v", "error",
+		"-show_entries", "format=duration",
+		"-of", "default=noprint_wrappers=1:nokey=1",
+		path,
+	).Output()
+	if err != nil {
+		return 0, fmt.Errorf("ffprobe error: %w", err)
+	}
+	return strconv.ParseFloat(strings.TrimSpace(string(out)), 64)
+}
+
 // FFmpegTrim trims video file
-func FFmpegTrim(jobDir string, format string, trim *models.TrimConfig, bitrate string) (string, error) {
-	return ffmpegTrim(jobDir, format, trim, bitrate, true)
+func FFmpegTrim(ctx context.Context, jobDir string, format string, trim *models.TrimConfig, bitrate string, muted bool, rotation *models.VideoRotation) (string, error) {
+	return ffmpegTrim(ctx, jobDir, format, trim, bitrate, "", true, muted, rotation)
 }
 
 // FFmpegTrimAudio trims audio file
-func FFmpegTrimAudio(jobDir string, format string, trim *models.TrimConfig, bitrate string) (string, error) {
-	return ffmpegTrim(jobDir, format, trim, bitrate, false)
+func FFmpegTrimAudio(ctx context.Context, jobDir string, format string, trim *models.TrimConfig, bitrate string, codec string) (string, error) {
+	return ffmpegTrim(ctx, jobDir, format, trim, bitrate, codec, false, false, nil)
+}
+
+// StreamVideoArgs returns the ffmpeg arguments remuxing the sources of a stream-only video
+// job to stdout (no re-encoding, very light CPU); an empty audioPath streams it muted
+func StreamVideoArgs(meta *models.Meta, videoPath string, audioPath string) []string {
+	args := []string{"-y", "-i", videoPath}
+	if audioPath != "" {
+		args = append(args, "-i", audioPath, "-c:v", "copy", "-c:a", "copy")
+	} else {
+		args = append(args, "-c:v", "copy", "-an")
+	}
+	args = append(args, RotationCopyArgs(meta.Rotation)...)
+	args = append(args, "-f", FFmpegMuxer(meta.Format))
+
+	// Fragmented MP4 can be written to a pipe and played while it arrives
+	if meta.Format == "mp4" {
+		args = append(args, "-movflags", "frag_keyframe+empty_moov+faststart")
+	}
+	return append(args, "pipe:1")
+}
+
+// StreamAudioArgs returns the ffmpeg arguments streaming the audio source of a stream-only
+// job to stdout, copied when the format allows and transcoded otherwise
+func StreamAudioArgs(meta *models.Meta, audioPath string) []string {
+	if !NeedsAudioTranscode(filepath.Ext(audioPath), SourceAudioCodec(meta), meta.Format, meta.Bitrate, meta.AudioCodec) {
+		return []string{"-y", "-i", audioPath, "-c:a", "copy", "-f", FFmpegMuxer(meta.Format), "pipe:1"}
+	}
+
+	bitrate := meta.Bitrate
+	if bitrate == "" {
+		bitrate = DefaultBitrate(meta.Format, meta.AudioCodec)
+	}
+	args := []string{"-y", "-i", audioPath, "-vn"}
+	args = append(args, AudioEncodeArgs(meta.Format, meta.AudioCodec, bitrate)...)
+	return append(args, "-f", FFmpegMuxer(meta.Format), "pipe:1")
+}
+
+// FFmpegMuxer returns the FFmpeg format (muxer) name for a given extension
+// Needed whenever the output name doesn't end in the extension (pipes, .part files).
+func FFmpegMuxer(ext string) string {
+	switch ext {
+	case "mp4":
+		return "mp4"
+	case "webm":
+		return "webm"
+	case "mkv":
+		return "matroska"
+	case "mp3":
+		return "mp3"
+	case "m4a", "m4b":
+		return "ipod" // FFmpeg uses "ipod" for m4a/m4b
+	case "opus":
+		return "opus"
+	case "ogg":
+		return "ogg"
+	case "wav":
+		return "wav"
+	case "flac":
+		return "flac"
+	default:
+		return ext
+	}
 }
 
 // runFFmpeg executes ffmpeg command
-func runFFmpeg(args []string) error {
-	cmd := exec.Command("ffmpeg", args...)
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
+// ctx attributes the run to its job (WithFFmpegJob); the FFmpeg phase is not cut short by its cancellation.
+func runFFmpeg(ctx context.Context, args []string) error {
+	return FFmpeg.Run(context.WithoutCancel(ctx), args)
+}
 
-	if err := cmd.Run(); err != nil {
-		return fmt.Errorf("ffmpeg error: %w", err)
+// runFFmpegToFile runs ffmpeg with outputPath+".part" as the output and renames it on success
+// A crash or failure never leaves a partial file under the final name.
+func runFFmpegToFile(ctx context.Context, args []string, format string, outputPath string) error {
+	partPath := outputPath + config.PartialSuffix
+	args = append(args, faststartArgs(ctx, format)...)
+	args = append(args, audioTagArgs(ctx)...)
+	args = append(args, "-f", FFmpegMuxer(format), partPath)
+	if err := runFFmpeg(ctx, args); err != nil {
+		os.Remove(partPath)
+		return err
 	}
+	return os.Rename(partPath, outputPath)
+}
+
+// vbrBitrates approximates the average bitrate of LAME V0..V9, for encoders without a quality scale
+var vbrBitrates = [10]string{"245k", "225k", "190k", "175k", "165k", "130k", "115k", "100k", "85k", "65k"}
+
+// IsVBR reports whether bitrate is a VBR quality level ("V0".."V9") instead of a bitrate
+func IsVBR(bitrate string) bool {
+	return len(bitrate) == 2 && bitrate[0] == 'V' && bitrate[1] >= '0' && bitrate[1] <= '9'
+}
 
-	return nil
+// AudioBitsPerSecond returns the average rate of a bitrate ("192k") or VBR level, 0 if unknown
+func AudioBitsPerSecond(bitrate string) int64 {
+	if IsVBR(bitrate) {
+		bitrate = vbrBitrates[bitrate[1]-'0']
+	}
+	kbps, ok := strings.CutSuffix(bitrate, "k")
+	if !ok {
+		return 0
+	}
+	n, err := strconv.ParseInt(kbps, 10, 64)
+	if err != nil {
+		return 0
+	}
+	return n * 1000
 }
 
-// canCopyAudio checks if audio can be copied without re-encoding
-func canCopyAudio(inputExt string, outputFormat string) bool {
-	// Remove leading dot
-	if len(inputExt) > 0 && inputExt[0] == '.' {
-		inputExt = inputExt[1:]
+// AudioRateArgs returns the ffmpeg rate arguments for a bitrate or VBR level
+// Lossless codecs take none.
+func AudioRateArgs(codec string, bitrate string) []string {
+	if bitrate == "" || codec == "pcm_s16le" || codec == "flac" {
+		return nil
+	}
+	if !IsVBR(bitrate) {
+		return []string{"-b:a", bitrate}
 	}
 
-	// Same format: always copy
-	if inputExt == outputFormat {
-		return true
+	level := int(bitrate[1] - '0')
+	switch codec {
+	case "libmp3lame":
+		return []string{"-q:a", strconv.Itoa(level)}
+	case "libopus":
+		return []string{"-vbr", "on", "-compression_level", "10", "-b:a", vbrBitrates[level]}
+	default:
+		return []string{"-b:a", vbrBitrates[level]}
 	}
+}
 
-	// m4a/mp4 are compatible (both use AAC codec typically)
-	if (inputExt == "m4a" || inputExt == "mp4") && (outputFormat == "m4a" || outputFormat == "mp4") {
-		return true
+// AudioEncoder returns the ffmpeg audio encoder for a format and optional codec override
+func AudioEncoder(format string, codec string) string {
+	if encoder := config.AudioCodecOverrideMap[codec]; encoder != "" {
+		return encoder
 	}
+	if encoder := config.AudioCodecMap[format]; encoder != "" {
+		return encoder
+	}
+	return "aac"
+}
 
-	// webm to opus: YouTube webm audio typically contains Opus codec
-	// Note: webm can also contain Vorbis, but YouTube primarily uses Opus for audio
-	// If copy fails, FFmpeg will error and user can retry with re-encoding
-	if inputExt == "webm" && outputFormat == "opus" {
-		return true
+// AudioEncodeArgs returns the ffmpeg encoder, profile and rate arguments for an audio output
+func AudioEncodeArgs(format string, codec string, bitrate string) []string {
+	encoder := AudioEncoder(format, codec)
+	args := []string{"-c:a", encoder}
+	if profile := config.AudioCodecProfiles[codec]; profile != "" {
+		args = append(args, "-profile:a", profile)
 	}
+	return append(args, AudioRateArgs(encoder, bitrate)...)
+}
 
-	return false
+// DefaultBitrate returns the bitrate used when none is requested ("" for lossless formats)
+func DefaultBitrate(format string, codec string) string {
+	if slices.Contains(config.LosslessFormats, format) {
+		return ""
+	}
+	if bitrate := config.DefaultBitrates[codec]; bitrate != "" {
+		return bitrate
+	}
+	if bitrate := config.DefaultBitrates[format]; bitrate != "" {
+		return bitrate
+	}
+	return config.DefaultBitrate
 }
@@ -0,0 +1,181 @@
+package services
+
+import (
+	"context"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"yt-downloader-go/config"
+	"yt-downloader-go/models"
+	"yt-downloader-go/utils"
+)
+
+// crashingFFmpeg writes part of its output (the last argument) and is then killed mid-write
+const crashingFFmpeg = `#!/bin/sh
+for last; do :; done
+printf 'partial output' > "$last"
+kill -9 $$
+`
+
+// useCrashingFFmpeg runs every ffmpeg invocation of the test through crashingFFmpeg
+func useCrashingFFmpeg(t *testing.T) {
+	t.Helper()
+	dir := t.TempDir()
+	path := filepath.Join(dir, "ffmpeg")
+	if err := os.WriteFile(path, []byte(crashingFFmpeg), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	prevRunner, prevSessions := FFmpeg, config.SessionDir
+	FFmpeg = execRunner{path: path}
+	config.SessionDir = filepath.Join(dir, "_sessions")
+	t.Cleanup(func() { FFmpeg, config.SessionDir = prevRunner, prevSessions })
+}
+
+// assertNoPartialOutput checks that a crashed run left neither a .part file nor a new final output
+func assertNoPartialOutput(t *testing.T, dir string, output string) {
+	t.Helper()
+	if parts := utils.PartialFiles(dir); len(parts) > 0 {
+		t.Errorf(".part files left behind: %v", parts)
+	}
+	if _, err := os.Stat(filepath.Join(dir, output)); !os.IsNotExist(err) {
+		t.Errorf("%s exists after the crash (err %v)", output, err)
+	}
+}
+
+func TestFFmpegCrashLeavesNoPartialOutput(t *testing.T) {
+	useCrashingFFmpeg(t)
+	ctx := context.Background()
+
+	t.Run("merge", func(t *testing.T) {
+		dir := t.TempDir()
+		if _, err := FFmpegMerge(ctx, dir, "mp4", "video.mp4", "audio.m4a", false, nil); err == nil {
+			t.Fatal("merge succeeded with a crashing ffmpeg")
+		}
+		assertNoPartialOutput(t, dir, "output.mp4")
+	})
+
+	t.Run("convert", func(t *testing.T) {
+		dir := t.TempDir()
+		if _, err := FFmpegConvertAudio(ctx, dir, "mp3", "192k", "", "opus", "audio.webm", "", 0); err == nil {
+			t.Fatal("convert succeeded with a crashing ffmpeg")
+		}
+		assertNoPartialOutput(t, dir, "output.mp3")
+	})
+
+	// Trim rewrites output.<format> in place: the crash must leave the untrimmed output as it was
+	for _, accurate := range []bool{false, true} {
+		name := "fast trim"
+		if accurate {
+			name = "accurate trim"
+		}
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			output := filepath.Join(dir, "output.mp4")
+			if err := os.WriteFile(output, []byte("complete output"), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			trim := &models.TrimConfig{Start: 10, End: 20, Accurate: accurate}
+			if _, err := FFmpegTrim(ctx, dir, "mp4", trim, "", false, nil); err == nil {
+				t.Fatal("trim succeeded with a crashing ffmpeg")
+			}
+			if parts := utils.PartialFiles(dir); len(parts) > 0 {
+				t.Errorf(".part files left behind: %v", parts)
+			}
+			if data, err := os.ReadFile(output); err != nil || string(data) != "complete output" {
+				t.Errorf("output.mp4 = %q (err %v), want the untrimmed output", data, err)
+			}
+		})
+	}
+}
+
+// audio.mute: the merge drops the audio of the video source and a trim of the muted output
+// adds none back
+func TestMutedOutputHasNoAudio(t *testing.T) {
+	useRealFFmpeg(t)
+	dir := t.TempDir()
+	generate(t, dir, "video.mp4",
+		"-f", "lavfi", "-i", "testsrc=duration=4:size=160x90:rate=10",
+		"-f", "lavfi", "-i", "sine=duration=4",
+		"-c:v", "mpeg4", "-c:a", "aac")
+
+	output, err := FFmpegMerge(context.Background(), dir, "mp4", "video.mp4", "", false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertVideoOnly(t, filepath.Join(dir, output))
+
+	for _, accurate := range []bool{false, true} {
+		trim := &models.TrimConfig{Start: 0.5, End: 3, Accurate: accurate}
+		if _, err := FFmpegTrim(context.Background(), dir, "mp4", trim, "", true, nil); err != nil {
+			t.Fatal(err)
+		}
+		assertVideoOnly(t, filepath.Join(dir, output))
+	}
+}
+
+// assertVideoOnly fails the test unless ffprobe finds a video stream and no audio stream in path
+func assertVideoOnly(t *testing.T, path string) {
+	t.Helper()
+	media := probe(t, path)
+	if video, audio := media.streams("video"), media.streams("audio"); video != 1 || audio != 0 {
+		t.Errorf("%s: %d video and %d audio streams, want 1 and 0", filepath.Base(path), video, audio)
+	}
+}
+
+// Accurate trims of a source with a keyframe every 10s cut within 50ms of the requested length
+func TestAccurateTrimDuration(t *testing.T) {
+	useRealFFmpeg(t)
+
+	tests := []struct {
+		name       string
+		format     string
+		source     []string
+		start, end float64
+	}{
+		{
+			name:   "video between keyframes",
+			format: "mp4",
+			source: []string{"-f", "lavfi", "-i", "testsrc=duration=40:size=160x90:rate=25", "-f", "lavfi", "-i", "sine=duration=40", "-c:v", "mpeg4", "-g", "250", "-c:a", "aac"},
+			start:  13.36, end: 17.8,
+		},
+		{
+			name:   "video inside the preroll",
+			format: "mp4",
+			source: []string{"-f", "lavfi", "-i", "testsrc=duration=20:size=160x90:rate=25", "-c:v", "mpeg4", "-g", "250"},
+			start:  4.2, end: 6.08,
+		},
+		{
+			name:   "audio",
+			format: "wav",
+			source: []string{"-f", "lavfi", "-i", "sine=duration=40"},
+			start:  21.337, end: 29.5,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			generate(t, dir, "output."+tt.format, tt.source...)
+
+			trim := &models.TrimConfig{Start: tt.start, End: tt.end, Accurate: true}
+			var output string
+			var err error
+			if tt.format == "wav" {
+				output, err = FFmpegTrimAudio(context.Background(), dir, tt.format, trim, "", "")
+			} else {
+				output, err = FFmpegTrim(context.Background(), dir, tt.format, trim, "", false, nil)
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			want := tt.end - tt.start
+			if got := probe(t, filepath.Join(dir, output)).duration(t); math.Abs(got-want) > accurateTrimTolerance {
+				t.Errorf("trimmed to %.3fs, want %.3fs", got, want)
+			}
+		})
+	}
+}
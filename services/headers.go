@@ -0,0 +1,44 @@
+package services
+
+import (
+	"expvar"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"yt-downloader-go/config"
+)
+
+// headerProfileStats counts origin requests per header profile as "<name>.requests" and
+// the 403/429 answers among them as "<name>.403" / "<name>.429" (exported via /debug/vars)
+var headerProfileStats = expvar.NewMap("header_profiles")
+
+// headerProfileTurn rotates through config.HeaderProfiles
+var headerProfileTurn atomic.Uint64
+
+// nextHeaderProfile returns the profile for the next origin request (round robin)
+func nextHeaderProfile() *config.HeaderProfile {
+	profiles := config.HeaderProfiles
+	turn := headerProfileTurn.Add(1) - 1
+	return &profiles[turn%uint64(len(profiles))]
+}
+
+// setOriginHeaders applies the next header profile to req; media (stream range) requests
+// also get its MediaHeaders. The profile is returned for recordOriginStatus.
+func setOriginHeaders(req *http.Request, media bool) *config.HeaderProfile {
+	profile := nextHeaderProfile()
+	req.Header.Set("User-Agent", profile.UserAgent)
+	if media {
+		for name, value := range profile.MediaHeaders {
+			req.Header.Set(name, value)
+		}
+	}
+	return profile
+}
+
+// recordOriginStatus counts an answer to a request sent with profile
+func recordOriginStatus(profile *config.HeaderProfile, status int) {
+	headerProfileStats.Add(profile.Name+".requests", 1)
+	if status == http.StatusForbidden || status == http.StatusTooManyRequests {
+		headerProfileStats.Add(profile.Name+"."+strconv.Itoa(status), 1)
+	}
+}
@@ -0,0 +1,127 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"yt-downloader-go/config"
+	"yt-downloader-go/models"
+	"yt-downloader-go/utils"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// hlsGroup ensures HLS output is generated once per job even if several viewers request it at the same time
+var hlsGroup singleflight.Group
+
+// Names of the files written by ffmpeg into the HLS directory
+const (
+	HLSMasterPlaylist = "master.m3u8"
+	hlsMediaPlaylist  = "index.m3u8"
+	hlsInitSegment    = "init.mp4"
+)
+
+// GetHLSDir returns the HLS output directory of a job
+func GetHLSDir(jobID string) string {
+	return filepath.Join(utils.GetJobDir(jobID), config.HLSDirName)
+}
+
+// HLSReady reports whether HLS output has been fully generated for a job
+func HLSReady(jobID string) bool {
+	_, err := os.Stat(filepath.Join(GetHLSDir(jobID), HLSMasterPlaylist))
+	return err == nil
+}
+
+// GenerateHLS starts HLS generation for a job, or joins the run already in progress
+// The channel receives the result once the playlists and segments are in place
+func GenerateHLS(meta *models.Meta) <-chan singleflight.Result {
+	return hlsGroup.DoChan(meta.ID, func() (interface{}, error) {
+		if HLSReady(meta.ID) {
+			return nil, nil
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), config.JobTimeout)
+		defer cancel()
+		ctx = WithFFmpegJob(ctx, meta.ID)
+
+		// Write into a temp dir and rename, so a partial run is never served
+		hlsDir := GetHLSDir(meta.ID)
+		tmpDir := hlsDir + ".tmp"
+		os.RemoveAll(tmpDir)
+		if err := os.MkdirAll(tmpDir, 0755); err != nil {
+			return nil, fmt.Errorf("failed to create hls dir: %w", err)
+		}
+
+		if err := FFmpeg.Run(ctx, hlsArgs(meta, tmpDir)); err != nil {
+			os.RemoveAll(tmpDir)
+			return nil, err
+		}
+
+		if err := os.Rename(tmpDir, hlsDir); err != nil {
+			os.RemoveAll(tmpDir)
+			return nil, fmt.Errorf("failed to finalize hls dir: %w", err)
+		}
+		utils.AddStorageUsage(utils.UsageHLS, utils.DirSize(hlsDir))
+		return nil, nil
+	})
+}
+
+// hlsArgs builds the ffmpeg command for fMP4 HLS output
+// Video is always copied; audio is copied when it is already AAC
+func hlsArgs(meta *models.Meta, outDir string) []string {
+	jobDir := utils.GetJobDir(meta.ID)
+
+	args := []string{"-y"}
+	var audioExt string
+	switch {
+	case meta.Output != "" && !meta.StreamOnly:
+		args = append(args, "-i", filepath.Join(jobDir, meta.Output))
+		audioExt = meta.Format
+	case meta.OutputType == "video" && meta.Files.Audio == nil:
+		args = append(args, "-i", filepath.Join(jobDir, meta.Files.Video.Name))
+	case meta.OutputType == "video":
+		args = append(args,
+			"-i", filepath.Join(jobDir, meta.Files.Video.Name),
+			"-i", filepath.Join(jobDir, meta.Files.Audio.Name),
+			"-map", "0:v:0", "-map", "1:a:0",
+		)
+		audioExt = strings.TrimPrefix(filepath.Ext(meta.Files.Audio.Name), ".")
+	default:
+		args = append(args, "-i", filepath.Join(jobDir, meta.Files.Audio.Name))
+		audioExt = strings.TrimPrefix(filepath.Ext(meta.Files.Audio.Name), ".")
+	}
+
+	if meta.OutputType == "video" {
+		args = append(args, "-c:v", "copy")
+	} else {
+		args = append(args, "-vn")
+	}
+
+	switch {
+	case meta.Files.Audio == nil:
+		// Muted video (audio.mute)
+		args = append(args, "-an")
+	case audioExt == "m4a" || audioExt == "m4b" || audioExt == "mp4":
+		args = append(args, "-c:a", "copy")
+	default:
+		bitrate := meta.Bitrate
+		if bitrate == "" {
+			bitrate = DefaultBitrate("m4a", "")
+		}
+		args = append(args, "-c:a", "aac")
+		args = append(args, AudioRateArgs("aac", bitrate)...)
+	}
+
+	return append(args,
+		"-f", "hls",
+		"-hls_time", fmt.Sprintf("%d", config.HLSSegmentDuration),
+		"-hls_playlist_type", "vod",
+		"-hls_segment_type", "fmp4",
+		"-hls_fmp4_init_filename", hlsInitSegment,
+		"-hls_segment_filename", filepath.Join(outDir, "seg_%05d.m4s"),
+		"-master_pl_name", HLSMasterPlaylist,
+		filepath.Join(outDir, hlsMediaPlaylist),
+	)
+}
@@ -0,0 +1,90 @@
+package services
+
+import (
+	"encoding/json"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"yt-downloader-go/config"
+)
+
+// useRealFFmpeg runs the test's ffmpeg and ffprobe invocations with the binaries on PATH. The
+// tests checking real output are skipped where they aren't installed.
+func useRealFFmpeg(t *testing.T) {
+	t.Helper()
+	ffmpegPath, err := exec.LookPath("ffmpeg")
+	if err != nil {
+		t.Skip("needs ffmpeg on PATH")
+	}
+	ffprobePath, err := exec.LookPath("ffprobe")
+	if err != nil {
+		t.Skip("needs ffprobe on PATH")
+	}
+
+	prevRunner, prevFFmpeg, prevFFprobe := FFmpeg, config.FFmpegPath, config.FFprobePath
+	FFmpeg = NewExecRunner(ffmpegPath)
+	config.FFmpegPath, config.FFprobePath = ffmpegPath, ffprobePath
+	t.Cleanup(func() { FFmpeg, config.FFmpegPath, config.FFprobePath = prevRunner, prevFFmpeg, prevFFprobe })
+}
+
+// generate writes dir/name with ffmpeg; args are its inputs (lavfi sources) and encoding
+func generate(t *testing.T, dir string, name string, args ...string) {
+	t.Helper()
+	args = append(append([]string{"-y", "-v", "error"}, args...), filepath.Join(dir, name))
+	if out, err := exec.Command(config.FFmpegPath, args...).CombinedOutput(); err != nil {
+		t.Fatalf("generate %s: %v\n%s", name, err, out)
+	}
+}
+
+// probedMedia is what ffprobe reports of a file, as far as the tests check it
+type probedMedia struct {
+	Format struct {
+		Duration string `json:"duration"`
+	} `json:"format"`
+	Streams []struct {
+		CodecType string `json:"codec_type"`
+	} `json:"streams"`
+	Chapters []struct {
+		StartTime string `json:"start_time"`
+		EndTime   string `json:"end_time"`
+		Tags      struct {
+			Title string `json:"title"`
+		} `json:"tags"`
+	} `json:"chapters"`
+}
+
+// probe runs ffprobe on path
+func probe(t *testing.T, path string) probedMedia {
+	t.Helper()
+	out, err := exec.Command(config.FFprobePath, "-v", "error", "-show_format", "-show_streams", "-show_chapters", "-of", "json", path).Output()
+	if err != nil {
+		t.Fatalf("ffprobe %s: %v", path, err)
+	}
+	var media probedMedia
+	if err := json.Unmarshal(out, &media); err != nil {
+		t.Fatalf("ffprobe %s: %v", path, err)
+	}
+	return media
+}
+
+// duration returns the container duration in seconds
+func (m probedMedia) duration(t *testing.T) float64 {
+	t.Helper()
+	seconds, err := strconv.ParseFloat(m.Format.Duration, 64)
+	if err != nil {
+		t.Fatalf("duration %q: %v", m.Format.Duration, err)
+	}
+	return seconds
+}
+
+// streams counts the streams of a codec type ("video", "audio")
+func (m probedMedia) streams(codecType string) int {
+	count := 0
+	for _, stream := range m.Streams {
+		if stream.CodecType == codecType {
+			count++
+		}
+	}
+	return count
+}
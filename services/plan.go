@@ -0,0 +1,96 @@
+package services
+
+import (
+	"path/filepath"
+	"yt-downloader-go/config"
+	"yt-downloader-go/models"
+)
+
+// PlanJob decides how a job is processed: download strategy, stream copy or re-encode, trim
+// mode and whether the output is pre-rendered or only streamed. The download response reports
+// the plan and the worker follows it, so both go through this function.
+// meta.Selection must be set (the copy-vs-encode decision reads the source audio codec).
+// Strategy: minimize CPU usage
+// - Heavy tasks (transcode): pre-rendered up to 15 minutes
+// - Light tasks (remux/copy): pre-rendered up to 4 hours
+func PlanJob(meta *models.Meta) *models.JobPlan {
+	plan := &models.JobPlan{
+		Download:          models.PlanDownloadSingle,
+		Merge:             models.PlanCopy,
+		Trim:              models.PlanTrimNone,
+		Delivery:          models.PlanDeliveryFile,
+		EstimatedCPUClass: models.CPUClassLight,
+		Thresholds: models.PlanThresholds{
+			ParallelDownloadBytes: config.ChunkSize,
+			MaxFileDuration:       config.MaxMergeDurationRemux,
+		},
+	}
+
+	for _, file := range []*models.FileInfo{meta.Files.Video, meta.Files.Audio} {
+		if file != nil && IsParallelDownload(file.Size) {
+			plan.Download = models.PlanDownloadParallel
+		}
+	}
+
+	if reencodesSources(meta) {
+		plan.Merge = models.PlanTranscode
+	}
+
+	// Fades force accurate mode; silence auto-trim cuts precisely once detected
+	switch {
+	case meta.AutoTrimSilence || (meta.Trim != nil && (meta.Trim.Accurate || meta.Trim.Fade != nil)):
+		plan.Trim = models.PlanTrimAccurate
+	case meta.Trim != nil:
+		plan.Trim = models.PlanTrimFast
+	}
+
+	if needsTranscode(meta) {
+		plan.EstimatedCPUClass = models.CPUClassHeavy
+		plan.Thresholds.MaxFileDuration = config.MaxMergeDurationTranscode
+	}
+
+	// Static video can't be streamed; its length is capped at creation
+	if !meta.StaticVideo && meta.Duration > plan.Thresholds.MaxFileDuration {
+		plan.Delivery = models.PlanDeliveryStream
+	}
+	return plan
+}
+
+// reencodesSources reports whether the merge/convert step re-encodes instead of copying streams
+func reencodesSources(meta *models.Meta) bool {
+	if meta.StaticVideo {
+		return true
+	}
+	if meta.OutputType == "video" {
+		return MergeTranscodesVideo(meta)
+	}
+	if HasAudioEffects(meta) {
+		return true
+	}
+	return meta.Files.Audio != nil && NeedsAudioTranscode(filepath.Ext(meta.Files.Audio.Name), SourceAudioCodec(meta), meta.Format, meta.Bitrate, meta.AudioCodec)
+}
+
+// needsTranscode checks if the job requires transcoding (heavy CPU)
+// Returns true for:
+// - Audio format conversion (e.g., webm→mp3), tempo or pitch changes
+// - Video re-encoded for the device, for its rotation (forceRotate) or over a still image
+// - Video with accurate trim (requires re-encoding)
+// - Fades (require re-encoding)
+// - Silence auto-trim
+func needsTranscode(meta *models.Meta) bool {
+	if reencodesSources(meta) {
+		return true
+	}
+
+	// Video with accurate trim needs re-encoding
+	if meta.OutputType == "video" && meta.Trim != nil && meta.Trim.Accurate {
+		return true
+	}
+
+	if meta.Trim != nil && meta.Trim.Fade != nil {
+		return true
+	}
+
+	// Silence auto-trim cuts audio precisely
+	return meta.AutoTrimSilence
+}
@@ -0,0 +1,225 @@
+package services
+
+import (
+	"testing"
+	"yt-downloader-go/config"
+	"yt-downloader-go/models"
+)
+
+// audioJob is a small opus source converted to format
+func audioJob(format string) *models.Meta {
+	return &models.Meta{
+		OutputType: "audio",
+		Format:     format,
+		Duration:   240,
+		Files:      models.FilesInfo{Audio: &models.FileInfo{Name: "audio.webm", Size: 4_000_000}},
+		Selection:  &models.StreamSelection{Audio: &models.SelectedStream{Codec: "opus"}},
+	}
+}
+
+// videoJob is a small video+audio merge into mp4
+func videoJob() *models.Meta {
+	return &models.Meta{
+		OutputType: "video",
+		Format:     "mp4",
+		Duration:   240,
+		Files: models.FilesInfo{
+			Video: &models.FileInfo{Name: "video.mp4", Size: 8_000_000},
+			Audio: &models.FileInfo{Name: "audio.m4a", Size: 4_000_000},
+		},
+		Selection: &models.StreamSelection{
+			Video: &models.SelectedStream{Codec: "avc1"},
+			Audio: &models.SelectedStream{Codec: "mp4a"},
+		},
+	}
+}
+
+func TestPlanJob(t *testing.T) {
+	light := models.PlanThresholds{ParallelDownloadBytes: config.ChunkSize, MaxFileDuration: config.MaxMergeDurationRemux}
+	heavy := models.PlanThresholds{ParallelDownloadBytes: config.ChunkSize, MaxFileDuration: config.MaxMergeDurationTranscode}
+
+	tests := []struct {
+		name string
+		meta func() *models.Meta
+		want models.JobPlan
+	}{
+		{
+			name: "audio copy",
+			meta: func() *models.Meta { return audioJob("opus") },
+			want: models.JobPlan{Download: "single", Merge: "copy", Trim: "none", Delivery: "file", EstimatedCPUClass: "light", Thresholds: light},
+		},
+		{
+			name: "audio conversion",
+			meta: func() *models.Meta { return audioJob("mp3") },
+			want: models.JobPlan{Download: "single", Merge: "transcode", Trim: "none", Delivery: "file", EstimatedCPUClass: "heavy", Thresholds: heavy},
+		},
+		{
+			name: "vbr forces an encode",
+			meta: func() *models.Meta {
+				meta := audioJob("opus")
+				meta.Bitrate = "V2"
+				return meta
+			},
+			want: models.JobPlan{Download: "single", Merge: "transcode", Trim: "none", Delivery: "file", EstimatedCPUClass: "heavy", Thresholds: heavy},
+		},
+		{
+			name: "tempo change",
+			meta: func() *models.Meta {
+				meta := audioJob("opus")
+				meta.Tempo = 1.25
+				return meta
+			},
+			want: models.JobPlan{Download: "single", Merge: "transcode", Trim: "none", Delivery: "file", EstimatedCPUClass: "heavy", Thresholds: heavy},
+		},
+		{
+			name: "large audio source",
+			meta: func() *models.Meta {
+				meta := audioJob("opus")
+				meta.Files.Audio.Size = config.ChunkSize + 1
+				return meta
+			},
+			want: models.JobPlan{Download: "parallel", Merge: "copy", Trim: "none", Delivery: "file", EstimatedCPUClass: "light", Thresholds: light},
+		},
+		{
+			name: "source at the chunk size",
+			meta: func() *models.Meta {
+				meta := audioJob("opus")
+				meta.Files.Audio.Size = config.ChunkSize
+				return meta
+			},
+			want: models.JobPlan{Download: "single", Merge: "copy", Trim: "none", Delivery: "file", EstimatedCPUClass: "light", Thresholds: light},
+		},
+		{
+			name: "video remux",
+			meta: videoJob,
+			want: models.JobPlan{Download: "single", Merge: "copy", Trim: "none", Delivery: "file", EstimatedCPUClass: "light", Thresholds: light},
+		},
+		{
+			name: "large video source",
+			meta: func() *models.Meta {
+				meta := videoJob()
+				meta.Files.Video.Size = 5 * config.ChunkSize
+				return meta
+			},
+			want: models.JobPlan{Download: "parallel", Merge: "copy", Trim: "none", Delivery: "file", EstimatedCPUClass: "light", Thresholds: light},
+		},
+		{
+			name: "video transcode",
+			meta: func() *models.Meta {
+				meta := videoJob()
+				meta.VideoTranscode = true
+				return meta
+			},
+			want: models.JobPlan{Download: "single", Merge: "transcode", Trim: "none", Delivery: "file", EstimatedCPUClass: "heavy", Thresholds: heavy},
+		},
+		{
+			name: "forced rotation",
+			meta: func() *models.Meta {
+				meta := videoJob()
+				meta.Rotation = &models.VideoRotation{Degrees: 90, Action: models.RotationForced}
+				return meta
+			},
+			want: models.JobPlan{Download: "single", Merge: "transcode", Trim: "none", Delivery: "file", EstimatedCPUClass: "heavy", Thresholds: heavy},
+		},
+		{
+			name: "video audio effects are ignored",
+			meta: func() *models.Meta {
+				meta := videoJob()
+				meta.Tempo = 1.5
+				return meta
+			},
+			want: models.JobPlan{Download: "single", Merge: "copy", Trim: "none", Delivery: "file", EstimatedCPUClass: "light", Thresholds: light},
+		},
+		{
+			name: "fast trim",
+			meta: func() *models.Meta {
+				meta := videoJob()
+				meta.Trim = &models.TrimConfig{Start: 10, End: 60}
+				return meta
+			},
+			want: models.JobPlan{Download: "single", Merge: "copy", Trim: "fast", Delivery: "file", EstimatedCPUClass: "light", Thresholds: light},
+		},
+		{
+			name: "accurate video trim",
+			meta: func() *models.Meta {
+				meta := videoJob()
+				meta.Trim = &models.TrimConfig{Start: 10, End: 60, Accurate: true}
+				return meta
+			},
+			want: models.JobPlan{Download: "single", Merge: "copy", Trim: "accurate", Delivery: "file", EstimatedCPUClass: "heavy", Thresholds: heavy},
+		},
+		{
+			name: "accurate audio trim",
+			meta: func() *models.Meta {
+				meta := audioJob("opus")
+				meta.Trim = &models.TrimConfig{Start: 10, End: 60, Accurate: true}
+				return meta
+			},
+			want: models.JobPlan{Download: "single", Merge: "copy", Trim: "accurate", Delivery: "file", EstimatedCPUClass: "light", Thresholds: light},
+		},
+		{
+			name: "fade forces accurate",
+			meta: func() *models.Meta {
+				meta := audioJob("opus")
+				meta.Trim = &models.TrimConfig{Start: 10, End: 60, Fade: &models.FadeConfig{In: 1}}
+				return meta
+			},
+			want: models.JobPlan{Download: "single", Merge: "copy", Trim: "accurate", Delivery: "file", EstimatedCPUClass: "heavy", Thresholds: heavy},
+		},
+		{
+			name: "silence auto-trim",
+			meta: func() *models.Meta {
+				meta := audioJob("opus")
+				meta.AutoTrimSilence = true
+				return meta
+			},
+			want: models.JobPlan{Download: "single", Merge: "copy", Trim: "accurate", Delivery: "file", EstimatedCPUClass: "heavy", Thresholds: heavy},
+		},
+		{
+			name: "long remux streams",
+			meta: func() *models.Meta {
+				meta := videoJob()
+				meta.Duration = config.MaxMergeDurationRemux + 1
+				return meta
+			},
+			want: models.JobPlan{Download: "single", Merge: "copy", Trim: "none", Delivery: "stream", EstimatedCPUClass: "light", Thresholds: light},
+		},
+		{
+			name: "remux at the limit is pre-rendered",
+			meta: func() *models.Meta {
+				meta := videoJob()
+				meta.Duration = config.MaxMergeDurationRemux
+				return meta
+			},
+			want: models.JobPlan{Download: "single", Merge: "copy", Trim: "none", Delivery: "file", EstimatedCPUClass: "light", Thresholds: light},
+		},
+		{
+			name: "long transcode streams",
+			meta: func() *models.Meta {
+				meta := audioJob("mp3")
+				meta.Duration = config.MaxMergeDurationTranscode + 1
+				return meta
+			},
+			want: models.JobPlan{Download: "single", Merge: "transcode", Trim: "none", Delivery: "stream", EstimatedCPUClass: "heavy", Thresholds: heavy},
+		},
+		{
+			name: "static video never streams",
+			meta: func() *models.Meta {
+				meta := audioJob("opus")
+				meta.Format = "mp4"
+				meta.StaticVideo = true
+				meta.Duration = config.MaxMergeDurationRemux + 1
+				return meta
+			},
+			want: models.JobPlan{Download: "single", Merge: "transcode", Trim: "none", Delivery: "file", EstimatedCPUClass: "heavy", Thresholds: heavy},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := PlanJob(tt.meta()); *got != tt.want {
+				t.Errorf("PlanJob = %+v, want %+v", *got, tt.want)
+			}
+		})
+	}
+}
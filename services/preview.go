@@ -0,0 +1,116 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"yt-downloader-go/config"
+	"yt-downloader-go/models"
+)
+
+// previewFallbackBitrate is assumed for streams that report neither bitrate nor size (bits/s)
+const previewFallbackBitrate = 160_000
+
+// AudioTracks lists the audio tracks of a video that have a downloadable stream, original first
+func AudioTracks(data *models.ExtractResponse) []models.AudioTrack {
+	tracks := []models.AudioTrack{}
+	seen := map[string]bool{}
+	for i := range data.AudioStreams {
+		stream := &data.AudioStreams[i]
+		if isProtectedStream(stream) || seen[stream.AudioTrackID] {
+			continue
+		}
+		seen[stream.AudioTrackID] = true
+
+		track := models.AudioTrack{ID: stream.AudioTrackID, Original: stream.IsOriginal}
+		if track.Original {
+			tracks = append([]models.AudioTrack{track}, tracks...)
+		} else {
+			tracks = append(tracks, track)
+		}
+	}
+	return tracks
+}
+
+// PreviewStream returns the smallest downloadable stream of an audio track (the original
+// track when trackID is empty); nil when the video has no such track
+func PreviewStream(data *models.ExtractResponse, trackID string) *models.Stream {
+	var best *models.Stream
+	for i := range data.AudioStreams {
+		stream := &data.AudioStreams[i]
+		if isProtectedStream(stream) {
+			continue
+		}
+		if trackID != "" && stream.AudioTrackID != trackID {
+			continue
+		}
+		if trackID == "" && stream.AudioTrackID != "" && !stream.IsOriginal {
+			continue
+		}
+		if best == nil || stream.Bitrate < best.Bitrate {
+			best = stream
+		}
+	}
+	return best
+}
+
+// RenderAudioPreview downloads about the first seconds of stream and encodes them as a small
+// opus clip (PreviewBitrate). The temp dir under PreviewDir is removed before returning.
+func RenderAudioPreview(ctx context.Context, stream *models.Stream, duration float64, seconds int) ([]byte, error) {
+	if err := os.MkdirAll(config.PreviewDir, 0755); err != nil {
+		return nil, err
+	}
+	dir, err := os.MkdirTemp(config.PreviewDir, "preview-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	sourcePath := filepath.Join(dir, "source."+GetExtension(stream))
+	resp, err := fetchRange(ctx, stream.URL, 0, previewSourceBytes(stream, duration, seconds)-1)
+	if err != nil {
+		return nil, err
+	}
+	err = streamToFile(io.LimitReader(resp.Body, config.PreviewMaxSourceBytes), sourcePath)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	// The source is cut off mid-stream; -t stops before ffmpeg reaches the cut
+	outputPath := filepath.Join(dir, "preview.opus")
+	args := []string{
+		"-y",
+		"-i", sourcePath,
+		"-t", strconv.Itoa(seconds),
+		"-vn",
+	}
+	args = append(args, AudioEncodeArgs("opus", "", config.PreviewBitrate)...)
+	args = append(args, "-f", FFmpegMuxer("opus"), outputPath)
+	if err := FFmpeg.Run(ctx, args); err != nil {
+		return nil, fmt.Errorf("encode preview: %w", err)
+	}
+
+	return os.ReadFile(outputPath)
+}
+
+// previewSourceBytes estimates the bytes holding the first seconds of stream, with headroom
+// for the container header and bitrate peaks
+func previewSourceBytes(stream *models.Stream, duration float64, seconds int) int64 {
+	bitrate := stream.Bitrate
+	if bitrate <= 0 && stream.ContentLength > 0 && duration > 0 {
+		bitrate = float64(stream.ContentLength) * 8 / duration
+	}
+	if bitrate <= 0 {
+		bitrate = previewFallbackBitrate
+	}
+
+	size := int64(bitrate/8*float64(seconds)*1.5) + 64*1024
+	if stream.ContentLength > 0 {
+		size = min(size, stream.ContentLength)
+	}
+	return min(size, config.PreviewMaxSourceBytes)
+}
@@ -0,0 +1,126 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os/exec"
+	"strconv"
+	"yt-downloader-go/config"
+	"yt-downloader-go/models"
+)
+
+// ProbeRotation returns the clockwise display rotation of a video file's first video
+// stream (0, 90, 180 or 270), from its display matrix or, in older files, its rotate tag
+func ProbeRotation(path string) (int, error) {
+	out, err := exec.Command(config.FFprobePath,
+		"-v", "error",
+		"-select_streams", "v:0",
+		"-show_entries", "stream_tags=rotate:stream_side_data=rotation",
+		"-of", "json",
+		path,
+	).Output()
+	if err != nil {
+		return 0, fmt.Errorf("ffprobe error: %w", err)
+	}
+
+	var probe struct {
+		Streams []struct {
+			Tags struct {
+				Rotate string `json:"rotate"`
+			} `json:"tags"`
+			SideData []struct {
+				Rotation *float64 `json:"rotation"`
+			} `json:"side_data_list"`
+		} `json:"streams"`
+	}
+	if err := json.Unmarshal(out, &probe); err != nil {
+		return 0, fmt.Errorf("ffprobe output: %w", err)
+	}
+	if len(probe.Streams) == 0 {
+		return 0, fmt.Errorf("no video stream")
+	}
+
+	stream := probe.Streams[0]
+	for _, side := range stream.SideData {
+		// The display matrix rotation is counterclockwise
+		if side.Rotation != nil {
+			return normalizeRotation(-*side.Rotation), nil
+		}
+	}
+	if stream.Tags.Rotate != "" {
+		degrees, err := strconv.ParseFloat(stream.Tags.Rotate, 64)
+		if err != nil {
+			return 0, fmt.Errorf("rotate tag %q: %w", stream.Tags.Rotate, err)
+		}
+		return normalizeRotation(degrees), nil
+	}
+	return 0, nil
+}
+
+// normalizeRotation rounds clockwise degrees to a quarter turn in [0, 360)
+func normalizeRotation(degrees float64) int {
+	quarter := int(math.Round(degrees/90)) % 4
+	return (quarter + 4) % 4 * 90
+}
+
+// DecideRotation returns how the output of meta handles a source rotated by degrees; nil for
+// outputs without video. A forced re-encode that would push the job past the transcode
+// duration limit (stream delivery, which copies) preserves the rotation instead.
+func DecideRotation(meta *models.Meta, degrees int) *models.VideoRotation {
+	if meta.OutputType != "video" || meta.StaticVideo {
+		return nil
+	}
+
+	rotation := &models.VideoRotation{Degrees: degrees}
+	switch {
+	case degrees == 0:
+		rotation.Action = models.RotationNone
+	case meta.NoAutorotate:
+		rotation.Action = models.RotationIgnored
+	case reencodesVideo(meta):
+		rotation.Action = models.RotationApplied
+	case meta.ForceRotate:
+		rotation.Action = models.RotationForced
+	default:
+		rotation.Action = models.RotationPreserved
+	}
+
+	if rotation.Action == models.RotationForced {
+		forced := *meta
+		forced.Rotation = rotation
+		if PlanJob(&forced).Delivery == models.PlanDeliveryStream {
+			rotation.Action = models.RotationPreserved
+		}
+	}
+	return rotation
+}
+
+// reencodesVideo reports whether the video is re-encoded regardless of rotation: for the
+// device (allowTranscode) or by an accurate trim
+func reencodesVideo(meta *models.Meta) bool {
+	return meta.VideoTranscode || (meta.Trim != nil && (meta.Trim.Accurate || meta.Trim.Fade != nil))
+}
+
+// MergeTranscodesVideo reports whether the merge re-encodes the video: allowTranscode or forceRotate
+func MergeTranscodesVideo(meta *models.Meta) bool {
+	return meta.VideoTranscode || (meta.Rotation != nil && meta.Rotation.Action == models.RotationForced)
+}
+
+// RotationInputArgs returns the options placed before a video input: re-encodes don't apply
+// the rotation with output.autorotate false
+func RotationInputArgs(rotation *models.VideoRotation) []string {
+	if rotation != nil && rotation.Action == models.RotationIgnored {
+		return []string{"-noautorotate"}
+	}
+	return nil
+}
+
+// RotationCopyArgs returns the output options of a stream-copied video: the rotation is
+// written explicitly, as some muxers drop the source display matrix on a copy
+func RotationCopyArgs(rotation *models.VideoRotation) []string {
+	if rotation == nil || rotation.Degrees == 0 || rotation.Action == models.RotationIgnored {
+		return nil
+	}
+	return []string{"-metadata:s:v:0", fmt.Sprintf("rotate=%d", rotation.Degrees)}
+}
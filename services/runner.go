@@ -0,0 +1,153 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+	"time"
+	"yt-downloader-go/config"
+	"yt-downloader-go/models"
+	"yt-downloader-go/utils"
+)
+
+// FFmpegRunner executes ffmpeg with the given arguments
+type FFmpegRunner interface {
+	// Run executes ffmpeg to completion
+	Run(ctx context.Context, args []string) error
+	// StartPipe starts ffmpeg and returns its stdout; wait must be called after reading
+	StartPipe(ctx context.Context, args []string) (stdout io.ReadCloser, wait func() error, err error)
+}
+
+// FFmpeg is the runner used by every ffmpeg call site (swap it to mock or wrap ffmpeg)
+var FFmpeg = NewExecRunner(config.FFmpegPath)
+
+// NewExecRunner returns a runner executing the ffmpeg binary at path, recording the
+// arguments of job runs in meta.json
+func NewExecRunner(path string) FFmpegRunner {
+	return recordingRunner{execRunner{path: path}}
+}
+
+var (
+	serverLog = utils.Logger(utils.LogServer)
+	streamLog = utils.Logger(utils.LogStream)
+)
+
+// FFmpegVersion is the first line of "ffmpeg -version", set by CaptureFFmpegVersion at startup
+var FFmpegVersion string
+
+// CaptureFFmpegVersion records the version of the ffmpeg binary for job metadata and logs
+func CaptureFFmpegVersion() {
+	out, err := exec.Command(config.FFmpegPath, "-version").Output()
+	if err != nil {
+		serverLog.Warn("ffmpeg version unavailable", "err", err)
+		return
+	}
+	line, _, _ := bytes.Cut(out, []byte("\n"))
+	FFmpegVersion = strings.TrimSpace(string(line))
+	serverLog.Info("ffmpeg", "version", FFmpegVersion)
+}
+
+type ffmpegJobKey struct{}
+
+// WithFFmpegJob returns a context whose ffmpeg runs are attributed to jobID
+// Run records the arguments in the job's meta.json; StartPipe (streaming sessions) only logs them.
+func WithFFmpegJob(ctx context.Context, jobID string) context.Context {
+	return context.WithValue(ctx, ffmpegJobKey{}, jobID)
+}
+
+// ffmpegJobID returns the job set by WithFFmpegJob ("" when none)
+func ffmpegJobID(ctx context.Context) string {
+	jobID, _ := ctx.Value(ffmpegJobKey{}).(string)
+	return jobID
+}
+
+// recordingRunner records the arguments of every invocation before delegating to the inner runner
+type recordingRunner struct {
+	inner FFmpegRunner
+}
+
+func (r recordingRunner) Run(ctx context.Context, args []string) error {
+	if jobID := ffmpegJobID(ctx); jobID != "" {
+		command := models.FFmpegCommand{Args: args, StartedAt: time.Now().UnixMilli()}
+		if err := utils.AppendMetaFFmpegCommand(jobID, FFmpegVersion, command); err != nil {
+			jobLog.Warn("failed to record ffmpeg command", "job", jobID, "err", err)
+		}
+	}
+	return r.inner.Run(ctx, args)
+}
+
+// StartPipe logs instead of recording: a job can be streamed any number of times
+func (r recordingRunner) StartPipe(ctx context.Context, args []string) (io.ReadCloser, func() error, error) {
+	if jobID := ffmpegJobID(ctx); jobID != "" {
+		streamLog.Debug("stream ffmpeg command", "job", jobID, "args", strings.Join(args, " "))
+	}
+	return r.inner.StartPipe(ctx, args)
+}
+
+// execRunner runs the ffmpeg binary at path, logging its output to the process stdout/stderr
+// Every process is registered in SessionDir while it runs, for the orphan reaper.
+type execRunner struct {
+	path string
+}
+
+func (r execRunner) Run(ctx context.Context, args []string) error {
+	var tail stderrTail
+	cmd := exec.CommandContext(ctx, r.path, args...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = io.MultiWriter(os.Stderr, &tail)
+
+	if err := cmd.Start(); err != nil {
+		return &FFmpegError{Err: err}
+	}
+	unregister := utils.RegisterFFmpegSession(ffmpegJobID(ctx), cmd.Process.Pid)
+	err := cmd.Wait()
+	unregister()
+
+	if err != nil {
+		// ffmpeg only reports a full disk on stderr; surface it as ENOSPC
+		if bytes.Contains(tail.buf, []byte("No space left on device")) {
+			err = fmt.Errorf("%w (%v)", syscall.ENOSPC, err)
+		}
+		return &FFmpegError{Err: err}
+	}
+	return nil
+}
+
+// stderrTailSize bounds the ffmpeg output kept for error classification
+const stderrTailSize = 4096
+
+// stderrTail keeps the last stderrTailSize bytes written to it
+type stderrTail struct {
+	buf []byte
+}
+
+func (t *stderrTail) Write(p []byte) (int, error) {
+	t.buf = append(t.buf, p...)
+	if over := len(t.buf) - stderrTailSize; over > 0 {
+		t.buf = t.buf[over:]
+	}
+	return len(p), nil
+}
+
+func (r execRunner) StartPipe(ctx context.Context, args []string) (io.ReadCloser, func() error, error) {
+	cmd := exec.CommandContext(ctx, r.path, args...)
+	cmd.Stderr = os.Stderr
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, nil, &FFmpegError{Err: err}
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, nil, &FFmpegError{Err: err}
+	}
+	unregister := utils.RegisterFFmpegSession(ffmpegJobID(ctx), cmd.Process.Pid)
+	return stdout, func() error {
+		defer unregister()
+		return cmd.Wait()
+	}, nil
+}
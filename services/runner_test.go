@@ -0,0 +1,303 @@
+package services
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"yt-downloader-go/models"
+)
+
+// mockRunner records the arguments of every ffmpeg invocation instead of running ffmpeg.
+// Run creates its output (the last argument) so the .part rename succeeds.
+type mockRunner struct {
+	calls [][]string
+}
+
+func (m *mockRunner) Run(ctx context.Context, args []string) error {
+	m.calls = append(m.calls, args)
+	return os.WriteFile(args[len(args)-1], nil, 0644)
+}
+
+func (m *mockRunner) StartPipe(ctx context.Context, args []string) (io.ReadCloser, func() error, error) {
+	m.calls = append(m.calls, args)
+	return io.NopCloser(strings.NewReader("")), func() error { return nil }, nil
+}
+
+// useMockRunner swaps FFmpeg for a mockRunner for the duration of the test
+func useMockRunner(t *testing.T) *mockRunner {
+	t.Helper()
+	mock := &mockRunner{}
+	prev := FFmpeg
+	FFmpeg = mock
+	t.Cleanup(func() { FFmpeg = prev })
+	return mock
+}
+
+// assertSingleCall checks that exactly one ffmpeg invocation was made, with want as its arguments
+func assertSingleCall(t *testing.T, mock *mockRunner, want []string) {
+	t.Helper()
+	if len(mock.calls) != 1 {
+		t.Fatalf("got %d ffmpeg calls, want 1", len(mock.calls))
+	}
+	if got := mock.calls[0]; !slices.Equal(got, want) {
+		t.Errorf("args mismatch\n got: %q\nwant: %q", got, want)
+	}
+}
+
+func TestFFmpegMergeArgs(t *testing.T) {
+	tests := []struct {
+		name      string
+		format    string
+		audioFile string
+		transcode bool
+		rotation  *models.VideoRotation
+		want      func(dir string) []string
+	}{
+		{
+			name: "mp4 copy", format: "mp4", audioFile: "audio.m4a",
+			want: func(dir string) []string {
+				return []string{"-y", "-i", dir + "/video.mp4", "-i", dir + "/audio.m4a",
+					"-c:v", "copy", "-c:a", "copy", "-f", "mp4", dir + "/output.mp4.part"}
+			},
+		},
+		{
+			name: "webm muted", format: "webm",
+			want: func(dir string) []string {
+				return []string{"-y", "-i", dir + "/video.mp4", "-c:v", "copy", "-an", "-f", "webm", dir + "/output.webm.part"}
+			},
+		},
+		{
+			name: "mp4 copy keeps rotation", format: "mp4", audioFile: "audio.m4a",
+			rotation: &models.VideoRotation{Degrees: 90, Action: models.RotationPreserved},
+			want: func(dir string) []string {
+				return []string{"-y", "-i", dir + "/video.mp4", "-i", dir + "/audio.m4a",
+					"-c:v", "copy", "-metadata:s:v:0", "rotate=90", "-c:a", "copy", "-f", "mp4", dir + "/output.mp4.part"}
+			},
+		},
+		{
+			name: "mkv transcode without autorotate", format: "mkv", audioFile: "audio.webm", transcode: true,
+			rotation: &models.VideoRotation{Degrees: 90, Action: models.RotationIgnored},
+			want: func(dir string) []string {
+				return []string{"-y", "-noautorotate", "-i", dir + "/video.mp4", "-i", dir + "/audio.webm",
+					"-threads", "0", "-c:v", "libx264", "-pix_fmt", "yuv420p", "-c:a", "copy", "-f", "matroska", dir + "/output.mkv.part"}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := useMockRunner(t)
+			dir := t.TempDir()
+
+			output, err := FFmpegMerge(context.Background(), dir, tt.format, "video.mp4", tt.audioFile, tt.transcode, tt.rotation)
+			if err != nil {
+				t.Fatalf("FFmpegMerge: %v", err)
+			}
+			if output != "output."+tt.format {
+				t.Errorf("output = %q, want %q", output, "output."+tt.format)
+			}
+			assertSingleCall(t, mock, tt.want(dir))
+		})
+	}
+}
+
+func TestFFmpegConvertAudioArgs(t *testing.T) {
+	tests := []struct {
+		name        string
+		format      string
+		bitrate     string
+		codec       string
+		sourceCodec string
+		audioFile   string
+		filter      string
+		channels    int
+		want        func(dir string) []string
+	}{
+		{
+			name: "opus to mp3", format: "mp3", bitrate: "192k", sourceCodec: "opus", audioFile: "audio.webm",
+			want: func(dir string) []string {
+				return []string{"-y", "-i", dir + "/audio.webm", "-threads", "0",
+					"-c:a", "libmp3lame", "-b:a", "192k", "-f", "mp3", dir + "/output.mp3.part"}
+			},
+		},
+		{
+			name: "mp3 vbr", format: "mp3", bitrate: "V2", sourceCodec: "opus", audioFile: "audio.webm",
+			want: func(dir string) []string {
+				return []string{"-y", "-i", dir + "/audio.webm", "-threads", "0",
+					"-c:a", "libmp3lame", "-q:a", "2", "-f", "mp3", dir + "/output.mp3.part"}
+			},
+		},
+		{
+			name: "aac copied into m4a", format: "m4a", bitrate: "160k", sourceCodec: "mp4a.40.2", audioFile: "audio.m4a",
+			want: func(dir string) []string {
+				return []string{"-y", "-i", dir + "/audio.m4a", "-c:a", "copy", "-f", "ipod", dir + "/output.m4a.part"}
+			},
+		},
+		{
+			name: "downmix forces encode", format: "opus", bitrate: "128k", sourceCodec: "opus", audioFile: "audio.webm",
+			filter: "pan=stereo|FL<1*FC+0.707*FL|FR<1*FC+0.707*FR", channels: 2,
+			want: func(dir string) []string {
+				return []string{"-y", "-i", dir + "/audio.webm", "-threads", "0",
+					"-af", "pan=stereo|FL<1*FC+0.707*FL|FR<1*FC+0.707*FR", "-ac", "2",
+					"-c:a", "libopus", "-b:a", "128k", "-f", "opus", dir + "/output.opus.part"}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := useMockRunner(t)
+			dir := t.TempDir()
+
+			_, err := FFmpegConvertAudio(context.Background(), dir, tt.format, tt.bitrate, tt.codec, tt.sourceCodec, tt.audioFile, tt.filter, tt.channels)
+			if err != nil {
+				t.Fatalf("FFmpegConvertAudio: %v", err)
+			}
+			assertSingleCall(t, mock, tt.want(dir))
+		})
+	}
+}
+
+func TestFFmpegTrimArgs(t *testing.T) {
+	tests := []struct {
+		name    string
+		format  string
+		trim    models.TrimConfig
+		bitrate string
+		video   bool
+		muted   bool
+		want    func(in string) []string
+	}{
+		{
+			name: "fast video", format: "mp4", video: true,
+			trim: models.TrimConfig{Start: 10, End: 25},
+			want: func(in string) []string {
+				return []string{"-y", "-ss", "10.000", "-i", in, "-t", "15.000", "-c", "copy", "-f", "mp4", in + ".part"}
+			},
+		},
+		{
+			name: "fast audio", format: "mp3",
+			trim: models.TrimConfig{Start: 10, End: 25},
+			want: func(in string) []string {
+				return []string{"-y", "-ss", "10.000", "-i", in, "-t", "15.000", "-c:a", "copy", "-f", "mp3", in + ".part"}
+			},
+		},
+		{
+			name: "accurate video", format: "mp4", bitrate: "128k", video: true,
+			trim: models.TrimConfig{Start: 30, End: 40, Accurate: true},
+			want: func(in string) []string {
+				return []string{"-y", "-ss", "20.000", "-i", in, "-ss", "10.000", "-t", "10.000",
+					"-c:v", "libx264", "-c:a", "aac", "-b:a", "128k", "-f", "mp4", in + ".part"}
+			},
+		},
+		{
+			name: "accurate muted video near the start", format: "webm", video: true, muted: true,
+			trim: models.TrimConfig{Start: 4, End: 9.5, Accurate: true},
+			want: func(in string) []string {
+				return []string{"-y", "-ss", "0.000", "-i", in, "-ss", "4.000", "-t", "5.500",
+					"-c:v", "libvpx-vp9", "-an", "-f", "webm", in + ".part"}
+			},
+		},
+		{
+			name: "accurate audio with fades", format: "mp3", bitrate: "192k",
+			trim: models.TrimConfig{Start: 30, End: 40, Accurate: true, Fade: &models.FadeConfig{In: 2, Out: 3}},
+			want: func(in string) []string {
+				return []string{"-y", "-ss", "20.000", "-i", in, "-ss", "10.000", "-t", "10.000",
+					"-af", "afade=t=in:st=10.000:d=2.000,afade=t=out:st=17.000:d=3.000",
+					"-threads", "0", "-c:a", "libmp3lame", "-b:a", "192k", "-f", "mp3", in + ".part"}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := useMockRunner(t)
+			dir := t.TempDir()
+			in := filepath.Join(dir, "output."+tt.format)
+
+			var err error
+			if tt.video {
+				_, err = FFmpegTrim(context.Background(), dir, tt.format, &tt.trim, tt.bitrate, tt.muted, nil)
+			} else {
+				_, err = FFmpegTrimAudio(context.Background(), dir, tt.format, &tt.trim, tt.bitrate, "")
+			}
+			if err != nil {
+				t.Fatalf("trim: %v", err)
+			}
+			assertSingleCall(t, mock, tt.want(in))
+		})
+	}
+}
+
+func TestFFmpegTrimRejectsEmptyRange(t *testing.T) {
+	mock := useMockRunner(t)
+
+	_, err := FFmpegTrim(context.Background(), t.TempDir(), "mp4", &models.TrimConfig{Start: 20, End: 20}, "", false, nil)
+	if jobErr, ok := err.(*models.JobError); !ok || jobErr.Code != models.JobErrInvalidTrim {
+		t.Fatalf("err = %v, want %s", err, models.JobErrInvalidTrim)
+	}
+	if len(mock.calls) != 0 {
+		t.Errorf("ffmpeg ran for an invalid trim: %q", mock.calls)
+	}
+}
+
+func TestStreamRemuxArgs(t *testing.T) {
+	const video, audio = "/s/video.mp4", "/s/audio.webm"
+
+	tests := []struct {
+		name string
+		args []string
+		want []string
+	}{
+		{
+			name: "mp4 fragmented",
+			args: StreamVideoArgs(&models.Meta{Format: "mp4"}, video, audio),
+			want: []string{"-y", "-i", video, "-i", audio, "-c:v", "copy", "-c:a", "copy",
+				"-f", "mp4", "-movflags", "frag_keyframe+empty_moov+faststart", "pipe:1"},
+		},
+		{
+			name: "webm muted",
+			args: StreamVideoArgs(&models.Meta{Format: "webm"}, video, ""),
+			want: []string{"-y", "-i", video, "-c:v", "copy", "-an", "-f", "webm", "pipe:1"},
+		},
+		{
+			name: "mkv keeps rotation",
+			args: StreamVideoArgs(&models.Meta{Format: "mkv", Rotation: &models.VideoRotation{Degrees: 270, Action: models.RotationPreserved}}, video, audio),
+			want: []string{"-y", "-i", video, "-i", audio, "-c:v", "copy", "-c:a", "copy",
+				"-metadata:s:v:0", "rotate=270", "-f", "matroska", "pipe:1"},
+		},
+		{
+			name: "opus copied",
+			args: StreamAudioArgs(&models.Meta{Format: "opus"}, audio),
+			want: []string{"-y", "-i", audio, "-c:a", "copy", "-f", "opus", "pipe:1"},
+		},
+		{
+			name: "mp3 transcoded at the default bitrate",
+			args: StreamAudioArgs(&models.Meta{Format: "mp3"}, audio),
+			want: []string{"-y", "-i", audio, "-vn", "-c:a", "libmp3lame", "-b:a", "192k", "-f", "mp3", "pipe:1"},
+		},
+		{
+			name: "m4a from aac copied",
+			args: StreamAudioArgs(&models.Meta{Format: "m4a"}, "/s/audio.m4a"),
+			want: []string{"-y", "-i", "/s/audio.m4a", "-c:a", "copy", "-f", "ipod", "pipe:1"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := useMockRunner(t)
+
+			stdout, wait, err := FFmpeg.StartPipe(context.Background(), tt.args)
+			if err != nil {
+				t.Fatalf("StartPipe: %v", err)
+			}
+			stdout.Close()
+			wait()
+			assertSingleCall(t, mock, tt.want)
+		})
+	}
+}
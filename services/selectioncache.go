@@ -0,0 +1,165 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+	"yt-downloader-go/config"
+	"yt-downloader-go/models"
+	"yt-downloader-go/utils"
+)
+
+// cachedSelection is a stream decision remembered per video and request (streams by itag)
+type cachedSelection struct {
+	video     *models.VideoSelectionResult // Stream is nil; flags of the original selection
+	videoItag int
+	audioItag int
+	trackID   string // Audio tracks share an itag
+	expires   time.Time
+}
+
+// selectionCache keeps recent selections so re-requests get the same streams
+var selectionCache = struct {
+	mu      sync.Mutex
+	entries map[string]*cachedSelection
+}{entries: map[string]*cachedSelection{}}
+
+// SelectionKey identifies the inputs of a stream selection
+func SelectionKey(videoID string, outputType string, quality string, osType string, trackID string, allowTranscode bool) string {
+	return fmt.Sprintf("%s|%s|%s|%s|%s|%t", videoID, outputType, quality, osType, trackID, allowTranscode)
+}
+
+// ReuseSelection looks up the streams selected for key within SelectionCacheTTL in
+// fresh extract data (so URLs are current). video is nil for audio jobs.
+// changed is true when a remembered stream is no longer offered; the caller selects
+// afresh and the stale entry is dropped.
+func ReuseSelection(data *models.ExtractResponse, key string) (video *models.VideoSelectionResult, audio *models.Stream, changed bool) {
+	selectionCache.mu.Lock()
+	entry := selectionCache.entries[key]
+	if entry != nil && time.Now().After(entry.expires) {
+		delete(selectionCache.entries, key)
+		entry = nil
+	}
+	selectionCache.mu.Unlock()
+
+	if entry == nil {
+		return nil, nil, false
+	}
+
+	audio = findStreamByItag(data.AudioStreams, entry.audioItag, entry.trackID)
+	if entry.video != nil {
+		if stream := findStreamByItag(data.VideoStreams, entry.videoItag, ""); stream != nil {
+			result := *entry.video
+			result.Stream = stream
+			video = &result
+		}
+	}
+
+	if audio == nil || (entry.video != nil && video == nil) {
+		selectionCache.mu.Lock()
+		delete(selectionCache.entries, key)
+		selectionCache.mu.Unlock()
+		return nil, nil, true
+	}
+	return video, audio, false
+}
+
+// RememberSelection stores the streams selected for key (video nil for audio jobs)
+// Streams without an itag can't be recognized later and are not remembered.
+func RememberSelection(key string, video *models.VideoSelectionResult, audio *models.Stream) {
+	if audio.Itag == 0 || (video != nil && video.Stream.Itag == 0) {
+		return
+	}
+
+	entry := &cachedSelection{
+		audioItag: audio.Itag,
+		trackID:   audio.AudioTrackID,
+		expires:   time.Now().Add(config.SelectionCacheTTL),
+	}
+	if video != nil {
+		flags := *video
+		flags.Stream = nil
+		entry.video = &flags
+		entry.videoItag = video.Stream.Itag
+	}
+
+	selectionCache.mu.Lock()
+	defer selectionCache.mu.Unlock()
+
+	// Drop expired entries so the map stays bounded by recent traffic
+	now := time.Now()
+	for k, e := range selectionCache.entries {
+		if now.After(e.expires) {
+			delete(selectionCache.entries, k)
+		}
+	}
+	selectionCache.entries[key] = entry
+}
+
+// findStreamByItag returns the downloadable stream with itag (and audio track, when set)
+func findStreamByItag(streams []models.Stream, itag int, trackID string) *models.Stream {
+	if itag == 0 {
+		return nil
+	}
+	for i := range streams {
+		if streams[i].Itag == itag && streams[i].AudioTrackID == trackID && !isProtectedStream(&streams[i]) {
+			return &streams[i]
+		}
+	}
+	return nil
+}
+
+// MatchStream finds a job's selected stream in fresh extract data: by itag (and audio track)
+// first, then by codec and height (same fps and track, closest bitrate) when the itag is
+// unknown or no longer offered. nil when neither matches.
+func MatchStream(streams []models.Stream, selected *models.SelectedStream) *models.Stream {
+	if selected == nil {
+		return nil
+	}
+	if stream := findStreamByItag(streams, selected.Itag, selected.TrackID); stream != nil {
+		return stream
+	}
+
+	var best *models.Stream
+	for i := range streams {
+		stream := &streams[i]
+		if getStreamCodec(stream) != selected.Codec || stream.Height != selected.Height || stream.AudioTrackID != selected.TrackID {
+			continue
+		}
+		if selected.FPS != 0 && stream.FPS != selected.FPS {
+			continue
+		}
+		if isProtectedStream(stream) {
+			continue
+		}
+		if best == nil || bitrateDistance(stream, selected) < bitrateDistance(best, selected) {
+			best = stream
+		}
+	}
+	return best
+}
+
+// bitrateDistance returns how far a stream's bitrate is from the selected one
+func bitrateDistance(stream *models.Stream, selected *models.SelectedStream) float64 {
+	return math.Abs(stream.Bitrate - float64(selected.Bitrate))
+}
+
+// jobLog logs job lines of the services package
+var jobLog = utils.Logger(utils.LogJobs)
+
+// LogSelection logs the upstream streams (itags) of a job, so reports about its quality
+// can be traced after the stream URLs expired
+func LogSelection(jobID string, selection *models.StreamSelection) {
+	attrs := []any{"job", jobID}
+	if video := selection.Video; video != nil {
+		attrs = append(attrs, "videoItag", video.Itag, "videoCodec", video.Codec, "height", video.Height, "fps", video.FPS)
+	}
+	if audio := selection.Audio; audio != nil {
+		attrs = append(attrs, "audioItag", audio.Itag, "audioCodec", audio.Codec)
+		if audio.TrackID != "" {
+			attrs = append(attrs, "track", audio.TrackID)
+		}
+	}
+	jobLog.Info("selected streams", attrs...)
+}
@@ -0,0 +1,144 @@
+package services
+
+import (
+	"fmt"
+	"testing"
+	"yt-downloader-go/models"
+)
+
+// itagStream is a playable stream for the itag matching tests
+func itagStream(itag int, codec string, height int, fps int, bitrate float64, trackID string) models.Stream {
+	return models.Stream{
+		URL:          fmt.Sprintf("https://origin.example/videoplayback?itag=%d", itag),
+		Codec:        codec,
+		Height:       height,
+		FPS:          fps,
+		Bitrate:      bitrate,
+		Itag:         itag,
+		AudioTrackID: trackID,
+	}
+}
+
+func TestFindStreamByItag(t *testing.T) {
+	protected := itagStream(251, "opus", 0, 0, 140_000, "")
+	protected.DRM = true
+	streams := []models.Stream{
+		itagStream(140, "mp4a", 0, 0, 130_000, ""),
+		protected,
+		itagStream(251, "opus", 0, 0, 135_000, ""),
+		itagStream(251, "opus", 0, 0, 150_000, ""), // Duplicate: the first playable one wins
+		itagStream(251, "opus", 0, 0, 160_000, "fr.3"),
+		itagStream(251, "opus", 0, 0, 165_000, "de.3"),
+	}
+
+	tests := []struct {
+		name    string
+		itag    int
+		trackID string
+		want    int // Index in streams, -1 for none
+	}{
+		{name: "single", itag: 140, want: 0},
+		{name: "duplicate skips the protected one", itag: 251, want: 2},
+		{name: "duplicate told apart by track", itag: 251, trackID: "de.3", want: 5},
+		{name: "track not offered", itag: 251, trackID: "es.3", want: -1},
+		{name: "itag not offered", itag: 399, want: -1},
+		{name: "unknown itag", itag: 0, want: -1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := findStreamByItag(streams, tt.itag, tt.trackID)
+			switch {
+			case tt.want < 0 && got != nil:
+				t.Errorf("found %+v, want none", *got)
+			case tt.want >= 0 && got != &streams[tt.want]:
+				t.Errorf("found %+v, want streams[%d]", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatchStream(t *testing.T) {
+	protected := itagStream(299, "avc1", 1080, 60, 6_000_000, "")
+	protected.Cipher = "s=abc"
+	streams := []models.Stream{
+		itagStream(137, "avc1", 1080, 30, 4_000_000, ""),
+		itagStream(248, "vp9", 1080, 30, 3_000_000, ""),
+		itagStream(399, "av01", 1080, 30, 2_500_000, ""),
+		itagStream(399, "av01", 1080, 30, 2_700_000, ""), // Duplicate itag
+		itagStream(616, "vp9", 1080, 30, 5_000_000, ""),
+		protected,
+		itagStream(136, "avc1", 720, 30, 2_000_000, ""),
+	}
+
+	tests := []struct {
+		name     string
+		selected *models.SelectedStream
+		want     int // Index in streams, -1 for none
+	}{
+		{name: "nothing selected", want: -1},
+		{name: "by itag", selected: &models.SelectedStream{Itag: 137, Codec: "avc1", Height: 1080, FPS: 30}, want: 0},
+		{
+			// The itag wins over codec, height and bitrate
+			name:     "by itag over codec and height",
+			selected: &models.SelectedStream{Itag: 136, Codec: "vp9", Height: 1080, FPS: 30, Bitrate: 5_000_000},
+			want:     6,
+		},
+		{name: "duplicate itag", selected: &models.SelectedStream{Itag: 399, Codec: "av01", Height: 1080, Bitrate: 2_700_000}, want: 2},
+		{
+			name:     "itag gone, closest bitrate of the codec and height",
+			selected: &models.SelectedStream{Itag: 303, Codec: "vp9", Height: 1080, FPS: 30, Bitrate: 4_600_000},
+			want:     4,
+		},
+		{
+			name:     "itag unknown",
+			selected: &models.SelectedStream{Codec: "vp9", Height: 1080, FPS: 30, Bitrate: 3_100_000},
+			want:     1,
+		},
+		{
+			// The only 60fps avc1 1080p stream needs deciphering
+			name:     "itag gone, only a protected match",
+			selected: &models.SelectedStream{Itag: 298, Codec: "avc1", Height: 1080, FPS: 60},
+			want:     -1,
+		},
+		{name: "itag and height gone", selected: &models.SelectedStream{Itag: 271, Codec: "vp9", Height: 1440, FPS: 30}, want: -1},
+		{name: "protected itag", selected: &models.SelectedStream{Itag: 299, Codec: "av01", Height: 720}, want: -1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := MatchStream(streams, tt.selected)
+			switch {
+			case tt.want < 0 && got != nil:
+				t.Errorf("matched %+v, want none", *got)
+			case tt.want >= 0 && got != &streams[tt.want]:
+				t.Errorf("matched %+v, want streams[%d]", got, tt.want)
+			}
+		})
+	}
+}
+
+// A remembered selection is found again by itag in fresh extract data; a missing itag drops it
+func TestReuseSelection(t *testing.T) {
+	video := itagStream(137, "avc1", 1080, 30, 4_000_000, "")
+	audio := itagStream(140, "mp4a", 0, 0, 130_000, "en.4")
+	data := &models.ExtractResponse{
+		VideoStreams: []models.Stream{itagStream(248, "vp9", 1080, 30, 3_000_000, ""), video, video},
+		AudioStreams: []models.Stream{itagStream(140, "mp4a", 0, 0, 130_000, "fr.3"), audio},
+	}
+	const key = "reuseTest01|video|1080p|windows|en.4|false"
+	RememberSelection(key, &models.VideoSelectionResult{Stream: &video, SelectedQuality: "1080p"}, &audio)
+
+	gotVideo, gotAudio, changed := ReuseSelection(data, key)
+	if changed || gotVideo == nil || gotVideo.Stream != &data.VideoStreams[1] || gotVideo.SelectedQuality != "1080p" || gotAudio != &data.AudioStreams[1] {
+		t.Fatalf("ReuseSelection = %+v, %+v, %t, want itag 137 (first of the duplicates) and the en.4 itag 140", gotVideo, gotAudio, changed)
+	}
+
+	data.VideoStreams = data.VideoStreams[:1]
+	if gotVideo, gotAudio, changed := ReuseSelection(data, key); !changed || gotVideo != nil || gotAudio != nil {
+		t.Errorf("ReuseSelection without itag 137 = %+v, %+v, %t, want changed", gotVideo, gotAudio, changed)
+	}
+	if _, _, changed := ReuseSelection(data, key); changed {
+		t.Error("the stale selection was kept")
+	}
+}
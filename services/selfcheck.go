@@ -0,0 +1,254 @@
+package services
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"yt-downloader-go/config"
+)
+
+// Check severities
+const (
+	CheckOK   = "OK"
+	CheckWarn = "WARN"
+	CheckFail = "FAIL"
+)
+
+// CheckResult is the outcome of one startup check
+type CheckResult struct {
+	Name   string
+	Status string // OK, WARN or FAIL
+	Detail string
+}
+
+// versionPattern matches "ffmpeg version 6.1.1" / "ffprobe version n7.0"
+var versionPattern = regexp.MustCompile(`version n?(\d+)\.(\d+)`)
+
+// RunStartupChecks verifies the environment the server depends on
+// FAIL results should stop startup; WARN results are reachability issues that may be transient.
+func RunStartupChecks() []CheckResult {
+	return []CheckResult{
+		checkBinary("ffmpeg", config.FFmpegPath, "FFMPEG_PATH"),
+		checkBinary("ffprobe", config.FFprobePath, "FFPROBE_PATH"),
+		checkStorageDir(),
+		checkTempDir(),
+		checkBaseURL(),
+		checkSigningSecret(),
+		checkExtractAuthConfig(),
+		checkExtractAPI(),
+		checkProxy(),
+	}
+}
+
+// HasFailedCheck reports whether any result is a hard failure
+func HasFailedCheck(results []CheckResult) bool {
+	for _, r := range results {
+		if r.Status == CheckFail {
+			return true
+		}
+	}
+	return false
+}
+
+// checkBinary runs "<path> -version" and compares the reported version with MinFFmpegVersion
+func checkBinary(name string, path string, envName string) CheckResult {
+	result := CheckResult{Name: name}
+
+	out, err := exec.Command(path, "-version").Output()
+	if err != nil {
+		result.Status = CheckFail
+		result.Detail = fmt.Sprintf("%s not runnable (set %s): %v", path, envName, err)
+		return result
+	}
+
+	match := versionPattern.FindSubmatch(out)
+	if match == nil {
+		// Git builds report e.g. "N-112345-g..."; assume recent
+		result.Status = CheckWarn
+		result.Detail = "unrecognized version string, assuming compatible"
+		return result
+	}
+
+	major, _ := strconv.Atoi(string(match[1]))
+	minor, _ := strconv.Atoi(string(match[2]))
+	if major < config.MinFFmpegMajor || (major == config.MinFFmpegMajor && minor < config.MinFFmpegMinor) {
+		result.Status = CheckFail
+		result.Detail = fmt.Sprintf("version %d.%d < required %d.%d", major, minor, config.MinFFmpegMajor, config.MinFFmpegMinor)
+		return result
+	}
+
+	result.Status = CheckOK
+	result.Detail = fmt.Sprintf("version %d.%d", major, minor)
+	return result
+}
+
+// checkStorageDir creates StorageDir if needed and writes a probe file
+func checkStorageDir() CheckResult {
+	return checkWritableDir("storage", config.StorageDir, "STORAGE_DIR")
+}
+
+// checkTempDir checks TempDir like StorageDir when TEMP_DIR is set
+func checkTempDir() CheckResult {
+	if config.TempDir == "" {
+		return CheckResult{Name: "temp_dir", Status: CheckOK, Detail: "not set, working in " + config.StorageDir}
+	}
+	return checkWritableDir("temp_dir", config.TempDir, "TEMP_DIR")
+}
+
+// checkWritableDir creates dir if needed and writes a probe file
+func checkWritableDir(name string, dir string, envName string) CheckResult {
+	result := CheckResult{Name: name}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		result.Status = CheckFail
+		result.Detail = fmt.Sprintf("cannot create %s (set %s): %v", dir, envName, err)
+		return result
+	}
+
+	probe := filepath.Join(dir, ".write-check")
+	if err := os.WriteFile(probe, []byte("ok"), 0644); err != nil {
+		result.Status = CheckFail
+		result.Detail = fmt.Sprintf("%s is not writable: %v", dir, err)
+		return result
+	}
+	os.Remove(probe)
+
+	result.Status = CheckOK
+	result.Detail = dir
+	return result
+}
+
+// checkBaseURL requires an absolute http(s) URL without query or fragment (links append to it)
+func checkBaseURL() CheckResult {
+	result := CheckResult{Name: "base_url"}
+
+	u, err := url.Parse(config.BaseURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || u.RawQuery != "" || u.Fragment != "" {
+		result.Status = CheckFail
+		result.Detail = fmt.Sprintf("BASE_URL %q must be an absolute http(s) URL without query or fragment", config.BaseURL)
+		return result
+	}
+
+	result.Status = CheckOK
+	result.Detail = config.PublicURL
+	return result
+}
+
+// checkSigningSecret requires configured secrets of at least MinSecretLength
+// The built-in default only warns so local setups keep working.
+func checkSigningSecret() CheckResult {
+	result := CheckResult{Name: "signing_secret"}
+
+	if os.Getenv("SIGNED_URL_SECRETS") == "" {
+		result.Status = CheckWarn
+		result.Detail = "using the built-in default secret; set SIGNED_URL_SECRETS in production"
+		return result
+	}
+
+	for i, secret := range config.SignedURLSecrets {
+		if len(secret) < config.MinSecretLength {
+			result.Status = CheckFail
+			result.Detail = fmt.Sprintf("secret #%d is shorter than %d characters", i+1, config.MinSecretLength)
+			return result
+		}
+	}
+
+	result.Status = CheckOK
+	result.Detail = fmt.Sprintf("%d secret(s)", len(config.SignedURLSecrets))
+	return result
+}
+
+// checkExtractAuthConfig fails on an unknown EXTRACT_API_AUTH mode or a missing credential
+func checkExtractAuthConfig() CheckResult {
+	result := CheckResult{Name: "extract_auth", Status: CheckOK}
+
+	switch config.ExtractAPIAuth {
+	case "":
+		result.Detail = "none"
+	case config.ExtractAuthBearer:
+		result.Detail = "bearer token"
+		if config.ExtractAPIToken == "" {
+			result.Status = CheckFail
+			result.Detail = "EXTRACT_API_AUTH=bearer needs EXTRACT_API_TOKEN"
+		}
+	case config.ExtractAuthHMAC:
+		result.Detail = "HMAC signature"
+		if config.ExtractAPISecret == "" {
+			result.Status = CheckFail
+			result.Detail = "EXTRACT_API_AUTH=hmac needs EXTRACT_API_SECRET"
+		}
+	default:
+		result.Status = CheckFail
+		result.Detail = fmt.Sprintf("EXTRACT_API_AUTH must be %q or %q", config.ExtractAuthBearer, config.ExtractAuthHMAC)
+	}
+	return result
+}
+
+// checkExtractAPI warns if the extract API does not answer (any HTTP response counts)
+// or rejects our credentials
+func checkExtractAPI() CheckResult {
+	result := CheckResult{Name: "extract_api"}
+
+	req, err := http.NewRequest("GET", config.ExtractAPIBase, nil)
+	if err != nil {
+		result.Status = CheckWarn
+		result.Detail = fmt.Sprintf("invalid EXTRACT_API_BASE: %v", err)
+		return result
+	}
+	signExtractRequest(req)
+
+	client := &http.Client{Timeout: config.StartupCheckTimeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		result.Status = CheckWarn
+		result.Detail = fmt.Sprintf("unreachable (set EXTRACT_API_BASE): %v", err)
+		return result
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+		result.Status = CheckWarn
+		result.Detail = fmt.Sprintf("%s: answered %d (check EXTRACT_API_AUTH credentials)", ExtractAuthFailed, resp.StatusCode)
+		return result
+	}
+
+	result.Status = CheckOK
+	result.Detail = config.ExtractAPIBase
+	return result
+}
+
+// checkProxy warns if the download proxy does not accept connections
+func checkProxy() CheckResult {
+	result := CheckResult{Name: "proxy"}
+
+	if config.WARPProxyURL == config.ProxyDirect {
+		result.Status = CheckOK
+		result.Detail = "direct (no proxy)"
+		return result
+	}
+
+	u, err := url.Parse(config.WARPProxyURL)
+	if err != nil || u.Host == "" {
+		result.Status = CheckWarn
+		result.Detail = "WARP_PROXY_URL is not a valid URL"
+		return result
+	}
+
+	conn, err := net.DialTimeout("tcp", u.Host, config.StartupCheckTimeout)
+	if err != nil {
+		result.Status = CheckWarn
+		result.Detail = fmt.Sprintf("unreachable: %v", err)
+		return result
+	}
+	conn.Close()
+
+	result.Status = CheckOK
+	result.Detail = u.Host
+	return result
+}
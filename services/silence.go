@@ -0,0 +1,73 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"yt-downloader-go/config"
+	"yt-downloader-go/models"
+)
+
+var (
+	silenceStartPattern = regexp.MustCompile(`silence_start=(-?[0-9.]+)`)
+	silenceEndPattern   = regexp.MustCompile(`silence_end=(-?[0-9.]+)`)
+)
+
+// silenceEdgeTolerance is how close (seconds) a silence must be to the file edge to count as leading/trailing
+const silenceEdgeTolerance = 0.05
+
+// DetectSilence runs ffmpeg silencedetect on an audio file and returns the leading/trailing
+// silence boundaries, each bounded to remove at most SilenceMaxTrim seconds
+func DetectSilence(ctx context.Context, path string, duration float64) (*models.SilenceTrim, error) {
+	// silencedetect reports via frame metadata; ametadata prints it to a file next to the input
+	reportPath := filepath.Join(filepath.Dir(path), "silence.txt")
+	defer os.Remove(reportPath)
+
+	args := []string{
+		"-hide_banner", "-nostats",
+		"-i", path,
+		"-af", fmt.Sprintf("silencedetect=noise=%s:d=%g,ametadata=mode=print:file=%s",
+			config.SilenceNoiseLevel, config.SilenceMinDuration, reportPath),
+		"-f", "null", "-",
+	}
+	if err := FFmpeg.Run(ctx, args); err != nil {
+		return nil, err
+	}
+
+	out, err := os.ReadFile(reportPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read silence report: %w", err)
+	}
+
+	result := &models.SilenceTrim{Start: 0, End: duration}
+
+	starts := silenceStartPattern.FindAllSubmatch(out, -1)
+	ends := silenceEndPattern.FindAllSubmatch(out, -1)
+
+	// Leading: the first silence begins at the start of the file
+	if len(starts) > 0 && len(ends) > 0 {
+		start, _ := strconv.ParseFloat(string(starts[0][1]), 64)
+		end, _ := strconv.ParseFloat(string(ends[0][1]), 64)
+		if start <= silenceEdgeTolerance {
+			result.Start = min(end, config.SilenceMaxTrim)
+		}
+	}
+
+	// Trailing: the last silence has no end (runs to EOF) or ends at the end of the file
+	if len(starts) > 0 {
+		last, _ := strconv.ParseFloat(string(starts[len(starts)-1][1]), 64)
+		trailing := len(ends) < len(starts)
+		if !trailing {
+			end, _ := strconv.ParseFloat(string(ends[len(ends)-1][1]), 64)
+			trailing = end >= duration-silenceEdgeTolerance
+		}
+		if trailing && last > result.Start {
+			result.End = max(last, duration-config.SilenceMaxTrim)
+		}
+	}
+
+	return result, nil
+}
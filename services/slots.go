@@ -0,0 +1,194 @@
+package services
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+	"yt-downloader-go/config"
+	"yt-downloader-go/models"
+)
+
+// priorityRank orders priorities; aging raises a waiter's rank up to the highest
+var priorityRank = map[string]int{
+	models.PriorityLow:    0,
+	models.PriorityNormal: 1,
+	models.PriorityHigh:   2,
+}
+
+// slotWaiter is a job waiting for a slot
+type slotWaiter struct {
+	jobID    string
+	rank     int
+	enqueued time.Time
+	ready    chan struct{}
+}
+
+// effectiveRank adds one rank per PriorityAgingInterval waited (starvation protection)
+func (w *slotWaiter) effectiveRank(now time.Time) int {
+	aged := w.rank + int(now.Sub(w.enqueued)/config.PriorityAgingInterval)
+	return min(aged, priorityRank[models.PriorityHigh])
+}
+
+// prioritySlots limits concurrent work to limit() slots (<= 0 = unlimited), handing free
+// slots out by priority
+type prioritySlots struct {
+	limit   func() int
+	mu      sync.Mutex
+	used    int
+	waiters []*slotWaiter
+}
+
+var (
+	// extractSlots limits extract API calls in flight (MAX_CONCURRENT_EXTRACTS)
+	extractSlots = &prioritySlots{limit: func() int { return config.MaxConcurrentExtracts }}
+	// downloadSlots limits jobs downloading their sources (MAX_CONCURRENT_DOWNLOADS)
+	downloadSlots = &prioritySlots{limit: func() int { return config.MaxConcurrentDownloads }}
+	// ffmpegSlots limits jobs in FFmpeg processing (MAX_CONCURRENT_FFMPEG)
+	ffmpegSlots = &prioritySlots{limit: func() int { return config.MaxConcurrentFFmpeg }}
+)
+
+// acquire blocks until a slot is free for jobID and returns its release func
+func (s *prioritySlots) acquire(ctx context.Context, jobID string, priority string) (func(), error) {
+	if s.limit() <= 0 {
+		return func() {}, nil
+	}
+
+	s.mu.Lock()
+	if s.used < s.limit() && len(s.waiters) == 0 {
+		s.used++
+		s.mu.Unlock()
+		return s.release, nil
+	}
+
+	waiter := &slotWaiter{
+		jobID:    jobID,
+		rank:     priorityRank[priority],
+		enqueued: time.Now(),
+		ready:    make(chan struct{}),
+	}
+	s.waiters = append(s.waiters, waiter)
+	s.mu.Unlock()
+
+	select {
+	case <-waiter.ready:
+		return s.release, nil
+	case <-ctx.Done():
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		select {
+		case <-waiter.ready:
+			// Granted while timing out: hand the slot on
+			s.used--
+			s.grantNext()
+		default:
+			s.remove(waiter)
+		}
+		return nil, ctx.Err()
+	}
+}
+
+// release frees a slot and grants it to the next waiter
+func (s *prioritySlots) release() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.used--
+	s.grantNext()
+}
+
+// grantNext wakes the best waiter if a slot is free (caller holds the lock)
+func (s *prioritySlots) grantNext() {
+	if s.used >= s.limit() || len(s.waiters) == 0 {
+		return
+	}
+	next := s.ordered(time.Now())[0]
+	s.remove(next)
+	s.used++
+	close(next.ready)
+}
+
+// remove drops a waiter from the queue (caller holds the lock)
+func (s *prioritySlots) remove(waiter *slotWaiter) {
+	for i, w := range s.waiters {
+		if w == waiter {
+			s.waiters = append(s.waiters[:i], s.waiters[i+1:]...)
+			return
+		}
+	}
+}
+
+// ordered returns waiters by effective priority, then by wait time (caller holds the lock)
+func (s *prioritySlots) ordered(now time.Time) []*slotWaiter {
+	ordered := make([]*slotWaiter, len(s.waiters))
+	copy(ordered, s.waiters)
+	slices.SortStableFunc(ordered, func(a, b *slotWaiter) int {
+		if ra, rb := a.effectiveRank(now), b.effectiveRank(now); ra != rb {
+			return rb - ra
+		}
+		return a.enqueued.Compare(b.enqueued)
+	})
+	return ordered
+}
+
+// position returns the job's 1-based position among waiters, or 0
+func (s *prioritySlots) position(jobID string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, w := range s.ordered(time.Now()) {
+		if w.jobID == jobID {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// AcquireFFmpegSlot blocks until the job may run FFmpeg processing and returns its release func
+// Unlimited when MaxConcurrentFFmpeg <= 0.
+func AcquireFFmpegSlot(ctx context.Context, jobID string, priority string) (func(), error) {
+	release, err := ffmpegSlots.acquire(ctx, jobID, priority)
+	if err != nil {
+		return nil, err
+	}
+	return timedRelease(release), nil
+}
+
+// AcquireDownloadSlot blocks until the job may download its sources and returns its release func
+// Unlimited when MaxConcurrentDownloads <= 0.
+func AcquireDownloadSlot(ctx context.Context, jobID string, priority string) (func(), error) {
+	return downloadSlots.acquire(ctx, jobID, priority)
+}
+
+// timedRelease wraps release to record how long the slot was held (admission estimates)
+func timedRelease(release func()) func() {
+	start := time.Now()
+	return func() {
+		RecordProcessingDuration(time.Since(start))
+		release()
+	}
+}
+
+// QueuePosition returns the job's 1-based position among jobs waiting to download, or else
+// among jobs waiting for FFmpeg; 0 when it isn't waiting
+func QueuePosition(jobID string) int {
+	if position := downloadSlots.position(jobID); position > 0 {
+		return position
+	}
+	return ffmpegSlots.position(jobID)
+}
+
+type extractPriorityKey struct{}
+
+// WithExtractPriority returns a context whose extract API calls wait for a slot at priority
+func WithExtractPriority(ctx context.Context, priority string) context.Context {
+	return context.WithValue(ctx, extractPriorityKey{}, priority)
+}
+
+// acquireExtractSlot waits for an extract API slot at the priority of ctx (normal by default)
+func acquireExtractSlot(ctx context.Context) (func(), error) {
+	priority, _ := ctx.Value(extractPriorityKey{}).(string)
+	if priority == "" {
+		priority = models.PriorityNormal
+	}
+	return extractSlots.acquire(ctx, "", priority)
+}
@@ -0,0 +1,131 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"yt-downloader-go/config"
+	"yt-downloader-go/models"
+	"yt-downloader-go/utils"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// sourceGroup ensures a source is downloaded once even if several jobs request it at the same time
+var sourceGroup singleflight.Group
+
+// SourceCacheName returns the content-addressed cache file name for a stream of a video
+func SourceCacheName(videoID string, stream *models.Stream) string {
+	key := fmt.Sprintf("%s|%s|%s|%d|%d|%d|%s",
+		videoID, stream.MimeType, stream.Codec, stream.Height, stream.FPS, stream.ContentLength, stream.AudioTrackID)
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:16]) + "." + GetExtension(stream)
+}
+
+// FetchSource downloads a stream into the shared source cache (once per cache name)
+// and links it into the job's work directory at destPath. A non-empty contentHash (Stream.ContentHash)
+// is verified; a mismatching download is tried once more before failing with *ChecksumError.
+// With TEMP_DIR the download runs in SourceWorkDir and only the finished file moves into the cache.
+// The download runs on its own context (ctx's values, SourceFetchTimeout) so it outlives a
+// canceled caller that other jobs are waiting with; each caller waits until its own ctx is done.
+func FetchSource(ctx context.Context, jobID string, name string, downloadURL string, destPath string, totalSize int64, contentHash string) error {
+	if err := os.MkdirAll(config.SourceCacheDir, 0755); err != nil {
+		return fmt.Errorf("failed to create source cache dir: %w", err)
+	}
+	if err := os.MkdirAll(config.SourceWorkDir, 0755); err != nil {
+		return fmt.Errorf("failed to create source work dir: %w", err)
+	}
+
+	// Reference first so eviction can't remove the source while we use it
+	if err := utils.AddSourceRef(name, jobID); err != nil {
+		return fmt.Errorf("failed to reference source: %w", err)
+	}
+
+	sourcePath := utils.GetSourcePath(name)
+
+	result := sourceGroup.DoChan(name, func() (_ interface{}, err error) {
+		// DoChan re-panics in a goroutine of its own, which no caller could recover
+		defer func() {
+			if r := recover(); r != nil {
+				err = utils.PanicError("source download", r, "source", name)
+			}
+		}()
+
+		if _, err := os.Stat(sourcePath); err == nil {
+			return nil, nil
+		}
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), config.SourceFetchTimeout)
+		defer cancel()
+
+		workPath := filepath.Join(config.SourceWorkDir, name)
+		if contentHash == "" {
+			return nil, finishSource(Download(ctx, downloadURL, workPath, totalSize), workPath, sourcePath)
+		}
+
+		ctx = withContentHash(ctx, contentHash)
+		err = Download(ctx, downloadURL, workPath, totalSize)
+		var checksumErr *ChecksumError
+		if errors.As(err, &checksumErr) {
+			downloadLog.Warn("source checksum mismatch, downloading again", "source", name, "err", err)
+			err = Download(ctx, downloadURL, workPath, totalSize)
+		}
+		return nil, finishSource(err, workPath, sourcePath)
+	})
+
+	var err error
+	select {
+	case res := <-result:
+		err = res.Err
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	if err != nil {
+		// The source may never land; the refs dir of a missing source is removed by eviction
+		utils.RemoveSourceRef(name, jobID)
+		return err
+	}
+
+	return linkOrCopy(sourcePath, destPath)
+}
+
+// finishSource moves a source downloaded to workPath into the cache at sourcePath
+// (no move when both are the same, without TEMP_DIR) and counts it toward the storage
+// quota; err is the download's result
+func finishSource(err error, workPath string, sourcePath string) error {
+	if err != nil {
+		return err
+	}
+	if workPath != sourcePath {
+		if err := utils.MoveFile(workPath, sourcePath); err != nil {
+			os.Remove(workPath)
+			return fmt.Errorf("failed to store source: %w", err)
+		}
+	}
+	utils.AddStorageUsage(utils.UsageSources, utils.GetFileSize(sourcePath))
+	return nil
+}
+
+// linkOrCopy hard-links src to dst, falling back to a copy (e.g. across devices)
+func linkOrCopy(src string, dst string) error {
+	if err := os.Link(src, dst); err == nil {
+		return nil
+	}
+
+	srcFile, err := os.Open(src)
+	if err != nil {
+		return fmt.Errorf("open source failed: %w", err)
+	}
+	defer srcFile.Close()
+
+	tmpPath := dst + ".tmp"
+	if err := streamToFile(srcFile, tmpPath); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+
+	return os.Rename(tmpPath, dst)
+}
@@ -0,0 +1,201 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+	"yt-downloader-go/config"
+	"yt-downloader-go/utils"
+)
+
+// downloadSparse preallocates destPath+".download" and has each worker write its
+// range in place (no chunk files, no merge pass). Completed chunks are recorded in
+// the sidecar so a crashed download resumes where it stopped.
+func downloadSparse(ctx context.Context, downloadURL string, destPath string, totalSize int64, tracker *utils.DownloadTracker) error {
+	partPath := destPath + config.SparseDownloadSuffix
+	sidecarPath := destPath + config.SparseSidecarSuffix
+
+	// Resume only a download of the same size and chunking
+	state := utils.ReadDownloadState(sidecarPath)
+	if state == nil || state.TotalSize != totalSize || state.ChunkSize != config.ChunkSize {
+		state = &utils.DownloadState{TotalSize: totalSize, ChunkSize: config.ChunkSize}
+		os.Remove(partPath)
+	}
+
+	file, err := os.OpenFile(partPath, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return fmt.Errorf("create file failed: %w", err)
+	}
+	utils.DownloadFilesOpen.Add(1)
+	defer utils.DownloadFilesOpen.Add(-1)
+	defer file.Close()
+
+	// Sparse preallocation: disk blocks are only used as ranges are written
+	if err := file.Truncate(totalSize); err != nil {
+		return fmt.Errorf("preallocate failed: %w", err)
+	}
+
+	// Verified in chunk order as chunks complete (chunks of a resumed download included)
+	expectedHash := expectedContentHash(ctx)
+	var hasher *chunkHasher
+	if expectedHash != "" {
+		hasher = newChunkHasher(file, totalSize)
+	}
+
+	numChunks := int((totalSize + config.ChunkSize - 1) / config.ChunkSize)
+	var pending []int
+	for idx := 0; idx < numChunks; idx++ {
+		if slices.Contains(state.Done, idx) {
+			tracker.Written.Add(chunkLength(idx, totalSize))
+			if hasher != nil {
+				hasher.complete(idx)
+			}
+		} else {
+			pending = append(pending, idx)
+		}
+	}
+
+	// Sidecar updates are serialized; a lost update only re-downloads a chunk
+	var stateMu sync.Mutex
+	markDone := func(idx int) {
+		if hasher != nil {
+			hasher.complete(idx)
+		}
+		stateMu.Lock()
+		defer stateMu.Unlock()
+		state.Done = append(state.Done, idx)
+		utils.WriteDownloadState(sidecarPath, state)
+	}
+
+	errChan := make(chan error, config.Threads)
+	next := int32(-1)
+	guard := &throttleGuard{}
+
+	var wg sync.WaitGroup
+	for w := 0; w < config.Threads; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() {
+				if r := recover(); r != nil {
+					errChan <- utils.PanicError("download worker", r)
+				}
+			}()
+
+			for {
+				i := int(atomic.AddInt32(&next, 1))
+				if i >= len(pending) {
+					return
+				}
+				if ctx.Err() != nil {
+					errChan <- ctx.Err()
+					return
+				}
+
+				idx := pending[i]
+				start := int64(idx) * config.ChunkSize
+				end := start + chunkLength(idx, totalSize) - 1
+				if err := writeRangeWithRetry(ctx, downloadURL, file, start, end, tracker, guard); err != nil {
+					errChan <- fmt.Errorf("chunk %d failed: %w", idx, err)
+					return
+				}
+				markDone(idx)
+			}
+		}()
+	}
+
+	wg.Wait()
+	close(errChan)
+
+	// Keep the partial file and sidecar for resume
+	for err := range errChan {
+		if err != nil {
+			return err
+		}
+	}
+
+	// Corrupt data can't be resumed
+	if hasher != nil {
+		if err := hasher.verify(expectedHash); err != nil {
+			os.Remove(partPath)
+			os.Remove(sidecarPath)
+			return err
+		}
+	}
+
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("close failed: %w", err)
+	}
+	if err := os.Rename(partPath, destPath); err != nil {
+		return fmt.Errorf("final rename failed: %w", err)
+	}
+	os.Remove(sidecarPath)
+	return nil
+}
+
+// chunkLength returns the size of chunk idx (the last one may be short)
+func chunkLength(idx int, totalSize int64) int64 {
+	start := int64(idx) * config.ChunkSize
+	return min(config.ChunkSize, totalSize-start)
+}
+
+// writeRangeWithRetry downloads bytes start..end into file at their offset
+// Bytes of a failed attempt are taken back from tracker before retrying. A request
+// guard finds throttled is retried on a fresh connection.
+func writeRangeWithRetry(ctx context.Context, downloadURL string, file *os.File, start, end int64, tracker *utils.DownloadTracker, guard *throttleGuard) error {
+	tracker.Workers.Add(1)
+	defer tracker.Workers.Add(-1)
+
+	bufPtr := utils.GetBuffer()
+	defer utils.PutBuffer(bufPtr)
+
+	var lastErr error
+	var throttled bool
+	for retry := 0; retry < config.MaxRetries; retry++ {
+		var written atomic.Int64
+		reqCtx, stopWatch := guard.watch(ctx, &written, throttled)
+		resp, err := fetchRange(reqCtx, downloadURL, start, end)
+		if err != nil {
+			throttled = stopWatch()
+			lastErr = err
+			// Don't retry on 403
+			if httpErr, ok := err.(*HTTPError); ok && httpErr.StatusCode == 403 {
+				return err
+			}
+			logRetry(start, end, retry+1, err)
+			time.Sleep(config.RetryDelay * time.Duration(retry+1))
+			continue
+		}
+
+		body := &countingReadCloser{ReadCloser: &countingReadCloser{ReadCloser: resp.Body, counter: &tracker.Written}, counter: &written}
+		n, err := io.CopyBuffer(io.NewOffsetWriter(file, start), io.LimitReader(body, end-start+1), *bufPtr)
+		resp.Body.Close()
+		if throttled = stopWatch(); throttled {
+			err = errThrottled
+		}
+
+		if err == nil && n != end-start+1 {
+			err = fmt.Errorf("short read: %d of %d bytes", n, end-start+1)
+		}
+		if err != nil {
+			lastErr = err
+			tracker.Written.Add(-written.Load())
+			// Retrying can't succeed until space is freed
+			if utils.IsStorageFull(err) {
+				return err
+			}
+			logRetry(start, end, retry+1, err)
+			time.Sleep(config.RetryDelay * time.Duration(retry+1))
+			continue
+		}
+		downloadLog.Debug("range done", "range", fmt.Sprintf("%d-%d", start, end), "bytes", n)
+		return nil
+	}
+
+	return lastErr
+}
@@ -0,0 +1,89 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"yt-downloader-go/config"
+	"yt-downloader-go/models"
+)
+
+// DownloadCover fetches the video thumbnail used as the still image of a static video
+func DownloadCover(ctx context.Context, thumbnailURL string, destPath string) error {
+	if thumbnailURL == "" {
+		return fmt.Errorf("video has no thumbnail")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", thumbnailURL, nil)
+	if err != nil {
+		return fmt.Errorf("create request failed: %w", err)
+	}
+	profile := setOriginHeaders(req, false)
+
+	resp, err := config.DownloadClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	recordOriginStatus(profile, resp.StatusCode)
+
+	if resp.StatusCode >= 400 {
+		body, _ := io.ReadAll(resp.Body)
+		return &HTTPError{StatusCode: resp.StatusCode, Message: string(body)}
+	}
+
+	tmpPath := destPath + ".tmp"
+	if err := streamToFile(io.LimitReader(resp.Body, config.MaxCoverSize), tmpPath); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	return os.Rename(tmpPath, destPath)
+}
+
+// FFmpegStaticVideo renders the audio over a still image (-tune stillimage) into a video container
+// Trim and fades are applied to the audio input directly.
+func FFmpegStaticVideo(ctx context.Context, jobDir string, format string, bitrate string, sourceCodec string, audioFile string, coverFile string, trim *models.TrimConfig) (string, error) {
+	outputFile := filepath.Join(jobDir, fmt.Sprintf("output.%s", format))
+
+	args := []string{
+		"-y",
+		"-loop", "1",
+		"-framerate", "1",
+		"-i", filepath.Join(jobDir, coverFile),
+	}
+	if trim != nil {
+		args = append(args, "-ss", fmt.Sprintf("%.3f", trim.Start), "-t", fmt.Sprintf("%.3f", trim.End-trim.Start))
+	}
+	args = append(args,
+		"-i", filepath.Join(jobDir, audioFile),
+		"-map", "0:v",
+		"-map", "1:a",
+		"-c:v", "libx264",
+		"-tune", "stillimage",
+		"-pix_fmt", "yuv420p",
+		// x264 needs even dimensions
+		"-vf", "scale=trunc(iw/2)*2:trunc(ih/2)*2",
+	)
+
+	fade := ""
+	if trim != nil && trim.Fade != nil {
+		fade = fadeFilter("afade", trim.Fade, 0, trim.End-trim.Start)
+	}
+	if fade == "" && !NeedsAudioTranscode(filepath.Ext(audioFile), sourceCodec, format, bitrate, "") {
+		args = append(args, "-c:a", "copy")
+	} else {
+		if fade != "" {
+			args = append(args, "-af", fade)
+		}
+		args = append(args, AudioEncodeArgs(format, "", bitrate)...)
+	}
+	args = append(args, "-shortest")
+
+	if err := runFFmpegToFile(ctx, args, format, outputFile); err != nil {
+		return "", fmt.Errorf("static video failed: %w", err)
+	}
+	return filepath.Base(outputFile), nil
+}
@@ -0,0 +1,54 @@
+package services
+
+import (
+	"path/filepath"
+	"yt-downloader-go/models"
+	"yt-downloader-go/utils"
+)
+
+// streamContainerOverhead is the size of a stream-copy remux relative to its sources, per
+// output container. The sources are already DASH fragments (mp4/webm) with similar framing:
+// Matroska clusters add next to nothing, fragmented mp4 (frag_keyframe) adds a moof per
+// keyframe and Ogg a page header per page. Containers without an entry get no estimate.
+var streamContainerOverhead = map[string]float64{
+	"mkv":  1.002,
+	"webm": 1.002,
+	"mp4":  1.01,
+	"m4a":  1.01,
+	"opus": 1.01,
+	"ogg":  1.01,
+}
+
+// EstimateStreamSize estimates the /stream body of a stream-only job whose streams are all
+// copied: the size of its sources times the container overhead. Returns 0 when a stream is
+// encoded (the size is only known at the end) or a source is missing.
+func EstimateStreamSize(meta *models.Meta) int64 {
+	factor, ok := streamContainerOverhead[meta.Format]
+	if !ok || !meta.StreamOnly || (meta.Files.Audio == nil && !meta.Muted) {
+		return 0
+	}
+
+	var files []*models.FileInfo
+	if meta.Files.Audio != nil {
+		files = append(files, meta.Files.Audio)
+	}
+	if meta.OutputType == "video" {
+		if meta.Files.Video == nil {
+			return 0
+		}
+		files = append(files, meta.Files.Video)
+	} else if NeedsAudioTranscode(filepath.Ext(meta.Files.Audio.Name), SourceAudioCodec(meta), meta.Format, meta.Bitrate, meta.AudioCodec) {
+		return 0
+	}
+
+	jobDir := utils.GetJobDir(meta.ID)
+	var total int64
+	for _, file := range files {
+		size := utils.GetFileSize(filepath.Join(jobDir, file.Name))
+		if size == 0 {
+			return 0
+		}
+		total += size
+	}
+	return int64(float64(total) * factor)
+}
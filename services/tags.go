@@ -0,0 +1,44 @@
+package services
+
+import (
+	"context"
+	"yt-downloader-go/config"
+	"yt-downloader-go/models"
+	"yt-downloader-go/utils"
+)
+
+type audioTagsKey struct{}
+
+// WithAudioTags returns a context whose file outputs are tagged with the title, channel and
+// upload year of meta (ID3 for mp3, the format's own tags otherwise). No-op with TAG_AUDIO=false.
+func WithAudioTags(ctx context.Context, meta *models.Meta) context.Context {
+	if !config.TagAudio {
+		return ctx
+	}
+	var args []string
+	for _, tag := range [][2]string{
+		{"title", meta.Title},
+		{"artist", meta.Author},
+		{"date", uploadYear(meta.UploadDate)},
+	} {
+		if tag[1] != "" {
+			args = append(args, "-metadata", tag[0]+"="+tag[1])
+		}
+	}
+	return context.WithValue(ctx, audioTagsKey{}, args)
+}
+
+// audioTagArgs returns the -metadata arguments set by WithAudioTags
+func audioTagArgs(ctx context.Context) []string {
+	args, _ := ctx.Value(audioTagsKey{}).([]string)
+	return args
+}
+
+// uploadYear returns the year of an upload date ("" when unknown); ID3 year frames take the year only
+func uploadYear(date string) string {
+	date = utils.NormalizeUploadDate(date)
+	if date == "" {
+		return ""
+	}
+	return date[:4]
+}
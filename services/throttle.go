@@ -0,0 +1,110 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"net/http"
+	"sync/atomic"
+	"time"
+	"yt-downloader-go/config"
+)
+
+// throttleReconnects counts chunk requests aborted as throttled (exported via /debug/vars)
+var throttleReconnects = expvar.NewInt("throttle_reconnects")
+
+// errThrottled is the cause of a chunk request aborted below THROTTLE_FLOOR_KBPS
+var errThrottled = errors.New("connection throttled")
+
+// throttleSampleInterval is how often a watched request's rate is measured
+const throttleSampleInterval = time.Second
+
+type freshConnectionKey struct{}
+
+// withFreshConnection returns a context whose range requests dial a new connection
+func withFreshConnection(ctx context.Context) context.Context {
+	return context.WithValue(ctx, freshConnectionKey{}, true)
+}
+
+// downloadClient returns the client for a range request of ctx: a new connection, the
+// download's own connections (DOWNLOAD_FILE_CONNS) or the shared pool
+func downloadClient(ctx context.Context) *http.Client {
+	if fresh, _ := ctx.Value(freshConnectionKey{}).(bool); fresh {
+		return config.FreshDownloadClient
+	}
+	if client, _ := ctx.Value(fileConnectionsKey{}).(*http.Client); client != nil {
+		return client
+	}
+	return config.DownloadClient
+}
+
+// throttleGuard detects throttled chunk requests of one download
+// It arms once a request of the download ran at ThrottleRearmFactor × the floor (the
+// source can be fast) and disarms when it aborts one, so a source that is slow on every
+// connection is not reconnected in a loop.
+type throttleGuard struct {
+	armed atomic.Bool
+}
+
+// watch measures the bytes written for a chunk request sent with the returned context
+// and aborts it when it stays below the floor for ThrottleWindow while the guard is armed.
+// fresh sends the request on a new connection. stop must be called once the body is read;
+// it reports whether the request was aborted as throttled.
+func (g *throttleGuard) watch(ctx context.Context, written *atomic.Int64, fresh bool) (context.Context, func() (throttled bool)) {
+	if fresh {
+		ctx = withFreshConnection(ctx)
+	}
+	if config.ThrottleFloor <= 0 {
+		return ctx, func() bool { return false }
+	}
+
+	ctx, cancel := context.WithCancelCause(ctx)
+	started := time.Now()
+	done := make(chan struct{})
+
+	go func() {
+		ticker := time.NewTicker(throttleSampleInterval)
+		defer ticker.Stop()
+
+		var last int64
+		lastAt := started
+		var slowSince time.Time
+		for {
+			select {
+			case <-done:
+				return
+			case now := <-ticker.C:
+				n := written.Load()
+				rate := float64(n-last) / now.Sub(lastAt).Seconds()
+				if rate >= float64(config.ThrottleFloor) {
+					slowSince = time.Time{}
+				} else if slowSince.IsZero() {
+					slowSince = lastAt
+				}
+				last, lastAt = n, now
+
+				if !slowSince.IsZero() && now.Sub(slowSince) >= config.ThrottleWindow && g.armed.Load() {
+					cancel(errThrottled)
+					return
+				}
+			}
+		}
+	}()
+
+	return ctx, func() bool {
+		close(done)
+		throttled := context.Cause(ctx) == errThrottled
+		cancel(nil)
+
+		if throttled {
+			g.armed.Store(false)
+			throttleReconnects.Add(1)
+			downloadLog.Debug("chunk request throttled, reconnecting", "floorKBps", config.ThrottleFloor/1024, "window", config.ThrottleWindow)
+			return true
+		}
+		if elapsed := time.Since(started).Seconds(); elapsed > 0 && float64(written.Load())/elapsed >= float64(config.ThrottleFloor)*config.ThrottleRearmFactor {
+			g.armed.Store(true)
+		}
+		return false
+	}
+}
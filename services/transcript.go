@@ -0,0 +1,233 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"yt-downloader-go/config"
+	"yt-downloader-go/models"
+)
+
+// SelectCaption picks the track for a transcript: uploaded captions in the spoken
+// language (the language of the generated track) first, then any uploaded captions,
+// then the generated track. nil when the video has no captions.
+func SelectCaption(tracks []models.CaptionTrack) *models.CaptionTrack {
+	var generated, uploaded *models.CaptionTrack
+	for i := range tracks {
+		track := &tracks[i]
+		if track.URL == "" {
+			continue
+		}
+		if track.Kind == "asr" {
+			if generated == nil {
+				generated = track
+			}
+		} else if uploaded == nil {
+			uploaded = track
+		}
+	}
+
+	if generated != nil {
+		for i := range tracks {
+			if tracks[i].URL != "" && tracks[i].Kind != "asr" && tracks[i].LanguageCode == generated.LanguageCode {
+				return &tracks[i]
+			}
+		}
+	}
+	if uploaded != nil {
+		return uploaded
+	}
+	return generated
+}
+
+// NewTranscript describes the transcript of a job from the selected caption track (nil = none)
+func NewTranscript(track *models.CaptionTrack) *models.Transcript {
+	if track == nil {
+		return &models.Transcript{}
+	}
+	return &models.Transcript{
+		URL:       track.URL,
+		Language:  track.LanguageCode,
+		Name:      track.Name,
+		Generated: track.Kind == "asr",
+	}
+}
+
+// cue is one timed caption (seconds)
+type cue struct {
+	Start float64
+	End   float64
+	Lines []string
+}
+
+// vttTagPattern matches inline cue tags: <c>, </c>, <00:00:01.520> word timings
+var vttTagPattern = regexp.MustCompile(`<[^>]*>`)
+
+// SaveTranscript downloads transcript's captions as WebVTT and writes TranscriptVTTName and
+// TranscriptTextName to jobDir, clipped to the trimmed range with times relative to its start
+func SaveTranscript(ctx context.Context, transcript *models.Transcript, jobDir string, duration float64, trim *models.TrimConfig) error {
+	data, err := fetchCaptions(ctx, transcript.URL)
+	if err != nil {
+		return err
+	}
+
+	start, end := 0.0, duration
+	if trim != nil {
+		start, end = trim.Start, min(trim.End, duration)
+	}
+	cues := clipCues(parseVTT(data), start, end)
+
+	var vtt, text bytes.Buffer
+	vtt.WriteString("WEBVTT\n")
+	fmt.Fprintf(&vtt, "Language: %s\n\n", transcript.Language)
+	if transcript.Generated {
+		vtt.WriteString("NOTE Generated by speech recognition (ASR); may contain errors\n\n")
+	}
+
+	// Generated captions repeat the previous line as they scroll; the text keeps each line once
+	var last string
+	for _, c := range cues {
+		fmt.Fprintf(&vtt, "%s --> %s\n%s\n\n", vttTimestamp(c.Start), vttTimestamp(c.End), strings.Join(c.Lines, "\n"))
+		for _, line := range c.Lines {
+			if line != last {
+				text.WriteString(line + "\n")
+				last = line
+			}
+		}
+	}
+
+	if err := writeFileAtomic(filepath.Join(jobDir, config.TranscriptVTTName), vtt.Bytes()); err != nil {
+		return err
+	}
+	return writeFileAtomic(filepath.Join(jobDir, config.TranscriptTextName), text.Bytes())
+}
+
+// fetchCaptions downloads a caption track in WebVTT format
+func fetchCaptions(ctx context.Context, trackURL string) ([]byte, error) {
+	u, err := url.Parse(trackURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid caption url: %w", err)
+	}
+	query := u.Query()
+	query.Set("fmt", "vtt")
+	u.RawQuery = query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", u.String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("create request failed: %w", err)
+	}
+	profile := setOriginHeaders(req, false)
+
+	resp, err := config.DownloadClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	recordOriginStatus(profile, resp.StatusCode)
+
+	if resp.StatusCode >= 400 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, &HTTPError{StatusCode: resp.StatusCode, Message: string(body)}
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, config.MaxTranscriptSize))
+}
+
+// parseVTT reads the cues of a WebVTT file, with inline tags removed and entities decoded
+func parseVTT(data []byte) []cue {
+	var cues []cue
+	var current *cue
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		// Generated captions start cues with a whitespace-only line; only an empty line ends a cue
+		raw := strings.TrimSuffix(scanner.Text(), "\r")
+		line := strings.TrimSpace(raw)
+
+		if before, after, ok := strings.Cut(line, "-->"); ok {
+			// Cue settings may follow the end time ("align:start position:0%")
+			fields := strings.Fields(after)
+			if len(fields) == 0 {
+				current = nil
+				continue
+			}
+			start, errStart := parseVTTTime(before)
+			end, errEnd := parseVTTTime(fields[0])
+			if errStart != nil || errEnd != nil {
+				current = nil
+				continue
+			}
+			cues = append(cues, cue{Start: start, End: end})
+			current = &cues[len(cues)-1]
+			continue
+		}
+
+		if raw == "" {
+			current = nil
+			continue
+		}
+		if current == nil {
+			continue // Header, NOTE/STYLE blocks and cue identifiers
+		}
+		if text := strings.TrimSpace(html.UnescapeString(vttTagPattern.ReplaceAllString(line, ""))); text != "" {
+			current.Lines = append(current.Lines, text)
+		}
+	}
+	return cues
+}
+
+// parseVTTTime parses "hh:mm:ss.ttt" or "mm:ss.ttt" into seconds
+func parseVTTTime(value string) (float64, error) {
+	parts := strings.Split(strings.TrimSpace(value), ":")
+	if len(parts) < 2 || len(parts) > 3 {
+		return 0, fmt.Errorf("invalid timestamp %q", value)
+	}
+	var seconds float64
+	for _, part := range parts {
+		n, err := strconv.ParseFloat(part, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid timestamp %q", value)
+		}
+		seconds = seconds*60 + n
+	}
+	return seconds, nil
+}
+
+// clipCues shifts cues into [start, end) and drops those outside it or without text
+func clipCues(cues []cue, start float64, end float64) []cue {
+	var result []cue
+	for _, c := range cues {
+		clippedStart := max(c.Start, start)
+		clippedEnd := min(c.End, end)
+		if clippedEnd <= clippedStart || len(c.Lines) == 0 {
+			continue
+		}
+		result = append(result, cue{Start: clippedStart - start, End: clippedEnd - start, Lines: c.Lines})
+	}
+	return result
+}
+
+// vttTimestamp formats seconds as "hh:mm:ss.ttt"
+func vttTimestamp(seconds float64) string {
+	ms := int64(seconds*1000 + 0.5)
+	return fmt.Sprintf("%02d:%02d:%02d.%03d", ms/3600000, ms/60000%60, ms/1000%60, ms%1000)
+}
+
+// writeFileAtomic writes data to a temp file and renames it over path
+func writeFileAtomic(path string, data []byte) error {
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("write %s failed: %w", filepath.Base(path), err)
+	}
+	return os.Rename(tmpPath, path)
+}
@@ -0,0 +1,32 @@
+package services
+
+import (
+	"context"
+	"io"
+	"sync/atomic"
+)
+
+type originCounterKey struct{}
+
+// WithOriginCounter returns a context whose downloads add received bytes to counter
+func WithOriginCounter(ctx context.Context, counter *atomic.Int64) context.Context {
+	return context.WithValue(ctx, originCounterKey{}, counter)
+}
+
+// originCounter returns the byte counter attached to ctx, if any
+func originCounter(ctx context.Context) *atomic.Int64 {
+	counter, _ := ctx.Value(originCounterKey{}).(*atomic.Int64)
+	return counter
+}
+
+// countingReadCloser counts bytes read through it
+type countingReadCloser struct {
+	io.ReadCloser
+	counter *atomic.Int64
+}
+
+func (r *countingReadCloser) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.counter.Add(int64(n))
+	return n, err
+}
@@ -0,0 +1,258 @@
+package utils
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"expvar"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+	"yt-downloader-go/config"
+	"yt-downloader-go/models"
+)
+
+// ArchiveDateLayout names daily archive files (UTC): 2024-01-13.jsonl
+const ArchiveDateLayout = "2006-01-02"
+
+var (
+	archiveQueue   chan *models.JobArchiveRecord
+	archiveDone    chan struct{}
+	archiveDropped = expvar.NewInt("archive_dropped")
+)
+
+// archiveSink stores batches of job records; the writer goroutine is its only caller
+type archiveSink interface {
+	// Write stores a batch; records it can't store are counted in archive_dropped
+	Write(batch []*models.JobArchiveRecord)
+	// Rotate runs once per UTC day change
+	Rotate()
+}
+
+// StartArchiveWriter starts the background writer when ARCHIVE_JOBS is enabled
+// Records go to daily files under ArchiveDir, or to ARCHIVE_HTTP_URL when set.
+func StartArchiveWriter() {
+	if !config.ArchiveJobs {
+		return
+	}
+	var sink archiveSink = fileSink{}
+	if config.ArchiveHTTPURL != "" {
+		sink = &httpSink{url: config.ArchiveHTTPURL, client: &http.Client{Timeout: config.ArchiveHTTPTimeout}}
+	} else if err := os.MkdirAll(config.ArchiveDir, 0755); err != nil {
+		storageLog.Error("job archive disabled", "dir", config.ArchiveDir, "err", err)
+		return
+	}
+	archiveQueue = make(chan *models.JobArchiveRecord, config.ArchiveQueueSize)
+	archiveDone = make(chan struct{})
+	go runArchiveWriter(sink)
+}
+
+// StopArchiveWriter flushes queued records and stops the writer
+func StopArchiveWriter() {
+	if archiveQueue == nil {
+		return
+	}
+	close(archiveQueue)
+	<-archiveDone
+}
+
+// ArchiveJob queues a record of a job about to be removed
+// Never blocks: records are dropped (archive_dropped) when the queue is full.
+func ArchiveJob(meta *models.Meta) {
+	if archiveQueue == nil {
+		return
+	}
+	select {
+	case archiveQueue <- newArchiveRecord(meta):
+	default:
+		archiveDropped.Add(1)
+	}
+}
+
+// newArchiveRecord summarizes a job (files must still exist for outputBytes)
+func newArchiveRecord(meta *models.Meta) *models.JobArchiveRecord {
+	record := &models.JobArchiveRecord{
+		ID:          meta.ID,
+		VideoHash:   hashVideoID(meta.VideoID),
+		Status:      meta.Status,
+		OutputType:  meta.OutputType,
+		Format:      meta.Format,
+		Quality:     meta.Quality,
+		Bitrate:     meta.Bitrate,
+		Duration:    meta.Duration,
+		Outputs:     len(meta.Outputs),
+		StreamOnly:  meta.StreamOnly,
+		OriginBytes: meta.Usage.OriginBytes,
+		ServedBytes: meta.Usage.ServedBytes,
+		ServedCount: meta.Usage.ServedCount,
+		UsageKey:    meta.UsageKey,
+		CreatedAt:   meta.CreatedAt,
+		DeletedAt:   meta.DeletedAt,
+		RemovedAt:   time.Now().UnixMilli(),
+	}
+	if meta.JobError != nil {
+		record.ErrorCode = meta.JobError.Code
+	}
+	if meta.Files.Video != nil {
+		record.SourceBytes += meta.Files.Video.Size
+	}
+	if meta.Files.Audio != nil {
+		record.SourceBytes += meta.Files.Audio.Size
+	}
+	if meta.Output != "" {
+		record.OutputBytes = GetFileSize(filepath.Join(GetJobDir(meta.ID), meta.Output))
+	}
+	return record
+}
+
+// hashVideoID keeps archived records joinable per video without naming it
+func hashVideoID(videoID string) string {
+	sum := sha256.Sum256([]byte(videoID))
+	return hex.EncodeToString(sum[:16])
+}
+
+// runArchiveWriter batches queued records into sink
+func runArchiveWriter(sink archiveSink) {
+	defer close(archiveDone)
+
+	ticker := time.NewTicker(config.ArchiveFlushInterval)
+	defer ticker.Stop()
+
+	var batch []*models.JobArchiveRecord
+	lastDay := ""
+	for {
+		select {
+		case record, ok := <-archiveQueue:
+			if !ok {
+				writeArchiveBatch(sink, batch)
+				return
+			}
+			batch = append(batch, record)
+			if len(batch) < config.ArchiveBatchSize {
+				continue
+			}
+		case <-ticker.C:
+		}
+
+		writeArchiveBatch(sink, batch)
+		batch = batch[:0]
+
+		// Rotation: prune old days once per day change
+		if day := time.Now().UTC().Format(ArchiveDateLayout); day != lastDay {
+			lastDay = day
+			sink.Rotate()
+		}
+	}
+}
+
+// writeArchiveBatch hands a non-empty batch to sink
+func writeArchiveBatch(sink archiveSink, batch []*models.JobArchiveRecord) {
+	if len(batch) > 0 {
+		sink.Write(batch)
+	}
+}
+
+// fileSink appends records to daily files under ArchiveDir (GET /api/admin/archive/:date)
+type fileSink struct{}
+
+// Write appends records to today's archive, dropping them past the daily cap
+func (fileSink) Write(batch []*models.JobArchiveRecord) {
+
+	path := ArchivePath(time.Now().UTC().Format(ArchiveDateLayout))
+	size := GetFileSize(path)
+	if size >= config.ArchiveMaxDailyBytes {
+		archiveDropped.Add(int64(len(batch)))
+		return
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		storageLog.Warn("job archive write failed", "path", path, "err", err)
+		archiveDropped.Add(int64(len(batch)))
+		return
+	}
+	defer file.Close()
+
+	w := bufio.NewWriter(file)
+	for i, record := range batch {
+		line, _ := json.Marshal(record)
+		if size+int64(len(line))+1 > config.ArchiveMaxDailyBytes {
+			archiveDropped.Add(int64(len(batch) - i))
+			break
+		}
+		w.Write(line)
+		w.WriteByte('\n')
+		size += int64(len(line)) + 1
+	}
+	if err := w.Flush(); err != nil {
+		storageLog.Warn("job archive write failed", "path", path, "err", err)
+	}
+}
+
+// Rotate removes daily files older than ARCHIVE_RETENTION_DAYS
+func (fileSink) Rotate() {
+	entries, err := os.ReadDir(config.ArchiveDir)
+	if err != nil {
+		return
+	}
+	cutoff := time.Now().UTC().AddDate(0, 0, -config.ArchiveRetentionDays)
+	for _, entry := range entries {
+		day, err := time.Parse(ArchiveDateLayout, strings.TrimSuffix(entry.Name(), ".jsonl"))
+		if err == nil && day.Before(cutoff) {
+			os.Remove(filepath.Join(config.ArchiveDir, entry.Name()))
+		}
+	}
+}
+
+// httpSink POSTs each batch as JSON lines to ARCHIVE_HTTP_URL; retention is the receiver's
+type httpSink struct {
+	url    string
+	client *http.Client
+}
+
+// Write sends one batch; a failed request or a non-2xx answer drops the batch
+func (s *httpSink) Write(batch []*models.JobArchiveRecord) {
+	var body bytes.Buffer
+	for _, record := range batch {
+		line, _ := json.Marshal(record)
+		body.Write(line)
+		body.WriteByte('\n')
+	}
+
+	req, err := http.NewRequest(http.MethodPost, s.url, &body)
+	if err != nil {
+		storageLog.Warn("job archive post failed", "err", err)
+		archiveDropped.Add(int64(len(batch)))
+		return
+	}
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	if config.ArchiveHTTPAuth != "" {
+		req.Header.Set("Authorization", config.ArchiveHTTPAuth)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		storageLog.Warn("job archive post failed", "records", len(batch), "err", err)
+		archiveDropped.Add(int64(len(batch)))
+		return
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		storageLog.Warn("job archive post rejected", "records", len(batch), "status", resp.StatusCode)
+		archiveDropped.Add(int64(len(batch)))
+	}
+}
+
+// Rotate is a no-op: the receiver keeps its own history
+func (s *httpSink) Rotate() {}
+
+// ArchivePath returns the archive file for a day (YYYY-MM-DD, UTC)
+func ArchivePath(day string) string {
+	return filepath.Join(config.ArchiveDir, day+".jsonl")
+}
@@ -5,25 +5,78 @@ import (
 	"path/filepath"
 	"time"
 	"yt-downloader-go/config"
+	"yt-downloader-go/models"
 
 	"github.com/robfig/cron/v3"
 )
 
+// cleanupLog logs cleanup runs, the orphan reaper and work directories; per-entry lines are debug
+var cleanupLog = Logger(LogCleanup)
+
+// jobLog logs job lines of the utils package
+var jobLog = Logger(LogJobs)
+
 func StartCleanupScheduler() *cron.Cron {
 	c := cron.New()
-	c.AddFunc(config.CleanupInterval, func() {
-		CleanupOldJobs()
-	})
+	c.AddFunc(config.CleanupInterval, runCleanup)
+	c.AddFunc(config.ReapInterval, func() { runRecovered("reaper", ReapOrphans) })
+	// Archives of groups whose last member expired or was deleted (no run ended)
+	c.AddFunc(config.CleanupInterval, func() { runRecovered("group archives", CheckGroupArchives) })
 	c.Start()
-	go CleanupOldJobs()
+	go func() {
+		// Work dirs of jobs a crashed previous run left behind are removed with the rest
+		runCleanup()
+		// ffmpeg processes of a crashed previous run are orphans right away
+		runRecovered("reaper", ReapOrphans)
+		runRecovered("group archives", ResumeGroupArchives)
+	}()
 	return c
 }
 
+// runCleanup runs the cleanup steps; a panicking step is logged and the others still run
+func runCleanup() {
+	runRecovered("job cleanup", CleanupOldJobs)
+	runRecovered("group cleanup", CleanupGroups)
+	runRecovered("source cache cleanup", CleanupSourceCache)
+	runRecovered("idempotency cleanup", CleanupIdempotencyKeys)
+	runRecovered("tombstone cleanup", CleanupTombstones)
+	runRecovered("extract cache cleanup", CleanupExtractCache)
+	runRecovered("work dir cleanup", CleanupWorkDirs)
+	runRecovered("storage quota", enforceStorageQuota)
+}
+
+// enforceStorageQuota recounts storage usage; over STORAGE_QUOTA_MB it evicts every
+// unreferenced cached source regardless of idle time
+func enforceStorageQuota() {
+	ScanStorageUsage()
+	if !StorageQuotaExceeded() {
+		return
+	}
+	evictSourceCache(0)
+	ScanStorageUsage()
+	cleanupLog.Warn("storage quota exceeded", "usedMB", StorageUsedBytes()/(1024*1024), "quotaMB", config.StorageQuota/(1024*1024))
+}
+
+// runRecovered runs a background task, recovering and logging its panic
+func runRecovered(where string, task func()) {
+	defer RecoverPanic(where)
+	task()
+}
+
+// isStorageSubdir reports whether a directory of StorageDir is not a job directory
+func isStorageSubdir(name string) bool {
+	return name == filepath.Base(config.SourceCacheDir) || name == filepath.Base(config.IdempotencyDir) || name == filepath.Base(config.ArchiveDir) ||
+		name == filepath.Base(config.SessionDir) || name == filepath.Base(config.PreviewDir) || name == filepath.Base(config.GroupDir) ||
+		name == filepath.Base(config.StagingDir) || name == filepath.Base(config.TombstoneDir) || name == filepath.Base(config.CacheDir)
+}
+
 func CleanupOldJobs() {
 	if _, err := os.Stat(config.StorageDir); os.IsNotExist(err) {
 		return
 	}
 
+	cleanupStagedJobs()
+
 	entries, err := os.ReadDir(config.StorageDir)
 	if err != nil {
 		return
@@ -31,6 +84,7 @@ func CleanupOldJobs() {
 
 	now := time.Now()
 	processed := 0
+	removed := 0
 
 	for _, entry := range entries {
 		if !entry.IsDir() {
@@ -39,6 +93,11 @@ func CleanupOldJobs() {
 
 		jobID := entry.Name()
 
+		// Source cache, idempotency records, the job archive, the session registry, previews, groups, staged jobs, tombstones and caches have their own eviction
+		if isStorageSubdir(jobID) {
+			continue
+		}
+
 		if !ValidateJobID(jobID) {
 			DeleteJobDir(jobID)
 			continue
@@ -50,11 +109,23 @@ func CleanupOldJobs() {
 			continue
 		}
 
-		createdAt := time.UnixMilli(meta.CreatedAt)
-		age := now.Sub(createdAt)
-
-		if age > config.MaxJobAge {
+		if now.After(JobExpiresAt(meta)) {
+			ArchiveJob(meta)
+			writeDeletedTombstone(meta)
 			DeleteJobDir(jobID)
+			removed++
+			cleanupLog.Debug("removed expired job", "job", jobID)
+		} else if IsDeleted(meta) && now.Sub(time.UnixMilli(meta.DeletedAt)) > config.DeleteGracePeriod {
+			// Soft-deleted and restore window passed
+			ArchiveJob(meta)
+			writeDeletedTombstone(meta)
+			DeleteJobDir(jobID)
+			removed++
+			cleanupLog.Debug("removed deleted job", "job", jobID)
+		} else if meta.Client != nil && meta.Client.ScrubbedAt == 0 && now.Sub(time.UnixMilli(meta.CreatedAt)) > config.ClientInfoRetention {
+			// Privacy window passed; the job itself stays until expiry
+			ScrubMetaClient(jobID)
+			cleanupLog.Debug("scrubbed client info", "job", jobID)
 		}
 
 		processed++
@@ -62,20 +133,64 @@ func CleanupOldJobs() {
 			break
 		}
 	}
+	if removed > 0 {
+		cleanupLog.Info("removed jobs", "count", removed, "checked", processed)
+	}
+}
+
+// EmergencyCleanup frees space after a disk-full error: the regular job cleanup,
+// every unreferenced cached source regardless of idle time, and the sources and
+// partial files of failed jobs (a requeue downloads them again)
+func EmergencyCleanup() {
+	CleanupOldJobs()
+	evictSourceCache(0)
+
+	entries, err := os.ReadDir(config.StorageDir)
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() || !ValidateJobID(entry.Name()) {
+			continue
+		}
+		meta, err := ReadMeta(entry.Name())
+		if err != nil || meta.Status != models.StatusError || IsJobRunning(meta.ID) {
+			continue
+		}
+		CleanupTempFiles(meta.ID, false)
+	}
+	ScanStorageUsage()
+
+	if free, err := StorageFreeBytes(); err == nil {
+		cleanupLog.Info("emergency cleanup done", "freeMB", free/(1024*1024))
+	}
 }
 
 // CleanupTempFiles removes temporary files from a job directory
-func CleanupTempFiles(jobID string) error {
-	jobDir := GetJobDir(jobID)
+// keepSources retains video.* and audio.* for re-conversion. With TEMP_DIR the retained
+// sources move into the job directory and the work directory is removed.
+func CleanupTempFiles(jobID string, keepSources bool) error {
+	removeTempFiles(GetJobDir(jobID), keepSources)
+	if config.TempDir == "" {
+		return nil
+	}
+
+	if keepSources {
+		removeTempFiles(GetWorkDir(jobID), true)
+		promoteSources(jobID)
+	}
+	return RemoveWorkDir(jobID)
+}
 
-	patterns := []string{
-		"*.tmp",
-		"video.*",
-		"audio.*",
+// removeTempFiles removes temporary files (and with !keepSources the sources) from dir
+func removeTempFiles(dir string, keepSources bool) {
+	patterns := []string{"*.tmp", "*" + config.PartialSuffix, "*" + config.SparseDownloadSuffix, "*" + config.SparseSidecarSuffix}
+	if !keepSources {
+		patterns = append(patterns, sourcePatterns...)
 	}
 
 	for _, pattern := range patterns {
-		matches, err := filepath.Glob(filepath.Join(jobDir, pattern))
+		matches, err := filepath.Glob(filepath.Join(dir, pattern))
 		if err != nil {
 			continue
 		}
@@ -86,6 +201,20 @@ func CleanupTempFiles(jobID string) error {
 			}
 		}
 	}
+}
 
-	return nil
+// PartialFiles lists unfinished FFmpeg outputs (*.part) in dir
+func PartialFiles(dir string) []string {
+	matches, _ := filepath.Glob(filepath.Join(dir, "*"+config.PartialSuffix))
+	for i, match := range matches {
+		matches[i] = filepath.Base(match)
+	}
+	return matches
+}
+
+// RemovePartialFiles deletes unfinished FFmpeg outputs in dir
+func RemovePartialFiles(dir string) {
+	for _, name := range PartialFiles(dir) {
+		os.Remove(filepath.Join(dir, name))
+	}
 }
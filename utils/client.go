@@ -0,0 +1,28 @@
+package utils
+
+import (
+	"time"
+	"yt-downloader-go/models"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// RequestClientInfo captures the creating client of a job
+// The IP honors TRUST_PROXY; the API key is stored as a fingerprint only.
+func RequestClientInfo(c *fiber.Ctx) *models.ClientInfo {
+	return &models.ClientInfo{
+		IP:        c.IP(),
+		UserAgent: c.Get(fiber.HeaderUserAgent),
+		APIKey:    ClientBinding("key", c.Get("X-API-Key")),
+		Referer:   c.Get(fiber.HeaderReferer),
+	}
+}
+
+// ScrubMetaClient overwrites the job's client details, keeping only when they were cleared
+func ScrubMetaClient(jobID string) error {
+	return UpdateMeta(jobID, func(meta *models.Meta) {
+		if meta.Client != nil {
+			meta.Client = &models.ClientInfo{ScrubbedAt: time.Now().UnixMilli()}
+		}
+	})
+}
@@ -0,0 +1,136 @@
+package utils
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+	"yt-downloader-go/config"
+	"yt-downloader-go/models"
+)
+
+var extractLog = Logger(LogExtract)
+
+// ExtractCacheEntry is an extract API result persisted in ExtractCacheDir
+// The file's modification time is its last use, for LRU eviction.
+type ExtractCacheEntry struct {
+	VideoID   string                  `json:"videoId"`
+	FetchedAt int64                   `json:"fetchedAt"` // Extract API call time (ms)
+	Data      *models.ExtractResponse `json:"data"`
+}
+
+// getExtractCachePath returns the cache file of a video ("" for IDs that aren't a plain name)
+func getExtractCachePath(videoID string) string {
+	if videoID == "" || filepath.Base(videoID) != videoID || strings.HasPrefix(videoID, ".") {
+		return ""
+	}
+	return filepath.Join(config.ExtractCacheDir, videoID+".json")
+}
+
+// extractCacheFresh reports whether an entry was fetched within ExtractCacheTTL
+func extractCacheFresh(entry *ExtractCacheEntry) bool {
+	return time.Since(time.UnixMilli(entry.FetchedAt)) < config.ExtractCacheTTL
+}
+
+// ReadExtractCache returns the persisted extract result of a video, or nil if none is
+// within ExtractCacheTTL. A hit counts as a use for eviction.
+func ReadExtractCache(videoID string) *ExtractCacheEntry {
+	path := getExtractCachePath(videoID)
+	if path == "" || config.ExtractCacheMaxEntries <= 0 {
+		return nil
+	}
+	entry := readExtractCacheFile(path)
+	if entry == nil {
+		return nil
+	}
+	now := time.Now()
+	os.Chtimes(path, now, now)
+	return entry
+}
+
+// readExtractCacheFile reads a cache file, or nil if it is unreadable or past ExtractCacheTTL
+func readExtractCacheFile(path string) *ExtractCacheEntry {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+
+	var entry ExtractCacheEntry
+	if err := json.Unmarshal(data, &entry); err != nil || entry.Data == nil || !extractCacheFresh(&entry) {
+		return nil
+	}
+	return &entry
+}
+
+// WriteExtractCache persists a fresh extract result of a video (temp file and rename)
+func WriteExtractCache(videoID string, fetchedAt time.Time, result *models.ExtractResponse) {
+	path := getExtractCachePath(videoID)
+	if path == "" || config.ExtractCacheMaxEntries <= 0 {
+		return
+	}
+	if err := os.MkdirAll(config.ExtractCacheDir, 0755); err != nil {
+		extractLog.Warn("failed to write extract cache", "video", videoID, "err", err)
+		return
+	}
+
+	data, err := json.Marshal(ExtractCacheEntry{VideoID: videoID, FetchedAt: fetchedAt.UnixMilli(), Data: result})
+	if err != nil {
+		return
+	}
+	tmpPath := path + ".new"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		extractLog.Warn("failed to write extract cache", "video", videoID, "err", err)
+		os.Remove(tmpPath)
+		return
+	}
+	os.Rename(tmpPath, path)
+}
+
+// CleanupExtractCache removes persisted extract results past ExtractCacheTTL, then the least
+// recently used ones beyond EXTRACT_CACHE_MAX_ENTRIES or EXTRACT_CACHE_MAX_MB
+func CleanupExtractCache() {
+	entries, err := os.ReadDir(config.ExtractCacheDir)
+	if err != nil {
+		return
+	}
+
+	type cacheFile struct {
+		path   string
+		size   int64
+		usedAt time.Time
+	}
+	var files []cacheFile
+	var total int64
+	for _, entry := range entries {
+		path := filepath.Join(config.ExtractCacheDir, entry.Name())
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		if !strings.HasSuffix(entry.Name(), ".json") {
+			// Interrupted writes
+			if time.Since(info.ModTime()) > time.Minute {
+				os.Remove(path)
+			}
+			continue
+		}
+
+		// Unused for the TTL means fetched before it too; otherwise read the fetch time
+		if time.Since(info.ModTime()) >= config.ExtractCacheTTL || readExtractCacheFile(path) == nil {
+			os.Remove(path)
+			continue
+		}
+		files = append(files, cacheFile{path: path, size: info.Size(), usedAt: info.ModTime()})
+		total += info.Size()
+	}
+
+	// Least recently used first
+	sort.Slice(files, func(i, j int) bool { return files[i].usedAt.Before(files[j].usedAt) })
+	for len(files) > 0 && (len(files) > max(config.ExtractCacheMaxEntries, 0) || total > config.ExtractCacheMaxBytes) {
+		os.Remove(files[0].path)
+		total -= files[0].size
+		files = files[1:]
+	}
+}
@@ -0,0 +1,114 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+	"yt-downloader-go/config"
+	"yt-downloader-go/models"
+)
+
+// cachedVideoIDs returns the video IDs persisted in ExtractCacheDir
+func cachedVideoIDs(t *testing.T) map[string]bool {
+	t.Helper()
+	entries, err := os.ReadDir(config.ExtractCacheDir)
+	if err != nil && !os.IsNotExist(err) {
+		t.Fatal(err)
+	}
+	ids := map[string]bool{}
+	for _, entry := range entries {
+		ids[strings.TrimSuffix(entry.Name(), ".json")] = true
+	}
+	return ids
+}
+
+// touchExtractCache sets the last use of a cache file
+func touchExtractCache(t *testing.T, videoID string, usedAt time.Time) {
+	t.Helper()
+	if err := os.Chtimes(getExtractCachePath(videoID), usedAt, usedAt); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestExtractCacheRoundTrip(t *testing.T) {
+	useTempStorage(t)
+	data := &models.ExtractResponse{Title: "Never Gonna Give You Up", Duration: 213}
+	WriteExtractCache("dQw4w9WgXcQ", time.Now(), data)
+
+	entry := ReadExtractCache("dQw4w9WgXcQ")
+	if entry == nil || entry.VideoID != "dQw4w9WgXcQ" || entry.Data.Title != data.Title || entry.Data.Duration != data.Duration {
+		t.Fatalf("ReadExtractCache = %+v, want the written result", entry)
+	}
+	if ReadExtractCache("aaaaaaaaaaa") != nil {
+		t.Error("hit for a video never cached")
+	}
+
+	// IDs that would escape ExtractCacheDir are never read or written
+	for _, videoID := range []string{"", "../meta", ".hidden", "a/b"} {
+		WriteExtractCache(videoID, time.Now(), data)
+		if ReadExtractCache(videoID) != nil {
+			t.Errorf("hit for video ID %q", videoID)
+		}
+	}
+	if ids := cachedVideoIDs(t); len(ids) != 1 {
+		t.Errorf("cache holds %v, want only dQw4w9WgXcQ", ids)
+	}
+}
+
+func TestExtractCacheTTL(t *testing.T) {
+	useTempStorage(t)
+	data := &models.ExtractResponse{Title: "Song"}
+	WriteExtractCache("fresh000000", time.Now(), data)
+	WriteExtractCache("stale000000", time.Now().Add(-config.ExtractCacheTTL-time.Second), data)
+
+	if ReadExtractCache("fresh000000") == nil {
+		t.Error("miss for an entry within the TTL")
+	}
+	if ReadExtractCache("stale000000") != nil {
+		t.Error("hit for an entry fetched before the TTL")
+	}
+
+	// A recent use doesn't extend the TTL: cleanup reads the fetch time
+	CleanupExtractCache()
+	if ids := cachedVideoIDs(t); !ids["fresh000000"] || ids["stale000000"] {
+		t.Errorf("after cleanup the cache holds %v, want only fresh000000", ids)
+	}
+}
+
+func TestExtractCacheEviction(t *testing.T) {
+	useTempStorage(t)
+	prev := config.ExtractCacheMaxEntries
+	config.ExtractCacheMaxEntries = 2
+	t.Cleanup(func() { config.ExtractCacheMaxEntries = prev })
+
+	now := time.Now()
+	data := &models.ExtractResponse{Title: "Song"}
+	for i, videoID := range []string{"oldest00000", "middle00000", "newest00000"} {
+		WriteExtractCache(videoID, now, data)
+		touchExtractCache(t, videoID, now.Add(time.Duration(i-3)*time.Minute))
+	}
+
+	// Reading the oldest makes it the most recently used
+	if ReadExtractCache("oldest00000") == nil {
+		t.Fatal("miss for oldest00000")
+	}
+	CleanupExtractCache()
+	if ids := cachedVideoIDs(t); len(ids) != 2 || !ids["oldest00000"] || !ids["newest00000"] {
+		t.Errorf("after eviction the cache holds %v, want oldest00000 and newest00000", ids)
+	}
+
+	// Interrupted writes go once they are a minute old
+	leftover := filepath.Join(config.ExtractCacheDir, "newest00000.json.new")
+	if err := os.WriteFile(leftover, []byte("{"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(leftover, now.Add(-2*time.Minute), now.Add(-2*time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	CleanupExtractCache()
+	if _, err := os.Stat(leftover); !os.IsNotExist(err) {
+		t.Errorf("interrupted write kept (err %v)", err)
+	}
+}
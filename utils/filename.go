@@ -3,15 +3,22 @@ package utils
 import (
 	"fmt"
 	"regexp"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
+	"unicode/utf8"
+	"yt-downloader-go/config"
 	"yt-downloader-go/models"
 )
 
 var (
 	// Characters not allowed in filenames
-	invalidChars = regexp.MustCompile(`[<>:"/\\|?*\x00-\x1f]`)
+	invalidChars = regexp.MustCompile(`[<>:"/\\|?*\x00-\x1f\x7f]`)
 	// Multiple spaces/underscores
 	multipleSpaces = regexp.MustCompile(`[\s_]+`)
+	// Brackets left empty by a filename template placeholder without a value
+	emptyBrackets = regexp.MustCompile(`\(\s*\)|\[\s*\]`)
 )
 
 // SanitizeFilename removes invalid characters from filename
@@ -22,16 +29,20 @@ func SanitizeFilename(name string) string {
 	name = multipleSpaces.ReplaceAllString(name, "_")
 	// Trim leading/trailing underscores and spaces
 	name = strings.Trim(name, "_ ")
-	// Limit length
+	// Limit length (bytes) without splitting a multi-byte character
 	if len(name) > 200 {
-		name = name[:200]
+		cut := 200
+		for cut > 0 && !utf8.RuneStart(name[cut]) {
+			cut--
+		}
+		name = strings.TrimRight(name[:cut], "_ ")
 	}
 	return name
 }
 
 // GenerateOutputFilename generates the output filename based on job metadata
 func GenerateOutputFilename(meta *models.Meta) string {
-	title := SanitizeFilename(meta.Title)
+	title := SanitizeFilename(renderFilenameTemplate(meta))
 	if title == "" {
 		title = "output"
 	}
@@ -44,21 +55,137 @@ func GenerateOutputFilename(meta *models.Meta) string {
 		parts = append(parts, meta.Quality)
 	}
 
-	// Add bitrate for audio
-	if meta.OutputType == "audio" && meta.Bitrate != "" {
-		parts = append(parts, meta.Bitrate)
+	// Add bitrate for lossy audio, with the codec suffix if any ("64k-HE")
+	if meta.OutputType == "audio" && !meta.StaticVideo && !slices.Contains(config.LosslessFormats, meta.Format) {
+		rate := meta.Bitrate
+		if suffix := config.AudioCodecFilenameSuffix[meta.AudioCodec]; suffix != "" {
+			rate = strings.TrimPrefix(rate+"-"+suffix, "-")
+		}
+		if rate != "" {
+			parts = append(parts, rate)
+		}
+	}
+
+	// Tempo and pitch of practice and transcription tracks ("0.75x", "-2st")
+	if meta.Tempo != 0 && meta.Tempo != 1 {
+		parts = append(parts, formatSeconds(meta.Tempo)+"x")
+	}
+	if meta.PitchSemitones != 0 {
+		parts = append(parts, fmt.Sprintf("%+dst", meta.PitchSemitones))
 	}
 
 	// Add trim info
 	if meta.Trim != nil {
-		trimInfo := fmt.Sprintf("%.0f-%.0fs", meta.Trim.Start, meta.Trim.End)
-		parts = append(parts, trimInfo)
+		parts = append(parts, formatTrimRange(meta.Trim.Start, meta.Trim.End))
 	}
 
 	filename := strings.Join(parts, "_")
 	return fmt.Sprintf("%s.%s", filename, meta.Format)
 }
 
+// renderFilenameTemplate fills FILENAME_TEMPLATE for meta; separators and brackets around
+// placeholders without a value are dropped
+func renderFilenameTemplate(meta *models.Meta) string {
+	name := strings.NewReplacer(
+		"{title}", meta.Title,
+		"{author}", meta.Author,
+		"{date}", NormalizeUploadDate(meta.UploadDate),
+		"{videoId}", meta.VideoID,
+	).Replace(config.FilenameTemplate)
+	name = emptyBrackets.ReplaceAllString(name, "")
+	return strings.Trim(name, " -_.")
+}
+
+// NormalizeUploadDate returns an upload date as YYYY-MM-DD; the extract API sends it either
+// that way or as YYYYMMDD. Anything else is "".
+func NormalizeUploadDate(date string) string {
+	for _, layout := range []string{"2006-01-02", "20060102"} {
+		if t, err := time.Parse(layout, date); err == nil {
+			return t.Format("2006-01-02")
+		}
+	}
+	return ""
+}
+
+// GetDisplayFilename returns the user-facing filename stored at completion,
+// or generates it for jobs created before it was stored
+func GetDisplayFilename(meta *models.Meta) string {
+	if meta.DisplayFilename != "" {
+		return meta.DisplayFilename
+	}
+	return GenerateOutputFilename(meta)
+}
+
+// formatTrimRange renders a trim range like "10-60s"
+// Ranges under a second keep sub-second precision ("0-0.5s" instead of "0-0s")
+func formatTrimRange(start, end float64) string {
+	if end-start >= 1 {
+		return fmt.Sprintf("%.0f-%.0fs", start, end)
+	}
+	return fmt.Sprintf("%s-%ss", formatSeconds(start), formatSeconds(end))
+}
+
+// formatSeconds formats seconds with up to 2 decimals, without trailing zeros
+func formatSeconds(v float64) string {
+	s := strconv.FormatFloat(v, 'f', 2, 64)
+	s = strings.TrimRight(s, "0")
+	return strings.TrimSuffix(s, ".")
+}
+
+// ContentDisposition builds the Content-Disposition header value
+// filename is an ASCII-only quoted-string fallback; filename* (RFC 5987) carries the real UTF-8 name
+func ContentDisposition(filename string, inline bool) string {
+	disposition := "attachment"
+	if inline {
+		disposition = "inline"
+	}
+	return fmt.Sprintf(`%s; filename="%s"; filename*=UTF-8''%s`, disposition, quoteFilename(filename), encodeRFC5987(filename))
+}
+
+// quoteFilename makes an ASCII quoted-string body: control characters are dropped
+// (no header injection), non-ASCII becomes "_", and quotes/backslashes are escaped
+func quoteFilename(name string) string {
+	var b strings.Builder
+	for _, r := range name {
+		switch {
+		case r < 0x20 || r == 0x7f:
+			continue
+		case r > 0x7e:
+			b.WriteByte('_')
+		case r == '"' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// encodeRFC5987 percent-encodes every byte that is not an RFC 5987 attr-char
+func encodeRFC5987(name string) string {
+	const hex = "0123456789ABCDEF"
+	var b strings.Builder
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		if isAttrChar(c) {
+			b.WriteByte(c)
+			continue
+		}
+		b.WriteByte('%')
+		b.WriteByte(hex[c>>4])
+		b.WriteByte(hex[c&0x0f])
+	}
+	return b.String()
+}
+
+func isAttrChar(c byte) bool {
+	if (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9') {
+		return true
+	}
+	return strings.IndexByte("!#$&+-.^_`|~", c) != -1
+}
+
 // GetExtFromMimeType extracts file extension from MIME type
 func GetExtFromMimeType(mimeType string) string {
 	// Remove codec info: "video/mp4; codecs=..." -> "video/mp4"
@@ -109,6 +236,8 @@ func ContentTypeFromExt(ext string) string {
 		return "audio/mpeg"
 	case "m4a":
 		return "audio/mp4"
+	case "m4b":
+		return "audio/x-m4b"
 	case "wav":
 		return "audio/wav"
 	case "opus":
@@ -117,6 +246,14 @@ func ContentTypeFromExt(ext string) string {
 		return "audio/flac"
 	case "ogg":
 		return "audio/ogg"
+	case "m3u8":
+		return "application/vnd.apple.mpegurl"
+	case "m4s":
+		return "video/iso.segment"
+	case "vtt":
+		return "text/vtt; charset=utf-8"
+	case "txt":
+		return "text/plain; charset=utf-8"
 	default:
 		return "application/octet-stream"
 	}
@@ -0,0 +1,107 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+	"yt-downloader-go/models"
+)
+
+func TestGenerateOutputFilenameBitrate(t *testing.T) {
+	tests := []struct {
+		name string
+		meta models.Meta
+		want string
+	}{
+		{name: "mp3", meta: models.Meta{Format: "mp3", Bitrate: "192k"}, want: "Song_192k.mp3"},
+		{name: "opus default", meta: models.Meta{Format: "opus", Bitrate: "128k"}, want: "Song_128k.opus"},
+		{name: "he-aac suffix", meta: models.Meta{Format: "m4a", Bitrate: "64k", AudioCodec: "aac_he"}, want: "Song_64k-HE.m4a"},
+		{name: "flac has no bitrate", meta: models.Meta{Format: "flac", Bitrate: "192k"}, want: "Song.flac"},
+		{name: "wav has no bitrate", meta: models.Meta{Format: "wav"}, want: "Song.wav"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			meta := tt.meta
+			meta.OutputType = "audio"
+			meta.Title = "Song"
+			if got := GenerateOutputFilename(&meta); got != tt.want {
+				t.Errorf("GenerateOutputFilename = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestContentDisposition(t *testing.T) {
+	tests := []struct {
+		name     string
+		filename string
+		inline   bool
+		want     string
+	}{
+		{
+			name:     "plain",
+			filename: "Song_192k.mp3",
+			want:     `attachment; filename="Song_192k.mp3"; filename*=UTF-8''Song_192k.mp3`,
+		},
+		{
+			name:     "inline",
+			filename: "Song_192k.mp3",
+			inline:   true,
+			want:     `inline; filename="Song_192k.mp3"; filename*=UTF-8''Song_192k.mp3`,
+		},
+		{
+			name:     "quotes",
+			filename: `say "hi".mp3`,
+			want:     `attachment; filename="say \"hi\".mp3"; filename*=UTF-8''say%20%22hi%22.mp3`,
+		},
+		{
+			name:     "backslash",
+			filename: `a\b.mp3`,
+			want:     `attachment; filename="a\\b.mp3"; filename*=UTF-8''a%5Cb.mp3`,
+		},
+		{
+			// Kept inside the quoted-string, so no parameter is added
+			name:     "semicolon",
+			filename: "a; filename=evil.exe.mp3",
+			want:     `attachment; filename="a; filename=evil.exe.mp3"; filename*=UTF-8''a%3B%20filename%3Devil.exe.mp3`,
+		},
+		{
+			name:     "CR/LF injection",
+			filename: "a\r\nSet-Cookie: x=1.mp3",
+			want:     `attachment; filename="aSet-Cookie: x=1.mp3"; filename*=UTF-8''a%0D%0ASet-Cookie%3A%20x%3D1.mp3`,
+		},
+		{
+			name:     "other control characters",
+			filename: "a\x00b\tc\x7f.mp3",
+			want:     `attachment; filename="abc.mp3"; filename*=UTF-8''a%00b%09c%7F.mp3`,
+		},
+		{
+			name:     "non-ASCII",
+			filename: "Café.mp3",
+			want:     `attachment; filename="Caf_.mp3"; filename*=UTF-8''Caf%C3%A9.mp3`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ContentDisposition(tt.filename, tt.inline); got != tt.want {
+				t.Errorf("ContentDisposition = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+// A 300-character CJK title is cut to 200 bytes on a character boundary before quoting
+func TestContentDispositionLongCJKTitle(t *testing.T) {
+	meta := models.Meta{OutputType: "audio", Format: "mp3", Bitrate: "192k", Title: strings.Repeat("中", 300)}
+	filename := GenerateOutputFilename(&meta)
+	if want := strings.Repeat("中", 66) + "_192k.mp3"; filename != want {
+		t.Fatalf("GenerateOutputFilename = %q, want 66 characters of the title", filename)
+	}
+
+	want := `attachment; filename="` + strings.Repeat("_", 66) + `_192k.mp3"; filename*=UTF-8''` +
+		strings.Repeat("%E4%B8%AD", 66) + "_192k.mp3"
+	if got := ContentDisposition(filename, false); got != want {
+		t.Errorf("ContentDisposition = %s, want %s", got, want)
+	}
+}
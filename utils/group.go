@@ -0,0 +1,275 @@
+package utils
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+	"yt-downloader-go/config"
+	"yt-downloader-go/models"
+)
+
+// Member states of a group beyond the job statuses
+const (
+	GroupMemberDeleted = "deleted" // Soft-deleted job
+	GroupMemberExpired = "expired" // Job removed by cleanup
+)
+
+// GetGroupDir returns the directory of a job group
+func GetGroupDir(groupID string) string {
+	return filepath.Join(config.GroupDir, groupID)
+}
+
+// getGroupPath returns the group.json path of a group
+func getGroupPath(groupID string) string {
+	return filepath.Join(GetGroupDir(groupID), "group.json")
+}
+
+// GetGroupArchivePath returns the zip of a group's outputs
+func GetGroupArchivePath(groupID string) string {
+	return filepath.Join(GetGroupDir(groupID), config.GroupArchiveName)
+}
+
+// ReadGroup reads the group.json file of a group
+func ReadGroup(groupID string) (*models.Group, error) {
+	data, err := os.ReadFile(getGroupPath(groupID))
+	if err != nil {
+		return nil, err
+	}
+
+	var group models.Group
+	if err := json.Unmarshal(data, &group); err != nil {
+		return nil, err
+	}
+	return &group, nil
+}
+
+// WriteGroup writes the group.json file of a group (temp file and rename)
+func WriteGroup(group *models.Group) error {
+	if err := os.MkdirAll(GetGroupDir(group.ID), 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(group, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	path := getGroupPath(group.ID)
+	tmpPath := path + ".new"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, path)
+}
+
+// groupLocks holds a mutex per group, like metaLocks for jobs
+var groupLocks sync.Map
+
+// UpdateGroup applies fn to the group atomically
+func UpdateGroup(groupID string, fn func(group *models.Group)) error {
+	lock, _ := groupLocks.LoadOrStore(groupID, &sync.Mutex{})
+	mu := lock.(*sync.Mutex)
+	mu.Lock()
+	defer mu.Unlock()
+
+	group, err := ReadGroup(groupID)
+	if err != nil {
+		return err
+	}
+	fn(group)
+	return WriteGroup(group)
+}
+
+// GroupExists checks if a group directory exists
+func GroupExists(groupID string) bool {
+	_, err := os.Stat(getGroupPath(groupID))
+	return err == nil
+}
+
+// ClaimGroupMember adds a job to a group; false when it already belongs to another one
+func ClaimGroupMember(jobID string, groupID string) (bool, error) {
+	claimed := false
+	err := UpdateMeta(jobID, func(meta *models.Meta) {
+		if meta.GroupID == "" || meta.GroupID == groupID || !GroupExists(meta.GroupID) {
+			meta.GroupID = groupID
+			claimed = true
+		}
+	})
+	return claimed, err
+}
+
+// releaseGroupMember removes a job from its group (no-op for jobs already gone)
+func releaseGroupMember(jobID string, groupID string) {
+	if !JobExists(jobID) {
+		return
+	}
+	UpdateMeta(jobID, func(meta *models.Meta) {
+		if meta.GroupID == groupID {
+			meta.GroupID = ""
+		}
+	})
+}
+
+// GroupMemberState returns a member's job status, GroupMemberDeleted or GroupMemberExpired
+// The meta is nil for members that are gone.
+func GroupMemberState(jobID string) (string, *models.Meta) {
+	meta, err := ReadMeta(jobID)
+	if err != nil {
+		return GroupMemberExpired, nil
+	}
+	if IsDeleted(meta) {
+		return GroupMemberDeleted, meta
+	}
+	return meta.Status, meta
+}
+
+// isGroupMemberFinished reports whether a member state is final
+func isGroupMemberFinished(state string) bool {
+	return state != models.StatusPending && state != models.StatusProcessing
+}
+
+// GroupExpiresAt returns when the group is removed: the latest expiry of its members,
+// never earlier than one seen before (members removed by cleanup keep it)
+func GroupExpiresAt(group *models.Group) time.Time {
+	expiresAt := time.UnixMilli(group.ExpiresAt)
+	for _, jobID := range group.JobIDs {
+		if meta, err := ReadMeta(jobID); err == nil && JobExpiresAt(meta).After(expiresAt) {
+			expiresAt = JobExpiresAt(meta)
+		}
+	}
+	return expiresAt
+}
+
+// DeleteGroup removes a group and its archive. withMembers soft-deletes the member jobs
+// (restorable like DELETE /api/jobs/:id); otherwise they leave the group. Returns the
+// number of members deleted.
+func DeleteGroup(groupID string, withMembers bool) (int, error) {
+	group, err := ReadGroup(groupID)
+	if err != nil {
+		return 0, err
+	}
+	if err := os.RemoveAll(GetGroupDir(groupID)); err != nil {
+		return 0, err
+	}
+	groupLocks.Delete(groupID)
+
+	deleted := 0
+	now := time.Now().UnixMilli()
+	for _, jobID := range group.JobIDs {
+		if !withMembers {
+			releaseGroupMember(jobID, groupID)
+			continue
+		}
+		if state, _ := GroupMemberState(jobID); state == GroupMemberDeleted || state == GroupMemberExpired {
+			continue
+		}
+		if err := UpdateMetaDeleted(jobID, now); err == nil {
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+// NotifyGroupMemberFinished builds the archive of the job's group once the job was its last
+// running member; called when a job run ends
+func NotifyGroupMemberFinished(jobID string) {
+	meta, err := ReadMeta(jobID)
+	if err != nil || meta.GroupID == "" {
+		return
+	}
+	CheckGroupArchive(meta.GroupID)
+}
+
+// CheckGroupArchive builds the archive of a group whose members have all finished
+// The build runs in the calling goroutine; concurrent callers return right away.
+func CheckGroupArchive(groupID string) {
+	var group *models.Group
+	err := UpdateGroup(groupID, func(g *models.Group) {
+		if g.Archive == nil || g.Archive.Status != models.StatusPending {
+			return
+		}
+		for _, jobID := range g.JobIDs {
+			if state, _ := GroupMemberState(jobID); !isGroupMemberFinished(state) {
+				return
+			}
+		}
+		g.Archive.Status = models.StatusProcessing
+		group = g
+	})
+	if err != nil || group == nil {
+		return
+	}
+
+	files, size, buildErr := buildGroupArchive(group)
+	UpdateGroup(groupID, func(g *models.Group) {
+		if buildErr != nil {
+			jobLog.Warn("group archive failed", "group", groupID, "err", buildErr)
+			g.Archive = &models.GroupArchive{Status: models.StatusError, Error: buildErr.Error()}
+			return
+		}
+		g.Archive = &models.GroupArchive{Status: models.StatusCompleted, Files: files, Size: size}
+	})
+}
+
+// listGroupIDs returns the IDs of all stored groups
+func listGroupIDs() []string {
+	entries, err := os.ReadDir(config.GroupDir)
+	if err != nil {
+		return nil
+	}
+	var ids []string
+	for _, entry := range entries {
+		if entry.IsDir() && ValidateJobID(entry.Name()) {
+			ids = append(ids, entry.Name())
+		}
+	}
+	return ids
+}
+
+// ResumeGroupArchives restarts the archives a previous run of the server was building
+func ResumeGroupArchives() {
+	for _, groupID := range listGroupIDs() {
+		UpdateGroup(groupID, func(group *models.Group) {
+			if group.Archive != nil && group.Archive.Status == models.StatusProcessing {
+				group.Archive.Status = models.StatusPending
+			}
+		})
+	}
+	CheckGroupArchives()
+}
+
+// CheckGroupArchives builds the archives whose last member finished without a run ending
+// (expired, deleted or force-failed), one at a time
+func CheckGroupArchives() {
+	for _, groupID := range listGroupIDs() {
+		CheckGroupArchive(groupID)
+	}
+}
+
+// CleanupGroups removes groups past the expiry of all their members
+func CleanupGroups() {
+	now := time.Now()
+	for _, groupID := range listGroupIDs() {
+		group, err := ReadGroup(groupID)
+		if err != nil {
+			// Leave a group being created alone; unreadable ones go
+			if info, statErr := os.Stat(GetGroupDir(groupID)); statErr == nil && now.Sub(info.ModTime()) > time.Minute {
+				os.RemoveAll(GetGroupDir(groupID))
+			}
+			continue
+		}
+
+		expiresAt := GroupExpiresAt(group)
+		if now.After(expiresAt) {
+			os.RemoveAll(GetGroupDir(groupID))
+			groupLocks.Delete(groupID)
+			continue
+		}
+		if expiresAt.UnixMilli() != group.ExpiresAt {
+			UpdateGroup(groupID, func(g *models.Group) {
+				g.ExpiresAt = max(g.ExpiresAt, expiresAt.UnixMilli())
+			})
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package utils
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+	"yt-downloader-go/config"
+	"yt-downloader-go/models"
+)
+
+// errEmptyGroupArchive fails an archive none of whose members has an output file
+var errEmptyGroupArchive = errors.New("no member has an output file")
+
+// buildGroupArchive zips the primary output of every completed member of group, plus a
+// manifest.json listing the members left out and why. Outputs are streamed into the
+// zip uncompressed (media doesn't deflate) and never held in memory.
+// Returns the number of outputs included and the archive size.
+func buildGroupArchive(group *models.Group) (int, int64, error) {
+	archivePath := GetGroupArchivePath(group.ID)
+	tmpPath := archivePath + ".tmp"
+
+	file, err := os.Create(tmpPath)
+	if err != nil {
+		return 0, 0, fmt.Errorf("create archive failed: %w", err)
+	}
+	DownloadFilesOpen.Add(1)
+	defer DownloadFilesOpen.Add(-1)
+	defer file.Close()
+
+	manifest := models.GroupManifest{
+		Group:     group.ID,
+		Name:      group.Name,
+		CreatedAt: time.Now().UnixMilli(),
+		Files:     []models.GroupManifestEntry{},
+		Errors:    []models.GroupManifestEntry{},
+	}
+	names := map[string]bool{config.GroupManifestName: true}
+
+	zw := zip.NewWriter(file)
+	for _, jobID := range group.JobIDs {
+		entry, err := addGroupMember(zw, jobID, names)
+		if err != nil {
+			os.Remove(tmpPath)
+			return 0, 0, err
+		}
+		if entry.Error != "" {
+			manifest.Errors = append(manifest.Errors, entry)
+		} else {
+			manifest.Files = append(manifest.Files, entry)
+		}
+	}
+	if len(manifest.Files) == 0 {
+		os.Remove(tmpPath)
+		return 0, 0, errEmptyGroupArchive
+	}
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err == nil {
+		var w io.Writer
+		if w, err = zw.Create(config.GroupManifestName); err == nil {
+			_, err = w.Write(data)
+		}
+	}
+	if err == nil {
+		err = zw.Close()
+	}
+	if err == nil {
+		err = file.Close()
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		return 0, 0, fmt.Errorf("write archive failed: %w", err)
+	}
+
+	if err := os.Rename(tmpPath, archivePath); err != nil {
+		os.Remove(tmpPath)
+		return 0, 0, fmt.Errorf("rename archive failed: %w", err)
+	}
+	return len(manifest.Files), GetFileSize(archivePath), nil
+}
+
+// addGroupMember writes a member's output to zw under a name not yet in names
+// A member without an output is reported in the entry's Error; the error return is
+// for failures writing the archive.
+func addGroupMember(zw *zip.Writer, jobID string, names map[string]bool) (models.GroupManifestEntry, error) {
+	entry := models.GroupManifestEntry{JobID: jobID}
+
+	state, meta := GroupMemberState(jobID)
+	if meta != nil {
+		entry.Title = meta.Title
+	}
+	switch {
+	case state == GroupMemberExpired:
+		entry.Error = "Job expired"
+		return entry, nil
+	case state == GroupMemberDeleted:
+		entry.Error = "Job was deleted"
+		return entry, nil
+	case state == models.StatusError:
+		entry.Error = meta.Error
+		if entry.Error == "" {
+			entry.Error = "Job failed"
+		}
+		return entry, nil
+	case meta.Output == "":
+		entry.Error = "Job is served as a stream only and has no file"
+		return entry, nil
+	}
+
+	source, err := os.Open(filepath.Join(GetJobDir(jobID), meta.Output))
+	if err != nil {
+		entry.Error = "Output file is missing"
+		return entry, nil
+	}
+	DownloadFilesOpen.Add(1)
+	defer DownloadFilesOpen.Add(-1)
+	defer source.Close()
+
+	info, err := source.Stat()
+	if err != nil {
+		entry.Error = "Output file is missing"
+		return entry, nil
+	}
+
+	entry.File = uniqueArchiveName(GetDisplayFilename(meta), names)
+	w, err := zw.CreateHeader(&zip.FileHeader{
+		Name:     entry.File,
+		Method:   zip.Store,
+		Modified: info.ModTime(),
+	})
+	if err != nil {
+		return entry, fmt.Errorf("add %s failed: %w", jobID, err)
+	}
+
+	bufPtr := GetBuffer()
+	defer PutBuffer(bufPtr)
+	if _, err := io.CopyBuffer(w, source, *bufPtr); err != nil {
+		return entry, fmt.Errorf("copy %s failed: %w", jobID, err)
+	}
+	return entry, nil
+}
+
+// uniqueArchiveName returns name, or "name (2).ext" and so on when it is taken, and records it
+func uniqueArchiveName(name string, names map[string]bool) string {
+	unique := name
+	ext := filepath.Ext(name)
+	for i := 2; names[unique]; i++ {
+		unique = fmt.Sprintf("%s (%d)%s", strings.TrimSuffix(name, ext), i, ext)
+	}
+	names[unique] = true
+	return unique
+}
@@ -0,0 +1,107 @@
+package utils
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+	"yt-downloader-go/config"
+	"yt-downloader-go/models"
+)
+
+// IdempotencyRecord maps an idempotency key to the job it created
+type IdempotencyRecord struct {
+	JobID     string                  `json:"jobId"`
+	BodyHash  string                  `json:"bodyHash"` // SHA-256 of the request body, to detect key reuse
+	Response  models.DownloadResponse `json:"response"`
+	CreatedAt int64                   `json:"createdAt"`
+}
+
+// idempotencyLocks serializes requests sharing a key so only the first creates a job
+var idempotencyLocks sync.Map
+
+// getIdempotencyPath returns the record path for a key (hashed, so any key is a safe file name)
+func getIdempotencyPath(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(config.IdempotencyDir, hex.EncodeToString(sum[:])+".json")
+}
+
+// LockIdempotencyKey holds the lock for a key until the returned unlock is called
+func LockIdempotencyKey(key string) (unlock func()) {
+	lock, _ := idempotencyLocks.LoadOrStore(getIdempotencyPath(key), &sync.Mutex{})
+	mu := lock.(*sync.Mutex)
+	mu.Lock()
+	return mu.Unlock
+}
+
+// HashRequestBody returns the hash compared between requests sharing a key
+func HashRequestBody(req *models.DownloadRequest) string {
+	data, _ := json.Marshal(req)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// ReadIdempotencyRecord returns the record for a key, or nil if none is live
+// Records live as long as their job.
+func ReadIdempotencyRecord(key string) *IdempotencyRecord {
+	data, err := os.ReadFile(getIdempotencyPath(key))
+	if err != nil {
+		return nil
+	}
+
+	var record IdempotencyRecord
+	if err := json.Unmarshal(data, &record); err != nil || !isLiveIdempotencyRecord(&record) {
+		return nil
+	}
+	return &record
+}
+
+// WriteIdempotencyRecord stores the record for a key
+func WriteIdempotencyRecord(key string, record *IdempotencyRecord) error {
+	if err := os.MkdirAll(config.IdempotencyDir, 0755); err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	path := getIdempotencyPath(key)
+	tmpPath := path + ".new"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, path)
+}
+
+// isLiveIdempotencyRecord reports whether the record's job still exists and is within MaxJobAge
+func isLiveIdempotencyRecord(record *IdempotencyRecord) bool {
+	return time.Since(time.UnixMilli(record.CreatedAt)) <= config.MaxJobAge && JobExists(record.JobID)
+}
+
+// CleanupIdempotencyKeys removes records whose job has expired or been deleted
+func CleanupIdempotencyKeys() {
+	entries, err := os.ReadDir(config.IdempotencyDir)
+	if err != nil {
+		return
+	}
+
+	for _, entry := range entries {
+		path := filepath.Join(config.IdempotencyDir, entry.Name())
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+
+		var record IdempotencyRecord
+		if err := json.Unmarshal(data, &record); err != nil || !isLiveIdempotencyRecord(&record) {
+			os.Remove(path)
+			idempotencyLocks.Delete(path)
+		}
+	}
+}
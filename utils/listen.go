@@ -0,0 +1,28 @@
+package utils
+
+import "net"
+
+// netListen opens listeners; tests replace it to simulate a host without IPv6
+var netListen = net.Listen
+
+// Listen opens the server listener. The default "tcp6" listens dual-stack and falls back
+// to tcp4 on hosts with IPv6 disabled; any other network is used as-is.
+// Returns the network actually bound.
+func Listen(network string, addr string) (net.Listener, string, error) {
+	if network != "tcp6" {
+		ln, err := netListen(network, addr)
+		return ln, network, err
+	}
+
+	// Go makes "tcp6" sockets IPv6-only. "tcp" on a wildcard address is dual-stack where
+	// IPv6 is available and IPv4-only where it isn't.
+	ln, err := netListen("tcp", addr)
+	if err != nil {
+		return nil, network, err
+	}
+	if tcpAddr, ok := ln.Addr().(*net.TCPAddr); ok && tcpAddr.IP.To4() != nil {
+		Logger(LogServer).Warn("IPv6 unavailable, listening on tcp4", "addr", addr)
+		return ln, "tcp4", nil
+	}
+	return ln, network, nil
+}
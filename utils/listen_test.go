@@ -0,0 +1,103 @@
+package utils
+
+import (
+	"net"
+	"strconv"
+	"testing"
+)
+
+// withoutIPv6 makes listeners IPv4-only for the duration of the test, as on a host with
+// IPv6 disabled
+func withoutIPv6(t *testing.T) {
+	t.Helper()
+	netListen = func(network string, addr string) (net.Listener, error) {
+		if network == "tcp" {
+			network = "tcp4"
+		}
+		return net.Listen(network, addr)
+	}
+	t.Cleanup(func() { netListen = net.Listen })
+}
+
+// assertAccepts fails the test unless ln was given an ephemeral port and accepts a
+// connection on it at host
+func assertAccepts(t *testing.T, ln net.Listener, host string) {
+	t.Helper()
+	port := ln.Addr().(*net.TCPAddr).Port
+	if port == 0 {
+		t.Fatalf("listening on %s, want an ephemeral port", ln.Addr())
+	}
+	go func() {
+		if conn, err := ln.Accept(); err == nil {
+			conn.Close()
+		}
+	}()
+	conn, err := net.Dial("tcp", net.JoinHostPort(host, strconv.Itoa(port)))
+	if err != nil {
+		t.Fatalf("dial %s: %v", host, err)
+	}
+	conn.Close()
+}
+
+func TestListen(t *testing.T) {
+	tests := []struct {
+		name        string
+		network     string
+		addr        string
+		noIPv6      bool
+		wantNetwork string
+	}{
+		{name: "tcp4", network: "tcp4", addr: "127.0.0.1:0", wantNetwork: "tcp4"},
+		{name: "tcp", network: "tcp", addr: "127.0.0.1:0", wantNetwork: "tcp"},
+		{name: "tcp6 without IPv6 falls back to tcp4", network: "tcp6", addr: ":0", noIPv6: true, wantNetwork: "tcp4"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.noIPv6 {
+				withoutIPv6(t)
+			}
+			ln, network, err := Listen(tt.network, tt.addr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer ln.Close()
+			if network != tt.wantNetwork {
+				t.Errorf("bound %s, want %s", network, tt.wantNetwork)
+			}
+			assertAccepts(t, ln, "127.0.0.1")
+		})
+	}
+}
+
+// tcp6 is dual-stack where the host has IPv6: IPv4 clients connect too
+func TestListenTCP6DualStack(t *testing.T) {
+	ln, network, err := Listen("tcp6", ":0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	switch network {
+	case "tcp6":
+		assertAccepts(t, ln, "::1")
+		assertAccepts(t, ln, "127.0.0.1")
+	case "tcp4":
+		// No IPv6 on this host
+		assertAccepts(t, ln, "127.0.0.1")
+	default:
+		t.Errorf("bound %s, want tcp6 or tcp4", network)
+	}
+}
+
+// Only tcp6 falls back: an explicit network that fails is an error
+func TestListenNoFallback(t *testing.T) {
+	ln, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	if _, network, err := Listen("tcp4", ln.Addr().String()); err == nil || network != "tcp4" {
+		t.Errorf("Listen on a port in use = %s, %v, want a tcp4 error", network, err)
+	}
+}
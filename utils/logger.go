@@ -0,0 +1,162 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"yt-downloader-go/config"
+)
+
+// Logging modules, each with its own level (LOG_LEVEL_<module>)
+const (
+	LogDownloader = "downloader" // Source downloads: chunk requests and retries
+	LogCleanup    = "cleanup"    // Cleanup runs, orphan reaper and work directories
+	LogJobs       = "jobs"       // Job runs, requeues and conversions
+	LogStream     = "stream"     // /stream sessions and previews
+	LogHTTP       = "http"       // Failed requests
+	LogExtract    = "extract"    // Extract API authentication, recordings and the extract cache
+	LogStorage    = "storage"    // Disk-full state, tombstones and the job archive
+	LogServer     = "server"     // Startup: listener, transport, ffmpeg version and migrations
+)
+
+// LogModules lists the modules whose level can be set
+var LogModules = []string{LogDownloader, LogCleanup, LogJobs, LogStream, LogHTTP, LogExtract, LogStorage, LogServer}
+
+// logLevels holds the default level and the per-module overrides in effect
+var logLevels = struct {
+	mu      sync.RWMutex
+	level   slog.Level
+	modules map[string]slog.Level
+}{modules: map[string]slog.Level{}}
+
+// logOutput writes the records of every module; moduleHandler filters them by level
+var logOutput slog.Handler = slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelDebug})
+
+// moduleHandler passes on the records of a module at or above the module's level
+type moduleHandler struct {
+	slog.Handler
+	module string
+}
+
+func (h *moduleHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= moduleLevel(h.module)
+}
+
+func (h *moduleHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &moduleHandler{Handler: h.Handler.WithAttrs(attrs), module: h.module}
+}
+
+func (h *moduleHandler) WithGroup(name string) slog.Handler {
+	return &moduleHandler{Handler: h.Handler.WithGroup(name), module: h.module}
+}
+
+// Logger returns the logger of a module; its records carry module=<name>
+func Logger(module string) *slog.Logger {
+	return slog.New(&moduleHandler{Handler: logOutput.WithAttrs([]slog.Attr{slog.String("module", module)}), module: module})
+}
+
+// InitLogging applies LOG_LEVEL and LOG_LEVEL_<module> and routes the log package (lines
+// outside the modules) through the same output at info level
+func InitLogging() {
+	logLevels.mu.Lock()
+	logLevels.level = config.LogLevel
+	for module, level := range config.LogModuleLevels {
+		logLevels.modules[module] = level
+	}
+	logLevels.mu.Unlock()
+
+	slog.SetDefault(slog.New(&moduleHandler{Handler: logOutput}))
+	for module := range config.LogModuleLevels {
+		if !slices.Contains(LogModules, module) {
+			slog.Warn("unknown log module, level ignored", "module", module, "modules", strings.Join(LogModules, ","))
+		}
+	}
+}
+
+// moduleLevel returns the level in effect for a module ("" = the default level)
+func moduleLevel(module string) slog.Level {
+	logLevels.mu.RLock()
+	defer logLevels.mu.RUnlock()
+	if level, ok := logLevels.modules[module]; ok {
+		return level
+	}
+	return logLevels.level
+}
+
+// LogLevels returns the default level and the level in effect for each module
+func LogLevels() (string, map[string]string) {
+	modules := map[string]string{}
+	for _, module := range LogModules {
+		modules[module] = levelName(moduleLevel(module))
+	}
+	logLevels.mu.RLock()
+	defer logLevels.mu.RUnlock()
+	return levelName(logLevels.level), modules
+}
+
+// SetLogLevels changes the default level (empty = unchanged) and module levels until restart
+// A module set to "" follows the default level again. Nothing changes when a value is invalid.
+func SetLogLevels(level string, modules map[string]string) error {
+	var base *slog.Level
+	if level != "" {
+		parsed, err := parseLevel(level)
+		if err != nil {
+			return fmt.Errorf("level: %w", err)
+		}
+		base = &parsed
+	}
+
+	names := make([]string, 0, len(modules))
+	for module := range modules {
+		names = append(names, module)
+	}
+	sort.Strings(names)
+	overrides := map[string]*slog.Level{}
+	for _, module := range names {
+		if !slices.Contains(LogModules, module) {
+			return fmt.Errorf("modules: unknown module %q (%s)", module, strings.Join(LogModules, ", "))
+		}
+		if modules[module] == "" {
+			overrides[module] = nil
+			continue
+		}
+		parsed, err := parseLevel(modules[module])
+		if err != nil {
+			return fmt.Errorf("modules.%s: %w", module, err)
+		}
+		overrides[module] = &parsed
+	}
+
+	logLevels.mu.Lock()
+	defer logLevels.mu.Unlock()
+	if base != nil {
+		logLevels.level = *base
+	}
+	for module, level := range overrides {
+		if level == nil {
+			delete(logLevels.modules, module)
+			continue
+		}
+		logLevels.modules[module] = *level
+	}
+	return nil
+}
+
+// parseLevel parses debug, info, warn or error
+func parseLevel(value string) (slog.Level, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(value)); err != nil {
+		return 0, fmt.Errorf("invalid log level %q (debug, info, warn, error)", value)
+	}
+	return level, nil
+}
+
+// levelName returns the lower-case name of a level (info, warn+2, ...)
+func levelName(level slog.Level) string {
+	return strings.ToLower(level.String())
+}
@@ -0,0 +1,52 @@
+package utils
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+	"yt-downloader-go/models"
+)
+
+// BuildFileManifest hashes a file in fixed-size parts plus as a whole
+func BuildFileManifest(path string, partSize int64) (*models.FileManifest, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	manifest := &models.FileManifest{
+		Size:     info.Size(),
+		PartSize: partSize,
+	}
+
+	// Get buffer from pool
+	bufPtr := GetBuffer()
+	defer PutBuffer(bufPtr)
+
+	whole := sha256.New()
+	for offset, index := int64(0), 0; offset < manifest.Size; offset, index = offset+partSize, index+1 {
+		length := min(partSize, manifest.Size-offset)
+
+		part := sha256.New()
+		if _, err := io.CopyBuffer(io.MultiWriter(part, whole), io.LimitReader(file, length), *bufPtr); err != nil {
+			return nil, err
+		}
+
+		manifest.Parts = append(manifest.Parts, models.FilePart{
+			Index:  index,
+			Offset: offset,
+			Length: length,
+			SHA256: hex.EncodeToString(part.Sum(nil)),
+		})
+	}
+	manifest.SHA256 = hex.EncodeToString(whole.Sum(nil))
+
+	return manifest, nil
+}
@@ -2,8 +2,13 @@ package utils
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
+	"time"
 	"yt-downloader-go/config"
 	"yt-downloader-go/models"
 )
@@ -19,7 +24,18 @@ func GetMetaPath(jobID string) string {
 }
 
 // ReadMeta reads the meta.json file for a job
+// Files of an older schema are upgraded in memory; the next write stores the upgrade.
 func ReadMeta(jobID string) (*models.Meta, error) {
+	meta, err := readMetaFile(jobID)
+	if err != nil {
+		return nil, err
+	}
+	migrateMeta(meta)
+	return meta, nil
+}
+
+// readMetaFile reads meta.json as written, without migration
+func readMetaFile(jobID string) (*models.Meta, error) {
 	data, err := os.ReadFile(GetMetaPath(jobID))
 	if err != nil {
 		return nil, err
@@ -34,65 +50,415 @@ func ReadMeta(jobID string) (*models.Meta, error) {
 }
 
 // WriteMeta writes the meta.json file for a job
+// Writes to a temp file and renames so readers never see a partial file. The schema
+// version of a newer build is kept, so it doesn't migrate its own fields again.
 func WriteMeta(jobID string, meta *models.Meta) error {
+	return writeMetaFile(GetMetaPath(jobID), meta)
+}
+
+// writeMetaFile writes meta to metaPath (temp file and rename)
+func writeMetaFile(metaPath string, meta *models.Meta) error {
+	meta.SchemaVersion = max(meta.SchemaVersion, models.MetaSchemaVersion)
 	data, err := json.MarshalIndent(meta, "", "  ")
 	if err != nil {
 		return err
 	}
 
-	return os.WriteFile(GetMetaPath(jobID), data, 0644)
+	tmpPath := metaPath + ".new" // not *.tmp, which CleanupTempFiles removes
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, metaPath)
+}
+
+// MetaMissing reports whether a ReadMeta error means the job has no usable meta.json
+// (never written or corrupt); handlers treat such a job as not found
+func MetaMissing(err error) bool {
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	return os.IsNotExist(err) || errors.As(err, &syntaxErr) || errors.As(err, &typeErr)
 }
 
-// UpdateMetaStatus updates the status field
-func UpdateMetaStatus(jobID string, status string) error {
-	meta, err := ReadMeta(jobID)
-	if err != nil {
-		return err
+// metaMigrations upgrade a meta from schema version i to i+1 (index i)
+var metaMigrations = []func(meta *models.Meta){
+	migrateMetaV0,
+}
+
+// migrateMeta upgrades a meta of an older schema in memory; newer ones are left as they are
+func migrateMeta(meta *models.Meta) {
+	for meta.SchemaVersion < models.MetaSchemaVersion {
+		metaMigrations[meta.SchemaVersion](meta)
+		meta.SchemaVersion++
 	}
-	meta.Status = status
-	return WriteMeta(jobID, meta)
 }
 
-// UpdateMetaError updates status to error with message
-func UpdateMetaError(jobID string, errMsg string) error {
-	meta, err := ReadMeta(jobID)
+// migrateMetaV0 fills in what unversioned metas may lack: expiresAt (before job extension),
+// displayFilename of completed jobs (before it was fixed at completion) and jobErrorDetail of
+// failed jobs (before structured errors, which only kept the message)
+func migrateMetaV0(meta *models.Meta) {
+	if meta.ExpiresAt == 0 {
+		meta.ExpiresAt = time.UnixMilli(meta.CreatedAt).Add(config.MaxJobAge).UnixMilli()
+	}
+	if meta.Status == models.StatusCompleted && meta.DisplayFilename == "" {
+		meta.DisplayFilename = GenerateOutputFilename(meta)
+	}
+	if meta.Status == models.StatusError && meta.JobError == nil {
+		jobErr := &models.JobError{Code: models.JobErrInternal, Message: meta.Error, Phase: models.PhaseProcessing}
+		switch {
+		case strings.HasPrefix(meta.Error, "Download failed"):
+			jobErr.Code, jobErr.Phase, jobErr.Retryable = models.JobErrDownloadFailed, models.PhaseDownload, true
+		case strings.HasPrefix(meta.Error, "Processing failed"), strings.HasPrefix(meta.Error, "Trim failed"):
+			jobErr.Code = models.JobErrFFmpegFailed
+		}
+		meta.JobError = jobErr
+	}
+}
+
+// MigrateMetas rewrites every meta.json of an older schema (META_MIGRATE_ON_START)
+// Returns the number of jobs rewritten.
+func MigrateMetas() int {
+	entries, err := os.ReadDir(config.StorageDir)
 	if err != nil {
-		return err
+		return 0
 	}
-	meta.Status = models.StatusError
-	meta.Error = errMsg
-	return WriteMeta(jobID, meta)
+
+	migrated := 0
+	for _, entry := range entries {
+		if !entry.IsDir() || !ValidateJobID(entry.Name()) {
+			continue
+		}
+		meta, err := readMetaFile(entry.Name())
+		if err != nil || meta.SchemaVersion >= models.MetaSchemaVersion {
+			continue
+		}
+		// ReadMeta inside UpdateMeta migrates; writing stores it
+		if err := UpdateMeta(entry.Name(), func(meta *models.Meta) {}); err == nil {
+			migrated++
+		}
+	}
+	return migrated
 }
 
-// UpdateMetaOutput updates the output filename
-func UpdateMetaOutput(jobID string, output string) error {
+// metaLocks holds a mutex per job so concurrent read-modify-write updates don't lose writes
+var metaLocks sync.Map
+
+// UpdateMeta applies fn to the job metadata atomically
+func UpdateMeta(jobID string, fn func(meta *models.Meta)) error {
+	lock, _ := metaLocks.LoadOrStore(jobID, &sync.Mutex{})
+	mu := lock.(*sync.Mutex)
+	mu.Lock()
+	defer mu.Unlock()
+
 	meta, err := ReadMeta(jobID)
 	if err != nil {
 		return err
 	}
-	meta.Status = models.StatusCompleted
-	meta.Output = output
+	fn(meta)
 	return WriteMeta(jobID, meta)
 }
 
+// UpdateMetaStatus updates the status field
+func UpdateMetaStatus(jobID string, status string) error {
+	return UpdateMeta(jobID, func(meta *models.Meta) {
+		meta.Status = status
+	})
+}
+
+// UpdateMetaError updates status to error with a structured error
+// The plain message is kept in Error for older clients
+func UpdateMetaError(jobID string, jobErr *models.JobError) error {
+	return UpdateMeta(jobID, func(meta *models.Meta) {
+		meta.Status = models.StatusError
+		meta.Error = jobErr.Message
+		meta.JobError = jobErr
+
+		// Outputs sharing the failed download or run fail with it
+		for i := range meta.Outputs {
+			if output := &meta.Outputs[i]; output.Status == models.StatusPending || output.Status == models.StatusProcessing {
+				output.Status = models.StatusError
+				output.Error = jobErr.Message
+			}
+		}
+	})
+}
+
+// UpdateMetaRequeued resets a job to pending for another run (admin requeue)
+// Failed additional outputs are retried with the job.
+func UpdateMetaRequeued(jobID string, files models.FilesInfo, selection *models.StreamSelection) error {
+	return UpdateMeta(jobID, func(meta *models.Meta) {
+		meta.Status = models.StatusPending
+		meta.Error = ""
+		meta.JobError = nil
+		meta.Files = files
+		meta.Selection = selection
+		for i := range meta.Outputs {
+			if output := &meta.Outputs[i]; output.Status != models.StatusCompleted {
+				output.Status = models.StatusPending
+				output.Error = ""
+			}
+		}
+	})
+}
+
+// UpdateMetaOutput updates the output filename and whether it was written with +faststart
+// The display filename is fixed at completion so it never drifts from the disk name
+func UpdateMetaOutput(jobID string, output string, faststart bool) error {
+	AddStorageUsage(UsageJobs, GetFileSize(filepath.Join(GetJobDir(jobID), output)))
+	return UpdateMeta(jobID, func(meta *models.Meta) {
+		meta.Status = models.StatusCompleted
+		meta.Output = output
+		meta.Faststart = faststart
+		meta.DisplayFilename = GenerateOutputFilename(meta)
+	})
+}
+
+// ExtraOutputName returns the on-disk name of the additional output at index (output_2.mp3, ...)
+func ExtraOutputName(index int, format string) string {
+	return fmt.Sprintf("output_%d.%s", index+2, format)
+}
+
+// AddMetaExtraOutput appends a pending additional output, named output_<n>.<format>
+// Returns the output's index and name.
+func AddMetaExtraOutput(jobID string, output models.ExtraOutput) (int, string, error) {
+	var index int
+	err := UpdateMeta(jobID, func(meta *models.Meta) {
+		index = len(meta.Outputs)
+		output.Name = ExtraOutputName(index, output.Format)
+		output.Status = models.StatusPending
+		meta.Outputs = append(meta.Outputs, output)
+	})
+	return index, output.Name, err
+}
+
+// UpdateMetaExtraOutput sets an additional output's status (and error message, if any)
+func UpdateMetaExtraOutput(jobID string, index int, status string, errMessage string) error {
+	return UpdateMeta(jobID, func(meta *models.Meta) {
+		if index >= len(meta.Outputs) {
+			return
+		}
+		output := &meta.Outputs[index]
+		output.Status = status
+		output.Error = errMessage
+		if status == models.StatusCompleted {
+			AddStorageUsage(UsageJobs, GetFileSize(filepath.Join(GetJobDir(jobID), output.Name)))
+			output.DisplayFilename = GenerateOutputFilename(&models.Meta{
+				Title:      meta.Title,
+				Author:     meta.Author,
+				UploadDate: meta.UploadDate,
+				VideoID:    meta.VideoID,
+				OutputType: output.OutputType,
+				Format:     output.Format,
+				Quality:    meta.Quality,
+				Bitrate:    output.Bitrate,
+				AudioCodec: output.AudioCodec,
+				Trim:       output.Trim,
+			})
+		}
+	})
+}
+
+// UpdateMetaManifest stores the part hashes of the output file
+func UpdateMetaManifest(jobID string, manifest *models.FileManifest) error {
+	return UpdateMeta(jobID, func(meta *models.Meta) {
+		meta.Manifest = manifest
+	})
+}
+
+// UpdateMetaSilenceTrim records detected silence boundaries and the implicit trim they produce
+func UpdateMetaSilenceTrim(jobID string, silence *models.SilenceTrim, trim *models.TrimConfig, outputDuration float64) error {
+	return UpdateMeta(jobID, func(meta *models.Meta) {
+		meta.SilenceTrim = silence
+		meta.Trim = trim
+		meta.OutputDuration = outputDuration
+	})
+}
+
+// UpdateMetaRotation records the detected rotation of a video job and its handling
+func UpdateMetaRotation(jobID string, rotation *models.VideoRotation) error {
+	return UpdateMeta(jobID, func(meta *models.Meta) {
+		meta.Rotation = rotation
+	})
+}
+
+// UpdateMetaAudioChannels records the detected channels of a job's audio and their handling
+func UpdateMetaAudioChannels(jobID string, channels *models.AudioChannels) error {
+	return UpdateMeta(jobID, func(meta *models.Meta) {
+		meta.AudioChannels = channels
+	})
+}
+
+// UpdateMetaTranscriptAvailable records that the transcript files were written
+func UpdateMetaTranscriptAvailable(jobID string) error {
+	return UpdateMeta(jobID, func(meta *models.Meta) {
+		if meta.Transcript != nil {
+			meta.Transcript.Available = true
+		}
+	})
+}
+
 // UpdateMetaStreamOnly marks the job as completed for streaming (no merge)
 func UpdateMetaStreamOnly(jobID string) error {
-	meta, err := ReadMeta(jobID)
+	return UpdateMeta(jobID, func(meta *models.Meta) {
+		meta.Status = models.StatusCompleted
+		meta.StreamOnly = true
+		meta.Warnings.Add(models.WarnStreamOnly, "", models.StreamOnlyMessage)
+		meta.DisplayFilename = GenerateOutputFilename(meta)
+	})
+}
+
+// AppendMetaFFmpegCommand records an ffmpeg invocation of the job, keeping the newest MaxFFmpegCommands
+func AppendMetaFFmpegCommand(jobID string, version string, command models.FFmpegCommand) error {
+	return UpdateMeta(jobID, func(meta *models.Meta) {
+		meta.FFmpegVersion = version
+		meta.FFmpegCommands = append(meta.FFmpegCommands, command)
+		if over := len(meta.FFmpegCommands) - config.MaxFFmpegCommands; over > 0 {
+			meta.FFmpegCommands = meta.FFmpegCommands[over:]
+		}
+	})
+}
+
+// AddMetaOriginBytes adds bytes downloaded from origin to the job usage
+func AddMetaOriginBytes(jobID string, n int64) error {
+	return UpdateMeta(jobID, func(meta *models.Meta) {
+		meta.Usage.OriginBytes += n
+		usageOriginBytes.Add(UsageAccount(meta), n)
+	})
+}
+
+// AddMetaServedBytes records one transfer of n bytes to a client
+func AddMetaServedBytes(jobID string, n int64) error {
+	return UpdateMeta(jobID, func(meta *models.Meta) {
+		meta.Usage.ServedBytes += n
+		meta.Usage.ServedCount++
+		usageServedBytes.Add(UsageAccount(meta), n)
+	})
+}
+
+// UpdateMetaStreamError records a /stream transfer that failed after n body bytes
+func UpdateMetaStreamError(jobID string, message string, n int64) error {
+	return UpdateMeta(jobID, func(meta *models.Meta) {
+		meta.LastStreamError = &models.StreamError{
+			Error:     message,
+			BytesSent: n,
+			At:        time.Now().UnixMilli(),
+		}
+	})
+}
+
+// ListJobMetas reads the metadata of every job on disk (unreadable jobs are skipped)
+func ListJobMetas() ([]*models.Meta, error) {
+	entries, err := os.ReadDir(config.StorageDir)
+	if err != nil {
+		return nil, err
+	}
+
+	var metas []*models.Meta
+	for _, entry := range entries {
+		if !entry.IsDir() || !ValidateJobID(entry.Name()) {
+			continue
+		}
+		if meta, err := ReadMeta(entry.Name()); err == nil {
+			metas = append(metas, meta)
+		}
+	}
+	return metas, nil
+}
+
+// IsServableFile reports whether filename is a finished output declared in meta
+// Anything else in the job dir (meta.json, sources, *.tmp, logs) is never served.
+func IsServableFile(meta *models.Meta, filename string) bool {
+	if meta.Output != "" && filename == meta.Output {
+		return true
+	}
+	for _, output := range meta.Outputs {
+		if output.Name == filename && output.Status == models.StatusCompleted {
+			return true
+		}
+	}
+	if meta.Transcript != nil && meta.Transcript.Available {
+		return filename == config.TranscriptVTTName || filename == config.TranscriptTextName
+	}
+	return false
+}
+
+// JobExpiresAt returns when cleanup removes the job
+// Jobs written before expiresAt existed expire MaxJobAge after creation.
+func JobExpiresAt(meta *models.Meta) time.Time {
+	if meta.ExpiresAt > 0 {
+		return time.UnixMilli(meta.ExpiresAt)
+	}
+	return time.UnixMilli(meta.CreatedAt).Add(config.MaxJobAge)
+}
+
+// JobMaxExpiresAt returns the latest expiry an extension can reach
+func JobMaxExpiresAt(meta *models.Meta) time.Time {
+	return time.UnixMilli(meta.CreatedAt).Add(config.MaxJobLifetime)
+}
+
+// UpdateMetaDeleted soft-deletes (deletedAt > 0) or restores (deletedAt = 0) a job
+func UpdateMetaDeleted(jobID string, deletedAt int64) error {
+	return UpdateMeta(jobID, func(meta *models.Meta) {
+		meta.DeletedAt = deletedAt
+	})
+}
+
+// IsDeleted reports whether the job was soft-deleted
+func IsDeleted(meta *models.Meta) bool {
+	return meta.DeletedAt > 0
+}
+
+// ErrJobExists is returned by CreateJob when the job directory already exists
+var ErrJobExists = errors.New("job directory already exists")
+
+// CreateJob creates the job directory with its meta.json
+// The directory is prepared under StagingDir and renamed into place, so a job directory
+// never exists without its meta (a crash leaves only the staged one, removed by cleanup).
+// An existing directory, also one matching only case-insensitively, is ErrJobExists.
+func CreateJob(jobID string, meta *models.Meta) error {
+	if JobExists(jobID) {
+		return ErrJobExists
+	}
+	if err := os.MkdirAll(config.StagingDir, 0755); err != nil {
+		return err
+	}
+	stagedDir, err := os.MkdirTemp(config.StagingDir, jobID+"-")
 	if err != nil {
 		return err
 	}
-	meta.Status = models.StatusCompleted
-	meta.StreamOnly = true
-	return WriteMeta(jobID, meta)
+	if err := os.Chmod(stagedDir, 0755); err != nil {
+		os.RemoveAll(stagedDir)
+		return err
+	}
+
+	if err := writeMetaFile(filepath.Join(stagedDir, "meta.json"), meta); err != nil {
+		os.RemoveAll(stagedDir)
+		return err
+	}
+	if err := os.Rename(stagedDir, GetJobDir(jobID)); err != nil {
+		os.RemoveAll(stagedDir)
+		return err
+	}
+	return nil
 }
 
-// CreateJobDir creates the job directory
-func CreateJobDir(jobID string) error {
-	return os.MkdirAll(GetJobDir(jobID), 0755)
+// cleanupStagedJobs removes job directories left under StagingDir by a crashed CreateJob
+func cleanupStagedJobs() {
+	entries, err := os.ReadDir(config.StagingDir)
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err == nil && time.Since(info.ModTime()) > time.Minute {
+			os.RemoveAll(filepath.Join(config.StagingDir, entry.Name()))
+		}
+	}
 }
 
-// DeleteJobDir deletes the job directory and all contents
+// DeleteJobDir deletes the job directory and all contents, and the job's work directory
 func DeleteJobDir(jobID string) error {
+	metaLocks.Delete(jobID)
+	RemoveWorkDir(jobID)
 	return os.RemoveAll(GetJobDir(jobID))
 }
 
@@ -121,7 +487,17 @@ func getDownloadedSize(jobDir, fileName string, expectedSize int64) int64 {
 		return expectedSize
 	}
 
-	// 2. Chunks dir exists = downloading
+	// 2. Active download in this process: bytes counted as they are written
+	if written, ok := trackedDownloadSize(basePath); ok {
+		return written
+	}
+
+	// 3. Sparse download sidecar = downloading or resumable (another process)
+	if state := ReadDownloadState(basePath + config.SparseSidecarSuffix); state != nil {
+		return state.WrittenBytes()
+	}
+
+	// 4. Chunks dir exists = downloading (legacy, another process)
 	chunksDir := basePath + ".chunks"
 	if info, err := os.Stat(chunksDir); err == nil && info.IsDir() {
 		var total int64
@@ -137,7 +513,7 @@ func getDownloadedSize(jobDir, fileName string, expectedSize int64) int64 {
 		return total
 	}
 
-	// 3. Tmp file exists (no chunks dir) = merging = download done
+	// 5. Tmp file exists (no chunks dir) = merging = download done
 	if GetFileSize(basePath+".tmp") > 0 {
 		return expectedSize
 	}
@@ -145,21 +521,36 @@ func getDownloadedSize(jobDir, fileName string, expectedSize int64) int64 {
 	return 0
 }
 
+// getFileProgressSize returns downloaded bytes for a job file,
+// following it into the shared source cache while it is being fetched there
+func getFileProgressSize(jobDir string, file *models.FileInfo) int64 {
+	size := getDownloadedSize(jobDir, file.Name, file.Size)
+	if size == 0 && file.Source != "" {
+		size = getDownloadedSize(config.SourceWorkDir, file.Source, file.Size)
+	}
+	// Finished, not yet linked into the job (TEMP_DIR)
+	if size == 0 && file.Source != "" && config.SourceWorkDir != config.SourceCacheDir {
+		size = getDownloadedSize(config.SourceCacheDir, file.Source, file.Size)
+	}
+	return size
+}
+
 // CalculateProgress calculates download progress from file sizes
 func CalculateProgress(meta *models.Meta) int {
-	if meta.Status == models.StatusCompleted {
+	// Downloads are finished once processing starts
+	if meta.Status == models.StatusCompleted || meta.Status == models.StatusProcessing {
 		return 100
 	}
 	if meta.Status == models.StatusError {
 		return 0
 	}
 
-	jobDir := GetJobDir(meta.ID)
+	jobDir := GetWorkDir(meta.ID)
 
 	if meta.OutputType == "video" && meta.Files.Video != nil && meta.Files.Audio != nil {
 		// Video + Audio download
-		videoSize := getDownloadedSize(jobDir, meta.Files.Video.Name, meta.Files.Video.Size)
-		audioSize := getDownloadedSize(jobDir, meta.Files.Audio.Name, meta.Files.Audio.Size)
+		videoSize := getFileProgressSize(jobDir, meta.Files.Video)
+		audioSize := getFileProgressSize(jobDir, meta.Files.Audio)
 
 		videoProgress := 0
 		audioProgress := 0
@@ -178,7 +569,7 @@ func CalculateProgress(meta *models.Meta) int {
 		return min(progress, 100)
 	} else if meta.Files.Audio != nil {
 		// Audio only
-		audioSize := getDownloadedSize(jobDir, meta.Files.Audio.Name, meta.Files.Audio.Size)
+		audioSize := getFileProgressSize(jobDir, meta.Files.Audio)
 
 		audioProgress := 0
 		if meta.Files.Audio.Size > 0 {
@@ -186,6 +577,16 @@ func CalculateProgress(meta *models.Meta) int {
 		}
 
 		return min(audioProgress, 100)
+	} else if meta.Files.Video != nil {
+		// Video only (audio.mute)
+		videoSize := getFileProgressSize(jobDir, meta.Files.Video)
+
+		videoProgress := 0
+		if meta.Files.Video.Size > 0 {
+			videoProgress = int(float64(videoSize) / float64(meta.Files.Video.Size) * 100)
+		}
+
+		return min(videoProgress, 100)
 	}
 
 	return 0
@@ -0,0 +1,307 @@
+package utils
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+	"yt-downloader-go/config"
+	"yt-downloader-go/models"
+)
+
+// useTempStorage points StorageDir at a temp dir for the duration of the test
+func useTempStorage(t *testing.T) {
+	t.Helper()
+	prev := config.StorageDir
+	config.SetStorageDir(t.TempDir())
+	t.Cleanup(func() { config.SetStorageDir(prev) })
+}
+
+// installMetaFixture copies testdata/meta/<name> to the meta.json of testJobID
+func installMetaFixture(t *testing.T, name string) {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", "meta", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(GetJobDir(testJobID), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(GetMetaPath(testJobID), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// readRawMeta returns the top-level fields of testJobID's meta.json as written
+func readRawMeta(t *testing.T) map[string]json.RawMessage {
+	t.Helper()
+	data, err := os.ReadFile(GetMetaPath(testJobID))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		t.Fatal(err)
+	}
+	return fields
+}
+
+func TestReadMetaMigratesV0(t *testing.T) {
+	const createdAt = 1700000000000
+	wantExpires := time.UnixMilli(createdAt).Add(config.MaxJobAge).UnixMilli()
+
+	tests := []struct {
+		fixture  string
+		expires  int64
+		filename string
+		jobErr   *models.JobError
+	}{
+		{
+			fixture:  "v0_completed.json",
+			expires:  wantExpires,
+			filename: "Never_Gonna_Give_You_Up_192k.mp3",
+		},
+		{
+			fixture: "v0_download_error.json",
+			expires: wantExpires,
+			jobErr: &models.JobError{
+				Code:      models.JobErrDownloadFailed,
+				Message:   "Download failed: chunk 3: unexpected EOF",
+				Phase:     models.PhaseDownload,
+				Retryable: true,
+			},
+		},
+		{
+			fixture: "v0_processing_error.json",
+			expires: 1700086400000, // set in the file: kept
+			jobErr: &models.JobError{
+				Code:    models.JobErrFFmpegFailed,
+				Message: "Processing failed: exit status 1",
+				Phase:   models.PhaseProcessing,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			useTempStorage(t)
+			installMetaFixture(t, tt.fixture)
+
+			meta, err := ReadMeta(testJobID)
+			if err != nil {
+				t.Fatalf("ReadMeta: %v", err)
+			}
+			if meta.SchemaVersion != models.MetaSchemaVersion {
+				t.Errorf("SchemaVersion = %d, want %d", meta.SchemaVersion, models.MetaSchemaVersion)
+			}
+			if meta.ExpiresAt != tt.expires {
+				t.Errorf("ExpiresAt = %d, want %d", meta.ExpiresAt, tt.expires)
+			}
+			if meta.DisplayFilename != tt.filename {
+				t.Errorf("DisplayFilename = %q, want %q", meta.DisplayFilename, tt.filename)
+			}
+			switch {
+			case tt.jobErr == nil && meta.JobError != nil:
+				t.Errorf("JobError = %+v, want none", *meta.JobError)
+			case tt.jobErr != nil && (meta.JobError == nil || *meta.JobError != *tt.jobErr):
+				t.Errorf("JobError = %+v, want %+v", meta.JobError, *tt.jobErr)
+			}
+
+			// Reading migrates in memory only; the file stays unversioned until written
+			if _, ok := readRawMeta(t)["schemaVersion"]; ok {
+				t.Error("ReadMeta rewrote meta.json")
+			}
+		})
+	}
+}
+
+func TestMigrateMetas(t *testing.T) {
+	useTempStorage(t)
+	installMetaFixture(t, "v0_completed.json")
+
+	if n := MigrateMetas(); n != 1 {
+		t.Fatalf("MigrateMetas = %d, want 1", n)
+	}
+	fields := readRawMeta(t)
+	if string(fields["schemaVersion"]) != "1" || string(fields["displayFilename"]) != `"Never_Gonna_Give_You_Up_192k.mp3"` {
+		t.Errorf("migrated meta.json: schemaVersion %s, displayFilename %s", fields["schemaVersion"], fields["displayFilename"])
+	}
+
+	if n := MigrateMetas(); n != 0 {
+		t.Errorf("second MigrateMetas = %d, want 0", n)
+	}
+}
+
+func TestUpdateMetaKeepsUnknownFields(t *testing.T) {
+	useTempStorage(t)
+	installMetaFixture(t, "v2_unknown_fields.json")
+	before := readRawMeta(t)
+
+	meta, err := ReadMeta(testJobID)
+	if err != nil {
+		t.Fatalf("ReadMeta: %v", err)
+	}
+	if meta.SchemaVersion != 2 {
+		t.Errorf("SchemaVersion = %d, want 2 (newer files aren't migrated)", meta.SchemaVersion)
+	}
+	if len(meta.Unknown) != 2 {
+		t.Errorf("Unknown = %v, want loudness and priorityClass", meta.Unknown)
+	}
+
+	if err := UpdateMetaStatus(testJobID, models.StatusCompleted); err != nil {
+		t.Fatalf("UpdateMetaStatus: %v", err)
+	}
+	after := readRawMeta(t)
+	for _, name := range []string{"loudness", "priorityClass"} {
+		var want, got any
+		json.Unmarshal(before[name], &want)
+		json.Unmarshal(after[name], &got)
+		if string(mustMarshal(t, got)) != string(mustMarshal(t, want)) {
+			t.Errorf("%s = %s after the update, want %s", name, after[name], before[name])
+		}
+	}
+	if string(after["schemaVersion"]) != "2" {
+		t.Errorf("schemaVersion = %s after the update, want 2", after["schemaVersion"])
+	}
+	if string(after["status"]) != `"completed"` {
+		t.Errorf("status = %s, want the update applied", after["status"])
+	}
+}
+
+// mustMarshal encodes v compactly, with sorted object keys
+func mustMarshal(t *testing.T, v any) []byte {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestIsServableFile(t *testing.T) {
+	meta := &models.Meta{
+		Output: "output.mp3",
+		Outputs: []models.ExtraOutput{
+			{Name: "output_2.flac", Status: models.StatusCompleted},
+			{Name: "output_3.m4a", Status: models.StatusProcessing},
+		},
+	}
+	withTranscript := *meta
+	withTranscript.Transcript = &models.Transcript{Available: true}
+
+	tests := []struct {
+		meta     *models.Meta
+		filename string
+		want     bool
+	}{
+		{meta, "output.mp3", true},
+		{meta, "output_2.flac", true},
+		{meta, "output_3.m4a", false}, // Not finished
+		{meta, "meta.json", false},
+		{meta, "run.lock", false},
+		{meta, "audio.webm", false},
+		{meta, "audio.webm.chunks", false},
+		{meta, "output.mp3.part", false},
+		{meta, config.TranscriptVTTName, false},
+		{&withTranscript, config.TranscriptVTTName, true},
+		{&withTranscript, config.TranscriptTextName, true},
+		{&withTranscript, "meta.json", false},
+		{&models.Meta{}, "", false},
+	}
+	for _, tt := range tests {
+		if got := IsServableFile(tt.meta, tt.filename); got != tt.want {
+			t.Errorf("IsServableFile(%q) with transcript %t = %t, want %t", tt.filename, tt.meta.Transcript != nil, got, tt.want)
+		}
+	}
+}
+
+func TestCreateJob(t *testing.T) {
+	useTempStorage(t)
+	meta := &models.Meta{ID: testJobID, Status: models.StatusPending}
+	if err := CreateJob(testJobID, meta); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := ReadMeta(testJobID); err != nil || got.Status != models.StatusPending {
+		t.Fatalf("ReadMeta = %+v, %v", got, err)
+	}
+	if staged, _ := os.ReadDir(config.StagingDir); len(staged) != 0 {
+		t.Errorf("%d directories left staged", len(staged))
+	}
+
+	// The directory is already in place: a second creation fails and leaves nothing staged
+	if err := CreateJob(testJobID, &models.Meta{ID: testJobID, Status: models.StatusError}); err == nil {
+		t.Error("CreateJob over an existing job succeeded")
+	}
+	if got, _ := ReadMeta(testJobID); got == nil || got.Status != models.StatusPending {
+		t.Errorf("existing meta replaced: %+v", got)
+	}
+	if staged, _ := os.ReadDir(config.StagingDir); len(staged) != 0 {
+		t.Errorf("%d directories left staged", len(staged))
+	}
+}
+
+func TestMetaMissing(t *testing.T) {
+	tests := []struct {
+		name    string
+		meta    string // "" for no meta.json
+		missing bool
+	}{
+		{name: "no meta", missing: true},
+		{name: "empty", meta: " ", missing: true},
+		{name: "truncated", meta: `{"id":"` + testJobID + `","status":"pend`, missing: true},
+		{name: "wrong type", meta: `{"id":"` + testJobID + `","createdAt":"yesterday"}`, missing: true},
+		{name: "readable", meta: `{"id":"` + testJobID + `","status":"pending"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useTempStorage(t)
+			if err := os.MkdirAll(GetJobDir(testJobID), 0755); err != nil {
+				t.Fatal(err)
+			}
+			if tt.meta != "" {
+				if err := os.WriteFile(GetMetaPath(testJobID), []byte(tt.meta), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			_, err := ReadMeta(testJobID)
+			if missing := err != nil && MetaMissing(err); missing != tt.missing {
+				t.Errorf("MetaMissing(%v) = %t, want %t", err, missing, tt.missing)
+			}
+		})
+	}
+}
+
+// Staged directories of a crashed creation are removed once a minute old, never while a
+// creation may still be moving them into place; job directories without a meta go at once
+func TestCleanupCreationLeftovers(t *testing.T) {
+	useTempStorage(t)
+	staged := func(name string, age time.Duration) string {
+		dir := filepath.Join(config.StagingDir, name)
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		modTime := time.Now().Add(-age)
+		if err := os.Chtimes(dir, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+		return dir
+	}
+	fresh := staged(testJobID+"-1", 0)
+	inWindow := staged(testJobID+"-2", time.Minute-5*time.Second)
+	crashed := staged(testJobID+"-3", time.Minute+5*time.Second)
+	if err := os.MkdirAll(GetJobDir(testJobID), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	CleanupOldJobs()
+	for dir, kept := range map[string]bool{fresh: true, inWindow: true, crashed: false} {
+		if _, err := os.Stat(dir); (err == nil) != kept {
+			t.Errorf("%s: kept %t, want %t", filepath.Base(dir), err == nil, kept)
+		}
+	}
+	if JobExists(testJobID) {
+		t.Error("job directory without a meta kept")
+	}
+}
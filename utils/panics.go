@@ -0,0 +1,30 @@
+package utils
+
+import (
+	"expvar"
+	"fmt"
+	"log/slog"
+	"runtime/debug"
+)
+
+// panicsRecovered counts panics recovered in background goroutines (exported via /debug/vars)
+var panicsRecovered = expvar.NewInt("panics_recovered")
+
+// PanicError logs a value recovered in where with the goroutine's stack, counts it and
+// returns it as an error. Call it from the deferred function that recovered the value;
+// attrs are added to the log line (e.g. "job", jobID).
+func PanicError(where string, r any, attrs ...any) error {
+	panicsRecovered.Add(1)
+	attrs = append([]any{"where", where, "panic", fmt.Sprint(r)}, attrs...)
+	slog.Error("panic recovered", append(attrs, "stack", string(debug.Stack()))...)
+	return fmt.Errorf("panic in %s: %v", where, r)
+}
+
+// RecoverPanic recovers a panic of the calling goroutine and logs it (PanicError), so one
+// failing background task doesn't crash the process. Defer it directly:
+// defer utils.RecoverPanic("cleanup").
+func RecoverPanic(where string) {
+	if r := recover(); r != nil {
+		PanicError(where, r)
+	}
+}
@@ -0,0 +1,113 @@
+package utils
+
+import (
+	"encoding/json"
+	"expvar"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"yt-downloader-go/config"
+)
+
+// DownloadTracker counts an active chunked download's written bytes and busy workers
+type DownloadTracker struct {
+	Written atomic.Int64
+	Workers atomic.Int32
+}
+
+// activeDownloads maps a destination path to its tracker while the download runs
+// Status polls read it instead of listing the chunk directory.
+var activeDownloads sync.Map
+
+// Live memory counters (expvar)
+var (
+	buffersInUse      = expvar.NewInt("buffers_in_use")
+	DownloadFilesOpen = expvar.NewInt("download_files_open") // Chunk, download and merge files being written or read
+)
+
+func init() {
+	// Estimated buffer memory per active download: each busy worker holds a
+	// pooled copy buffer and the transport's read buffer
+	expvar.Publish("download_memory_estimate", expvar.Func(func() any {
+		estimates := map[string]int64{}
+		activeDownloads.Range(func(key, value any) bool {
+			workers := int64(value.(*DownloadTracker).Workers.Load())
+			estimates[key.(string)] = workers * (config.BufferSize + config.DownloadReadBufferSize)
+			return true
+		})
+		return estimates
+	}))
+}
+
+// TrackDownload registers an active download of destPath until done is called
+func TrackDownload(destPath string) (tracker *DownloadTracker, done func()) {
+	key := filepath.Clean(destPath)
+	tracker = &DownloadTracker{}
+	activeDownloads.Store(key, tracker)
+	return tracker, func() { activeDownloads.Delete(key) }
+}
+
+// trackedDownloadSize returns bytes written so far if destPath is being downloaded
+func trackedDownloadSize(destPath string) (int64, bool) {
+	value, ok := activeDownloads.Load(filepath.Clean(destPath))
+	if !ok {
+		return 0, false
+	}
+	return value.(*DownloadTracker).Written.Load(), true
+}
+
+// GetBuffer checks out a BufferSize copy buffer from the pool (return it with PutBuffer)
+func GetBuffer() *[]byte {
+	buffersInUse.Add(1)
+	return config.BufferPool.Get().(*[]byte)
+}
+
+// PutBuffer returns a buffer from GetBuffer to the pool
+func PutBuffer(buf *[]byte) {
+	config.BufferPool.Put(buf)
+	buffersInUse.Add(-1)
+}
+
+// DownloadState is the sidecar of a sparse download: the chunks already written
+type DownloadState struct {
+	TotalSize int64 `json:"totalSize"`
+	ChunkSize int64 `json:"chunkSize"`
+	Done      []int `json:"done"`
+}
+
+// ReadDownloadState reads a sparse download sidecar, nil if missing or invalid
+func ReadDownloadState(path string) *DownloadState {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	var state DownloadState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil
+	}
+	return &state
+}
+
+// WriteDownloadState atomically replaces a sparse download sidecar
+func WriteDownloadState(path string, state *DownloadState) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	tmpPath := path + ".new"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, path)
+}
+
+// WrittenBytes returns the bytes covered by the completed chunks
+func (s *DownloadState) WrittenBytes() int64 {
+	var total int64
+	for _, idx := range s.Done {
+		start := int64(idx) * s.ChunkSize
+		total += max(min(s.ChunkSize, s.TotalSize-start), 0)
+	}
+	return total
+}
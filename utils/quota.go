@@ -0,0 +1,107 @@
+package utils
+
+import (
+	"expvar"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"syscall"
+	"yt-downloader-go/config"
+)
+
+// Storage usage categories counted toward STORAGE_QUOTA_MB
+const (
+	UsageJobs    = "jobs"    // Job directories: outputs, retained sources and extra files
+	UsageSources = "sources" // The shared source cache
+	UsageHLS     = "hls"     // HLS playlists and segments in job directories
+)
+
+// usageCategories lists the categories in the order they are counted (hard links count once, in the first)
+var usageCategories = []string{UsageSources, UsageHLS, UsageJobs}
+
+// storageUsed holds the bytes used per category (exported via /debug/vars as storage_used_bytes).
+// Finished files are added as they land; each cleanup run recounts it from disk, which also
+// takes removals into account.
+var storageUsed = expvar.NewMap("storage_used_bytes")
+
+// AddStorageUsage adds the bytes of a file written under StorageDir to a category
+func AddStorageUsage(category string, bytes int64) {
+	if bytes > 0 {
+		storageUsed.Add(category, bytes)
+	}
+}
+
+// StorageUsedBytes returns the bytes used by all categories
+func StorageUsedBytes() int64 {
+	var total int64
+	for _, category := range usageCategories {
+		if v, ok := storageUsed.Get(category).(*expvar.Int); ok {
+			total += v.Value()
+		}
+	}
+	return total
+}
+
+// StorageQuotaExceeded reports whether STORAGE_QUOTA_MB is set and used up
+func StorageQuotaExceeded() bool {
+	return config.StorageQuota > 0 && StorageUsedBytes() >= config.StorageQuota
+}
+
+// ScanStorageUsage recounts the bytes used per category from disk. Sources hard-linked into
+// job directories are counted once, under sources; the HLS directory of a job counts as hls.
+func ScanStorageUsage() {
+	seen := map[fileKey]bool{}
+	usage := map[string]int64{UsageSources: dirUsage(config.SourceCacheDir, seen)}
+
+	entries, err := os.ReadDir(config.StorageDir)
+	if err == nil {
+		for _, entry := range entries {
+			if entry.IsDir() && !isStorageSubdir(entry.Name()) && ValidateJobID(entry.Name()) {
+				jobDir := GetJobDir(entry.Name())
+				usage[UsageHLS] += dirUsage(filepath.Join(jobDir, config.HLSDirName), seen)
+				usage[UsageJobs] += dirUsage(jobDir, seen)
+			}
+		}
+	}
+
+	for _, category := range usageCategories {
+		v := new(expvar.Int)
+		v.Set(usage[category])
+		storageUsed.Set(category, v)
+	}
+}
+
+// fileKey identifies a file across hard links
+type fileKey struct {
+	dev uint64
+	ino uint64
+}
+
+// DirSize returns the bytes of the files under dir
+func DirSize(dir string) int64 {
+	return dirUsage(dir, map[fileKey]bool{})
+}
+
+// dirUsage returns the bytes of the files under dir not already in seen, and adds them to it
+func dirUsage(dir string, seen map[fileKey]bool) int64 {
+	var total int64
+	filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+			key := fileKey{dev: uint64(stat.Dev), ino: stat.Ino}
+			if seen[key] {
+				return nil
+			}
+			seen[key] = true
+		}
+		total += info.Size()
+		return nil
+	})
+	return total
+}
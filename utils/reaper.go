@@ -0,0 +1,227 @@
+package utils
+
+import (
+	"bytes"
+	"expvar"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+	"yt-downloader-go/config"
+	"yt-downloader-go/models"
+)
+
+// Reaper actions (exported via /debug/vars)
+var (
+	reapedProcesses = expvar.NewInt("reaped_processes")
+	reapedChunkDirs = expvar.NewInt("reaped_chunk_dirs")
+	reapedTmpFiles  = expvar.NewInt("reaped_tmp_files")
+)
+
+// noSessionJob stands in for the job ID of ffmpeg runs not tied to a job
+const noSessionJob = "-"
+
+// liveSessions counts the running ffmpeg processes of this process per job ID
+var liveSessions = struct {
+	mu   sync.Mutex
+	jobs map[string]int
+}{jobs: map[string]int{}}
+
+// getSessionPath returns the registry file of an ffmpeg process
+func getSessionPath(pid int) string {
+	return filepath.Join(config.SessionDir, strconv.Itoa(pid))
+}
+
+// RegisterFFmpegSession records a started ffmpeg process of jobID ("" = no job) in SessionDir
+// The record holds "<job ID> <owner pid> <unix ms>" so a later process can find orphans.
+// unregister removes it once the ffmpeg process has been waited for.
+func RegisterFFmpegSession(jobID string, pid int) (unregister func()) {
+	if jobID == "" {
+		jobID = noSessionJob
+	}
+
+	liveSessions.mu.Lock()
+	liveSessions.jobs[jobID]++
+	liveSessions.mu.Unlock()
+
+	path := getSessionPath(pid)
+	err := os.MkdirAll(config.SessionDir, 0755)
+	if err == nil {
+		err = os.WriteFile(path, []byte(fmt.Sprintf("%s %d %d", jobID, os.Getpid(), time.Now().UnixMilli())), 0644)
+	}
+	if err != nil {
+		cleanupLog.Warn("failed to register ffmpeg session", "pid", pid, "job", jobID, "err", err)
+	}
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			os.Remove(path)
+			liveSessions.mu.Lock()
+			defer liveSessions.mu.Unlock()
+			if liveSessions.jobs[jobID]--; liveSessions.jobs[jobID] <= 0 {
+				delete(liveSessions.jobs, jobID)
+			}
+		})
+	}
+}
+
+// hasLiveSession reports whether this process runs ffmpeg for the job (render, HLS or /stream)
+func hasLiveSession(jobID string) bool {
+	liveSessions.mu.Lock()
+	defer liveSessions.mu.Unlock()
+	return liveSessions.jobs[jobID] > 0
+}
+
+// IsJobActive reports whether a worker or ffmpeg session of this process uses the job
+// The reaper never touches the files of active jobs.
+func IsJobActive(jobID string) bool {
+	return IsJobRunning(jobID) || hasLiveSession(jobID)
+}
+
+// ReapOrphans kills ffmpeg processes left behind by crashed sessions and removes the
+// download leftovers that CleanupTempFiles misses while a job is within its lifetime
+func ReapOrphans() {
+	reapSessions()
+	reapJobFiles()
+}
+
+// reapSessions kills registered ffmpeg processes whose owner is gone (an earlier run of
+// the server) or whose job no longer exists, and drops their records
+func reapSessions() {
+	entries, err := os.ReadDir(config.SessionDir)
+	if err != nil {
+		return
+	}
+
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue
+		}
+		path := getSessionPath(pid)
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+
+		fields := strings.Fields(string(data))
+		if len(fields) != 3 {
+			os.Remove(path)
+			continue
+		}
+		jobID := fields[0]
+		owner, _ := strconv.Atoi(fields[1])
+
+		// Sessions of this process unregister themselves; only a deleted job orphans them
+		if owner == os.Getpid() && (jobID == noSessionJob || JobExists(jobID)) {
+			continue
+		}
+
+		// The pid may have been reused since the record was written
+		if isFFmpegProcess(pid) {
+			if err := syscall.Kill(pid, syscall.SIGKILL); err != nil {
+				cleanupLog.Warn("reaper: failed to kill orphaned ffmpeg", "pid", pid, "job", jobID, "err", err)
+				continue
+			}
+			reapedProcesses.Add(1)
+			cleanupLog.Info("reaper: killed orphaned ffmpeg", "pid", pid, "job", jobID, "owner", owner)
+		}
+		if owner != os.Getpid() {
+			os.Remove(path)
+		}
+	}
+}
+
+// isFFmpegProcess reports whether pid is a running ffmpeg (Linux /proc; false when unknown)
+func isFFmpegProcess(pid int) bool {
+	cmdline, err := os.ReadFile(fmt.Sprintf("/proc/%d/cmdline", pid))
+	if err != nil || len(cmdline) == 0 {
+		return false
+	}
+	name, _, _ := bytes.Cut(cmdline, []byte{0})
+	return strings.Contains(filepath.Base(string(name)), "ffmpeg")
+}
+
+// reapJobFiles removes .chunks directories of finished jobs untouched for OrphanChunkDirAge
+// and *.tmp files older than ChunkTimeout from jobs no worker or session is using, in the
+// job directories and their work directories (TEMP_DIR)
+func reapJobFiles() {
+	entries, err := os.ReadDir(config.StorageDir)
+	if err != nil {
+		return
+	}
+
+	now := time.Now()
+	for _, entry := range entries {
+		jobID := entry.Name()
+		if !entry.IsDir() || !ValidateJobID(jobID) || IsJobActive(jobID) {
+			continue
+		}
+		// Unreadable jobs are removed by CleanupOldJobs
+		meta, err := ReadMeta(jobID)
+		if err != nil {
+			continue
+		}
+		finished := meta.Status == models.StatusCompleted || meta.Status == models.StatusError
+
+		reapDirFiles(jobID, GetJobDir(jobID), finished, now)
+		if config.TempDir != "" {
+			reapDirFiles(jobID, GetWorkDir(jobID), finished, now)
+		}
+	}
+}
+
+// reapDirFiles removes the stale chunk directories and temp files of one directory of a job
+func reapDirFiles(jobID string, dir string, finished bool, now time.Time) {
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	for _, file := range files {
+		path := filepath.Join(dir, file.Name())
+		switch {
+		case file.IsDir() && strings.HasSuffix(file.Name(), ".chunks"):
+			if !finished || now.Sub(lastModified(path)) <= config.OrphanChunkDirAge || IsJobActive(jobID) {
+				continue
+			}
+			if err := os.RemoveAll(path); err != nil {
+				cleanupLog.Warn("reaper: failed to remove", "path", path, "err", err)
+				continue
+			}
+			reapedChunkDirs.Add(1)
+			cleanupLog.Debug("reaper: removed stale chunks dir", "file", file.Name(), "job", jobID)
+
+		case !file.IsDir() && strings.HasSuffix(file.Name(), ".tmp"):
+			info, err := file.Info()
+			if err != nil || now.Sub(info.ModTime()) <= config.ChunkTimeout || IsJobActive(jobID) {
+				continue
+			}
+			if err := os.Remove(path); err != nil {
+				cleanupLog.Warn("reaper: failed to remove", "path", path, "err", err)
+				continue
+			}
+			reapedTmpFiles.Add(1)
+			cleanupLog.Debug("reaper: removed stray temp file", "file", file.Name(), "job", jobID)
+		}
+	}
+}
+
+// lastModified returns the newest modification time of dir and the entries directly in it
+func lastModified(dir string) time.Time {
+	var latest time.Time
+	if info, err := os.Stat(dir); err == nil {
+		latest = info.ModTime()
+	}
+	entries, _ := os.ReadDir(dir)
+	for _, entry := range entries {
+		if info, err := entry.Info(); err == nil && info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest
+}
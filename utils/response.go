@@ -1,24 +1,56 @@
 package utils
 
-import "github.com/gofiber/fiber/v2"
+import (
+	"yt-downloader-go/models"
+
+	"github.com/gofiber/fiber/v2"
+)
 
 // Error codes
 const (
-	ErrInvalidRequest  = "INVALID_REQUEST"
-	ErrValidationError = "VALIDATION_ERROR"
-	ErrInvalidURL      = "INVALID_URL"
-	ErrInvalidJobID    = "INVALID_JOB_ID"
-	ErrInvalidFilename = "INVALID_FILENAME"
-	ErrInvalidExpires  = "INVALID_EXPIRES"
-	ErrJobNotReady     = "JOB_NOT_READY"
-	ErrUnauthorized    = "UNAUTHORIZED"
-	ErrForbidden       = "FORBIDDEN"
-	ErrJobNotFound     = "JOB_NOT_FOUND"
-	ErrVideoNotFound   = "VIDEO_NOT_FOUND"
-	ErrAudioNotFound   = "AUDIO_NOT_FOUND"
-	ErrFileNotFound    = "FILE_NOT_FOUND"
-	ErrInternalError   = "INTERNAL_ERROR"
-	ErrExtractFailed   = "EXTRACT_FAILED"
+	ErrInvalidRequest      = "INVALID_REQUEST"
+	ErrBodyTooLarge        = "BODY_TOO_LARGE"
+	ErrValidationError     = "VALIDATION_ERROR"
+	ErrInvalidURL          = "INVALID_URL"
+	ErrInvalidJobID        = "INVALID_JOB_ID"
+	ErrInvalidFilename     = "INVALID_FILENAME"
+	ErrInvalidExpires      = "INVALID_EXPIRES"
+	ErrInvalidPart         = "INVALID_PART"
+	ErrJobNotReady         = "JOB_NOT_READY"
+	ErrHLSGenerating       = "HLS_GENERATING"
+	ErrUnauthorized        = "UNAUTHORIZED"
+	ErrForbidden           = "FORBIDDEN"
+	ErrClientMismatch      = "CLIENT_MISMATCH"
+	ErrJobNotFound         = "JOB_NOT_FOUND"
+	ErrJobDeleted          = "JOB_DELETED"
+	ErrJobNotDeleted       = "JOB_NOT_DELETED"
+	ErrJobExpired          = "JOB_EXPIRED"
+	ErrExtendLimit         = "EXTEND_LIMIT_REACHED"
+	ErrIdempotencyKey      = "IDEMPOTENCY_KEY_REUSED"
+	ErrSourcesNotKept      = "SOURCES_NOT_KEPT"
+	ErrJobBusy             = "JOB_BUSY"
+	ErrJobFinished         = "JOB_FINISHED"
+	ErrVideoNotFound       = "VIDEO_NOT_FOUND"
+	ErrAudioNotFound       = "AUDIO_NOT_FOUND"
+	ErrProtectedContent    = "PROTECTED_CONTENT"
+	ErrLossySource         = "LOSSY_SOURCE"
+	ErrFileNotFound        = "FILE_NOT_FOUND"
+	ErrInternalError       = "INTERNAL_ERROR"
+	ErrTimeout             = "TIMEOUT"
+	ErrExtractFailed       = "EXTRACT_FAILED"
+	ErrExtractLimited      = "EXTRACT_RATE_LIMITED"
+	ErrOverloaded          = "OVERLOADED"
+	ErrStorageFull         = "STORAGE_FULL"
+	ErrStorageQuota        = "STORAGE_QUOTA_EXCEEDED"
+	ErrTooLarge            = "TOO_LARGE"
+	ErrRangeNotSatisfiable = "RANGE_NOT_SATISFIABLE"
+	ErrNotFound            = "NOT_FOUND"
+	ErrMethodNotAllowed    = "METHOD_NOT_ALLOWED"
+	ErrTrackNotFound       = "TRACK_NOT_FOUND"
+	ErrRateLimited         = "RATE_LIMITED"
+	ErrGroupNotFound       = "GROUP_NOT_FOUND"
+	ErrJobInGroup          = "JOB_IN_GROUP"
+	ErrArchiveNotReady     = "ARCHIVE_NOT_READY"
 )
 
 // ErrorResponse represents an API error
@@ -26,6 +58,42 @@ type ErrorResponse struct {
 	Error ErrorDetail `json:"error"`
 }
 
+// OverloadResponse is returned with 503 when new jobs are shed
+type OverloadResponse struct {
+	Error                ErrorDetail `json:"error"`
+	EstimatedWaitSeconds int         `json:"estimatedWaitSeconds"`
+}
+
+// IncompatibleStreamResponse is returned with 404 when no video stream suits the requested os
+type IncompatibleStreamResponse struct {
+	Error              ErrorDetail `json:"error"`
+	OfferedCodecs      []string    `json:"offeredCodecs"`      // Video codecs the video is published in
+	CompatibleProfiles []string    `json:"compatibleProfiles"` // os values that can play one of them
+	TranscodeAvailable bool        `json:"transcodeAvailable"` // Retry with allowTranscode to get H.264
+}
+
+// TooLargeResponse is returned with 422 when the selected streams or the estimated output exceed the size limits
+type TooLargeResponse struct {
+	Error                ErrorDetail `json:"error"`
+	VideoBytes           int64       `json:"videoBytes,omitempty"` // Selected video stream
+	AudioBytes           int64       `json:"audioBytes"`           // Selected audio stream
+	EstimatedOutputBytes int64       `json:"estimatedOutputBytes"`
+	MaxSourceBytes       int64       `json:"maxSourceBytes,omitempty"` // Per stream, 0 = unlimited
+	MaxOutputBytes       int64       `json:"maxOutputBytes,omitempty"` // 0 = unlimited
+}
+
+// TrackNotFoundResponse is returned with 404 when a preview names an audio track the video doesn't have
+type TrackNotFoundResponse struct {
+	Error  ErrorDetail         `json:"error"`
+	Tracks []models.AudioTrack `json:"tracks"` // Tracks the video offers
+}
+
+// JobDeletedResponse is returned with 410 JOB_DELETED for soft-deleted jobs and, for TOMBSTONE_TTL, removed ones
+type JobDeletedResponse struct {
+	Error     ErrorDetail `json:"error"`
+	DeletedAt int64       `json:"deletedAt"` // Soft delete time (ms)
+}
+
 // ErrorDetail contains error information
 type ErrorDetail struct {
 	Code    string `json:"code"`
@@ -39,7 +107,7 @@ func Error(c *fiber.Ctx, status int, code, message string) error {
 			Code:    code,
 			Message: message,
 		},
-	})
+	}, fiber.MIMEApplicationJSONCharsetUTF8)
 }
 
 // BadRequest returns 400 error
@@ -62,6 +130,22 @@ func NotFound(c *fiber.Ctx, code, message string) error {
 	return Error(c, fiber.StatusNotFound, code, message)
 }
 
+// Gone returns 410 error
+func Gone(c *fiber.Ctx, code, message string) error {
+	return Error(c, fiber.StatusGone, code, message)
+}
+
+// JobGone returns 410 JOB_DELETED with the job's deletion time
+func JobGone(c *fiber.Ctx, message string, deletedAt int64) error {
+	return c.Status(fiber.StatusGone).JSON(JobDeletedResponse{
+		Error: ErrorDetail{
+			Code:    ErrJobDeleted,
+			Message: message,
+		},
+		DeletedAt: deletedAt,
+	}, fiber.MIMEApplicationJSONCharsetUTF8)
+}
+
 // InternalError returns 500 error
 func InternalError(c *fiber.Ctx, message string) error {
 	return Error(c, fiber.StatusInternalServerError, ErrInternalError, message)
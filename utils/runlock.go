@@ -0,0 +1,100 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"yt-downloader-go/config"
+)
+
+// ErrJobRunning is returned when another worker already holds the job's run lock
+var ErrJobRunning = errors.New("job is already being processed")
+
+// getRunLockPath returns the run lock path for a job
+func getRunLockPath(jobID string) string {
+	return filepath.Join(GetJobDir(jobID), "run.lock")
+}
+
+// AcquireRunLock atomically creates the job's run lock (O_EXCL)
+// The lock records "<pid> <unix ms>" so stale locks can be detected
+func AcquireRunLock(jobID string) error {
+	file, err := os.OpenFile(getRunLockPath(jobID), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		if os.IsExist(err) {
+			return ErrJobRunning
+		}
+		return err
+	}
+	defer file.Close()
+
+	_, err = fmt.Fprintf(file, "%d %d", os.Getpid(), time.Now().UnixMilli())
+	return err
+}
+
+// ReleaseRunLock removes the job's run lock
+func ReleaseRunLock(jobID string) error {
+	return os.Remove(getRunLockPath(jobID))
+}
+
+// processStart is when this process started; locks taken before it belong to an earlier
+// process even when it had the same PID (PID 1 in a container after every restart)
+var processStart = time.Now()
+
+// isStaleRunLock reports whether a lock was left by another (crashed) process
+// or is older than the job timeout
+func isStaleRunLock(path string) bool {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return false
+	}
+
+	fields := strings.Fields(string(data))
+	if len(fields) != 2 {
+		return true
+	}
+	pid, err := strconv.Atoi(fields[0])
+	if err != nil {
+		return true
+	}
+	lockedAt, err := strconv.ParseInt(fields[1], 10, 64)
+	if err != nil {
+		return true
+	}
+
+	return pid != os.Getpid() || lockedAt < processStart.UnixMilli() ||
+		time.Since(time.UnixMilli(lockedAt)) > config.JobTimeout
+}
+
+// IsJobRunning reports whether a live worker of this process holds the job's run lock
+func IsJobRunning(jobID string) bool {
+	path := getRunLockPath(jobID)
+	if _, err := os.Stat(path); err != nil {
+		return false
+	}
+	return !isStaleRunLock(path)
+}
+
+// ClearStaleRunLock removes the job's run lock if it was left by a crashed or timed-out worker
+func ClearStaleRunLock(jobID string) {
+	if path := getRunLockPath(jobID); isStaleRunLock(path) {
+		os.Remove(path)
+	}
+}
+
+// ClearStaleRunLocks removes run locks left behind by crashed processes
+// Called on startup before any job is processed
+func ClearStaleRunLocks() {
+	matches, err := filepath.Glob(filepath.Join(config.StorageDir, "*", "run.lock"))
+	if err != nil {
+		return
+	}
+	for _, path := range matches {
+		if isStaleRunLock(path) {
+			os.Remove(path)
+		}
+	}
+}
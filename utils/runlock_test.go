@@ -0,0 +1,70 @@
+package utils
+
+import (
+	"fmt"
+	"os"
+	"testing"
+	"time"
+	"yt-downloader-go/config"
+)
+
+// writeRunLock writes testJobID's run lock as AcquireRunLock would for pid at lockedAt
+func writeRunLock(t *testing.T, pid int, lockedAt time.Time) {
+	t.Helper()
+	if err := os.MkdirAll(GetJobDir(testJobID), 0755); err != nil {
+		t.Fatal(err)
+	}
+	lock := fmt.Sprintf("%d %d", pid, lockedAt.UnixMilli())
+	if err := os.WriteFile(getRunLockPath(testJobID), []byte(lock), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestClearStaleRunLocks(t *testing.T) {
+	tests := []struct {
+		name     string
+		pid      int
+		lockedAt time.Time
+		stale    bool
+	}{
+		{name: "held by this process", pid: os.Getpid(), lockedAt: time.Now(), stale: false},
+		{name: "other process", pid: os.Getpid() + 1, lockedAt: time.Now(), stale: true},
+		{
+			// A container restart reuses the PID: the lock predates this process
+			name: "same PID before a restart", pid: os.Getpid(), lockedAt: processStart.Add(-time.Second), stale: true,
+		},
+		{name: "past the job timeout", pid: os.Getpid(), lockedAt: time.Now().Add(-config.JobTimeout - time.Minute), stale: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useTempStorage(t)
+			writeRunLock(t, tt.pid, tt.lockedAt)
+			if running := IsJobRunning(testJobID); running == tt.stale {
+				t.Errorf("IsJobRunning = %t, want %t", running, !tt.stale)
+			}
+
+			ClearStaleRunLocks()
+			_, err := os.Stat(getRunLockPath(testJobID))
+			if removed := os.IsNotExist(err); removed != tt.stale {
+				t.Errorf("lock removed = %t, want %t", removed, tt.stale)
+			}
+		})
+	}
+}
+
+func TestAcquireRunLockAfterRestart(t *testing.T) {
+	useTempStorage(t)
+	writeRunLock(t, os.Getpid(), processStart.Add(-time.Minute))
+	if err := AcquireRunLock(testJobID); err != ErrJobRunning {
+		t.Fatalf("AcquireRunLock over a left lock = %v, want ErrJobRunning", err)
+	}
+
+	ClearStaleRunLock(testJobID)
+	if err := AcquireRunLock(testJobID); err != nil {
+		t.Fatalf("AcquireRunLock after clearing = %v", err)
+	}
+	if !IsJobRunning(testJobID) {
+		t.Error("the new lock isn't held")
+	}
+}
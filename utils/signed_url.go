@@ -5,87 +5,229 @@ import (
 	"crypto/sha256"
 	"encoding/hex"
 	"fmt"
+	"net/url"
 	"strconv"
+	"strings"
 	"time"
 	"yt-downloader-go/config"
+	"yt-downloader-go/models"
+
+	"github.com/gofiber/fiber/v2"
 )
 
-// GenerateSignedURL creates a signed URL with token and expiration
-func GenerateSignedURL(jobID, filename string) string {
-	expires := time.Now().Add(config.SignedURLExpiration).Unix()
-	token := generateToken(jobID, filename, expires)
-	return fmt.Sprintf("%s/files/%s/%s?token=%s&expires=%d", config.BaseURL, jobID, filename, token, expires)
+// GenerateSignedURL creates a signed file URL with a compact token
+// A non-empty binding (see ClientBinding) ties the URL to one client
+func GenerateSignedURL(jobID, filename, binding string) string {
+	return fmt.Sprintf("%s/files/%s/%s?t=%s", config.PublicURL, jobID, filename, fileToken(jobID, filename, binding))
+}
+
+// GenerateShareURL creates a signed /d URL of a job's output with displayName as its last
+// segment. The segment is cosmetic: the token is the /files token of filename.
+func GenerateShareURL(jobID, filename, displayName, binding string) string {
+	name := url.PathEscape(strings.ReplaceAll(displayName, " ", "_"))
+	return fmt.Sprintf("%s/d/%s/%s?t=%s", config.PublicURL, jobID, name, fileToken(jobID, filename, binding))
+}
+
+// fileToken returns a compact download token for a file of a job
+func fileToken(jobID, filename, binding string) string {
+	return GenerateToken(URLToken{
+		Job:   jobID,
+		File:  filename,
+		Exp:   time.Now().Add(config.SignedURLExpiration).Unix(),
+		Scope: ScopeDownload,
+	}, binding)
 }
 
-// GenerateStreamURL creates a signed stream URL
-func GenerateStreamURL(jobID string) string {
-	expires := time.Now().Add(config.SignedURLExpiration).Unix()
-	token := generateStreamToken(jobID, expires)
-	return fmt.Sprintf("%s/stream/%s?token=%s&expires=%d", config.BaseURL, jobID, token, expires)
+// SignedURLExpiresAt returns when a file URL generated now expires
+func SignedURLExpiresAt() time.Time {
+	return time.Unix(time.Now().Add(config.SignedURLExpiration).Unix(), 0)
 }
 
-// GenerateStatusURL creates a signed status URL
+// StreamURLExpiresAt returns when a stream URL of the job generated now expires:
+// STREAM_URL_EXPIRATION from now, but not after the job is removed
+func StreamURLExpiresAt(meta *models.Meta) time.Time {
+	expiresAt := time.Now().Add(config.StreamURLExpiration)
+	if jobExpiresAt := JobExpiresAt(meta); jobExpiresAt.Before(expiresAt) {
+		expiresAt = jobExpiresAt
+	}
+	return time.Unix(expiresAt.Unix(), 0)
+}
+
+// GenerateStreamURL creates a signed stream URL with a compact token, valid until expiresAt
+// (see StreamURLExpiresAt). A non-empty binding (see ClientBinding) ties the URL to one client.
+func GenerateStreamURL(jobID, binding string, expiresAt time.Time) string {
+	token := GenerateToken(URLToken{
+		Job:   jobID,
+		Exp:   expiresAt.Unix(),
+		Scope: ScopeDownload,
+	}, binding)
+	return fmt.Sprintf("%s/stream/%s?t=%s", config.PublicURL, jobID, token)
+}
+
+// GenerateHLSURL creates a signed URL for an HLS playlist or segment of a job
+// Playlists are fetched once per playback, so these last as long as stream URLs.
+func GenerateHLSURL(meta *models.Meta, name string) string {
+	token := GenerateToken(URLToken{
+		Job:   meta.ID,
+		File:  config.HLSDirName + "/" + name,
+		Exp:   StreamURLExpiresAt(meta).Unix(),
+		Scope: ScopeDownload,
+	}, meta.Binding)
+	return fmt.Sprintf("%s/stream/%s/%s/%s?t=%s", config.PublicURL, meta.ID, config.HLSDirName, name, token)
+}
+
+// GenerateStatusURL creates a signed status URL with a compact token
 func GenerateStatusURL(jobID string) string {
-	expires := time.Now().Add(config.SignedURLExpiration).Unix()
-	token := generateStatusToken(jobID, expires)
-	return fmt.Sprintf("%s/api/status/%s?token=%s&expires=%d", config.BaseURL, jobID, token, expires)
+	token := GenerateToken(URLToken{
+		Job:   jobID,
+		Exp:   time.Now().Add(config.SignedURLExpiration).Unix(),
+		Scope: ScopeStatus,
+	}, "")
+	return fmt.Sprintf("%s/api/status/%s?t=%s", config.PublicURL, jobID, token)
+}
+
+// GenerateGroupStatusURL creates a signed job group status URL (its token also deletes the group)
+func GenerateGroupStatusURL(groupID string) string {
+	token := GenerateToken(URLToken{
+		Job:   groupID,
+		Exp:   time.Now().Add(config.SignedURLExpiration).Unix(),
+		Scope: ScopeStatus,
+	}, "")
+	return fmt.Sprintf("%s/api/groups/%s?t=%s", config.PublicURL, groupID, token)
+}
+
+// GenerateGroupArchiveURL creates a signed URL for the archive of a job group
+func GenerateGroupArchiveURL(groupID, binding string) string {
+	token := GenerateToken(URLToken{
+		Job:   groupID,
+		File:  config.GroupArchiveName,
+		Exp:   time.Now().Add(config.SignedURLExpiration).Unix(),
+		Scope: ScopeDownload,
+	}, binding)
+	return fmt.Sprintf("%s/groups/%s/archive?t=%s", config.PublicURL, groupID, token)
 }
 
-// ValidateStatusURL checks if the status token is valid and not expired
+// ValidateStatusURL checks if the legacy status token is valid and not expired
 func ValidateStatusURL(jobID, token string, expires int64) bool {
 	if time.Now().Unix() > expires {
 		return false
 	}
-	expectedToken := generateStatusToken(jobID, expires)
-	return hmac.Equal([]byte(token), []byte(expectedToken))
+	return matchesAnySecret(token, func(secret string) string {
+		return generateStatusToken(secret, jobID, expires)
+	})
 }
 
 // generateStatusToken creates HMAC-SHA256 token for status URLs
-func generateStatusToken(jobID string, expires int64) string {
+func generateStatusToken(secret, jobID string, expires int64) string {
 	data := fmt.Sprintf("status:%s:%d", jobID, expires)
-	h := hmac.New(sha256.New, []byte(config.SignedURLSecret))
+	h := hmac.New(sha256.New, []byte(secret))
 	h.Write([]byte(data))
 	return hex.EncodeToString(h.Sum(nil))
 }
 
-// ValidateStreamURL checks if the stream token is valid and not expired
-func ValidateStreamURL(jobID, token string, expires int64) bool {
+// ValidateStreamURL checks if the legacy stream token is valid and not expired
+func ValidateStreamURL(jobID, token string, expires int64, binding string) bool {
 	if time.Now().Unix() > expires {
 		return false
 	}
-	expectedToken := generateStreamToken(jobID, expires)
-	return hmac.Equal([]byte(token), []byte(expectedToken))
+	return matchesAnySecret(token, func(secret string) string {
+		return generateStreamToken(secret, jobID, expires, binding)
+	})
 }
 
 // generateStreamToken creates HMAC-SHA256 token for stream URLs
-func generateStreamToken(jobID string, expires int64) string {
-	data := fmt.Sprintf("stream:%s:%d", jobID, expires)
-	h := hmac.New(sha256.New, []byte(config.SignedURLSecret))
+func generateStreamToken(secret, jobID string, expires int64, binding string) string {
+	data := fmt.Sprintf("stream:%s:%d", jobID, expires) + bindSuffix(binding)
+	h := hmac.New(sha256.New, []byte(secret))
 	h.Write([]byte(data))
 	return hex.EncodeToString(h.Sum(nil))
 }
 
-// ValidateSignedURL checks if the token is valid and not expired
-func ValidateSignedURL(jobID, filename, token string, expires int64) bool {
+// ValidateSignedURL checks if the legacy file token is valid and not expired
+func ValidateSignedURL(jobID, filename, token string, expires int64, binding string) bool {
 	// Check if expired
 	if time.Now().Unix() > expires {
 		return false
 	}
 
 	// Validate token
-	expectedToken := generateToken(jobID, filename, expires)
-	return hmac.Equal([]byte(token), []byte(expectedToken))
+	return matchesAnySecret(token, func(secret string) string {
+		return generateToken(secret, jobID, filename, expires, binding)
+	})
 }
 
 // generateToken creates HMAC-SHA256 token
-func generateToken(jobID, filename string, expires int64) string {
-	data := fmt.Sprintf("%s:%s:%d", jobID, filename, expires)
-	h := hmac.New(sha256.New, []byte(config.SignedURLSecret))
+func generateToken(secret, jobID, filename string, expires int64, binding string) string {
+	data := fmt.Sprintf("%s:%s:%d", jobID, filename, expires) + bindSuffix(binding)
+	h := hmac.New(sha256.New, []byte(secret))
 	h.Write([]byte(data))
 	return hex.EncodeToString(h.Sum(nil))
 }
 
+// matchesAnySecret checks token against every accepted secret (constant-time per comparison)
+// The first secret signs new URLs; older ones keep validating during rotation
+func matchesAnySecret(token string, sign func(secret string) string) bool {
+	valid := false
+	for _, secret := range config.SignedURLSecrets {
+		if hmac.Equal([]byte(token), []byte(sign(secret))) {
+			valid = true
+		}
+	}
+	return valid
+}
+
 // ParseExpires converts expires string to int64
 func ParseExpires(expiresStr string) (int64, error) {
 	return strconv.ParseInt(expiresStr, 10, 64)
 }
+
+// Client binding modes
+const (
+	BindIP      = "ip"
+	BindSession = "session"
+)
+
+// ClientBinding returns the binding ("<mode>:<hash>") stored with a job and
+// included in the HMAC payload of its file/stream URLs, or "" when unbound
+func ClientBinding(mode, value string) string {
+	if mode == "" || value == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(value))
+	return mode + ":" + hex.EncodeToString(sum[:16])
+}
+
+// RequestBinding recomputes the binding for the connecting client from the legacy URL's bind mode
+func RequestBinding(c *fiber.Ctx) string {
+	return requestBindingForMode(c, c.Query("bind"))
+}
+
+// requestBindingForMode recomputes the binding for the connecting client
+// Session IDs are read from the X-Session-ID header or the session query parameter
+func requestBindingForMode(c *fiber.Ctx, mode string) string {
+	switch mode {
+	case BindIP:
+		return ClientBinding(BindIP, c.IP())
+	case BindSession:
+		session := c.Get("X-Session-ID")
+		if session == "" {
+			session = c.Query("session")
+		}
+		return ClientBinding(BindSession, session)
+	}
+	return ""
+}
+
+// IsBoundURL reports whether the request URL is bound to a client and not yet expired,
+// so a failed validation can be reported as a client mismatch
+func IsBoundURL(c *fiber.Ctx, expires int64) bool {
+	return c.Query("bind") != "" && time.Now().Unix() <= expires
+}
+
+// bindSuffix returns the HMAC payload suffix for a binding (empty keeps unbound tokens unchanged)
+func bindSuffix(binding string) string {
+	if binding == "" {
+		return ""
+	}
+	return ":" + binding
+}
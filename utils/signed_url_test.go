@@ -0,0 +1,64 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+	"time"
+	"yt-downloader-go/config"
+)
+
+// usePathPrefix sets PATH_PREFIX for the duration of the test
+func usePathPrefix(t *testing.T, prefix string) {
+	t.Helper()
+	prev := config.PathPrefix
+	config.SetPathPrefix(prefix)
+	t.Cleanup(func() { config.SetPathPrefix(prev) })
+}
+
+func TestURLsPathPrefix(t *testing.T) {
+	useSecrets(t, "secret")
+	prefixes := []struct {
+		prefix string
+		want   string // Normalized
+	}{
+		{prefix: "", want: ""},
+		{prefix: "/", want: ""},
+		{prefix: "/yt", want: "/yt"},
+		{prefix: "yt/", want: "/yt"},
+		{prefix: "/a/b/", want: "/a/b"},
+	}
+	urls := []struct {
+		name string
+		url  func() string
+		path string // After the prefix, up to the token
+	}{
+		{
+			name: "GenerateSignedURL",
+			url:  func() string { return GenerateSignedURL(testJobID, "output.mp3", "") },
+			path: "/files/" + testJobID + "/output.mp3?t=",
+		},
+		{
+			name: "GenerateStreamURL",
+			url:  func() string { return GenerateStreamURL(testJobID, "", time.Now().Add(time.Hour)) },
+			path: "/stream/" + testJobID + "?t=",
+		},
+		{
+			name: "GenerateShareURL",
+			url:  func() string { return GenerateShareURL(testJobID, "output.mp3", "My Song_192k.mp3", "") },
+			path: "/d/" + testJobID + "/My_Song_192k.mp3?t=",
+		},
+	}
+
+	for _, p := range prefixes {
+		for _, u := range urls {
+			t.Run(u.name+" "+p.prefix, func(t *testing.T) {
+				usePathPrefix(t, p.prefix)
+				got := u.url()
+				want := config.BaseURL + p.want + u.path
+				if !strings.HasPrefix(got, want) || len(got) == len(want) {
+					t.Errorf("%s = %s, want %s<token>", u.name, got, want)
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+	"yt-downloader-go/config"
+)
+
+// sourceMu serializes reference changes against eviction
+var sourceMu sync.Mutex
+
+// GetSourcePath returns the path of a cached source file
+func GetSourcePath(name string) string {
+	return filepath.Join(config.SourceCacheDir, name)
+}
+
+// getSourceRefsDir returns the directory holding job references for a source
+func getSourceRefsDir(name string) string {
+	return GetSourcePath(name) + ".refs"
+}
+
+// AddSourceRef records that a job uses a cached source
+// Each reference is an empty file named after the job ID
+func AddSourceRef(name string, jobID string) error {
+	sourceMu.Lock()
+	defer sourceMu.Unlock()
+
+	refsDir := getSourceRefsDir(name)
+	if err := os.MkdirAll(refsDir, 0755); err != nil {
+		return err
+	}
+
+	file, err := os.Create(filepath.Join(refsDir, jobID))
+	if err != nil {
+		return err
+	}
+	file.Close()
+
+	// Touch source so idle time counts from last use
+	now := time.Now()
+	os.Chtimes(GetSourcePath(name), now, now)
+	return nil
+}
+
+// RemoveSourceRef drops a job's reference to a cached source (a fetch that failed)
+func RemoveSourceRef(name string, jobID string) {
+	sourceMu.Lock()
+	defer sourceMu.Unlock()
+	os.Remove(filepath.Join(getSourceRefsDir(name), jobID))
+}
+
+// countSourceRefs prunes references to deleted jobs and returns the remaining count
+func countSourceRefs(name string) int {
+	refsDir := getSourceRefsDir(name)
+	entries, err := os.ReadDir(refsDir)
+	if err != nil {
+		return 0
+	}
+
+	count := 0
+	for _, entry := range entries {
+		if JobExists(entry.Name()) {
+			count++
+			continue
+		}
+		os.Remove(filepath.Join(refsDir, entry.Name()))
+	}
+	return count
+}
+
+// CleanupSourceCache evicts cached sources that no job references
+// and that have been idle longer than SourceCacheTTL
+func CleanupSourceCache() {
+	evictSourceCache(config.SourceCacheTTL)
+}
+
+// evictSourceCache removes unreferenced cached sources idle longer than ttl
+func evictSourceCache(ttl time.Duration) {
+	entries, err := os.ReadDir(config.SourceCacheDir)
+	if err != nil {
+		return
+	}
+
+	sourceMu.Lock()
+	defer sourceMu.Unlock()
+
+	now := time.Now()
+	for _, entry := range entries {
+		name := entry.Name()
+
+		// Refs dirs of sources that never landed (every fetch failed) go once unreferenced
+		if entry.IsDir() {
+			source := strings.TrimSuffix(name, ".refs")
+			if source == name || countSourceRefs(source) > 0 {
+				continue
+			}
+			if _, err := os.Stat(GetSourcePath(source)); os.IsNotExist(err) {
+				os.RemoveAll(getSourceRefsDir(source))
+			}
+			continue
+		}
+
+		// Skip in-progress downloads
+		if strings.HasSuffix(name, ".tmp") ||
+			strings.HasSuffix(name, config.SparseDownloadSuffix) || strings.HasSuffix(name, config.SparseSidecarSuffix) {
+			continue
+		}
+
+		if countSourceRefs(name) > 0 {
+			continue
+		}
+
+		info, err := entry.Info()
+		if err != nil || now.Sub(info.ModTime()) < ttl {
+			continue
+		}
+
+		os.Remove(GetSourcePath(name))
+		os.RemoveAll(getSourceRefsDir(name))
+	}
+}
@@ -0,0 +1,65 @@
+package utils
+
+import (
+	"errors"
+	"expvar"
+	"sync/atomic"
+	"syscall"
+	"yt-downloader-go/config"
+)
+
+var storageLog = Logger(LogStorage)
+
+// storageFull is set by a disk-full error and cleared once StorageRecoveryFree is available
+var storageFull atomic.Bool
+
+// emergencyCleanupRunning keeps a burst of disk-full errors to one cleanup pass
+var emergencyCleanupRunning atomic.Bool
+
+// storageFullEvents counts disk-full errors (exported via /debug/vars)
+var storageFullEvents = expvar.NewInt("storage_full_events")
+
+// IsStorageFull reports whether err was caused by the volume running out of space
+func IsStorageFull(err error) bool {
+	return errors.Is(err, syscall.ENOSPC)
+}
+
+// MarkStorageFull records a disk-full error: new downloads are refused until space
+// recovers, and an emergency cleanup pass starts unless one is already running
+func MarkStorageFull() {
+	storageFullEvents.Add(1)
+	if !storageFull.Swap(true) {
+		storageLog.Error("storage full, refusing new downloads", "dir", config.StorageDir)
+	}
+
+	if emergencyCleanupRunning.CompareAndSwap(false, true) {
+		go func() {
+			defer emergencyCleanupRunning.Store(false)
+			runRecovered("emergency cleanup", EmergencyCleanup)
+		}()
+	}
+}
+
+// StorageFull reports whether new downloads should be refused after a disk-full error
+// The flag clears once StorageDir has StorageRecoveryFree bytes available.
+func StorageFull() bool {
+	if !storageFull.Load() {
+		return false
+	}
+	if free, err := StorageFreeBytes(); err == nil && free >= config.StorageRecoveryFree {
+		if storageFull.Swap(false) {
+			storageLog.Info("storage recovered, accepting downloads again", "freeMB", free/(1024*1024))
+		}
+		return false
+	}
+	return true
+}
+
+// StorageFreeBytes returns the space available to the process on the StorageDir volume
+func StorageFreeBytes() (int64, error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(config.StorageDir, &stat); err != nil {
+		return 0, err
+	}
+	return int64(stat.Bavail) * int64(stat.Bsize), nil
+}
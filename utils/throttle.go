@@ -0,0 +1,97 @@
+package utils
+
+import (
+	"io"
+	"sync"
+	"time"
+)
+
+// throttleChunk bounds the bytes read per call so limited transfers are paced smoothly
+const throttleChunk = 16 * 1024
+
+// RateLimiter paces a byte stream to a rate (bytes/s); safe for concurrent transfers
+type RateLimiter struct {
+	mu   sync.Mutex
+	rate int64
+	next time.Time // When the bytes reserved so far have been sent at rate
+}
+
+// NewRateLimiter returns a limiter for rate bytes per second
+func NewRateLimiter(rate int64) *RateLimiter {
+	return &RateLimiter{rate: rate}
+}
+
+// Wait blocks until n more bytes fit within the rate
+func (l *RateLimiter) Wait(n int) {
+	l.mu.Lock()
+	now := time.Now()
+	start := l.next
+	if start.Before(now) {
+		start = now
+	}
+	l.next = start.Add(time.Duration(int64(n) * int64(time.Second) / l.rate))
+	l.mu.Unlock()
+
+	time.Sleep(time.Until(start))
+}
+
+// ipLimiters shares one limiter among the concurrent transfers of a client IP
+var ipLimiters = struct {
+	mu      sync.Mutex
+	entries map[string]*ipLimiter
+}{entries: map[string]*ipLimiter{}}
+
+type ipLimiter struct {
+	limiter *RateLimiter
+	refs    int
+}
+
+// AcquireIPLimiter returns the IP's shared limiter; release drops it after the last transfer ends
+func AcquireIPLimiter(ip string, rate int64) (limiter *RateLimiter, release func()) {
+	ipLimiters.mu.Lock()
+	defer ipLimiters.mu.Unlock()
+
+	entry := ipLimiters.entries[ip]
+	if entry == nil {
+		entry = &ipLimiter{limiter: NewRateLimiter(rate)}
+		ipLimiters.entries[ip] = entry
+	}
+	entry.refs++
+
+	return entry.limiter, func() {
+		ipLimiters.mu.Lock()
+		defer ipLimiters.mu.Unlock()
+		if entry.refs--; entry.refs == 0 {
+			delete(ipLimiters.entries, ip)
+		}
+	}
+}
+
+// ThrottledReader paces reads through every limiter; Close runs onClose and closes the reader
+type ThrottledReader struct {
+	Reader   io.Reader
+	Limiters []*RateLimiter
+	OnClose  func()
+}
+
+func (r *ThrottledReader) Read(p []byte) (int, error) {
+	if len(p) > throttleChunk {
+		p = p[:throttleChunk]
+	}
+	n, err := r.Reader.Read(p)
+	for _, limiter := range r.Limiters {
+		limiter.Wait(n)
+	}
+	return n, err
+}
+
+func (r *ThrottledReader) Close() error {
+	if r.OnClose != nil {
+		r.OnClose()
+		r.OnClose = nil
+	}
+	if closer, ok := r.Reader.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
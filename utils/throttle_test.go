@@ -0,0 +1,63 @@
+package utils
+
+import (
+	"bytes"
+	"io"
+	"sync"
+	"testing"
+	"time"
+)
+
+// minThrottledDuration is the least time a transfer of size bytes at rate takes: every byte
+// but the last read is paced before the next read may start
+func minThrottledDuration(size int, rate int64) time.Duration {
+	return time.Duration(int64(size-throttleChunk) * int64(time.Second) / rate)
+}
+
+func TestThrottledReaderTakesExpectedTime(t *testing.T) {
+	const size, rate = 96 * 1024, 160 * 1024
+	reader := &ThrottledReader{
+		Reader:   bytes.NewReader(make([]byte, size)),
+		Limiters: []*RateLimiter{NewRateLimiter(rate)},
+	}
+
+	start := time.Now()
+	n, err := io.Copy(io.Discard, reader)
+	elapsed := time.Since(start)
+	if err != nil || n != size {
+		t.Fatalf("copied %d bytes (err %v), want %d", n, err, size)
+	}
+	if want := minThrottledDuration(size, rate); elapsed < want {
+		t.Errorf("transfer took %v, want at least %v", elapsed, want)
+	}
+}
+
+func TestIPLimiterSharedAcrossTransfers(t *testing.T) {
+	const size, rate = 48 * 1024, 160 * 1024
+	const ip = "203.0.113.7"
+
+	var wg sync.WaitGroup
+	start := time.Now()
+	for range 2 {
+		limiter, release := AcquireIPLimiter(ip, rate)
+		reader := &ThrottledReader{Reader: bytes.NewReader(make([]byte, size)), Limiters: []*RateLimiter{limiter}, OnClose: release}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer reader.Close()
+			io.Copy(io.Discard, reader)
+		}()
+	}
+	wg.Wait()
+
+	// Both transfers share the rate: together they take as long as one of twice the size
+	if elapsed, want := time.Since(start), minThrottledDuration(2*size, rate); elapsed < want {
+		t.Errorf("parallel transfers took %v, want at least %v", elapsed, want)
+	}
+
+	ipLimiters.mu.Lock()
+	defer ipLimiters.mu.Unlock()
+	if _, ok := ipLimiters.entries[ip]; ok {
+		t.Error("IP limiter kept after its last transfer closed")
+	}
+}
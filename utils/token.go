@@ -0,0 +1,157 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"time"
+	"yt-downloader-go/config"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Token scopes
+const (
+	ScopeStatus   = "status"
+	ScopeDownload = "download" // files and stream
+)
+
+// URLToken is the signed payload of a compact URL token (?t=<payload>.<signature>)
+type URLToken struct {
+	Job   string `json:"j"`
+	File  string `json:"f,omitempty"` // Empty for status and stream
+	Exp   int64  `json:"e"`
+	Scope string `json:"s"`
+	Bind  string `json:"b,omitempty"` // Binding mode; the client hash is mixed into the signature
+}
+
+// GenerateToken encodes and signs a compact token with the primary secret
+func GenerateToken(payload URLToken, binding string) string {
+	if mode, _, found := strings.Cut(binding, ":"); found {
+		payload.Bind = mode
+	}
+	data, _ := json.Marshal(payload)
+	encoded := base64.RawURLEncoding.EncodeToString(data)
+	return encoded + "." + signToken(config.SignedURLSecrets[0], encoded, binding)
+}
+
+// ParseToken verifies a compact token against all accepted secrets and returns its payload
+// binding is recomputed from the client for the mode found in the payload
+func ParseToken(c *fiber.Ctx, token string) (*URLToken, bool) {
+	encoded, signature, found := strings.Cut(token, ".")
+	if !found {
+		return nil, false
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, false
+	}
+	var payload URLToken
+	if err := json.Unmarshal(data, &payload); err != nil {
+		return nil, false
+	}
+
+	binding := requestBindingForMode(c, payload.Bind)
+	valid := matchesAnySecret(signature, func(secret string) string {
+		return signToken(secret, encoded, binding)
+	})
+	if !valid {
+		return &payload, false
+	}
+	return &payload, true
+}
+
+// TokenFile returns the file a compact token is issued for, without verifying it ("" if
+// malformed); AuthorizeRequest verifies the token for that file
+func TokenFile(token string) string {
+	encoded, _, _ := strings.Cut(token, ".")
+	data, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return ""
+	}
+	var payload URLToken
+	if err := json.Unmarshal(data, &payload); err != nil {
+		return ""
+	}
+	return payload.File
+}
+
+// ValidToken reports whether token is a valid, unexpired compact token for the scope,
+// job and file, without writing a response (member tokens in POST /api/groups)
+func ValidToken(c *fiber.Ctx, token, scope, jobID, filename string) bool {
+	payload, valid := ParseToken(c, token)
+	return valid && payload.Job == jobID && payload.Scope == scope && payload.File == filename && time.Now().Unix() <= payload.Exp
+}
+
+// signToken returns the truncated base64url HMAC-SHA256 of the encoded payload and binding
+func signToken(secret, encoded, binding string) string {
+	h := hmac.New(sha256.New, []byte(secret))
+	h.Write([]byte(encoded + bindSuffix(binding)))
+	return base64.RawURLEncoding.EncodeToString(h.Sum(nil)[:16])
+}
+
+// authorizedExpiryKey holds the expiry of the token accepted by AuthorizeRequest
+const authorizedExpiryKey = "authorizedExpiry"
+
+// AuthorizedExpiry returns the Unix expiry of the request's accepted token (0 if none)
+func AuthorizedExpiry(c *fiber.Ctx) int64 {
+	exp, _ := c.Locals(authorizedExpiryKey).(int64)
+	return exp
+}
+
+// AuthorizeRequest validates the compact token (?t=) or the legacy token+expires
+// parameters for a scope, job and file (empty for status and stream).
+// When it returns false the error response has already been written; return err.
+func AuthorizeRequest(c *fiber.Ctx, scope, jobID, filename string) (bool, error) {
+	if t := c.Query("t"); t != "" {
+		payload, valid := ParseToken(c, t)
+		switch {
+		case payload == nil:
+			return false, Forbidden(c, "Invalid token")
+		case payload.Job != jobID || payload.Scope != scope || payload.File != filename:
+			return false, Forbidden(c, "Token is not valid for this resource")
+		case time.Now().Unix() > payload.Exp:
+			return false, Forbidden(c, "Token has expired")
+		case !valid && payload.Bind != "":
+			return false, Error(c, fiber.StatusForbidden, ErrClientMismatch, "Link is bound to another client")
+		case !valid:
+			return false, Forbidden(c, "Invalid token")
+		}
+		c.Locals(authorizedExpiryKey, payload.Exp)
+		return true, nil
+	}
+
+	// Legacy two-parameter URLs (deprecated)
+	token := c.Query("token")
+	expiresStr := c.Query("expires")
+	if token == "" || expiresStr == "" {
+		return false, Unauthorized(c, "Missing token")
+	}
+
+	expires, err := ParseExpires(expiresStr)
+	if err != nil {
+		return false, BadRequest(c, ErrInvalidExpires, "Invalid expires parameter")
+	}
+
+	var valid bool
+	switch {
+	case scope == ScopeStatus:
+		valid = ValidateStatusURL(jobID, token, expires)
+	case filename == "":
+		valid = ValidateStreamURL(jobID, token, expires, RequestBinding(c))
+	default:
+		valid = ValidateSignedURL(jobID, filename, token, expires, RequestBinding(c))
+	}
+
+	if !valid {
+		if IsBoundURL(c, expires) {
+			return false, Error(c, fiber.StatusForbidden, ErrClientMismatch, "Link is bound to another client")
+		}
+		return false, Forbidden(c, "Invalid or expired token")
+	}
+	c.Locals(authorizedExpiryKey, expires)
+	return true, nil
+}
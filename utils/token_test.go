@@ -0,0 +1,164 @@
+package utils
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+	"yt-downloader-go/config"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const testJobID = "V1StGXR8_Z5jdHi6B-myT"
+
+// useSecrets sets SIGNED_URL_SECRETS for the duration of the test
+func useSecrets(t *testing.T, secrets ...string) {
+	t.Helper()
+	prev := config.SignedURLSecrets
+	config.SignedURLSecrets = secrets
+	t.Cleanup(func() { config.SignedURLSecrets = prev })
+}
+
+// fileStatus requests a job file through AuthorizeRequest and returns the response status
+func fileStatus(t *testing.T, query string) int {
+	t.Helper()
+	app := fiber.New()
+	app.Get("/files/:id/:file", func(c *fiber.Ctx) error {
+		if ok, err := AuthorizeRequest(c, ScopeDownload, c.Params("id"), c.Params("file")); !ok {
+			return err
+		}
+		return c.SendString("ok")
+	})
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/files/"+testJobID+"/output.mp3?"+query, nil))
+	if err != nil {
+		t.Fatalf("request: %v", err)
+	}
+	return resp.StatusCode
+}
+
+func TestSecretRotation(t *testing.T) {
+	useSecrets(t, "old-secret")
+	oldToken := fileToken(testJobID, "output.mp3", "")
+
+	// Overlap window: the new secret signs, the old one still validates
+	config.SignedURLSecrets = []string{"new-secret", "old-secret"}
+	if status := fileStatus(t, "t="+oldToken); status != fiber.StatusOK {
+		t.Errorf("old token during rotation: status %d, want 200", status)
+	}
+	newToken := fileToken(testJobID, "output.mp3", "")
+	if status := fileStatus(t, "t="+newToken); status != fiber.StatusOK {
+		t.Errorf("new token during rotation: status %d, want 200", status)
+	}
+	encoded, signature, _ := strings.Cut(newToken, ".")
+	if signature != signToken("new-secret", encoded, "") {
+		t.Error("new token is not signed with the first secret")
+	}
+
+	// Old secret removed: only new tokens validate
+	config.SignedURLSecrets = []string{"new-secret"}
+	if status := fileStatus(t, "t="+oldToken); status != fiber.StatusForbidden {
+		t.Errorf("old token after rotation: status %d, want 403", status)
+	}
+	if status := fileStatus(t, "t="+newToken); status != fiber.StatusOK {
+		t.Errorf("new token after rotation: status %d, want 200", status)
+	}
+}
+
+func TestLegacySecretRotation(t *testing.T) {
+	useSecrets(t, "new-secret", "old-secret")
+	expires := time.Now().Add(time.Hour).Unix()
+	query := func(secret string) string {
+		return fmt.Sprintf("token=%s&expires=%d", generateToken(secret, testJobID, "output.mp3", expires, ""), expires)
+	}
+
+	if status := fileStatus(t, query("old-secret")); status != fiber.StatusOK {
+		t.Errorf("legacy URL under the old secret: status %d, want 200", status)
+	}
+	if status := fileStatus(t, query("other-secret")); status != fiber.StatusForbidden {
+		t.Errorf("legacy URL under an unknown secret: status %d, want 403", status)
+	}
+	if !ValidateStatusURL(testJobID, generateStatusToken("old-secret", testJobID, expires), expires) {
+		t.Error("legacy status URL under the old secret rejected")
+	}
+}
+
+// tamper re-encodes the payload of token after edit, keeping its signature
+func tamper(t *testing.T, token string, edit func(*URLToken)) string {
+	t.Helper()
+	encoded, signature, _ := strings.Cut(token, ".")
+	data, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var payload URLToken
+	if err := json.Unmarshal(data, &payload); err != nil {
+		t.Fatal(err)
+	}
+	edit(&payload)
+	data, _ = json.Marshal(payload)
+	return base64.RawURLEncoding.EncodeToString(data) + "." + signature
+}
+
+func TestTamperedTokens(t *testing.T) {
+	useSecrets(t, "secret")
+	exp := time.Now().Add(time.Hour).Unix()
+	issue := func(job, file, scope string, exp int64, binding string) string {
+		return GenerateToken(URLToken{Job: job, File: file, Exp: exp, Scope: scope}, binding)
+	}
+	valid := issue(testJobID, "output.mp3", ScopeDownload, exp, "")
+	bound := issue(testJobID, "output.mp3", ScopeDownload, exp, ClientBinding(BindSession, "session-1"))
+	encoded, signature, _ := strings.Cut(valid, ".")
+
+	tests := []struct {
+		name  string
+		query string
+		want  int
+	}{
+		{name: "valid", query: "t=" + valid, want: fiber.StatusOK},
+		{name: "valid bound", query: "t=" + bound + "&session=session-1", want: fiber.StatusOK},
+		{name: "expired", query: "t=" + issue(testJobID, "output.mp3", ScopeDownload, time.Now().Add(-time.Minute).Unix(), ""), want: fiber.StatusForbidden},
+		{name: "other job's token", query: "t=" + issue("Uakgb_J5m9g-0JDMbcJqL", "output.mp3", ScopeDownload, exp, ""), want: fiber.StatusForbidden},
+		{
+			name:  "job changed",
+			query: "t=" + tamper(t, issue("Uakgb_J5m9g-0JDMbcJqL", "output.mp3", ScopeDownload, exp, ""), func(p *URLToken) { p.Job = testJobID }),
+			want:  fiber.StatusForbidden,
+		},
+		{
+			name:  "file changed",
+			query: "t=" + tamper(t, issue(testJobID, "meta.json", ScopeDownload, exp, ""), func(p *URLToken) { p.File = "output.mp3" }),
+			want:  fiber.StatusForbidden,
+		},
+		{
+			name:  "scope changed",
+			query: "t=" + tamper(t, issue(testJobID, "output.mp3", ScopeStatus, exp, ""), func(p *URLToken) { p.Scope = ScopeDownload }),
+			want:  fiber.StatusForbidden,
+		},
+		{
+			name:  "exp extended",
+			query: "t=" + tamper(t, issue(testJobID, "output.mp3", ScopeDownload, time.Now().Add(-time.Minute).Unix(), ""), func(p *URLToken) { p.Exp = exp }),
+			want:  fiber.StatusForbidden,
+		},
+		{name: "bound to another session", query: "t=" + bound + "&session=session-2", want: fiber.StatusForbidden},
+		{name: "bind stripped", query: "t=" + tamper(t, bound, func(p *URLToken) { p.Bind = "" }), want: fiber.StatusForbidden},
+		{name: "bind altered", query: "t=" + tamper(t, bound, func(p *URLToken) { p.Bind = BindIP }), want: fiber.StatusForbidden},
+		{name: "truncated signature", query: "t=" + valid[:len(valid)-4], want: fiber.StatusForbidden},
+		{name: "signature of another secret", query: "t=" + encoded + "." + signToken("other-secret", encoded, ""), want: fiber.StatusForbidden},
+		{name: "altered signature", query: "t=" + encoded + "." + strings.ToUpper(signature), want: fiber.StatusForbidden},
+		{name: "no signature", query: "t=" + encoded, want: fiber.StatusForbidden},
+		{name: "bad base64", query: "t=" + "!!" + encoded + "." + signature, want: fiber.StatusForbidden},
+		{name: "payload not JSON", query: "t=" + base64.RawURLEncoding.EncodeToString([]byte("not json")) + "." + signature, want: fiber.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if status := fileStatus(t, tt.query); status != tt.want {
+				t.Errorf("status %d, want %d", status, tt.want)
+			}
+		})
+	}
+}
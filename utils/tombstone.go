@@ -0,0 +1,86 @@
+package utils
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+	"yt-downloader-go/config"
+	"yt-downloader-go/models"
+)
+
+// Tombstone records a soft-deleted job after cleanup removed its directory, so requests for
+// it get 410 with the deletion time instead of 404 for TOMBSTONE_TTL
+type Tombstone struct {
+	JobID     string `json:"jobId"`
+	DeletedAt int64  `json:"deletedAt"` // Soft delete time (ms)
+	RemovedAt int64  `json:"removedAt"` // Directory removal time (ms)
+}
+
+// getTombstonePath returns the tombstone file of a job
+func getTombstonePath(jobID string) string {
+	return filepath.Join(config.TombstoneDir, jobID+".json")
+}
+
+// writeDeletedTombstone leaves a tombstone for a soft-deleted job about to be removed
+// Jobs removed on expiry without a delete get none.
+func writeDeletedTombstone(meta *models.Meta) {
+	if !IsDeleted(meta) {
+		return
+	}
+	if err := os.MkdirAll(config.TombstoneDir, 0755); err != nil {
+		storageLog.Warn("failed to write tombstone", "job", meta.ID, "err", err)
+		return
+	}
+
+	data, _ := json.Marshal(Tombstone{JobID: meta.ID, DeletedAt: meta.DeletedAt, RemovedAt: time.Now().UnixMilli()})
+	path := getTombstonePath(meta.ID)
+	if err := os.WriteFile(path+".new", data, 0644); err != nil {
+		storageLog.Warn("failed to write tombstone", "job", meta.ID, "err", err)
+		return
+	}
+	os.Rename(path+".new", path)
+}
+
+// ReadTombstone returns the tombstone of a removed job, or nil if none is live
+func ReadTombstone(jobID string) *Tombstone {
+	data, err := os.ReadFile(getTombstonePath(jobID))
+	if err != nil {
+		return nil
+	}
+
+	var tombstone Tombstone
+	if err := json.Unmarshal(data, &tombstone); err != nil || !isLiveTombstone(&tombstone) {
+		return nil
+	}
+	return &tombstone
+}
+
+// isLiveTombstone reports whether a tombstone is within TOMBSTONE_TTL of the job's removal
+func isLiveTombstone(tombstone *Tombstone) bool {
+	return time.Since(time.UnixMilli(tombstone.RemovedAt)) <= config.TombstoneTTL
+}
+
+// CleanupTombstones removes tombstones past TOMBSTONE_TTL
+func CleanupTombstones() {
+	entries, err := os.ReadDir(config.TombstoneDir)
+	if err != nil {
+		return
+	}
+
+	for _, entry := range entries {
+		path := filepath.Join(config.TombstoneDir, entry.Name())
+		jobID, ok := strings.CutSuffix(entry.Name(), ".json")
+		if !ok || !ValidateJobID(jobID) {
+			// Interrupted writes (.new) and anything else
+			if info, err := entry.Info(); err == nil && time.Since(info.ModTime()) > time.Minute {
+				os.Remove(path)
+			}
+			continue
+		}
+		if ReadTombstone(jobID) == nil {
+			os.Remove(path)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package utils
+
+import (
+	"cmp"
+	"expvar"
+	"slices"
+	"yt-downloader-go/models"
+)
+
+// UsageAnonymous is the usage account of jobs created without an API key
+const UsageAnonymous = "anonymous"
+
+// Bytes per usage account since start (exported via /debug/vars)
+var (
+	usageOriginBytes = expvar.NewMap("usage_origin_bytes")
+	usageServedBytes = expvar.NewMap("usage_served_bytes")
+)
+
+// UsageAccount returns the account the job's usage is billed to: its API key fingerprint, or UsageAnonymous
+func UsageAccount(meta *models.Meta) string {
+	if meta.UsageKey == "" {
+		return UsageAnonymous
+	}
+	return meta.UsageKey
+}
+
+// AggregateUsage sums the usage of metas per account, ordered by account
+func AggregateUsage(metas []*models.Meta) []models.KeyUsage {
+	byKey := map[string]*models.KeyUsage{}
+	for _, meta := range metas {
+		key := UsageAccount(meta)
+		usage := byKey[key]
+		if usage == nil {
+			usage = &models.KeyUsage{Key: key}
+			byKey[key] = usage
+		}
+		usage.Jobs++
+		usage.OriginBytes += meta.Usage.OriginBytes
+		usage.ServedBytes += meta.Usage.ServedBytes
+		usage.ServedCount += meta.Usage.ServedCount
+	}
+
+	keys := make([]models.KeyUsage, 0, len(byKey))
+	for _, usage := range byKey {
+		keys = append(keys, *usage)
+	}
+	slices.SortFunc(keys, func(a, b models.KeyUsage) int {
+		return cmp.Compare(a.Key, b.Key)
+	})
+	return keys
+}
@@ -2,17 +2,24 @@ package utils
 
 import (
 	"fmt"
+	"net/url"
 	"regexp"
 	"slices"
+	"strconv"
+	"strings"
 	"yt-downloader-go/config"
 	"yt-downloader-go/models"
 )
 
 var (
-	// YouTube URL patterns
-	youtubeURLPattern = regexp.MustCompile(`(?:youtube\.com\/(?:watch\?v=|embed\/|v\/|shorts\/)|youtu\.be\/)([a-zA-Z0-9_-]{11})`)
-	bitratePattern    = regexp.MustCompile(`^\d{1,3}k$`)
-	jobIDPattern      = regexp.MustCompile(config.JobIDRegex)
+	// YouTube URL patterns (www., m. and music. hosts; watch, embed, v, shorts, live paths)
+	youtubeURLPattern = regexp.MustCompile(`(?:^|[/.])(?:youtube\.com\/(?:watch\?(?:[^#]*&)?v=|embed\/|v\/|shorts\/|live\/)|youtu\.be\/)([a-zA-Z0-9_-]{11})(?:[^a-zA-Z0-9_-]|$)`)
+	// Bare video ID (strict charset so arbitrary strings never reach the extract API)
+	videoIDPattern   = regexp.MustCompile(`^[a-zA-Z0-9_-]{11}$`)
+	bitratePattern   = regexp.MustCompile(`^\d{1,6}k$`)
+	vbrPattern       = regexp.MustCompile(`^V[0-9]$`)
+	timestampPattern = regexp.MustCompile(`^(?:(\d+)h)?(?:(\d+)m)?(?:(\d+)s)?$`)
+	jobIDPattern     = regexp.MustCompile(config.JobIDRegex)
 )
 
 // ValidationError represents a validation error
@@ -25,21 +32,113 @@ func (e ValidationError) Error() string {
 	return fmt.Sprintf("%s: %s", e.Field, e.Message)
 }
 
-// ExtractVideoID extracts the video ID from a YouTube URL
+// ExtractVideoID extracts the video ID from a YouTube URL or a bare 11-character ID
 func ExtractVideoID(url string) (string, error) {
+	url = strings.TrimSpace(url)
+	if videoIDPattern.MatchString(url) {
+		return url, nil
+	}
+
 	matches := youtubeURLPattern.FindStringSubmatch(url)
 	if len(matches) < 2 {
-		return "", ValidationError{Field: "url", Message: "Invalid YouTube URL"}
+		return "", ValidationError{Field: "url", Message: "Invalid YouTube URL (e.g. https://www.youtube.com/watch?v=dQw4w9WgXcQ or dQw4w9WgXcQ)"}
 	}
 	return matches[1], nil
 }
 
+// ParseYouTubeURL extracts the video ID and the start time from the t/start parameter
+// (query or fragment, e.g. "?t=90", "&t=1m30s", "#t=1h2m3s"). Malformed times are ignored (0).
+func ParseYouTubeURL(rawURL string) (string, float64, error) {
+	videoID, err := ExtractVideoID(rawURL)
+	if err != nil {
+		return "", 0, err
+	}
+
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return videoID, 0, nil
+	}
+
+	query := parsed.Query()
+	fragment, _ := url.ParseQuery(parsed.Fragment)
+
+	for _, value := range []string{query.Get("t"), query.Get("start"), fragment.Get("t"), fragment.Get("start")} {
+		if value == "" {
+			continue
+		}
+		if seconds, ok := parseTimestamp(value); ok {
+			return videoID, seconds, nil
+		}
+	}
+
+	return videoID, 0, nil
+}
+
+// parseTimestamp parses "90", "90s", "1m30s" or "1h2m3s" into seconds
+func parseTimestamp(value string) (float64, bool) {
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return float64(seconds), seconds >= 0
+	}
+
+	matches := timestampPattern.FindStringSubmatch(value)
+	if matches == nil || value == "" {
+		return 0, false
+	}
+
+	var total int
+	for i, unit := range []int{3600, 60, 1} {
+		if matches[i+1] == "" {
+			continue
+		}
+		n, err := strconv.Atoi(matches[i+1])
+		if err != nil {
+			return 0, false
+		}
+		total += n * unit
+	}
+	return float64(total), true
+}
+
+// validateBitrate normalizes audio.bitrate ("0192k" -> "192k") and checks it against the
+// output encoder's range, clamping out-of-range values unless audio.strict is set
+func validateBitrate(req *models.DownloadRequest, warnings *models.Warnings) error {
+	if !bitratePattern.MatchString(req.Audio.Bitrate) {
+		return ValidationError{Field: "audio.bitrate", Message: "Invalid bitrate format. Must be like '192k'"}
+	}
+	kbps, _ := strconv.Atoi(strings.TrimSuffix(req.Audio.Bitrate, "k"))
+	if kbps == 0 {
+		return ValidationError{Field: "audio.bitrate", Message: "Bitrate must be greater than 0"}
+	}
+
+	// The encoder (and so the range) is only known for an explicit format
+	encoder := config.AudioCodecOverrideMap[req.Audio.Codec]
+	if encoder == "" {
+		encoder = config.AudioCodecMap[req.Output.Format]
+	}
+	if r, ok := config.BitrateRanges[encoder]; ok && (kbps < r.Min || kbps > r.Max) {
+		if req.Audio.Strict {
+			return ValidationError{Field: "audio.bitrate", Message: fmt.Sprintf("Bitrate for %s must be between %dk and %dk", req.Output.Format, r.Min, r.Max)}
+		}
+		clamped := min(max(kbps, r.Min), r.Max)
+		warnings.Add(models.WarnBitrateClamped, "audio.bitrate",
+			fmt.Sprintf("%dk is outside %dk-%dk for %s; using %dk", kbps, r.Min, r.Max, req.Output.Format, clamped))
+		kbps = clamped
+	}
+
+	req.Audio.Bitrate = fmt.Sprintf("%dk", kbps)
+	return nil
+}
+
 // ValidateDownloadRequest validates the download request
-func ValidateDownloadRequest(req *models.DownloadRequest) error {
+// Values it adjusts instead of rejecting are added to warnings (may be nil).
+func ValidateDownloadRequest(req *models.DownloadRequest, warnings *models.Warnings) error {
 	// Validate URL
 	if req.URL == "" {
 		return ValidationError{Field: "url", Message: "URL is required"}
 	}
+	if len(req.URL) > config.MaxURLLength {
+		return ValidationError{Field: "url", Message: fmt.Sprintf("URL must be at most %d characters", config.MaxURLLength)}
+	}
 	if _, err := ExtractVideoID(req.URL); err != nil {
 		return err
 	}
@@ -54,14 +153,68 @@ func ValidateDownloadRequest(req *models.DownloadRequest) error {
 		return ValidationError{Field: "output.type", Message: "Must be 'video' or 'audio'"}
 	}
 
+	// audioOnly turns a video-format request into audio extraction
+	if req.Output.AudioOnly {
+		if req.Output.Type != "video" {
+			return ValidationError{Field: "output.audioOnly", Message: "Only valid with output.type 'video'; audio jobs already extract audio"}
+		}
+		audioFormat, ok := config.AudioOnlyFormats[req.Output.Format]
+		if !ok {
+			return ValidationError{Field: "output.format", Message: fmt.Sprintf("Invalid video format. Must be one of: %v or %s", config.VideoFormats, config.FormatAuto)}
+		}
+		req.Output.Type = "audio"
+		req.Output.Format = audioFormat
+		req.Output.Quality = ""
+	}
+
+	// Rotation handling only applies to video outputs
+	if req.Output.Type != "video" {
+		if req.Output.Autorotate != nil {
+			return ValidationError{Field: "output.autorotate", Message: "Only valid with output.type 'video'"}
+		}
+		if req.Output.ForceRotate {
+			return ValidationError{Field: "output.forceRotate", Message: "Only valid with output.type 'video'"}
+		}
+	}
+	if req.Output.ForceRotate && req.Output.Autorotate != nil && !*req.Output.Autorotate {
+		return ValidationError{Field: "output.forceRotate", Message: "Can't be combined with output.autorotate false"}
+	}
+
+	// Static video keeps its video container; the audio is rendered over the thumbnail
+	if req.Output.StaticVideo {
+		if req.Output.Type != "audio" {
+			return ValidationError{Field: "output.staticVideo", Message: "Only valid with output.type 'audio'"}
+		}
+		if !slices.Contains(config.StaticVideoFormats, req.Output.Format) {
+			return ValidationError{Field: "output.format", Message: fmt.Sprintf("staticVideo needs a video container. Must be one of: %v", config.StaticVideoFormats)}
+		}
+		if req.Audio.Codec != "" {
+			return ValidationError{Field: "audio.codec", Message: "Codec override is not supported with staticVideo"}
+		}
+		return validateRequestOptions(req, warnings)
+	}
+
+	// Normalize audio aliases (mp4 -> m4a)
+	if alias, ok := config.AudioFormatAliases[req.Output.Format]; ok && req.Output.Type == "audio" {
+		req.Output.Format = alias
+	}
+
 	// Validate format
 	if req.Output.Type == "video" {
-		if !slices.Contains(config.VideoFormats, req.Output.Format) {
-			return ValidationError{Field: "output.format", Message: fmt.Sprintf("Invalid video format. Must be one of: %v", config.VideoFormats)}
+		if req.Output.Format != config.FormatAuto && !slices.Contains(config.VideoFormats, req.Output.Format) {
+			message := fmt.Sprintf("Invalid video format. Must be one of: %v or %s", config.VideoFormats, config.FormatAuto)
+			if slices.Contains(config.AudioFormats, req.Output.Format) {
+				message += fmt.Sprintf(". %s is an audio format: use output.type 'audio', or a video format with audioOnly", req.Output.Format)
+			}
+			return ValidationError{Field: "output.format", Message: message}
 		}
 	} else {
-		if !slices.Contains(config.AudioFormats, req.Output.Format) {
-			return ValidationError{Field: "output.format", Message: fmt.Sprintf("Invalid audio format. Must be one of: %v", config.AudioFormats)}
+		if req.Output.Format != config.FormatAuto && !slices.Contains(config.AudioFormats, req.Output.Format) {
+			message := fmt.Sprintf("Invalid audio format. Must be one of: %v, mp4 (alias of m4a) or %s", config.AudioFormats, config.FormatAuto)
+			if slices.Contains(config.VideoFormats, req.Output.Format) {
+				message += fmt.Sprintf(". For audio use format %s, or output.type 'video' with audioOnly", config.AudioOnlyFormats[req.Output.Format])
+			}
+			return ValidationError{Field: "output.format", Message: message}
 		}
 	}
 
@@ -72,9 +225,62 @@ func ValidateDownloadRequest(req *models.DownloadRequest) error {
 		}
 	}
 
+	return validateRequestOptions(req, warnings)
+}
+
+// validateRequestOptions validates the audio, priority and trim options of a request
+func validateRequestOptions(req *models.DownloadRequest, warnings *models.Warnings) error {
 	// Validate bitrate if provided
-	if req.Audio.Bitrate != "" && !bitratePattern.MatchString(req.Audio.Bitrate) {
-		return ValidationError{Field: "audio.bitrate", Message: "Invalid bitrate format. Must be like '192k'"}
+	if req.Audio.Bitrate != "" {
+		if err := validateBitrate(req, warnings); err != nil {
+			return err
+		}
+	}
+
+	if req.Audio.VBR != "" {
+		if !vbrPattern.MatchString(req.Audio.VBR) {
+			return ValidationError{Field: "audio.vbr", Message: "Invalid VBR quality. Must be V0 to V9"}
+		}
+		if req.Audio.Bitrate != "" {
+			return ValidationError{Field: "audio.vbr", Message: "vbr and bitrate are mutually exclusive"}
+		}
+		if req.Output.Type != "audio" || !slices.Contains(config.VBRFormats, req.Output.Format) {
+			return ValidationError{Field: "audio.vbr", Message: fmt.Sprintf("VBR is only supported for audio output in: %v", config.VBRFormats)}
+		}
+	}
+
+	if req.Audio.Codec != "" {
+		codecs, ok := config.AudioCodecOptions[req.Output.Format]
+		if req.Output.Type != "audio" || !ok {
+			return ValidationError{Field: "audio.codec", Message: "Codec override is only supported for ogg, m4a and m4b audio output"}
+		}
+		if !slices.Contains(codecs, req.Audio.Codec) {
+			return ValidationError{Field: "audio.codec", Message: fmt.Sprintf("Invalid codec for %s. Must be one of: %v", req.Output.Format, codecs)}
+		}
+	}
+
+	if req.Priority != "" && !slices.Contains([]string{models.PriorityLow, models.PriorityNormal, models.PriorityHigh}, req.Priority) {
+		return ValidationError{Field: "priority", Message: "Invalid priority. Must be one of: low, normal, high"}
+	}
+
+	if req.Audio.AutoTrimSilence && req.Output.Type != "audio" {
+		return ValidationError{Field: "audio.autoTrimSilence", Message: "Silence auto-trim is only supported for audio output"}
+	}
+
+	if req.Audio.Channels != "" {
+		if !slices.Contains([]string{models.ChannelModeKeep, models.ChannelModeStereo, models.ChannelModeMono}, req.Audio.Channels) {
+			return ValidationError{Field: "audio.channels", Message: "Invalid channels. Must be one of: keep, stereo, mono"}
+		}
+		if req.Output.Type != "audio" || req.Output.StaticVideo {
+			return ValidationError{Field: "audio.channels", Message: "Channels are only supported for audio output"}
+		}
+	}
+
+	if err := validateAudioEffects(req); err != nil {
+		return err
+	}
+	if err := validateMute(req); err != nil {
+		return err
 	}
 
 	// Validate trim if provided
@@ -89,8 +295,61 @@ func ValidateDownloadRequest(req *models.DownloadRequest) error {
 		if duration > config.MaxTrimDuration.Seconds() {
 			return ValidationError{Field: "trim", Message: fmt.Sprintf("Trim duration must be <= %v", config.MaxTrimDuration)}
 		}
+		if fade := req.Trim.Fade; fade != nil {
+			if fade.In < 0 || fade.In > config.MaxFadeDuration {
+				return ValidationError{Field: "trim.fade.in", Message: fmt.Sprintf("Fade in must be between 0 and %g seconds", config.MaxFadeDuration)}
+			}
+			if fade.Out < 0 || fade.Out > config.MaxFadeDuration {
+				return ValidationError{Field: "trim.fade.out", Message: fmt.Sprintf("Fade out must be between 0 and %g seconds", config.MaxFadeDuration)}
+			}
+		}
+	}
+
+	return nil
+}
+
+// validateAudioEffects validates audio.tempo and audio.pitchSemitones (0 = unchanged)
+func validateAudioEffects(req *models.DownloadRequest) error {
+	field := "audio.tempo"
+	switch {
+	case req.Audio.Tempo == 0 && req.Audio.PitchSemitones == 0:
+		return nil
+	case req.Audio.Tempo == 0:
+		field = "audio.pitchSemitones"
 	}
 
+	if req.Audio.Tempo != 0 && (req.Audio.Tempo < config.MinTempo || req.Audio.Tempo > config.MaxTempo) {
+		return ValidationError{Field: "audio.tempo", Message: fmt.Sprintf("Tempo must be between %g and %g", config.MinTempo, config.MaxTempo)}
+	}
+	if req.Audio.PitchSemitones < -config.MaxPitchSemitones || req.Audio.PitchSemitones > config.MaxPitchSemitones {
+		return ValidationError{Field: "audio.pitchSemitones", Message: fmt.Sprintf("Pitch must be between -%d and %d semitones", config.MaxPitchSemitones, config.MaxPitchSemitones)}
+	}
+	if req.Output.Type != "audio" || req.Output.StaticVideo {
+		return ValidationError{Field: field, Message: "Tempo and pitch are only supported for audio output"}
+	}
+	// Caption timings are not rescaled
+	if req.Audio.Transcript {
+		return ValidationError{Field: field, Message: "Can't be combined with audio.transcript"}
+	}
+	return nil
+}
+
+// validateMute validates audio.mute: a video output without audio, so no other audio option applies
+func validateMute(req *models.DownloadRequest) error {
+	if !req.Audio.Mute {
+		return nil
+	}
+	if req.Output.Type != "video" {
+		return ValidationError{Field: "audio.mute", Message: "Only valid with output.type 'video'"}
+	}
+	audio := req.Audio
+	audio.Mute = false
+	if audio != (models.AudioConfig{}) {
+		return ValidationError{Field: "audio.mute", Message: "Can't be combined with other audio options"}
+	}
+	if req.Trim != nil && req.Trim.Fade != nil && !req.Trim.Fade.Video {
+		return ValidationError{Field: "trim.fade.video", Message: "Required with audio.mute; there is no audio to fade"}
+	}
 	return nil
 }
 
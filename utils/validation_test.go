@@ -0,0 +1,202 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+	"yt-downloader-go/models"
+)
+
+func TestParseTimestamp(t *testing.T) {
+	tests := []struct {
+		value   string
+		seconds float64
+		ok      bool
+	}{
+		{"90", 90, true},
+		{"0", 0, true},
+		{"90s", 90, true},
+		{"1m30s", 90, true},
+		{"1m", 60, true},
+		{"2h", 7200, true},
+		{"1h5s", 3605, true},
+		{"1h2m3s", 3723, true},
+		{"", 0, false},
+		{"-5", 0, false},
+		{"1m30", 0, false},
+		{"1s2m", 0, false},
+		{"1.5", 0, false},
+		{"abc", 0, false},
+		{"1h 2m", 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			seconds, ok := parseTimestamp(tt.value)
+			if ok != tt.ok || (ok && seconds != tt.seconds) {
+				t.Errorf("parseTimestamp(%q) = %v, %v; want %v, %v", tt.value, seconds, ok, tt.seconds, tt.ok)
+			}
+		})
+	}
+}
+
+func TestParseYouTubeURL(t *testing.T) {
+	tests := []struct {
+		url   string
+		start float64
+	}{
+		{"https://youtu.be/dQw4w9WgXcQ?t=90", 90},
+		{"https://www.youtube.com/watch?v=dQw4w9WgXcQ&t=1m30s", 90},
+		{"https://www.youtube.com/watch?t=45s&v=dQw4w9WgXcQ", 45},
+		{"https://www.youtube.com/watch?v=dQw4w9WgXcQ#t=1h2m3s", 3723},
+		{"https://www.youtube.com/embed/dQw4w9WgXcQ?start=30", 30},
+		{"https://www.youtube.com/watch?v=dQw4w9WgXcQ&t=bogus", 0},
+		{"https://www.youtube.com/watch?v=dQw4w9WgXcQ&t=bogus&start=12", 12},
+		{"https://www.youtube.com/watch?v=dQw4w9WgXcQ", 0},
+		{"dQw4w9WgXcQ", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			videoID, start, err := ParseYouTubeURL(tt.url)
+			if err != nil {
+				t.Fatalf("ParseYouTubeURL: %v", err)
+			}
+			if videoID != "dQw4w9WgXcQ" || start != tt.start {
+				t.Errorf("got %q, %v; want dQw4w9WgXcQ, %v", videoID, start, tt.start)
+			}
+		})
+	}
+
+	if _, _, err := ParseYouTubeURL("https://vimeo.com/123456789?t=90"); err == nil {
+		t.Error("non-YouTube URL accepted")
+	}
+}
+
+func TestExtractVideoID(t *testing.T) {
+	accepted := []struct {
+		input string
+		want  string
+	}{
+		{"https://www.youtube.com/watch?v=dQw4w9WgXcQ", "dQw4w9WgXcQ"},
+		{"http://youtube.com/watch?v=dQw4w9WgXcQ", "dQw4w9WgXcQ"},
+		{"youtube.com/watch?v=dQw4w9WgXcQ", "dQw4w9WgXcQ"},
+		{"https://m.youtube.com/watch?v=dQw4w9WgXcQ", "dQw4w9WgXcQ"},
+		{"https://music.youtube.com/watch?v=dQw4w9WgXcQ&list=RDAMVMdQw4w9WgXcQ", "dQw4w9WgXcQ"},
+		{"https://www.youtube.com/watch?feature=share&v=dQw4w9WgXcQ", "dQw4w9WgXcQ"},
+		{"https://youtu.be/dQw4w9WgXcQ", "dQw4w9WgXcQ"},
+		{"https://youtu.be/dQw4w9WgXcQ?si=abc&t=90", "dQw4w9WgXcQ"},
+		{"https://www.youtube.com/embed/dQw4w9WgXcQ", "dQw4w9WgXcQ"},
+		{"https://www.youtube.com/v/dQw4w9WgXcQ", "dQw4w9WgXcQ"},
+		{"https://www.youtube.com/shorts/dQw4w9WgXcQ", "dQw4w9WgXcQ"},
+		{"https://www.youtube.com/live/dQw4w9WgXcQ?feature=share", "dQw4w9WgXcQ"},
+		{"https://m.youtube.com/shorts/dQw4w9WgXcQ", "dQw4w9WgXcQ"},
+		{"dQw4w9WgXcQ", "dQw4w9WgXcQ"},
+		{"  dQw4w9WgXcQ\n", "dQw4w9WgXcQ"},
+		{"a_b-C1d2E3f", "a_b-C1d2E3f"},
+		{"https://www.youtube.com/watch?v=a_b-C1d2E3f#t=10", "a_b-C1d2E3f"},
+	}
+	for _, tt := range accepted {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ExtractVideoID(tt.input)
+			if err != nil || got != tt.want {
+				t.Errorf("ExtractVideoID(%q) = %q, %v; want %q", tt.input, got, err, tt.want)
+			}
+		})
+	}
+
+	rejected := []string{
+		"",
+		"dQw4w9WgXc",
+		"dQw4w9WgXcQQ",
+		"dQw4w9WgXc!",
+		"dQw4w9 WgXcQ",
+		"https://www.youtube.com/watch?v=dQw4w9WgXc",
+		"https://www.youtube.com/watch?v=dQw4w9WgXcQQ",
+		"https://www.youtube.com/watch?vv=dQw4w9WgXcQ",
+		"https://www.youtube.com/watch#v=dQw4w9WgXcQ",
+		"https://www.youtube.com/watch?x=1#&v=dQw4w9WgXcQ",
+		"https://notyoutube.com/watch?v=dQw4w9WgXcQ",
+		"https://vimeo.com/123456789",
+		"https://www.youtube.com/channel/UCuAXFkgsw1L7xaCfnd5JJOw",
+		"https://www.youtube.com/playlist?list=PLrAXtmErZgOeiKm4sgNOknGvNjby9efdf",
+		"https://youtu.be/",
+		"not a url at all",
+	}
+	for _, input := range rejected {
+		t.Run("reject "+input, func(t *testing.T) {
+			got, err := ExtractVideoID(input)
+			if err == nil {
+				t.Fatalf("ExtractVideoID(%q) = %q, want an error", input, got)
+			}
+			if !strings.Contains(err.Error(), "watch?v=dQw4w9WgXcQ") {
+				t.Errorf("error %q has no example of an accepted form", err)
+			}
+		})
+	}
+}
+
+func TestValidateBitrate(t *testing.T) {
+	tests := []struct {
+		name    string
+		format  string
+		codec   string
+		bitrate string
+		strict  bool
+		want    string // Normalized bitrate; "" when rejected
+		err     string // Substring of the error message
+		clamped bool
+	}{
+		{name: "mp3 in range", format: "mp3", bitrate: "192k", want: "192k"},
+		{name: "mp3 leading zero", format: "mp3", bitrate: "0192k", want: "192k"},
+		{name: "mp3 lower bound", format: "mp3", bitrate: "32k", want: "32k"},
+		{name: "mp3 upper bound", format: "mp3", bitrate: "320k", want: "320k"},
+		{name: "mp3 above clamped", format: "mp3", bitrate: "999k", want: "320k", clamped: true},
+		{name: "mp3 below clamped", format: "mp3", bitrate: "8k", want: "32k", clamped: true},
+		{name: "mp3 above strict", format: "mp3", bitrate: "999k", strict: true, err: "between 32k and 320k"},
+		{name: "m4a aac upper bound", format: "m4a", bitrate: "512k", want: "512k"},
+		{name: "m4a aac above clamped", format: "m4a", bitrate: "640k", want: "512k", clamped: true},
+		{name: "m4b aac below strict", format: "m4b", bitrate: "16k", strict: true, err: "between 32k and 512k"},
+		{name: "opus lower bound", format: "opus", bitrate: "6k", want: "6k"},
+		{name: "opus below clamped", format: "opus", bitrate: "4k", want: "6k", clamped: true},
+		{name: "opus above strict", format: "opus", bitrate: "600k", strict: true, err: "between 6k and 510k"},
+		{name: "ogg vorbis below clamped", format: "ogg", codec: "vorbis", bitrate: "32k", want: "45k", clamped: true},
+		{name: "ogg vorbis in range", format: "ogg", codec: "vorbis", bitrate: "96k", want: "96k"},
+		{name: "m4a fdk above strict", format: "m4a", codec: "libfdk_aac", bitrate: "600k", strict: true, err: "between 32k and 512k"},
+		{name: "auto format unbounded", format: "auto", bitrate: "999k", want: "999k"},
+		{name: "flac unbounded", format: "flac", bitrate: "999k", want: "999k"},
+		{name: "zero", format: "mp3", bitrate: "0k", err: "greater than 0"},
+		{name: "no unit", format: "mp3", bitrate: "192", err: "Invalid bitrate format"},
+		{name: "mbps unit", format: "mp3", bitrate: "1M", err: "Invalid bitrate format"},
+		{name: "too many digits", format: "mp3", bitrate: "1234567k", err: "Invalid bitrate format"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := &models.DownloadRequest{}
+			req.Output.Type = "audio"
+			req.Output.Format = tt.format
+			req.Audio.Codec = tt.codec
+			req.Audio.Bitrate = tt.bitrate
+			req.Audio.Strict = tt.strict
+			var warnings models.Warnings
+
+			err := validateBitrate(req, &warnings)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("err = %v, want one containing %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("validateBitrate: %v", err)
+			}
+			if req.Audio.Bitrate != tt.want {
+				t.Errorf("bitrate = %q, want %q", req.Audio.Bitrate, tt.want)
+			}
+			clamped := len(warnings) == 1 && warnings[0].Code == models.WarnBitrateClamped && warnings[0].Field == "audio.bitrate"
+			if clamped != tt.clamped || (!tt.clamped && len(warnings) > 0) {
+				t.Errorf("warnings = %+v, want clamped %v", warnings, tt.clamped)
+			}
+		})
+	}
+}
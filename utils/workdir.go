@@ -0,0 +1,178 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+	"yt-downloader-go/config"
+	"yt-downloader-go/models"
+)
+
+// sourcePatterns match the downloaded sources of a job directory
+var sourcePatterns = []string{"video.*", "audio.*", config.CoverFileName}
+
+// GetWorkDir returns the directory a job's sources are downloaded to and its outputs
+// rendered in: TempDir/<jobID> with TEMP_DIR, otherwise the job directory itself
+func GetWorkDir(jobID string) string {
+	if config.TempDir == "" {
+		return GetJobDir(jobID)
+	}
+	return filepath.Join(config.TempDir, jobID)
+}
+
+// RemoveWorkDir removes a job's work directory (no-op without TEMP_DIR)
+func RemoveWorkDir(jobID string) error {
+	if config.TempDir == "" {
+		return nil
+	}
+	return os.RemoveAll(GetWorkDir(jobID))
+}
+
+// PromoteWorkFile moves a finished file of the job's work directory into the job directory
+func PromoteWorkFile(jobID string, name string) error {
+	if config.TempDir == "" {
+		return nil
+	}
+	return MoveFile(filepath.Join(GetWorkDir(jobID), name), filepath.Join(GetJobDir(jobID), name))
+}
+
+// promoteSources moves the sources left in the job's work directory into the job directory
+func promoteSources(jobID string) {
+	for _, pattern := range sourcePatterns {
+		matches, _ := filepath.Glob(filepath.Join(GetWorkDir(jobID), pattern))
+		for _, match := range matches {
+			if info, err := os.Stat(match); err != nil || info.IsDir() {
+				continue
+			}
+			if err := PromoteWorkFile(jobID, filepath.Base(match)); err != nil {
+				jobLog.Warn("failed to keep source", "job", jobID, "file", filepath.Base(match), "err", err)
+			}
+		}
+	}
+}
+
+// MoveFile renames src to dst. Across devices (EXDEV) src is copied to a temp file next to
+// dst, which is renamed into place, so dst never appears partially written; src is then removed.
+func MoveFile(src string, dst string) error {
+	err := os.Rename(src, dst)
+	if !errors.Is(err, syscall.EXDEV) {
+		return err
+	}
+
+	tmpPath := dst + ".tmp"
+	if err := copyFile(src, tmpPath); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := os.Rename(tmpPath, dst); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	return os.Remove(src)
+}
+
+// copyFile copies src to dst with its permissions, synced before returning
+func copyFile(src string, dst string) error {
+	srcFile, err := os.Open(src)
+	if err != nil {
+		return fmt.Errorf("open source failed: %w", err)
+	}
+	defer srcFile.Close()
+	info, err := srcFile.Stat()
+	if err != nil {
+		return fmt.Errorf("stat source failed: %w", err)
+	}
+
+	dstFile, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, info.Mode().Perm())
+	if err != nil {
+		return fmt.Errorf("create file failed: %w", err)
+	}
+	defer dstFile.Close()
+
+	bufPtr := GetBuffer()
+	defer PutBuffer(bufPtr)
+	if _, err := io.CopyBuffer(dstFile, srcFile, *bufPtr); err != nil {
+		return fmt.Errorf("copy failed: %w", err)
+	}
+	if err := dstFile.Sync(); err != nil {
+		return fmt.Errorf("sync failed: %w", err)
+	}
+	return dstFile.Close()
+}
+
+// CleanupWorkDirs reconciles TempDir with the jobs after a crash and on every cleanup run.
+// Work directories of jobs that are gone or completed are removed; those of unfinished
+// jobs no worker uses stay for OrphanChunkDirAge so a requeue can resume from them.
+// Abandoned source downloads are removed after the same time. Entries that aren't job
+// directories are left alone (TEMP_DIR may be shared).
+func CleanupWorkDirs() {
+	if config.TempDir == "" {
+		return
+	}
+	entries, err := os.ReadDir(config.TempDir)
+	if err != nil {
+		return
+	}
+
+	now := time.Now()
+	for _, entry := range entries {
+		jobID := entry.Name()
+		path := filepath.Join(config.TempDir, jobID)
+		if path == filepath.Clean(config.SourceWorkDir) {
+			cleanupSourceWork(now)
+			continue
+		}
+		if !entry.IsDir() || !ValidateJobID(jobID) || IsJobActive(jobID) {
+			continue
+		}
+
+		if JobExists(jobID) {
+			meta, err := ReadMeta(jobID)
+			if err != nil {
+				continue
+			}
+			if meta.Status != models.StatusCompleted && now.Sub(lastModified(path)) <= config.OrphanChunkDirAge {
+				continue
+			}
+		}
+		if err := os.RemoveAll(path); err != nil {
+			cleanupLog.Warn("failed to remove work dir", "job", jobID, "err", err)
+			continue
+		}
+		cleanupLog.Debug("removed orphaned work dir", "job", jobID)
+	}
+}
+
+// cleanupSourceWork removes source downloads in SourceWorkDir that no download of this
+// process is writing and that have been untouched for OrphanChunkDirAge
+func cleanupSourceWork(now time.Time) {
+	entries, err := os.ReadDir(config.SourceWorkDir)
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		path := filepath.Join(config.SourceWorkDir, entry.Name())
+		if _, active := trackedDownloadSize(sourceWorkBase(path)); active {
+			continue
+		}
+		if now.Sub(lastModified(path)) <= config.OrphanChunkDirAge {
+			continue
+		}
+		os.RemoveAll(path)
+	}
+}
+
+// sourceWorkBase returns the download destination a source work file belongs to
+func sourceWorkBase(path string) string {
+	for _, suffix := range []string{".chunks", ".tmp", config.SparseSidecarSuffix, config.SparseDownloadSuffix} {
+		if strings.HasSuffix(path, suffix) {
+			return strings.TrimSuffix(path, suffix)
+		}
+	}
+	return path
+}